@@ -0,0 +1,106 @@
+// Copyright 2019 Canonical Ltd.
+// Licensed under the LGPLv3 with static-linking exception.
+// See LICENCE file for details.
+
+package tcglog
+
+import (
+	"io"
+	"strings"
+)
+
+// Action corresponds to one of the action strings defined by the TCG specifications for EV_ACTION and EV_EFI_ACTION events.
+type Action int
+
+const (
+	UnknownAction Action = iota // The action string isn't one that is defined by the TCG specifications
+
+	// EV_ACTION strings
+	CallingInt19h      // "Calling INT 19h"
+	ReturnedInt19h     // "Returned INT 19h"
+	ReturnViaInt18h    // "Return via INT 18h"
+	StartOptionROMScan // "Start Option ROM Scan"
+
+	// EV_EFI_ACTION strings
+	CallingEFIApplication           // "Calling EFI Application from Boot Option"
+	ReturningFromEFIApplication     // "Returning from EFI Application from Boot Option"
+	ExitBootServicesInvocation      // "Exit Boot Services Invocation"
+	ExitBootServicesReturnedFailure // "Exit Boot Services Returned with Failure"
+	ExitBootServicesReturnedSuccess // "Exit Boot Services Returned with Success"
+	UEFIDebugMode                   // "UEFI Debug Mode"
+	DMAProtectionDisabled           // "DMA Protection Disabled"
+)
+
+type actionInfo struct {
+	str       string
+	eventType EventType
+}
+
+// https://trustedcomputinggroup.org/wp-content/uploads/TCG_PCClientImplementation_1-21_1_00.pdf (section 11.3.3 "EV_ACTION event types")
+// https://trustedcomputinggroup.org/wp-content/uploads/TCG_PCClientSpecPlat_TPM_2p0_1p04_pub.pdf (section 9.4.4 "EV_EFI_ACTION Strings")
+var actions = map[Action]actionInfo{
+	CallingInt19h:                   {"Calling INT 19h", EventTypeAction},
+	ReturnedInt19h:                  {"Returned INT 19h", EventTypeAction},
+	ReturnViaInt18h:                 {"Return via INT 18h", EventTypeAction},
+	StartOptionROMScan:              {"Start Option ROM Scan", EventTypeAction},
+	CallingEFIApplication:           {"Calling EFI Application from Boot Option", EventTypeEFIAction},
+	ReturningFromEFIApplication:     {"Returning from EFI Application from Boot Option", EventTypeEFIAction},
+	ExitBootServicesInvocation:      {"Exit Boot Services Invocation", EventTypeEFIAction},
+	ExitBootServicesReturnedFailure: {"Exit Boot Services Returned with Failure", EventTypeEFIAction},
+	ExitBootServicesReturnedSuccess: {"Exit Boot Services Returned with Success", EventTypeEFIAction},
+	UEFIDebugMode:                   {"UEFI Debug Mode", EventTypeEFIAction},
+	DMAProtectionDisabled:           {"DMA Protection Disabled", EventTypeEFIAction},
+}
+
+// String returns the action string for this action, or an empty string if it is UnknownAction.
+func (a Action) String() string {
+	return actions[a].str
+}
+
+// EventType returns the type of event that this action is defined for.
+func (a Action) EventType() EventType {
+	return actions[a].eventType
+}
+
+func lookupAction(s string) Action {
+	for a, info := range actions {
+		if info.str == s {
+			return a
+		}
+	}
+	return UnknownAction
+}
+
+// ActionEventData is the event data associated with EV_ACTION and EV_EFI_ACTION events, which is an ASCII string.
+type ActionEventData struct {
+	data      []byte
+	eventType EventType
+	Message   string // The action string, without any trailing NUL bytes
+	Action    Action // The action that corresponds to the string when it was decoded, or UnknownAction if it is non-standard
+}
+
+func (e *ActionEventData) String() string {
+	return EscapeString(e.Message)
+}
+
+func (e *ActionEventData) Bytes() []byte {
+	return e.data
+}
+
+// EncodeTo encodes this event data in to the form in which it appears in the event log. If Message hasn't been modified since
+// the event data was decoded, the original bytes are written, including any trailing NUL bytes. Otherwise, Message is written
+// without a NUL terminator, as the TCG specifications require.
+func (e *ActionEventData) EncodeTo(w io.Writer) error {
+	if e.data != nil && e.Message == strings.TrimRight(string(e.data), "\x00") {
+		_, err := w.Write(e.data)
+		return err
+	}
+	_, err := io.WriteString(w, e.Message)
+	return err
+}
+
+// IsStandard indicates whether the action string is one that is defined by the TCG specifications for the type of event that
+// it is associated with.
+func (e *ActionEventData) IsStandard() bool {
+	return e.Action != UnknownAction && e.Action.EventType() == e.eventType
+}
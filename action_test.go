@@ -0,0 +1,111 @@
+// Copyright 2019 Canonical Ltd.
+// Licensed under the LGPLv3 with static-linking exception.
+// See LICENCE file for details.
+
+package tcglog
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestDecodeActionEventData(t *testing.T) {
+	for _, data := range []struct {
+		desc      string
+		eventType EventType
+		data      string
+		action    Action
+		standard  bool
+	}{
+		{
+			desc:      "CallingEFIApplication",
+			eventType: EventTypeEFIAction,
+			data:      "Calling EFI Application from Boot Option",
+			action:    CallingEFIApplication,
+			standard:  true,
+		},
+		{
+			desc:      "ExitBootServicesInvocation",
+			eventType: EventTypeEFIAction,
+			data:      "Exit Boot Services Invocation",
+			action:    ExitBootServicesInvocation,
+			standard:  true,
+		},
+		{
+			desc:      "CallingInt19h",
+			eventType: EventTypeAction,
+			data:      "Calling INT 19h",
+			action:    CallingInt19h,
+			standard:  true,
+		},
+		{
+			desc:      "WrongEventType",
+			eventType: EventTypeAction,
+			data:      "Exit Boot Services Invocation",
+			action:    ExitBootServicesInvocation,
+		},
+		{
+			desc:      "NonStandard",
+			eventType: EventTypeEFIAction,
+			data:      "Calling EFI Application from Boot Option\x00",
+			action:    UnknownAction,
+		},
+	} {
+		t.Run(data.desc, func(t *testing.T) {
+			d, ok := DecodeEventData(4, data.eventType, DigestMap{}, []byte(data.data), nil).(*ActionEventData)
+			if !ok {
+				t.Fatalf("Unexpected event data type")
+			}
+			if d.Action != data.action {
+				t.Errorf("Unexpected action: %d", d.Action)
+			}
+			if d.IsStandard() != data.standard {
+				t.Errorf("Unexpected IsStandard result")
+			}
+			if d.String() != strings.TrimRight(data.data, "\x00") {
+				t.Errorf("Unexpected string: %s", d)
+			}
+		})
+	}
+}
+
+func TestActionString(t *testing.T) {
+	if CallingEFIApplication.String() != "Calling EFI Application from Boot Option" {
+		t.Errorf("Unexpected string: %s", CallingEFIApplication)
+	}
+	if CallingEFIApplication.EventType() != EventTypeEFIAction {
+		t.Errorf("Unexpected event type: %v", CallingEFIApplication.EventType())
+	}
+	if UnknownAction.String() != "" {
+		t.Errorf("Unexpected string: %s", UnknownAction)
+	}
+}
+
+func TestActionEventDataEncode(t *testing.T) {
+	d, ok := DecodeEventData(4, EventTypeEFIAction, DigestMap{}, []byte("Calling EFI Application from Boot Option\x00"), nil).(*ActionEventData)
+	if !ok {
+		t.Fatalf("Unexpected event data type")
+	}
+
+	var buf bytes.Buffer
+	if err := d.EncodeTo(&buf); err != nil {
+		t.Fatalf("EncodeTo failed: %v", err)
+	}
+	if buf.String() != "Calling EFI Application from Boot Option\x00" {
+		t.Errorf("Unexpected encoding of unmodified event data: %q", buf.String())
+	}
+
+	d.Message = ReturningFromEFIApplication.String()
+	buf.Reset()
+	if err := d.EncodeTo(&buf); err != nil {
+		t.Fatalf("EncodeTo failed: %v", err)
+	}
+	d2, ok := DecodeEventData(4, EventTypeEFIAction, DigestMap{}, buf.Bytes(), nil).(*ActionEventData)
+	if !ok {
+		t.Fatalf("Cannot decode modified event data")
+	}
+	if d2.Message != "Returning from EFI Application from Boot Option" || d2.Action != ReturningFromEFIApplication || !d2.IsStandard() {
+		t.Errorf("Unexpected modified event data: %s", d2)
+	}
+}
@@ -0,0 +1,185 @@
+// Copyright 2019 Canonical Ltd.
+// Licensed under the LGPLv3 with static-linking exception.
+// See LICENCE file for details.
+
+package tcglog
+
+import (
+	"bytes"
+	"fmt"
+)
+
+// AuthorityAnchorStatus describes how an authority measured by an EV_EFI_VARIABLE_AUTHORITY event relates to the trust anchors
+// measured earlier in the log.
+type AuthorityAnchorStatus int
+
+const (
+	// AuthorityInDatabase indicates that the authority is an entry in the db measured earlier in the log.
+	AuthorityInDatabase AuthorityAnchorStatus = iota
+
+	// AuthorityIssuedByDatabase indicates that the authority is a X.509 certificate that isn't in the measured db, but which is
+	// issued by a certificate that is. The firmware should measure the db entry rather than the certificate that it issued.
+	AuthorityIssuedByDatabase
+
+	// AuthorityNotInDatabase indicates that the authority isn't present in and doesn't chain to the measured db.
+	AuthorityNotInDatabase
+
+	// AuthorityNoDatabase indicates that the authority is measured from db, but db isn't measured before it.
+	AuthorityNoDatabase
+
+	// AuthorityShim indicates that the authority is one of shim's built-in or machine owner keys. These can't be verified from
+	// the log, but are only expected after an EFI application (shim) has been loaded.
+	AuthorityShim
+
+	// AuthorityShimBeforeImageLoad indicates that the authority is measured by shim before any EFI application has been loaded.
+	AuthorityShimBeforeImageLoad
+
+	// AuthorityUnexpectedSource indicates that the authority is measured from a variable that isn't expected to contain
+	// authorities.
+	AuthorityUnexpectedSource
+
+	// AuthorityUnrecognized indicates that the authority is not a recognized signature.
+	AuthorityUnrecognized
+)
+
+// AuthorityAnchor describes the trust anchor for an authority measured by an EV_EFI_VARIABLE_AUTHORITY event.
+type AuthorityAnchor struct {
+	Event     *Event
+	Signature *EFISignatureData // The decoded authority, or nil if it isn't a recognized signature
+	Status    AuthorityAnchorStatus
+	Database  *Event            // The measurement of db that the authority was checked against, if any
+	Anchor    *EFISignatureData // The entry in db that matches or issued the authority, if any
+}
+
+// shimAuthorityVariables are the variable names that shim uses when measuring authorities that aren't from db.
+//
+// https://github.com/rhboot/shim/blob/main/shim.c
+//  (verify_one_signature)
+var shimAuthorityVariables = map[string]bool{
+	"Shim":           true,
+	"vendor_db":      true,
+	"MokList":        true,
+	"MokListRT":      true,
+	"MokListTrusted": true,
+}
+
+func findDatabaseAnchor(sig *EFISignatureData, db EFISignatureDatabase) (*EFISignatureData, AuthorityAnchorStatus) {
+	for _, l := range db {
+		for _, s := range l.Signatures {
+			if s.SignatureType == sig.SignatureType && bytes.Equal(s.Data, sig.Data) {
+				return s, AuthorityInDatabase
+			}
+		}
+	}
+
+	cert, err := sig.Certificate()
+	if err != nil {
+		return nil, AuthorityNotInDatabase
+	}
+	for _, l := range db {
+		for _, s := range l.Signatures {
+			issuer, err := s.Certificate()
+			if err != nil {
+				continue
+			}
+			if cert.CheckSignatureFrom(issuer) == nil {
+				return s, AuthorityIssuedByDatabase
+			}
+		}
+	}
+	return nil, AuthorityNotInDatabase
+}
+
+// AuthorityAnchors checks that each EV_EFI_VARIABLE_AUTHORITY event in PCR 7 is anchored to the trust anchors measured earlier in
+// the log. Authorities measured from db must be present in the most recent measurement of db, and authorities measured by shim
+// must follow the load of an EFI application.
+func (l *Log) AuthorityAnchors() (out []*AuthorityAnchor) {
+	var dbEvent *Event
+	var db EFISignatureDatabase
+	seenImageLoad := false
+
+	for _, e := range l.Events {
+		switch {
+		case e.PCRIndex == 7 && e.EventType == EventTypeEFIVariableDriverConfig:
+			if d, ok := e.Data.(*EFIVariableData); ok && d.VariableName == EFIImageSecurityDatabaseGuid && d.UnicodeName == "db" {
+				dbEvent = e
+				db, _ = d.SignatureDatabase()
+			}
+			continue
+		case e.EventType == EventTypeEFIBootServicesApplication:
+			seenImageLoad = true
+			continue
+		case e.PCRIndex != 7 || e.EventType != EventTypeEFIVariableAuthority:
+			continue
+		}
+
+		anchor := &AuthorityAnchor{Event: e}
+		out = append(out, anchor)
+
+		d, ok := e.Data.(*EFIVariableData)
+		if !ok {
+			anchor.Status = AuthorityUnrecognized
+			continue
+		}
+		sig, err := d.AuthoritySignature()
+		if err != nil {
+			anchor.Status = AuthorityUnrecognized
+			continue
+		}
+		anchor.Signature = sig
+
+		switch {
+		case d.VariableName == EFIImageSecurityDatabaseGuid && d.UnicodeName == "db":
+			if dbEvent == nil {
+				anchor.Status = AuthorityNoDatabase
+				break
+			}
+			anchor.Database = dbEvent
+			anchor.Anchor, anchor.Status = findDatabaseAnchor(sig, db)
+		case d.VariableName == ShimLockGuid && shimAuthorityVariables[d.UnicodeName]:
+			if seenImageLoad {
+				anchor.Status = AuthorityShim
+			} else {
+				anchor.Status = AuthorityShimBeforeImageLoad
+			}
+		default:
+			anchor.Status = AuthorityUnexpectedSource
+		}
+	}
+	return out
+}
+
+// CheckAuthorityAnchors is a ValidationCheck that reports EV_EFI_VARIABLE_AUTHORITY events that aren't anchored to the trust
+// anchors measured earlier in the log (see Log.AuthorityAnchors), which indicates an inconsistent or spoofed measurement sequence.
+func CheckAuthorityAnchors(log *Log) (out []*ValidationFinding) {
+	for _, a := range log.AuthorityAnchors() {
+		f := &ValidationFinding{Event: a.Event}
+		switch a.Status {
+		case AuthorityIssuedByDatabase:
+			f.Severity = SeverityWarning
+			f.Message = fmt.Sprintf("the authority is not in the db measured by event %d, but is issued by one of its "+
+				"certificates", a.Database.Index)
+		case AuthorityNotInDatabase:
+			f.Severity = SeverityError
+			f.Message = fmt.Sprintf("the authority is not in the db measured by event %d", a.Database.Index)
+		case AuthorityNoDatabase:
+			f.Severity = SeverityError
+			f.Message = "the authority is measured from db before db is measured"
+		case AuthorityShimBeforeImageLoad:
+			f.Severity = SeverityError
+			f.Message = "the authority is measured by shim before any EFI application was loaded"
+		case AuthorityUnexpectedSource:
+			d := a.Event.Data.(*EFIVariableData)
+			f.Severity = SeverityWarning
+			f.Message = fmt.Sprintf("the authority is measured from an unexpected variable (%s-%s)", d.UnicodeName,
+				d.VariableName)
+		case AuthorityUnrecognized:
+			f.Severity = SeverityInfo
+			f.Message = "the authority is not a recognized signature, so its trust anchor can't be checked"
+		default:
+			continue
+		}
+		out = append(out, f)
+	}
+	return out
+}
@@ -0,0 +1,141 @@
+// Copyright 2019 Canonical Ltd.
+// Licensed under the LGPLv3 with static-linking exception.
+// See LICENCE file for details.
+
+package tcglog
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"math/big"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// makeTestCertificateChain returns a self-signed CA certificate and a certificate issued by it.
+func makeTestCertificateChain(t *testing.T) (ca, leaf []byte) {
+	caKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("GenerateKey failed: %v", err)
+	}
+	caTemplate := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "Test CA"},
+		NotBefore:             time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC),
+		NotAfter:              time.Date(2040, 1, 1, 0, 0, 0, 0, time.UTC),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageCertSign}
+	ca, err = x509.CreateCertificate(rand.Reader, caTemplate, caTemplate, &caKey.PublicKey, caKey)
+	if err != nil {
+		t.Fatalf("CreateCertificate failed: %v", err)
+	}
+
+	leafKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("GenerateKey failed: %v", err)
+	}
+	leafTemplate := &x509.Certificate{
+		SerialNumber: big.NewInt(2),
+		Subject:      pkix.Name{CommonName: "Test Signer"},
+		NotBefore:    time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC),
+		NotAfter:     time.Date(2040, 1, 1, 0, 0, 0, 0, time.UTC)}
+	leaf, err = x509.CreateCertificate(rand.Reader, leafTemplate, caTemplate, &leafKey.PublicKey, caKey)
+	if err != nil {
+		t.Fatalf("CreateCertificate failed: %v", err)
+	}
+	return ca, leaf
+}
+
+func TestAuthorityAnchors(t *testing.T) {
+	owner := MakeEFIGUID(0x77fa9abd, 0x0359, 0x4d32, 0xbd60, [...]uint8{0x28, 0xf4, 0xe7, 0x8f, 0x78, 0x4b})
+	ca, leaf := makeTestCertificateChain(t)
+	other := makeTestCertificate(t, "Other CA")
+	h := sha256.Sum256([]byte("foo"))
+
+	variable := func(eventType EventType, guid EFIGUID, name string, data []byte) *Event {
+		varData := EFIVariableData{VariableName: guid, UnicodeName: name, VariableData: data}
+		var b bytes.Buffer
+		varData.EncodeMeasuredBytes(&b)
+		return makeTestEvent(7, eventType, b.Bytes(), AlgorithmSha256)
+	}
+	authority := func(guid EFIGUID, name string, data []byte) *Event {
+		return variable(EventTypeEFIVariableAuthority, guid, name, data)
+	}
+
+	var dbData bytes.Buffer
+	dbData.Write(makeTestSignatureList(EFICertX509Guid, owner, ca))
+	dbData.Write(makeTestSignatureList(EFICertSha256Guid, owner, h[:]))
+
+	early := authority(EFIImageSecurityDatabaseGuid, "db", append(owner[:], ca...))
+	db := variable(EventTypeEFIVariableDriverConfig, EFIImageSecurityDatabaseGuid, "db", dbData.Bytes())
+	earlyShim := authority(ShimLockGuid, "Shim", other)
+	inDb := authority(EFIImageSecurityDatabaseGuid, "db", append(owner[:], ca...))
+	hashInDb := authority(EFIImageSecurityDatabaseGuid, "db", append(owner[:], h[:]...))
+	issued := authority(EFIImageSecurityDatabaseGuid, "db", append(owner[:], leaf...))
+	notInDb := authority(EFIImageSecurityDatabaseGuid, "db", append(owner[:], other...))
+	shim := authority(ShimLockGuid, "Shim", other)
+	unexpected := authority(EFIGlobalVariableGuid, "KEK", append(owner[:], ca...))
+	unrecognized := authority(ShimLockGuid, "MokListRT", []byte("foo"))
+
+	log := NewLog([]*Event{
+		early,
+		db,
+		earlyShim,
+		inDb,
+		hashInDb,
+		issued,
+		notInDb,
+		makeTestEvent(4, EventTypeEFIBootServicesApplication, makeTestImageLoadEventData("\\EFI\\ubuntu\\shimx64.efi"),
+			AlgorithmSha256),
+		shim,
+		unexpected,
+		unrecognized,
+		makeTestEvent(7, EventTypeSeparator, []byte{0, 0, 0, 0}, AlgorithmSha256)})
+
+	var statuses []AuthorityAnchorStatus
+	for _, a := range log.AuthorityAnchors() {
+		statuses = append(statuses, a.Status)
+	}
+	if !reflect.DeepEqual(statuses, []AuthorityAnchorStatus{AuthorityNoDatabase, AuthorityShimBeforeImageLoad,
+		AuthorityInDatabase, AuthorityInDatabase, AuthorityIssuedByDatabase, AuthorityNotInDatabase, AuthorityShim,
+		AuthorityUnexpectedSource, AuthorityUnrecognized}) {
+		t.Errorf("Unexpected statuses: %v", statuses)
+	}
+
+	anchors := log.AuthorityAnchors()
+	if anchors[2].Database != db || !bytes.Equal(anchors[2].Anchor.Data, ca) {
+		t.Errorf("Unexpected anchor for authority in db")
+	}
+	if anchors[4].Database != db || !bytes.Equal(anchors[4].Anchor.Data, ca) {
+		t.Errorf("Unexpected anchor for issued authority")
+	}
+
+	v := new(Validator)
+	v.AddCheck("authority-anchors", CheckAuthorityAnchors)
+	var findings []string
+	for _, f := range v.Validate(log) {
+		findings = append(findings, f.String())
+	}
+	if !reflect.DeepEqual(findings, []string{
+		"error: authority-anchors: event 0 in PCR 7 (type: EV_EFI_VARIABLE_AUTHORITY): the authority is measured from db " +
+			"before db is measured",
+		"error: authority-anchors: event 2 in PCR 7 (type: EV_EFI_VARIABLE_AUTHORITY): the authority is measured by shim " +
+			"before any EFI application was loaded",
+		"warning: authority-anchors: event 5 in PCR 7 (type: EV_EFI_VARIABLE_AUTHORITY): the authority is not in the db " +
+			"measured by event 1, but is issued by one of its certificates",
+		"error: authority-anchors: event 6 in PCR 7 (type: EV_EFI_VARIABLE_AUTHORITY): the authority is not in the db " +
+			"measured by event 1",
+		"warning: authority-anchors: event 8 in PCR 7 (type: EV_EFI_VARIABLE_AUTHORITY): the authority is measured from an " +
+			"unexpected variable (KEK-{8be4df61-93ca-11d2-aa0d-00e098032b8c})",
+		"info: authority-anchors: event 9 in PCR 7 (type: EV_EFI_VARIABLE_AUTHORITY): the authority is not a recognized " +
+			"signature, so its trust anchor can't be checked"}) {
+		t.Errorf("Unexpected findings: %q", findings)
+	}
+}
@@ -0,0 +1,161 @@
+// Copyright 2019 Canonical Ltd.
+// Licensed under the LGPLv3 with static-linking exception.
+// See LICENCE file for details.
+
+package tcglog
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/binary"
+	"hash/crc32"
+
+	"golang.org/x/xerrors"
+)
+
+const (
+	efiHardDriveSignatureTypeMBR  = 0x01
+	efiHardDriveSignatureTypeGUID = 0x02
+)
+
+// anonymizer computes stable pseudonyms for disk identifiers.
+type anonymizer struct {
+	key []byte
+}
+
+// pseudonym returns a pseudonym of the same length as id, which is derived from the key and the original value.
+func (a *anonymizer) pseudonym(kind string, id []byte) []byte {
+	h := hmac.New(sha256.New, a.key)
+	h.Write([]byte(kind))
+	h.Write(id)
+	return h.Sum(nil)[:len(id)]
+}
+
+// guid replaces the supplied GUID in place with a pseudonym, which is a valid version 4 GUID.
+func (a *anonymizer) guid(guid []byte) {
+	p := a.pseudonym("guid", guid)
+	p[7] = (p[7] & 0x0f) | 0x40
+	p[8] = (p[8] & 0x3f) | 0x80
+	copy(guid, p)
+}
+
+// devicePath rewrites the identifiers in the supplied device path in place. Nodes that can't be parsed are left unmodified.
+func (a *anonymizer) devicePath(data []byte) {
+	for len(data) >= 4 {
+		t := efiDevicePathNodeType(data[0])
+		subType := data[1]
+		length := int(binary.LittleEndian.Uint16(data[2:]))
+		if t == efiDevicePathNodeEoH || length < 4 || length > len(data) {
+			return
+		}
+		node := data[4:length]
+
+		switch {
+		case t == efiDevicePathNodeMedia && subType == efiMediaDevicePathNodeHardDrive && len(node) >= 38:
+			// HARDDRIVE_DEVICE_PATH.Signature
+			sig := node[20:36]
+			switch node[37] {
+			case efiHardDriveSignatureTypeMBR:
+				copy(sig, a.pseudonym("mbr", sig[:4]))
+			case efiHardDriveSignatureTypeGUID:
+				a.guid(sig)
+			}
+		case t == efiDevicePathNodeMsg && subType == efiMsgDevicePathNodeNVMe && len(node) >= 12:
+			// NVME_NAMESPACE_DEVICE_PATH.NamespaceUuid (IEEE EUI-64)
+			copy(node[4:12], a.pseudonym("eui64", node[4:12]))
+		}
+
+		data = data[length:]
+	}
+}
+
+// https://uefi.org/sites/default/files/resources/UEFI_Spec_2_8_final.pdf
+//  (section 5.3.2 "GPT Header")
+// https://trustedcomputinggroup.org/wp-content/uploads/TCG_PCClientSpecPlat_TPM_2p0_1p04_pub.pdf
+//  (section 9.4 "UEFI_GPT_DATA Structure")
+func (a *anonymizer) gptEventData(d *EFIGPTData) (EventData, error) {
+	const (
+		headerSize      = 92
+		headerCRCOffset = 16
+	)
+
+	out := &EFIGPTData{Header: d.Header}
+	a.guid(out.Header.DiskGUID[:])
+
+	// The partition entry array CRC identifies the disk as well, and can't be recomputed because the event only contains
+	// the used entries.
+	var crc [4]byte
+	binary.LittleEndian.PutUint32(crc[:], d.Header.PartitionEntryArrayCRC32)
+	out.Header.PartitionEntryArrayCRC32 = binary.LittleEndian.Uint32(a.pseudonym("crc", crc[:]))
+
+	for _, p := range d.Partitions {
+		part := *p
+		a.guid(part.UniquePartitionGUID[:])
+		out.Partitions = append(out.Partitions, &part)
+	}
+
+	var b bytes.Buffer
+	if err := out.EncodeTo(&b); err != nil {
+		return nil, xerrors.Errorf("cannot encode anonymized GPT event: %w", err)
+	}
+	data := b.Bytes()
+
+	hdrSize := int(out.Header.HeaderSize)
+	if hdrSize > headerSize {
+		hdrSize = headerSize
+	}
+	binary.LittleEndian.PutUint32(data[headerCRCOffset:], 0)
+	binary.LittleEndian.PutUint32(data[headerCRCOffset:], crc32.ChecksumIEEE(data[:hdrSize]))
+
+	decoded, err := decodeEventDataEFIGPT(data)
+	if err != nil {
+		return nil, xerrors.Errorf("cannot decode anonymized GPT event: %w", err)
+	}
+	return decoded, nil
+}
+
+func (a *anonymizer) imageLoadEventData(d *EFIImageLoadEvent) (EventData, error) {
+	var b bytes.Buffer
+	if err := d.EncodeTo(&b); err != nil {
+		return nil, xerrors.Errorf("cannot encode image load event: %w", err)
+	}
+	data := b.Bytes()
+	a.devicePath(data[32:])
+
+	out, err := decodeEventDataEFIImageLoad(data)
+	if err != nil {
+		return nil, xerrors.Errorf("cannot decode anonymized image load event: %w", err)
+	}
+	return out, nil
+}
+
+// Anonymize returns a copy of this log in which the disk identifiers that appear in EV_EFI_GPT_EVENT events and in the device
+// paths of loaded EFI images are replaced with pseudonyms. This covers disk GUIDs, unique partition GUIDs, MBR disk signatures and
+// NVMe namespace identifiers. The partition type GUIDs and all other event data are preserved, as are the PCR indices, event types
+// and digests of every event.
+//
+// The pseudonyms are derived from the original values using the supplied key, so that the same identifier maps to the same
+// pseudonym wherever it appears, both within a log and across logs anonymized with the same key. This allows, for example, the
+// partition in a device path to be correlated with the corresponding GPT entry. The key should be kept secret to prevent the
+// original identifiers from being recovered by exhaustive search.
+//
+// The anonymized event data is encoded from the exported fields of the original event data. An error is returned if this fails.
+func (l *Log) Anonymize(key []byte) (*Log, error) {
+	a := &anonymizer{key: key}
+
+	out := l.ShallowCopy()
+	for i, e := range out.Events {
+		var err error
+		switch d := e.Data.(type) {
+		case *EFIGPTData:
+			e.Data, err = a.gptEventData(d)
+		case *EFIImageLoadEvent:
+			e.Data, err = a.imageLoadEventData(d)
+		}
+		if err != nil {
+			return nil, xerrors.Errorf("cannot anonymize data for event %d: %w", i, err)
+		}
+	}
+	return out, nil
+}
@@ -0,0 +1,190 @@
+// Copyright 2019 Canonical Ltd.
+// Licensed under the LGPLv3 with static-linking exception.
+// See LICENCE file for details.
+
+package tcglog
+
+import (
+	"bytes"
+	"encoding/binary"
+	"hash/crc32"
+	"reflect"
+	"testing"
+)
+
+type testGPTPartition struct {
+	typeGUID   EFIGUID
+	uniqueGUID EFIGUID
+	name       string
+}
+
+func makeTestGPTEventData(diskGUID EFIGUID, partitions []testGPTPartition) []byte {
+	const entrySize = 128
+
+	header := make([]byte, 92)
+	copy(header, "EFI PART")
+	binary.LittleEndian.PutUint32(header[8:], 0x00010000)
+	binary.LittleEndian.PutUint32(header[12:], 92)
+	binary.LittleEndian.PutUint64(header[24:], 1)
+	copy(header[56:], diskGUID[:])
+	binary.LittleEndian.PutUint64(header[72:], 2)
+	binary.LittleEndian.PutUint32(header[80:], 128)
+	binary.LittleEndian.PutUint32(header[84:], entrySize)
+	binary.LittleEndian.PutUint32(header[88:], 0x12345678)
+	binary.LittleEndian.PutUint32(header[16:], crc32.ChecksumIEEE(header))
+
+	var b bytes.Buffer
+	b.Write(header)
+	binary.Write(&b, binary.LittleEndian, uint64(len(partitions)))
+	for _, p := range partitions {
+		entry := make([]byte, entrySize)
+		copy(entry, p.typeGUID[:])
+		copy(entry[16:], p.uniqueGUID[:])
+		var name bytes.Buffer
+		binary.Write(&name, binary.LittleEndian, convertStringToUtf16(p.name))
+		copy(entry[56:], name.Bytes())
+		b.Write(entry)
+	}
+	return b.Bytes()
+}
+
+func makeTestHDImageLoadEventData(partGUID EFIGUID, path string) []byte {
+	var hd bytes.Buffer
+	hd.Write([]byte{0x04, 0x01, 42, 0x00})
+	binary.Write(&hd, binary.LittleEndian, uint32(1))
+	binary.Write(&hd, binary.LittleEndian, uint64(2048))
+	binary.Write(&hd, binary.LittleEndian, uint64(1050624))
+	hd.Write(partGUID[:])
+	hd.Write([]byte{0x02, 0x02})
+
+	data := makeTestImageLoadEventData(path)
+	devicePath := append(hd.Bytes(), data[32:]...)
+	binary.LittleEndian.PutUint64(data[24:], uint64(len(devicePath)))
+	return append(data[:32], devicePath...)
+}
+
+func TestLogAnonymize(t *testing.T) {
+	diskGUID := MakeEFIGUID(0x0f8e2a43, 0x2a5e, 0x4b28, 0x9d1f, [...]uint8{0x6c, 0x4e, 0x8d, 0x54, 0x3f, 0x21})
+	espType := MakeEFIGUID(0xc12a7328, 0xf81f, 0x11d2, 0xba4b, [...]uint8{0x00, 0xa0, 0xc9, 0x3e, 0xc9, 0x3b})
+	espGUID := MakeEFIGUID(0x6a3c6f52, 0x1cbd, 0x4c9e, 0x8f27, [...]uint8{0x5b, 0x19, 0x30, 0x4e, 0x81, 0x6c})
+	rootType := MakeEFIGUID(0x0fc63daf, 0x8483, 0x4772, 0x8e79, [...]uint8{0x3d, 0x69, 0xd8, 0x47, 0x7d, 0xe4})
+	rootGUID := MakeEFIGUID(0x9b2d56ee, 0x4a3c, 0x4f7e, 0xa1c3, [...]uint8{0x2e, 0x44, 0x0b, 0x1f, 0x72, 0x9d})
+
+	var events []*Event
+	for _, e := range []struct {
+		pcr       PCRIndex
+		eventType EventType
+		data      []byte
+	}{
+		{pcr: 5, eventType: EventTypeEFIGPTEvent, data: makeTestGPTEventData(diskGUID, []testGPTPartition{
+			{typeGUID: espType, uniqueGUID: espGUID, name: "EFI System Partition"},
+			{typeGUID: rootType, uniqueGUID: rootGUID}})},
+		{pcr: 4, eventType: EventTypeEFIBootServicesApplication, data: makeTestHDImageLoadEventData(espGUID, "\\EFI\\ubuntu\\shimx64.efi")},
+	} {
+		event := makeTestEvent(e.pcr, e.eventType, e.data, AlgorithmSha1)
+		event.Data = DecodeEventData(e.pcr, e.eventType, event.Digests, e.data, nil)
+		if err, isErr := event.Data.(error); isErr {
+			t.Fatalf("Invalid test event data: %v", err)
+		}
+		events = append(events, event)
+	}
+	log := NewLog(events)
+
+	anonymized, err := log.Anonymize([]byte("foo"))
+	if err != nil {
+		t.Fatalf("Anonymize failed: %v", err)
+	}
+	for i, e := range anonymized.Events {
+		if err, isErr := e.Data.(error); isErr {
+			t.Fatalf("Anonymized data for event %d is invalid: %v", i, err)
+		}
+		if !reflect.DeepEqual(e.Digests, log.Events[i].Digests) {
+			t.Errorf("Unexpected digests for event %d", i)
+		}
+	}
+
+	gpt := anonymized.Events[0].Data.(*EFIGPTData)
+	if gpt.Header.DiskGUID == diskGUID {
+		t.Errorf("Disk GUID was not anonymized")
+	}
+	if len(gpt.Partitions) != 2 {
+		t.Fatalf("Unexpected number of partitions")
+	}
+	if gpt.Partitions[0].PartitionTypeGUID != espType || gpt.Partitions[1].PartitionTypeGUID != rootType {
+		t.Errorf("Partition type GUIDs should be preserved")
+	}
+	if gpt.Partitions[0].UniquePartitionGUID == espGUID || gpt.Partitions[1].UniquePartitionGUID == rootGUID {
+		t.Errorf("Partition GUIDs were not anonymized")
+	}
+	if gpt.Partitions[0].Name != "EFI System Partition" {
+		t.Errorf("Unexpected partition name %q", gpt.Partitions[0].Name)
+	}
+	header := append([]byte(nil), gpt.data[:92]...)
+	crc := binary.LittleEndian.Uint32(header[16:])
+	binary.LittleEndian.PutUint32(header[16:], 0)
+	if crc32.ChecksumIEEE(header) != crc {
+		t.Errorf("Invalid header CRC")
+	}
+
+	imageLoad := anonymized.Events[1].Data.(*EFIImageLoadEvent)
+	sig := imageLoad.data[32+24 : 32+40]
+	if !bytes.Equal(sig, gpt.Partitions[0].UniquePartitionGUID[:]) {
+		t.Errorf("Partition GUID in device path doesn't match the anonymized GPT entry")
+	}
+
+	again, err := log.Anonymize([]byte("foo"))
+	if err != nil {
+		t.Fatalf("Anonymize failed: %v", err)
+	}
+	if !bytes.Equal(again.Events[0].Data.Bytes(), gpt.data) {
+		t.Errorf("Pseudonyms are not stable")
+	}
+	other, err := log.Anonymize([]byte("bar"))
+	if err != nil {
+		t.Fatalf("Anonymize failed: %v", err)
+	}
+	if other.Events[0].Data.(*EFIGPTData).Header.DiskGUID == gpt.Header.DiskGUID {
+		t.Errorf("Pseudonyms should depend on the key")
+	}
+
+	if log.Events[0].Data.(*EFIGPTData).Header.DiskGUID != diskGUID {
+		t.Errorf("Original log was modified")
+	}
+}
+
+func TestLogAnonymizeConstructedGPTData(t *testing.T) {
+	diskGUID := MakeEFIGUID(0x0f8e2a43, 0x2a5e, 0x4b28, 0x9d1f, [...]uint8{0x6c, 0x4e, 0x8d, 0x54, 0x3f, 0x21})
+	partGUID := MakeEFIGUID(0x6a3c6f52, 0x1cbd, 0x4c9e, 0x8f27, [...]uint8{0x5b, 0x19, 0x30, 0x4e, 0x81, 0x6c})
+
+	event := makeTestEvent(5, EventTypeEFIGPTEvent, nil, AlgorithmSha1)
+	event.Data = &EFIGPTData{
+		Header: EFIPartitionTableHeader{
+			Signature:            0x5452415020494645,
+			Revision:             0x00010000,
+			HeaderSize:           92,
+			DiskGUID:             diskGUID,
+			SizeOfPartitionEntry: 128},
+		Partitions: []*EFIPartitionEntry{{UniquePartitionGUID: partGUID, Name: "ESP"}}}
+	log := NewLog([]*Event{event})
+
+	anonymized, err := log.Anonymize([]byte("foo"))
+	if err != nil {
+		t.Fatalf("Anonymize failed: %v", err)
+	}
+	gpt, ok := anonymized.Events[0].Data.(*EFIGPTData)
+	if !ok {
+		t.Fatalf("Unexpected event data type")
+	}
+	if gpt.Header.DiskGUID == diskGUID || len(gpt.Partitions) != 1 || gpt.Partitions[0].UniquePartitionGUID == partGUID {
+		t.Errorf("GPT data was not anonymized: %s", gpt)
+	}
+	if gpt.Partitions[0].Name != "ESP" {
+		t.Errorf("Unexpected partition name %q", gpt.Partitions[0].Name)
+	}
+
+	event.Data.(*EFIGPTData).Header.SizeOfPartitionEntry = 16
+	_, err = log.Anonymize([]byte("foo"))
+	if err == nil || err.Error() != "cannot anonymize data for event 0: cannot encode anonymized GPT event: entry for partition 0 is too large" {
+		t.Errorf("Unexpected error: %v", err)
+	}
+}
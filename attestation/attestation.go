@@ -0,0 +1,183 @@
+// Copyright 2019 Canonical Ltd.
+// Licensed under the LGPLv3 with static-linking exception.
+// See LICENCE file for details.
+
+// Package attestation implements a remote attestation verifier on top of the tcglog package. It checks the certificate chain of
+// an attestation key, a nonce-bound TPM2 quote made with that key, the consistency of an event log with the quote, and a set of
+// configurable policies, and returns a structured result.
+package attestation
+
+import (
+	"bytes"
+	"crypto/subtle"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/canonical/tcglog-parser"
+
+	"golang.org/x/xerrors"
+)
+
+// Evidence is the evidence supplied by an attesting platform.
+type Evidence struct {
+	AKCertificate *x509.Certificate   // The certificate for the attestation key that signed the quote
+	Intermediates []*x509.Certificate // Intermediate certificates that chain AKCertificate to a trusted root
+	Quote         []byte              // The TPMS_ATTEST structure returned from TPM2_Quote
+	Signature     []byte              // The TPMT_SIGNATURE structure returned from TPM2_Quote
+	Log           []byte              // The raw TCG event log
+}
+
+// Policy is a named check that is applied to a log that has been verified against a quote. A non-nil error indicates that the
+// log doesn't satisfy the policy.
+type Policy struct {
+	Name  string
+	Check func(log *tcglog.Log) error
+}
+
+// PolicyFailure describes a policy that a log doesn't satisfy.
+type PolicyFailure struct {
+	Name string
+	Err  error
+}
+
+func (f *PolicyFailure) String() string {
+	return fmt.Sprintf("%s: %v", f.Name, f.Err)
+}
+
+// Verifier verifies attestation evidence. The zero value only verifies the quote signature, the nonce and the log replay, and
+// doesn't trust any attestation key certificate.
+type Verifier struct {
+	Roots       *x509.CertPool    // The trusted roots for attestation key certificates
+	CurrentTime func() time.Time  // Returns the time at which certificates are checked for validity. Defaults to time.Now
+	LogOptions  tcglog.LogOptions // Options used to parse the log
+	Validator   *tcglog.Validator // If not nil, validation findings with SeverityError cause verification to fail
+	Policies    []Policy          // Policies that the log must satisfy
+}
+
+// Result is the result of verifying attestation evidence.
+type Result struct {
+	CertificateChain []*x509.Certificate // The verified chain for the attestation key certificate, if it is trusted
+	CertificateError error               // The reason that the attestation key certificate isn't trusted, if it isn't
+
+	Quote            *tcglog.QuoteVerification   // The result of verifying the quote against the log
+	UncoveredPCRs    []tcglog.PCRIndex           // PCRs that are extended by the log but aren't included in the quote, in ascending order
+	NonceValid       bool                        // The quote is bound to the expected nonce
+	Log              *tcglog.Log                 // The parsed log
+	IncorrectDigests []*tcglog.IncorrectDigest   // Events with event data that is inconsistent with their digests
+	Findings         []*tcglog.ValidationFinding // Findings from the verifier's Validator, if it has one
+	PolicyFailures   []*PolicyFailure            // The policies that the log doesn't satisfy
+}
+
+// CertificateTrusted indicates whether the attestation key certificate chains to one of the trusted roots.
+func (r *Result) CertificateTrusted() bool {
+	return r.CertificateError == nil && len(r.CertificateChain) > 0
+}
+
+// Ok indicates whether the evidence is trustworthy: the attestation key certificate is trusted, the quote has a valid signature,
+// is bound to the expected nonce, covers every PCR extended by the log and is explained by the log, the event data is consistent
+// with the digests, there are no validation findings with SeverityError, and the log satisfies every policy.
+func (r *Result) Ok() bool {
+	if !r.CertificateTrusted() || !r.NonceValid || !r.Quote.Explained() || len(r.UncoveredPCRs) > 0 ||
+		len(r.IncorrectDigests) > 0 || len(r.PolicyFailures) > 0 {
+		return false
+	}
+	for _, f := range r.Findings {
+		if f.Severity == tcglog.SeverityError {
+			return false
+		}
+	}
+	return true
+}
+
+func (v *Verifier) verifyCertificate(evidence *Evidence) ([]*x509.Certificate, error) {
+	if v.Roots == nil {
+		return nil, errors.New("no trusted roots")
+	}
+
+	now := time.Now
+	if v.CurrentTime != nil {
+		now = v.CurrentTime
+	}
+
+	intermediates := x509.NewCertPool()
+	for _, c := range evidence.Intermediates {
+		intermediates.AddCert(c)
+	}
+
+	chains, err := evidence.AKCertificate.Verify(x509.VerifyOptions{
+		Roots:         v.Roots,
+		Intermediates: intermediates,
+		CurrentTime:   now(),
+		KeyUsages:     []x509.ExtKeyUsage{x509.ExtKeyUsageAny}})
+	if err != nil {
+		return nil, err
+	}
+	return chains[0], nil
+}
+
+// uncoveredPCRs returns the PCRs that are extended by events in the supplied log but aren't selected in any bank of the supplied
+// quote, in ascending order. The events for these PCRs aren't authenticated by the quote, so the policies and validation findings
+// for them can't be trusted.
+func uncoveredPCRs(log *tcglog.Log, quote *tcglog.Quote) (out []tcglog.PCRIndex) {
+	quoted := make(map[tcglog.PCRIndex]bool)
+	for _, pcrs := range quote.PCRSelection {
+		for _, pcr := range pcrs {
+			quoted[pcr] = true
+		}
+	}
+
+	seen := make(map[tcglog.PCRIndex]bool)
+	for _, e := range log.Events {
+		if e.EventType == tcglog.EventTypeNoAction || quoted[e.PCRIndex] || seen[e.PCRIndex] {
+			continue
+		}
+		seen[e.PCRIndex] = true
+		out = append(out, e.PCRIndex)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i] < out[j] })
+	return out
+}
+
+// Verify verifies the supplied evidence against the expected nonce. An error is returned if the evidence is malformed and can't
+// be verified at all - eg, if the log or quote can't be decoded. Otherwise, the outcome of each check is recorded in the returned
+// Result, and Result.Ok indicates whether the evidence is trustworthy.
+func (v *Verifier) Verify(evidence *Evidence, nonce []byte) (*Result, error) {
+	if evidence.AKCertificate == nil {
+		return nil, errors.New("no attestation key certificate")
+	}
+
+	result := new(Result)
+	result.CertificateChain, result.CertificateError = v.verifyCertificate(evidence)
+
+	log, err := tcglog.ParseLog(bytes.NewReader(evidence.Log), &v.LogOptions)
+	if err != nil {
+		return nil, xerrors.Errorf("cannot parse log: %w", err)
+	}
+	result.Log = log
+
+	result.Quote, err = log.VerifyQuote(evidence.Quote, evidence.Signature, evidence.AKCertificate.PublicKey)
+	if err != nil {
+		return nil, xerrors.Errorf("cannot verify quote: %w", err)
+	}
+	result.NonceValid = subtle.ConstantTimeCompare(result.Quote.Quote.ExtraData, nonce) == 1
+	result.UncoveredPCRs = uncoveredPCRs(log, result.Quote.Quote)
+
+	// The quote only authenticates the digests, so the event data that the policies inspect must be checked against them
+	// regardless of whether there is a Validator.
+	result.IncorrectDigests = log.ValidateDigests()
+
+	if v.Validator != nil {
+		result.Findings = v.Validator.Validate(log)
+	}
+
+	for _, p := range v.Policies {
+		if err := p.Check(log); err != nil {
+			result.PolicyFailures = append(result.PolicyFailures, &PolicyFailure{Name: p.Name, Err: err})
+		}
+	}
+
+	return result, nil
+}
@@ -0,0 +1,293 @@
+// Copyright 2019 Canonical Ltd.
+// Licensed under the LGPLv3 with static-linking exception.
+// See LICENCE file for details.
+
+package attestation
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/binary"
+	"errors"
+	"math/big"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/canonical/tcglog-parser"
+)
+
+type testEnv struct {
+	t      *testing.T
+	roots  *x509.CertPool
+	akKey  *ecdsa.PrivateKey
+	akCert *x509.Certificate
+	log    *tcglog.Log
+	raw    []byte
+}
+
+func newTestEnv(t *testing.T) *testEnv {
+	caKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("GenerateKey failed: %v", err)
+	}
+	caTemplate := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "Test CA"},
+		NotBefore:             time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC),
+		NotAfter:              time.Date(2040, 1, 1, 0, 0, 0, 0, time.UTC),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageCertSign}
+	caDER, err := x509.CreateCertificate(rand.Reader, caTemplate, caTemplate, &caKey.PublicKey, caKey)
+	if err != nil {
+		t.Fatalf("CreateCertificate failed: %v", err)
+	}
+	caCert, _ := x509.ParseCertificate(caDER)
+
+	akKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("GenerateKey failed: %v", err)
+	}
+	akTemplate := &x509.Certificate{
+		SerialNumber: big.NewInt(2),
+		Subject:      pkix.Name{CommonName: "Test AK"},
+		NotBefore:    time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC),
+		NotAfter:     time.Date(2040, 1, 1, 0, 0, 0, 0, time.UTC),
+		KeyUsage:     x509.KeyUsageDigitalSignature}
+	akDER, err := x509.CreateCertificate(rand.Reader, akTemplate, caCert, &akKey.PublicKey, caKey)
+	if err != nil {
+		t.Fatalf("CreateCertificate failed: %v", err)
+	}
+	akCert, _ := x509.ParseCertificate(akDER)
+
+	b, err := tcglog.NewLogBuilder(tcglog.AlgorithmIdList{tcglog.AlgorithmSha256}, nil)
+	if err != nil {
+		t.Fatalf("NewLogBuilder failed: %v", err)
+	}
+	b.AddEvent(0, tcglog.EventTypeSCRTMVersion, []byte{0x31, 0x00})
+	for pcr := tcglog.PCRIndex(0); pcr <= 7; pcr++ {
+		b.AddEvent(pcr, tcglog.EventTypeSeparator, []byte{0, 0, 0, 0})
+	}
+	log := b.Log()
+
+	var raw bytes.Buffer
+	if err := tcglog.WriteLog(&raw, log); err != nil {
+		t.Fatalf("WriteLog failed: %v", err)
+	}
+
+	roots := x509.NewCertPool()
+	roots.AddCert(caCert)
+
+	return &testEnv{t: t, roots: roots, akKey: akKey, akCert: akCert, log: log, raw: raw.Bytes()}
+}
+
+func writeTPM2B(w *bytes.Buffer, data []byte) {
+	binary.Write(w, binary.BigEndian, uint16(len(data)))
+	w.Write(data)
+}
+
+// quote returns a TPMS_ATTEST structure for a quote of PCRs 0-7 in the SHA-256 bank, and a TPMT_SIGNATURE for it.
+func (e *testEnv) quote(nonce []byte, log *tcglog.Log) (attest, signature []byte) {
+	return e.quotePCRs(nonce, log, 0, 1, 2, 3, 4, 5, 6, 7)
+}
+
+// quotePCRs returns a TPMS_ATTEST structure for a quote of the specified PCRs in the SHA-256 bank, and a TPMT_SIGNATURE for it.
+func (e *testEnv) quotePCRs(nonce []byte, log *tcglog.Log, pcrs ...tcglog.PCRIndex) (attest, signature []byte) {
+	h := sha256.New()
+	pcrSelect := make([]byte, 3)
+	for _, pcr := range pcrs {
+		pcrSelect[pcr/8] |= 1 << (pcr % 8)
+		value, err := log.ReplayPCR(tcglog.AlgorithmSha256, pcr)
+		if err != nil {
+			e.t.Fatalf("ReplayPCR failed: %v", err)
+		}
+		h.Write(value)
+	}
+
+	var w bytes.Buffer
+	binary.Write(&w, binary.BigEndian, uint32(0xff544347))
+	binary.Write(&w, binary.BigEndian, uint16(0x8018))
+	writeTPM2B(&w, nil)
+	writeTPM2B(&w, nonce)
+	w.Write(make([]byte, 25))
+	binary.Write(&w, binary.BigEndian, uint32(1))
+	binary.Write(&w, binary.BigEndian, tcglog.AlgorithmSha256)
+	w.WriteByte(uint8(len(pcrSelect)))
+	w.Write(pcrSelect)
+	writeTPM2B(&w, h.Sum(nil))
+	attest = w.Bytes()
+
+	digest := sha256.Sum256(attest)
+	r, s, err := ecdsa.Sign(rand.Reader, e.akKey, digest[:])
+	if err != nil {
+		e.t.Fatalf("Sign failed: %v", err)
+	}
+	var sig bytes.Buffer
+	binary.Write(&sig, binary.BigEndian, uint16(0x0018))
+	binary.Write(&sig, binary.BigEndian, tcglog.AlgorithmSha256)
+	writeTPM2B(&sig, r.Bytes())
+	writeTPM2B(&sig, s.Bytes())
+	return attest, sig.Bytes()
+}
+
+func TestVerify(t *testing.T) {
+	env := newTestEnv(t)
+	nonce := []byte("1234567890")
+	attest, sig := env.quote(nonce, env.log)
+
+	otherLog := tcglog.NewLog(append([]*tcglog.Event{}, env.log.Events[:1]...))
+	badAttest, badSig := env.quote(nonce, otherLog)
+
+	// A log with a forged PCR 7 history and a quote that only covers PCR 0, which the log explains.
+	b, err := tcglog.NewLogBuilder(tcglog.AlgorithmIdList{tcglog.AlgorithmSha256}, nil)
+	if err != nil {
+		t.Fatalf("NewLogBuilder failed: %v", err)
+	}
+	b.AddEvent(0, tcglog.EventTypeSCRTMVersion, []byte{0x31, 0x00})
+	b.AddEvent(7, tcglog.EventTypeEFIAction, []byte("forged"))
+	for pcr := tcglog.PCRIndex(0); pcr <= 7; pcr++ {
+		b.AddEvent(pcr, tcglog.EventTypeSeparator, []byte{0, 0, 0, 0})
+	}
+	var forged bytes.Buffer
+	if err := tcglog.WriteLog(&forged, b.Log()); err != nil {
+		t.Fatalf("WriteLog failed: %v", err)
+	}
+	pcr0Attest, pcr0Sig := env.quotePCRs(nonce, b.Log(), 0)
+
+	// A log where the data of an event in PCR 4 has been replaced without changing its digests, so it still explains the quote.
+	b, err = tcglog.NewLogBuilder(tcglog.AlgorithmIdList{tcglog.AlgorithmSha256}, nil)
+	if err != nil {
+		t.Fatalf("NewLogBuilder failed: %v", err)
+	}
+	b.AddEvent(4, tcglog.EventTypeEFIAction, []byte("UEFI Debug Mode"))
+	for pcr := tcglog.PCRIndex(0); pcr <= 7; pcr++ {
+		b.AddEvent(pcr, tcglog.EventTypeSeparator, []byte{0, 0, 0, 0})
+	}
+	tampered := b.Log()
+	e := tampered.Events[1]
+	e.Data = tcglog.DecodeEventData(e.PCRIndex, e.EventType, e.Digests, []byte("Calling EFI Application from Boot Option"), nil)
+	var tamperedRaw bytes.Buffer
+	if err := tcglog.WriteLog(&tamperedRaw, tampered); err != nil {
+		t.Fatalf("WriteLog failed: %v", err)
+	}
+	tamperedAttest, tamperedSig := env.quote(nonce, tampered)
+	noDebugPolicy := Policy{Name: "no-debug", Check: func(log *tcglog.Log) error {
+		for _, e := range log.Events {
+			if e.EventType == tcglog.EventTypeEFIAction && e.Data.String() == "UEFI Debug Mode" {
+				return errors.New("debug mode is enabled")
+			}
+		}
+		return nil
+	}}
+
+	failingPolicy := Policy{Name: "always-fails", Check: func(*tcglog.Log) error { return errors.New("policy failed") }}
+
+	for _, data := range []struct {
+		desc     string
+		verifier *Verifier
+		evidence *Evidence
+		nonce    []byte
+		check    func(*Result) bool
+	}{
+		{
+			desc:     "Good",
+			verifier: &Verifier{Roots: env.roots, Validator: new(tcglog.Validator)},
+			evidence: &Evidence{AKCertificate: env.akCert, Quote: attest, Signature: sig, Log: env.raw},
+			nonce:    nonce,
+			check:    func(r *Result) bool { return r.Ok() && len(r.CertificateChain) == 2 },
+		},
+		{
+			desc:     "UntrustedCertificate",
+			verifier: &Verifier{Roots: x509.NewCertPool()},
+			evidence: &Evidence{AKCertificate: env.akCert, Quote: attest, Signature: sig, Log: env.raw},
+			nonce:    nonce,
+			check:    func(r *Result) bool { return !r.Ok() && !r.CertificateTrusted() && r.Quote.Explained() },
+		},
+		{
+			desc:     "ExpiredCertificate",
+			verifier: &Verifier{Roots: env.roots, CurrentTime: func() time.Time { return time.Date(2050, 1, 1, 0, 0, 0, 0, time.UTC) }},
+			evidence: &Evidence{AKCertificate: env.akCert, Quote: attest, Signature: sig, Log: env.raw},
+			nonce:    nonce,
+			check:    func(r *Result) bool { return !r.Ok() && r.CertificateError != nil },
+		},
+		{
+			desc:     "WrongNonce",
+			verifier: &Verifier{Roots: env.roots},
+			evidence: &Evidence{AKCertificate: env.akCert, Quote: attest, Signature: sig, Log: env.raw},
+			nonce:    []byte("foo"),
+			check:    func(r *Result) bool { return !r.Ok() && !r.NonceValid && r.CertificateTrusted() },
+		},
+		{
+			desc:     "LogDoesNotExplainQuote",
+			verifier: &Verifier{Roots: env.roots},
+			evidence: &Evidence{AKCertificate: env.akCert, Quote: badAttest, Signature: badSig, Log: env.raw},
+			nonce:    nonce,
+			check:    func(r *Result) bool { return !r.Ok() && r.Quote.SignatureValid && !r.Quote.DigestMatches() },
+		},
+		{
+			desc:     "QuoteDoesNotCoverLog",
+			verifier: &Verifier{Roots: env.roots},
+			evidence: &Evidence{AKCertificate: env.akCert, Quote: pcr0Attest, Signature: pcr0Sig, Log: forged.Bytes()},
+			nonce:    nonce,
+			check: func(r *Result) bool {
+				return !r.Ok() && r.Quote.Explained() && r.NonceValid && r.CertificateTrusted() &&
+					reflect.DeepEqual(r.UncoveredPCRs, []tcglog.PCRIndex{1, 2, 3, 4, 5, 6, 7})
+			},
+		},
+		{
+			desc:     "PolicyFailure",
+			verifier: &Verifier{Roots: env.roots, Policies: []Policy{failingPolicy}},
+			evidence: &Evidence{AKCertificate: env.akCert, Quote: attest, Signature: sig, Log: env.raw},
+			nonce:    nonce,
+			check: func(r *Result) bool {
+				return !r.Ok() && len(r.PolicyFailures) == 1 && r.PolicyFailures[0].String() == "always-fails: policy failed"
+			},
+		},
+		{
+			desc:     "TamperedEventData",
+			verifier: &Verifier{Roots: env.roots, Policies: []Policy{noDebugPolicy}},
+			evidence: &Evidence{AKCertificate: env.akCert, Quote: tamperedAttest, Signature: tamperedSig, Log: tamperedRaw.Bytes()},
+			nonce:    nonce,
+			check: func(r *Result) bool {
+				return !r.Ok() && r.Quote.Explained() && len(r.PolicyFailures) == 0 && len(r.IncorrectDigests) == 1 &&
+					r.IncorrectDigests[0].Event.EventType == tcglog.EventTypeEFIAction
+			},
+		},
+		{
+			desc:     "ValidationError",
+			verifier: &Verifier{Roots: env.roots, Validator: tcglog.NewValidator()},
+			evidence: &Evidence{AKCertificate: env.akCert, Quote: attest, Signature: sig, Log: env.raw},
+			nonce:    nonce,
+			check:    func(r *Result) bool { return !r.Ok() && len(r.Findings) > 0 },
+		},
+	} {
+		t.Run(data.desc, func(t *testing.T) {
+			result, err := data.verifier.Verify(data.evidence, data.nonce)
+			if err != nil {
+				t.Fatalf("Verify failed: %v", err)
+			}
+			if !data.check(result) {
+				t.Errorf("Unexpected result: %+v", result)
+			}
+		})
+	}
+}
+
+func TestVerifyMalformed(t *testing.T) {
+	env := newTestEnv(t)
+	v := &Verifier{Roots: env.roots}
+
+	if _, err := v.Verify(&Evidence{}, nil); err == nil || err.Error() != "no attestation key certificate" {
+		t.Errorf("Unexpected error: %v", err)
+	}
+	_, err := v.Verify(&Evidence{AKCertificate: env.akCert, Quote: []byte{0x00}, Log: env.raw}, nil)
+	if err == nil || err.Error() != "cannot verify quote: cannot decode quote: cannot read header: unexpected EOF" {
+		t.Errorf("Unexpected error: %v", err)
+	}
+}
@@ -0,0 +1,143 @@
+// Copyright 2019 Canonical Ltd.
+// Licensed under the LGPLv3 with static-linking exception.
+// See LICENCE file for details.
+
+package attestation
+
+import (
+	"bytes"
+	"crypto/x509"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+
+	"github.com/canonical/tcglog-parser"
+
+	"golang.org/x/xerrors"
+)
+
+// BundleVersion is the version of the bundle format written by WriteBundle.
+const BundleVersion = 1
+
+// Bundle is a single artifact containing the attestation evidence captured from a platform, for exchange between collectors and
+// verifiers.
+type Bundle struct {
+	Log           []byte              // The raw TCG event log
+	FinalEvents   []byte              // The raw EFI_TCG2_FINAL_EVENTS_TABLE, if captured
+	Quote         []byte              // The TPMS_ATTEST structure returned from TPM2_Quote, if captured
+	Signature     []byte              // The TPMT_SIGNATURE structure returned from TPM2_Quote, if captured
+	PCRValues     tcglog.PCRValues    // PCR values read from the TPM, if captured
+	AKCertificate *x509.Certificate   // The attestation key certificate, if captured
+	Intermediates []*x509.Certificate // Intermediate certificates for AKCertificate
+}
+
+// Evidence returns the evidence in this bundle that is required by Verifier.Verify.
+func (b *Bundle) Evidence() *Evidence {
+	return &Evidence{
+		AKCertificate: b.AKCertificate,
+		Intermediates: b.Intermediates,
+		Quote:         b.Quote,
+		Signature:     b.Signature,
+		Log:           b.Log}
+}
+
+// bundleJSON is the serialized form of a bundle. Binary fields are base64 encoded by encoding/json, and PCR values are encoded
+// in the JSON format accepted by tcglog.ParsePCRValues.
+type bundleJSON struct {
+	Version       int                          `json:"version"`
+	Log           []byte                       `json:"log"`
+	FinalEvents   []byte                       `json:"final_events,omitempty"`
+	Quote         []byte                       `json:"quote,omitempty"`
+	Signature     []byte                       `json:"signature,omitempty"`
+	PCRs          map[string]map[string]string `json:"pcrs,omitempty"`
+	AKCertificate []byte                       `json:"ak_certificate,omitempty"`
+	Intermediates [][]byte                     `json:"intermediates,omitempty"`
+}
+
+// WriteBundle serializes the supplied bundle to w as JSON.
+func WriteBundle(w io.Writer, b *Bundle) error {
+	if len(b.Log) == 0 {
+		return errors.New("bundle has no log")
+	}
+
+	j := &bundleJSON{
+		Version:     BundleVersion,
+		Log:         b.Log,
+		FinalEvents: b.FinalEvents,
+		Quote:       b.Quote,
+		Signature:   b.Signature}
+
+	if len(b.PCRValues) > 0 {
+		j.PCRs = make(map[string]map[string]string)
+		for pcr, digests := range b.PCRValues {
+			for alg, digest := range digests {
+				name := alg.String()
+				if _, ok := j.PCRs[name]; !ok {
+					j.PCRs[name] = make(map[string]string)
+				}
+				j.PCRs[name][strconv.FormatUint(uint64(pcr), 10)] = hex.EncodeToString(digest)
+			}
+		}
+	}
+
+	if b.AKCertificate != nil {
+		j.AKCertificate = b.AKCertificate.Raw
+	}
+	for _, c := range b.Intermediates {
+		j.Intermediates = append(j.Intermediates, c.Raw)
+	}
+
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(j)
+}
+
+// ReadBundle reads a bundle written by WriteBundle from r.
+func ReadBundle(r io.Reader) (*Bundle, error) {
+	var j bundleJSON
+	if err := json.NewDecoder(r).Decode(&j); err != nil {
+		return nil, xerrors.Errorf("cannot decode bundle: %w", err)
+	}
+	if j.Version != BundleVersion {
+		return nil, fmt.Errorf("unsupported bundle version %d", j.Version)
+	}
+	if len(j.Log) == 0 {
+		return nil, errors.New("bundle has no log")
+	}
+
+	b := &Bundle{
+		Log:         j.Log,
+		FinalEvents: j.FinalEvents,
+		Quote:       j.Quote,
+		Signature:   j.Signature}
+
+	if len(j.PCRs) > 0 {
+		data, err := json.Marshal(j.PCRs)
+		if err != nil {
+			return nil, xerrors.Errorf("cannot encode PCR values: %w", err)
+		}
+		if b.PCRValues, err = tcglog.ParsePCRValues(bytes.NewReader(data)); err != nil {
+			return nil, xerrors.Errorf("cannot decode PCR values: %w", err)
+		}
+	}
+
+	if len(j.AKCertificate) > 0 {
+		cert, err := x509.ParseCertificate(j.AKCertificate)
+		if err != nil {
+			return nil, xerrors.Errorf("cannot decode AK certificate: %w", err)
+		}
+		b.AKCertificate = cert
+	}
+	for i, data := range j.Intermediates {
+		cert, err := x509.ParseCertificate(data)
+		if err != nil {
+			return nil, xerrors.Errorf("cannot decode intermediate certificate %d: %w", i, err)
+		}
+		b.Intermediates = append(b.Intermediates, cert)
+	}
+
+	return b, nil
+}
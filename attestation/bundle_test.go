@@ -0,0 +1,91 @@
+// Copyright 2019 Canonical Ltd.
+// Licensed under the LGPLv3 with static-linking exception.
+// See LICENCE file for details.
+
+package attestation
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/canonical/tcglog-parser"
+)
+
+func TestBundleRoundTrip(t *testing.T) {
+	env := newTestEnv(t)
+	nonce := []byte("nonce")
+	attest, sig := env.quote(nonce, env.log)
+
+	values := make(tcglog.PCRValues)
+	for pcr := tcglog.PCRIndex(0); pcr <= 7; pcr++ {
+		value, err := env.log.ReplayPCR(tcglog.AlgorithmSha256, pcr)
+		if err != nil {
+			t.Fatalf("ReplayPCR failed: %v", err)
+		}
+		values[pcr] = tcglog.DigestMap{tcglog.AlgorithmSha256: value}
+	}
+
+	bundle := &Bundle{
+		Log:           env.raw,
+		FinalEvents:   []byte{0x01, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00},
+		Quote:         attest,
+		Signature:     sig,
+		PCRValues:     values,
+		AKCertificate: env.akCert}
+
+	var b bytes.Buffer
+	if err := WriteBundle(&b, bundle); err != nil {
+		t.Fatalf("WriteBundle failed: %v", err)
+	}
+
+	read, err := ReadBundle(&b)
+	if err != nil {
+		t.Fatalf("ReadBundle failed: %v", err)
+	}
+	if !reflect.DeepEqual(read, bundle) {
+		t.Errorf("Unexpected bundle: %+v", read)
+	}
+
+	result, err := (&Verifier{Roots: env.roots}).Verify(read.Evidence(), nonce)
+	if err != nil {
+		t.Fatalf("Verify failed: %v", err)
+	}
+	if !result.Ok() {
+		t.Errorf("Unexpected result: %+v", result)
+	}
+	comparison, err := result.Log.ComparePCRs(read.PCRValues, nil)
+	if err != nil {
+		t.Fatalf("ComparePCRs failed: %v", err)
+	}
+	if !comparison.Ok() {
+		t.Errorf("Unexpected PCR comparison: %v", comparison.Diverged)
+	}
+}
+
+func TestReadBundleErrors(t *testing.T) {
+	for _, data := range []struct {
+		desc  string
+		input string
+		err   string
+	}{
+		{desc: "Version", input: `{"version": 2, "log": "AA=="}`, err: "unsupported bundle version 2"},
+		{desc: "NoLog", input: `{"version": 1}`, err: "bundle has no log"},
+		{desc: "BadCertificate", input: `{"version": 1, "log": "AA==", "ak_certificate": "AA=="}`,
+			err: "cannot decode AK certificate: x509: malformed certificate"},
+		{desc: "BadPCRs", input: `{"version": 1, "log": "AA==", "pcrs": {"sha256": {"0": "00"}}}`,
+			err: "cannot decode PCR values: unexpected value length for PCR 0 in the SHA-256 bank (1)"},
+	} {
+		t.Run(data.desc, func(t *testing.T) {
+			_, err := ReadBundle(strings.NewReader(data.input))
+			if err == nil || err.Error() != data.err {
+				t.Errorf("Unexpected error: %v", err)
+			}
+		})
+	}
+
+	if err := WriteBundle(new(bytes.Buffer), &Bundle{}); err == nil || err.Error() != "bundle has no log" {
+		t.Errorf("Unexpected error: %v", err)
+	}
+}
@@ -0,0 +1,115 @@
+// Copyright 2019 Canonical Ltd.
+// Licensed under the LGPLv3 with static-linking exception.
+// See LICENCE file for details.
+
+package tcglog
+
+import (
+	"debug/pe"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+
+	"golang.org/x/xerrors"
+)
+
+const (
+	peCertificateTableIndex = 4 // Index of the certificate table in the optional header data directories
+)
+
+// ComputeAuthenticodeDigest computes the Authenticode digest of the supplied PE image with the specified algorithm. This is the
+// digest that firmware measures for EV_EFI_BOOT_SERVICES_APPLICATION, EV_EFI_BOOT_SERVICES_DRIVER and
+// EV_EFI_RUNTIME_SERVICES_DRIVER events.
+//
+// https://download.microsoft.com/download/9/c/5/9c5b2167-8017-4bae-9fde-d599bac8184a/Authenticode_PE.docx
+//  (section "Calculating the PE Image Hash")
+func ComputeAuthenticodeDigest(r io.ReaderAt, size int64, alg AlgorithmId) (Digest, error) {
+	if !alg.supported() {
+		return nil, fmt.Errorf("unsupported algorithm %v", alg)
+	}
+
+	f, err := pe.NewFile(r)
+	if err != nil {
+		return nil, xerrors.Errorf("cannot decode PE image: %w", err)
+	}
+
+	var peOffset [4]byte
+	if _, err := r.ReadAt(peOffset[:], 0x3c); err != nil {
+		return nil, xerrors.Errorf("cannot read PE header offset: %w", err)
+	}
+	optionalHeaderOffset := int64(binary.LittleEndian.Uint32(peOffset[:])) + 4 + int64(binary.Size(f.FileHeader))
+
+	var sizeOfHeaders int64
+	var dataDirectoriesOffset int64
+	var certTable pe.DataDirectory
+	switch oh := f.OptionalHeader.(type) {
+	case *pe.OptionalHeader32:
+		sizeOfHeaders = int64(oh.SizeOfHeaders)
+		dataDirectoriesOffset = optionalHeaderOffset + 96
+		if oh.NumberOfRvaAndSizes > peCertificateTableIndex {
+			certTable = oh.DataDirectory[peCertificateTableIndex]
+		}
+	case *pe.OptionalHeader64:
+		sizeOfHeaders = int64(oh.SizeOfHeaders)
+		dataDirectoriesOffset = optionalHeaderOffset + 112
+		if oh.NumberOfRvaAndSizes > peCertificateTableIndex {
+			certTable = oh.DataDirectory[peCertificateTableIndex]
+		}
+	default:
+		return nil, errors.New("PE image has no optional header")
+	}
+
+	// The checksum is at the same offset in both optional header formats.
+	checksumOffset := optionalHeaderOffset + 64
+	certTableOffset := dataDirectoriesOffset + peCertificateTableIndex*8
+
+	hasher := alg.GetHash().New()
+	hashRange := func(start, end int64) error {
+		if end < start || end > size {
+			return fmt.Errorf("invalid range [%d, %d)", start, end)
+		}
+		_, err := io.Copy(hasher, io.NewSectionReader(r, start, end-start))
+		return err
+	}
+
+	// Hash the headers, excluding the checksum and the certificate table entry.
+	if err := hashRange(0, checksumOffset); err != nil {
+		return nil, xerrors.Errorf("cannot hash headers: %w", err)
+	}
+	if err := hashRange(checksumOffset+4, certTableOffset); err != nil {
+		return nil, xerrors.Errorf("cannot hash headers: %w", err)
+	}
+	if err := hashRange(certTableOffset+8, sizeOfHeaders); err != nil {
+		return nil, xerrors.Errorf("cannot hash headers: %w", err)
+	}
+
+	// Hash the sections in the order in which they appear in the file.
+	sections := make([]*pe.Section, 0, len(f.Sections))
+	for _, s := range f.Sections {
+		if s.Size > 0 {
+			sections = append(sections, s)
+		}
+	}
+	sort.Slice(sections, func(i, j int) bool { return sections[i].Offset < sections[j].Offset })
+
+	hashed := sizeOfHeaders
+	for _, s := range sections {
+		start := int64(s.Offset)
+		end := start + int64(s.Size)
+		if err := hashRange(start, end); err != nil {
+			return nil, xerrors.Errorf("cannot hash section %s: %w", s.Name, err)
+		}
+		hashed += int64(s.Size)
+	}
+
+	// Hash any data after the sections, excluding the certificate table.
+	if extra := size - int64(certTable.Size) - hashed; extra > 0 {
+		if err := hashRange(hashed, hashed+extra); err != nil {
+			return nil, xerrors.Errorf("cannot hash trailing data: %w", err)
+		}
+	}
+
+	return hasher.Sum(nil), nil
+}
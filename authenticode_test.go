@@ -0,0 +1,98 @@
+// Copyright 2019 Canonical Ltd.
+// Licensed under the LGPLv3 with static-linking exception.
+// See LICENCE file for details.
+
+package tcglog
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"debug/pe"
+	"encoding/binary"
+	"testing"
+)
+
+// makeTestPEImage creates a minimal PE32+ image with a single section containing the supplied data, followed by the supplied
+// trailing data and certificate table.
+func makeTestPEImage(checksum uint32, section, trailing, cert []byte) []byte {
+	const sizeOfHeaders = 0x200
+
+	sectionSize := (len(section) + 0x1ff) &^ 0x1ff
+
+	var b bytes.Buffer
+	dos := make([]byte, 0x40)
+	copy(dos, "MZ")
+	binary.LittleEndian.PutUint32(dos[0x3c:], 0x40)
+	b.Write(dos)
+	b.WriteString("PE\x00\x00")
+
+	binary.Write(&b, binary.LittleEndian, pe.FileHeader{
+		Machine:              pe.IMAGE_FILE_MACHINE_AMD64,
+		NumberOfSections:     1,
+		SizeOfOptionalHeader: uint16(binary.Size(pe.OptionalHeader64{})),
+		Characteristics:      0x22})
+
+	oh := pe.OptionalHeader64{
+		Magic:               0x20b,
+		SizeOfHeaders:       sizeOfHeaders,
+		CheckSum:            checksum,
+		SectionAlignment:    0x1000,
+		FileAlignment:       0x200,
+		SizeOfImage:         0x2000,
+		NumberOfRvaAndSizes: 16}
+	if len(cert) > 0 {
+		oh.DataDirectory[4] = pe.DataDirectory{
+			VirtualAddress: uint32(sizeOfHeaders + sectionSize + len(trailing)),
+			Size:           uint32(len(cert))}
+	}
+	binary.Write(&b, binary.LittleEndian, oh)
+
+	binary.Write(&b, binary.LittleEndian, pe.SectionHeader32{
+		Name:             [8]uint8{'.', 't', 'e', 'x', 't'},
+		VirtualSize:      uint32(len(section)),
+		VirtualAddress:   0x1000,
+		SizeOfRawData:    uint32(sectionSize),
+		PointerToRawData: sizeOfHeaders,
+		Characteristics:  0x60000020})
+
+	b.Write(make([]byte, sizeOfHeaders-b.Len()))
+	b.Write(section)
+	b.Write(make([]byte, sectionSize-len(section)))
+	b.Write(trailing)
+	b.Write(cert)
+	return b.Bytes()
+}
+
+func TestComputeAuthenticodeDigest(t *testing.T) {
+	image := makeTestPEImage(0x1234, []byte("section data"), []byte("trailing"), []byte("certificate"))
+
+	// The checksum is at 0x98 and the certificate table entry is at 0xe8 for a PE32+ image at this offset.
+	h := sha256.New()
+	h.Write(image[:0x98])
+	h.Write(image[0x9c:0xe8])
+	h.Write(image[0xf0:0x200])
+	h.Write(image[0x200 : len(image)-len("certificate")])
+	expected := h.Sum(nil)
+
+	digest, err := ComputeAuthenticodeDigest(bytes.NewReader(image), int64(len(image)), AlgorithmSha256)
+	if err != nil {
+		t.Fatalf("ComputeAuthenticodeDigest failed: %v", err)
+	}
+	if !bytes.Equal(digest, expected) {
+		t.Errorf("Unexpected digest: %x", digest)
+	}
+
+	// The digest doesn't depend on the checksum or the certificate table.
+	other := makeTestPEImage(0x5678, []byte("section data"), []byte("trailing"), []byte("another certificate"))
+	digest, err = ComputeAuthenticodeDigest(bytes.NewReader(other), int64(len(other)), AlgorithmSha256)
+	if err != nil {
+		t.Fatalf("ComputeAuthenticodeDigest failed: %v", err)
+	}
+	if !bytes.Equal(digest, expected) {
+		t.Errorf("Unexpected digest for image with different checksum and certificate: %x", digest)
+	}
+
+	if _, err := ComputeAuthenticodeDigest(bytes.NewReader([]byte("foo")), 3, AlgorithmSha256); err == nil {
+		t.Errorf("Expected an error for an invalid image")
+	}
+}
@@ -0,0 +1,125 @@
+// Copyright 2019 Canonical Ltd.
+// Licensed under the LGPLv3 with static-linking exception.
+// See LICENCE file for details.
+
+package tcglog
+
+import (
+	"bytes"
+	"fmt"
+)
+
+// AuthorityMeasurement describes a distinct signature that is measured to PCR 7 by one or more EV_EFI_VARIABLE_AUTHORITY events.
+// The firmware is meant to measure each authority once, the first time that it is used to authenticate an image, but it's common
+// for the same certificate to be measured again - eg, by shim when it uses a certificate from db to authenticate the next stage
+// loader after the firmware has already used it to authenticate shim.
+type AuthorityMeasurement struct {
+	Signature *EFISignatureData // The decoded signature, or nil if the variable data is not a recognized signature
+	Events    []*Event          // The events that measure this authority, in the order in which they were measured
+}
+
+// Duplicated indicates whether this authority was measured more than once.
+func (m *AuthorityMeasurement) Duplicated() bool {
+	return len(m.Events) > 1
+}
+
+func (m *AuthorityMeasurement) String() string {
+	var events []string
+	for _, e := range m.Events {
+		events = append(events, fmt.Sprintf("%d", e.Index))
+	}
+	var desc string
+	switch {
+	case m.Signature == nil:
+		desc = m.Events[0].Data.String()
+	default:
+		desc = m.Signature.String()
+	}
+	return fmt.Sprintf("%s (events: %v)", desc, events)
+}
+
+// authorityKey returns the bytes that identify the authority measured by the supplied EV_EFI_VARIABLE_AUTHORITY event. This is
+// the signature data where it can be decoded, so that the same certificate measured under different variable names or with
+// different owners is treated as the same authority.
+func authorityKey(e *Event) ([]byte, *EFISignatureData) {
+	d, ok := e.Data.(*EFIVariableData)
+	if !ok {
+		return e.Data.Bytes(), nil
+	}
+	sig, err := d.AuthoritySignature()
+	if err != nil {
+		return d.VariableData, nil
+	}
+	return sig.Data, sig
+}
+
+// AuthorityMeasurements returns each distinct authority that is measured to PCR 7 by EV_EFI_VARIABLE_AUTHORITY events in this log,
+// in the order in which they were first measured.
+func (l *Log) AuthorityMeasurements() (out []*AuthorityMeasurement) {
+	var keys [][]byte
+	for _, e := range l.Events {
+		if e.PCRIndex != 7 || e.EventType != EventTypeEFIVariableAuthority {
+			continue
+		}
+
+		key, sig := authorityKey(e)
+		found := false
+		for i, k := range keys {
+			if bytes.Equal(k, key) {
+				out[i].Events = append(out[i].Events, e)
+				found = true
+				break
+			}
+		}
+		if !found {
+			keys = append(keys, key)
+			out = append(out, &AuthorityMeasurement{Signature: sig, Events: []*Event{e}})
+		}
+	}
+	return out
+}
+
+// DuplicateAuthorityEvents returns the EV_EFI_VARIABLE_AUTHORITY events in this log that measure an authority that was already
+// measured by an earlier event. An empty result indicates that the platform measures each authority only once.
+func (l *Log) DuplicateAuthorityEvents() (out []*Event) {
+	duplicates := make(map[*Event]bool)
+	for _, m := range l.AuthorityMeasurements() {
+		for _, e := range m.Events[1:] {
+			duplicates[e] = true
+		}
+	}
+	for _, e := range l.Events {
+		if duplicates[e] {
+			out = append(out, e)
+		}
+	}
+	return out
+}
+
+// DeduplicatedEvents returns the events in this log with the duplicate EV_EFI_VARIABLE_AUTHORITY events (see
+// DuplicateAuthorityEvents) omitted. This describes the sequence of measurements that a platform which measures each authority
+// only once would make.
+func (l *Log) DeduplicatedEvents() (out []*Event) {
+	duplicates := make(map[*Event]bool)
+	for _, e := range l.DuplicateAuthorityEvents() {
+		duplicates[e] = true
+	}
+	for _, e := range l.Events {
+		if !duplicates[e] {
+			out = append(out, e)
+		}
+	}
+	return out
+}
+
+// CheckDuplicateAuthorities is a ValidationCheck that reports EV_EFI_VARIABLE_AUTHORITY events that measure an authority that
+// was already measured to PCR 7.
+func CheckDuplicateAuthorities(log *Log) (out []*ValidationFinding) {
+	for _, m := range log.AuthorityMeasurements() {
+		for _, e := range m.Events[1:] {
+			out = append(out, &ValidationFinding{Severity: SeverityWarning, Event: e,
+				Message: fmt.Sprintf("the authority was already measured by event %d", m.Events[0].Index)})
+		}
+	}
+	return out
+}
@@ -0,0 +1,88 @@
+// Copyright 2019 Canonical Ltd.
+// Licensed under the LGPLv3 with static-linking exception.
+// See LICENCE file for details.
+
+package tcglog
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+)
+
+func TestAuthorityMeasurements(t *testing.T) {
+	shimGuid := EFIGUID{0x60, 0x5d, 0xab, 0x50, 0xe0, 0x46, 0x43, 0x00, 0xab, 0xb6, 0x3d, 0xd8, 0x10, 0xdd, 0x8b, 0x23}
+	owner := EFIGUID{0x77, 0xfa, 0x9a, 0xbd, 0x03, 0x59, 0x4d, 0x32, 0xbd, 0x60, 0x28, 0xf4, 0xe7, 0x8f, 0x78, 0x4b}
+
+	caCert := makeTestCertificate(t, "Test CA")
+	vendorCert := makeTestCertificate(t, "Test Vendor")
+
+	authority := func(guid EFIGUID, name string, data []byte) *Event {
+		varData := EFIVariableData{VariableName: guid, UnicodeName: name, VariableData: data}
+		var b bytes.Buffer
+		varData.EncodeMeasuredBytes(&b)
+		return makeTestEvent(7, EventTypeEFIVariableAuthority, b.Bytes(), AlgorithmSha256)
+	}
+
+	firmwareDb := authority(EFIImageSecurityDatabaseGuid, "db", append(owner[:], caCert...))
+	shimDb := authority(EFIImageSecurityDatabaseGuid, "db", append(owner[:], caCert...))
+	shimVendor := authority(shimGuid, "Shim", vendorCert)
+	shimVendorDb := authority(shimGuid, "vendor_db", caCert)
+	unrecognized := authority(shimGuid, "MokListRT", []byte("foo"))
+
+	events := []*Event{
+		makeTestEvent(7, EventTypeSeparator, []byte{0, 0, 0, 0}, AlgorithmSha256),
+		firmwareDb,
+		makeTestEvent(4, EventTypeEFIBootServicesApplication, makeTestImageLoadEventData("\\EFI\\ubuntu\\shimx64.efi"),
+			AlgorithmSha256),
+		shimDb,
+		shimVendor,
+		shimVendorDb,
+		unrecognized}
+	log := NewLog(events)
+
+	measurements := log.AuthorityMeasurements()
+	if len(measurements) != 3 {
+		t.Fatalf("Unexpected number of authorities (%d)", len(measurements))
+	}
+	for i, expected := range []struct {
+		events    []*Event
+		signature bool
+	}{
+		{events: []*Event{firmwareDb, shimDb, shimVendorDb}, signature: true},
+		{events: []*Event{shimVendor}, signature: true},
+		{events: []*Event{unrecognized}},
+	} {
+		m := measurements[i]
+		if !reflect.DeepEqual(m.Events, expected.events) {
+			t.Errorf("Unexpected events for authority %d: %s", i, m)
+		}
+		if (m.Signature != nil) != expected.signature {
+			t.Errorf("Unexpected signature for authority %d: %s", i, m)
+		}
+		if m.Duplicated() != (len(expected.events) > 1) {
+			t.Errorf("Unexpected Duplicated() for authority %d", i)
+		}
+	}
+
+	if !reflect.DeepEqual(log.DuplicateAuthorityEvents(), []*Event{shimDb, shimVendorDb}) {
+		t.Errorf("Unexpected duplicate events")
+	}
+	if !reflect.DeepEqual(log.DeduplicatedEvents(), []*Event{events[0], firmwareDb, events[2], shimVendor, unrecognized}) {
+		t.Errorf("Unexpected deduplicated events")
+	}
+
+	v := new(Validator)
+	v.AddCheck("duplicate-authorities", CheckDuplicateAuthorities)
+	var findings []string
+	for _, f := range v.Validate(log) {
+		findings = append(findings, f.String())
+	}
+	if !reflect.DeepEqual(findings, []string{
+		"warning: duplicate-authorities: event 2 in PCR 7 (type: EV_EFI_VARIABLE_AUTHORITY): the authority was already measured by " +
+			"event 1",
+		"warning: duplicate-authorities: event 4 in PCR 7 (type: EV_EFI_VARIABLE_AUTHORITY): the authority was already measured by " +
+			"event 1"}) {
+		t.Errorf("Unexpected findings: %q", findings)
+	}
+}
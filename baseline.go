@@ -0,0 +1,160 @@
+// Copyright 2019 Canonical Ltd.
+// Licensed under the LGPLv3 with static-linking exception.
+// See LICENCE file for details.
+
+package tcglog
+
+import (
+	"fmt"
+	"io"
+	"path"
+
+	"golang.org/x/xerrors"
+)
+
+// Tolerance describes a class of event differences that are expected to vary between boots and which shouldn't be reported as
+// deviations from a baseline.
+type Tolerance struct {
+	Name  string
+	Match func(d *EventDiff) bool // Returns true if the difference is tolerated
+}
+
+func (d *EventDiff) events() []*Event {
+	var out []*Event
+	if d.A != nil {
+		out = append(out, d.A)
+	}
+	if d.B != nil {
+		out = append(out, d.B)
+	}
+	return out
+}
+
+// TolerateEventType returns a Tolerance that permits any difference in events of the specified type.
+func TolerateEventType(name string, eventType EventType) Tolerance {
+	return Tolerance{
+		Name: name,
+		Match: func(d *EventDiff) bool {
+			for _, e := range d.events() {
+				if e.EventType != eventType {
+					return false
+				}
+			}
+			return true
+		}}
+}
+
+// TolerateEFIVariable returns a Tolerance that permits any difference in the measurement of EFI variables with a name that
+// matches the supplied pattern, using the syntax of path.Match.
+func TolerateEFIVariable(name, pattern string) Tolerance {
+	return Tolerance{
+		Name: name,
+		Match: func(d *EventDiff) bool {
+			for _, e := range d.events() {
+				v, ok := e.Data.(*EFIVariableData)
+				if !ok {
+					return false
+				}
+				if matched, _ := path.Match(pattern, v.UnicodeName); !matched {
+					return false
+				}
+			}
+			return true
+		}}
+}
+
+var (
+	// TolerateBootOrder permits changes to the measurement of the BootOrder variable.
+	TolerateBootOrder = TolerateEFIVariable("boot-order", "BootOrder")
+
+	// TolerateBootEntries permits changes to the measurement of Boot#### variables, eg, from boot entries being created or
+	// reordered, or from firmware that updates a counter in a boot entry on every boot.
+	TolerateBootEntries = TolerateEFIVariable("boot-entries", "Boot[0-9A-F][0-9A-F][0-9A-F][0-9A-F]")
+
+	// TolerateMicrocode permits changes to EV_CPU_MICROCODE events, eg, from a microcode revision update.
+	TolerateMicrocode = TolerateEventType("microcode", EventTypeCPUMicrocode)
+
+	// DefaultTolerances is the set of tolerances used by a Baseline that is created without any tolerances.
+	DefaultTolerances = []Tolerance{TolerateBootOrder, TolerateBootEntries, TolerateMicrocode}
+)
+
+// ToleratedDifference associates a difference from a baseline with the tolerance that permits it.
+type ToleratedDifference struct {
+	*EventDiff
+	Tolerance string
+}
+
+// BaselineReport is the result of comparing a log with a baseline.
+type BaselineReport struct {
+	Diff       *LogDiff               // The complete diff between the baseline and the log
+	Deviations []*EventDiff           // Differences that aren't permitted by any tolerance, in PCR order
+	Tolerated  []*ToleratedDifference // Differences that are permitted by a tolerance, in PCR order
+}
+
+// Ok indicates whether the log has no meaningful deviations from the baseline.
+func (r *BaselineReport) Ok() bool {
+	return len(r.Deviations) == 0
+}
+
+// Baseline is a golden reference log that subsequent boots can be compared against.
+type Baseline struct {
+	Log        *Log
+	Tolerances []Tolerance
+}
+
+// NewBaseline creates a new baseline from the supplied reference log. If no tolerances are supplied, DefaultTolerances is used.
+func NewBaseline(log *Log, tolerances ...Tolerance) *Baseline {
+	if len(tolerances) == 0 {
+		tolerances = DefaultTolerances
+	}
+	return &Baseline{Log: log, Tolerances: tolerances}
+}
+
+// ReadBaseline reads a reference log from r, as written by Baseline.Write, and creates a new baseline from it. See NewBaseline.
+func ReadBaseline(r io.Reader, options *LogOptions, tolerances ...Tolerance) (*Baseline, error) {
+	if options == nil {
+		options = &LogOptions{}
+	}
+	log, err := ParseLog(r, options)
+	if err != nil {
+		return nil, xerrors.Errorf("cannot parse baseline log: %w", err)
+	}
+	return NewBaseline(log, tolerances...), nil
+}
+
+// Write serializes the reference log of this baseline to w, so that it can be restored with ReadBaseline. Tolerances aren't
+// serialized.
+func (b *Baseline) Write(w io.Writer) error {
+	return WriteLog(w, b.Log)
+}
+
+// Compare compares the supplied log with this baseline, and reports the differences that aren't permitted by any of the
+// baseline's tolerances.
+func (b *Baseline) Compare(log *Log) *BaselineReport {
+	report := &BaselineReport{Diff: DiffLogs(b.Log, log)}
+	for _, p := range report.Diff.PCRs {
+	Diffs:
+		for _, d := range p.Events {
+			for _, t := range b.Tolerances {
+				if t.Match(d) {
+					report.Tolerated = append(report.Tolerated, &ToleratedDifference{EventDiff: d, Tolerance: t.Name})
+					continue Diffs
+				}
+			}
+			report.Deviations = append(report.Deviations, d)
+		}
+	}
+	return report
+}
+
+func (r *BaselineReport) String() string {
+	if r.Ok() {
+		return fmt.Sprintf("no deviations from baseline (%d tolerated differences)", len(r.Tolerated))
+	}
+	s := fmt.Sprintf("%d deviation(s) from baseline:", len(r.Deviations))
+	for _, d := range r.Deviations {
+		pcr := d.events()[0].PCRIndex
+		s += fmt.Sprintf("\n  PCR %d: %s", pcr, d)
+	}
+	return s
+}
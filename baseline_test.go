@@ -0,0 +1,86 @@
+// Copyright 2019 Canonical Ltd.
+// Licensed under the LGPLv3 with static-linking exception.
+// See LICENCE file for details.
+
+package tcglog
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestBaseline(t *testing.T) {
+	newLog := func(bootOrder, microcode, kernel []byte) *Log {
+		b, err := NewLogBuilder(AlgorithmIdList{AlgorithmSha256}, nil)
+		if err != nil {
+			t.Fatalf("NewLogBuilder failed: %v", err)
+		}
+		b.AddEvent(0, EventTypeSCRTMVersion, []byte{0x31, 0x00})
+		b.AddEventWithDigests(1, EventTypeCPUMicrocode, DigestMap{AlgorithmSha256: AlgorithmSha256.hash(microcode)}, microcode)
+		var v bytes.Buffer
+		(&EFIVariableData{VariableName: EFIGlobalVariableGuid, UnicodeName: "BootOrder", VariableData: bootOrder}).EncodeMeasuredBytes(&v)
+		b.AddEvent(1, EventTypeEFIVariableBoot, v.Bytes())
+		b.AddEvent(4, EventTypeEFIAction, kernel)
+		for pcr := PCRIndex(0); pcr <= 7; pcr++ {
+			b.AddEvent(pcr, EventTypeSeparator, []byte{0, 0, 0, 0})
+		}
+		return b.Log()
+	}
+
+	reference := newLog([]byte{0x01, 0x00}, []byte("rev 1"), []byte("kernel 1"))
+
+	var stored bytes.Buffer
+	if err := NewBaseline(reference).Write(&stored); err != nil {
+		t.Fatalf("Write failed: %v", err)
+	}
+	baseline, err := ReadBaseline(&stored, nil)
+	if err != nil {
+		t.Fatalf("ReadBaseline failed: %v", err)
+	}
+	if len(baseline.Tolerances) != len(DefaultTolerances) {
+		t.Errorf("Unexpected tolerances")
+	}
+
+	t.Run("Identical", func(t *testing.T) {
+		report := baseline.Compare(reference)
+		if !report.Ok() || len(report.Tolerated) != 0 || !report.Diff.Equal() {
+			t.Errorf("Unexpected report: %s", report)
+		}
+	})
+
+	t.Run("Tolerated", func(t *testing.T) {
+		report := baseline.Compare(newLog([]byte{0x02, 0x00, 0x01, 0x00}, []byte("rev 2"), []byte("kernel 1")))
+		if !report.Ok() {
+			t.Errorf("Unexpected report: %s", report)
+		}
+		if len(report.Tolerated) != 2 || report.Tolerated[0].Tolerance != "microcode" ||
+			report.Tolerated[1].Tolerance != "boot-order" {
+			t.Errorf("Unexpected tolerated differences: %v", report.Tolerated)
+		}
+		if report.String() != "no deviations from baseline (2 tolerated differences)" {
+			t.Errorf("Unexpected string: %s", report)
+		}
+	})
+
+	t.Run("Deviation", func(t *testing.T) {
+		report := baseline.Compare(newLog([]byte{0x01, 0x00}, []byte("rev 2"), []byte("kernel 2")))
+		if report.Ok() {
+			t.Fatalf("Expected a deviation")
+		}
+		if len(report.Deviations) != 1 || report.Deviations[0].Kind != EventChanged ||
+			report.Deviations[0].B.PCRIndex != 4 {
+			t.Errorf("Unexpected deviations: %v", report.Deviations)
+		}
+		if len(report.Tolerated) != 1 {
+			t.Errorf("Unexpected tolerated differences: %v", report.Tolerated)
+		}
+	})
+
+	t.Run("NoTolerances", func(t *testing.T) {
+		strict := NewBaseline(reference, TolerateEventType("none", EventTypeAction))
+		report := strict.Compare(newLog([]byte{0x02, 0x00}, []byte("rev 1"), []byte("kernel 1")))
+		if report.Ok() || len(report.Deviations) != 1 {
+			t.Errorf("Unexpected report: %s", report)
+		}
+	})
+}
@@ -0,0 +1,137 @@
+// Copyright 2019 Canonical Ltd.
+// Licensed under the LGPLv3 with static-linking exception.
+// See LICENCE file for details.
+
+package tcglog
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+
+	"golang.org/x/xerrors"
+)
+
+// BootChainNode is a node in the tree of images loaded during boot. The root node represents the platform firmware and has no
+// event. Its children are the drivers and option ROMs that it loaded, and the EFI applications that it loaded from boot options.
+// Drivers are always leaf nodes.
+type BootChainNode struct {
+	Event      *Event // The image load event in PCR 2 or PCR 4, or nil for the root node
+	Path       string // The path of the image, or empty if the device path doesn't contain one
+	DevicePath string // The textual representation of the device path from which the image was loaded
+
+	// Authorities are the EV_EFI_VARIABLE_AUTHORITY events measured to PCR 7 in order to authorize this image (see
+	// SecureBootImage).
+	Authorities []*SecureBootAuthority
+
+	// Image describes the file on the EFI system partition that corresponds to this image, if Log.BootChain was supplied the
+	// path of one.
+	Image *ESPImage
+
+	Parent   *BootChainNode
+	Children []*BootChainNode
+}
+
+// IsDriver indicates whether this node corresponds to a boot or runtime services driver rather than an EFI application.
+func (n *BootChainNode) IsDriver() bool {
+	return n.Event != nil && n.Event.EventType != EventTypeEFIBootServicesApplication
+}
+
+func (n *BootChainNode) describe() string {
+	if n.Event == nil {
+		return "platform firmware"
+	}
+
+	var b bytes.Buffer
+	switch {
+	case n.Path != "":
+		b.WriteString(n.Path)
+	case n.DevicePath != "":
+		b.WriteString(EscapeString(n.DevicePath))
+	default:
+		b.WriteString("<unknown>")
+	}
+	if n.IsDriver() {
+		b.WriteString(" [driver]")
+	}
+	fmt.Fprintf(&b, " (event %d in PCR %d)", n.Event.Index, n.Event.PCRIndex)
+	for _, a := range n.Authorities {
+		fmt.Fprintf(&b, ", authorized by event %d in PCR %d", a.Event.Index, a.Event.PCRIndex)
+	}
+	if n.Image != nil {
+		fmt.Fprintf(&b, ", on ESP: %s", n.Image.Status)
+		if n.Image.File != "" && n.Image.File != n.Path {
+			fmt.Fprintf(&b, " (matches %s)", n.Image.File)
+		}
+	}
+	return b.String()
+}
+
+func (n *BootChainNode) writeTo(b *bytes.Buffer, depth int) {
+	if depth > 0 {
+		b.WriteString("\n")
+	}
+	fmt.Fprintf(b, "%s%s", strings.Repeat("  ", depth), n.describe())
+	for _, c := range n.Children {
+		c.writeTo(b, depth+1)
+	}
+}
+
+// String returns an indented rendering of the tree rooted at this node.
+func (n *BootChainNode) String() string {
+	var b bytes.Buffer
+	n.writeTo(&b, 0)
+	return b.String()
+}
+
+// BootChain reconstructs the tree of images loaded during boot from the image load events in PCR 2 and PCR 4. The log doesn't
+// record which image loaded another, so each image is assumed to be loaded by the most recently loaded EFI application (eg,
+// firmware → shim → GRUB → kernel), or by the platform firmware if no application has been loaded yet or a "Returning from EFI
+// Application from Boot Option" event indicates that control returned to the firmware's boot manager.
+//
+// If espRoot is not empty, each EFI application is compared with the images on the EFI system partition mounted at that path in
+// order to identify the file that it was loaded from (see Log.CheckESPImages).
+func (l *Log) BootChain(espRoot string) (*BootChainNode, error) {
+	authorities := make(map[*Event][]*SecureBootAuthority)
+	for _, i := range l.SecureBootPolicy().Images {
+		authorities[i.Event] = i.Authorities
+	}
+
+	images := make(map[*Event]*ESPImage)
+	if espRoot != "" {
+		espImages, err := l.CheckESPImages(espRoot)
+		if err != nil {
+			return nil, xerrors.Errorf("cannot compare images with ESP: %w", err)
+		}
+		for _, i := range espImages {
+			images[i.Event] = i
+		}
+	}
+
+	root := &BootChainNode{}
+	current := root
+
+	for _, e := range l.Events {
+		if d, ok := e.Data.(*ActionEventData); ok && d.Action == ReturningFromEFIApplication {
+			current = root
+			continue
+		}
+		if !isImageLoadEvent(e) {
+			continue
+		}
+
+		n := &BootChainNode{Event: e, Authorities: authorities[e], Image: images[e]}
+		if d, ok := e.Data.(*EFIImageLoadEvent); ok {
+			n.Path = d.FilePath()
+			n.DevicePath = d.DevicePath
+		}
+
+		n.Parent = current
+		current.Children = append(current.Children, n)
+		if !n.IsDriver() {
+			current = n
+		}
+	}
+
+	return root, nil
+}
@@ -0,0 +1,90 @@
+// Copyright 2019 Canonical Ltd.
+// Licensed under the LGPLv3 with static-linking exception.
+// See LICENCE file for details.
+
+package tcglog
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"testing"
+)
+
+func TestBootChain(t *testing.T) {
+	owner := MakeEFIGUID(0x77fa9abd, 0x0359, 0x4d32, 0xbd60, [...]uint8{0x28, 0xf4, 0xe7, 0x8f, 0x78, 0x4b})
+	varData := EFIVariableData{VariableName: EFIImageSecurityDatabaseGuid, UnicodeName: "db",
+		VariableData: append(owner[:], makeTestCertificate(t, "Test UEFI CA")...)}
+	var authority bytes.Buffer
+	varData.EncodeMeasuredBytes(&authority)
+
+	event := func(pcr PCRIndex, eventType EventType, data []byte) *Event {
+		return makeTestEvent(pcr, eventType, data, AlgorithmSha256)
+	}
+	image := func(eventType EventType, path string) *Event {
+		pcr := PCRIndex(4)
+		if eventType != EventTypeEFIBootServicesApplication {
+			pcr = 2
+		}
+		return event(pcr, eventType, makeTestImageLoadEventData(path))
+	}
+
+	events := []*Event{
+		image(EventTypeEFIBootServicesDriver, "\\Driver.efi"),
+		event(4, EventTypeEFIAction, []byte("Calling EFI Application from Boot Option")),
+		event(7, EventTypeEFIVariableAuthority, authority.Bytes()),
+		image(EventTypeEFIBootServicesApplication, "\\EFI\\ubuntu\\shimx64.efi"),
+		image(EventTypeEFIBootServicesApplication, "\\EFI\\ubuntu\\grubx64.efi"),
+		image(EventTypeEFIBootServicesApplication, "\\vmlinuz"),
+		event(4, EventTypeEFIAction, []byte("Returning from EFI Application from Boot Option")),
+		image(EventTypeEFIBootServicesApplication, "\\EFI\\BOOT\\BOOTX64.EFI"),
+	}
+	log := NewLog(events)
+
+	root, err := log.BootChain("")
+	if err != nil {
+		t.Fatalf("BootChain failed: %v", err)
+	}
+	if root.Event != nil || len(root.Children) != 3 {
+		t.Fatalf("Unexpected root")
+	}
+	shim := root.Children[1]
+	if shim.Event != events[3] || shim.Path != "/EFI/ubuntu/shimx64.efi" || shim.Parent != root ||
+		len(shim.Authorities) != 1 || shim.Authorities[0].Event != events[2] {
+		t.Errorf("Unexpected shim node")
+	}
+	if len(shim.Children) != 1 || shim.Children[0].Event != events[4] || len(shim.Children[0].Children) != 1 ||
+		shim.Children[0].Children[0].Parent != shim.Children[0] {
+		t.Errorf("Unexpected chain")
+	}
+	if !root.Children[0].IsDriver() || root.Children[1].IsDriver() {
+		t.Errorf("Unexpected IsDriver result")
+	}
+
+	expected := `platform firmware
+  /Driver.efi [driver] (event 0 in PCR 2)
+  /EFI/ubuntu/shimx64.efi (event 1 in PCR 4), authorized by event 0 in PCR 7
+    /EFI/ubuntu/grubx64.efi (event 2 in PCR 4)
+      /vmlinuz (event 3 in PCR 4)
+  /EFI/BOOT/BOOTX64.EFI (event 5 in PCR 4)`
+	if root.String() != expected {
+		t.Errorf("Unexpected string:\n%s", root)
+	}
+
+	dir, err := ioutil.TempDir("", "esp")
+	if err != nil {
+		t.Fatalf("TempDir failed: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	root, err = log.BootChain(dir)
+	if err != nil {
+		t.Fatalf("BootChain failed: %v", err)
+	}
+	if root.Children[0].Image != nil {
+		t.Errorf("Unexpected ESP image for driver")
+	}
+	if i := root.Children[1].Image; i == nil || i.Event != events[3] || i.Status != ESPImageMissing {
+		t.Errorf("Unexpected ESP image for shim")
+	}
+}
@@ -0,0 +1,252 @@
+// Copyright 2019 Canonical Ltd.
+// Licensed under the LGPLv3 with static-linking exception.
+// See LICENCE file for details.
+
+package tcglog
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"os"
+	"strings"
+
+	"golang.org/x/xerrors"
+)
+
+// BootEntryStatus describes how a boot entry has changed since it was measured.
+type BootEntryStatus int
+
+const (
+	// BootEntryUnchanged indicates that the current value of the Boot#### variable matches the measured value.
+	BootEntryUnchanged BootEntryStatus = iota
+
+	// BootEntryModified indicates that the current value of the Boot#### variable is different to the measured value.
+	BootEntryModified
+
+	// BootEntryDeleted indicates that the measured Boot#### variable no longer exists.
+	BootEntryDeleted
+
+	// BootEntryAdded indicates that the Boot#### variable is in the current BootOrder but wasn't measured.
+	BootEntryAdded
+)
+
+func (s BootEntryStatus) String() string {
+	switch s {
+	case BootEntryUnchanged:
+		return "unchanged"
+	case BootEntryModified:
+		return "modified"
+	case BootEntryDeleted:
+		return "deleted"
+	case BootEntryAdded:
+		return "added"
+	default:
+		return fmt.Sprintf("%%!(UNKNOWN_BOOT_ENTRY_STATUS=%d)", int(s))
+	}
+}
+
+// BootEntry correlates a Boot#### variable measured to the log with its current value.
+type BootEntry struct {
+	Name     string         // The variable name, eg, "Boot0001"
+	Event    *Event         // The last EV_EFI_VARIABLE_BOOT event that measures this entry, or nil if it wasn't measured
+	Measured *EFILoadOption // The measured load option, or nil if it wasn't measured or can't be decoded
+	Current  *EFILoadOption // The current load option, or nil if it doesn't exist or can't be decoded
+	Status   BootEntryStatus
+}
+
+// Stale indicates whether this entry has changed since it was measured, which means that PCR 1 will have a different value on
+// the next boot.
+func (e *BootEntry) Stale() bool {
+	return e.Status != BootEntryUnchanged
+}
+
+func (e *BootEntry) String() string {
+	var b bytes.Buffer
+	b.WriteString(e.Name)
+	switch {
+	case e.Current != nil:
+		fmt.Fprintf(&b, " \"%s\"", EscapeString(e.Current.Description))
+	case e.Measured != nil:
+		fmt.Fprintf(&b, " \"%s\"", EscapeString(e.Measured.Description))
+	}
+	fmt.Fprintf(&b, ": %s", e.Status)
+	if e.Event != nil {
+		fmt.Fprintf(&b, " (event %d in PCR %d)", e.Event.Index, e.Event.PCRIndex)
+	}
+	return b.String()
+}
+
+// BootEntryCorrelation is the result of correlating the boot entries measured to a log with the current boot variables.
+type BootEntryCorrelation struct {
+	Entries []*BootEntry // The measured entries in the order in which they were measured, followed by entries that were added
+
+	MeasuredOrder []string // The measured BootOrder, or nil if it wasn't measured
+	CurrentOrder  []string // The current BootOrder, or nil if it doesn't exist
+
+	// BootCurrent is the entry named by the current value of the BootCurrent variable, which is set by the firmware to the
+	// entry that was used for the current boot. It is empty if the variable doesn't exist.
+	BootCurrent string
+
+	// Taken is the entry that was used for the current boot. This is the entry named by BootCurrent if there is one. Otherwise,
+	// it is the measured entry with a device path that matches the device path of the first EFI application loaded, if any.
+	Taken *BootEntry
+
+	// FirstImage is the first EV_EFI_BOOT_SERVICES_APPLICATION event in PCR 4, or nil if there isn't one.
+	FirstImage *Event
+}
+
+// OrderChanged indicates whether the current BootOrder is different to the measured one.
+func (c *BootEntryCorrelation) OrderChanged() bool {
+	if len(c.MeasuredOrder) != len(c.CurrentOrder) {
+		return true
+	}
+	for i, n := range c.MeasuredOrder {
+		if c.CurrentOrder[i] != n {
+			return true
+		}
+	}
+	return false
+}
+
+// Stale returns the entries that have changed since they were measured.
+func (c *BootEntryCorrelation) Stale() (out []*BootEntry) {
+	for _, e := range c.Entries {
+		if e.Stale() {
+			out = append(out, e)
+		}
+	}
+	return out
+}
+
+func (c *BootEntryCorrelation) String() string {
+	var b bytes.Buffer
+	fmt.Fprintf(&b, "BootOrder: measured [%s], current [%s]", strings.Join(c.MeasuredOrder, ","),
+		strings.Join(c.CurrentOrder, ","))
+	if c.OrderChanged() {
+		b.WriteString(" (changed)")
+	}
+	if c.Taken != nil {
+		fmt.Fprintf(&b, "\nBoot entry taken: %s", c.Taken.Name)
+	}
+	for _, e := range c.Entries {
+		fmt.Fprintf(&b, "\n%s", e)
+	}
+	return b.String()
+}
+
+// bootEntryMatchesImage indicates whether the supplied load option could have loaded the image described by the supplied device
+// path. Load options often contain a short-form device path that begins with the hard drive node, so this checks whether the
+// image's device path ends with the load option's.
+func bootEntryMatchesImage(o *EFILoadOption, devicePath string) bool {
+	if o == nil || o.FilePath == "" {
+		return false
+	}
+	return strings.HasSuffix(strings.ToLower(devicePath), strings.ToLower(o.FilePath))
+}
+
+// CorrelateBootEntries correlates the boot variables measured by EV_EFI_VARIABLE_BOOT events in this log with the current boot
+// variables read from the supplied reader (eg, EFIVarfs when running on the measured machine). Each measured Boot#### entry is
+// compared with its current value, and entries in the current BootOrder that weren't measured are reported as added. The
+// entry that was used for the current boot is identified from BootCurrent, or from the first EFI application loaded.
+func (l *Log) CorrelateBootEntries(r EFIVariableReader) (*BootEntryCorrelation, error) {
+	out := &BootEntryCorrelation{}
+
+	var names []string
+	measured := make(map[string]*Event)
+	for _, e := range l.Events {
+		if e.PCRIndex == 4 && e.EventType == EventTypeEFIBootServicesApplication && out.FirstImage == nil {
+			out.FirstImage = e
+		}
+		if e.EventType != EventTypeEFIVariableBoot {
+			continue
+		}
+		d, ok := e.Data.(*EFIVariableData)
+		if !ok || d.VariableName != EFIGlobalVariableGuid {
+			continue
+		}
+		switch {
+		case d.UnicodeName == "BootOrder":
+			out.MeasuredOrder, _ = d.LoadOrder()
+		case d.IsLoadOption() && strings.HasPrefix(d.UnicodeName, "Boot"):
+			if _, seen := measured[d.UnicodeName]; !seen {
+				names = append(names, d.UnicodeName)
+			}
+			measured[d.UnicodeName] = e
+		}
+	}
+
+	entries := make(map[string]*BootEntry)
+	for _, name := range names {
+		e := measured[name]
+		d := e.Data.(*EFIVariableData)
+		entry := &BootEntry{Name: name, Event: e}
+		entry.Measured, _ = d.LoadOption()
+
+		data, err := r.ReadEFIVariable(name, EFIGlobalVariableGuid)
+		switch {
+		case xerrors.Is(err, os.ErrNotExist):
+			entry.Status = BootEntryDeleted
+		case err != nil:
+			return nil, xerrors.Errorf("cannot read variable %s: %w", name, err)
+		default:
+			entry.Current, _ = DecodeEFILoadOption(data)
+			if !bytes.Equal(data, d.VariableData) {
+				entry.Status = BootEntryModified
+			}
+		}
+		out.Entries = append(out.Entries, entry)
+		entries[name] = entry
+	}
+
+	order, err := r.ReadEFIVariable("BootOrder", EFIGlobalVariableGuid)
+	switch {
+	case xerrors.Is(err, os.ErrNotExist):
+	case err != nil:
+		return nil, xerrors.Errorf("cannot read variable BootOrder: %w", err)
+	default:
+		out.CurrentOrder, _ = (&EFIVariableData{VariableName: EFIGlobalVariableGuid, UnicodeName: "BootOrder",
+			VariableData: order}).LoadOrder()
+	}
+	for _, name := range out.CurrentOrder {
+		if _, ok := entries[name]; ok {
+			continue
+		}
+		data, err := r.ReadEFIVariable(name, EFIGlobalVariableGuid)
+		switch {
+		case xerrors.Is(err, os.ErrNotExist):
+			continue
+		case err != nil:
+			return nil, xerrors.Errorf("cannot read variable %s: %w", name, err)
+		}
+		entry := &BootEntry{Name: name, Status: BootEntryAdded}
+		entry.Current, _ = DecodeEFILoadOption(data)
+		out.Entries = append(out.Entries, entry)
+		entries[name] = entry
+	}
+
+	current, err := r.ReadEFIVariable("BootCurrent", EFIGlobalVariableGuid)
+	switch {
+	case xerrors.Is(err, os.ErrNotExist):
+	case err != nil:
+		return nil, xerrors.Errorf("cannot read variable BootCurrent: %w", err)
+	case len(current) != 2:
+		return nil, fmt.Errorf("invalid BootCurrent length (%d)", len(current))
+	default:
+		out.BootCurrent = fmt.Sprintf("Boot%04X", binary.LittleEndian.Uint16(current))
+		out.Taken = entries[out.BootCurrent]
+	}
+
+	if out.Taken == nil && out.FirstImage != nil {
+		if d, ok := out.FirstImage.Data.(*EFIImageLoadEvent); ok {
+			for _, e := range out.Entries {
+				if e.Event != nil && bootEntryMatchesImage(e.Measured, d.DevicePath) {
+					out.Taken = e
+					break
+				}
+			}
+		}
+	}
+
+	return out, nil
+}
@@ -0,0 +1,99 @@
+// Copyright 2019 Canonical Ltd.
+// Licensed under the LGPLv3 with static-linking exception.
+// See LICENCE file for details.
+
+package tcglog
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestCorrelateBootEntries(t *testing.T) {
+	espGUID := MakeEFIGUID(0x6a3c6f52, 0x1cbd, 0x4c9e, 0x8f27, [...]uint8{0x5b, 0x19, 0x30, 0x4e, 0x81, 0x6c})
+	shim := makeTestHDImageLoadEventData(espGUID, "\\EFI\\ubuntu\\shimx64.efi")
+	boot1 := makeTestLoadOption(EFILoadOptionActive, "ubuntu", shim[32:], nil)
+	boot2 := makeTestLoadOption(EFILoadOptionActive, "Windows Boot Manager",
+		makeTestHDImageLoadEventData(espGUID, "\\EFI\\Microsoft\\Boot\\bootmgfw.efi")[32:], nil)
+	boot3 := makeTestLoadOption(EFILoadOptionActive, "UEFI Shell",
+		makeTestHDImageLoadEventData(espGUID, "\\EFI\\shell.efi")[32:], nil)
+
+	variable := func(name string, data []byte) *Event {
+		var b bytes.Buffer
+		(&EFIVariableData{VariableName: EFIGlobalVariableGuid, UnicodeName: name, VariableData: data}).EncodeMeasuredBytes(&b)
+		return makeTestEvent(1, EventTypeEFIVariableBoot, b.Bytes(), AlgorithmSha256)
+	}
+	events := []*Event{
+		variable("BootOrder", []byte{0x01, 0x00, 0x02, 0x00}),
+		variable("Boot0001", boot1),
+		variable("Boot0002", boot2),
+		makeTestEvent(4, EventTypeEFIBootServicesApplication, shim, AlgorithmSha256),
+	}
+	log := NewLog(events)
+
+	dir, err := ioutil.TempDir("", "efivars")
+	if err != nil {
+		t.Fatalf("TempDir failed: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	writeVariable := func(name string, data []byte) {
+		path := filepath.Join(dir, name+"-8be4df61-93ca-11d2-aa0d-00e098032b8c")
+		if err := ioutil.WriteFile(path, append([]byte{0x07, 0x00, 0x00, 0x00}, data...), 0644); err != nil {
+			t.Fatalf("WriteFile failed: %v", err)
+		}
+	}
+	writeVariable("BootOrder", []byte{0x03, 0x00, 0x01, 0x00})
+	writeVariable("Boot0001", boot1)
+	writeVariable("Boot0003", boot3)
+
+	c, err := log.CorrelateBootEntries(EFIVarfs(dir))
+	if err != nil {
+		t.Fatalf("CorrelateBootEntries failed: %v", err)
+	}
+	if len(c.Entries) != 3 {
+		t.Fatalf("Unexpected number of entries: %d", len(c.Entries))
+	}
+	for i, expected := range []struct {
+		name   string
+		event  *Event
+		status BootEntryStatus
+	}{
+		{"Boot0001", events[1], BootEntryUnchanged},
+		{"Boot0002", events[2], BootEntryDeleted},
+		{"Boot0003", nil, BootEntryAdded},
+	} {
+		e := c.Entries[i]
+		if e.Name != expected.name || e.Event != expected.event || e.Status != expected.status {
+			t.Errorf("Unexpected entry %d: %s", i, e)
+		}
+	}
+	if !c.OrderChanged() || len(c.Stale()) != 2 {
+		t.Errorf("Expected the boot order and entries to be stale")
+	}
+	if c.BootCurrent != "" || c.Taken != c.Entries[0] || c.FirstImage != events[3] {
+		t.Errorf("Unexpected taken entry")
+	}
+	expected := `BootOrder: measured [Boot0001,Boot0002], current [Boot0003,Boot0001] (changed)
+Boot entry taken: Boot0001
+Boot0001 "ubuntu": unchanged (event 1 in PCR 1)
+Boot0002 "Windows Boot Manager": deleted (event 2 in PCR 1)
+Boot0003 "UEFI Shell": added`
+	if c.String() != expected {
+		t.Errorf("Unexpected string:\n%s", c)
+	}
+
+	// BootCurrent takes precedence over the first image.
+	writeVariable("BootCurrent", []byte{0x02, 0x00})
+	writeVariable("Boot0002", boot1)
+	c, err = log.CorrelateBootEntries(EFIVarfs(dir))
+	if err != nil {
+		t.Fatalf("CorrelateBootEntries failed: %v", err)
+	}
+	if c.BootCurrent != "Boot0002" || c.Taken != c.Entries[1] || c.Taken.Status != BootEntryModified {
+		t.Errorf("Unexpected taken entry: %v", c.Taken)
+	}
+}
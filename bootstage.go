@@ -0,0 +1,152 @@
+// Copyright 2019 Canonical Ltd.
+// Licensed under the LGPLv3 with static-linking exception.
+// See LICENCE file for details.
+
+package tcglog
+
+import (
+	"fmt"
+	"strings"
+)
+
+// BootStage describes the stage of the boot process during which an event was measured.
+type BootStage int
+
+const (
+	// BootStageUnknown indicates that the stage hasn't been determined.
+	BootStageUnknown BootStage = iota
+
+	// BootStageSCRTM indicates a measurement of the static core root of trust for measurement.
+	BootStageSCRTM
+
+	// BootStagePlatformFirmware indicates a measurement made by the platform firmware before the boot manager runs.
+	BootStagePlatformFirmware
+
+	// BootStageOptionROMs indicates a measurement of option ROM code or configuration, or other drivers that aren't part of
+	// the platform firmware.
+	BootStageOptionROMs
+
+	// BootStageBootManager indicates a measurement made by the firmware boot manager, such as the boot variables, the partition
+	// table, the separators and the load of the first EFI application.
+	BootStageBootManager
+
+	// BootStageBootloader indicates a measurement made by the bootloader (such as shim or GRUB) or by the firmware on its behalf.
+	BootStageBootloader
+
+	// BootStageKernel indicates a measurement made by the kernel's EFI stub or by systemd-stub.
+	BootStageKernel
+
+	// BootStageOSPresent indicates a measurement made from ExitBootServices onwards.
+	BootStageOSPresent
+)
+
+var bootStageNames = map[BootStage]string{
+	BootStageUnknown:          "unknown",
+	BootStageSCRTM:            "S-CRTM",
+	BootStagePlatformFirmware: "platform firmware",
+	BootStageOptionROMs:       "option ROMs",
+	BootStageBootManager:      "boot manager",
+	BootStageBootloader:       "bootloader",
+	BootStageKernel:           "kernel",
+	BootStageOSPresent:        "OS-present",
+}
+
+func (s BootStage) String() string {
+	if name, ok := bootStageNames[s]; ok {
+		return name
+	}
+	return fmt.Sprintf("%%!(UNKNOWN_BOOT_STAGE=%d)", int(s))
+}
+
+func normalizeBootStageName(name string) string {
+	return strings.Replace(strings.Replace(strings.ToLower(name), " ", "", -1), "-", "", -1)
+}
+
+// ParseBootStage returns the BootStage with the supplied name. The name is matched case-insensitively and ignoring spaces and
+// hyphens, so "boot-manager" and "os-present" are accepted.
+func ParseBootStage(name string) (BootStage, error) {
+	n := normalizeBootStageName(name)
+	for s, sname := range bootStageNames {
+		if normalizeBootStageName(sname) == n {
+			return s, nil
+		}
+	}
+	return BootStageUnknown, fmt.Errorf("unrecognized boot stage \"%s\"", name)
+}
+
+// ClassifyBootStages populates the Stage field of every event in this log using heuristics based on the PCR index, the event
+// type and data and the order of events. The boot progresses through the platform firmware and boot manager stages until the
+// first EFI application is loaded, the bootloader stage until the kernel's EFI stub or systemd-stub makes a measurement, and the
+// kernel stage until ExitBootServices is called. S-CRTM measurements and measurements of option ROMs and drivers are identified
+// by their type and PCR. This is done automatically by NewLog, ParseLog and the editing functions, but must be called explicitly
+// after modifying the Events field directly.
+func (l *Log) ClassifyBootStages() {
+	stage := BootStagePlatformFirmware
+
+	for _, e := range l.Events {
+		if stage < BootStageOSPresent {
+			if d, ok := e.Data.(*ActionEventData); ok {
+				switch d.Action {
+				case ExitBootServicesInvocation, ExitBootServicesReturnedFailure, ExitBootServicesReturnedSuccess:
+					stage = BootStageOSPresent
+				}
+			}
+		}
+
+		switch {
+		case stage == BootStageOSPresent:
+			e.Stage = stage
+		case e.EventType == EventTypeSCRTMVersion || e.EventType == EventTypeSCRTMContents || e.EventType == EventTypeEFIHCRTMEvent:
+			e.Stage = BootStageSCRTM
+		case stage <= BootStageBootManager && (e.PCRIndex == 2 || e.PCRIndex == 3) && e.EventType != EventTypeSeparator:
+			e.Stage = BootStageOptionROMs
+		case stage <= BootStageBootManager && (e.EventType == EventTypeEFIBootServicesDriver ||
+			e.EventType == EventTypeEFIRuntimeServicesDriver):
+			e.Stage = BootStageOptionROMs
+		case isLinuxEFIStubEvent(e.Data):
+			stage = BootStageKernel
+			e.Stage = stage
+		default:
+			switch e.Data.(type) {
+			case *SystemdEFIStubEventData, *SystemdUKIEventData:
+				stage = BootStageKernel
+				e.Stage = stage
+				continue
+			}
+
+			switch {
+			case stage >= BootStageBootloader:
+				e.Stage = stage
+			case e.EventType == EventTypeEFIBootServicesApplication:
+				// The first EFI application is loaded by the boot manager, and subsequent loads are on behalf of it.
+				e.Stage = BootStageBootManager
+				stage = BootStageBootloader
+			case e.EventType == EventTypeSeparator && e.PCRIndex <= 7, e.EventType == EventTypeEFIVariableBoot,
+				e.EventType == EventTypeEFIGPTEvent:
+				e.Stage = BootStageBootManager
+				stage = BootStageBootManager
+			case e.EventType == EventTypeEFIAction:
+				if d, ok := e.Data.(*ActionEventData); ok && d.Action == CallingEFIApplication {
+					stage = BootStageBootManager
+				}
+				e.Stage = stage
+			default:
+				e.Stage = stage
+			}
+		}
+	}
+}
+
+// EventsInStages returns the events in this log that were measured during any of the supplied boot stages, in the order in
+// which they were measured.
+func (l *Log) EventsInStages(stages ...BootStage) (out []*Event) {
+	for _, e := range l.Events {
+		for _, s := range stages {
+			if e.Stage == s {
+				out = append(out, e)
+				break
+			}
+		}
+	}
+	return out
+}
@@ -0,0 +1,100 @@
+// Copyright 2019 Canonical Ltd.
+// Licensed under the LGPLv3 with static-linking exception.
+// See LICENCE file for details.
+
+package tcglog
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestClassifyBootStages(t *testing.T) {
+	image := makeTestImageLoadEventData("\\EFI\\ubuntu\\shimx64.efi")
+	event := func(pcr PCRIndex, eventType EventType, data []byte) *Event {
+		return makeTestEvent(pcr, eventType, data, AlgorithmSha256)
+	}
+
+	events := []*Event{
+		event(0, EventTypeSCRTMVersion, []byte{0x31, 0x00}),
+		event(0, EventTypeEFIPlatformFirmwareBlob, make([]byte, 16)),
+		event(7, EventTypeEFIVariableDriverConfig, []byte("SecureBoot")),
+		event(2, EventTypeEFIBootServicesDriver, image),
+		event(1, EventTypeEFIVariableBoot, []byte("BootOrder")),
+		event(4, EventTypeEFIAction, []byte("Calling EFI Application from Boot Option")),
+		event(0, EventTypeSeparator, []byte{0, 0, 0, 0}),
+		event(2, EventTypeSeparator, []byte{0, 0, 0, 0}),
+		event(4, EventTypeEFIBootServicesApplication, image),
+		event(7, EventTypeEFIVariableAuthority, []byte("authority")),
+		event(4, EventTypeEFIBootServicesApplication, image),
+		{PCRIndex: 8, EventType: EventTypeIPL, Data: &GrubStringEventData{Type: KernelCmdline, Str: "/vmlinuz ro"}},
+		{PCRIndex: 9, EventType: EventTypeEventTag, Data: &TaggedEventData{ID: LinuxInitrdEventTagID}},
+		event(4, EventTypeEFIBootServicesApplication, image),
+		event(5, EventTypeEFIAction, []byte("Exit Boot Services Invocation")),
+		event(5, EventTypeEFIAction, []byte("Exit Boot Services Returned with Success")),
+	}
+	log := NewLog(append([]*Event(nil), events...))
+
+	var stages []BootStage
+	for _, e := range log.Events {
+		stages = append(stages, e.Stage)
+	}
+	expected := []BootStage{
+		BootStageSCRTM,
+		BootStagePlatformFirmware,
+		BootStagePlatformFirmware,
+		BootStageOptionROMs,
+		BootStageBootManager,
+		BootStageBootManager,
+		BootStageBootManager,
+		BootStageBootManager,
+		BootStageBootManager,
+		BootStageBootloader,
+		BootStageBootloader,
+		BootStageBootloader,
+		BootStageKernel,
+		BootStageKernel,
+		BootStageOSPresent,
+		BootStageOSPresent,
+	}
+	if !reflect.DeepEqual(stages, expected) {
+		t.Errorf("Unexpected stages: %v", stages)
+	}
+
+	if !reflect.DeepEqual(log.EventsInStages(BootStageKernel, BootStageOSPresent), events[12:]) {
+		t.Errorf("Unexpected events in kernel and OS-present stages")
+	}
+
+	// Removing the first EFI application means that the next one is loaded by the boot manager.
+	if err := log.RemoveEvent(8); err != nil {
+		t.Fatalf("RemoveEvent failed: %v", err)
+	}
+	if events[9].Stage != BootStageBootManager || events[10].Stage != BootStageBootManager {
+		t.Errorf("Unexpected stages after RemoveEvent: %v, %v", events[9].Stage, events[10].Stage)
+	}
+}
+
+func TestParseBootStage(t *testing.T) {
+	for s := BootStageUnknown; s <= BootStageOSPresent; s++ {
+		parsed, err := ParseBootStage(s.String())
+		if err != nil {
+			t.Errorf("ParseBootStage failed: %v", err)
+		}
+		if parsed != s {
+			t.Errorf("Unexpected stage for %s: %v", s, parsed)
+		}
+	}
+	for name, expected := range map[string]BootStage{
+		"boot-manager": BootStageBootManager,
+		"os-present":   BootStageOSPresent,
+		"s-crtm":       BootStageSCRTM,
+		"OptionROMs":   BootStageOptionROMs,
+	} {
+		if s, err := ParseBootStage(name); err != nil || s != expected {
+			t.Errorf("Unexpected result for %s: %v, %v", name, s, err)
+		}
+	}
+	if _, err := ParseBootStage("firmware"); err == nil || err.Error() != "unrecognized boot stage \"firmware\"" {
+		t.Errorf("Unexpected error: %v", err)
+	}
+}
@@ -0,0 +1,111 @@
+// Copyright 2019 Canonical Ltd.
+// Licensed under the LGPLv3 with static-linking exception.
+// See LICENCE file for details.
+
+package tcglog
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+)
+
+// LogBuilder constructs synthetic crypto-agile logs, for use in tests or for simulating attacks. The log starts with a Spec ID
+// event that describes the digest algorithms supplied to NewLogBuilder, and events are appended in the order in which they are
+// added.
+type LogBuilder struct {
+	algorithms AlgorithmIdList
+	options    *LogOptions
+	events     []*Event
+	indices    map[PCRIndex]uint
+}
+
+// NewLogBuilder returns a new LogBuilder for a log containing digests for the supplied algorithms. The supplied options, which
+// may be nil, are used to decode the data of events that are added.
+func NewLogBuilder(algorithms AlgorithmIdList, options *LogOptions) (*LogBuilder, error) {
+	if len(algorithms) == 0 {
+		return nil, errors.New("no digest algorithms")
+	}
+	for _, alg := range algorithms {
+		if !alg.supported() {
+			return nil, fmt.Errorf("unsupported algorithm %v", alg)
+		}
+	}
+
+	b := &LogBuilder{
+		algorithms: make(AlgorithmIdList, len(algorithms)),
+		options:    options,
+		indices:    make(map[PCRIndex]uint)}
+	copy(b.algorithms, algorithms)
+
+	digests := DigestMap{AlgorithmSha1: make(Digest, AlgorithmSha1.Size())}
+	specId := b.appendEvent(0, EventTypeNoAction, digests, encodeSpecIdEvent03(nil, b.algorithms))
+	fixupSpecIdEvent(specId, b.algorithms)
+
+	return b, nil
+}
+
+func (b *LogBuilder) appendEvent(pcr PCRIndex, eventType EventType, digests DigestMap, data []byte) *Event {
+	event := &Event{
+		Index:     b.indices[pcr],
+		PCRIndex:  pcr,
+		EventType: eventType,
+		Digests:   digests,
+		Data:      DecodeEventData(pcr, eventType, digests, data, b.options)}
+	b.indices[pcr]++
+	b.events = append(b.events, event)
+	return event
+}
+
+// AddEvent appends an event with the specified PCR index, type and data, and returns it. The digests are computed in the same
+// way as NewEvent, but the data is decoded with the options supplied to NewLogBuilder.
+func (b *LogBuilder) AddEvent(pcr PCRIndex, eventType EventType, data []byte) (*Event, error) {
+	event, err := newEvent(pcr, eventType, data, b.algorithms, b.options)
+	if err != nil {
+		return nil, err
+	}
+	return b.appendEvent(pcr, eventType, event.Digests, data), nil
+}
+
+// AddEventWithDigests appends an event with the specified PCR index, type, digests and data, and returns it. This is useful for
+// events where the digest is not a hash of the event data (eg, EV_EFI_BOOT_SERVICES_APPLICATION events, where it is the
+// Authenticode digest of the loaded image), or for simulating events where the digest is inconsistent with the data. A digest
+// must be supplied for each of the log's algorithms.
+func (b *LogBuilder) AddEventWithDigests(pcr PCRIndex, eventType EventType, digests DigestMap, data []byte) (*Event, error) {
+	if !isPCRIndexInRange(pcr) {
+		return nil, fmt.Errorf("out-of-range PCR index (%d)", pcr)
+	}
+
+	d := make(DigestMap)
+	for _, alg := range b.algorithms {
+		digest, ok := digests[alg]
+		if !ok {
+			return nil, fmt.Errorf("missing digest for algorithm %v", alg)
+		}
+		if len(digest) != alg.Size() {
+			return nil, fmt.Errorf("invalid digest length for algorithm %v (%d)", alg, len(digest))
+		}
+		d[alg] = digest
+	}
+	return b.appendEvent(pcr, eventType, d, data), nil
+}
+
+// Log returns the log constructed so far. The returned log shares events with this builder.
+func (b *LogBuilder) Log() *Log {
+	log := &Log{
+		Spec:       SpecEFI_2,
+		Algorithms: make(AlgorithmIdList, len(b.algorithms)),
+		Events:     make([]*Event, len(b.events))}
+	copy(log.Algorithms, b.algorithms)
+	copy(log.Events, b.events)
+	return log
+}
+
+// Bytes returns the binary encoding of the log constructed so far, in the crypto-agile format.
+func (b *LogBuilder) Bytes() ([]byte, error) {
+	var w bytes.Buffer
+	if err := WriteLog(&w, b.Log()); err != nil {
+		return nil, err
+	}
+	return w.Bytes(), nil
+}
@@ -0,0 +1,86 @@
+// Copyright 2019 Canonical Ltd.
+// Licensed under the LGPLv3 with static-linking exception.
+// See LICENCE file for details.
+
+package tcglog
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestLogBuilder(t *testing.T) {
+	if _, err := NewLogBuilder(nil, nil); err == nil {
+		t.Errorf("NewLogBuilder should fail with no algorithms")
+	}
+
+	algs := AlgorithmIdList{AlgorithmSha1, AlgorithmSha256}
+	b, err := NewLogBuilder(algs, nil)
+	if err != nil {
+		t.Fatalf("NewLogBuilder failed: %v", err)
+	}
+
+	if _, err := b.AddEvent(0, EventTypeSCRTMVersion, []byte{0x31, 0x00}); err != nil {
+		t.Fatalf("AddEvent failed: %v", err)
+	}
+	if _, err := b.AddEvent(0, EventTypeNoAction, []byte("StartupLocality\x00\x03")); err != nil {
+		t.Fatalf("AddEvent failed: %v", err)
+	}
+	if _, err := b.AddEvent(7, EventTypeSeparator, []byte{0, 0, 0, 0}); err != nil {
+		t.Fatalf("AddEvent failed: %v", err)
+	}
+	if _, err := b.AddEvent(32, EventTypeSeparator, []byte{0, 0, 0, 0}); err == nil {
+		t.Errorf("AddEvent should fail for an out-of-range PCR")
+	}
+
+	digests := DigestMap{AlgorithmSha1: AlgorithmSha1.hash([]byte("foo"))}
+	if _, err := b.AddEventWithDigests(4, EventTypeEFIBootServicesApplication, digests, nil); err == nil {
+		t.Errorf("AddEventWithDigests should fail with a missing digest")
+	}
+	digests[AlgorithmSha256] = AlgorithmSha256.hash([]byte("foo"))
+	if _, err := b.AddEventWithDigests(4, EventTypeEFIAction, digests, []byte("bar")); err != nil {
+		t.Fatalf("AddEventWithDigests failed: %v", err)
+	}
+
+	log := b.Log()
+	if len(log.Events) != 5 {
+		t.Fatalf("Unexpected number of events (%d)", len(log.Events))
+	}
+	for i, index := range []uint{0, 1, 2, 0, 0} {
+		if log.Events[i].Index != index {
+			t.Errorf("Unexpected index for event %d: %d", i, log.Events[i].Index)
+		}
+	}
+	if !bytes.Equal(log.Events[2].Digests[AlgorithmSha256], make(Digest, 32)) {
+		t.Errorf("Unexpected digest for EV_NO_ACTION event")
+	}
+	if !bytes.Equal(log.Events[4].Digests[AlgorithmSha1], AlgorithmSha1.hash([]byte("foo"))) {
+		t.Errorf("Unexpected digest for event 4")
+	}
+
+	data, err := b.Bytes()
+	if err != nil {
+		t.Fatalf("Bytes failed: %v", err)
+	}
+	parsed, err := ParseLog(bytes.NewReader(data), &LogOptions{})
+	if err != nil {
+		t.Fatalf("ParseLog failed: %v", err)
+	}
+	if parsed.Spec != SpecEFI_2 || len(parsed.Events) != len(log.Events) {
+		t.Fatalf("Unexpected parsed log")
+	}
+	for i, e := range parsed.Events {
+		orig := log.Events[i]
+		if e.PCRIndex != orig.PCRIndex || e.EventType != orig.EventType || e.Index != orig.Index {
+			t.Errorf("Unexpected event %d: %d %v %d", i, e.PCRIndex, e.EventType, e.Index)
+		}
+		for _, alg := range algs {
+			if !bytes.Equal(e.Digests[alg], orig.Digests[alg]) {
+				t.Errorf("Unexpected %v digest for event %d", alg, i)
+			}
+		}
+		if !bytes.Equal(e.Data.Bytes(), orig.Data.Bytes()) {
+			t.Errorf("Unexpected data for event %d", i)
+		}
+	}
+}
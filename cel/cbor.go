@@ -0,0 +1,297 @@
+// Copyright 2019 Canonical Ltd.
+// Licensed under the LGPLv3 with static-linking exception.
+// See LICENCE file for details.
+
+package cel
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+
+	"github.com/canonical/tcglog-parser"
+	"github.com/canonical/tcglog-parser/internal/cbor"
+	"golang.org/x/xerrors"
+)
+
+// In CEL-CBOR, each record is a map keyed by the same type values that are used for CEL-TLV. Digests are encoded as a map of
+// algorithm identifiers to digests, and the content is encoded as a map keyed by the content specific field types.
+
+func writeCBORDigests(e *cbor.Encoder, digests tcglog.DigestMap) {
+	algs := sortedAlgorithms(digests)
+	e.WriteMapHeader(len(algs))
+	for _, alg := range algs {
+		e.WriteUint(uint64(alg))
+		e.WriteBytes(digests[alg])
+	}
+}
+
+func writeCBORRecord(e *cbor.Encoder, r *Record) error {
+	e.WriteMapHeader(4)
+
+	e.WriteUint(uint64(FieldRecNum))
+	e.WriteUint(r.RecNum)
+
+	if r.IsNVIndex {
+		e.WriteUint(uint64(FieldNVIndex))
+	} else {
+		e.WriteUint(uint64(FieldPCR))
+	}
+	e.WriteUint(uint64(r.Index))
+
+	e.WriteUint(uint64(FieldDigests))
+	writeCBORDigests(e, r.Digests)
+
+	e.WriteUint(uint64(r.Content.Type()))
+	switch c := r.Content.(type) {
+	case *PCClientStdContent:
+		e.WriteMapHeader(2)
+		e.WriteUint(uint64(pcclientStdEventType))
+		e.WriteUint(uint64(c.EventType))
+		e.WriteUint(uint64(pcclientStdEventData))
+		e.WriteBytes(c.EventData)
+	case *IMATemplateContent:
+		e.WriteMapHeader(2)
+		e.WriteUint(uint64(imaTemplateName))
+		e.WriteString(c.TemplateName)
+		e.WriteUint(uint64(imaTemplateData))
+		e.WriteBytes(c.TemplateData)
+	case *IMATLVContent:
+		n := 2
+		if len(c.DataSignature) > 0 {
+			n++
+		}
+		e.WriteMapHeader(n)
+		e.WriteUint(uint64(imaTLVPath))
+		e.WriteString(c.Path)
+		e.WriteUint(uint64(imaTLVDataHash))
+		writeCBORDigests(e, tcglog.DigestMap{c.DataHashAlg: c.DataHash})
+		if len(c.DataSignature) > 0 {
+			e.WriteUint(uint64(imaTLVDataSig))
+			e.WriteBytes(c.DataSignature)
+		}
+	case *RawContent:
+		e.WriteRaw(c.Data)
+	default:
+		return fmt.Errorf("unsupported content type %v", r.Content.Type())
+	}
+
+	return nil
+}
+
+// WriteCBOR writes the supplied records to w in the CEL-CBOR encoding, as a CBOR array.
+func WriteCBOR(w io.Writer, records []*Record) error {
+	e := cbor.NewEncoder(w)
+	e.WriteArrayHeader(len(records))
+	for _, r := range records {
+		if err := writeCBORRecord(e, r); err != nil {
+			return fmt.Errorf("cannot encode record %d: %v", r.RecNum, err)
+		}
+	}
+	return e.Err()
+}
+
+func cborUint(v interface{}) (uint64, error) {
+	n, ok := v.(uint64)
+	if !ok {
+		return 0, fmt.Errorf("unexpected type %T (expected unsigned integer)", v)
+	}
+	return n, nil
+}
+
+func decodeCBORDigests(v interface{}) (tcglog.DigestMap, error) {
+	m, ok := v.(cbor.Map)
+	if !ok {
+		return nil, fmt.Errorf("unexpected type %T (expected map)", v)
+	}
+
+	out := make(tcglog.DigestMap)
+	for _, e := range m {
+		alg, err := cborUint(e.Key)
+		if err != nil || alg > math.MaxUint16 {
+			return nil, errors.New("invalid algorithm")
+		}
+		digest, ok := e.Value.([]byte)
+		if !ok {
+			return nil, fmt.Errorf("unexpected type %T for digest", e.Value)
+		}
+		if !tcglog.AlgorithmId(alg).GetHash().Available() {
+			return nil, xerrors.Errorf("unsupported algorithm %v", tcglog.AlgorithmId(alg))
+		}
+		if size := tcglog.AlgorithmId(alg).Size(); size != len(digest) {
+			return nil, fmt.Errorf("invalid digest size for algorithm %v", tcglog.AlgorithmId(alg))
+		}
+		out[tcglog.AlgorithmId(alg)] = digest
+	}
+	return out, nil
+}
+
+func decodeCBORContent(t ContentType, v interface{}) (Content, error) {
+	m, ok := v.(cbor.Map)
+	if !ok {
+		return nil, fmt.Errorf("unexpected type %T (expected map)", v)
+	}
+
+	bytesField := func(key uint8) ([]byte, error) {
+		v, exists := m.Get(uint64(key))
+		if !exists {
+			return nil, nil
+		}
+		b, ok := v.([]byte)
+		if !ok {
+			return nil, fmt.Errorf("unexpected type %T for field %d", v, key)
+		}
+		return b, nil
+	}
+	stringField := func(key uint8) (string, error) {
+		v, exists := m.Get(uint64(key))
+		if !exists {
+			return "", nil
+		}
+		s, ok := v.(string)
+		if !ok {
+			return "", fmt.Errorf("unexpected type %T for field %d", v, key)
+		}
+		return s, nil
+	}
+
+	switch t {
+	case ContentTypePCClientStd:
+		c := &PCClientStdContent{}
+		if v, exists := m.Get(uint64(pcclientStdEventType)); exists {
+			eventType, err := cborUint(v)
+			if err != nil || eventType > math.MaxUint32 {
+				return nil, errors.New("invalid event type")
+			}
+			c.EventType = tcglog.EventType(eventType)
+		}
+		var err error
+		if c.EventData, err = bytesField(pcclientStdEventData); err != nil {
+			return nil, err
+		}
+		return c, nil
+	case ContentTypeIMATemplate:
+		c := &IMATemplateContent{}
+		var err error
+		if c.TemplateName, err = stringField(imaTemplateName); err != nil {
+			return nil, err
+		}
+		if c.TemplateData, err = bytesField(imaTemplateData); err != nil {
+			return nil, err
+		}
+		return c, nil
+	case ContentTypeIMATLV:
+		c := &IMATLVContent{}
+		var err error
+		if c.Path, err = stringField(imaTLVPath); err != nil {
+			return nil, err
+		}
+		v, exists := m.Get(uint64(imaTLVDataHash))
+		if !exists {
+			return nil, errors.New("missing datahash")
+		}
+		digests, err := decodeCBORDigests(v)
+		if err != nil || len(digests) != 1 {
+			return nil, errors.New("invalid datahash")
+		}
+		for alg, digest := range digests {
+			c.DataHashAlg = alg
+			c.DataHash = digest
+		}
+		if c.DataSignature, err = bytesField(imaTLVDataSig); err != nil {
+			return nil, err
+		}
+		return c, nil
+	default:
+		var b bytes.Buffer
+		e := cbor.NewEncoder(&b)
+		e.WriteValue(v)
+		if err := e.Err(); err != nil {
+			return nil, err
+		}
+		return &RawContent{ContentType: t, Data: b.Bytes()}, nil
+	}
+}
+
+func decodeCBORRecord(v interface{}) (*Record, error) {
+	m, ok := v.(cbor.Map)
+	if !ok {
+		return nil, fmt.Errorf("unexpected type %T (expected map)", v)
+	}
+
+	rec := &Record{}
+	seen := make(map[uint64]bool)
+
+	for _, e := range m {
+		key, err := cborUint(e.Key)
+		if err != nil || key > math.MaxUint8 {
+			return nil, errors.New("invalid key")
+		}
+		if seen[key] {
+			return nil, fmt.Errorf("duplicate key %d", key)
+		}
+		seen[key] = true
+
+		switch FieldType(key) {
+		case FieldRecNum:
+			if rec.RecNum, err = cborUint(e.Value); err != nil {
+				return nil, xerrors.Errorf("cannot decode recnum: %w", err)
+			}
+		case FieldPCR, FieldNVIndex:
+			if seen[uint64(FieldPCR)] && seen[uint64(FieldNVIndex)] {
+				return nil, errors.New("record must have exactly one of pcr or nv_index")
+			}
+			index, err := cborUint(e.Value)
+			if err != nil || index > math.MaxUint32 {
+				return nil, errors.New("invalid index")
+			}
+			rec.Index = uint32(index)
+			rec.IsNVIndex = FieldType(key) == FieldNVIndex
+		case FieldDigests:
+			if rec.Digests, err = decodeCBORDigests(e.Value); err != nil {
+				return nil, xerrors.Errorf("cannot decode digests: %w", err)
+			}
+		default:
+			if rec.Content != nil {
+				return nil, errors.New("record has more than one content field")
+			}
+			if rec.Content, err = decodeCBORContent(ContentType(key), e.Value); err != nil {
+				return nil, xerrors.Errorf("cannot decode content: %w", err)
+			}
+		}
+	}
+
+	switch {
+	case !seen[uint64(FieldPCR)] && !seen[uint64(FieldNVIndex)]:
+		return nil, errors.New("record must have exactly one of pcr or nv_index")
+	case rec.Digests == nil:
+		return nil, errors.New("record has no digests")
+	case rec.Content == nil:
+		return nil, errors.New("record has no content")
+	}
+
+	return rec, nil
+}
+
+// ReadCBOR reads records in the CEL-CBOR encoding from r. The input must be a CBOR array of records.
+func ReadCBOR(r io.Reader) (out []*Record, err error) {
+	v, err := cbor.Decode(r)
+	if err != nil {
+		return nil, err
+	}
+	records, ok := v.([]interface{})
+	if !ok {
+		return nil, fmt.Errorf("unexpected type %T (expected array)", v)
+	}
+
+	for i, v := range records {
+		rec, err := decodeCBORRecord(v)
+		if err != nil {
+			return out, xerrors.Errorf("cannot decode record %d: %w", i, err)
+		}
+		out = append(out, rec)
+	}
+	return out, nil
+}
@@ -0,0 +1,317 @@
+// Copyright 2019 Canonical Ltd.
+// Licensed under the LGPLv3 with static-linking exception.
+// See LICENCE file for details.
+
+// Package cel implements conversion between the event logs supported by the tcglog package and the TCG Canonical Event Log
+// (CEL) format, in its JSON (CEL-JSON), CBOR (CEL-CBOR) and TLV (CEL-TLV) encodings.
+//
+// See https://trustedcomputinggroup.org/resource/canonical-event-log-format/
+package cel
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"sort"
+
+	"github.com/canonical/tcglog-parser"
+
+	"golang.org/x/xerrors"
+)
+
+// FieldType corresponds to the type of a top level field of a CEL record. The content types are also field types.
+type FieldType uint8
+
+const (
+	FieldRecNum  FieldType = 0 // recnum
+	FieldPCR     FieldType = 1 // pcr
+	FieldNVIndex FieldType = 2 // nv_index
+	FieldDigests FieldType = 3 // digests
+)
+
+// ContentType corresponds to the type of the content of a CEL record.
+type ContentType uint8
+
+const (
+	ContentTypeMgt         ContentType = 4 // cel_mgt
+	ContentTypePCClientStd ContentType = 5 // pcclient_std
+	ContentTypeIMATemplate ContentType = 7 // ima_template
+	ContentTypeIMATLV      ContentType = 8 // ima_tlv
+)
+
+func (t ContentType) String() string {
+	switch t {
+	case ContentTypeMgt:
+		return "cel_mgt"
+	case ContentTypePCClientStd:
+		return "pcclient_std"
+	case ContentTypeIMATemplate:
+		return "ima_template"
+	case ContentTypeIMATLV:
+		return "ima_tlv"
+	default:
+		return fmt.Sprintf("%d", uint8(t))
+	}
+}
+
+// Field types for the pcclient_std content type.
+const (
+	pcclientStdEventType uint8 = 0
+	pcclientStdEventData uint8 = 1
+)
+
+// Field types for the ima_template content type.
+const (
+	imaTemplateName uint8 = 0
+	imaTemplateData uint8 = 1
+)
+
+// Field types for the ima_tlv content type.
+const (
+	imaTLVPath     uint8 = 0
+	imaTLVDataHash uint8 = 1
+	imaTLVDataSig  uint8 = 2
+)
+
+// Content corresponds to the content of a CEL record.
+type Content interface {
+	Type() ContentType
+}
+
+// PCClientStdContent corresponds to the content of a record with the pcclient_std content type, which records an event from a
+// log defined by the TCG PC Client Platform Firmware Profile Specification.
+type PCClientStdContent struct {
+	EventType tcglog.EventType
+	EventData []byte
+}
+
+func (c *PCClientStdContent) Type() ContentType {
+	return ContentTypePCClientStd
+}
+
+// IMATemplateContent corresponds to the content of a record with the ima_template content type, which records an entry from an
+// IMA log in its original template form.
+type IMATemplateContent struct {
+	TemplateName string
+	TemplateData []byte
+}
+
+func (c *IMATemplateContent) Type() ContentType {
+	return ContentTypeIMATemplate
+}
+
+// IMATLVContent corresponds to the content of a record with the ima_tlv content type, which records an entry from an IMA log
+// as individual fields.
+type IMATLVContent struct {
+	Path          string
+	DataHashAlg   tcglog.AlgorithmId
+	DataHash      tcglog.Digest
+	DataSignature []byte
+}
+
+func (c *IMATLVContent) Type() ContentType {
+	return ContentTypeIMATLV
+}
+
+// RawContent corresponds to the content of a record with a content type that this package does not interpret, such as cel_mgt.
+// Data is the encoded content in the encoding that it was read from. Content that was read from CEL-JSON is written back to
+// CEL-JSON unmodified, and other content is written to CEL-JSON as a base64 encoded string.
+type RawContent struct {
+	ContentType ContentType
+	Data        []byte
+
+	isJSON bool // Data is a JSON value read from CEL-JSON
+}
+
+func (c *RawContent) Type() ContentType {
+	return c.ContentType
+}
+
+// Record corresponds to a single record in a CEL.
+type Record struct {
+	RecNum    uint64
+	Index     uint32 // The PCR or NV index that this record was extended to
+	IsNVIndex bool   // Indicates that Index refers to a NV index rather than a PCR
+	Digests   tcglog.DigestMap
+	Content   Content
+}
+
+// FromLog converts the supplied log to a sequence of CEL records with the pcclient_std content type.
+func FromLog(log *tcglog.Log) (out []*Record, err error) {
+	for i, e := range log.Events {
+		digests := make(tcglog.DigestMap)
+		for alg, d := range e.Digests {
+			digests[alg] = d
+		}
+		var data bytes.Buffer
+		if err := e.Data.EncodeTo(&data); err != nil {
+			return nil, xerrors.Errorf("cannot encode data for event %d: %w", i, err)
+		}
+		out = append(out, &Record{
+			RecNum:  uint64(i),
+			Index:   uint32(e.PCRIndex),
+			Digests: digests,
+			Content: &PCClientStdContent{EventType: e.EventType, EventData: data.Bytes()}})
+	}
+	return out, nil
+}
+
+// IMAOptions allows the behaviour of FromIMALog to be controlled.
+type IMAOptions struct {
+	// Algorithms specifies the algorithms for which digests should be recorded. This defaults to SHA-1 if not set. Digests for
+	// algorithms other than SHA-1 are computed using the current kernel behaviour (see tcglog.IMAEvent.ExtendDigest).
+	Algorithms tcglog.AlgorithmIdList
+
+	// UseTLV specifies that records should be created with the ima_tlv content type rather than the ima_template content type.
+	// Entries for which the template data cannot be decoded are always recorded with the ima_template content type.
+	UseTLV bool
+
+	// FirstRecNum specifies the record number of the first record, so that the records can be appended to those created from
+	// a firmware log.
+	FirstRecNum uint64
+}
+
+// FromIMALog converts the supplied IMA log to a sequence of CEL records with the ima_template or ima_tlv content types. The
+// options may be nil.
+func FromIMALog(log *tcglog.IMALog, options *IMAOptions) (out []*Record, err error) {
+	var opts IMAOptions
+	if options != nil {
+		opts = *options
+	}
+	if len(opts.Algorithms) == 0 {
+		opts.Algorithms = tcglog.AlgorithmIdList{tcglog.AlgorithmSha1}
+	}
+
+	for i, e := range log.Events {
+		digests := make(tcglog.DigestMap)
+		for _, alg := range opts.Algorithms {
+			d, err := e.ExtendDigest(alg, false)
+			if err != nil {
+				return nil, fmt.Errorf("cannot compute %v digest for entry %d: %v", alg, i, err)
+			}
+			digests[alg] = d
+		}
+
+		r := &Record{RecNum: opts.FirstRecNum + uint64(i), Index: uint32(e.PCRIndex), Digests: digests}
+
+		d, isTemplateData := e.Data.(*tcglog.IMATemplateData)
+		if opts.UseTLV && isTemplateData && d.FileDigest != nil && d.FileDigest.Algorithm != 0 {
+			r.Content = &IMATLVContent{
+				Path:          d.FileName,
+				DataHashAlg:   d.FileDigest.Algorithm,
+				DataHash:      d.FileDigest.Digest,
+				DataSignature: d.Signature}
+		} else {
+			r.Content = &IMATemplateContent{TemplateName: e.TemplateName, TemplateData: e.Data.Bytes()}
+		}
+
+		out = append(out, r)
+	}
+
+	return out, nil
+}
+
+func algorithmName(alg tcglog.AlgorithmId) string {
+	switch alg {
+	case tcglog.AlgorithmSha1:
+		return "sha1"
+	case tcglog.AlgorithmSha256:
+		return "sha256"
+	case tcglog.AlgorithmSha384:
+		return "sha384"
+	case tcglog.AlgorithmSha512:
+		return "sha512"
+	default:
+		return fmt.Sprintf("0x%04x", uint16(alg))
+	}
+}
+
+// sortedAlgorithms returns the algorithms in the supplied digest map in a stable order.
+func sortedAlgorithms(digests tcglog.DigestMap) (out tcglog.AlgorithmIdList) {
+	for alg := range digests {
+		out = append(out, alg)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i] < out[j] })
+	return out
+}
+
+// ToLog creates a tcglog.Log from the records with the pcclient_std content type, decoding the event data using the supplied
+// options. The options may be nil. Records with other content types are ignored.
+func ToLog(records []*Record, options *tcglog.LogOptions) (*tcglog.Log, error) {
+	var events []*tcglog.Event
+	for _, r := range records {
+		c, ok := r.Content.(*PCClientStdContent)
+		if !ok {
+			continue
+		}
+		if r.IsNVIndex || r.Index > 31 {
+			return nil, fmt.Errorf("record %d has an invalid PCR index", r.RecNum)
+		}
+
+		events = append(events, &tcglog.Event{
+			PCRIndex:  tcglog.PCRIndex(r.Index),
+			EventType: c.EventType,
+			Digests:   r.Digests,
+			Data:      tcglog.DecodeEventData(tcglog.PCRIndex(r.Index), c.EventType, r.Digests, c.EventData, options)})
+	}
+
+	return tcglog.NewLog(events), nil
+}
+
+// makeIMATemplateData creates ima-ng or ima-sig template data from the supplied ima_tlv content.
+func makeIMATemplateData(c *IMATLVContent) (name string, data []byte) {
+	var buf bytes.Buffer
+	appendField := func(data []byte) {
+		binary.Write(&buf, binary.LittleEndian, uint32(len(data)))
+		buf.Write(data)
+	}
+
+	appendField(append([]byte(algorithmName(c.DataHashAlg)+":\x00"), c.DataHash...))
+	appendField(append([]byte(c.Path), 0))
+
+	name = tcglog.IMANGTemplate
+	if len(c.DataSignature) > 0 {
+		name = tcglog.IMASigTemplate
+		appendField(c.DataSignature)
+	}
+
+	return name, buf.Bytes()
+}
+
+// ToIMALog creates a tcglog.IMALog from the records with the ima_template or ima_tlv content types, decoding the template data
+// using the supplied options. The options may be nil. Records with ima_tlv content are converted to entries with the ima-ng or
+// ima-sig templates. Each record must have a SHA-1 digest, which is used as the template digest. Records with other content
+// types are ignored.
+func ToIMALog(records []*Record, options *tcglog.IMALogOptions) (*tcglog.IMALog, error) {
+	var events []*tcglog.IMAEvent
+	for _, r := range records {
+		var name string
+		var data []byte
+
+		switch c := r.Content.(type) {
+		case *IMATemplateContent:
+			name = c.TemplateName
+			data = c.TemplateData
+		case *IMATLVContent:
+			name, data = makeIMATemplateData(c)
+		default:
+			continue
+		}
+
+		if r.IsNVIndex || r.Index > 31 {
+			return nil, fmt.Errorf("record %d has an invalid PCR index", r.RecNum)
+		}
+		digest, ok := r.Digests[tcglog.AlgorithmSha1]
+		if !ok {
+			return nil, fmt.Errorf("record %d has no SHA-1 digest", r.RecNum)
+		}
+
+		events = append(events, &tcglog.IMAEvent{
+			PCRIndex:       tcglog.PCRIndex(r.Index),
+			TemplateDigest: digest,
+			TemplateName:   name,
+			Data:           tcglog.DecodeIMATemplateData(name, data, options)})
+	}
+
+	return tcglog.NewIMALog(events), nil
+}
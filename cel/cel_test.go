@@ -0,0 +1,237 @@
+// Copyright 2019 Canonical Ltd.
+// Licensed under the LGPLv3 with static-linking exception.
+// See LICENCE file for details.
+
+package cel
+
+import (
+	"bytes"
+	"crypto/sha1"
+	"crypto/sha256"
+	"encoding/json"
+	"io"
+	"reflect"
+	"testing"
+
+	"github.com/canonical/tcglog-parser"
+)
+
+func makeTestRecords(t *testing.T) []*Record {
+	action := []byte("Calling EFI Application from Boot Option")
+	separator := []byte{0, 0, 0, 0}
+	sha1Action := sha1.Sum(action)
+	sha256Action := sha256.Sum256(action)
+	sha1Separator := sha1.Sum(separator)
+	sha256Separator := sha256.Sum256(separator)
+	fileDigest := sha256.Sum256([]byte("foo"))
+
+	log := tcglog.NewLog([]*tcglog.Event{
+		{
+			PCRIndex:  4,
+			EventType: tcglog.EventTypeEFIAction,
+			Digests:   tcglog.DigestMap{tcglog.AlgorithmSha1: sha1Action[:], tcglog.AlgorithmSha256: sha256Action[:]},
+			Data:      tcglog.DecodeEventData(4, tcglog.EventTypeEFIAction, nil, action, nil)},
+		{
+			PCRIndex:  7,
+			EventType: tcglog.EventTypeSeparator,
+			Digests:   tcglog.DigestMap{tcglog.AlgorithmSha1: sha1Separator[:], tcglog.AlgorithmSha256: sha256Separator[:]},
+			Data:      tcglog.DecodeEventData(7, tcglog.EventTypeSeparator, nil, separator, nil)}})
+
+	records, err := FromLog(log)
+	if err != nil {
+		t.Fatalf("FromLog failed: %v", err)
+	}
+	records = append(records,
+		&Record{
+			RecNum:  2,
+			Index:   10,
+			Digests: tcglog.DigestMap{tcglog.AlgorithmSha1: make(tcglog.Digest, 20)},
+			Content: &IMATemplateContent{TemplateName: "ima-ng", TemplateData: []byte{1, 2, 3}}},
+		&Record{
+			RecNum:  3,
+			Index:   10,
+			Digests: tcglog.DigestMap{tcglog.AlgorithmSha256: make(tcglog.Digest, 32)},
+			Content: &IMATLVContent{
+				Path:          "/usr/bin/foo",
+				DataHashAlg:   tcglog.AlgorithmSha256,
+				DataHash:      fileDigest[:],
+				DataSignature: []byte{1, 2, 3, 4}}},
+		&Record{
+			RecNum:    4,
+			Index:     0x01c10100,
+			IsNVIndex: true,
+			Digests:   tcglog.DigestMap{tcglog.AlgorithmSha256: make(tcglog.Digest, 32)},
+			Content:   &PCClientStdContent{EventType: tcglog.EventTypeAction, EventData: []byte("foo")}})
+	return records
+}
+
+func TestRoundTrip(t *testing.T) {
+	for _, data := range []struct {
+		desc  string
+		write func(io.Writer, []*Record) error
+		read  func(io.Reader) ([]*Record, error)
+	}{
+		{desc: "TLV", write: WriteTLV, read: ReadTLV},
+		{desc: "JSON", write: WriteJSON, read: ReadJSON},
+		{desc: "CBOR", write: WriteCBOR, read: ReadCBOR},
+	} {
+		t.Run(data.desc, func(t *testing.T) {
+			records := makeTestRecords(t)
+
+			var buf bytes.Buffer
+			if err := data.write(&buf, records); err != nil {
+				t.Fatalf("Write failed: %v", err)
+			}
+
+			out, err := data.read(&buf)
+			if err != nil {
+				t.Fatalf("Read failed: %v", err)
+			}
+
+			if !reflect.DeepEqual(records, out) {
+				t.Errorf("Unexpected records")
+				for i := range out {
+					t.Logf("%#v vs %#v", records[i], out[i])
+				}
+			}
+		})
+	}
+}
+
+func TestToLog(t *testing.T) {
+	log, err := ToLog(makeTestRecords(t)[:2], nil)
+	if err != nil {
+		t.Fatalf("ToLog failed: %v", err)
+	}
+
+	if len(log.Events) != 2 {
+		t.Fatalf("Unexpected number of events")
+	}
+	if !reflect.DeepEqual(log.Algorithms, tcglog.AlgorithmIdList{tcglog.AlgorithmSha1, tcglog.AlgorithmSha256}) {
+		t.Errorf("Unexpected algorithms: %v", log.Algorithms)
+	}
+	if log.Events[0].Data.String() != "Calling EFI Application from Boot Option" {
+		t.Errorf("Unexpected event data for event 0: %s", log.Events[0].Data)
+	}
+	if _, ok := log.Events[1].Data.(*tcglog.SeparatorEventData); !ok {
+		t.Errorf("Unexpected event data type for event 1")
+	}
+}
+
+func TestToIMALog(t *testing.T) {
+	fileDigest := sha256.Sum256([]byte("foo"))
+	records := []*Record{
+		{
+			Index:   10,
+			Digests: tcglog.DigestMap{tcglog.AlgorithmSha1: make(tcglog.Digest, 20)},
+			Content: &IMATLVContent{Path: "/usr/bin/foo", DataHashAlg: tcglog.AlgorithmSha256, DataHash: fileDigest[:]}}}
+
+	log, err := ToIMALog(records, nil)
+	if err != nil {
+		t.Fatalf("ToIMALog failed: %v", err)
+	}
+	if len(log.Events) != 1 {
+		t.Fatalf("Unexpected number of events")
+	}
+	if log.Events[0].TemplateName != tcglog.IMANGTemplate {
+		t.Errorf("Unexpected template name %s", log.Events[0].TemplateName)
+	}
+	d, ok := log.Events[0].Data.(*tcglog.IMATemplateData)
+	if !ok {
+		t.Fatalf("Unexpected template data: %v", log.Events[0].Data)
+	}
+	if d.FileName != "/usr/bin/foo" || !bytes.Equal(d.FileDigest.Digest, fileDigest[:]) {
+		t.Errorf("Unexpected template data: %v", d)
+	}
+}
+
+func TestReadUnsupportedAlgorithm(t *testing.T) {
+	for _, data := range []struct {
+		desc  string
+		write func(io.Writer, []*Record) error
+		read  func(io.Reader) ([]*Record, error)
+		err   string
+	}{
+		{desc: "TLV", write: WriteTLV, read: ReadTLV, err: "cannot read record 0: cannot decode digests: unsupported algorithm 0012"},
+		{desc: "JSON", write: WriteJSON, read: ReadJSON, err: "cannot decode record 0: cannot decode digests: unsupported algorithm 0012"},
+		{desc: "CBOR", write: WriteCBOR, read: ReadCBOR, err: "cannot decode record 0: cannot decode digests: unsupported algorithm 0012"},
+	} {
+		t.Run(data.desc, func(t *testing.T) {
+			records := []*Record{{
+				Digests: tcglog.DigestMap{tcglog.AlgorithmId(0x0012): []byte{0}},
+				Content: &PCClientStdContent{EventType: tcglog.EventTypeAction, EventData: []byte("foo")}}}
+
+			var buf bytes.Buffer
+			if err := data.write(&buf, records); err != nil {
+				t.Fatalf("Write failed: %v", err)
+			}
+
+			if _, err := data.read(&buf); err == nil || err.Error() != data.err {
+				t.Errorf("Unexpected error: %v", err)
+			}
+		})
+	}
+}
+
+func TestRawContentTLVToJSON(t *testing.T) {
+	var mgt bytes.Buffer
+	appendTLV(&mgt, 0, []byte{1, 2, 3})
+	var record bytes.Buffer
+	if err := WriteTLV(&record, []*Record{{
+		Digests: tcglog.DigestMap{tcglog.AlgorithmSha256: make(tcglog.Digest, 32)},
+		Content: &RawContent{ContentType: ContentTypeMgt, Data: mgt.Bytes()}}}); err != nil {
+		t.Fatalf("WriteTLV failed: %v", err)
+	}
+
+	records, err := ReadTLV(bytes.NewReader(record.Bytes()))
+	if err != nil {
+		t.Fatalf("ReadTLV failed: %v", err)
+	}
+
+	var buf bytes.Buffer
+	if err := WriteJSON(&buf, records); err != nil {
+		t.Fatalf("WriteJSON failed: %v", err)
+	}
+	if !json.Valid(buf.Bytes()) {
+		t.Fatalf("Invalid JSON: %s", buf.String())
+	}
+	out, err := ReadJSON(&buf)
+	if err != nil {
+		t.Fatalf("ReadJSON failed: %v", err)
+	}
+	if !reflect.DeepEqual(records, out) {
+		t.Errorf("Unexpected records: %#v", out[0].Content)
+	}
+
+	buf.Reset()
+	if err := WriteTLV(&buf, out); err != nil {
+		t.Fatalf("WriteTLV failed: %v", err)
+	}
+	if !bytes.Equal(buf.Bytes(), record.Bytes()) {
+		t.Errorf("Unexpected TLV encoding: %x", buf.Bytes())
+	}
+}
+
+func TestRawContentJSON(t *testing.T) {
+	in := `[{"recnum":0,"pcr":0,"digests":[],"content_type":"cel_mgt","content":{"cel_version":{"major":0,"minor":1}}}]`
+	records, err := ReadJSON(bytes.NewReader([]byte(in)))
+	if err != nil {
+		t.Fatalf("ReadJSON failed: %v", err)
+	}
+
+	var buf bytes.Buffer
+	if err := WriteJSON(&buf, records); err != nil {
+		t.Fatalf("WriteJSON failed: %v", err)
+	}
+	var out []struct{ Content json.RawMessage }
+	if err := json.Unmarshal(buf.Bytes(), &out); err != nil {
+		t.Fatalf("Unmarshal failed: %v", err)
+	}
+	var content bytes.Buffer
+	if err := json.Compact(&content, out[0].Content); err != nil {
+		t.Fatalf("Compact failed: %v", err)
+	}
+	if content.String() != `{"cel_version":{"major":0,"minor":1}}` {
+		t.Errorf("Unexpected content: %s", content.String())
+	}
+}
@@ -0,0 +1,228 @@
+// Copyright 2019 Canonical Ltd.
+// Licensed under the LGPLv3 with static-linking exception.
+// See LICENCE file for details.
+
+package cel
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/canonical/tcglog-parser"
+	"golang.org/x/xerrors"
+)
+
+type jsonDigest struct {
+	HashAlg string `json:"hashAlg"`
+	Digest  string `json:"digest"`
+}
+
+func makeJSONDigest(alg tcglog.AlgorithmId, digest tcglog.Digest) *jsonDigest {
+	return &jsonDigest{HashAlg: algorithmName(alg), Digest: hex.EncodeToString(digest)}
+}
+
+type jsonPCClientStdContent struct {
+	EventType uint32 `json:"event_type"`
+	EventData []byte `json:"event_data"`
+}
+
+type jsonIMATemplateContent struct {
+	TemplateName string `json:"template_name"`
+	TemplateData []byte `json:"template_data"`
+}
+
+type jsonIMATLVContent struct {
+	Path     string      `json:"path"`
+	DataHash *jsonDigest `json:"datahash"`
+	DataSig  []byte      `json:"datasig,omitempty"`
+}
+
+type jsonRecord struct {
+	RecNum      uint64          `json:"recnum"`
+	PCR         *uint32         `json:"pcr,omitempty"`
+	NVIndex     *uint32         `json:"nv_index,omitempty"`
+	Digests     []*jsonDigest   `json:"digests"`
+	ContentType string          `json:"content_type"`
+	Content     json.RawMessage `json:"content"`
+}
+
+func makeJSONRecord(r *Record) (*jsonRecord, error) {
+	out := &jsonRecord{RecNum: r.RecNum, ContentType: r.Content.Type().String()}
+
+	index := r.Index
+	if r.IsNVIndex {
+		out.NVIndex = &index
+	} else {
+		out.PCR = &index
+	}
+
+	for _, alg := range sortedAlgorithms(r.Digests) {
+		out.Digests = append(out.Digests, makeJSONDigest(alg, r.Digests[alg]))
+	}
+
+	var content interface{}
+	switch c := r.Content.(type) {
+	case *PCClientStdContent:
+		content = &jsonPCClientStdContent{EventType: uint32(c.EventType), EventData: c.EventData}
+	case *IMATemplateContent:
+		content = &jsonIMATemplateContent{TemplateName: c.TemplateName, TemplateData: c.TemplateData}
+	case *IMATLVContent:
+		content = &jsonIMATLVContent{Path: c.Path, DataHash: makeJSONDigest(c.DataHashAlg, c.DataHash), DataSig: c.DataSignature}
+	case *RawContent:
+		if c.ContentType != ContentTypeMgt {
+			return nil, fmt.Errorf("unsupported content type %v", c.ContentType)
+		}
+		if c.isJSON {
+			content = json.RawMessage(c.Data)
+		} else {
+			content = c.Data
+		}
+	default:
+		return nil, fmt.Errorf("unsupported content type %v", r.Content.Type())
+	}
+
+	b, err := json.Marshal(content)
+	if err != nil {
+		return nil, err
+	}
+	out.Content = b
+
+	return out, nil
+}
+
+// WriteJSON writes the supplied records to w in the CEL-JSON encoding, as a JSON array.
+func WriteJSON(w io.Writer, records []*Record) error {
+	var out []*jsonRecord
+	for _, r := range records {
+		jr, err := makeJSONRecord(r)
+		if err != nil {
+			return fmt.Errorf("cannot encode record %d: %v", r.RecNum, err)
+		}
+		out = append(out, jr)
+	}
+
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(out)
+}
+
+func parseAlgorithmName(name string) (tcglog.AlgorithmId, error) {
+	switch name {
+	case "sha1":
+		return tcglog.AlgorithmSha1, nil
+	case "sha256":
+		return tcglog.AlgorithmSha256, nil
+	case "sha384":
+		return tcglog.AlgorithmSha384, nil
+	case "sha512":
+		return tcglog.AlgorithmSha512, nil
+	default:
+		var alg uint16
+		if _, err := fmt.Sscanf(name, "0x%04x", &alg); err != nil {
+			return 0, fmt.Errorf("unrecognized algorithm %q", name)
+		}
+		return tcglog.AlgorithmId(alg), nil
+	}
+}
+
+func (d *jsonDigest) decode() (tcglog.AlgorithmId, tcglog.Digest, error) {
+	alg, err := parseAlgorithmName(d.HashAlg)
+	if err != nil {
+		return 0, nil, err
+	}
+	digest, err := hex.DecodeString(d.Digest)
+	if err != nil {
+		return 0, nil, xerrors.Errorf("cannot decode digest: %w", err)
+	}
+	if !alg.GetHash().Available() {
+		return 0, nil, xerrors.Errorf("unsupported algorithm %v", alg)
+	}
+	if size := alg.Size(); size != len(digest) {
+		return 0, nil, fmt.Errorf("invalid digest size for algorithm %v", alg)
+	}
+	return alg, digest, nil
+}
+
+func (r *jsonRecord) decode() (*Record, error) {
+	rec := &Record{RecNum: r.RecNum, Digests: make(tcglog.DigestMap)}
+
+	switch {
+	case r.PCR != nil && r.NVIndex == nil:
+		rec.Index = *r.PCR
+	case r.NVIndex != nil && r.PCR == nil:
+		rec.Index = *r.NVIndex
+		rec.IsNVIndex = true
+	default:
+		return nil, errors.New("record must have exactly one of pcr or nv_index")
+	}
+
+	for _, d := range r.Digests {
+		alg, digest, err := d.decode()
+		if err != nil {
+			return nil, xerrors.Errorf("cannot decode digests: %w", err)
+		}
+		rec.Digests[alg] = digest
+	}
+
+	switch r.ContentType {
+	case ContentTypePCClientStd.String():
+		var c jsonPCClientStdContent
+		if err := json.Unmarshal(r.Content, &c); err != nil {
+			return nil, xerrors.Errorf("cannot decode content: %w", err)
+		}
+		rec.Content = &PCClientStdContent{EventType: tcglog.EventType(c.EventType), EventData: c.EventData}
+	case ContentTypeIMATemplate.String():
+		var c jsonIMATemplateContent
+		if err := json.Unmarshal(r.Content, &c); err != nil {
+			return nil, xerrors.Errorf("cannot decode content: %w", err)
+		}
+		rec.Content = &IMATemplateContent{TemplateName: c.TemplateName, TemplateData: c.TemplateData}
+	case ContentTypeIMATLV.String():
+		var c jsonIMATLVContent
+		if err := json.Unmarshal(r.Content, &c); err != nil {
+			return nil, xerrors.Errorf("cannot decode content: %w", err)
+		}
+		if c.DataHash == nil {
+			return nil, errors.New("cannot decode content: missing datahash")
+		}
+		alg, digest, err := c.DataHash.decode()
+		if err != nil {
+			return nil, xerrors.Errorf("cannot decode content: %w", err)
+		}
+		rec.Content = &IMATLVContent{Path: c.Path, DataHashAlg: alg, DataHash: digest, DataSignature: c.DataSig}
+	case ContentTypeMgt.String():
+		if len(r.Content) == 0 || r.Content[0] != '"' {
+			rec.Content = &RawContent{ContentType: ContentTypeMgt, Data: r.Content, isJSON: true}
+			break
+		}
+		var data []byte
+		if err := json.Unmarshal(r.Content, &data); err != nil {
+			return nil, xerrors.Errorf("cannot decode content: %w", err)
+		}
+		rec.Content = &RawContent{ContentType: ContentTypeMgt, Data: data}
+	default:
+		return nil, fmt.Errorf("unsupported content type %q", r.ContentType)
+	}
+
+	return rec, nil
+}
+
+// ReadJSON reads records in the CEL-JSON encoding from r. The input must be a JSON array of records.
+func ReadJSON(r io.Reader) (out []*Record, err error) {
+	var records []*jsonRecord
+	if err := json.NewDecoder(r).Decode(&records); err != nil {
+		return nil, err
+	}
+
+	for i, jr := range records {
+		rec, err := jr.decode()
+		if err != nil {
+			return out, xerrors.Errorf("cannot decode record %d: %w", i, err)
+		}
+		out = append(out, rec)
+	}
+	return out, nil
+}
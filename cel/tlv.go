@@ -0,0 +1,322 @@
+// Copyright 2019 Canonical Ltd.
+// Licensed under the LGPLv3 with static-linking exception.
+// See LICENCE file for details.
+
+package cel
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+
+	"github.com/canonical/tcglog-parser"
+	"golang.org/x/xerrors"
+)
+
+// appendTLV appends a TLV with the specified type and value to buf. In CEL-TLV, the type is a single byte and the length is a
+// 32-bit big-endian integer.
+func appendTLV(buf *bytes.Buffer, t uint8, value []byte) {
+	buf.WriteByte(t)
+	binary.Write(buf, binary.BigEndian, uint32(len(value)))
+	buf.Write(value)
+}
+
+func encodeTLVDigest(alg tcglog.AlgorithmId, digest tcglog.Digest) ([]byte, error) {
+	if alg > math.MaxUint8 {
+		return nil, fmt.Errorf("cannot encode algorithm %v", alg)
+	}
+	var buf bytes.Buffer
+	appendTLV(&buf, uint8(alg), digest)
+	return buf.Bytes(), nil
+}
+
+func encodeTLVContent(c Content) ([]byte, error) {
+	var buf bytes.Buffer
+
+	switch c := c.(type) {
+	case *PCClientStdContent:
+		var eventType [4]byte
+		binary.BigEndian.PutUint32(eventType[:], uint32(c.EventType))
+		appendTLV(&buf, pcclientStdEventType, eventType[:])
+		appendTLV(&buf, pcclientStdEventData, c.EventData)
+	case *IMATemplateContent:
+		appendTLV(&buf, imaTemplateName, []byte(c.TemplateName))
+		appendTLV(&buf, imaTemplateData, c.TemplateData)
+	case *IMATLVContent:
+		appendTLV(&buf, imaTLVPath, []byte(c.Path))
+		hash, err := encodeTLVDigest(c.DataHashAlg, c.DataHash)
+		if err != nil {
+			return nil, err
+		}
+		appendTLV(&buf, imaTLVDataHash, hash)
+		if len(c.DataSignature) > 0 {
+			appendTLV(&buf, imaTLVDataSig, c.DataSignature)
+		}
+	case *RawContent:
+		buf.Write(c.Data)
+	default:
+		return nil, fmt.Errorf("unsupported content type %v", c.Type())
+	}
+
+	return buf.Bytes(), nil
+}
+
+func encodeTLVRecord(r *Record) ([]byte, error) {
+	var buf bytes.Buffer
+
+	var recnum [8]byte
+	binary.BigEndian.PutUint64(recnum[:], r.RecNum)
+	appendTLV(&buf, uint8(FieldRecNum), recnum[:])
+
+	if r.IsNVIndex {
+		var index [4]byte
+		binary.BigEndian.PutUint32(index[:], r.Index)
+		appendTLV(&buf, uint8(FieldNVIndex), index[:])
+	} else {
+		if r.Index > math.MaxUint8 {
+			return nil, fmt.Errorf("invalid PCR index %d", r.Index)
+		}
+		appendTLV(&buf, uint8(FieldPCR), []byte{uint8(r.Index)})
+	}
+
+	var digests bytes.Buffer
+	for _, alg := range sortedAlgorithms(r.Digests) {
+		d, err := encodeTLVDigest(alg, r.Digests[alg])
+		if err != nil {
+			return nil, err
+		}
+		digests.Write(d)
+	}
+	appendTLV(&buf, uint8(FieldDigests), digests.Bytes())
+
+	content, err := encodeTLVContent(r.Content)
+	if err != nil {
+		return nil, err
+	}
+	appendTLV(&buf, uint8(r.Content.Type()), content)
+
+	return buf.Bytes(), nil
+}
+
+// WriteTLV writes the supplied records to w in the CEL-TLV encoding.
+func WriteTLV(w io.Writer, records []*Record) error {
+	for _, r := range records {
+		b, err := encodeTLVRecord(r)
+		if err != nil {
+			return fmt.Errorf("cannot encode record %d: %v", r.RecNum, err)
+		}
+		if _, err := w.Write(b); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+type tlv struct {
+	t uint8
+	v []byte
+}
+
+// readTLV reads a single TLV from r. It returns io.EOF if there is no more data.
+func readTLV(r io.Reader) (*tlv, error) {
+	var hdr [5]byte
+	if _, err := io.ReadFull(r, hdr[:1]); err != nil {
+		return nil, err
+	}
+	if _, err := io.ReadFull(r, hdr[1:]); err != nil {
+		return nil, xerrors.Errorf("cannot read length: %w", ioErrUnexpectedEOF(err))
+	}
+	n := binary.BigEndian.Uint32(hdr[1:])
+
+	var v bytes.Buffer
+	if _, err := io.CopyN(&v, r, int64(n)); err != nil {
+		return nil, xerrors.Errorf("cannot read value: %w", ioErrUnexpectedEOF(err))
+	}
+
+	return &tlv{t: hdr[0], v: v.Bytes()}, nil
+}
+
+func ioErrUnexpectedEOF(err error) error {
+	if err == io.EOF {
+		return io.ErrUnexpectedEOF
+	}
+	return err
+}
+
+func decodeTLVs(data []byte) (out []*tlv, err error) {
+	r := bytes.NewReader(data)
+	for r.Len() > 0 {
+		t, err := readTLV(r)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, t)
+	}
+	return out, nil
+}
+
+// decodeTLVUint decodes a big-endian unsigned integer of up to 8 bytes.
+func decodeTLVUint(b []byte) (uint64, error) {
+	if len(b) == 0 || len(b) > 8 {
+		return 0, fmt.Errorf("invalid integer length (%d)", len(b))
+	}
+	var out uint64
+	for _, x := range b {
+		out = out<<8 | uint64(x)
+	}
+	return out, nil
+}
+
+func decodeTLVDigest(t *tlv) (tcglog.AlgorithmId, tcglog.Digest, error) {
+	alg := tcglog.AlgorithmId(t.t)
+	if !alg.GetHash().Available() {
+		return 0, nil, xerrors.Errorf("unsupported algorithm %v", alg)
+	}
+	if size := alg.Size(); size != len(t.v) {
+		return 0, nil, fmt.Errorf("invalid digest size for algorithm %v", alg)
+	}
+	return alg, t.v, nil
+}
+
+func decodeTLVContent(t *tlv) (Content, error) {
+	if ContentType(t.t) != ContentTypePCClientStd && ContentType(t.t) != ContentTypeIMATemplate &&
+		ContentType(t.t) != ContentTypeIMATLV {
+		return &RawContent{ContentType: ContentType(t.t), Data: t.v}, nil
+	}
+
+	fields, err := decodeTLVs(t.v)
+	if err != nil {
+		return nil, xerrors.Errorf("cannot decode fields: %w", err)
+	}
+
+	switch ContentType(t.t) {
+	case ContentTypePCClientStd:
+		c := &PCClientStdContent{}
+		for _, f := range fields {
+			switch f.t {
+			case pcclientStdEventType:
+				eventType, err := decodeTLVUint(f.v)
+				if err != nil || eventType > math.MaxUint32 {
+					return nil, fmt.Errorf("invalid event type")
+				}
+				c.EventType = tcglog.EventType(eventType)
+			case pcclientStdEventData:
+				c.EventData = f.v
+			}
+		}
+		return c, nil
+	case ContentTypeIMATemplate:
+		c := &IMATemplateContent{}
+		for _, f := range fields {
+			switch f.t {
+			case imaTemplateName:
+				c.TemplateName = string(f.v)
+			case imaTemplateData:
+				c.TemplateData = f.v
+			}
+		}
+		return c, nil
+	default:
+		c := &IMATLVContent{}
+		for _, f := range fields {
+			switch f.t {
+			case imaTLVPath:
+				c.Path = string(f.v)
+			case imaTLVDataHash:
+				digests, err := decodeTLVs(f.v)
+				if err != nil || len(digests) != 1 {
+					return nil, errors.New("invalid datahash field")
+				}
+				c.DataHashAlg, c.DataHash, err = decodeTLVDigest(digests[0])
+				if err != nil {
+					return nil, xerrors.Errorf("invalid datahash field: %w", err)
+				}
+			case imaTLVDataSig:
+				c.DataSignature = f.v
+			}
+		}
+		return c, nil
+	}
+}
+
+func readTLVRecord(r io.Reader) (*Record, error) {
+	rec := &Record{Digests: make(tcglog.DigestMap)}
+
+	t, err := readTLV(r)
+	switch {
+	case err == io.EOF:
+		return nil, io.EOF
+	case err != nil:
+		return nil, xerrors.Errorf("cannot read recnum: %w", err)
+	case FieldType(t.t) != FieldRecNum:
+		return nil, fmt.Errorf("unexpected type %d for recnum", t.t)
+	}
+	if rec.RecNum, err = decodeTLVUint(t.v); err != nil {
+		return nil, xerrors.Errorf("cannot decode recnum: %w", err)
+	}
+
+	t, err = readTLV(r)
+	if err != nil {
+		return nil, xerrors.Errorf("cannot read index: %w", ioErrUnexpectedEOF(err))
+	}
+	switch FieldType(t.t) {
+	case FieldPCR:
+	case FieldNVIndex:
+		rec.IsNVIndex = true
+	default:
+		return nil, fmt.Errorf("unexpected type %d for index", t.t)
+	}
+	index, err := decodeTLVUint(t.v)
+	if err != nil || index > math.MaxUint32 {
+		return nil, errors.New("invalid index")
+	}
+	rec.Index = uint32(index)
+
+	t, err = readTLV(r)
+	switch {
+	case err != nil:
+		return nil, xerrors.Errorf("cannot read digests: %w", ioErrUnexpectedEOF(err))
+	case FieldType(t.t) != FieldDigests:
+		return nil, fmt.Errorf("unexpected type %d for digests", t.t)
+	}
+	digests, err := decodeTLVs(t.v)
+	if err != nil {
+		return nil, xerrors.Errorf("cannot decode digests: %w", err)
+	}
+	for _, d := range digests {
+		alg, digest, err := decodeTLVDigest(d)
+		if err != nil {
+			return nil, xerrors.Errorf("cannot decode digests: %w", err)
+		}
+		rec.Digests[alg] = digest
+	}
+
+	t, err = readTLV(r)
+	if err != nil {
+		return nil, xerrors.Errorf("cannot read content: %w", ioErrUnexpectedEOF(err))
+	}
+	if rec.Content, err = decodeTLVContent(t); err != nil {
+		return nil, xerrors.Errorf("cannot decode content: %w", err)
+	}
+
+	return rec, nil
+}
+
+// ReadTLV reads a sequence of records in the CEL-TLV encoding from r. If an error occurs, this may return an incomplete list
+// of records with the error.
+func ReadTLV(r io.Reader) (out []*Record, err error) {
+	for {
+		rec, err := readTLVRecord(r)
+		switch {
+		case err == io.EOF:
+			return out, nil
+		case err != nil:
+			return out, xerrors.Errorf("cannot read record %d: %w", len(out), err)
+		default:
+			out = append(out, rec)
+		}
+	}
+}
@@ -24,6 +24,7 @@ const (
 	EventTypeNonhostConfig        EventType = 0x00000010 // EV_NONHOST_CONFIG
 	EventTypeNonhostInfo          EventType = 0x00000011 // EV_NONHOST_INFO
 	EventTypeOmitBootDeviceEvents EventType = 0x00000012 // EV_OMIT_BOOT_DEVICE_EVENTS
+	EventTypePostCode2            EventType = 0x00000013 // EV_POST_CODE2
 
 	EventTypeEFIEventBase               EventType = 0x80000000 // EV_EFI_EVENT_BASE
 	EventTypeEFIVariableDriverConfig    EventType = 0x80000001 // EV_EFI_VARIABLE_DRIVER_CONFIG
@@ -34,9 +35,49 @@ const (
 	EventTypeEFIGPTEvent                EventType = 0x80000006 // EV_EFI_GPT_EVENT
 	EventTypeEFIAction                  EventType = 0x80000007 // EV_EFI_ACTION
 	EventTypeEFIPlatformFirmwareBlob    EventType = 0x80000008 // EV_EFI_PLATFORM_FIRMWARE_BLOB
-	EventTypeEFIHandoffTables           EventType = 0x80000009 // EF_EFI_HANDOFF_TABLES
-	EventTypeEFIHCRTMEvent              EventType = 0x80000010 // EF_EFI_HCRTM_EVENT
+	EventTypeEFIHandoffTables           EventType = 0x80000009 // EV_EFI_HANDOFF_TABLES
+	EventTypeEFIPlatformFirmwareBlob2   EventType = 0x8000000a // EV_EFI_PLATFORM_FIRMWARE_BLOB2
+	EventTypeEFIHandoffTables2          EventType = 0x8000000b // EV_EFI_HANDOFF_TABLES2
+	EventTypeEFIHCRTMEvent              EventType = 0x80000010 // EV_EFI_HCRTM_EVENT
 	EventTypeEFIVariableAuthority       EventType = 0x800000e0 // EV_EFI_VARIABLE_AUTHORITY
+	EventTypeEFISPDMFirmwareBlob        EventType = 0x800000e1 // EV_EFI_SPDM_FIRMWARE_BLOB
+	EventTypeEFISPDMFirmwareConfig      EventType = 0x800000e2 // EV_EFI_SPDM_FIRMWARE_CONFIG
+	EventTypeEFISPDMDevicePolicy        EventType = 0x800000e3 // EV_EFI_SPDM_DEVICE_POLICY
+	EventTypeEFISPDMDeviceAuthority     EventType = 0x800000e4 // EV_EFI_SPDM_DEVICE_AUTHORITY
+)
+
+// Event types recorded in the DRTM event log by Intel TXT capable platforms and SINIT ACMs, which measure to PCRs 17 to 19.
+const (
+	EventTypeTXTPCRMapping         EventType = 0x00000401 // EVTYPE_PCRMAPPING
+	EventTypeTXTHashStart          EventType = 0x00000402 // EVTYPE_HASH_START
+	EventTypeTXTCombinedHash       EventType = 0x00000403 // EVTYPE_COMBINED_HASH
+	EventTypeTXTMLEHash            EventType = 0x00000404 // EVTYPE_MLE_HASH
+	EventTypeTXTBIOSACRegData      EventType = 0x0000040a // EVTYPE_BIOSAC_REG_DATA
+	EventTypeTXTCPUSCRTMStat       EventType = 0x0000040b // EVTYPE_CPU_SCRTM_STAT
+	EventTypeTXTLCPControlHash     EventType = 0x0000040c // EVTYPE_LCP_CONTROL_HASH
+	EventTypeTXTElementsDigest     EventType = 0x0000040d // EVTYPE_ELEMENTS_DIGEST
+	EventTypeTXTSTMHash            EventType = 0x0000040e // EVTYPE_STM_HASH
+	EventTypeTXTOSSINITDataCapHash EventType = 0x0000040f // EVTYPE_OSSINITDATA_CAP_HASH
+	EventTypeTXTSINITPubKeyHash    EventType = 0x00000410 // EVTYPE_SINIT_PUBKEY_HASH
+	EventTypeTXTLCPHash            EventType = 0x00000411 // EVTYPE_LCP_HASH
+	EventTypeTXTLCPDetailsHash     EventType = 0x00000412 // EVTYPE_LCP_DETAILS_HASH
+	EventTypeTXTLCPAuthoritiesHash EventType = 0x00000413 // EVTYPE_LCP_AUTHORITIES_HASH
+	EventTypeTXTNVInfoHash         EventType = 0x00000414 // EVTYPE_NV_INFO_HASH
+	EventTypeTXTColdBootBIOSHash   EventType = 0x00000415 // EVTYPE_COLD_BOOT_BIOS_HASH
+	EventTypeTXTKMHash             EventType = 0x00000416 // EVTYPE_KM_HASH
+	EventTypeTXTBPMHash            EventType = 0x00000417 // EVTYPE_BPM_HASH
+	EventTypeTXTKMInfoHash         EventType = 0x00000418 // EVTYPE_KM_INFO_HASH
+	EventTypeTXTBPMInfoHash        EventType = 0x00000419 // EVTYPE_BPM_INFO_HASH
+	EventTypeTXTBootPolHash        EventType = 0x0000041a // EVTYPE_BOOT_POL_HASH
+	EventTypeTXTRandomValue        EventType = 0x000004fe // EVTYPE_RANDOM_VALUE
+	EventTypeTXTCapValue           EventType = 0x000004ff // EVTYPE_CAP_VALUE
+)
+
+// Event types recorded in the DRTM event log by the Linux secure launch loaders, on both AMD (SKINIT) and Intel TXT platforms.
+const (
+	EventTypeSecureLaunch      EventType = 0x00000502 // TXT_EVTYPE_SLAUNCH
+	EventTypeSecureLaunchStart EventType = 0x00000503 // TXT_EVTYPE_SLAUNCH_START
+	EventTypeSecureLaunchEnd   EventType = 0x00000504 // TXT_EVTYPE_SLAUNCH_END
 )
 
 const (
@@ -69,3 +110,11 @@ const (
 const (
 	SeparatorEventErrorValue uint32 = 1
 )
+
+const (
+	// HCRTMEventString is the event data for an EV_EFI_HCRTM_EVENT event.
+	HCRTMEventString = "HCRTM"
+
+	// OmitBootDeviceEventsString is the event data for an EV_OMIT_BOOT_DEVICE_EVENTS event.
+	OmitBootDeviceEventsString = "BOOT ATTEMPTS OMITTED"
+)
@@ -0,0 +1,424 @@
+// Copyright 2019 Canonical Ltd.
+// Licensed under the LGPLv3 with static-linking exception.
+// See LICENCE file for details.
+
+package tcglog
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"strings"
+
+	"golang.org/x/xerrors"
+
+	"github.com/canonical/tcglog-parser/internal/cbor"
+)
+
+const (
+	cborTagUUID          = 37
+	cborTagCOSESign1     = 18
+	cborTagUnsignedCoRIM = 501
+	cborTagSignedCoRIM   = 502
+	cborTagCoMID         = 506
+)
+
+// corimDigestAlgorithms maps the identifiers and names from the IANA Named Information Hash Algorithm registry, which CoRIM uses
+// to identify digest algorithms, to the corresponding algorithms.
+var corimDigestAlgorithms = map[interface{}]AlgorithmId{
+	uint64(1): AlgorithmSha256,
+	uint64(7): AlgorithmSha384,
+	uint64(8): AlgorithmSha512,
+	"sha-1":   AlgorithmSha1,
+	"sha-256": AlgorithmSha256,
+	"sha-384": AlgorithmSha384,
+	"sha-512": AlgorithmSha512,
+}
+
+// CoRIMReferenceValue is a reference value claim from a CoMID (Concise Module Identifier) contained in a CoRIM.
+type CoRIMReferenceValue struct {
+	TagID   string    // The tag identifier of the CoMID that contains this claim
+	Vendor  string    // The vendor of the environment that the claim applies to, if specified
+	Model   string    // The model of the environment that the claim applies to, if specified
+	HasPCR  bool      // Whether the claim is for the value of a PCR
+	PCR     PCRIndex  // The PCR that the claim applies to if HasPCR is true
+	Name    string    // The name of the measured component, if specified
+	Digests DigestMap // The reference digests, for the algorithms that are supported by this package
+}
+
+func (v *CoRIMReferenceValue) String() string {
+	var s string
+	switch {
+	case v.HasPCR:
+		s = fmt.Sprintf("PCR %d", v.PCR)
+	case v.Name != "":
+		s = fmt.Sprintf("component \"%s\"", v.Name)
+	default:
+		s = "component"
+	}
+	if env := strings.TrimSpace(v.Vendor + " " + v.Model); env != "" {
+		s += fmt.Sprintf(" (%s)", env)
+	}
+	return s
+}
+
+// CoRIM is a Concise Reference Integrity Manifest, as defined by the IETF RATS working group.
+type CoRIM struct {
+	ID              string
+	ReferenceValues []*CoRIMReferenceValue // The reference value claims from every CoMID in the manifest
+}
+
+func decodeCoRIMID(v interface{}) (string, error) {
+	switch v := v.(type) {
+	case string:
+		return v, nil
+	case *cbor.Tag:
+		b, ok := v.Content.([]byte)
+		if v.Number != cborTagUUID || !ok || len(b) != 16 {
+			return "", fmt.Errorf("unexpected tag %d", v.Number)
+		}
+		return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:16]), nil
+	default:
+		return "", fmt.Errorf("unexpected type %T", v)
+	}
+}
+
+func decodeCoRIMDigests(v interface{}) (DigestMap, error) {
+	digests, ok := v.([]interface{})
+	if !ok {
+		return nil, fmt.Errorf("unexpected type %T (expected array)", v)
+	}
+	out := make(DigestMap)
+	for i, d := range digests {
+		d, ok := d.([]interface{})
+		if !ok || len(d) != 2 {
+			return nil, fmt.Errorf("digest %d is not an array of 2 elements", i)
+		}
+		value, ok := d[1].([]byte)
+		if !ok {
+			return nil, fmt.Errorf("digest %d has unexpected value type %T", i, d[1])
+		}
+		alg, ok := corimDigestAlgorithms[d[0]]
+		if !ok {
+			// Ignore algorithms that can't appear in a log.
+			continue
+		}
+		if len(value) != alg.Size() {
+			return nil, fmt.Errorf("digest %d has the wrong length for %v", i, alg)
+		}
+		out[alg] = value
+	}
+	return out, nil
+}
+
+func decodeCoRIMMeasurement(v interface{}, value *CoRIMReferenceValue) error {
+	m, ok := v.(cbor.Map)
+	if !ok {
+		return fmt.Errorf("unexpected type %T (expected map)", v)
+	}
+
+	// The TPM profile identifies a PCR by an unsigned integer measured element key.
+	if key, ok := m.Get(uint64(0)); ok {
+		if pcr, ok := key.(uint64); ok {
+			if pcr > math.MaxUint32 {
+				return fmt.Errorf("invalid PCR index %d", pcr)
+			}
+			value.HasPCR = true
+			value.PCR = PCRIndex(pcr)
+		}
+	}
+
+	mval, ok := m.Get(uint64(1))
+	if !ok {
+		return errors.New("no measurement values")
+	}
+	values, ok := mval.(cbor.Map)
+	if !ok {
+		return fmt.Errorf("measurement values have unexpected type %T (expected map)", mval)
+	}
+	if name, ok := values.Get(uint64(11)); ok {
+		value.Name, _ = name.(string)
+	}
+	digests, ok := values.Get(uint64(2))
+	if !ok {
+		return errors.New("no digests")
+	}
+	d, err := decodeCoRIMDigests(digests)
+	if err != nil {
+		return xerrors.Errorf("cannot decode digests: %w", err)
+	}
+	value.Digests = d
+	return nil
+}
+
+func decodeCoMID(data []byte) (out []*CoRIMReferenceValue, err error) {
+	v, err := cbor.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	m, ok := v.(cbor.Map)
+	if !ok {
+		return nil, fmt.Errorf("unexpected type %T (expected map)", v)
+	}
+
+	var tagID string
+	if identity, ok := m.Get(uint64(1)); ok {
+		if identity, ok := identity.(cbor.Map); ok {
+			if id, ok := identity.Get(uint64(0)); ok {
+				if tagID, err = decodeCoRIMID(id); err != nil {
+					return nil, xerrors.Errorf("cannot decode tag-id: %w", err)
+				}
+			}
+		}
+	}
+
+	t, ok := m.Get(uint64(4))
+	if !ok {
+		return nil, errors.New("no triples")
+	}
+	triples, ok := t.(cbor.Map)
+	if !ok {
+		return nil, fmt.Errorf("triples have unexpected type %T (expected map)", t)
+	}
+	r, ok := triples.Get(uint64(0))
+	if !ok {
+		// This CoMID doesn't contain any reference values.
+		return nil, nil
+	}
+	references, ok := r.([]interface{})
+	if !ok {
+		return nil, fmt.Errorf("reference triples have unexpected type %T (expected array)", r)
+	}
+
+	for i, r := range references {
+		triple, ok := r.([]interface{})
+		if !ok || len(triple) != 2 {
+			return nil, fmt.Errorf("reference triple %d is not an array of 2 elements", i)
+		}
+
+		var vendor, model string
+		if env, ok := triple[0].(cbor.Map); ok {
+			if class, ok := env.Get(uint64(0)); ok {
+				if class, ok := class.(cbor.Map); ok {
+					if v, ok := class.Get(uint64(1)); ok {
+						vendor, _ = v.(string)
+					}
+					if v, ok := class.Get(uint64(2)); ok {
+						model, _ = v.(string)
+					}
+				}
+			}
+		}
+
+		measurements, ok := triple[1].([]interface{})
+		if !ok {
+			return nil, fmt.Errorf("reference triple %d has unexpected measurements type %T (expected array)", i, triple[1])
+		}
+		for j, m := range measurements {
+			value := &CoRIMReferenceValue{TagID: tagID, Vendor: vendor, Model: model}
+			if err := decodeCoRIMMeasurement(m, value); err != nil {
+				return nil, xerrors.Errorf("cannot decode measurement %d of reference triple %d: %w", j, i, err)
+			}
+			out = append(out, value)
+		}
+	}
+	return out, nil
+}
+
+// ReadCoRIM reads a CBOR encoded CoRIM from r. This accepts an unsigned CoRIM, with or without the unsigned-corim tag, or a
+// COSE_Sign1 signed CoRIM. Note that the signature of a signed CoRIM is not verified - it is the responsibility of the caller to
+// establish that the manifest is from a trusted source.
+//
+// The reference value triples of each CoMID in the manifest are decoded. A measurement with an unsigned integer measured
+// element key is a claim about the final value of the PCR with that index, as used by the TPM profiles of CoRIM. Any other
+// measurement is a claim about a component that is measured by an individual event in the log.
+func ReadCoRIM(r io.Reader) (*CoRIM, error) {
+	v, err := cbor.Decode(r)
+	if err != nil {
+		return nil, xerrors.Errorf("cannot decode CBOR: %w", err)
+	}
+
+	if t, ok := v.(*cbor.Tag); ok && t.Number == cborTagSignedCoRIM {
+		v = t.Content
+	}
+	if t, ok := v.(*cbor.Tag); ok && t.Number == cborTagCOSESign1 {
+		sign1, ok := t.Content.([]interface{})
+		if !ok || len(sign1) != 4 {
+			return nil, errors.New("COSE_Sign1 structure is not an array of 4 elements")
+		}
+		payload, ok := sign1[2].([]byte)
+		if !ok {
+			return nil, errors.New("COSE_Sign1 structure has a detached or invalid payload")
+		}
+		if v, err = cbor.Decode(bytes.NewReader(payload)); err != nil {
+			return nil, xerrors.Errorf("cannot decode COSE_Sign1 payload: %w", err)
+		}
+	}
+	if t, ok := v.(*cbor.Tag); ok && t.Number == cborTagUnsignedCoRIM {
+		v = t.Content
+	}
+
+	m, ok := v.(cbor.Map)
+	if !ok {
+		return nil, fmt.Errorf("unexpected type %T (expected map)", v)
+	}
+
+	out := new(CoRIM)
+	if id, ok := m.Get(uint64(0)); ok {
+		if out.ID, err = decodeCoRIMID(id); err != nil {
+			return nil, xerrors.Errorf("cannot decode corim-id: %w", err)
+		}
+	}
+
+	t, ok := m.Get(uint64(1))
+	if !ok {
+		return nil, errors.New("no tags")
+	}
+	tags, ok := t.([]interface{})
+	if !ok {
+		return nil, fmt.Errorf("tags have unexpected type %T (expected array)", t)
+	}
+	for i, t := range tags {
+		tag, ok := t.(*cbor.Tag)
+		if !ok {
+			return nil, fmt.Errorf("tag %d has unexpected type %T", i, t)
+		}
+		if tag.Number != cborTagCoMID {
+			// Ignore CoSWID and CoTS tags.
+			continue
+		}
+		data, ok := tag.Content.([]byte)
+		if !ok {
+			return nil, fmt.Errorf("tag %d has unexpected content type %T", i, tag.Content)
+		}
+		values, err := decodeCoMID(data)
+		if err != nil {
+			return nil, xerrors.Errorf("cannot decode CoMID %d: %w", i, err)
+		}
+		out.ReferenceValues = append(out.ReferenceValues, values...)
+	}
+
+	return out, nil
+}
+
+// CoRIMClaimResult is the result of verifying a single reference value claim against a log.
+type CoRIMClaimResult struct {
+	Value     *CoRIMReferenceValue
+	Satisfied bool
+	Reason    string   // Why the claim is not satisfied
+	Events    []*Event // The events that match a component claim
+}
+
+func (r *CoRIMClaimResult) String() string {
+	if !r.Satisfied {
+		return fmt.Sprintf("FAIL: %s: %s", r.Value, r.Reason)
+	}
+	var events []string
+	for _, e := range r.Events {
+		events = append(events, fmt.Sprintf("event %d in PCR %d", e.Index, e.PCRIndex))
+	}
+	if len(events) == 0 {
+		return fmt.Sprintf("PASS: %s", r.Value)
+	}
+	return fmt.Sprintf("PASS: %s (%s)", r.Value, strings.Join(events, ", "))
+}
+
+// CoRIMResult is the result of verifying a log against the reference values in a CoRIM.
+type CoRIMResult struct {
+	Claims []*CoRIMClaimResult
+}
+
+// Satisfied indicates whether the log satisfies every reference value claim.
+func (r *CoRIMResult) Satisfied() bool {
+	for _, c := range r.Claims {
+		if !c.Satisfied {
+			return false
+		}
+	}
+	return true
+}
+
+func (r *CoRIMResult) String() string {
+	var claims []string
+	for _, c := range r.Claims {
+		claims = append(claims, c.String())
+	}
+	return strings.Join(claims, "\n")
+}
+
+// matchCoRIMDigests compares the reference digests with the supplied digests. The digests match if there is at least one
+// algorithm in common and the digests for every algorithm in common are equal.
+func matchCoRIMDigests(reference, digests DigestMap) (compared, matched bool) {
+	var algs []AlgorithmId
+	for alg := range reference {
+		if _, ok := digests[alg]; ok {
+			algs = append(algs, alg)
+		}
+	}
+	if len(algs) == 0 {
+		return false, false
+	}
+	sort.Slice(algs, func(i, j int) bool { return algs[i] < algs[j] })
+	for _, alg := range algs {
+		if !bytes.Equal(reference[alg], digests[alg]) {
+			return true, false
+		}
+	}
+	return true, true
+}
+
+// Verify verifies the supplied log against the reference values in this manifest, returning the result for each claim in the
+// order in which they appear. A PCR claim is satisfied if the value of the PCR obtained by replaying the log matches the
+// reference value. A component claim is satisfied if the digests of at least one event in the log match the reference value.
+// In both cases, only the algorithms that are in both the claim and the log are compared, and a claim with no algorithms in
+// common with the log is not satisfied.
+func (c *CoRIM) Verify(log *Log) *CoRIMResult {
+	values := log.ReplayAllPCRs()
+
+	result := new(CoRIMResult)
+	for _, v := range c.ReferenceValues {
+		r := &CoRIMClaimResult{Value: v}
+		result.Claims = append(result.Claims, r)
+
+		if v.HasPCR {
+			value, ok := values[v.PCR]
+			if !ok {
+				r.Reason = "the log contains no measurements for this PCR"
+				continue
+			}
+			switch compared, matched := matchCoRIMDigests(v.Digests, value); {
+			case !compared:
+				r.Reason = "the claim has no digests for the algorithms in the log"
+			case !matched:
+				r.Reason = "the value of the PCR obtained by replaying the log doesn't match"
+			default:
+				r.Satisfied = true
+			}
+			continue
+		}
+
+		compared := false
+		for _, e := range log.Events {
+			if e.EventType == EventTypeNoAction {
+				continue
+			}
+			ok, matched := matchCoRIMDigests(v.Digests, e.Digests)
+			compared = compared || ok
+			if matched {
+				r.Events = append(r.Events, e)
+			}
+		}
+		switch {
+		case len(r.Events) > 0:
+			r.Satisfied = true
+		case !compared:
+			r.Reason = "the claim has no digests for the algorithms in the log"
+		default:
+			r.Reason = "no event in the log matches"
+		}
+	}
+	return result
+}
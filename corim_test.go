@@ -0,0 +1,128 @@
+// Copyright 2019 Canonical Ltd.
+// Licensed under the LGPLv3 with static-linking exception.
+// See LICENCE file for details.
+
+package tcglog
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/canonical/tcglog-parser/internal/cbor"
+)
+
+func encodeTestCBOR(t *testing.T, v interface{}) []byte {
+	var b bytes.Buffer
+	enc := cbor.NewEncoder(&b)
+	enc.WriteValue(v)
+	if err := enc.Err(); err != nil {
+		t.Fatalf("Encode failed: %v", err)
+	}
+	return b.Bytes()
+}
+
+func makeTestCoRIM(t *testing.T, log *Log) []byte {
+	values := log.ReplayAllPCRs()
+	digests := func(algs ...interface{}) []interface{} {
+		var out []interface{}
+		for i := 0; i < len(algs); i += 2 {
+			out = append(out, []interface{}{algs[i], algs[i+1]})
+		}
+		return out
+	}
+	measurement := func(key interface{}, vals cbor.Map) cbor.Map {
+		m := cbor.Map{}
+		if key != nil {
+			m = append(m, cbor.MapEntry{Key: uint64(0), Value: key})
+		}
+		return append(m, cbor.MapEntry{Key: uint64(1), Value: vals})
+	}
+
+	comid := cbor.Map{
+		{Key: uint64(1), Value: cbor.Map{{Key: uint64(0), Value: "test-comid"}}},
+		{Key: uint64(4), Value: cbor.Map{
+			{Key: uint64(0), Value: []interface{}{
+				[]interface{}{
+					cbor.Map{{Key: uint64(0), Value: cbor.Map{
+						{Key: uint64(1), Value: "ACME"},
+						{Key: uint64(2), Value: "Roadrunner"}}}},
+					[]interface{}{
+						measurement(uint64(0), cbor.Map{
+							{Key: uint64(2), Value: digests(uint64(1), []byte(values[0][AlgorithmSha256]))}}),
+						measurement(uint64(1), cbor.Map{
+							{Key: uint64(2), Value: digests("sha-1", make([]byte, 20))}}),
+						measurement(nil, cbor.Map{
+							{Key: uint64(2), Value: digests(uint64(1), []byte(log.Events[3].Digests[AlgorithmSha256]),
+								uint64(2), make([]byte, 16))},
+							{Key: uint64(11), Value: "shim"}}),
+						measurement("kernel", cbor.Map{
+							{Key: uint64(2), Value: digests(uint64(7), make([]byte, 48))}}),
+					}}}}}}}
+
+	corim := cbor.Map{
+		{Key: uint64(0), Value: &cbor.Tag{Number: 37, Content: []byte{0x6e, 0x5c, 0x12, 0x9d, 0x2b, 0x4f, 0x4d, 0x3a, 0x9a, 0x1c,
+			0x8e, 0x7d, 0x4f, 0x2e, 0x71, 0x0b}}},
+		{Key: uint64(1), Value: []interface{}{&cbor.Tag{Number: 506, Content: encodeTestCBOR(t, comid)}}}}
+	return encodeTestCBOR(t, &cbor.Tag{Number: 501, Content: corim})
+}
+
+func TestReadCoRIM(t *testing.T) {
+	log := makeSBOMTestLog()
+	c, err := ReadCoRIM(bytes.NewReader(makeTestCoRIM(t, log)))
+	if err != nil {
+		t.Fatalf("ReadCoRIM failed: %v", err)
+	}
+	if c.ID != "6e5c129d-2b4f-4d3a-9a1c-8e7d4f2e710b" || len(c.ReferenceValues) != 4 {
+		t.Fatalf("Unexpected CoRIM: %+v", c)
+	}
+	for i, expected := range []string{"PCR 0 (ACME Roadrunner)", "PCR 1 (ACME Roadrunner)", "component \"shim\" (ACME Roadrunner)",
+		"component (ACME Roadrunner)"} {
+		v := c.ReferenceValues[i]
+		if v.String() != expected || v.TagID != "test-comid" {
+			t.Errorf("Unexpected reference value %d: %s", i, v)
+		}
+	}
+	if len(c.ReferenceValues[2].Digests) != 1 || len(c.ReferenceValues[3].Digests) != 1 {
+		t.Errorf("Unexpected digests")
+	}
+}
+
+func TestReadSignedCoRIM(t *testing.T) {
+	log := makeSBOMTestLog()
+	sign1 := &cbor.Tag{Number: 18, Content: []interface{}{[]byte{0xa1, 0x01, 0x26}, cbor.Map{}, makeTestCoRIM(t, log), make([]byte, 64)}}
+	c, err := ReadCoRIM(bytes.NewReader(encodeTestCBOR(t, sign1)))
+	if err != nil {
+		t.Fatalf("ReadCoRIM failed: %v", err)
+	}
+	if len(c.ReferenceValues) != 4 {
+		t.Errorf("Unexpected number of reference values: %d", len(c.ReferenceValues))
+	}
+
+	sign1.Content.([]interface{})[2] = nil
+	if _, err := ReadCoRIM(bytes.NewReader(encodeTestCBOR(t, sign1))); err == nil ||
+		err.Error() != "COSE_Sign1 structure has a detached or invalid payload" {
+		t.Errorf("Unexpected error: %v", err)
+	}
+}
+
+func TestCoRIMVerify(t *testing.T) {
+	log := makeSBOMTestLog()
+	c, err := ReadCoRIM(bytes.NewReader(makeTestCoRIM(t, log)))
+	if err != nil {
+		t.Fatalf("ReadCoRIM failed: %v", err)
+	}
+	result := c.Verify(log)
+	if result.Satisfied() {
+		t.Errorf("Expected the result to be unsatisfied")
+	}
+	expected := `PASS: PCR 0 (ACME Roadrunner)
+FAIL: PCR 1 (ACME Roadrunner): the value of the PCR obtained by replaying the log doesn't match
+PASS: component "shim" (ACME Roadrunner) (event 0 in PCR 4)
+FAIL: component (ACME Roadrunner): the claim has no digests for the algorithms in the log`
+	if result.String() != expected {
+		t.Errorf("Unexpected result:\n%s", result)
+	}
+	if len(result.Claims[2].Events) != 1 || result.Claims[2].Events[0] != log.Events[3] {
+		t.Errorf("Unexpected matching events")
+	}
+}
@@ -0,0 +1,266 @@
+// Copyright 2019 Canonical Ltd.
+// Licensed under the LGPLv3 with static-linking exception.
+// See LICENCE file for details.
+
+// Package corpus generates a corpus of valid, edge-case and deliberately malformed event logs in the binary format, for driving
+// fuzzing and robustness testing of code that consumes event logs.
+package corpus
+
+import (
+	"bytes"
+	"crypto"
+	_ "crypto/sha1"
+	_ "crypto/sha256"
+	_ "crypto/sha512"
+	"encoding/binary"
+
+	"github.com/canonical/tcglog-parser"
+)
+
+// Kind describes the category of a corpus entry.
+type Kind int
+
+const (
+	// Valid indicates a well-formed log representative of those produced by real firmware.
+	Valid Kind = iota
+
+	// EdgeCase indicates a well-formed log that exercises an unusual but permitted feature of the format.
+	EdgeCase
+
+	// Malformed indicates a log that doesn't conform to the format and which a parser should reject.
+	Malformed
+)
+
+func (k Kind) String() string {
+	switch k {
+	case Valid:
+		return "valid"
+	case EdgeCase:
+		return "edge"
+	case Malformed:
+		return "malformed"
+	default:
+		return "unknown"
+	}
+}
+
+// Entry corresponds to a single log in the corpus.
+type Entry struct {
+	Name string // A unique name describing the log
+	Kind Kind
+	Data []byte // The log in the binary format
+}
+
+// algorithmSm3_256 is a digest algorithm that isn't supported by the tcglog package.
+const algorithmSm3_256 tcglog.AlgorithmId = 0x0012
+
+type algorithm struct {
+	id   tcglog.AlgorithmId
+	size int
+}
+
+var (
+	sha1Only       = []algorithm{{tcglog.AlgorithmSha1, 20}}
+	sha1AndSha256  = []algorithm{{tcglog.AlgorithmSha1, 20}, {tcglog.AlgorithmSha256, 32}}
+	allAlgorithms  = []algorithm{{tcglog.AlgorithmSha1, 20}, {tcglog.AlgorithmSha256, 32}, {tcglog.AlgorithmSha384, 48}, {tcglog.AlgorithmSha512, 64}}
+	withSm3_256    = []algorithm{{tcglog.AlgorithmSha256, 32}, {algorithmSm3_256, 32}}
+	separatorValue = []byte{0, 0, 0, 0}
+)
+
+func (a algorithm) digest(data []byte) []byte {
+	h := a.id.GetHash()
+	if h == crypto.Hash(0) {
+		d := make([]byte, a.size)
+		for i := range d {
+			d[i] = byte(i)
+		}
+		return d
+	}
+	hh := h.New()
+	hh.Write(data)
+	return hh.Sum(nil)[:a.size]
+}
+
+type event struct {
+	pcr       tcglog.PCRIndex
+	eventType tcglog.EventType
+	data      []byte
+}
+
+// typicalEvents is a short sequence of events similar to those measured by UEFI firmware.
+var typicalEvents = []event{
+	{0, tcglog.EventTypeSCRTMVersion, []byte("1\x00.\x000\x00\x00\x00")},
+	{0, tcglog.EventTypeEFIPlatformFirmwareBlob, []byte{0x00, 0x00, 0x82, 0xff, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x7e, 0x00, 0x00, 0x00, 0x00, 0x00}},
+	{4, tcglog.EventTypeEFIAction, []byte("Calling EFI Application from Boot Option")},
+	{0, tcglog.EventTypeSeparator, separatorValue},
+	{1, tcglog.EventTypeSeparator, separatorValue},
+	{4, tcglog.EventTypeSeparator, separatorValue},
+	{7, tcglog.EventTypeSeparator, separatorValue},
+	{8, tcglog.EventTypeIPL, []byte("grub_cmd: linux /vmlinuz root=/dev/sda1\x00")},
+	{5, tcglog.EventTypeEFIAction, []byte("Exit Boot Services Invocation")},
+}
+
+func writeEvent_1_2(w *bytes.Buffer, e event) {
+	binary.Write(w, binary.LittleEndian, e.pcr)
+	binary.Write(w, binary.LittleEndian, e.eventType)
+	w.Write(sha1Only[0].digest(e.data))
+	binary.Write(w, binary.LittleEndian, uint32(len(e.data)))
+	w.Write(e.data)
+}
+
+func writeEvent_2(w *bytes.Buffer, e event, algs []algorithm) {
+	binary.Write(w, binary.LittleEndian, e.pcr)
+	binary.Write(w, binary.LittleEndian, e.eventType)
+	binary.Write(w, binary.LittleEndian, uint32(len(algs)))
+	for _, alg := range algs {
+		binary.Write(w, binary.LittleEndian, alg.id)
+		w.Write(alg.digest(e.data))
+	}
+	binary.Write(w, binary.LittleEndian, uint32(len(e.data)))
+	w.Write(e.data)
+}
+
+// specIdEvent03 returns the data for a TCG_EfiSpecIdEvent for the supplied algorithms.
+func specIdEvent03(algs []algorithm, vendorInfo []byte) []byte {
+	var w bytes.Buffer
+	w.WriteString("Spec ID Event03\x00")
+	w.Write([]byte{0, 0, 0, 0, 0, 2, 0, 2})
+	binary.Write(&w, binary.LittleEndian, uint32(len(algs)))
+	for _, alg := range algs {
+		binary.Write(&w, binary.LittleEndian, alg.id)
+		binary.Write(&w, binary.LittleEndian, uint16(alg.size))
+	}
+	w.WriteByte(uint8(len(vendorInfo)))
+	w.Write(vendorInfo)
+	return w.Bytes()
+}
+
+// cryptoAgileLog returns a crypto-agile log with a Spec ID event describing algs, followed by events.
+func cryptoAgileLog(algs []algorithm, vendorInfo []byte, events []event) []byte {
+	var w bytes.Buffer
+	writeEvent_1_2(&w, event{0, tcglog.EventTypeNoAction, specIdEvent03(algs, vendorInfo)})
+	for _, e := range events {
+		writeEvent_2(&w, e, algs)
+	}
+	return w.Bytes()
+}
+
+// legacyLog returns a SHA-1 only log containing events.
+func legacyLog(events []event) []byte {
+	var w bytes.Buffer
+	for _, e := range events {
+		writeEvent_1_2(&w, e)
+	}
+	return w.Bytes()
+}
+
+// cryptoAgileLogWithRawEvent returns a crypto-agile log containing the typical events, followed by a single event with the
+// supplied raw encoding.
+func cryptoAgileLogWithRawEvent(raw func(w *bytes.Buffer)) []byte {
+	var w bytes.Buffer
+	w.Write(cryptoAgileLog(sha1AndSha256, nil, typicalEvents))
+	raw(&w)
+	return w.Bytes()
+}
+
+func rawEventHeader(w *bytes.Buffer, pcr tcglog.PCRIndex, eventType tcglog.EventType, count uint32) {
+	binary.Write(w, binary.LittleEndian, pcr)
+	binary.Write(w, binary.LittleEndian, eventType)
+	binary.Write(w, binary.LittleEndian, count)
+}
+
+func rawDigest(w *bytes.Buffer, alg algorithm, data []byte) {
+	binary.Write(w, binary.LittleEndian, alg.id)
+	w.Write(alg.digest(data))
+}
+
+func rawEventData(w *bytes.Buffer, data []byte) {
+	binary.Write(w, binary.LittleEndian, uint32(len(data)))
+	w.Write(data)
+}
+
+func validEntries() []*Entry {
+	return []*Entry{
+		{Name: "crypto-agile", Kind: Valid, Data: cryptoAgileLog(sha1AndSha256, nil, typicalEvents)},
+		{Name: "legacy", Kind: Valid, Data: legacyLog(typicalEvents)},
+	}
+}
+
+func edgeCaseEntries() []*Entry {
+	vendorInfo := make([]byte, 255)
+	for i := range vendorInfo {
+		vendorInfo[i] = byte(i)
+	}
+	large := make([]byte, 64*1024)
+	for i := range large {
+		large[i] = byte(i)
+	}
+
+	return []*Entry{
+		{Name: "spec-id-only", Kind: EdgeCase, Data: cryptoAgileLog(sha1AndSha256, nil, nil)},
+		{Name: "all-algorithms", Kind: EdgeCase, Data: cryptoAgileLog(allAlgorithms, nil, typicalEvents)},
+		{Name: "sha1-only-crypto-agile", Kind: EdgeCase, Data: cryptoAgileLog(sha1Only, nil, typicalEvents)},
+		{Name: "unsupported-algorithm", Kind: EdgeCase, Data: cryptoAgileLog(withSm3_256, nil, typicalEvents)},
+		{Name: "max-vendor-info", Kind: EdgeCase, Data: cryptoAgileLog(sha1AndSha256, vendorInfo, typicalEvents)},
+		{Name: "empty-event-data", Kind: EdgeCase, Data: cryptoAgileLog(sha1AndSha256, nil, []event{{0, tcglog.EventTypePostCode, nil}})},
+		{Name: "max-pcr-index", Kind: EdgeCase, Data: cryptoAgileLog(sha1AndSha256, nil, []event{{31, tcglog.EventTypeAction, []byte("foo")}})},
+		{Name: "large-event-data", Kind: EdgeCase, Data: cryptoAgileLog(sha1AndSha256, nil, []event{{0, tcglog.EventTypePostCode, large}})},
+		{Name: "separator-error", Kind: EdgeCase, Data: cryptoAgileLog(sha1AndSha256, nil, []event{{7, tcglog.EventTypeSeparator, []byte{1, 0, 0, 0}}})},
+		{Name: "unknown-event-type", Kind: EdgeCase, Data: cryptoAgileLog(sha1AndSha256, nil, []event{{0, tcglog.EventType(0x0000ffff), []byte("foo")}})},
+		{Name: "legacy-single-event", Kind: EdgeCase, Data: legacyLog(typicalEvents[:1])},
+	}
+}
+
+func malformedEntries() []*Entry {
+	valid := cryptoAgileLog(sha1AndSha256, nil, typicalEvents)
+	// The size of the final event in the valid log, which is a EV_EFI_ACTION event.
+	last := typicalEvents[len(typicalEvents)-1]
+	lastSize := 12 + (2 + 20) + (2 + 32) + 4 + len(last.data)
+	lastStart := len(valid) - lastSize
+
+	return []*Entry{
+		{Name: "truncated-header", Kind: Malformed, Data: valid[:lastStart+6]},
+		{Name: "truncated-digest", Kind: Malformed, Data: valid[:lastStart+12+2+10]},
+		{Name: "truncated-event-size", Kind: Malformed, Data: valid[:lastStart+12+(2+20)+(2+32)+2]},
+		{Name: "truncated-event-data", Kind: Malformed, Data: valid[:len(valid)-1]},
+		{Name: "trailing-bytes", Kind: Malformed, Data: append(append([]byte(nil), valid...), 0x01, 0x02, 0x03)},
+		{Name: "oversized-event-size", Kind: Malformed, Data: cryptoAgileLogWithRawEvent(func(w *bytes.Buffer) {
+			rawEventHeader(w, 0, tcglog.EventTypePostCode, 2)
+			rawDigest(w, sha1AndSha256[0], nil)
+			rawDigest(w, sha1AndSha256[1], nil)
+			binary.Write(w, binary.LittleEndian, uint32(16))
+			w.Write([]byte("foo"))
+		})},
+		{Name: "out-of-range-pcr", Kind: Malformed, Data: cryptoAgileLog(sha1AndSha256, nil, []event{{32, tcglog.EventTypeAction, []byte("foo")}})},
+		{Name: "legacy-out-of-range-pcr", Kind: Malformed, Data: legacyLog([]event{{0, tcglog.EventTypePostCode, nil}, {0xffffffff, tcglog.EventTypeAction, []byte("foo")}})},
+		{Name: "unknown-algorithm", Kind: Malformed, Data: cryptoAgileLogWithRawEvent(func(w *bytes.Buffer) {
+			rawEventHeader(w, 0, tcglog.EventTypePostCode, 3)
+			rawDigest(w, sha1AndSha256[0], nil)
+			rawDigest(w, sha1AndSha256[1], nil)
+			rawDigest(w, algorithm{algorithmSm3_256, 32}, nil)
+			rawEventData(w, nil)
+		})},
+		{Name: "missing-digest", Kind: Malformed, Data: cryptoAgileLogWithRawEvent(func(w *bytes.Buffer) {
+			rawEventHeader(w, 0, tcglog.EventTypePostCode, 1)
+			rawDigest(w, sha1AndSha256[0], nil)
+			rawEventData(w, nil)
+		})},
+		{Name: "duplicate-digest", Kind: Malformed, Data: cryptoAgileLogWithRawEvent(func(w *bytes.Buffer) {
+			rawEventHeader(w, 0, tcglog.EventTypePostCode, 3)
+			rawDigest(w, sha1AndSha256[0], nil)
+			rawDigest(w, sha1AndSha256[1], nil)
+			rawDigest(w, sha1AndSha256[0], nil)
+			rawEventData(w, nil)
+		})},
+		{Name: "empty", Kind: Malformed, Data: []byte{}},
+	}
+}
+
+// Generate returns the corpus. The contents are deterministic.
+func Generate() []*Entry {
+	var entries []*Entry
+	entries = append(entries, validEntries()...)
+	entries = append(entries, edgeCaseEntries()...)
+	entries = append(entries, malformedEntries()...)
+	return entries
+}
@@ -0,0 +1,49 @@
+// Copyright 2019 Canonical Ltd.
+// Licensed under the LGPLv3 with static-linking exception.
+// See LICENCE file for details.
+
+package corpus
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/canonical/tcglog-parser"
+)
+
+func TestGenerate(t *testing.T) {
+	names := make(map[string]bool)
+	for _, e := range Generate() {
+		t.Run(e.Name, func(t *testing.T) {
+			if names[e.Name] {
+				t.Errorf("Duplicate name")
+			}
+			names[e.Name] = true
+
+			_, err := tcglog.ParseLog(bytes.NewReader(e.Data), &tcglog.LogOptions{EnableGrub: true})
+			switch e.Kind {
+			case Valid, EdgeCase:
+				if err != nil {
+					t.Errorf("ParseLog failed: %v", err)
+				}
+			case Malformed:
+				if err == nil {
+					t.Errorf("ParseLog should have failed")
+				}
+			}
+		})
+	}
+}
+
+func TestGenerateDeterministic(t *testing.T) {
+	a := Generate()
+	b := Generate()
+	if len(a) != len(b) {
+		t.Fatalf("Unexpected number of entries")
+	}
+	for i := range a {
+		if a[i].Name != b[i].Name || !bytes.Equal(a[i].Data, b[i].Data) {
+			t.Errorf("Entry %d (%s) is not deterministic", i, a[i].Name)
+		}
+	}
+}
@@ -0,0 +1,61 @@
+// Copyright 2019 Canonical Ltd.
+// Licensed under the LGPLv3 with static-linking exception.
+// See LICENCE file for details.
+
+package tcglog
+
+import (
+	"sync"
+
+	"golang.org/x/xerrors"
+)
+
+// EventDataDecoder is a function that decodes the data associated with an event of a specific type that is measured to a specific
+// PCR. It should return a nil EventData and a nil error if it doesn't recognize the data, in which case the data is decoded by
+// the built-in decoders instead. If it returns an error, the event data is returned as an EventData implementation that also
+// implements the error interface.
+type EventDataDecoder func(pcrIndex PCRIndex, eventType EventType, data []byte) (EventData, error)
+
+type eventDataDecoderKey struct {
+	pcrIndex  PCRIndex
+	eventType EventType
+}
+
+var (
+	eventDataDecodersMu sync.RWMutex
+	eventDataDecoders   = make(map[eventDataDecoderKey]EventDataDecoder)
+)
+
+// RegisterEventDataDecoder registers a custom decoder for the data associated with events of the specified type that are measured
+// to the specified PCR, for interpreting vendor or product specific measurements. Custom decoders take precedence over the
+// built-in decoders, including those enabled by LogOptions. Registering a decoder replaces any decoder previously registered for
+// the same PCR and event type, and registering a nil decoder removes it.
+//
+// Decoders are used by ParseLog, DecodeEventData and anything else in this package that decodes event data. This is safe to call
+// from multiple goroutines, but decoders should generally be registered before any logs are parsed.
+func RegisterEventDataDecoder(eventType EventType, pcrIndex PCRIndex, fn EventDataDecoder) {
+	eventDataDecodersMu.Lock()
+	defer eventDataDecodersMu.Unlock()
+
+	key := eventDataDecoderKey{pcrIndex: pcrIndex, eventType: eventType}
+	if fn == nil {
+		delete(eventDataDecoders, key)
+		return
+	}
+	eventDataDecoders[key] = fn
+}
+
+func decodeEventDataCustom(pcrIndex PCRIndex, eventType EventType, data []byte) EventData {
+	eventDataDecodersMu.RLock()
+	fn, ok := eventDataDecoders[eventDataDecoderKey{pcrIndex: pcrIndex, eventType: eventType}]
+	eventDataDecodersMu.RUnlock()
+	if !ok {
+		return nil
+	}
+
+	out, err := fn(pcrIndex, eventType, data)
+	if err != nil {
+		return &invalidEventData{data: data, err: xerrors.Errorf("cannot decode event data with custom decoder: %w", err)}
+	}
+	return out
+}
@@ -0,0 +1,75 @@
+// Copyright 2019 Canonical Ltd.
+// Licensed under the LGPLv3 with static-linking exception.
+// See LICENCE file for details.
+
+package tcglog
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"testing"
+)
+
+type testVendorEventData struct {
+	data  []byte
+	Value string
+}
+
+func (e *testVendorEventData) String() string {
+	return "vendor: " + e.Value
+}
+
+func (e *testVendorEventData) Bytes() []byte {
+	return e.data
+}
+
+func (e *testVendorEventData) EncodeTo(w io.Writer) error {
+	_, err := w.Write(e.data)
+	return err
+}
+
+func TestRegisterEventDataDecoder(t *testing.T) {
+	RegisterEventDataDecoder(EventTypeAction, 1, func(pcrIndex PCRIndex, eventType EventType, data []byte) (EventData, error) {
+		switch {
+		case bytes.HasPrefix(data, []byte("vendor:")):
+			return &testVendorEventData{data: data, Value: string(data[7:])}, nil
+		case bytes.Equal(data, []byte("bad")):
+			return nil, errors.New("bad data")
+		default:
+			return nil, nil
+		}
+	})
+	defer RegisterEventDataDecoder(EventTypeAction, 1, nil)
+
+	for _, data := range []struct {
+		desc     string
+		pcr      PCRIndex
+		data     string
+		expected string
+		isErr    bool
+	}{
+		{desc: "Custom", pcr: 1, data: "vendor:foo", expected: "vendor: foo"},
+		{desc: "Fallback", pcr: 1, data: "foo", expected: "foo"},
+		{desc: "OtherPCR", pcr: 2, data: "vendor:foo", expected: "vendor:foo"},
+		{desc: "Error", pcr: 1, data: "bad", expected: "Invalid event data: cannot decode event data with custom decoder: bad data", isErr: true},
+	} {
+		t.Run(data.desc, func(t *testing.T) {
+			d := DecodeEventData(data.pcr, EventTypeAction, DigestMap{}, []byte(data.data), nil)
+			if d.String() != data.expected {
+				t.Errorf("Unexpected event data: %s", d)
+			}
+			if _, isErr := d.(error); isErr != data.isErr {
+				t.Errorf("Unexpected error state")
+			}
+			if !bytes.Equal(d.Bytes(), []byte(data.data)) {
+				t.Errorf("Unexpected event data bytes")
+			}
+		})
+	}
+
+	RegisterEventDataDecoder(EventTypeAction, 1, nil)
+	if d := DecodeEventData(1, EventTypeAction, DigestMap{}, []byte("vendor:foo"), nil); d.String() != "vendor:foo" {
+		t.Errorf("Decoder was not removed")
+	}
+}
@@ -0,0 +1,152 @@
+// Copyright 2019 Canonical Ltd.
+// Licensed under the LGPLv3 with static-linking exception.
+// See LICENCE file for details.
+
+package tcglog
+
+import (
+	"bytes"
+	"encoding/binary"
+	"testing"
+)
+
+func makeTestDevicePathNode(t, subType uint8, data []byte) []byte {
+	var b bytes.Buffer
+	b.Write([]byte{t, subType})
+	binary.Write(&b, binary.LittleEndian, uint16(len(data)+4))
+	b.Write(data)
+	return b.Bytes()
+}
+
+func TestEFIDevicePathToText(t *testing.T) {
+	pciRoot := makeTestDevicePathNode(0x02, 0x01, []byte{0xd0, 0x41, 0x03, 0x0a, 0x00, 0x00, 0x00, 0x00})
+	pci := makeTestDevicePathNode(0x01, 0x01, []byte{0x02, 0x1f})
+	sata := makeTestDevicePathNode(0x03, 0x12, []byte{0x00, 0x00, 0xff, 0xff, 0x00, 0x00})
+
+	var hd bytes.Buffer
+	binary.Write(&hd, binary.LittleEndian, uint32(1))
+	binary.Write(&hd, binary.LittleEndian, uint64(0x800))
+	binary.Write(&hd, binary.LittleEndian, uint64(0x100000))
+	hd.Write([]byte{0x78, 0x56, 0x34, 0x12})
+	hd.Write(make([]byte, 12))
+	hd.Write([]byte{0x01, 0x01})
+	mbr := makeTestDevicePathNode(0x04, 0x01, hd.Bytes())
+
+	var file bytes.Buffer
+	binary.Write(&file, binary.LittleEndian, convertStringToUtf16("\\EFI\\BOOT\\BOOTX64.EFI\x00"))
+	filePath := makeTestDevicePathNode(0x04, 0x04, file.Bytes())
+
+	fvGUID := MakeEFIGUID(0x7cb8bdc9, 0xf8eb, 0x4f34, 0xaaea, [...]uint8{0x3e, 0xe4, 0xaf, 0x65, 0x16, 0xa1})
+	fv := makeTestDevicePathNode(0x04, 0x07, fvGUID[:])
+	fvFile := makeTestDevicePathNode(0x04, 0x06, fvGUID[:])
+
+	endInstance := []byte{0x7f, 0x01, 0x04, 0x00}
+
+	nvme := makeTestDevicePathNode(0x03, 0x17, []byte{0x01, 0x00, 0x00, 0x00, 0x08, 0x07, 0x06, 0x05, 0x04, 0x03, 0x02, 0x01})
+	uri := makeTestDevicePathNode(0x03, 0x18, []byte("http://192.168.0.1/boot.efi"))
+
+	var wwid bytes.Buffer
+	binary.Write(&wwid, binary.LittleEndian, []uint16{0x0, 0x0781, 0x5583})
+	binary.Write(&wwid, binary.LittleEndian, convertStringToUtf16("1234"))
+	usbWwid := makeTestDevicePathNode(0x03, 0x10, wwid.Bytes())
+
+	sd := makeTestDevicePathNode(0x03, 0x1a, []byte{0x01})
+	emmc := makeTestDevicePathNode(0x03, 0x1d, []byte{0x00})
+
+	macAddr := make([]byte, 33)
+	copy(macAddr, []byte{0x52, 0x54, 0x00, 0x12, 0x34, 0x56})
+	macAddr[32] = 0x01
+	mac := makeTestDevicePathNode(0x03, 0x0b, macAddr)
+
+	var ipv4 bytes.Buffer
+	ipv4.Write([]byte{192, 168, 0, 10, 192, 168, 0, 1})
+	binary.Write(&ipv4, binary.LittleEndian, []uint16{0, 69, 17})
+	ipv4.Write([]byte{0x00, 192, 168, 0, 1, 255, 255, 255, 0})
+	ipv4Node := makeTestDevicePathNode(0x03, 0x0c, ipv4.Bytes())
+
+	var ipv6 bytes.Buffer
+	ipv6.Write(make([]byte, 16))
+	ipv6.Write([]byte{0xfe, 0x80, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 1})
+	binary.Write(&ipv6, binary.LittleEndian, []uint16{0, 0, 6})
+	ipv6.Write([]byte{0x01, 0x40})
+	ipv6.Write(make([]byte, 16))
+	ipv6Node := makeTestDevicePathNode(0x03, 0x0d, ipv6.Bytes())
+
+	for _, data := range []struct {
+		desc     string
+		nodes    [][]byte
+		expected string
+	}{
+		{
+			desc:     "SATA",
+			nodes:    [][]byte{pciRoot, pci, sata, mbr, filePath},
+			expected: "PciRoot(0x0)/Pci(0x1f,0x2)/Sata(0x0,0xffff,0x0)/HD(1,MBR,0x12345678,0x800,0x100000)/\\EFI\\BOOT\\BOOTX64.EFI",
+		},
+		{
+			desc:     "Firmware",
+			nodes:    [][]byte{fv, fvFile},
+			expected: "Fv(7cb8bdc9-f8eb-4f34-aaea-3ee4af6516a1)/FvFile(7cb8bdc9-f8eb-4f34-aaea-3ee4af6516a1)",
+		},
+		{
+			desc:     "MultipleInstances",
+			nodes:    [][]byte{pciRoot, pci, endInstance, pciRoot},
+			expected: "PciRoot(0x0)/Pci(0x1f,0x2),PciRoot(0x0)",
+		},
+		{
+			desc:     "NVMe",
+			nodes:    [][]byte{pciRoot, pci, nvme},
+			expected: "PciRoot(0x0)/Pci(0x1f,0x2)/NVMe(0x1,01-02-03-04-05-06-07-08)",
+		},
+		{
+			desc:     "URI",
+			nodes:    [][]byte{pciRoot, mac, ipv4Node, uri},
+			expected: "PciRoot(0x0)/MAC(525400123456,0x1)/IPv4(192.168.0.1,UDP,DHCP,192.168.0.10,192.168.0.1,255.255.255.0)/Uri(http://192.168.0.1/boot.efi)",
+		},
+		{
+			desc:     "IPv6",
+			nodes:    [][]byte{mac, ipv6Node},
+			expected: "MAC(525400123456,0x1)/IPv6(fe80:0:0:0:0:0:0:1,TCP,StatelessAutoConfigure,0:0:0:0:0:0:0:0,0x40,0:0:0:0:0:0:0:0)",
+		},
+		{
+			desc:     "UsbWwid",
+			nodes:    [][]byte{pciRoot, usbWwid},
+			expected: "PciRoot(0x0)/UsbWwid(0x781,0x5583,0x0,\"1234\")",
+		},
+		{
+			desc:     "SD",
+			nodes:    [][]byte{pciRoot, sd},
+			expected: "PciRoot(0x0)/SD(0x1)",
+		},
+		{
+			desc:     "eMMC",
+			nodes:    [][]byte{pciRoot, emmc},
+			expected: "PciRoot(0x0)/eMMC(0x0)",
+		},
+		{
+			desc:     "Generic",
+			nodes:    [][]byte{pciRoot, makeTestDevicePathNode(0x03, 0x7e, []byte{0x01, 0x00, 0x00, 0x00})},
+			expected: "PciRoot(0x0)/Msg(126,01000000)",
+		},
+		{
+			desc:     "UnknownType",
+			nodes:    [][]byte{makeTestDevicePathNode(0x06, 0x01, nil)},
+			expected: "Path(6,1)",
+		},
+	} {
+		t.Run(data.desc, func(t *testing.T) {
+			var path []byte
+			for _, n := range data.nodes {
+				path = append(path, n...)
+			}
+			path = append(path, efiEndEntireDevicePath...)
+
+			text, err := EFIDevicePathToText(path)
+			if err != nil {
+				t.Fatalf("EFIDevicePathToText failed: %v", err)
+			}
+			if text != data.expected {
+				t.Errorf("Unexpected text: %s", text)
+			}
+		})
+	}
+}
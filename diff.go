@@ -0,0 +1,228 @@
+// Copyright 2019 Canonical Ltd.
+// Licensed under the LGPLv3 with static-linking exception.
+// See LICENCE file for details.
+
+package tcglog
+
+import (
+	"bytes"
+	"fmt"
+	"sort"
+)
+
+// EventDiffKind describes how an event differs between two logs.
+type EventDiffKind int
+
+const (
+	// EventAdded indicates that the event only appears in the second log.
+	EventAdded EventDiffKind = iota
+
+	// EventRemoved indicates that the event only appears in the first log.
+	EventRemoved
+
+	// EventChanged indicates that an event of the same type appears at the same position in both logs, but with different data
+	// or digests.
+	EventChanged
+)
+
+func (k EventDiffKind) String() string {
+	switch k {
+	case EventAdded:
+		return "added"
+	case EventRemoved:
+		return "removed"
+	case EventChanged:
+		return "changed"
+	default:
+		return fmt.Sprintf("%%!(UNKNOWN_DIFF_KIND=%d)", int(k))
+	}
+}
+
+// EventDiff describes a difference in a single event between two logs.
+type EventDiff struct {
+	Kind EventDiffKind
+	A    *Event // The event from the first log, or nil if Kind is EventAdded
+	B    *Event // The event from the second log, or nil if Kind is EventRemoved
+}
+
+func (d *EventDiff) String() string {
+	switch d.Kind {
+	case EventAdded:
+		return fmt.Sprintf("+ event %d (type: %v): %s", d.B.Index, d.B.EventType, d.B.Data)
+	case EventRemoved:
+		return fmt.Sprintf("- event %d (type: %v): %s", d.A.Index, d.A.EventType, d.A.Data)
+	default:
+		return fmt.Sprintf("~ event %d -> %d (type: %v): %s -> %s", d.A.Index, d.B.Index, d.A.EventType, d.A.Data, d.B.Data)
+	}
+}
+
+// PCRDiff describes the differences between the events for a single PCR in two logs, and the resulting difference in the value
+// of the PCR.
+type PCRDiff struct {
+	PCRIndex PCRIndex
+	Events   []*EventDiff // The event differences, in log order
+	A        DigestMap    // The value of the PCR computed from the first log, for each algorithm that both logs have in common and that every event has a digest for
+	B        DigestMap    // The value of the PCR computed from the second log, for each algorithm that both logs have in common and that every event has a digest for
+}
+
+// ValueChanged indicates whether the value of the PCR differs between the two logs.
+func (d *PCRDiff) ValueChanged() bool {
+	for alg, a := range d.A {
+		if !bytes.Equal(a, d.B[alg]) {
+			return true
+		}
+	}
+	return false
+}
+
+// LogDiff is a structured diff of two logs, created by DiffLogs.
+type LogDiff struct {
+	Algorithms AlgorithmIdList // The algorithms that both logs have in common, which are used to compare digests
+	PCRs       []*PCRDiff      // The PCRs that have differences, sorted by PCR index
+}
+
+// Equal indicates whether there are no differences between the two logs.
+func (d *LogDiff) Equal() bool {
+	return len(d.PCRs) == 0
+}
+
+func (d *LogDiff) String() string {
+	var b bytes.Buffer
+	for _, p := range d.PCRs {
+		fmt.Fprintf(&b, "PCR %d:\n", p.PCRIndex)
+		for _, e := range p.Events {
+			fmt.Fprintf(&b, "  %s\n", e)
+		}
+		for _, alg := range d.Algorithms {
+			if a, ok := p.A[alg]; ok && !bytes.Equal(a, p.B[alg]) {
+				fmt.Fprintf(&b, "  %v: %x -> %x\n", alg, a, p.B[alg])
+			}
+		}
+	}
+	return b.String()
+}
+
+type eventDiffer struct {
+	algs AlgorithmIdList
+}
+
+func (d *eventDiffer) equal(a, b *Event) bool {
+	if a.EventType != b.EventType || !bytes.Equal(a.Data.Bytes(), b.Data.Bytes()) {
+		return false
+	}
+	for _, alg := range d.algs {
+		if !bytes.Equal(a.Digests[alg], b.Digests[alg]) {
+			return false
+		}
+	}
+	return true
+}
+
+// diff computes the differences between two sequences of events for the same PCR, using the longest common subsequence. Within
+// each run of differing events, removed and added events of the same type at the same position are reported as changed.
+func (d *eventDiffer) diff(a, b []*Event) (out []*EventDiff) {
+	lcs := make([][]int, len(a)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(b)+1)
+	}
+	for i := len(a) - 1; i >= 0; i-- {
+		for j := len(b) - 1; j >= 0; j-- {
+			switch {
+			case d.equal(a[i], b[j]):
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			case lcs[i+1][j] >= lcs[i][j+1]:
+				lcs[i][j] = lcs[i+1][j]
+			default:
+				lcs[i][j] = lcs[i][j+1]
+			}
+		}
+	}
+
+	var removed, added []*Event
+	flush := func() {
+		n := 0
+		for n < len(removed) && n < len(added) && removed[n].EventType == added[n].EventType {
+			out = append(out, &EventDiff{Kind: EventChanged, A: removed[n], B: added[n]})
+			n++
+		}
+		for _, e := range removed[n:] {
+			out = append(out, &EventDiff{Kind: EventRemoved, A: e})
+		}
+		for _, e := range added[n:] {
+			out = append(out, &EventDiff{Kind: EventAdded, B: e})
+		}
+		removed, added = nil, nil
+	}
+
+	i, j := 0, 0
+	for i < len(a) || j < len(b) {
+		switch {
+		case i < len(a) && j < len(b) && d.equal(a[i], b[j]):
+			flush()
+			i++
+			j++
+		case j >= len(b) || (i < len(a) && lcs[i+1][j] >= lcs[i][j+1]):
+			removed = append(removed, a[i])
+			i++
+		default:
+			added = append(added, b[j])
+			j++
+		}
+	}
+	flush()
+	return out
+}
+
+// DiffLogs compares two logs, eg, from boots before and after a firmware update, and returns the events that were added, removed
+// or changed for each PCR, along with the resulting values of each PCR that differs. Events are compared by type, data and the
+// digests for the algorithms that both logs have in common.
+func DiffLogs(a, b *Log) *LogDiff {
+	out := new(LogDiff)
+	for _, alg := range a.Algorithms {
+		if b.Algorithms.Contains(alg) {
+			out.Algorithms = append(out.Algorithms, alg)
+		}
+	}
+	differ := &eventDiffer{algs: out.Algorithms}
+
+	eventsA := make(map[PCRIndex][]*Event)
+	eventsB := make(map[PCRIndex][]*Event)
+	var pcrs []PCRIndex
+	for _, e := range a.Events {
+		if _, ok := eventsA[e.PCRIndex]; !ok {
+			pcrs = append(pcrs, e.PCRIndex)
+		}
+		eventsA[e.PCRIndex] = append(eventsA[e.PCRIndex], e)
+	}
+	for _, e := range b.Events {
+		_, okA := eventsA[e.PCRIndex]
+		_, okB := eventsB[e.PCRIndex]
+		if !okA && !okB {
+			pcrs = append(pcrs, e.PCRIndex)
+		}
+		eventsB[e.PCRIndex] = append(eventsB[e.PCRIndex], e)
+	}
+	sort.Slice(pcrs, func(i, j int) bool { return pcrs[i] < pcrs[j] })
+
+	for _, pcr := range pcrs {
+		events := differ.diff(eventsA[pcr], eventsB[pcr])
+		if len(events) == 0 {
+			continue
+		}
+		d := &PCRDiff{PCRIndex: pcr, Events: events, A: make(DigestMap), B: make(DigestMap)}
+		for _, alg := range out.Algorithms {
+			if !alg.supported() {
+				continue
+			}
+			if value, err := a.replayPCR(alg, pcr); err == nil {
+				d.A[alg] = value
+			}
+			if value, err := b.replayPCR(alg, pcr); err == nil {
+				d.B[alg] = value
+			}
+		}
+		out.PCRs = append(out.PCRs, d)
+	}
+
+	return out
+}
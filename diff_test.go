@@ -0,0 +1,93 @@
+// Copyright 2019 Canonical Ltd.
+// Licensed under the LGPLv3 with static-linking exception.
+// See LICENCE file for details.
+
+package tcglog
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestDiffLogs(t *testing.T) {
+	newLog := func(algs AlgorithmIdList, crtm string, actions ...string) *Log {
+		b, err := NewLogBuilder(algs, nil)
+		if err != nil {
+			t.Fatalf("NewLogBuilder failed: %v", err)
+		}
+		b.AddEvent(0, EventTypeSCRTMVersion, []byte(crtm))
+		for _, action := range actions {
+			b.AddEvent(4, EventTypeEFIAction, []byte(action))
+		}
+		b.AddEvent(4, EventTypeSeparator, []byte{0, 0, 0, 0})
+		b.AddEvent(7, EventTypeSeparator, []byte{0, 0, 0, 0})
+		return b.Log()
+	}
+
+	a := newLog(AlgorithmIdList{AlgorithmSha1, AlgorithmSha256}, "1.0", "foo", "bar", "baz")
+
+	t.Run("Equal", func(t *testing.T) {
+		diff := DiffLogs(a, a.ShallowCopy())
+		if !diff.Equal() || diff.String() != "" {
+			t.Errorf("Unexpected diff:\n%s", diff)
+		}
+	})
+
+	t.Run("Changes", func(t *testing.T) {
+		b := newLog(AlgorithmIdList{AlgorithmSha256}, "2.0", "foo", "baz", "qux")
+		diff := DiffLogs(a, b)
+		if diff.Equal() {
+			t.Fatalf("Expected differences")
+		}
+		if len(diff.Algorithms) != 1 || diff.Algorithms[0] != AlgorithmSha256 {
+			t.Errorf("Unexpected algorithms: %v", diff.Algorithms)
+		}
+		if len(diff.PCRs) != 2 || diff.PCRs[0].PCRIndex != 0 || diff.PCRs[1].PCRIndex != 4 {
+			t.Fatalf("Unexpected PCRs")
+		}
+
+		pcr0 := diff.PCRs[0]
+		// The Spec ID events differ because the logs have different algorithms.
+		if len(pcr0.Events) != 2 || pcr0.Events[0].A.EventType != EventTypeNoAction || pcr0.Events[1].Kind != EventChanged ||
+			pcr0.Events[1].A != a.Events[1] || pcr0.Events[1].B != b.Events[1] {
+			t.Errorf("Unexpected PCR 0 events: %v", pcr0.Events)
+		}
+		if !pcr0.ValueChanged() {
+			t.Errorf("Expected PCR 0 value to change")
+		}
+		expected, _ := b.ReplayPCR(AlgorithmSha256, 0)
+		if !bytes.Equal(pcr0.B[AlgorithmSha256], expected) || len(pcr0.A) != 1 {
+			t.Errorf("Unexpected PCR 0 values")
+		}
+
+		pcr4 := diff.PCRs[1]
+		if len(pcr4.Events) != 2 {
+			t.Fatalf("Unexpected PCR 4 events: %v", pcr4.Events)
+		}
+		if pcr4.Events[0].Kind != EventRemoved || pcr4.Events[0].A.Data.String() != "bar" {
+			t.Errorf("Unexpected PCR 4 event: %s", pcr4.Events[0])
+		}
+		if pcr4.Events[1].Kind != EventAdded || pcr4.Events[1].B.Data.String() != "qux" {
+			t.Errorf("Unexpected PCR 4 event: %s", pcr4.Events[1])
+		}
+
+		if diff.String() == "" {
+			t.Errorf("Expected a non-empty string")
+		}
+	})
+
+	t.Run("ChangedRun", func(t *testing.T) {
+		b := newLog(AlgorithmIdList{AlgorithmSha1, AlgorithmSha256}, "1.0", "foo", "BAR", "BAZ", "qux")
+		diff := DiffLogs(a, b)
+		if len(diff.PCRs) != 1 || diff.PCRs[0].PCRIndex != 4 {
+			t.Fatalf("Unexpected PCRs")
+		}
+		var kinds []EventDiffKind
+		for _, e := range diff.PCRs[0].Events {
+			kinds = append(kinds, e.Kind)
+		}
+		if len(kinds) != 3 || kinds[0] != EventChanged || kinds[1] != EventChanged || kinds[2] != EventAdded {
+			t.Errorf("Unexpected event diffs: %v", diff.PCRs[0].Events)
+		}
+	})
+}
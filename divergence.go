@@ -0,0 +1,125 @@
+// Copyright 2019 Canonical Ltd.
+// Licensed under the LGPLv3 with static-linking exception.
+// See LICENCE file for details.
+
+package tcglog
+
+import (
+	"bytes"
+	"fmt"
+)
+
+// DivergenceKind describes how the events in a log diverge from what was measured to a PCR.
+type DivergenceKind int
+
+const (
+	// DivergenceNone indicates that replaying every event in the log produces the observed PCR value.
+	DivergenceNone DivergenceKind = iota
+
+	// DivergenceTruncated indicates that the observed PCR value is produced by replaying only the events up to but not including
+	// the divergent event, ie, the divergent event and any subsequent events were recorded in the log but weren't measured.
+	DivergenceTruncated
+
+	// DivergenceUnmeasuredEvent indicates that the observed PCR value is produced by replaying every event except the divergent
+	// event, ie, the divergent event was recorded in the log but wasn't measured.
+	DivergenceUnmeasuredEvent
+
+	// DivergenceUnknown indicates that the observed PCR value can't be produced from the events in the log. This happens if a
+	// digest in the log is different to what was measured, or if something was measured without being recorded in the log. The
+	// divergent event can't be determined, so the Event field of the Divergence is nil.
+	DivergenceUnknown
+)
+
+func (k DivergenceKind) String() string {
+	switch k {
+	case DivergenceNone:
+		return "none"
+	case DivergenceTruncated:
+		return "truncated"
+	case DivergenceUnmeasuredEvent:
+		return "unmeasured event"
+	case DivergenceUnknown:
+		return "unknown"
+	default:
+		return fmt.Sprintf("%%!(UNKNOWN_DIVERGENCE=%d)", int(k))
+	}
+}
+
+// Divergence describes the point at which the events for a PCR in a log diverge from the value observed in the TPM. The Event
+// field is only set if Kind is DivergenceTruncated or DivergenceUnmeasuredEvent. It is nil if Kind is DivergenceNone, because
+// the log doesn't diverge, or if Kind is DivergenceUnknown, because the divergent event can't be determined.
+type Divergence struct {
+	PCRIndex  PCRIndex
+	Algorithm AlgorithmId
+	Kind      DivergenceKind
+	Event     *Event // The first event that is inconsistent with the observed value, or nil (see above)
+}
+
+func (d *Divergence) String() string {
+	switch {
+	case d.Kind == DivergenceNone:
+		return fmt.Sprintf("PCR %d, bank %v: the log is consistent with the observed value", d.PCRIndex, d.Algorithm)
+	case d.Event == nil:
+		return fmt.Sprintf("PCR %d, bank %v: the observed value can't be produced from the events in the log", d.PCRIndex,
+			d.Algorithm)
+	case d.Kind == DivergenceTruncated:
+		return fmt.Sprintf("PCR %d, bank %v: the log diverges at event %d (type: %v), which and all subsequent events weren't "+
+			"measured", d.PCRIndex, d.Algorithm, d.Event.Index, d.Event.EventType)
+	default:
+		return fmt.Sprintf("PCR %d, bank %v: event %d (type: %v) was recorded in the log but wasn't measured", d.PCRIndex,
+			d.Algorithm, d.Event.Index, d.Event.EventType)
+	}
+}
+
+// FindDivergence replays the events for the specified PCR incrementally and identifies the first event at which the log diverges
+// from the supplied observed value of the PCR, eg, as read from the TPM. If the observed value matches the running value after an
+// earlier event, the divergence is reported at the event after that (DivergenceTruncated). Otherwise, if omitting a single event
+// produces the observed value, that event is reported (DivergenceUnmeasuredEvent). If neither of these explains the observed value,
+// a Divergence of kind DivergenceUnknown with no event is returned. An error is returned if the log doesn't contain digests for the
+// specified algorithm.
+func (l *Log) FindDivergence(alg AlgorithmId, pcr PCRIndex, observed Digest) (*Divergence, error) {
+	if err := l.checkReplayAlgorithm(alg); err != nil {
+		return nil, err
+	}
+
+	var events []*Event
+	for _, e := range l.Events {
+		if e.PCRIndex == pcr && e.EventType != EventTypeNoAction {
+			events = append(events, e)
+		}
+	}
+
+	result := &Divergence{PCRIndex: pcr, Algorithm: alg}
+
+	initial := l.initialPCRValue(alg, pcr)
+	values := []Digest{initial}
+	for _, e := range events {
+		values = append(values, extendDigest(alg, values[len(values)-1], e.Digests[alg]))
+	}
+
+	if bytes.Equal(values[len(values)-1], observed) {
+		return result, nil
+	}
+	for i, value := range values[:len(values)-1] {
+		if bytes.Equal(value, observed) {
+			result.Kind = DivergenceTruncated
+			result.Event = events[i]
+			return result, nil
+		}
+	}
+
+	for skip := range events {
+		value := values[skip]
+		for _, e := range events[skip+1:] {
+			value = extendDigest(alg, value, e.Digests[alg])
+		}
+		if bytes.Equal(value, observed) {
+			result.Kind = DivergenceUnmeasuredEvent
+			result.Event = events[skip]
+			return result, nil
+		}
+	}
+
+	result.Kind = DivergenceUnknown
+	return result, nil
+}
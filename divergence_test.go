@@ -0,0 +1,62 @@
+// Copyright 2019 Canonical Ltd.
+// Licensed under the LGPLv3 with static-linking exception.
+// See LICENCE file for details.
+
+package tcglog
+
+import (
+	"testing"
+)
+
+func TestFindDivergence(t *testing.T) {
+	events := []*Event{
+		makeTestEvent(4, EventTypeEFIAction, []byte("Calling EFI Application from Boot Option"), AlgorithmSha256),
+		makeTestEvent(4, EventTypeSeparator, []byte{0, 0, 0, 0}, AlgorithmSha256),
+		makeTestEvent(4, EventTypeEFIBootServicesApplication, []byte("shim"), AlgorithmSha256),
+		makeTestEvent(4, EventTypeEFIBootServicesApplication, []byte("grub"), AlgorithmSha256),
+		makeTestEvent(7, EventTypeSeparator, []byte{0, 0, 0, 0}, AlgorithmSha256)}
+	log := NewLog(events)
+
+	replay := func(events ...*Event) Digest {
+		value := make(Digest, AlgorithmSha256.Size())
+		for _, e := range events {
+			value = extendDigest(AlgorithmSha256, value, e.Digests[AlgorithmSha256])
+		}
+		return value
+	}
+
+	for _, data := range []struct {
+		desc     string
+		observed Digest
+		kind     DivergenceKind
+		event    *Event
+	}{
+		{desc: "None", observed: replay(events[:4]...), kind: DivergenceNone},
+		{desc: "Truncated", observed: replay(events[:2]...), kind: DivergenceTruncated, event: events[2]},
+		{desc: "TruncatedInitial", observed: replay(), kind: DivergenceTruncated, event: events[0]},
+		{desc: "UnmeasuredEvent", observed: replay(events[0], events[2], events[3]), kind: DivergenceUnmeasuredEvent,
+			event: events[1]},
+		{desc: "Unknown", observed: AlgorithmSha256.hash([]byte("foo")), kind: DivergenceUnknown},
+	} {
+		t.Run(data.desc, func(t *testing.T) {
+			d, err := log.FindDivergence(AlgorithmSha256, 4, data.observed)
+			if err != nil {
+				t.Fatalf("FindDivergence failed: %v", err)
+			}
+			if d.PCRIndex != 4 || d.Algorithm != AlgorithmSha256 {
+				t.Errorf("Unexpected PCR or algorithm: %s", d)
+			}
+			if d.Kind != data.kind {
+				t.Errorf("Unexpected kind: %v", d.Kind)
+			}
+			if d.Event != data.event {
+				t.Errorf("Unexpected event: %s", d)
+			}
+		})
+	}
+
+	if _, err := log.FindDivergence(AlgorithmSha1, 4, nil); err == nil ||
+		err.Error() != "log does not contain digests for algorithm SHA-1" {
+		t.Errorf("Unexpected error: %v", err)
+	}
+}
@@ -0,0 +1,72 @@
+// Copyright 2019 Canonical Ltd.
+// Licensed under the LGPLv3 with static-linking exception.
+// See LICENCE file for details.
+
+package tcglog
+
+import (
+	"bytes"
+	"io"
+)
+
+var secureLaunchEventTypeNames = map[EventType]string{
+	EventTypeSecureLaunch:      "TXT_EVTYPE_SLAUNCH",
+	EventTypeSecureLaunchStart: "TXT_EVTYPE_SLAUNCH_START",
+	EventTypeSecureLaunchEnd:   "TXT_EVTYPE_SLAUNCH_END",
+}
+
+// isDRTMPCR indicates whether the specified PCR is one of the PCRs that are reset by a dynamic launch (PCRs 17 to 22). On AMD
+// platforms, SKINIT measures the secure loader block to PCR 17, and the secure loader measures the components it launches to
+// PCR 17 and their configuration to PCR 18.
+func isDRTMPCR(pcr PCRIndex) bool {
+	return pcr >= 17 && pcr <= 22
+}
+
+// SecureLaunchEventData is the event data associated with an event recorded by a secure launch loader, such as the AMD secure
+// loader started by SKINIT. The event data is an ASCII description of the measured component.
+type SecureLaunchEventData struct {
+	data []byte
+	Str  string
+}
+
+func (e *SecureLaunchEventData) String() string {
+	return e.Str
+}
+
+func (e *SecureLaunchEventData) Bytes() []byte {
+	return e.data
+}
+
+// EncodeTo encodes this event data in to the form in which it appears in the event log. If Str hasn't been modified since the
+// event data was decoded, the original bytes are written. Otherwise, Str is written as a NUL terminated ASCII string.
+func (e *SecureLaunchEventData) EncodeTo(w io.Writer) error {
+	if e.data != nil && e.Str == string(bytes.TrimRight(e.data, "\x00")) {
+		_, err := w.Write(e.data)
+		return err
+	}
+	_, err := w.Write(encodePrintableString(e.Str, false))
+	return err
+}
+
+func decodeEventDataSecureLaunch(eventType EventType, data []byte) EventData {
+	if _, ok := secureLaunchEventTypeNames[eventType]; !ok {
+		return nil
+	}
+	if !isPrintableASCII(data) {
+		return nil
+	}
+	return &SecureLaunchEventData{data: data, Str: string(bytes.TrimRight(data, "\x00"))}
+}
+
+// SecureLaunchMeasurements returns the events in this log that were recorded by a secure launch loader to the PCRs that are
+// reset by a dynamic launch, indexed by PCR.
+func (l *Log) SecureLaunchMeasurements() map[PCRIndex][]*Event {
+	out := make(map[PCRIndex][]*Event)
+	for _, e := range l.Events {
+		if _, ok := e.Data.(*SecureLaunchEventData); !ok {
+			continue
+		}
+		out[e.PCRIndex] = append(out[e.PCRIndex], e)
+	}
+	return out
+}
@@ -0,0 +1,109 @@
+// Copyright 2019 Canonical Ltd.
+// Licensed under the LGPLv3 with static-linking exception.
+// See LICENCE file for details.
+
+package tcglog
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestDecodeEventDataSecureLaunch(t *testing.T) {
+	for _, data := range []struct {
+		desc      string
+		pcr       PCRIndex
+		eventType EventType
+		data      []byte
+		str       string
+	}{
+		{
+			desc:      "Start",
+			pcr:       17,
+			eventType: EventTypeSecureLaunchStart,
+			data:      []byte("SKINIT\x00"),
+			str:       "SKINIT",
+		},
+		{
+			desc:      "Kernel",
+			pcr:       17,
+			eventType: EventTypeSecureLaunch,
+			data:      []byte("Measured kernel"),
+			str:       "Measured kernel",
+		},
+		{
+			desc:      "Config",
+			pcr:       18,
+			eventType: EventTypeSecureLaunch,
+			data:      []byte("Measured boot parameters\x00"),
+			str:       "Measured boot parameters",
+		},
+	} {
+		t.Run(data.desc, func(t *testing.T) {
+			d, ok := DecodeEventData(data.pcr, data.eventType, DigestMap{}, data.data, nil).(*SecureLaunchEventData)
+			if !ok {
+				t.Fatalf("Unexpected event data type")
+			}
+			if d.String() != data.str {
+				t.Errorf("Unexpected string: %s", d)
+			}
+		})
+	}
+
+	t.Run("NotDRTMPCR", func(t *testing.T) {
+		if _, ok := DecodeEventData(7, EventTypeSecureLaunch, DigestMap{}, []byte("foo"), nil).(*SecureLaunchEventData); ok {
+			t.Errorf("Unexpected event data type")
+		}
+	})
+
+	t.Run("Binary", func(t *testing.T) {
+		if _, ok := DecodeEventData(17, EventTypeSecureLaunch, DigestMap{}, []byte{0x01, 0xff}, nil).(*SecureLaunchEventData); ok {
+			t.Errorf("Unexpected event data type")
+		}
+	})
+
+	if EventTypeSecureLaunchEnd.String() != "TXT_EVTYPE_SLAUNCH_END" {
+		t.Errorf("Unexpected event type name: %s", EventTypeSecureLaunchEnd)
+	}
+}
+
+func TestLogSecureLaunchMeasurements(t *testing.T) {
+	log := &Log{Events: []*Event{
+		makeTestEvent(17, EventTypeSecureLaunchStart, []byte("SKINIT")),
+		makeTestEvent(0, EventTypeSeparator, []byte{0, 0, 0, 0}),
+		makeTestEvent(17, EventTypeSecureLaunch, []byte("Measured kernel")),
+		makeTestEvent(18, EventTypeSecureLaunch, []byte("Measured boot parameters")),
+	}}
+	m := log.SecureLaunchMeasurements()
+	if len(m) != 2 || len(m[17]) != 2 || len(m[18]) != 1 {
+		t.Errorf("Unexpected measurements: %v", m)
+	}
+}
+
+func TestSecureLaunchEventDataEncode(t *testing.T) {
+	d, ok := DecodeEventData(17, EventTypeSecureLaunch, DigestMap{}, []byte("SLB"), nil).(*SecureLaunchEventData)
+	if !ok {
+		t.Fatalf("Unexpected event data type")
+	}
+
+	var buf bytes.Buffer
+	if err := d.EncodeTo(&buf); err != nil {
+		t.Fatalf("EncodeTo failed: %v", err)
+	}
+	if buf.String() != "SLB" {
+		t.Errorf("Unexpected encoding of unmodified event data: %q", buf.String())
+	}
+
+	d.Str = "Linux kernel"
+	buf.Reset()
+	if err := d.EncodeTo(&buf); err != nil {
+		t.Fatalf("EncodeTo failed: %v", err)
+	}
+	d2, ok := DecodeEventData(17, EventTypeSecureLaunch, DigestMap{}, buf.Bytes(), nil).(*SecureLaunchEventData)
+	if !ok {
+		t.Fatalf("Cannot decode modified event data")
+	}
+	if d2.Str != "Linux kernel" {
+		t.Errorf("Unexpected modified event data: %s", d2)
+	}
+}
@@ -0,0 +1,307 @@
+// Copyright 2019 Canonical Ltd.
+// Licensed under the LGPLv3 with static-linking exception.
+// See LICENCE file for details.
+
+package tcglog
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/asn1"
+	"fmt"
+	"io"
+	"math/big"
+	"sort"
+	"time"
+
+	"golang.org/x/xerrors"
+
+	"github.com/canonical/tcglog-parser/internal/cbor"
+)
+
+const (
+	// EATProfileEvidence is the profile of an evidence token written by Log.WriteEAT.
+	EATProfileEvidence = "urn:tcglog-parser:eat:evidence"
+
+	// EATProfileAttestationResult is the profile of an attestation result token written by Log.WriteEAT.
+	EATProfileAttestationResult = "urn:tcglog-parser:eat:attestation-result"
+)
+
+// https://www.rfc-editor.org/rfc/rfc9711.html
+//  (Section 10: CBOR claim keys)
+const (
+	eatClaimIssuedAt = 6
+	eatClaimNonce    = 10
+	eatClaimOEMBoot  = 262
+	eatClaimProfile  = 265
+
+	cborTagUCCS = 601
+
+	coseHeaderAlg = 1
+	coseHeaderKID = 4
+
+	coseAlgES256 = -7
+	coseAlgEdDSA = -8
+	coseAlgES384 = -35
+	coseAlgES512 = -36
+	coseAlgPS256 = -37
+)
+
+// EATOptions allows the contents of a token written by Log.WriteEAT to be customized.
+type EATOptions struct {
+	Nonce    []byte    // The nonce supplied by the verifier, if any
+	IssuedAt time.Time // The time at which the token was issued, which defaults to the current time
+
+	// Signer is used to sign the token as a COSE_Sign1 message. ECDSA (P-256, P-384 and P-521), Ed25519 and RSA (with PSS) keys
+	// are supported. If this is nil, the token is written as an unprotected CWT claims set.
+	Signer crypto.Signer
+	KeyID  []byte // The identifier of the signing key, which is included in the unprotected header if not empty
+
+	// Result is the result of verifying the log against reference values. If this is set, the token is an attestation result
+	// rather than evidence.
+	Result *CoRIMResult
+}
+
+// eatDigestAlgorithms are the identifiers of digest algorithms from the IANA Named Information Hash Algorithm registry, as used by
+// CoRIM (see corimDigestAlgorithms). SHA-1 has no numeric identifier.
+var eatDigestAlgorithms = map[AlgorithmId]interface{}{
+	AlgorithmSha1:   "sha-1",
+	AlgorithmSha256: uint64(1),
+	AlgorithmSha384: uint64(7),
+	AlgorithmSha512: uint64(8),
+}
+
+// eatDigests encodes digests in the same form as CoRIM and CoMID.
+func eatDigests(digests DigestMap) []interface{} {
+	var algs []AlgorithmId
+	for alg := range digests {
+		if _, ok := eatDigestAlgorithms[alg]; ok {
+			algs = append(algs, alg)
+		}
+	}
+	sort.Slice(algs, func(i, j int) bool { return algs[i] < algs[j] })
+
+	out := []interface{}{}
+	for _, alg := range algs {
+		out = append(out, []interface{}{eatDigestAlgorithms[alg], []byte(digests[alg])})
+	}
+	return out
+}
+
+func eatBootChain(node *BootChainNode, parent int, out []interface{}) []interface{} {
+	index := parent
+	if node.Event != nil {
+		var authorities []interface{}
+		for _, a := range node.Authorities {
+			if a.Signature != nil {
+				authorities = append(authorities, shortSignatureString(a.Signature))
+			}
+		}
+
+		image := cbor.Map{
+			{Key: "pcr", Value: uint64(node.Event.PCRIndex)},
+			{Key: "digests", Value: eatDigests(node.Event.Digests)}}
+		if node.Path != "" {
+			image = append(image, cbor.MapEntry{Key: "path", Value: node.Path})
+		}
+		if parent >= 0 {
+			image = append(image, cbor.MapEntry{Key: "loaded-by", Value: uint64(parent)})
+		}
+		if len(authorities) > 0 {
+			image = append(image, cbor.MapEntry{Key: "authorized-by", Value: authorities})
+		}
+		index = len(out)
+		out = append(out, image)
+	}
+	for _, c := range node.Children {
+		out = eatBootChain(c, index, out)
+	}
+	return out
+}
+
+func (l *Log) eatClaims(options *EATOptions) (cbor.Map, error) {
+	issuedAt := options.IssuedAt
+	if issuedAt.IsZero() {
+		issuedAt = time.Now()
+	}
+
+	claims := cbor.Map{
+		{Key: uint64(eatClaimProfile), Value: EATProfileEvidence},
+		{Key: uint64(eatClaimIssuedAt), Value: uint64(issuedAt.Unix())}}
+	if options.Result != nil {
+		claims[0].Value = EATProfileAttestationResult
+	}
+	if len(options.Nonce) > 0 {
+		claims = append(claims, cbor.MapEntry{Key: uint64(eatClaimNonce), Value: options.Nonce})
+	}
+
+	policy := l.SecureBootPolicy()
+	if _, ok := policy.States["SecureBoot"]; ok {
+		claims = append(claims, cbor.MapEntry{Key: uint64(eatClaimOEMBoot), Value: policy.Enabled()})
+	}
+	states := cbor.Map{}
+	for _, name := range secureBootStateVariables {
+		if value, ok := policy.States[name]; ok {
+			states = append(states, cbor.MapEntry{Key: name, Value: value})
+		}
+	}
+	claims = append(claims, cbor.MapEntry{Key: "secure-boot", Value: states})
+
+	values := l.ReplayAllPCRs()
+	var pcrs []PCRIndex
+	for pcr := range values {
+		pcrs = append(pcrs, pcr)
+	}
+	sort.Slice(pcrs, func(i, j int) bool { return pcrs[i] < pcrs[j] })
+	pcrClaims := cbor.Map{}
+	for _, pcr := range pcrs {
+		pcrClaims = append(pcrClaims, cbor.MapEntry{Key: uint64(pcr), Value: eatDigests(values[pcr])})
+	}
+	claims = append(claims, cbor.MapEntry{Key: "pcrs", Value: pcrClaims})
+
+	chain, err := l.BootChain("")
+	if err != nil {
+		return nil, xerrors.Errorf("cannot reconstruct boot chain: %w", err)
+	}
+	claims = append(claims, cbor.MapEntry{Key: "boot-chain", Value: eatBootChain(chain, -1, []interface{}{})})
+
+	if options.Result != nil {
+		status := "affirming"
+		if !options.Result.Satisfied() {
+			status = "contraindicated"
+		}
+		var results []interface{}
+		for _, c := range options.Result.Claims {
+			results = append(results, cbor.Map{
+				{Key: "claim", Value: c.Value.String()},
+				{Key: "satisfied", Value: c.Satisfied}})
+		}
+		claims = append(claims,
+			cbor.MapEntry{Key: "ear.status", Value: status},
+			cbor.MapEntry{Key: "reference-values", Value: results})
+	}
+
+	return claims, nil
+}
+
+func encodeCBOR(v interface{}) ([]byte, error) {
+	var b bytes.Buffer
+	enc := cbor.NewEncoder(&b)
+	enc.WriteValue(v)
+	if err := enc.Err(); err != nil {
+		return nil, err
+	}
+	return b.Bytes(), nil
+}
+
+// coseSign1 signs the supplied payload with signer, returning a COSE_Sign1 structure.
+//
+// https://www.rfc-editor.org/rfc/rfc9052.html
+//  (Section 4.4: Signing and Verification Process)
+func coseSign1(signer crypto.Signer, keyID, payload []byte) (*cbor.Tag, error) {
+	var alg int64
+	var opts crypto.SignerOpts
+	var size int
+	switch k := signer.Public().(type) {
+	case *ecdsa.PublicKey:
+		size = (k.Curve.Params().BitSize + 7) / 8
+		switch k.Curve.Params().BitSize {
+		case 256:
+			alg, opts = coseAlgES256, crypto.SHA256
+		case 384:
+			alg, opts = coseAlgES384, crypto.SHA384
+		case 521:
+			alg, opts = coseAlgES512, crypto.SHA512
+		default:
+			return nil, fmt.Errorf("unsupported curve %s", k.Curve.Params().Name)
+		}
+	case ed25519.PublicKey:
+		alg, opts = coseAlgEdDSA, crypto.Hash(0)
+	case *rsa.PublicKey:
+		alg, opts = coseAlgPS256, &rsa.PSSOptions{SaltLength: rsa.PSSSaltLengthEqualsHash, Hash: crypto.SHA256}
+	default:
+		return nil, fmt.Errorf("unsupported key type %T", k)
+	}
+
+	protected, err := encodeCBOR(cbor.Map{{Key: uint64(coseHeaderAlg), Value: alg}})
+	if err != nil {
+		return nil, err
+	}
+	toBeSigned, err := encodeCBOR([]interface{}{"Signature1", protected, []byte{}, payload})
+	if err != nil {
+		return nil, err
+	}
+
+	digest := toBeSigned
+	if h := opts.HashFunc(); h != crypto.Hash(0) {
+		hash := h.New()
+		hash.Write(toBeSigned)
+		digest = hash.Sum(nil)
+	}
+	sig, err := signer.Sign(rand.Reader, digest, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	if size > 0 {
+		// COSE encodes ECDSA signatures as the concatenation of r and s rather than as a DER encoded structure.
+		var ecSig struct {
+			R, S *big.Int
+		}
+		if _, err := asn1.Unmarshal(sig, &ecSig); err != nil {
+			return nil, xerrors.Errorf("cannot decode ECDSA signature: %w", err)
+		}
+		sig = make([]byte, 2*size)
+		ecSig.R.FillBytes(sig[:size])
+		ecSig.S.FillBytes(sig[size:])
+	}
+
+	unprotected := cbor.Map{}
+	if len(keyID) > 0 {
+		unprotected = append(unprotected, cbor.MapEntry{Key: uint64(coseHeaderKID), Value: keyID})
+	}
+	return &cbor.Tag{Number: cborTagCOSESign1, Content: []interface{}{protected, unprotected, payload, sig}}, nil
+}
+
+// WriteEAT writes an IETF Entity Attestation Token (RFC 9711) to w that contains claims derived from this log, for
+// interoperability with RATS verifiers. The token contains the standard eat_profile, iat, eat_nonce and oemboot claims (the
+// latter indicating whether secure boot is enabled), and the following claims with text labels. The "secure-boot" claim is a map
+// of the measured secure boot state variables to their values. The "pcrs" claim is a map of each PCR to its values obtained by
+// replaying the log, in the form [[alg, digest]...] used by CoRIM. The "boot-chain" claim is an array of the images that were
+// loaded (see Log.BootChain), each with its PCR, digests, path, the index of the image that loaded it and the subjects of the
+// authorities that authorized it.
+//
+// If options.Result is set, the token is an attestation result that additionally contains an "ear.status" claim ("affirming" or
+// "contraindicated") and a "reference-values" claim listing whether each reference value claim was satisfied.
+//
+// The token is a COSE_Sign1 message if options.Signer is set, or an unprotected CWT claims set otherwise. The supplied options
+// may be nil, in which case the defaults are used.
+func (l *Log) WriteEAT(w io.Writer, options *EATOptions) error {
+	if options == nil {
+		options = &EATOptions{}
+	}
+
+	claims, err := l.eatClaims(options)
+	if err != nil {
+		return err
+	}
+
+	var token interface{} = &cbor.Tag{Number: cborTagUCCS, Content: claims}
+	if options.Signer != nil {
+		payload, err := encodeCBOR(claims)
+		if err != nil {
+			return err
+		}
+		if token, err = coseSign1(options.Signer, options.KeyID, payload); err != nil {
+			return xerrors.Errorf("cannot sign token: %w", err)
+		}
+	}
+
+	enc := cbor.NewEncoder(w)
+	enc.WriteValue(token)
+	return enc.Err()
+}
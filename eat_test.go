@@ -0,0 +1,171 @@
+// Copyright 2019 Canonical Ltd.
+// Licensed under the LGPLv3 with static-linking exception.
+// See LICENCE file for details.
+
+package tcglog
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/canonical/tcglog-parser/internal/cbor"
+)
+
+func TestWriteEAT(t *testing.T) {
+	log := makeSecureBootPolicyTestLog(t, 1)
+	var b bytes.Buffer
+	if err := log.WriteEAT(&b, &EATOptions{Nonce: []byte("nonce"), IssuedAt: time.Unix(1577934245, 0)}); err != nil {
+		t.Fatalf("WriteEAT failed: %v", err)
+	}
+
+	v, err := cbor.Decode(&b)
+	if err != nil {
+		t.Fatalf("Decode failed: %v", err)
+	}
+	tag, ok := v.(*cbor.Tag)
+	if !ok || tag.Number != 601 {
+		t.Fatalf("Unexpected token: %v", v)
+	}
+	claims := tag.Content.(cbor.Map)
+
+	for _, expected := range []struct {
+		key   interface{}
+		value interface{}
+	}{
+		{uint64(265), EATProfileEvidence},
+		{uint64(6), uint64(1577934245)},
+		{uint64(262), true},
+	} {
+		if value, _ := claims.Get(expected.key); value != expected.value {
+			t.Errorf("Unexpected value for claim %v: %v", expected.key, value)
+		}
+	}
+	if nonce, _ := claims.Get(uint64(10)); !bytes.Equal(nonce.([]byte), []byte("nonce")) {
+		t.Errorf("Unexpected nonce: %v", nonce)
+	}
+	if _, ok := claims.Get("ear.status"); ok {
+		t.Errorf("Evidence shouldn't contain an attestation result")
+	}
+
+	states, _ := claims.Get("secure-boot")
+	if secureBoot, _ := states.(cbor.Map).Get("SecureBoot"); secureBoot != true {
+		t.Errorf("Unexpected secure boot state: %v", states)
+	}
+
+	pcrs, _ := claims.Get("pcrs")
+	pcr7, _ := pcrs.(cbor.Map).Get(uint64(7))
+	digest := pcr7.([]interface{})[0].([]interface{})
+	if digest[0] != uint64(1) || !bytes.Equal(digest[1].([]byte), log.ReplayAllPCRs()[7][AlgorithmSha256]) {
+		t.Errorf("Unexpected PCR 7 value: %v", digest)
+	}
+
+	chain, _ := claims.Get("boot-chain")
+	images := chain.([]interface{})
+	if len(images) != 2 {
+		t.Fatalf("Unexpected number of images: %d", len(images))
+	}
+	shim, grub := images[0].(cbor.Map), images[1].(cbor.Map)
+	if path, _ := shim.Get("path"); path != "/EFI/ubuntu/shimx64.efi" {
+		t.Errorf("Unexpected path: %v", path)
+	}
+	if _, ok := shim.Get("loaded-by"); ok {
+		t.Errorf("Shim should be loaded by the platform firmware")
+	}
+	if authorities, _ := shim.Get("authorized-by"); len(authorities.([]interface{})) != 1 ||
+		authorities.([]interface{})[0] != "X509: \"CN=Test UEFI CA\"" {
+		t.Errorf("Unexpected authorities: %v", authorities)
+	}
+	if parent, _ := grub.Get("loaded-by"); parent != uint64(0) {
+		t.Errorf("Unexpected parent: %v", parent)
+	}
+}
+
+func TestWriteEATDefaultOptions(t *testing.T) {
+	log := makeSecureBootPolicyTestLog(t, 1)
+	var b bytes.Buffer
+	if err := log.WriteEAT(&b, nil); err != nil {
+		t.Fatalf("WriteEAT failed: %v", err)
+	}
+
+	v, err := cbor.Decode(&b)
+	if err != nil {
+		t.Fatalf("Decode failed: %v", err)
+	}
+	tag, ok := v.(*cbor.Tag)
+	if !ok || tag.Number != 601 {
+		t.Fatalf("Unexpected token: %v", v)
+	}
+	if profile, _ := tag.Content.(cbor.Map).Get(uint64(265)); profile != EATProfileEvidence {
+		t.Errorf("Unexpected profile: %v", profile)
+	}
+}
+
+func TestWriteEATSigned(t *testing.T) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("GenerateKey failed: %v", err)
+	}
+
+	log := makeSBOMTestLog()
+	c, err := ReadCoRIM(bytes.NewReader(makeTestCoRIM(t, log)))
+	if err != nil {
+		t.Fatalf("ReadCoRIM failed: %v", err)
+	}
+
+	var b bytes.Buffer
+	if err := log.WriteEAT(&b, &EATOptions{Signer: key, KeyID: []byte("key1"), Result: c.Verify(log)}); err != nil {
+		t.Fatalf("WriteEAT failed: %v", err)
+	}
+
+	v, err := cbor.Decode(&b)
+	if err != nil {
+		t.Fatalf("Decode failed: %v", err)
+	}
+	tag, ok := v.(*cbor.Tag)
+	if !ok || tag.Number != 18 {
+		t.Fatalf("Unexpected token: %v", v)
+	}
+	sign1 := tag.Content.([]interface{})
+	protected, payload, sig := sign1[0].([]byte), sign1[2].([]byte), sign1[3].([]byte)
+	if !bytes.Equal(protected, []byte{0xa1, 0x01, 0x26}) {
+		t.Errorf("Unexpected protected header: %x", protected)
+	}
+	if kid, _ := sign1[1].(cbor.Map).Get(uint64(4)); !bytes.Equal(kid.([]byte), []byte("key1")) {
+		t.Errorf("Unexpected key ID: %v", kid)
+	}
+
+	toBeSigned := encodeTestCBOR(t, []interface{}{"Signature1", protected, []byte{}, payload})
+	h := sha256.Sum256(toBeSigned)
+	if len(sig) != 64 || !ecdsa.Verify(&key.PublicKey, h[:], new(big.Int).SetBytes(sig[:32]), new(big.Int).SetBytes(sig[32:])) {
+		t.Errorf("Invalid signature")
+	}
+
+	v, err = cbor.Decode(bytes.NewReader(payload))
+	if err != nil {
+		t.Fatalf("Decode failed: %v", err)
+	}
+	claims := v.(cbor.Map)
+	if profile, _ := claims.Get(uint64(265)); profile != EATProfileAttestationResult {
+		t.Errorf("Unexpected profile: %v", profile)
+	}
+	if status, _ := claims.Get("ear.status"); status != "contraindicated" {
+		t.Errorf("Unexpected status: %v", status)
+	}
+	results, _ := claims.Get("reference-values")
+	if len(results.([]interface{})) != 4 {
+		t.Fatalf("Unexpected reference value results: %v", results)
+	}
+	first := results.([]interface{})[0].(cbor.Map)
+	if claim, _ := first.Get("claim"); claim != "PCR 0 (ACME Roadrunner)" {
+		t.Errorf("Unexpected claim: %v", claim)
+	}
+	if satisfied, _ := first.Get("satisfied"); satisfied != true {
+		t.Errorf("Expected the claim to be satisfied")
+	}
+}
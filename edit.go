@@ -0,0 +1,135 @@
+// Copyright 2019 Canonical Ltd.
+// Licensed under the LGPLv3 with static-linking exception.
+// See LICENCE file for details.
+
+package tcglog
+
+import (
+	"errors"
+	"fmt"
+)
+
+// ShallowCopy returns a copy of this log that can be edited without modifying the original. The events and their digests are
+// copied, but the event data is shared with the original log. Modifying the exported fields of an event's data therefore modifies
+// both logs. To change the data of an event in the copy, assign new data to the event's Data field or use SetEventData.
+func (l *Log) ShallowCopy() *Log {
+	out := &Log{
+		Spec:       l.Spec,
+		Algorithms: make(AlgorithmIdList, len(l.Algorithms)),
+		Events:     make([]*Event, 0, len(l.Events))}
+	copy(out.Algorithms, l.Algorithms)
+
+	for _, e := range l.Events {
+		c := *e
+		c.Digests = make(DigestMap)
+		for alg, digest := range e.Digests {
+			c.Digests[alg] = append(Digest(nil), digest...)
+		}
+		out.Events = append(out.Events, &c)
+	}
+
+	return out
+}
+
+// ReindexEvents updates the Index field of every event in this log so that it corresponds to the position of the event within
+// the sequence of events for its PCR. This is done automatically by the other editing functions, but must be called explicitly
+// after modifying the Events field directly.
+func (l *Log) ReindexEvents() {
+	indices := make(map[PCRIndex]uint)
+	for _, e := range l.Events {
+		e.Index = indices[e.PCRIndex]
+		indices[e.PCRIndex]++
+	}
+}
+
+func (l *Log) checkEvent(event *Event) error {
+	if !isPCRIndexInRange(event.PCRIndex) {
+		return fmt.Errorf("out-of-range PCR index (%d)", event.PCRIndex)
+	}
+	if event.Data == nil {
+		return errors.New("event has no data")
+	}
+	for _, alg := range l.Algorithms {
+		digest, ok := event.Digests[alg]
+		if !ok {
+			return fmt.Errorf("missing digest for algorithm %v", alg)
+		}
+		if len(digest) != alg.Size() {
+			return fmt.Errorf("invalid digest length for algorithm %v (%d)", alg, len(digest))
+		}
+	}
+	return nil
+}
+
+func (l *Log) checkIndex(i int) error {
+	if i < 0 || i >= len(l.Events) {
+		return fmt.Errorf("event index %d out of range", i)
+	}
+	return nil
+}
+
+// InsertEvent inserts the supplied event in to this log so that it is at position i in the Events field. The event must have a
+// digest for each of the log's algorithms. Events can be created with NewEvent.
+func (l *Log) InsertEvent(i int, event *Event) error {
+	if i < 0 || i > len(l.Events) {
+		return fmt.Errorf("event index %d out of range", i)
+	}
+	if err := l.checkEvent(event); err != nil {
+		return err
+	}
+
+	l.Events = append(l.Events, nil)
+	copy(l.Events[i+1:], l.Events[i:])
+	l.Events[i] = event
+	l.ReindexEvents()
+	l.ClassifyBootStages()
+	return nil
+}
+
+// RemoveEvent removes the event at position i in the Events field from this log.
+func (l *Log) RemoveEvent(i int) error {
+	if err := l.checkIndex(i); err != nil {
+		return err
+	}
+
+	l.Events = append(l.Events[:i], l.Events[i+1:]...)
+	l.ReindexEvents()
+	l.ClassifyBootStages()
+	return nil
+}
+
+// ReplaceEvent replaces the event at position i in the Events field of this log with the supplied event. The event must have a
+// digest for each of the log's algorithms.
+func (l *Log) ReplaceEvent(i int, event *Event) error {
+	if err := l.checkIndex(i); err != nil {
+		return err
+	}
+	if err := l.checkEvent(event); err != nil {
+		return err
+	}
+
+	l.Events[i] = event
+	l.ReindexEvents()
+	l.ClassifyBootStages()
+	return nil
+}
+
+// SetEventData replaces the data of the event at position i in the Events field of this log with the supplied data, which is
+// decoded using the supplied options. The digests are recomputed from the new data in the same way as NewEvent, and an error is
+// returned if this isn't possible for the type of event. To change the data without changing the digests (eg, to simulate a
+// log that is inconsistent with what was measured), use ReplaceEvent.
+func (l *Log) SetEventData(i int, data []byte, options *LogOptions) error {
+	if err := l.checkIndex(i); err != nil {
+		return err
+	}
+
+	orig := l.Events[i]
+	event, err := newEvent(orig.PCRIndex, orig.EventType, data, l.Algorithms, options)
+	if err != nil {
+		return err
+	}
+	event.Index = orig.Index
+	l.Events[i] = event
+	l.ClassifyBootStages()
+	return nil
+}
@@ -0,0 +1,79 @@
+// Copyright 2019 Canonical Ltd.
+// Licensed under the LGPLv3 with static-linking exception.
+// See LICENCE file for details.
+
+package tcglog
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestLogEditing(t *testing.T) {
+	algs := AlgorithmIdList{AlgorithmSha1, AlgorithmSha256}
+	b, err := NewLogBuilder(algs, nil)
+	if err != nil {
+		t.Fatalf("NewLogBuilder failed: %v", err)
+	}
+	b.AddEvent(4, EventTypeEFIAction, []byte("Calling EFI Application from Boot Option"))
+	b.AddEvent(4, EventTypeSeparator, []byte{0, 0, 0, 0})
+	b.AddEvent(7, EventTypeSeparator, []byte{0, 0, 0, 0})
+
+	orig := b.Log()
+	log := orig.ShallowCopy()
+	if log.Events[0] == orig.Events[0] || log.Events[0].Data != orig.Events[0].Data {
+		t.Errorf("ShallowCopy should copy the events and share their data")
+	}
+
+	e, err := NewEvent(4, EventTypeEFIAction, []byte("foo"), algs)
+	if err != nil {
+		t.Fatalf("NewEvent failed: %v", err)
+	}
+	if err := log.InsertEvent(1, e); err != nil {
+		t.Fatalf("InsertEvent failed: %v", err)
+	}
+	if log.Events[1] != e || e.Index != 0 || log.Events[2].Index != 1 || log.Events[3].Index != 2 {
+		t.Errorf("Unexpected events after InsertEvent")
+	}
+
+	if err := log.RemoveEvent(2); err != nil {
+		t.Fatalf("RemoveEvent failed: %v", err)
+	}
+	if len(log.Events) != 4 || log.Events[2].EventType != EventTypeSeparator || log.Events[2].Index != 1 {
+		t.Errorf("Unexpected events after RemoveEvent")
+	}
+
+	if err := log.SetEventData(1, []byte("bar"), nil); err != nil {
+		t.Fatalf("SetEventData failed: %v", err)
+	}
+	if !bytes.Equal(log.Events[1].Digests[AlgorithmSha256], AlgorithmSha256.hash([]byte("bar"))) {
+		t.Errorf("Unexpected digest after SetEventData")
+	}
+
+	bad := &Event{PCRIndex: 7, EventType: EventTypeSeparator, Digests: DigestMap{AlgorithmSha1: make(Digest, 20)},
+		Data: log.Events[3].Data}
+	if err := log.ReplaceEvent(3, bad); err == nil {
+		t.Errorf("ReplaceEvent should fail for an event with a missing digest")
+	}
+	if err := log.RemoveEvent(10); err == nil {
+		t.Errorf("RemoveEvent should fail for an out-of-range index")
+	}
+
+	// The original log should be unmodified.
+	if len(orig.Events) != 4 || orig.Events[1].EventType != EventTypeEFIAction ||
+		!bytes.Equal(orig.Events[1].Data.Bytes(), []byte("Calling EFI Application from Boot Option")) {
+		t.Errorf("Original log was modified")
+	}
+
+	var w bytes.Buffer
+	if err := WriteLog(&w, log); err != nil {
+		t.Fatalf("WriteLog failed: %v", err)
+	}
+	parsed, err := ParseLog(&w, &LogOptions{})
+	if err != nil {
+		t.Fatalf("ParseLog failed: %v", err)
+	}
+	if len(parsed.Events) != 4 || string(parsed.Events[1].Data.Bytes()) != "bar" {
+		t.Errorf("Unexpected re-serialized log")
+	}
+}
@@ -10,6 +10,9 @@ import (
 	"errors"
 	"fmt"
 	"io"
+	"io/ioutil"
+	"net"
+	"strings"
 	"unicode/utf16"
 	"unicode/utf8"
 
@@ -137,60 +140,82 @@ func parseEFI_2_SpecIdEvent(r io.Reader, eventData *SpecIdEvent) error {
 	return nil
 }
 
-// startupLocalityEventData is the event data for a StartupLocality EV_NO_ACTION event.
-type startupLocalityEventData struct {
-	data      []byte
-	signature string
-	locality  uint8
+// StartupLocalityEventData is the event data for a StartupLocality EV_NO_ACTION event.
+type StartupLocalityEventData struct {
+	data            []byte
+	signature       string
+	StartupLocality uint8 // The locality from which TPM2_Startup was executed
 }
 
-func (e *startupLocalityEventData) String() string {
-	return fmt.Sprintf("EfiStartupLocalityEvent{ StartupLocality: %d }", e.locality)
+func (e *StartupLocalityEventData) String() string {
+	return fmt.Sprintf("EfiStartupLocalityEvent{ StartupLocality: %d }", e.StartupLocality)
 }
 
-func (e *startupLocalityEventData) Bytes() []byte {
+func (e *StartupLocalityEventData) Bytes() []byte {
 	return e.data
 }
 
-func (e *startupLocalityEventData) Type() NoActionEventType {
+// EncodeTo encodes this event data in to the form in which it appears in the event log.
+func (e *StartupLocalityEventData) EncodeTo(w io.Writer) error {
+	var sig [16]byte
+	copy(sig[:], e.signature)
+	_, err := w.Write(append(sig[:], e.StartupLocality))
+	return err
+}
+
+func (e *StartupLocalityEventData) Type() NoActionEventType {
 	return StartupLocality
 }
 
-func (e *startupLocalityEventData) Signature() string {
+func (e *StartupLocalityEventData) Signature() string {
 	return e.signature
 }
 
 // https://trustedcomputinggroup.org/wp-content/uploads/TCG_PCClientSpecPlat_TPM_2p0_1p04_pub.pdf
 //  (section 9.4.5.3 "Startup Locality Event")
-func decodeStartupLocalityEvent(r io.Reader, signature string, data []byte) (*startupLocalityEventData, error) {
+func decodeStartupLocalityEvent(r io.Reader, signature string, data []byte) (*StartupLocalityEventData, error) {
 	var locality uint8
 	if err := binary.Read(r, binary.LittleEndian, &locality); err != nil {
 		return nil, err
 	}
 
-	return &startupLocalityEventData{data: data, signature: signature, locality: locality}, nil
+	return &StartupLocalityEventData{data: data, signature: signature, StartupLocality: locality}, nil
 }
 
-type bimReferenceManifestEventData struct {
-	data      []byte
-	signature string
-	vendorId  uint32
-	guid      EFIGUID
+// SP800_155_PlatformIdEventData is the event data for a SP800-155 Event EV_NO_ACTION event, which identifies the reference
+// manifest for the platform.
+type SP800_155_PlatformIdEventData struct {
+	data                  []byte
+	signature             string
+	VendorId              uint32  // The vendor ID of the platform manufacturer
+	ReferenceManifestGuid EFIGUID // The identifier of the reference manifest for the platform
 }
 
-func (e *bimReferenceManifestEventData) String() string {
-	return fmt.Sprintf("Sp800_155_PlatformId_Event{ VendorId: %d, ReferenceManifestGuid: %s }", e.vendorId, &e.guid)
+func (e *SP800_155_PlatformIdEventData) String() string {
+	return fmt.Sprintf("Sp800_155_PlatformId_Event{ VendorId: %d, ReferenceManifestGuid: %s }", e.VendorId, &e.ReferenceManifestGuid)
 }
 
-func (e *bimReferenceManifestEventData) Bytes() []byte {
+func (e *SP800_155_PlatformIdEventData) Bytes() []byte {
 	return e.data
 }
 
-func (e *bimReferenceManifestEventData) Type() NoActionEventType {
+// EncodeTo encodes this event data in to the form in which it appears in the event log.
+func (e *SP800_155_PlatformIdEventData) EncodeTo(w io.Writer) error {
+	var b bytes.Buffer
+	var sig [16]byte
+	copy(sig[:], e.signature)
+	b.Write(sig[:])
+	binary.Write(&b, binary.LittleEndian, e.VendorId)
+	b.Write(e.ReferenceManifestGuid[:])
+	_, err := b.WriteTo(w)
+	return err
+}
+
+func (e *SP800_155_PlatformIdEventData) Type() NoActionEventType {
 	return BiosIntegrityMeasurement
 }
 
-func (e *bimReferenceManifestEventData) Signature() string {
+func (e *SP800_155_PlatformIdEventData) Signature() string {
 	return e.signature
 }
 
@@ -198,7 +223,7 @@ func (e *bimReferenceManifestEventData) Signature() string {
 //  (section 9.4.5.2 "BIOS Integrity Measurement Reference Manifest Event")
 // https://trustedcomputinggroup.org/wp-content/uploads/TCG_EFI_Platform_1_22_Final_-v15.pdf
 //  (section 7.4 "EV_NO_ACTION Event Types")
-func decodeBIMReferenceManifestEvent(r io.Reader, signature string, data []byte) (*bimReferenceManifestEventData, error) {
+func decodeBIMReferenceManifestEvent(r io.Reader, signature string, data []byte) (*SP800_155_PlatformIdEventData, error) {
 	var d struct {
 		VendorId uint32
 		Guid     EFIGUID
@@ -207,7 +232,69 @@ func decodeBIMReferenceManifestEvent(r io.Reader, signature string, data []byte)
 		return nil, err
 	}
 
-	return &bimReferenceManifestEventData{data: data, signature: signature, vendorId: d.VendorId, guid: d.Guid}, nil
+	return &SP800_155_PlatformIdEventData{data: data, signature: signature, VendorId: d.VendorId, ReferenceManifestGuid: d.Guid}, nil
+}
+
+// NvIndexEventData is the event data for a NvIndexInstance or NvIndexDynamic EV_NO_ACTION event, which records the contents of
+// a NV index that is measured by the platform.
+type NvIndexEventData struct {
+	data      []byte
+	signature string
+	Version   uint16
+	Data      []byte // The data that follows the header
+}
+
+func (e *NvIndexEventData) String() string {
+	return fmt.Sprintf("%s{ Version: %d, Data: %x }", e.signature, e.Version, e.Data)
+}
+
+func (e *NvIndexEventData) Bytes() []byte {
+	return e.data
+}
+
+// EncodeTo encodes this event data in to the form in which it appears in the event log.
+func (e *NvIndexEventData) EncodeTo(w io.Writer) error {
+	var b bytes.Buffer
+	var sig [16]byte
+	copy(sig[:], e.signature)
+	b.Write(sig[:])
+	binary.Write(&b, binary.LittleEndian, e.Version)
+	b.Write(make([]byte, 6))
+	b.Write(e.Data)
+	_, err := b.WriteTo(w)
+	return err
+}
+
+func (e *NvIndexEventData) Type() NoActionEventType {
+	if e.signature == "NvIndexDynamic" {
+		return NvIndexDynamic
+	}
+	return NvIndexInstance
+}
+
+func (e *NvIndexEventData) Signature() string {
+	return e.signature
+}
+
+// https://trustedcomputinggroup.org/wp-content/uploads/TCG_PCClient_PFP_r1p05_v23_pub.pdf
+//  (section 10.4.5.4 "NV Index Instance Event Log Data", section 10.4.5.5 "NV Index Dynamic Event Log Data")
+func decodeNvIndexEvent(r io.Reader, signature string, data []byte) (*NvIndexEventData, error) {
+	var version uint16
+	if err := binary.Read(r, binary.LittleEndian, &version); err != nil {
+		return nil, xerrors.Errorf("cannot read version: %w", err)
+	}
+
+	var reserved [6]byte
+	if _, err := io.ReadFull(r, reserved[:]); err != nil {
+		return nil, xerrors.Errorf("cannot read reserved bytes: %w", err)
+	}
+
+	d, err := ioutil.ReadAll(r)
+	if err != nil {
+		return nil, xerrors.Errorf("cannot read data: %w", err)
+	}
+
+	return &NvIndexEventData{data: data, signature: signature, Version: version, Data: d}, nil
 }
 
 // EFIVariableData corresponds to the EFI_VARIABLE_DATA type and is the event data associated with the measurement of an
@@ -221,7 +308,8 @@ type EFIVariableData struct {
 }
 
 func (e *EFIVariableData) String() string {
-	return fmt.Sprintf("UEFI_VARIABLE_DATA{ VariableName: %s, UnicodeName: \"%s\" }", e.VariableName, e.UnicodeName)
+	return fmt.Sprintf("UEFI_VARIABLE_DATA{ VariableName: %s, UnicodeName: \"%s\" }", e.VariableName.stringWithName(),
+		EscapeString(e.UnicodeName))
 }
 
 func (e *EFIVariableData) Bytes() []byte {
@@ -248,6 +336,12 @@ func (e *EFIVariableData) EncodeMeasuredBytes(w io.Writer) error {
 	return nil
 }
 
+// EncodeTo encodes this event data in to the form in which it appears in the event log, which is a UEFI_VARIABLE_DATA
+// structure. Any trailing bytes in the original event data are not included.
+func (e *EFIVariableData) EncodeTo(w io.Writer) error {
+	return e.EncodeMeasuredBytes(w)
+}
+
 // TrailingBytes returns any trailing bytes that were not used during decoding. This indicates a bug in the software responsible
 // for the event. See https://github.com/rhboot/shim/commit/7e4d3f1c8c730a5d3f40729cb285b5d8c7b241af and
 // https://github.com/rhboot/shim/commit/8a27a4809a6a2b40fb6a4049071bf96d6ad71b50 for the types of bugs that might cause this. Note
@@ -326,16 +420,31 @@ const (
 
 	efiACPIDevicePathNodeNormal = 0x01
 
-	efiMsgDevicePathNodeLU   = 0x11
-	efiMsgDevicePathNodeSATA = 0x12
+	efiMsgDevicePathNodeMAC     = 0x0b
+	efiMsgDevicePathNodeIPv4    = 0x0c
+	efiMsgDevicePathNodeIPv6    = 0x0d
+	efiMsgDevicePathNodeUSBWWID = 0x10
+	efiMsgDevicePathNodeLU      = 0x11
+	efiMsgDevicePathNodeSATA    = 0x12
+	efiMsgDevicePathNodeNVMe    = 0x17
+	efiMsgDevicePathNodeURI     = 0x18
+	efiMsgDevicePathNodeSD      = 0x1a
+	efiMsgDevicePathNodeEMMC    = 0x1d
 
 	efiMediaDevicePathNodeHardDrive      = 0x01
 	efiMediaDevicePathNodeFilePath       = 0x04
 	efiMediaDevicePathNodeFvFile         = 0x06
 	efiMediaDevicePathNodeFv             = 0x07
 	efiMediaDevicePathNodeRelOffsetRange = 0x08
+
+	efiEndDevicePathNodeInstance = 0x01
 )
 
+// devicePathGUIDString returns the representation of the supplied GUID used in device path text, which omits the braces.
+func devicePathGUIDString(guid EFIGUID) string {
+	return strings.Trim(guid.String(), "{}")
+}
+
 func firmwareDevicePathNodeToString(subType uint8, data []byte) (string, error) {
 	r := bytes.NewReader(data)
 
@@ -347,14 +456,14 @@ func firmwareDevicePathNodeToString(subType uint8, data []byte) (string, error)
 	var builder bytes.Buffer
 	switch subType {
 	case efiMediaDevicePathNodeFvFile:
-		builder.WriteString("\\FvFile")
+		builder.WriteString("FvFile")
 	case efiMediaDevicePathNodeFv:
-		builder.WriteString("\\Fv")
+		builder.WriteString("Fv")
 	default:
 		return "", fmt.Errorf("invalid sub type for firmware device path node: %d", subType)
 	}
 
-	fmt.Fprintf(&builder, "(%s)", name)
+	fmt.Fprintf(&builder, "(%s)", devicePathGUIDString(name))
 	return builder.String(), nil
 }
 
@@ -374,16 +483,22 @@ func acpiDevicePathNodeToString(data []byte) (string, error) {
 	if hid&0xffff == 0x41d0 {
 		switch hid >> 16 {
 		case 0x0a03:
-			return fmt.Sprintf("\\PciRoot(0x%x)", uid), nil
+			return fmt.Sprintf("PciRoot(0x%x)", uid), nil
 		case 0x0a08:
-			return fmt.Sprintf("\\PcieRoot(0x%x)", uid), nil
+			return fmt.Sprintf("PcieRoot(0x%x)", uid), nil
 		case 0x0604:
-			return fmt.Sprintf("\\Floppy(0x%x)", uid), nil
+			return fmt.Sprintf("Floppy(0x%x)", uid), nil
+		case 0x0301:
+			return fmt.Sprintf("Keyboard(0x%x)", uid), nil
+		case 0x0501:
+			return fmt.Sprintf("Serial(0x%x)", uid), nil
+		case 0x0401:
+			return fmt.Sprintf("ParallelPort(0x%x)", uid), nil
 		default:
-			return fmt.Sprintf("\\Acpi(PNP%04x,0x%x)", hid>>16, uid), nil
+			return fmt.Sprintf("Acpi(PNP%04x,0x%x)", hid>>16, uid), nil
 		}
 	} else {
-		return fmt.Sprintf("\\Acpi(0x%08x,0x%x)", hid, uid), nil
+		return fmt.Sprintf("Acpi(0x%08x,0x%x)", hid, uid), nil
 	}
 }
 
@@ -400,7 +515,7 @@ func pciDevicePathNodeToString(data []byte) (string, error) {
 		return "", xerrors.Errorf("cannot read device: %w", err)
 	}
 
-	return fmt.Sprintf("\\Pci(0x%x,0x%x)", device, function), nil
+	return fmt.Sprintf("Pci(0x%x,0x%x)", device, function), nil
 }
 
 func luDevicePathNodeToString(data []byte) (string, error) {
@@ -411,7 +526,7 @@ func luDevicePathNodeToString(data []byte) (string, error) {
 		return "", xerrors.Errorf("cannot read LUN: %w", err)
 	}
 
-	return fmt.Sprintf("\\Unit(0x%x)", lun), nil
+	return fmt.Sprintf("Unit(0x%x)", lun), nil
 }
 
 func hardDriveDevicePathNodeToString(data []byte) (string, error) {
@@ -451,14 +566,14 @@ func hardDriveDevicePathNodeToString(data []byte) (string, error) {
 
 	switch sigType {
 	case 0x01:
-		fmt.Fprintf(&builder, "\\HD(%d,MBR,0x%08x,", partNumber, binary.LittleEndian.Uint32(sig[:]))
+		fmt.Fprintf(&builder, "HD(%d,MBR,0x%08x,", partNumber, binary.LittleEndian.Uint32(sig[:]))
 	case 0x02:
-		fmt.Fprintf(&builder, "\\HD(%d,GPT,%s,", partNumber, sig)
+		fmt.Fprintf(&builder, "HD(%d,GPT,%s,", partNumber, devicePathGUIDString(sig))
 	default:
-		fmt.Fprintf(&builder, "\\HD(%d,%d,0,", partNumber, sigType)
+		fmt.Fprintf(&builder, "HD(%d,%d,0,", partNumber, sigType)
 	}
 
-	fmt.Fprintf(&builder, "0x%016x, 0x%016x)", partStart, partSize)
+	fmt.Fprintf(&builder, "0x%x,0x%x)", partStart, partSize)
 	return builder.String(), nil
 }
 
@@ -480,7 +595,172 @@ func sataDevicePathNodeToString(data []byte) (string, error) {
 		return "", xerrors.Errorf("cannot read LUN: %w", err)
 	}
 
-	return fmt.Sprintf("\\Sata(0x%x,0x%x,0x%x)", hbaPortNumber, portMultiplierPortNumber, lun), nil
+	return fmt.Sprintf("Sata(0x%x,0x%x,0x%x)", hbaPortNumber, portMultiplierPortNumber, lun), nil
+}
+
+func nvmeDevicePathNodeToString(data []byte) (string, error) {
+	r := bytes.NewReader(data)
+
+	var nsid uint32
+	if err := binary.Read(r, binary.LittleEndian, &nsid); err != nil {
+		return "", xerrors.Errorf("cannot read namespace ID: %w", err)
+	}
+
+	var eui [8]uint8
+	if _, err := io.ReadFull(r, eui[:]); err != nil {
+		return "", xerrors.Errorf("cannot read EUI-64: %w", err)
+	}
+
+	return fmt.Sprintf("NVMe(0x%x,%02x-%02x-%02x-%02x-%02x-%02x-%02x-%02x)", nsid, eui[7], eui[6], eui[5], eui[4], eui[3], eui[2],
+		eui[1], eui[0]), nil
+}
+
+func uriDevicePathNodeToString(data []byte) string {
+	return fmt.Sprintf("Uri(%s)", data)
+}
+
+func usbWWIDDevicePathNodeToString(data []byte) (string, error) {
+	r := bytes.NewReader(data)
+
+	var hdr struct {
+		InterfaceNumber uint16
+		VendorId        uint16
+		ProductId       uint16
+	}
+	if err := binary.Read(r, binary.LittleEndian, &hdr); err != nil {
+		return "", xerrors.Errorf("cannot read header: %w", err)
+	}
+
+	serial := filePathDevicePathNodeToString(data[6:])
+	return fmt.Sprintf("UsbWwid(0x%x,0x%x,0x%x,\"%s\")", hdr.VendorId, hdr.ProductId, hdr.InterfaceNumber, serial), nil
+}
+
+func slotDevicePathNodeToString(name string, data []byte) (string, error) {
+	if len(data) < 1 {
+		return "", errors.New("cannot read slot number: EOF")
+	}
+	return fmt.Sprintf("%s(0x%x)", name, data[0]), nil
+}
+
+func macDevicePathNodeToString(data []byte) (string, error) {
+	r := bytes.NewReader(data)
+
+	var addr [32]uint8
+	if _, err := io.ReadFull(r, addr[:]); err != nil {
+		return "", xerrors.Errorf("cannot read address: %w", err)
+	}
+
+	var ifType uint8
+	if err := binary.Read(r, binary.LittleEndian, &ifType); err != nil {
+		return "", xerrors.Errorf("cannot read interface type: %w", err)
+	}
+
+	size := len(addr)
+	if ifType == 0x00 || ifType == 0x01 {
+		size = 6
+	}
+	return fmt.Sprintf("MAC(%x,0x%x)", addr[:size], ifType), nil
+}
+
+func networkProtocolString(protocol uint16) string {
+	switch protocol {
+	case 6:
+		return "TCP"
+	case 17:
+		return "UDP"
+	default:
+		return fmt.Sprintf("0x%x", protocol)
+	}
+}
+
+func ipv4DevicePathNodeToString(data []byte) (string, error) {
+	r := bytes.NewReader(data)
+
+	var node struct {
+		LocalIPAddress  [4]uint8
+		RemoteIPAddress [4]uint8
+		LocalPort       uint16
+		RemotePort      uint16
+		Protocol        uint16
+		StaticIPAddress uint8
+	}
+	if err := binary.Read(r, binary.LittleEndian, &node); err != nil {
+		return "", xerrors.Errorf("cannot read node: %w", err)
+	}
+
+	origin := "DHCP"
+	if node.StaticIPAddress != 0 {
+		origin = "Static"
+	}
+
+	var builder bytes.Buffer
+	fmt.Fprintf(&builder, "IPv4(%s,%s,%s,%s", net.IP(node.RemoteIPAddress[:]), networkProtocolString(node.Protocol), origin,
+		net.IP(node.LocalIPAddress[:]))
+
+	// The gateway and subnet mask fields were added in version 2.3 of the UEFI specification.
+	var ext struct {
+		GatewayIPAddress [4]uint8
+		SubnetMask       [4]uint8
+	}
+	if err := binary.Read(r, binary.LittleEndian, &ext); err == nil {
+		fmt.Fprintf(&builder, ",%s,%s", net.IP(ext.GatewayIPAddress[:]), net.IP(ext.SubnetMask[:]))
+	}
+
+	builder.WriteString(")")
+	return builder.String(), nil
+}
+
+func ipv6AddressString(addr [16]uint8) string {
+	var builder bytes.Buffer
+	for i := 0; i < len(addr); i += 2 {
+		if i > 0 {
+			builder.WriteString(":")
+		}
+		fmt.Fprintf(&builder, "%x", binary.BigEndian.Uint16(addr[i:]))
+	}
+	return builder.String()
+}
+
+func ipv6DevicePathNodeToString(data []byte) (string, error) {
+	r := bytes.NewReader(data)
+
+	var node struct {
+		LocalIPAddress  [16]uint8
+		RemoteIPAddress [16]uint8
+		LocalPort       uint16
+		RemotePort      uint16
+		Protocol        uint16
+		IPAddressOrigin uint8
+	}
+	if err := binary.Read(r, binary.LittleEndian, &node); err != nil {
+		return "", xerrors.Errorf("cannot read node: %w", err)
+	}
+
+	var origin string
+	switch node.IPAddressOrigin {
+	case 0:
+		origin = "Static"
+	case 1:
+		origin = "StatelessAutoConfigure"
+	default:
+		origin = "StatefulAutoConfigure"
+	}
+
+	var builder bytes.Buffer
+	fmt.Fprintf(&builder, "IPv6(%s,%s,%s,%s", ipv6AddressString(node.RemoteIPAddress), networkProtocolString(node.Protocol),
+		origin, ipv6AddressString(node.LocalIPAddress))
+
+	// The prefix length and gateway fields were added in version 2.4 of the UEFI specification.
+	var ext struct {
+		PrefixLength     uint8
+		GatewayIPAddress [16]uint8
+	}
+	if err := binary.Read(r, binary.LittleEndian, &ext); err == nil {
+		fmt.Fprintf(&builder, ",0x%x,%s", ext.PrefixLength, ipv6AddressString(ext.GatewayIPAddress))
+	}
+
+	builder.WriteString(")")
+	return builder.String(), nil
 }
 
 func filePathDevicePathNodeToString(data []byte) string {
@@ -490,6 +770,9 @@ func filePathDevicePathNodeToString(data []byte) string {
 
 	var buf bytes.Buffer
 	for _, r := range utf16.Decode(u16) {
+		if r == 0 {
+			break
+		}
 		buf.WriteRune(r)
 	}
 	return buf.String()
@@ -512,19 +795,32 @@ func relOffsetRangePathNodeToString(data []byte) (string, error) {
 		return "", xerrors.Errorf("cannot read end: %w", err)
 	}
 
-	return fmt.Sprintf("\\Offset(0x%x,0x%x)", start, end), nil
+	return fmt.Sprintf("Offset(0x%x,0x%x)", start, end), nil
+}
+
+func genericDevicePathNodeToString(t efiDevicePathNodeType, subType uint8, data []byte) string {
+	var builder bytes.Buffer
+	switch t {
+	case efiDevicePathNodeHardware, efiDevicePathNodeACPI, efiDevicePathNodeMsg, efiDevicePathNodeMedia, efiDevicePathNodeBBS:
+		fmt.Fprintf(&builder, "%s(%d", t, subType)
+	default:
+		fmt.Fprintf(&builder, "Path(%d,%d", t, subType)
+	}
+	if len(data) > 0 {
+		fmt.Fprintf(&builder, ",%x", data)
+	}
+	builder.WriteString(")")
+	return builder.String()
 }
 
+// decodeDevicePathNode decodes the next node from the supplied reader and returns its textual representation. It returns ","
+// for an end of instance node, and an empty string for an end of device path node.
 func decodeDevicePathNode(r io.Reader) (string, error) {
 	var t efiDevicePathNodeType
 	if err := binary.Read(r, binary.LittleEndian, &t); err != nil {
 		return "", xerrors.Errorf("cannot read type: %w", err)
 	}
 
-	if t == efiDevicePathNodeEoH {
-		return "", nil
-	}
-
 	var subType uint8
 	if err := binary.Read(r, binary.LittleEndian, &subType); err != nil {
 		return "", xerrors.Errorf("cannot read sub-type: %w", err)
@@ -545,6 +841,11 @@ func decodeDevicePathNode(r io.Reader) (string, error) {
 	}
 
 	switch t {
+	case efiDevicePathNodeEoH:
+		if subType == efiEndDevicePathNodeInstance {
+			return ",", nil
+		}
+		return "", nil
 	case efiDevicePathNodeMedia:
 		switch subType {
 		case efiMediaDevicePathNodeFvFile, efiMediaDevicePathNodeFv:
@@ -600,59 +901,134 @@ func decodeDevicePathNode(r io.Reader) (string, error) {
 				return "", xerrors.Errorf("cannot decode Sata node: %w", err)
 			}
 			return s, nil
+		case efiMsgDevicePathNodeNVMe:
+			s, err := nvmeDevicePathNodeToString(data)
+			if err != nil {
+				return "", xerrors.Errorf("cannot decode NVMe node: %w", err)
+			}
+			return s, nil
+		case efiMsgDevicePathNodeURI:
+			return uriDevicePathNodeToString(data), nil
+		case efiMsgDevicePathNodeUSBWWID:
+			s, err := usbWWIDDevicePathNodeToString(data)
+			if err != nil {
+				return "", xerrors.Errorf("cannot decode UsbWwid node: %w", err)
+			}
+			return s, nil
+		case efiMsgDevicePathNodeSD:
+			s, err := slotDevicePathNodeToString("SD", data)
+			if err != nil {
+				return "", xerrors.Errorf("cannot decode SD node: %w", err)
+			}
+			return s, nil
+		case efiMsgDevicePathNodeEMMC:
+			s, err := slotDevicePathNodeToString("eMMC", data)
+			if err != nil {
+				return "", xerrors.Errorf("cannot decode eMMC node: %w", err)
+			}
+			return s, nil
+		case efiMsgDevicePathNodeMAC:
+			s, err := macDevicePathNodeToString(data)
+			if err != nil {
+				return "", xerrors.Errorf("cannot decode MAC node: %w", err)
+			}
+			return s, nil
+		case efiMsgDevicePathNodeIPv4:
+			s, err := ipv4DevicePathNodeToString(data)
+			if err != nil {
+				return "", xerrors.Errorf("cannot decode IPv4 node: %w", err)
+			}
+			return s, nil
+		case efiMsgDevicePathNodeIPv6:
+			s, err := ipv6DevicePathNodeToString(data)
+			if err != nil {
+				return "", xerrors.Errorf("cannot decode IPv6 node: %w", err)
+			}
+			return s, nil
 		}
 
 	}
 
-	var builder bytes.Buffer
-	fmt.Fprintf(&builder, "\\%s(%d", t, subType)
-	if len(data) > 0 {
-		fmt.Fprintf(&builder, ", 0x")
-		for _, b := range data {
-			fmt.Fprintf(&builder, "%02x", b)
-		}
-	}
-	fmt.Fprintf(&builder, ")")
-	return builder.String(), nil
+	return genericDevicePathNodeToString(t, subType, data), nil
 }
 
 func decodeDevicePath(data []byte) (string, error) {
 	r := bytes.NewReader(data)
 	var builder bytes.Buffer
 
+	sep := ""
 	for i := 0; ; i++ {
 		node, err := decodeDevicePathNode(r)
 		if err != nil {
 			return "", xerrors.Errorf("cannot decode node %d: %w", i, err)
 		}
-		if node == "" {
+		switch node {
+		case "":
 			return builder.String(), nil
+		case ",":
+			builder.WriteString(node)
+			sep = ""
+		default:
+			builder.WriteString(sep + node)
+			sep = "/"
 		}
-		fmt.Fprintf(&builder, "%s", node)
 	}
 }
 
-type efiImageLoadEventData struct {
+// EFIDevicePathToText returns the textual representation of the supplied EFI device path, using the format defined by the UEFI
+// specification. This is the same format printed by UEFI shells and efibootmgr, so paths can be compared against other tooling.
+//
+// https://uefi.org/sites/default/files/resources/UEFI_Spec_2_8_final.pdf
+//  (section 10.6 "Text Device Path Representation")
+func EFIDevicePathToText(data []byte) (string, error) {
+	return decodeDevicePath(data)
+}
+
+// EFIImageLoadEvent corresponds to the UEFI_IMAGE_LOAD_EVENT type and is the event data associated with the measurement of an
+// EFI image.
+type EFIImageLoadEvent struct {
 	data             []byte
-	locationInMemory uint64
-	lengthInMemory   uint64
-	linkTimeAddress  uint64
-	path             string
+	devicePath       []byte // The raw device path
+	LocationInMemory uint64 // The address of the loaded image in memory
+	LengthInMemory   uint64 // The size of the loaded image in memory
+	LinkTimeAddress  uint64 // The link-time base address of the image
+	DevicePath       string // The textual representation of the device path from which the image was loaded
 }
 
-func (e *efiImageLoadEventData) String() string {
+func (e *EFIImageLoadEvent) String() string {
 	return fmt.Sprintf("UEFI_IMAGE_LOAD_EVENT{ ImageLocationInMemory: 0x%016x, ImageLengthInMemory: %d, "+
-		"ImageLinkTimeAddress: 0x%016x, DevicePath: %s }", e.locationInMemory, e.lengthInMemory,
-		e.linkTimeAddress, e.path)
+		"ImageLinkTimeAddress: 0x%016x, DevicePath: %s }", e.LocationInMemory, e.LengthInMemory,
+		e.LinkTimeAddress, EscapeString(e.DevicePath))
 }
 
-func (e *efiImageLoadEventData) Bytes() []byte {
+func (e *EFIImageLoadEvent) Bytes() []byte {
 	return e.data
 }
 
+// EncodeTo encodes this event data in to the form in which it appears in the event log. The device path is copied from the
+// original event data, as DevicePath is only a textual representation of it. A value that wasn't decoded from an event log
+// is encoded with a device path that consists only of an end node. An error is returned if DevicePath doesn't match the
+// device path that will be encoded, as it cannot be converted back to its binary form.
+func (e *EFIImageLoadEvent) EncodeTo(w io.Writer) error {
+	devicePath := e.devicePath
+	if devicePath == nil {
+		devicePath = efiEndEntireDevicePath
+	}
+	if path, err := decodeDevicePath(devicePath); err != nil || path != e.DevicePath {
+		return errors.New("cannot encode a modified device path")
+	}
+
+	var b bytes.Buffer
+	binary.Write(&b, binary.LittleEndian, []uint64{e.LocationInMemory, e.LengthInMemory, e.LinkTimeAddress,
+		uint64(len(devicePath))})
+	b.Write(devicePath)
+	_, err := b.WriteTo(w)
+	return err
+}
+
 // https://trustedcomputinggroup.org/wp-content/uploads/TCG_EFI_Platform_1_22_Final_-v15.pdf (section 4 "Measuring PE/COFF Image Files")
 // https://trustedcomputinggroup.org/wp-content/uploads/TCG_PCClientSpecPlat_TPM_2p0_1p04_pub.pdf (section 9.2.3 "UEFI_IMAGE_LOAD_EVENT Structure")
-func decodeEventDataEFIImageLoad(data []byte) (*efiImageLoadEventData, error) {
+func decodeEventDataEFIImageLoad(data []byte) (*EFIImageLoadEvent, error) {
 	r := bytes.NewReader(data)
 
 	var locationInMemory uint64
@@ -686,33 +1062,89 @@ func decodeEventDataEFIImageLoad(data []byte) (*efiImageLoadEventData, error) {
 		return nil, xerrors.Errorf("cannot decode device path: %w", err)
 	}
 
-	return &efiImageLoadEventData{data: data,
-		locationInMemory: locationInMemory,
-		lengthInMemory:   lengthInMemory,
-		linkTimeAddress:  linkTimeAddress,
-		path:             path}, nil
+	return &EFIImageLoadEvent{data: data,
+		devicePath:       devicePathBuf,
+		LocationInMemory: locationInMemory,
+		LengthInMemory:   lengthInMemory,
+		LinkTimeAddress:  linkTimeAddress,
+		DevicePath:       path}, nil
+}
+
+// EFIPartitionEntry corresponds to the EFI_PARTITION_ENTRY type, which is an entry in the partition table recorded in an
+// EV_EFI_GPT_EVENT event.
+type EFIPartitionEntry struct {
+	PartitionTypeGUID   EFIGUID // The GUID that identifies the type of partition
+	UniquePartitionGUID EFIGUID // The GUID that uniquely identifies this partition
+	StartingLBA         uint64  // The first LBA of this partition
+	EndingLBA           uint64  // The last LBA of this partition (inclusive)
+	Attributes          uint64  // The attributes of this partition
+	Name                string  // The name of the partition
+}
+
+// PartitionTypeName returns a human readable name for the type of this partition if it is well known, or an empty string if it
+// isn't.
+func (p *EFIPartitionEntry) PartitionTypeName() string {
+	return efiPartitionTypeNames[p.PartitionTypeGUID]
+}
+
+func (p *EFIPartitionEntry) String() string {
+	partType := p.PartitionTypeGUID.String()
+	if name := p.PartitionTypeName(); name != "" {
+		partType = fmt.Sprintf("%s (%s)", partType, name)
+	}
+	return fmt.Sprintf("PartitionTypeGUID: %s, UniquePartitionGUID: %s, StartingLBA: %d, EndingLBA: %d, Attributes: 0x%016x, "+
+		"Name: \"%s\"", partType, p.UniquePartitionGUID, p.StartingLBA, p.EndingLBA, p.Attributes, p.Name)
 }
 
-type efiGPTPartitionEntry struct {
-	typeGUID   EFIGUID
-	uniqueGUID EFIGUID
-	name       string
+// efiPartitionTypeNames maps well known partition type GUIDs to human readable names.
+var efiPartitionTypeNames = map[EFIGUID]string{
+	MakeEFIGUID(0xc12a7328, 0xf81f, 0x11d2, 0xba4b, [...]uint8{0x00, 0xa0, 0xc9, 0x3e, 0xc9, 0x3b}): "EFI System Partition",
+	MakeEFIGUID(0x024dee41, 0x33e7, 0x11d3, 0x9d69, [...]uint8{0x00, 0x08, 0xc7, 0x81, 0xf3, 0x9f}): "Legacy MBR",
+	MakeEFIGUID(0x21686148, 0x6449, 0x6e6f, 0x744e, [...]uint8{0x65, 0x65, 0x64, 0x45, 0x46, 0x49}): "BIOS boot",
+	MakeEFIGUID(0x0fc63daf, 0x8483, 0x4772, 0x8e79, [...]uint8{0x3d, 0x69, 0xd8, 0x47, 0x7d, 0xe4}): "Linux filesystem data",
+	MakeEFIGUID(0x4f68bce3, 0xe8cd, 0x4db1, 0x96e7, [...]uint8{0xfb, 0xca, 0xf9, 0x84, 0xb7, 0x09}): "Linux root (x86-64)",
+	MakeEFIGUID(0x933ac7e1, 0x2eb4, 0x4f13, 0xb844, [...]uint8{0x0e, 0x14, 0xe2, 0xae, 0xf9, 0x15}): "Linux home",
+	MakeEFIGUID(0xbc13c2ff, 0x59e6, 0x4262, 0xa352, [...]uint8{0xb2, 0x75, 0xfd, 0x6f, 0x71, 0x72}): "Linux extended boot",
+	MakeEFIGUID(0x0657fd6d, 0xa4ab, 0x43c4, 0x84e5, [...]uint8{0x09, 0x33, 0xc8, 0x4b, 0x4f, 0x4f}): "Linux swap",
+	MakeEFIGUID(0xe6d6d379, 0xf507, 0x44c2, 0xa23c, [...]uint8{0x23, 0x8f, 0x2a, 0x3d, 0xf9, 0x28}): "Linux LVM",
+	MakeEFIGUID(0xa19d880f, 0x05fc, 0x4d3b, 0xa006, [...]uint8{0x74, 0x3f, 0x0f, 0x84, 0x91, 0x1e}): "Linux RAID",
+	MakeEFIGUID(0xe3c9e316, 0x0b5c, 0x4db8, 0x817d, [...]uint8{0xf9, 0x2d, 0xf0, 0x02, 0x15, 0xae}): "Microsoft reserved",
+	MakeEFIGUID(0xebd0a0a2, 0xb9e5, 0x4433, 0x87c0, [...]uint8{0x68, 0xb6, 0xb7, 0x26, 0x99, 0xc7}): "Microsoft basic data",
+	MakeEFIGUID(0xde94bba4, 0x06d1, 0x4d40, 0xa16a, [...]uint8{0xbf, 0xd5, 0x01, 0x79, 0xd6, 0xac}): "Windows recovery environment",
+	MakeEFIGUID(0x48465300, 0x0000, 0x11aa, 0xaa11, [...]uint8{0x00, 0x30, 0x65, 0x43, 0xec, 0xac}): "Apple HFS+",
+	MakeEFIGUID(0x7c3457ef, 0x0000, 0x11aa, 0xaa11, [...]uint8{0x00, 0x30, 0x65, 0x43, 0xec, 0xac}): "Apple APFS",
 }
 
-func (p *efiGPTPartitionEntry) String() string {
-	return fmt.Sprintf("PartitionTypeGUID: %s, UniquePartitionGUID: %s, Name: \"%s\"", p.typeGUID, p.uniqueGUID, p.name)
+// EFIPartitionTableHeader corresponds to the EFI_PARTITION_TABLE_HEADER type.
+type EFIPartitionTableHeader struct {
+	Signature                uint64 // The signature ("EFI PART")
+	Revision                 uint32
+	HeaderSize               uint32
+	HeaderCRC32              uint32
+	Reserved                 uint32
+	MyLBA                    uint64  // The LBA that contains this header
+	AlternateLBA             uint64  // The LBA that contains the alternate header
+	FirstUsableLBA           uint64  // The first LBA that may be used by a partition
+	LastUsableLBA            uint64  // The last LBA that may be used by a partition
+	DiskGUID                 EFIGUID // The GUID that identifies the disk
+	PartitionEntryLBA        uint64  // The starting LBA of the partition entry array
+	NumberOfPartitionEntries uint32  // The number of entries in the partition entry array
+	SizeOfPartitionEntry     uint32  // The size of each entry in the partition entry array
+	PartitionEntryArrayCRC32 uint32
 }
 
-type efiGPTEventData struct {
+// EFIGPTData corresponds to the UEFI_GPT_DATA type and is the event data associated with the measurement of a GUID partition
+// table.
+type EFIGPTData struct {
 	data       []byte
-	diskGUID   EFIGUID
-	partitions []*efiGPTPartitionEntry
+	Header     EFIPartitionTableHeader // The partition table header
+	Partitions []*EFIPartitionEntry    // The partitions on the disk that are in use
 }
 
-func (e *efiGPTEventData) String() string {
+func (e *EFIGPTData) String() string {
 	var builder bytes.Buffer
-	fmt.Fprintf(&builder, "UEFI_GPT_DATA{ DiskGUID: %s, Partitions: [", e.diskGUID)
-	for i, part := range e.partitions {
+	fmt.Fprintf(&builder, "UEFI_GPT_DATA{ DiskGUID: %s, Partitions: [", e.Header.DiskGUID)
+	for i, part := range e.Partitions {
 		if i > 0 {
 			fmt.Fprintf(&builder, ", ")
 		}
@@ -722,39 +1154,48 @@ func (e *efiGPTEventData) String() string {
 	return builder.String()
 }
 
-func (e *efiGPTEventData) Bytes() []byte {
+func (e *EFIGPTData) Bytes() []byte {
 	return e.data
 }
 
-func decodeEventDataEFIGPT(data []byte) (*efiGPTEventData, error) {
-	r := bytes.NewReader(data)
-
-	// Skip UEFI_GPT_DATA.UEFIPartitionHeader.{Header, MyLBA, AlternateLBA, FirstUsableLBA, LastUsableLBA}
-	if _, err := r.Seek(56, io.SeekCurrent); err != nil {
-		return nil, err
+// EncodeTo encodes this event data in to the form in which it appears in the event log. Any bytes following the NULL terminator
+// of each partition name in the original event data are not preserved, and the header CRC is not updated if any fields are
+// modified.
+func (e *EFIGPTData) EncodeTo(w io.Writer) error {
+	var b bytes.Buffer
+	binary.Write(&b, binary.LittleEndian, e.Header)
+	binary.Write(&b, binary.LittleEndian, uint64(len(e.Partitions)))
+
+	entrySize := int(e.Header.SizeOfPartitionEntry)
+	for i, part := range e.Partitions {
+		var entry bytes.Buffer
+		entry.Write(part.PartitionTypeGUID[:])
+		entry.Write(part.UniquePartitionGUID[:])
+		binary.Write(&entry, binary.LittleEndian, []uint64{part.StartingLBA, part.EndingLBA, part.Attributes})
+		binary.Write(&entry, binary.LittleEndian, convertStringToUtf16(part.Name))
+		if entry.Len() > entrySize {
+			return fmt.Errorf("entry for partition %d is too large", i)
+		}
+		entry.Write(make([]byte, entrySize-entry.Len()))
+		entry.WriteTo(&b)
 	}
 
-	d := &efiGPTEventData{data: data}
+	_, err := b.WriteTo(w)
+	return err
+}
 
-	// UEFI_GPT_DATA.UEFIPartitionHeader.DiskGUID
-	if _, err := io.ReadFull(r, d.diskGUID[:]); err != nil {
-		return nil, xerrors.Errorf("cannot read disk GUID: %w", err)
-	}
+// https://trustedcomputinggroup.org/wp-content/uploads/TCG_PCClientSpecPlat_TPM_2p0_1p04_pub.pdf
+//  (section 9.4 "UEFI_GPT_DATA Structure")
+// https://uefi.org/sites/default/files/resources/UEFI_Spec_2_8_final.pdf
+//  (section 5.3 "GUID Partition Table (GPT) Disk Layout")
+func decodeEventDataEFIGPT(data []byte) (*EFIGPTData, error) {
+	r := bytes.NewReader(data)
 
-	// Skip UEFI_GPT_DATA.UEFIPartitionHeader.{PartitionEntryLBA, NumberOfPartitionEntries}
-	if _, err := r.Seek(12, io.SeekCurrent); err != nil {
-		return nil, err
-	}
+	d := &EFIGPTData{data: data}
 
-	// UEFI_GPT_DATA.UEFIPartitionHeader.SizeOfPartitionEntry
-	var partEntrySize uint32
-	if err := binary.Read(r, binary.LittleEndian, &partEntrySize); err != nil {
-		return nil, xerrors.Errorf("cannot read SizeOfPartitionEntry: %w", err)
-	}
-
-	// Skip UEFI_GPT_DATA.UEFIPartitionHeader.PartitionEntryArrayCRC32
-	if _, err := r.Seek(4, io.SeekCurrent); err != nil {
-		return nil, err
+	// UEFI_GPT_DATA.UEFIPartitionHeader
+	if err := binary.Read(r, binary.LittleEndian, &d.Header); err != nil {
+		return nil, xerrors.Errorf("cannot read partition table header: %w", err)
 	}
 
 	// UEFI_GPT_DATA.NumberOfPartitions
@@ -763,32 +1204,32 @@ func decodeEventDataEFIGPT(data []byte) (*efiGPTEventData, error) {
 		return nil, xerrors.Errorf("cannot read number of partitions: %w", err)
 	}
 
+	entrySize := d.Header.SizeOfPartitionEntry
+	if entrySize < 56 {
+		return nil, fmt.Errorf("invalid SizeOfPartitionEntry (%d)", entrySize)
+	}
+
 	for i := uint64(0); i < numberOfParts; i++ {
-		entryData := make([]byte, partEntrySize)
-		if _, err := io.ReadFull(r, entryData); err != nil {
-			return nil, xerrors.Errorf("cannot read partition entry data: %w", err)
+		if int64(entrySize) > int64(r.Len()) {
+			return nil, xerrors.Errorf("cannot read partition entry data: %w", io.ErrUnexpectedEOF)
 		}
+		entryData := make([]byte, entrySize)
+		io.ReadFull(r, entryData)
 
 		er := bytes.NewReader(entryData)
-		e := &efiGPTPartitionEntry{}
+		e := &EFIPartitionEntry{}
 
-		if _, err := io.ReadFull(er, e.typeGUID[:]); err != nil {
-			return nil, xerrors.Errorf("cannot read partition type GUID: %w", err)
-		}
+		// UEFI_GPT_DATA.Partitions[i].{PartitionTypeGUID, UniquePartitionGUID}
+		er.Read(e.PartitionTypeGUID[:])
+		er.Read(e.UniquePartitionGUID[:])
 
-		if _, err := io.ReadFull(er, e.uniqueGUID[:]); err != nil {
-			return nil, xerrors.Errorf("cannot read partition unique GUID: %w", err)
-		}
-
-		// Skip UEFI_GPT_DATA.Partitions[i].{StartingLBA, EndingLBA, Attributes}
-		if _, err := er.Seek(24, io.SeekCurrent); err != nil {
-			return nil, err
-		}
+		// UEFI_GPT_DATA.Partitions[i].{StartingLBA, EndingLBA, Attributes}
+		lbas := make([]uint64, 3)
+		binary.Read(er, binary.LittleEndian, lbas)
+		e.StartingLBA, e.EndingLBA, e.Attributes = lbas[0], lbas[1], lbas[2]
 
 		nameUtf16 := make([]uint16, er.Len()/2)
-		if err := binary.Read(er, binary.LittleEndian, &nameUtf16); err != nil {
-			return nil, xerrors.Errorf("cannot read partition name: %w", err)
-		}
+		binary.Read(er, binary.LittleEndian, &nameUtf16)
 
 		var name bytes.Buffer
 		for _, r := range utf16.Decode(nameUtf16) {
@@ -797,9 +1238,9 @@ func decodeEventDataEFIGPT(data []byte) (*efiGPTEventData, error) {
 			}
 			name.WriteRune(r)
 		}
-		e.name = name.String()
+		e.Name = name.String()
 
-		d.partitions = append(d.partitions, e)
+		d.Partitions = append(d.Partitions, e)
 	}
 
 	return d, nil
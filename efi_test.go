@@ -6,6 +6,8 @@ package tcglog
 
 import (
 	"bytes"
+	"encoding/binary"
+	"strings"
 	"testing"
 )
 
@@ -48,3 +50,146 @@ func TestEFIVariableDataEncode(t *testing.T) {
 		})
 	}
 }
+
+func TestEFIImageLoadEventFields(t *testing.T) {
+	data := makeTestImageLoadEventData("\\EFI\\ubuntu\\shimx64.efi")
+	d, ok := DecodeEventData(4, EventTypeEFIBootServicesApplication, DigestMap{}, data, nil).(*EFIImageLoadEvent)
+	if !ok {
+		t.Fatalf("Unexpected event data type")
+	}
+	if d.LocationInMemory != 0x5e3c7018 || d.LengthInMemory != 0x1e4a50 || d.LinkTimeAddress != 0 {
+		t.Errorf("Unexpected image location: %s", d)
+	}
+	if d.DevicePath != "\\EFI\\ubuntu\\shimx64.efi" {
+		t.Errorf("Unexpected device path: %q", d.DevicePath)
+	}
+
+	d.LocationInMemory = 0x1000
+	var buf bytes.Buffer
+	if err := d.EncodeTo(&buf); err != nil {
+		t.Fatalf("EncodeTo failed: %v", err)
+	}
+	d2, ok := DecodeEventData(4, EventTypeEFIBootServicesApplication, DigestMap{}, buf.Bytes(), nil).(*EFIImageLoadEvent)
+	if !ok {
+		t.Fatalf("Cannot decode modified event data")
+	}
+	if d2.LocationInMemory != 0x1000 || d2.DevicePath != d.DevicePath {
+		t.Errorf("Unexpected modified event data: %s", d2)
+	}
+
+	d.DevicePath = "\\EFI\\ubuntu\\grubx64.efi"
+	if err := d.EncodeTo(new(bytes.Buffer)); err == nil || err.Error() != "cannot encode a modified device path" {
+		t.Errorf("Unexpected error: %v", err)
+	}
+}
+
+func TestEFIImageLoadEventEncodeNew(t *testing.T) {
+	var buf bytes.Buffer
+	if err := (&EFIImageLoadEvent{LocationInMemory: 0x1000, LengthInMemory: 0x2000}).EncodeTo(&buf); err != nil {
+		t.Fatalf("EncodeTo failed: %v", err)
+	}
+	d, ok := DecodeEventData(4, EventTypeEFIBootServicesApplication, DigestMap{}, buf.Bytes(), nil).(*EFIImageLoadEvent)
+	if !ok {
+		t.Fatalf("Cannot decode event data")
+	}
+	if d.LocationInMemory != 0x1000 || d.LengthInMemory != 0x2000 || d.LinkTimeAddress != 0 || d.DevicePath != "" {
+		t.Errorf("Unexpected event data: %s", d)
+	}
+	if !bytes.Equal(d.DevicePathData(), efiEndEntireDevicePath) {
+		t.Errorf("Unexpected device path: %x", d.DevicePathData())
+	}
+
+	if err := (&EFIImageLoadEvent{DevicePath: "\\EFI\\ubuntu\\shimx64.efi"}).EncodeTo(new(bytes.Buffer)); err == nil {
+		t.Errorf("EncodeTo should fail for a device path that cannot be encoded")
+	}
+}
+
+func TestEFIGPTDataEncodeModified(t *testing.T) {
+	diskGUID := MakeEFIGUID(0x0f8e2a43, 0x2a5e, 0x4b28, 0x9d1f, [...]uint8{0x6c, 0x4e, 0x8d, 0x54, 0x3f, 0x21})
+	espType := MakeEFIGUID(0xc12a7328, 0xf81f, 0x11d2, 0xba4b, [...]uint8{0x00, 0xa0, 0xc9, 0x3e, 0xc9, 0x3b})
+	espGUID := MakeEFIGUID(0x6a3c6f52, 0x1cbd, 0x4c9e, 0x8f27, [...]uint8{0x5b, 0x19, 0x30, 0x4e, 0x81, 0x6c})
+
+	data := makeTestGPTEventData(diskGUID, []testGPTPartition{{typeGUID: espType, uniqueGUID: espGUID, name: "EFI System Partition"}})
+	d, ok := DecodeEventData(5, EventTypeEFIGPTEvent, DigestMap{}, data, nil).(*EFIGPTData)
+	if !ok {
+		t.Fatalf("Unexpected event data type")
+	}
+	if d.Header.DiskGUID != diskGUID || len(d.Partitions) != 1 || d.Partitions[0].UniquePartitionGUID != espGUID ||
+		d.Partitions[0].Name != "EFI System Partition" {
+		t.Fatalf("Unexpected event data: %s", d)
+	}
+
+	var buf bytes.Buffer
+	if err := d.EncodeTo(&buf); err != nil {
+		t.Fatalf("EncodeTo failed: %v", err)
+	}
+	if !bytes.Equal(buf.Bytes(), data) {
+		t.Errorf("Unexpected encoding of unmodified event data")
+	}
+
+	d.Partitions[0].Name = "ESP"
+	d.Header.DiskGUID = espGUID
+	buf.Reset()
+	if err := d.EncodeTo(&buf); err != nil {
+		t.Fatalf("EncodeTo failed: %v", err)
+	}
+	d2, ok := DecodeEventData(5, EventTypeEFIGPTEvent, DigestMap{}, buf.Bytes(), nil).(*EFIGPTData)
+	if !ok {
+		t.Fatalf("Cannot decode modified event data")
+	}
+	if d2.Header.DiskGUID != espGUID || d2.Partitions[0].Name != "ESP" {
+		t.Errorf("Unexpected modified event data: %s", d2)
+	}
+
+	d.Partitions[0].Name = strings.Repeat("A", 37)
+	if err := d.EncodeTo(new(bytes.Buffer)); err == nil {
+		t.Errorf("EncodeTo should fail if a partition name is too long")
+	}
+}
+
+func TestEFIGPTDataFields(t *testing.T) {
+	diskGUID := MakeEFIGUID(0x0f8e2a43, 0x2a5e, 0x4b28, 0x9d1f, [...]uint8{0x6c, 0x4e, 0x8d, 0x54, 0x3f, 0x21})
+	espType := MakeEFIGUID(0xc12a7328, 0xf81f, 0x11d2, 0xba4b, [...]uint8{0x00, 0xa0, 0xc9, 0x3e, 0xc9, 0x3b})
+	espGUID := MakeEFIGUID(0x6a3c6f52, 0x1cbd, 0x4c9e, 0x8f27, [...]uint8{0x5b, 0x19, 0x30, 0x4e, 0x81, 0x6c})
+	otherType := MakeEFIGUID(0x11111111, 0x2222, 0x3333, 0x4444, [...]uint8{0x55, 0x55, 0x55, 0x55, 0x55, 0x55})
+	otherGUID := MakeEFIGUID(0x9b2d56ee, 0x4a3c, 0x4f7e, 0xa1c3, [...]uint8{0x2e, 0x44, 0x0b, 0x1f, 0x72, 0x9d})
+
+	data := makeTestGPTEventData(diskGUID, []testGPTPartition{
+		{typeGUID: espType, uniqueGUID: espGUID, name: "EFI System Partition"},
+		{typeGUID: otherType, uniqueGUID: otherGUID}})
+	// Set the LBA range and attributes of the first partition
+	binary.LittleEndian.PutUint64(data[100+32:], 2048)
+	binary.LittleEndian.PutUint64(data[100+40:], 1050623)
+	binary.LittleEndian.PutUint64(data[100+48:], 1)
+
+	d, ok := DecodeEventData(5, EventTypeEFIGPTEvent, DigestMap{}, data, nil).(*EFIGPTData)
+	if !ok {
+		t.Fatalf("Unexpected event data type")
+	}
+
+	h := d.Header
+	if h.Signature != 0x5452415020494645 || h.Revision != 0x00010000 || h.HeaderSize != 92 || h.MyLBA != 1 ||
+		h.DiskGUID != diskGUID || h.PartitionEntryLBA != 2 || h.NumberOfPartitionEntries != 128 || h.SizeOfPartitionEntry != 128 ||
+		h.PartitionEntryArrayCRC32 != 0x12345678 {
+		t.Errorf("Unexpected header: %+v", h)
+	}
+
+	if len(d.Partitions) != 2 {
+		t.Fatalf("Unexpected number of partitions")
+	}
+	p := d.Partitions[0]
+	if p.StartingLBA != 2048 || p.EndingLBA != 1050623 || p.Attributes != 1 {
+		t.Errorf("Unexpected partition entry: %s", p)
+	}
+	if p.PartitionTypeName() != "EFI System Partition" {
+		t.Errorf("Unexpected partition type name: %s", p.PartitionTypeName())
+	}
+	if d.Partitions[1].PartitionTypeName() != "" {
+		t.Errorf("Unexpected partition type name: %s", d.Partitions[1].PartitionTypeName())
+	}
+	expected := "PartitionTypeGUID: " + espType.String() + " (EFI System Partition), UniquePartitionGUID: " + espGUID.String() +
+		", StartingLBA: 2048, EndingLBA: 1050623, Attributes: 0x0000000000000001, Name: \"EFI System Partition\""
+	if p.String() != expected {
+		t.Errorf("Unexpected string: %s", p)
+	}
+}
@@ -0,0 +1,152 @@
+// Copyright 2019 Canonical Ltd.
+// Licensed under the LGPLv3 with static-linking exception.
+// See LICENCE file for details.
+
+package tcglog
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+
+	"golang.org/x/xerrors"
+)
+
+// EFIPlatformFirmwareBlob corresponds to the UEFI_PLATFORM_FIRMWARE_BLOB type, which identifies the location of a measured region
+// of platform firmware.
+type EFIPlatformFirmwareBlob struct {
+	data       []byte
+	BlobBase   uint64 // The physical address of the measured blob
+	BlobLength uint64 // The length of the measured blob
+}
+
+func (b *EFIPlatformFirmwareBlob) String() string {
+	return fmt.Sprintf("UEFI_PLATFORM_FIRMWARE_BLOB{BlobBase: 0x%x, BlobLength: %d}", b.BlobBase, b.BlobLength)
+}
+
+func (b *EFIPlatformFirmwareBlob) Bytes() []byte {
+	return b.data
+}
+
+// EncodeTo encodes this event data in to the form in which it appears in the event log.
+func (b *EFIPlatformFirmwareBlob) EncodeTo(w io.Writer) error {
+	return binary.Write(w, binary.LittleEndian, struct {
+		BlobBase   uint64
+		BlobLength uint64
+	}{b.BlobBase, b.BlobLength})
+}
+
+// https://trustedcomputinggroup.org/wp-content/uploads/TCG_PCClientSpecPlat_TPM_2p0_1p04_pub.pdf
+//  (section 9.2.5 "UEFI_PLATFORM_FIRMWARE_BLOB Structure")
+func decodeEventDataEFIPlatformFirmwareBlob(data []byte) (*EFIPlatformFirmwareBlob, error) {
+	if len(data) != 16 {
+		return nil, fmt.Errorf("invalid length (%d)", len(data))
+	}
+	return &EFIPlatformFirmwareBlob{
+		data:       data,
+		BlobBase:   binary.LittleEndian.Uint64(data[0:]),
+		BlobLength: binary.LittleEndian.Uint64(data[8:])}, nil
+}
+
+// EFIPlatformFirmwareBlob2 corresponds to the UEFI_PLATFORM_FIRMWARE_BLOB2 type, which identifies the location of a measured
+// region of platform firmware along with a description of it. It is the event data for EV_EFI_PLATFORM_FIRMWARE_BLOB2 and
+// EV_POST_CODE2 events.
+type EFIPlatformFirmwareBlob2 struct {
+	data            []byte
+	BlobDescription string // A description of the measured blob
+	BlobBase        uint64 // The physical address of the measured blob
+	BlobLength      uint64 // The length of the measured blob
+}
+
+func (b *EFIPlatformFirmwareBlob2) String() string {
+	return fmt.Sprintf("UEFI_PLATFORM_FIRMWARE_BLOB2{BlobDescription: \"%s\", BlobBase: 0x%x, BlobLength: %d}", EscapeString(b.BlobDescription),
+		b.BlobBase, b.BlobLength)
+}
+
+func (b *EFIPlatformFirmwareBlob2) Bytes() []byte {
+	return b.data
+}
+
+// EncodeTo encodes this event data in to the form in which it appears in the event log.
+func (b *EFIPlatformFirmwareBlob2) EncodeTo(w io.Writer) error {
+	if len(b.BlobDescription) > 0xff {
+		return errors.New("description too long")
+	}
+	var buf bytes.Buffer
+	buf.WriteByte(uint8(len(b.BlobDescription)))
+	buf.WriteString(b.BlobDescription)
+	binary.Write(&buf, binary.LittleEndian, b.BlobBase)
+	binary.Write(&buf, binary.LittleEndian, b.BlobLength)
+	_, err := buf.WriteTo(w)
+	return err
+}
+
+// readEFIDescription reads a description string that is prefixed with a single byte length, as used in the UEFI_PLATFORM_FIRMWARE_BLOB2
+// and UEFI_HANDOFF_TABLE_POINTERS2 types.
+func readEFIDescription(r io.Reader) (string, error) {
+	var n uint8
+	if err := binary.Read(r, binary.LittleEndian, &n); err != nil {
+		return "", xerrors.Errorf("cannot read size: %w", err)
+	}
+	desc := make([]byte, n)
+	if _, err := io.ReadFull(r, desc); err != nil {
+		return "", xerrors.Errorf("cannot read description: %w", err)
+	}
+	return string(desc), nil
+}
+
+// https://trustedcomputinggroup.org/wp-content/uploads/TCG_PCClient_PFP_r1p05_v23_pub.pdf
+//  (section 10.2.4 "UEFI_PLATFORM_FIRMWARE_BLOB2 Structure")
+func decodeEventDataEFIPlatformFirmwareBlob2(data []byte) (*EFIPlatformFirmwareBlob2, error) {
+	r := bytes.NewReader(data)
+
+	desc, err := readEFIDescription(r)
+	if err != nil {
+		return nil, xerrors.Errorf("cannot read blob description: %w", err)
+	}
+
+	d := &EFIPlatformFirmwareBlob2{data: data, BlobDescription: desc}
+	if err := binary.Read(r, binary.LittleEndian, &d.BlobBase); err != nil {
+		return nil, xerrors.Errorf("cannot read blob base: %w", err)
+	}
+	if err := binary.Read(r, binary.LittleEndian, &d.BlobLength); err != nil {
+		return nil, xerrors.Errorf("cannot read blob length: %w", err)
+	}
+	return d, nil
+}
+
+// https://trustedcomputinggroup.org/wp-content/uploads/TCG_PCClientSpecPlat_TPM_2p0_1p04_pub.pdf
+//  (section 9.2.5 "UEFI_PLATFORM_FIRMWARE_BLOB Structure", section 9.4.1 "Event Types")
+func decodeEventDataPostCode(data []byte) EventData {
+	// EV_POST_CODE events contain either a UEFI_PLATFORM_FIRMWARE_BLOB structure or an ASCII string such as "POST CODE",
+	// "SMM CODE" or "ACPI DATA". A 16-byte string is indistinguishable from a blob by length alone, so prefer the string if the
+	// event data is printable.
+	if isPrintableASCII(data) {
+		return &AsciiStringEventData{data: data}
+	}
+	if out, err := decodeEventDataEFIPlatformFirmwareBlob(data); err == nil {
+		return out
+	}
+	return nil
+}
+
+// https://trustedcomputinggroup.org/wp-content/uploads/TCG_PCClient_PFP_r1p05_v23_pub.pdf
+//  (section 3.3.4.1 "PCR[0] - SRTM, BIOS, Host Platform Extensions, Embedded Option ROMs and PI Drivers", section 10.4.1 "Event Types")
+func decodeEventDataSCRTMContents(data []byte) EventData {
+	// EV_S_CRTM_CONTENTS events contain either a UEFI_PLATFORM_FIRMWARE_BLOB structure, a UEFI_PLATFORM_FIRMWARE_BLOB2
+	// structure or a string describing the S-CRTM, such as "Boot Guard Measured S-CRTM".
+	if isPrintableASCII(data) {
+		return &AsciiStringEventData{data: data}
+	}
+	if out, err := decodeEventDataEFIPlatformFirmwareBlob(data); err == nil {
+		return out
+	}
+	if len(data) > 0 && len(data) == 1+int(data[0])+16 {
+		if out, err := decodeEventDataEFIPlatformFirmwareBlob2(data); err == nil {
+			return out
+		}
+	}
+	return nil
+}
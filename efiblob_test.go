@@ -0,0 +1,176 @@
+// Copyright 2019 Canonical Ltd.
+// Licensed under the LGPLv3 with static-linking exception.
+// See LICENCE file for details.
+
+package tcglog
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestDecodeEventDataPostCode(t *testing.T) {
+	blob := []byte{0x00, 0x00, 0x80, 0xff, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00}
+
+	for _, data := range []struct {
+		desc     string
+		data     []byte
+		expected string
+	}{
+		{
+			desc:     "Blob",
+			data:     blob,
+			expected: "UEFI_PLATFORM_FIRMWARE_BLOB{BlobBase: 0xff800000, BlobLength: 524288}",
+		},
+		{
+			desc:     "String",
+			data:     []byte("POST CODE"),
+			expected: "POST CODE",
+		},
+		{
+			desc:     "String16",
+			data:     []byte("Embedded UEFI Dr"),
+			expected: "Embedded UEFI Dr",
+		},
+		{
+			desc:     "Opaque",
+			data:     []byte{1, 2, 3, 4},
+			expected: "",
+		},
+	} {
+		t.Run(data.desc, func(t *testing.T) {
+			d := DecodeEventData(0, EventTypePostCode, DigestMap{}, data.data, nil)
+			if err, isErr := d.(error); isErr {
+				t.Fatalf("DecodeEventData failed: %v", err)
+			}
+			if d.String() != data.expected {
+				t.Errorf("Unexpected string: %s", d)
+			}
+		})
+	}
+
+	d, ok := DecodeEventData(0, EventTypePostCode, DigestMap{}, blob, nil).(*EFIPlatformFirmwareBlob)
+	if !ok {
+		t.Fatalf("Unexpected event data type")
+	}
+	if d.BlobBase != 0xff800000 || d.BlobLength != 0x80000 {
+		t.Errorf("Unexpected blob: %s", d)
+	}
+	var buf bytes.Buffer
+	if err := d.EncodeTo(&buf); err != nil {
+		t.Fatalf("EncodeTo failed: %v", err)
+	}
+	if !bytes.Equal(buf.Bytes(), blob) {
+		t.Errorf("Unexpected encoding: %x", buf.Bytes())
+	}
+}
+
+func TestDecodeEventDataEFIPlatformFirmwareBlob2(t *testing.T) {
+	data := []byte{0x08, 'P', 'O', 'S', 'T', 'C', 'O', 'D', 'E',
+		0x00, 0x00, 0x80, 0xff, 0x00, 0x00, 0x00, 0x00,
+		0x00, 0x00, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00}
+
+	for _, eventType := range []EventType{EventTypeEFIPlatformFirmwareBlob2, EventTypePostCode2} {
+		t.Run(eventType.String(), func(t *testing.T) {
+			d, ok := DecodeEventData(0, eventType, DigestMap{}, data, nil).(*EFIPlatformFirmwareBlob2)
+			if !ok {
+				t.Fatalf("Unexpected event data type")
+			}
+			if d.BlobDescription != "POSTCODE" || d.BlobBase != 0xff800000 || d.BlobLength != 0x80000 {
+				t.Errorf("Unexpected blob: %s", d)
+			}
+			if d.String() != "UEFI_PLATFORM_FIRMWARE_BLOB2{BlobDescription: \"POSTCODE\", BlobBase: 0xff800000, BlobLength: 524288}" {
+				t.Errorf("Unexpected string: %s", d)
+			}
+			var buf bytes.Buffer
+			if err := d.EncodeTo(&buf); err != nil {
+				t.Fatalf("EncodeTo failed: %v", err)
+			}
+			if !bytes.Equal(buf.Bytes(), data) {
+				t.Errorf("Unexpected encoding: %x", buf.Bytes())
+			}
+		})
+	}
+
+	for _, data := range []struct {
+		desc string
+		data []byte
+		err  string
+	}{
+		{
+			desc: "TruncatedDescription",
+			data: []byte{0x08, 'P', 'O', 'S', 'T'},
+			err:  "cannot decode EV_EFI_PLATFORM_FIRMWARE_BLOB2 event data: cannot read blob description: cannot read description: unexpected EOF",
+		},
+		{
+			desc: "TruncatedLength",
+			data: []byte{0x00, 0x00, 0x00, 0x80, 0xff, 0x00, 0x00, 0x00, 0x00, 0x00},
+			err:  "cannot decode EV_EFI_PLATFORM_FIRMWARE_BLOB2 event data: cannot read blob length: unexpected EOF",
+		},
+	} {
+		t.Run(data.desc, func(t *testing.T) {
+			err, isErr := DecodeEventData(0, EventTypeEFIPlatformFirmwareBlob2, DigestMap{}, data.data, nil).(error)
+			if !isErr {
+				t.Fatalf("Expected an error")
+			}
+			if err.Error() != data.err {
+				t.Errorf("Unexpected error: %v", err)
+			}
+		})
+	}
+}
+
+func TestDecodeEventDataEFIPlatformFirmwareBlob(t *testing.T) {
+	data := []byte{0x00, 0x00, 0x80, 0xff, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00}
+	d, ok := DecodeEventData(0, EventTypeEFIPlatformFirmwareBlob, DigestMap{}, data, nil).(*EFIPlatformFirmwareBlob)
+	if !ok {
+		t.Fatalf("Unexpected event data type")
+	}
+	if d.BlobBase != 0xff800000 || d.BlobLength != 0x80000 {
+		t.Errorf("Unexpected blob: %s", d)
+	}
+
+	if _, isErr := DecodeEventData(0, EventTypeEFIPlatformFirmwareBlob, DigestMap{}, data[:8], nil).(error); !isErr {
+		t.Errorf("Expected an error")
+	}
+}
+
+func TestDecodeEventDataSCRTMContents(t *testing.T) {
+	for _, data := range []struct {
+		desc     string
+		data     []byte
+		expected string
+	}{
+		{
+			desc:     "Blob",
+			data:     []byte{0x00, 0x00, 0xf0, 0xff, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x01, 0x00, 0x00, 0x00, 0x00, 0x00},
+			expected: "UEFI_PLATFORM_FIRMWARE_BLOB{BlobBase: 0xfff00000, BlobLength: 65536}",
+		},
+		{
+			desc: "Blob2",
+			data: []byte{0x04, 'S', 'E', 'C', '0',
+				0x00, 0x00, 0xf0, 0xff, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x01, 0x00, 0x00, 0x00, 0x00, 0x00},
+			expected: "UEFI_PLATFORM_FIRMWARE_BLOB2{BlobDescription: \"SEC0\", BlobBase: 0xfff00000, BlobLength: 65536}",
+		},
+		{
+			desc:     "String",
+			data:     []byte("Boot Guard Measured S-CRTM\x00"),
+			expected: "Boot Guard Measured S-CRTM",
+		},
+		{
+			desc:     "Opaque",
+			data:     []byte{0xff, 0x01},
+			expected: "",
+		},
+	} {
+		t.Run(data.desc, func(t *testing.T) {
+			d := DecodeEventData(0, EventTypeSCRTMContents, DigestMap{}, data.data, nil)
+			if err, isErr := d.(error); isErr {
+				t.Fatalf("DecodeEventData failed: %v", err)
+			}
+			if d.String() != data.expected {
+				t.Errorf("Unexpected string: %q", d)
+			}
+		})
+	}
+}
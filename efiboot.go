@@ -0,0 +1,127 @@
+// Copyright 2019 Canonical Ltd.
+// Licensed under the LGPLv3 with static-linking exception.
+// See LICENCE file for details.
+
+package tcglog
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"regexp"
+
+	"golang.org/x/xerrors"
+)
+
+// EFI_LOAD_OPTION attributes.
+const (
+	EFILoadOptionActive         uint32 = 0x00000001 // LOAD_OPTION_ACTIVE
+	EFILoadOptionForceReconnect uint32 = 0x00000002 // LOAD_OPTION_FORCE_RECONNECT
+	EFILoadOptionHidden         uint32 = 0x00000008 // LOAD_OPTION_HIDDEN
+	EFILoadOptionCategoryApp    uint32 = 0x00000100 // LOAD_OPTION_CATEGORY_APP
+)
+
+var loadOptionVariableRE = regexp.MustCompile(`^(Boot|Driver|SysPrep)[0-9A-F]{4}$`)
+
+// EFILoadOption corresponds to the EFI_LOAD_OPTION type, which is the contents of Boot####, Driver#### and SysPrep####
+// variables.
+type EFILoadOption struct {
+	Attributes   uint32 // The attributes of this load option (a combination of the EFILoadOption* values)
+	Description  string // The user readable description of this load option
+	FilePath     string // The textual representation of the device path of the image associated with this load option
+	FilePathData []byte // The raw device path
+	OptionalData []byte // Data that is passed to the loaded image
+}
+
+func (o *EFILoadOption) String() string {
+	return fmt.Sprintf("EFI_LOAD_OPTION{ Attributes: 0x%08x, Description: \"%s\", FilePath: %s, OptionalData: %x }", o.Attributes,
+		EscapeString(o.Description), EscapeString(o.FilePath), o.OptionalData)
+}
+
+// DecodeEFILoadOption decodes the supplied data as an EFI_LOAD_OPTION structure.
+//
+// https://uefi.org/sites/default/files/resources/UEFI_Spec_2_8_final.pdf
+//  (section 3.1.3 "Load Options")
+func DecodeEFILoadOption(data []byte) (*EFILoadOption, error) {
+	r := bytes.NewReader(data)
+
+	var hdr struct {
+		Attributes         uint32
+		FilePathListLength uint16
+	}
+	if err := binary.Read(r, binary.LittleEndian, &hdr); err != nil {
+		return nil, xerrors.Errorf("cannot read header: %w", err)
+	}
+
+	var description []uint16
+	for {
+		var c uint16
+		if err := binary.Read(r, binary.LittleEndian, &c); err != nil {
+			return nil, xerrors.Errorf("cannot read description: %w", err)
+		}
+		if c == 0 {
+			break
+		}
+		description = append(description, c)
+	}
+
+	o := &EFILoadOption{Attributes: hdr.Attributes, Description: convertUtf16ToString(description)}
+
+	if int(hdr.FilePathListLength) > r.Len() {
+		return nil, errors.New("invalid file path list length")
+	}
+	o.FilePathData = make([]byte, hdr.FilePathListLength)
+	io.ReadFull(r, o.FilePathData)
+	path, err := decodeDevicePath(o.FilePathData)
+	if err != nil {
+		return nil, xerrors.Errorf("cannot decode file path: %w", err)
+	}
+	o.FilePath = path
+
+	o.OptionalData = make([]byte, r.Len())
+	io.ReadFull(r, o.OptionalData)
+
+	return o, nil
+}
+
+// IsLoadOption indicates whether this is the measurement of a Boot####, Driver#### or SysPrep#### variable, which contains an
+// EFI_LOAD_OPTION structure.
+func (e *EFIVariableData) IsLoadOption() bool {
+	return e.VariableName == EFIGlobalVariableGuid && loadOptionVariableRE.MatchString(e.UnicodeName)
+}
+
+// LoadOption decodes the variable data as an EFI_LOAD_OPTION structure. This returns an error if this is not the measurement of
+// a load option variable (see IsLoadOption) or the variable data cannot be decoded.
+func (e *EFIVariableData) LoadOption() (*EFILoadOption, error) {
+	if !e.IsLoadOption() {
+		return nil, fmt.Errorf("%s-%s is not a load option", e.UnicodeName, e.VariableName)
+	}
+	return DecodeEFILoadOption(e.VariableData)
+}
+
+// IsLoadOrder indicates whether this is the measurement of the BootOrder or DriverOrder variable, which contains an ordered list
+// of load option numbers.
+func (e *EFIVariableData) IsLoadOrder() bool {
+	return e.VariableName == EFIGlobalVariableGuid && (e.UnicodeName == "BootOrder" || e.UnicodeName == "DriverOrder")
+}
+
+// LoadOrder decodes the variable data as an ordered list of load options, returned as the names of the corresponding load option
+// variables (eg, "Boot0001" for BootOrder). This returns an error if this is not the measurement of a load order variable (see
+// IsLoadOrder) or the variable data cannot be decoded.
+func (e *EFIVariableData) LoadOrder() ([]string, error) {
+	if !e.IsLoadOrder() {
+		return nil, fmt.Errorf("%s-%s is not a load order variable", e.UnicodeName, e.VariableName)
+	}
+	if len(e.VariableData)%2 != 0 {
+		return nil, fmt.Errorf("invalid variable data length (%d)", len(e.VariableData))
+	}
+
+	prefix := e.UnicodeName[:len(e.UnicodeName)-len("Order")]
+	var out []string
+	for i := 0; i < len(e.VariableData); i += 2 {
+		out = append(out, fmt.Sprintf("%s%04X", prefix, binary.LittleEndian.Uint16(e.VariableData[i:])))
+	}
+	return out, nil
+}
@@ -0,0 +1,98 @@
+// Copyright 2019 Canonical Ltd.
+// Licensed under the LGPLv3 with static-linking exception.
+// See LICENCE file for details.
+
+package tcglog
+
+import (
+	"bytes"
+	"encoding/binary"
+	"testing"
+)
+
+func makeTestLoadOption(attrs uint32, description string, devicePath, optionalData []byte) []byte {
+	var b bytes.Buffer
+	binary.Write(&b, binary.LittleEndian, attrs)
+	binary.Write(&b, binary.LittleEndian, uint16(len(devicePath)))
+	binary.Write(&b, binary.LittleEndian, append(convertStringToUtf16(description), 0))
+	b.Write(devicePath)
+	b.Write(optionalData)
+	return b.Bytes()
+}
+
+func TestEFIVariableDataLoadOption(t *testing.T) {
+	espGUID := MakeEFIGUID(0x6a3c6f52, 0x1cbd, 0x4c9e, 0x8f27, [...]uint8{0x5b, 0x19, 0x30, 0x4e, 0x81, 0x6c})
+	imageLoad := makeTestHDImageLoadEventData(espGUID, "\\EFI\\ubuntu\\shimx64.efi")
+	devicePath := imageLoad[32:]
+
+	data := makeTestLoadOption(EFILoadOptionActive, "ubuntu", devicePath, []byte{1, 2, 3})
+	varData := &EFIVariableData{VariableName: EFIGlobalVariableGuid, UnicodeName: "Boot0001", VariableData: data}
+	if !varData.IsLoadOption() {
+		t.Fatalf("Boot0001 should be a load option")
+	}
+	opt, err := varData.LoadOption()
+	if err != nil {
+		t.Fatalf("LoadOption failed: %v", err)
+	}
+	if opt.Attributes != EFILoadOptionActive || opt.Description != "ubuntu" || !bytes.Equal(opt.FilePathData, devicePath) ||
+		!bytes.Equal(opt.OptionalData, []byte{1, 2, 3}) {
+		t.Errorf("Unexpected load option: %s", opt)
+	}
+	expectedPath := "HD(1,GPT,6a3c6f52-1cbd-4c9e-8f27-5b19304e816c,0x800,0x100800)/\\EFI\\ubuntu\\shimx64.efi"
+	if opt.FilePath != expectedPath {
+		t.Errorf("Unexpected file path: %q", opt.FilePath)
+	}
+
+	for _, name := range []string{"BootOrder", "Boot001", "boot0001", "BootNext"} {
+		varData.UnicodeName = name
+		if varData.IsLoadOption() {
+			t.Errorf("%s should not be a load option", name)
+		}
+	}
+
+	for i, invalid := range [][]byte{data[:5], data[:12], makeTestLoadOption(0, "foo", []byte{0x04, 0x04, 0xff, 0xff}, nil)} {
+		if _, err := DecodeEFILoadOption(invalid); err == nil {
+			t.Errorf("DecodeEFILoadOption should have failed for invalid data %d", i)
+		}
+	}
+}
+
+func TestEFIVariableDataLoadOrder(t *testing.T) {
+	for _, data := range []struct {
+		name     string
+		data     []byte
+		expected []string
+	}{
+		{name: "BootOrder", data: []byte{0x01, 0x00, 0x0a, 0x00, 0x00, 0x00}, expected: []string{"Boot0001", "Boot000A", "Boot0000"}},
+		{name: "DriverOrder", data: []byte{0x34, 0x12}, expected: []string{"Driver1234"}},
+		{name: "BootOrder", data: nil},
+	} {
+		t.Run(data.name, func(t *testing.T) {
+			varData := &EFIVariableData{VariableName: EFIGlobalVariableGuid, UnicodeName: data.name, VariableData: data.data}
+			if !varData.IsLoadOrder() {
+				t.Fatalf("%s should be a load order variable", data.name)
+			}
+			order, err := varData.LoadOrder()
+			if err != nil {
+				t.Fatalf("LoadOrder failed: %v", err)
+			}
+			if len(order) != len(data.expected) {
+				t.Fatalf("Unexpected load order: %v", order)
+			}
+			for i := range order {
+				if order[i] != data.expected[i] {
+					t.Errorf("Unexpected load order: %v", order)
+				}
+			}
+		})
+	}
+
+	varData := &EFIVariableData{VariableName: EFIGlobalVariableGuid, UnicodeName: "BootOrder", VariableData: []byte{0x01}}
+	if _, err := varData.LoadOrder(); err == nil {
+		t.Errorf("LoadOrder should have failed for an odd length")
+	}
+	varData = &EFIVariableData{VariableName: EFIImageSecurityDatabaseGuid, UnicodeName: "BootOrder"}
+	if varData.IsLoadOrder() {
+		t.Errorf("BootOrder with the wrong GUID should not be a load order variable")
+	}
+}
@@ -0,0 +1,227 @@
+// Copyright 2019 Canonical Ltd.
+// Licensed under the LGPLv3 with static-linking exception.
+// See LICENCE file for details.
+
+package tcglog
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+
+	"golang.org/x/xerrors"
+)
+
+var (
+	// EFIGlobalVariableGuid is the GUID of the namespace for architecturally defined variables, such as PK and KEK.
+	EFIGlobalVariableGuid = MakeEFIGUID(0x8be4df61, 0x93ca, 0x11d2, 0xaa0d, [...]uint8{0x00, 0xe0, 0x98, 0x03, 0x2b, 0x8c})
+
+	// EFIImageSecurityDatabaseGuid is the GUID of the namespace for the authorized and forbidden signature databases (db and
+	// dbx).
+	EFIImageSecurityDatabaseGuid = MakeEFIGUID(0xd719b2cb, 0x3d3a, 0x4596, 0xa3bc, [...]uint8{0xda, 0xd0, 0x0e, 0x67, 0x65, 0x6f})
+
+	EFICertSha1Guid       = MakeEFIGUID(0x826ca512, 0xcf10, 0x4ac9, 0xb187, [...]uint8{0xbe, 0x01, 0x49, 0x66, 0x31, 0xbd}) // EFI_CERT_SHA1_GUID
+	EFICertSha256Guid     = MakeEFIGUID(0xc1c41626, 0x504c, 0x4092, 0xaca9, [...]uint8{0x41, 0xf9, 0x36, 0x93, 0x43, 0x28}) // EFI_CERT_SHA256_GUID
+	EFICertRSA2048Guid    = MakeEFIGUID(0x3c5766e8, 0x269c, 0x4e34, 0xaa14, [...]uint8{0xed, 0x77, 0x6e, 0x85, 0xb3, 0xb6}) // EFI_CERT_RSA2048_GUID
+	EFICertX509Guid       = MakeEFIGUID(0xa5c059a1, 0x94e4, 0x4aa7, 0x87b5, [...]uint8{0xab, 0x15, 0x5c, 0x2b, 0xf0, 0x72}) // EFI_CERT_X509_GUID
+	EFICertX509Sha256Guid = MakeEFIGUID(0x3bd2a492, 0x96c0, 0x4079, 0xb420, [...]uint8{0xfc, 0xf9, 0x8e, 0xf1, 0x03, 0xed}) // EFI_CERT_X509_SHA256_GUID
+)
+
+func efiSignatureTypeString(t EFIGUID) string {
+	switch t {
+	case EFICertSha1Guid:
+		return "SHA1"
+	case EFICertSha256Guid:
+		return "SHA256"
+	case EFICertRSA2048Guid:
+		return "RSA2048"
+	case EFICertX509Guid:
+		return "X509"
+	case EFICertX509Sha256Guid:
+		return "X509_SHA256"
+	default:
+		return t.String()
+	}
+}
+
+// EFISignatureData corresponds to the EFI_SIGNATURE_DATA type.
+type EFISignatureData struct {
+	SignatureType  EFIGUID // The type of this signature, from the EFI_SIGNATURE_LIST that contains it
+	SignatureOwner EFIGUID // The identifier of the agent that added this signature
+	Data           []byte  // The signature data, the format of which depends on SignatureType
+}
+
+func (d *EFISignatureData) String() string {
+	var builder bytes.Buffer
+	fmt.Fprintf(&builder, "EFI_SIGNATURE_DATA{ SignatureType: %s, SignatureOwner: %s, ", efiSignatureTypeString(d.SignatureType),
+		d.SignatureOwner.stringWithName())
+	switch d.SignatureType {
+	case EFICertX509Guid:
+		if info, err := d.CertificateInfo(); err == nil {
+			fmt.Fprintf(&builder, "%s }", info)
+			return builder.String()
+		}
+	}
+	fmt.Fprintf(&builder, "SignatureData: %x }", d.Data)
+	return builder.String()
+}
+
+// Certificate decodes the signature data as a X.509 certificate. This returns an error if SignatureType is not EFICertX509Guid or
+// the data cannot be decoded.
+func (d *EFISignatureData) Certificate() (*x509.Certificate, error) {
+	if d.SignatureType != EFICertX509Guid {
+		return nil, fmt.Errorf("signature data has the wrong type (%s)", efiSignatureTypeString(d.SignatureType))
+	}
+	return x509.ParseCertificate(d.Data)
+}
+
+// CertificateInfo decodes the signature data as a X.509 certificate and returns a summary of it. This returns an error if
+// SignatureType is not EFICertX509Guid or the data cannot be decoded.
+func (d *EFISignatureData) CertificateInfo() (*X509CertificateInfo, error) {
+	cert, err := d.Certificate()
+	if err != nil {
+		return nil, err
+	}
+	return newX509CertificateInfo(cert), nil
+}
+
+func newX509CertificateInfo(cert *x509.Certificate) *X509CertificateInfo {
+	fingerprint := sha256.Sum256(cert.Raw)
+	return &X509CertificateInfo{
+		Subject:      cert.Subject.String(),
+		Issuer:       cert.Issuer.String(),
+		SerialNumber: fmt.Sprintf("%x", cert.SerialNumber),
+		Fingerprint:  fingerprint[:]}
+}
+
+// X509CertificateInfo is a summary of a X.509 certificate that appears in a log.
+type X509CertificateInfo struct {
+	Subject      string // The distinguished name of the subject
+	Issuer       string // The distinguished name of the issuer
+	SerialNumber string // The serial number in hexadecimal form
+	Fingerprint  []byte // The SHA-256 digest of the DER encoded certificate
+}
+
+func (i *X509CertificateInfo) String() string {
+	return fmt.Sprintf("Subject: \"%s\", Issuer: \"%s\", SerialNumber: %s, SHA256Fingerprint: %x",
+		EscapeString(i.Subject), EscapeString(i.Issuer), i.SerialNumber, i.Fingerprint)
+}
+
+// EFISignatureList corresponds to the EFI_SIGNATURE_LIST type.
+type EFISignatureList struct {
+	SignatureType EFIGUID             // The type of the signatures in this list
+	Header        []byte              // The signature header, the format of which depends on SignatureType
+	Signatures    []*EFISignatureData // The signatures in this list
+}
+
+// EFISignatureDatabase corresponds to a sequence of EFI_SIGNATURE_LIST structures, such as the contents of the PK, KEK, db and
+// dbx variables.
+type EFISignatureDatabase []*EFISignatureList
+
+// https://uefi.org/sites/default/files/resources/UEFI_Spec_2_8_final.pdf
+//  (section 32.4.1 "Signature Database")
+func decodeEFISignatureList(r *bytes.Reader) (*EFISignatureList, error) {
+	var hdr struct {
+		SignatureType       EFIGUID
+		SignatureListSize   uint32
+		SignatureHeaderSize uint32
+		SignatureSize       uint32
+	}
+	if err := binary.Read(r, binary.LittleEndian, &hdr); err != nil {
+		return nil, xerrors.Errorf("cannot read header: %w", err)
+	}
+
+	const hdrSize = 28
+	if hdr.SignatureListSize < hdrSize || int64(hdr.SignatureListSize-hdrSize) > int64(r.Len()) {
+		return nil, errors.New("invalid signature list size")
+	}
+	if hdr.SignatureHeaderSize > hdr.SignatureListSize-hdrSize {
+		return nil, errors.New("invalid signature header size")
+	}
+	sigsSize := hdr.SignatureListSize - hdrSize - hdr.SignatureHeaderSize
+	if hdr.SignatureSize < 16 || sigsSize%hdr.SignatureSize != 0 {
+		return nil, errors.New("invalid signature size")
+	}
+
+	l := &EFISignatureList{SignatureType: hdr.SignatureType, Header: make([]byte, hdr.SignatureHeaderSize)}
+	if _, err := io.ReadFull(r, l.Header); err != nil {
+		return nil, xerrors.Errorf("cannot read signature header: %w", err)
+	}
+
+	for i := uint32(0); i < sigsSize/hdr.SignatureSize; i++ {
+		d := &EFISignatureData{SignatureType: hdr.SignatureType, Data: make([]byte, hdr.SignatureSize-16)}
+		if _, err := io.ReadFull(r, d.SignatureOwner[:]); err != nil {
+			return nil, xerrors.Errorf("cannot read owner of signature %d: %w", i, err)
+		}
+		if _, err := io.ReadFull(r, d.Data); err != nil {
+			return nil, xerrors.Errorf("cannot read data of signature %d: %w", i, err)
+		}
+		l.Signatures = append(l.Signatures, d)
+	}
+
+	return l, nil
+}
+
+// DecodeEFISignatureDatabase decodes the supplied data as a sequence of EFI_SIGNATURE_LIST structures.
+func DecodeEFISignatureDatabase(data []byte) (EFISignatureDatabase, error) {
+	r := bytes.NewReader(data)
+	var db EFISignatureDatabase
+	for i := 0; r.Len() > 0; i++ {
+		l, err := decodeEFISignatureList(r)
+		if err != nil {
+			return nil, xerrors.Errorf("cannot decode signature list %d: %w", i, err)
+		}
+		db = append(db, l)
+	}
+	return db, nil
+}
+
+// IsSignatureDatabase indicates whether this is the measurement of one of the secure boot signature database variables (PK,
+// KEK, db or dbx).
+func (e *EFIVariableData) IsSignatureDatabase() bool {
+	switch {
+	case e.VariableName == EFIGlobalVariableGuid && (e.UnicodeName == "PK" || e.UnicodeName == "KEK"):
+		return true
+	case e.VariableName == EFIImageSecurityDatabaseGuid && (e.UnicodeName == "db" || e.UnicodeName == "dbx"):
+		return true
+	default:
+		return false
+	}
+}
+
+// SignatureDatabase decodes the variable data as a signature database. This returns an error if this is not the measurement of
+// a signature database variable (see IsSignatureDatabase) or the variable data cannot be decoded.
+func (e *EFIVariableData) SignatureDatabase() (EFISignatureDatabase, error) {
+	if !e.IsSignatureDatabase() {
+		return nil, fmt.Errorf("%s-%s is not a signature database", e.UnicodeName, e.VariableName)
+	}
+	return DecodeEFISignatureDatabase(e.VariableData)
+}
+
+// AuthoritySignature decodes the variable data of an EV_EFI_VARIABLE_AUTHORITY event, which records the entry in a signature
+// database that was used to authenticate an EFI image. The variable data is normally a single EFI_SIGNATURE_DATA structure, but
+// may also be just a DER encoded X.509 certificate (as measured by shim for its built-in vendor certificate). The signature type
+// isn't recorded in the event, so it is inferred from the data: X.509 certificates are recognized, and other signature data with
+// the length of a SHA-256 digest is assumed to be one.
+func (e *EFIVariableData) AuthoritySignature() (*EFISignatureData, error) {
+	if len(e.VariableData) > 16 {
+		if _, err := x509.ParseCertificate(e.VariableData[16:]); err == nil {
+			d := &EFISignatureData{SignatureType: EFICertX509Guid, Data: e.VariableData[16:]}
+			copy(d.SignatureOwner[:], e.VariableData)
+			return d, nil
+		}
+	}
+	if _, err := x509.ParseCertificate(e.VariableData); err == nil {
+		return &EFISignatureData{SignatureType: EFICertX509Guid, Data: e.VariableData}, nil
+	}
+	if len(e.VariableData) == 16+sha256.Size {
+		d := &EFISignatureData{SignatureType: EFICertSha256Guid, Data: e.VariableData[16:]}
+		copy(d.SignatureOwner[:], e.VariableData)
+		return d, nil
+	}
+	return nil, errors.New("variable data is not a recognized signature")
+}
@@ -0,0 +1,185 @@
+// Copyright 2019 Canonical Ltd.
+// Licensed under the LGPLv3 with static-linking exception.
+// See LICENCE file for details.
+
+package tcglog
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/binary"
+	"math/big"
+	"testing"
+	"time"
+)
+
+func makeTestCertificate(t *testing.T, cn string) []byte {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("GenerateKey failed: %v", err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1234),
+		Subject:      pkix.Name{CommonName: cn},
+		NotBefore:    time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC),
+		NotAfter:     time.Date(2040, 1, 1, 0, 0, 0, 0, time.UTC)}
+	cert, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatalf("CreateCertificate failed: %v", err)
+	}
+	return cert
+}
+
+func makeTestSignatureList(sigType, owner EFIGUID, sigs ...[]byte) []byte {
+	var b bytes.Buffer
+	b.Write(sigType[:])
+	binary.Write(&b, binary.LittleEndian, uint32(28+len(sigs)*(16+len(sigs[0]))))
+	binary.Write(&b, binary.LittleEndian, uint32(0))
+	binary.Write(&b, binary.LittleEndian, uint32(16+len(sigs[0])))
+	for _, sig := range sigs {
+		b.Write(owner[:])
+		b.Write(sig)
+	}
+	return b.Bytes()
+}
+
+func TestDecodeEFISignatureDatabase(t *testing.T) {
+	owner := MakeEFIGUID(0x77fa9abd, 0x0359, 0x4d32, 0xbd60, [...]uint8{0x28, 0xf4, 0xe7, 0x8f, 0x78, 0x4b})
+	cert := makeTestCertificate(t, "Test UEFI CA")
+	h1 := sha256.Sum256([]byte("foo"))
+	h2 := sha256.Sum256([]byte("bar"))
+
+	var db bytes.Buffer
+	db.Write(makeTestSignatureList(EFICertX509Guid, owner, cert))
+	db.Write(makeTestSignatureList(EFICertSha256Guid, owner, h1[:], h2[:]))
+
+	varData := &EFIVariableData{VariableName: EFIImageSecurityDatabaseGuid, UnicodeName: "dbx", VariableData: db.Bytes()}
+	if !varData.IsSignatureDatabase() {
+		t.Fatalf("dbx should be a signature database")
+	}
+	lists, err := varData.SignatureDatabase()
+	if err != nil {
+		t.Fatalf("SignatureDatabase failed: %v", err)
+	}
+	if len(lists) != 2 {
+		t.Fatalf("Unexpected number of signature lists (%d)", len(lists))
+	}
+
+	if lists[0].SignatureType != EFICertX509Guid || len(lists[0].Signatures) != 1 {
+		t.Fatalf("Unexpected first signature list")
+	}
+	c, err := lists[0].Signatures[0].Certificate()
+	if err != nil {
+		t.Fatalf("Certificate failed: %v", err)
+	}
+	if c.Subject.CommonName != "Test UEFI CA" {
+		t.Errorf("Unexpected certificate subject: %s", c.Subject)
+	}
+	if lists[0].Signatures[0].SignatureOwner != owner {
+		t.Errorf("Unexpected signature owner")
+	}
+
+	if lists[1].SignatureType != EFICertSha256Guid || len(lists[1].Signatures) != 2 {
+		t.Fatalf("Unexpected second signature list")
+	}
+	if !bytes.Equal(lists[1].Signatures[0].Data, h1[:]) || !bytes.Equal(lists[1].Signatures[1].Data, h2[:]) {
+		t.Errorf("Unexpected signature data")
+	}
+	if _, err := lists[1].Signatures[0].Certificate(); err == nil {
+		t.Errorf("Certificate should fail for a SHA256 signature")
+	}
+	expected := "EFI_SIGNATURE_DATA{ SignatureType: SHA256, SignatureOwner: " + owner.String() + " (MICROSOFT), SignatureData: " +
+		"2c26b46b68ffc68ff99b453c1d30413413422d706483bfa0f98a5e886266e7ae }"
+	if lists[1].Signatures[0].String() != expected {
+		t.Errorf("Unexpected string: %s", lists[1].Signatures[0])
+	}
+}
+
+func TestDecodeEFISignatureDatabaseInvalid(t *testing.T) {
+	owner := MakeEFIGUID(0x77fa9abd, 0x0359, 0x4d32, 0xbd60, [...]uint8{0x28, 0xf4, 0xe7, 0x8f, 0x78, 0x4b})
+	h := sha256.Sum256([]byte("foo"))
+	valid := makeTestSignatureList(EFICertSha256Guid, owner, h[:])
+
+	for _, data := range []struct {
+		desc string
+		data []byte
+	}{
+		{desc: "Truncated", data: valid[:len(valid)-1]},
+		{desc: "TruncatedHeader", data: valid[:20]},
+		{desc: "BadSignatureSize", data: func() []byte {
+			d := append([]byte(nil), valid...)
+			binary.LittleEndian.PutUint32(d[24:], 30)
+			return d
+		}()},
+		{desc: "BadHeaderSize", data: func() []byte {
+			d := append([]byte(nil), valid...)
+			binary.LittleEndian.PutUint32(d[20:], 0xffffffff)
+			return d
+		}()},
+	} {
+		t.Run(data.desc, func(t *testing.T) {
+			if _, err := DecodeEFISignatureDatabase(data.data); err == nil {
+				t.Errorf("DecodeEFISignatureDatabase should have failed")
+			}
+		})
+	}
+
+	varData := &EFIVariableData{VariableName: EFIGlobalVariableGuid, UnicodeName: "SecureBoot"}
+	if _, err := varData.SignatureDatabase(); err == nil {
+		t.Errorf("SignatureDatabase should fail for SecureBoot")
+	}
+}
+
+func TestEFIVariableDataAuthoritySignature(t *testing.T) {
+	owner := MakeEFIGUID(0x77fa9abd, 0x0359, 0x4d32, 0xbd60, [...]uint8{0x28, 0xf4, 0xe7, 0x8f, 0x78, 0x4b})
+	cert := makeTestCertificate(t, "Test UEFI CA 2011")
+	h := sha256.Sum256([]byte("foo"))
+
+	for _, data := range []struct {
+		desc         string
+		variableData []byte
+		sigType      EFIGUID
+		owner        EFIGUID
+		sigData      []byte
+	}{
+		{desc: "X509", variableData: append(owner[:], cert...), sigType: EFICertX509Guid, owner: owner, sigData: cert},
+		{desc: "BareX509", variableData: cert, sigType: EFICertX509Guid, sigData: cert},
+		{desc: "SHA256", variableData: append(owner[:], h[:]...), sigType: EFICertSha256Guid, owner: owner, sigData: h[:]},
+	} {
+		t.Run(data.desc, func(t *testing.T) {
+			varData := &EFIVariableData{VariableName: EFIImageSecurityDatabaseGuid, UnicodeName: "db", VariableData: data.variableData}
+			sig, err := varData.AuthoritySignature()
+			if err != nil {
+				t.Fatalf("AuthoritySignature failed: %v", err)
+			}
+			if sig.SignatureType != data.sigType || sig.SignatureOwner != data.owner || !bytes.Equal(sig.Data, data.sigData) {
+				t.Errorf("Unexpected signature: %s", sig)
+			}
+		})
+	}
+
+	varData := &EFIVariableData{VariableName: EFIImageSecurityDatabaseGuid, UnicodeName: "db", VariableData: append(owner[:], cert...)}
+	sig, err := varData.AuthoritySignature()
+	if err != nil {
+		t.Fatalf("AuthoritySignature failed: %v", err)
+	}
+	info, err := sig.CertificateInfo()
+	if err != nil {
+		t.Fatalf("CertificateInfo failed: %v", err)
+	}
+	fingerprint := sha256.Sum256(cert)
+	if info.Subject != "CN=Test UEFI CA 2011" || info.Issuer != "CN=Test UEFI CA 2011" || info.SerialNumber != "4d2" ||
+		!bytes.Equal(info.Fingerprint, fingerprint[:]) {
+		t.Errorf("Unexpected certificate info: %s", info)
+	}
+
+	varData.VariableData = []byte("foo")
+	if _, err := varData.AuthoritySignature(); err == nil {
+		t.Errorf("AuthoritySignature should have failed")
+	}
+}
@@ -0,0 +1,170 @@
+// Copyright 2019 Canonical Ltd.
+// Licensed under the LGPLv3 with static-linking exception.
+// See LICENCE file for details.
+
+package tcglog
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+
+	"golang.org/x/xerrors"
+)
+
+var (
+	EFISMBIOSTableGuid  = MakeEFIGUID(0xeb9d2d31, 0x2d88, 0x11d3, 0x9a16, [...]uint8{0x00, 0x90, 0x27, 0x3f, 0xc1, 0x4d}) // SMBIOS_TABLE_GUID
+	EFISMBIOS3TableGuid = MakeEFIGUID(0xf2fd1544, 0x9794, 0x4a2c, 0x992e, [...]uint8{0xe5, 0xbb, 0xcf, 0x20, 0xe3, 0x94}) // SMBIOS3_TABLE_GUID
+	EFIACPITableGuid    = MakeEFIGUID(0xeb9d2d30, 0x2d88, 0x11d3, 0x9a16, [...]uint8{0x00, 0x90, 0x27, 0x3f, 0xc1, 0x4d}) // ACPI_TABLE_GUID
+	EFIACPI20TableGuid  = MakeEFIGUID(0x8868e871, 0xe4f1, 0x11d3, 0xbc22, [...]uint8{0x00, 0x80, 0xc7, 0x3c, 0x88, 0x81}) // EFI_ACPI_20_TABLE_GUID
+)
+
+// efiConfigurationTableNames maps well known configuration table GUIDs to human readable names.
+var efiConfigurationTableNames = map[EFIGUID]string{
+	EFISMBIOSTableGuid:  "SMBIOS",
+	EFISMBIOS3TableGuid: "SMBIOS3",
+	EFIACPITableGuid:    "ACPI",
+	EFIACPI20TableGuid:  "ACPI 2.0",
+	MakeEFIGUID(0xeb9d2d2f, 0x2d88, 0x11d3, 0x9a16, [...]uint8{0x00, 0x90, 0x27, 0x3f, 0xc1, 0x4d}): "MPS",
+	MakeEFIGUID(0xeb9d2d32, 0x2d88, 0x11d3, 0x9a16, [...]uint8{0x00, 0x90, 0x27, 0x3f, 0xc1, 0x4d}): "SAL System Table",
+	MakeEFIGUID(0x87367f87, 0x1119, 0x41ce, 0xaaec, [...]uint8{0x8b, 0xe0, 0x11, 0x01, 0xf5, 0x58}): "JSON Config Data",
+	MakeEFIGUID(0x35e7a725, 0x8dd2, 0x4cac, 0x8011, [...]uint8{0x33, 0xcd, 0xa8, 0x10, 0x90, 0x56}): "JSON Capsule Data",
+	MakeEFIGUID(0xdbc461c3, 0xb3de, 0x422a, 0xb9b4, [...]uint8{0x98, 0x86, 0xfd, 0x49, 0xa1, 0xe5}): "JSON Capsule Result",
+	MakeEFIGUID(0xb1b621d5, 0xf19c, 0x41a5, 0x830b, [...]uint8{0xd9, 0x15, 0x2c, 0x69, 0xaa, 0xe0}): "Device Tree",
+	MakeEFIGUID(0xdcfa911d, 0x26eb, 0x469f, 0xa220, [...]uint8{0x38, 0xb7, 0xdc, 0x46, 0x12, 0x20}): "Memory Attributes",
+	MakeEFIGUID(0xeb66918a, 0x7eef, 0x402a, 0x842e, [...]uint8{0x93, 0x1d, 0x21, 0xc3, 0x8a, 0xe9}): "RT Properties",
+	MakeEFIGUID(0x880aaca3, 0x4adc, 0x4a04, 0x9079, [...]uint8{0xb7, 0x47, 0x34, 0x08, 0x25, 0xe5}): "Properties",
+}
+
+// EFIConfigurationTable corresponds to the EFI_CONFIGURATION_TABLE type.
+type EFIConfigurationTable struct {
+	VendorGuid  EFIGUID // The GUID that identifies the table
+	VendorTable uint64  // The address of the table
+}
+
+// Name returns a human readable name for this table if it is well known or has been registered with RegisterEFIGUIDName, or an
+// empty string if it isn't.
+func (t *EFIConfigurationTable) Name() string {
+	return t.VendorGuid.Name()
+}
+
+func (t *EFIConfigurationTable) String() string {
+	return fmt.Sprintf("{ VendorGuid: %s, VendorTable: 0x%016x }", t.VendorGuid.stringWithName(), t.VendorTable)
+}
+
+// EFIHandoffTablePointers corresponds to the UEFI_HANDOFF_TABLE_POINTERS type and is the event data associated with the
+// measurement of configuration tables that are handed off to the OS, such as SMBIOS tables.
+type EFIHandoffTablePointers struct {
+	data   []byte
+	Tables []EFIConfigurationTable
+}
+
+func (e *EFIHandoffTablePointers) String() string {
+	var builder bytes.Buffer
+	builder.WriteString("UEFI_HANDOFF_TABLE_POINTERS{ TableEntry: [")
+	for i := range e.Tables {
+		if i > 0 {
+			builder.WriteString(", ")
+		}
+		builder.WriteString(e.Tables[i].String())
+	}
+	builder.WriteString("] }")
+	return builder.String()
+}
+
+func (e *EFIHandoffTablePointers) Bytes() []byte {
+	return e.data
+}
+
+// EncodeTo encodes this event data in to the form in which it appears in the event log.
+func (e *EFIHandoffTablePointers) EncodeTo(w io.Writer) error {
+	var b bytes.Buffer
+	binary.Write(&b, binary.LittleEndian, uint64(len(e.Tables)))
+	binary.Write(&b, binary.LittleEndian, e.Tables)
+	_, err := b.WriteTo(w)
+	return err
+}
+
+// https://trustedcomputinggroup.org/wp-content/uploads/TCG_PCClientSpecPlat_TPM_2p0_1p04_pub.pdf
+//  (section 9.4.4 "UEFI_HANDOFF_TABLE_POINTERS Structure")
+func decodeEventDataEFIHandoffTables(data []byte) (*EFIHandoffTablePointers, error) {
+	tables, err := readEFIConfigurationTables(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	return &EFIHandoffTablePointers{data: data, Tables: tables}, nil
+}
+
+// EFIHandoffTablePointers2 corresponds to the UEFI_HANDOFF_TABLE_POINTERS2 type and is the event data associated with
+// EV_EFI_HANDOFF_TABLES2 events. It is the same as UEFI_HANDOFF_TABLE_POINTERS with the addition of a description.
+type EFIHandoffTablePointers2 struct {
+	data             []byte
+	TableDescription string
+	Tables           []EFIConfigurationTable
+}
+
+func (e *EFIHandoffTablePointers2) String() string {
+	var builder bytes.Buffer
+	fmt.Fprintf(&builder, "UEFI_HANDOFF_TABLE_POINTERS2{ TableDescription: \"%s\", TableEntry: [", EscapeString(e.TableDescription))
+	for i := range e.Tables {
+		if i > 0 {
+			builder.WriteString(", ")
+		}
+		builder.WriteString(e.Tables[i].String())
+	}
+	builder.WriteString("] }")
+	return builder.String()
+}
+
+func (e *EFIHandoffTablePointers2) Bytes() []byte {
+	return e.data
+}
+
+// EncodeTo encodes this event data in to the form in which it appears in the event log.
+func (e *EFIHandoffTablePointers2) EncodeTo(w io.Writer) error {
+	if len(e.TableDescription) > 0xff {
+		return errors.New("description too long")
+	}
+	var b bytes.Buffer
+	b.WriteByte(uint8(len(e.TableDescription)))
+	b.WriteString(e.TableDescription)
+	binary.Write(&b, binary.LittleEndian, uint64(len(e.Tables)))
+	binary.Write(&b, binary.LittleEndian, e.Tables)
+	_, err := b.WriteTo(w)
+	return err
+}
+
+func readEFIConfigurationTables(r *bytes.Reader) ([]EFIConfigurationTable, error) {
+	var n uint64
+	if err := binary.Read(r, binary.LittleEndian, &n); err != nil {
+		return nil, xerrors.Errorf("cannot read number of tables: %w", err)
+	}
+	if n > uint64(r.Len()/binary.Size(EFIConfigurationTable{})) {
+		return nil, errors.New("invalid number of tables")
+	}
+
+	tables := make([]EFIConfigurationTable, n)
+	if err := binary.Read(r, binary.LittleEndian, tables); err != nil {
+		return nil, xerrors.Errorf("cannot read tables: %w", err)
+	}
+	return tables, nil
+}
+
+// https://trustedcomputinggroup.org/wp-content/uploads/TCG_PCClient_PFP_r1p05_v23_pub.pdf
+//  (section 10.2.6 "UEFI_HANDOFF_TABLE_POINTERS2 Structure")
+func decodeEventDataEFIHandoffTables2(data []byte) (*EFIHandoffTablePointers2, error) {
+	r := bytes.NewReader(data)
+
+	desc, err := readEFIDescription(r)
+	if err != nil {
+		return nil, xerrors.Errorf("cannot read table description: %w", err)
+	}
+
+	tables, err := readEFIConfigurationTables(r)
+	if err != nil {
+		return nil, err
+	}
+	return &EFIHandoffTablePointers2{data: data, TableDescription: desc, Tables: tables}, nil
+}
@@ -0,0 +1,95 @@
+// Copyright 2019 Canonical Ltd.
+// Licensed under the LGPLv3 with static-linking exception.
+// See LICENCE file for details.
+
+package tcglog
+
+import (
+	"bytes"
+	"encoding/binary"
+	"testing"
+)
+
+func TestDecodeEFIHandoffTables(t *testing.T) {
+	unknown := MakeEFIGUID(0x11111111, 0x2222, 0x3333, 0x4444, [...]uint8{0x55, 0x55, 0x55, 0x55, 0x55, 0x55})
+
+	var data bytes.Buffer
+	binary.Write(&data, binary.LittleEndian, uint64(2))
+	data.Write(EFISMBIOS3TableGuid[:])
+	binary.Write(&data, binary.LittleEndian, uint64(0x7f8ef000))
+	data.Write(unknown[:])
+	binary.Write(&data, binary.LittleEndian, uint64(0x1000))
+
+	d, ok := DecodeEventData(1, EventTypeEFIHandoffTables, DigestMap{}, data.Bytes(), nil).(*EFIHandoffTablePointers)
+	if !ok {
+		t.Fatalf("Unexpected event data type")
+	}
+	if len(d.Tables) != 2 {
+		t.Fatalf("Unexpected number of tables")
+	}
+	if d.Tables[0].VendorGuid != EFISMBIOS3TableGuid || d.Tables[0].VendorTable != 0x7f8ef000 || d.Tables[0].Name() != "SMBIOS3" {
+		t.Errorf("Unexpected table: %s", &d.Tables[0])
+	}
+	if d.Tables[1].Name() != "" {
+		t.Errorf("Unexpected table name: %s", d.Tables[1].Name())
+	}
+	expected := "UEFI_HANDOFF_TABLE_POINTERS{ TableEntry: [{ VendorGuid: " + EFISMBIOS3TableGuid.String() +
+		" (SMBIOS3), VendorTable: 0x000000007f8ef000 }, { VendorGuid: " + unknown.String() + ", VendorTable: 0x0000000000001000 }] }"
+	if d.String() != expected {
+		t.Errorf("Unexpected string: %s", d)
+	}
+
+	var buf bytes.Buffer
+	if err := d.EncodeTo(&buf); err != nil {
+		t.Fatalf("EncodeTo failed: %v", err)
+	}
+	if !bytes.Equal(buf.Bytes(), data.Bytes()) {
+		t.Errorf("Unexpected encoding")
+	}
+
+	b := data.Bytes()
+	binary.LittleEndian.PutUint64(b, 3)
+	if _, isErr := DecodeEventData(1, EventTypeEFIHandoffTables, DigestMap{}, b, nil).(error); !isErr {
+		t.Errorf("Decoding should fail with an invalid number of tables")
+	}
+}
+
+func TestDecodeEFIHandoffTables2(t *testing.T) {
+	var data bytes.Buffer
+	data.WriteByte(6)
+	data.WriteString("SMBIOS")
+	binary.Write(&data, binary.LittleEndian, uint64(1))
+	data.Write(EFISMBIOSTableGuid[:])
+	binary.Write(&data, binary.LittleEndian, uint64(0x7f8ef000))
+
+	d, ok := DecodeEventData(1, EventTypeEFIHandoffTables2, DigestMap{}, data.Bytes(), nil).(*EFIHandoffTablePointers2)
+	if !ok {
+		t.Fatalf("Unexpected event data type")
+	}
+	if d.TableDescription != "SMBIOS" {
+		t.Errorf("Unexpected description: %s", d.TableDescription)
+	}
+	if len(d.Tables) != 1 || d.Tables[0].VendorGuid != EFISMBIOSTableGuid || d.Tables[0].VendorTable != 0x7f8ef000 {
+		t.Fatalf("Unexpected tables: %v", d.Tables)
+	}
+	if d.SMBIOSTable() != &d.Tables[0] {
+		t.Errorf("Unexpected SMBIOS table")
+	}
+	expected := "UEFI_HANDOFF_TABLE_POINTERS2{ TableDescription: \"SMBIOS\", TableEntry: [{ VendorGuid: " + EFISMBIOSTableGuid.String() +
+		" (SMBIOS), VendorTable: 0x000000007f8ef000 }] }"
+	if d.String() != expected {
+		t.Errorf("Unexpected string: %s", d)
+	}
+
+	var buf bytes.Buffer
+	if err := d.EncodeTo(&buf); err != nil {
+		t.Fatalf("EncodeTo failed: %v", err)
+	}
+	if !bytes.Equal(buf.Bytes(), data.Bytes()) {
+		t.Errorf("Unexpected encoding")
+	}
+
+	if _, isErr := DecodeEventData(1, EventTypeEFIHandoffTables2, DigestMap{}, data.Bytes()[:4], nil).(error); !isErr {
+		t.Errorf("Decoding should fail with a truncated description")
+	}
+}
@@ -0,0 +1,112 @@
+// Copyright 2019 Canonical Ltd.
+// Licensed under the LGPLv3 with static-linking exception.
+// See LICENCE file for details.
+
+package tcglog
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"golang.org/x/xerrors"
+)
+
+// EFIVariableReader provides access to the current value of EFI variables. ReadEFIVariable should return an error for which
+// xerrors.Is(err, os.ErrNotExist) is true if the variable doesn't exist.
+type EFIVariableReader interface {
+	ReadEFIVariable(name string, guid EFIGUID) ([]byte, error)
+}
+
+// DefaultEFIVarfsPath is the path at which efivarfs is normally mounted.
+const DefaultEFIVarfsPath = "/sys/firmware/efi/efivars"
+
+// EFIVarfs is an EFIVariableReader that reads variables from an efivarfs mount at the specified path, which defaults to
+// DefaultEFIVarfsPath if empty.
+type EFIVarfs string
+
+// ReadEFIVariable implements EFIVariableReader.ReadEFIVariable.
+func (p EFIVarfs) ReadEFIVariable(name string, guid EFIGUID) ([]byte, error) {
+	dir := string(p)
+	if dir == "" {
+		dir = DefaultEFIVarfsPath
+	}
+	data, err := ioutil.ReadFile(filepath.Join(dir, name+"-"+strings.Trim(guid.String(), "{}")))
+	if err != nil {
+		return nil, err
+	}
+	// The first 4 bytes of each file are the variable's attributes.
+	if len(data) < 4 {
+		return nil, errors.New("variable file is too short")
+	}
+	return data[4:], nil
+}
+
+// EFIVariableMismatch describes a measured EFI variable whose current value is different to the value recorded in the log.
+type EFIVariableMismatch struct {
+	Event   *Event
+	Current []byte // The current value of the variable
+	Missing bool   // The variable no longer exists
+}
+
+func (m *EFIVariableMismatch) String() string {
+	v := m.Event.Data.(*EFIVariableData)
+	if m.Missing {
+		return fmt.Sprintf("event %d in PCR %d (type: %v): variable %s-%s was deleted", m.Event.Index, m.Event.PCRIndex,
+			m.Event.EventType, v.UnicodeName, v.VariableName)
+	}
+	return fmt.Sprintf("event %d in PCR %d (type: %v): variable %s-%s was modified", m.Event.Index, m.Event.PCRIndex,
+		m.Event.EventType, v.UnicodeName, v.VariableName)
+}
+
+type efiVariableKey struct {
+	name string
+	guid EFIGUID
+}
+
+// CheckEFIVariables compares the variable data recorded by the EV_EFI_VARIABLE_DRIVER_CONFIG and EV_EFI_VARIABLE_BOOT events in
+// this log with the current contents of the corresponding variables, as read from the supplied reader (eg, EFIVarfs when running
+// on the measured machine). Only the last measurement of each variable is compared. Each returned mismatch indicates a variable
+// that has changed since boot, in which case the PCR values on the next boot will be different to those in the current log.
+// A variable that was measured with no data is treated as matching if it doesn't exist.
+func (l *Log) CheckEFIVariables(r EFIVariableReader) ([]*EFIVariableMismatch, error) {
+	var keys []efiVariableKey
+	last := make(map[efiVariableKey]*Event)
+	for _, e := range l.Events {
+		if e.EventType != EventTypeEFIVariableDriverConfig && e.EventType != EventTypeEFIVariableBoot {
+			continue
+		}
+		v, ok := e.Data.(*EFIVariableData)
+		if !ok {
+			continue
+		}
+		key := efiVariableKey{name: v.UnicodeName, guid: v.VariableName}
+		if _, seen := last[key]; !seen {
+			keys = append(keys, key)
+		}
+		last[key] = e
+	}
+
+	var out []*EFIVariableMismatch
+	for _, key := range keys {
+		e := last[key]
+		recorded := e.Data.(*EFIVariableData).VariableData
+
+		current, err := r.ReadEFIVariable(key.name, key.guid)
+		switch {
+		case xerrors.Is(err, os.ErrNotExist):
+			if len(recorded) > 0 {
+				out = append(out, &EFIVariableMismatch{Event: e, Missing: true})
+			}
+		case err != nil:
+			return nil, xerrors.Errorf("cannot read variable %s-%s: %w", key.name, key.guid, err)
+		case !bytes.Equal(current, recorded):
+			out = append(out, &EFIVariableMismatch{Event: e, Current: current})
+		}
+	}
+	return out, nil
+}
@@ -0,0 +1,78 @@
+// Copyright 2019 Canonical Ltd.
+// Licensed under the LGPLv3 with static-linking exception.
+// See LICENCE file for details.
+
+package tcglog
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestCheckEFIVariables(t *testing.T) {
+	b, err := NewLogBuilder(AlgorithmIdList{AlgorithmSha256}, nil)
+	if err != nil {
+		t.Fatalf("NewLogBuilder failed: %v", err)
+	}
+	addVariable := func(pcr PCRIndex, eventType EventType, name string, data []byte) {
+		var v bytes.Buffer
+		(&EFIVariableData{VariableName: EFIGlobalVariableGuid, UnicodeName: name, VariableData: data}).EncodeMeasuredBytes(&v)
+		if _, err := b.AddEvent(pcr, eventType, v.Bytes()); err != nil {
+			t.Fatalf("AddEvent failed: %v", err)
+		}
+	}
+	addVariable(7, EventTypeEFIVariableDriverConfig, "SecureBoot", []byte{0x01})
+	addVariable(7, EventTypeEFIVariableDriverConfig, "PK", nil)
+	addVariable(1, EventTypeEFIVariableBoot, "BootOrder", []byte{0x01, 0x00})
+	addVariable(1, EventTypeEFIVariableBoot, "Boot0001", []byte{0x01, 0x00, 0x00, 0x00})
+	addVariable(1, EventTypeEFIVariableBoot, "Boot0002", []byte{0x01, 0x00, 0x00, 0x00})
+	addVariable(1, EventTypeEFIVariableBoot, "BootOrder", []byte{0x02, 0x00})
+	log := b.Log()
+
+	dir, err := ioutil.TempDir("", "efivars")
+	if err != nil {
+		t.Fatalf("TempDir failed: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	writeVariable := func(name string, data []byte) {
+		path := filepath.Join(dir, name+"-8be4df61-93ca-11d2-aa0d-00e098032b8c")
+		if err := ioutil.WriteFile(path, append([]byte{0x07, 0x00, 0x00, 0x00}, data...), 0644); err != nil {
+			t.Fatalf("WriteFile failed: %v", err)
+		}
+	}
+	writeVariable("SecureBoot", []byte{0x01})
+	writeVariable("BootOrder", []byte{0x01, 0x00})
+	writeVariable("Boot0001", []byte{0x01, 0x00, 0x00, 0x00})
+
+	mismatches, err := log.CheckEFIVariables(EFIVarfs(dir))
+	if err != nil {
+		t.Fatalf("CheckEFIVariables failed: %v", err)
+	}
+	if len(mismatches) != 2 {
+		t.Fatalf("Unexpected number of mismatches (%d): %v", len(mismatches), mismatches)
+	}
+
+	// Only the last measurement of BootOrder is compared.
+	if mismatches[0].Event != log.Events[6] || mismatches[0].Missing || !bytes.Equal(mismatches[0].Current, []byte{0x01, 0x00}) {
+		t.Errorf("Unexpected mismatch: %s", mismatches[0])
+	}
+	if mismatches[1].Event != log.Events[5] || !mismatches[1].Missing {
+		t.Errorf("Unexpected mismatch: %s", mismatches[1])
+	}
+	if mismatches[1].String() != "event 2 in PCR 1 (type: EV_EFI_VARIABLE_BOOT): variable "+
+		"Boot0002-{8be4df61-93ca-11d2-aa0d-00e098032b8c} was deleted" {
+		t.Errorf("Unexpected string: %s", mismatches[1])
+	}
+
+	if err := ioutil.WriteFile(filepath.Join(dir, "PK-8be4df61-93ca-11d2-aa0d-00e098032b8c"), []byte{0x07, 0x00}, 0644); err != nil {
+		t.Fatalf("WriteFile failed: %v", err)
+	}
+	if _, err := log.CheckEFIVariables(EFIVarfs(dir)); err == nil || err.Error() !=
+		"cannot read variable PK-{8be4df61-93ca-11d2-aa0d-00e098032b8c}: variable file is too short" {
+		t.Errorf("Unexpected error: %v", err)
+	}
+}
@@ -0,0 +1,148 @@
+// Copyright 2019 Canonical Ltd.
+// Licensed under the LGPLv3 with static-linking exception.
+// See LICENCE file for details.
+
+package tcglog
+
+import (
+	"bytes"
+	"fmt"
+
+	"golang.org/x/xerrors"
+)
+
+// EFIVariableMeasurementScheme describes how firmware computes the digests of EV_EFI_VARIABLE_* events.
+type EFIVariableMeasurementScheme int
+
+const (
+	// EFIVariableMeasurementUnknown indicates that the scheme could not be determined, either because there are no events or
+	// because none of the events have digests that are consistent with a known scheme.
+	EFIVariableMeasurementUnknown EFIVariableMeasurementScheme = iota
+
+	// EFIVariableMeasurementFull indicates that the digests cover the entire UEFI_VARIABLE_DATA structure, as required by the
+	// TCG PC Client Platform Firmware Profile Specification.
+	EFIVariableMeasurementFull
+
+	// EFIVariableMeasurementDataOnly indicates that the digests cover only the variable contents.
+	EFIVariableMeasurementDataOnly
+
+	// EFIVariableMeasurementDataHash indicates that the digests are of a hash of the variable contents, computed using the same
+	// algorithm as the digest.
+	EFIVariableMeasurementDataHash
+
+	// EFIVariableMeasurementMixed indicates that different events are measured using different schemes.
+	EFIVariableMeasurementMixed
+)
+
+func (s EFIVariableMeasurementScheme) String() string {
+	switch s {
+	case EFIVariableMeasurementUnknown:
+		return "unknown"
+	case EFIVariableMeasurementFull:
+		return "full UEFI_VARIABLE_DATA"
+	case EFIVariableMeasurementDataOnly:
+		return "variable data only"
+	case EFIVariableMeasurementDataHash:
+		return "hash of variable data"
+	case EFIVariableMeasurementMixed:
+		return "mixed"
+	default:
+		return fmt.Sprintf("%%!(UNKNOWN_SCHEME=%d)", int(s))
+	}
+}
+
+// Digest computes the digest of the supplied variable using the specified algorithm, according to this scheme. This can be
+// used to predict the digests of EV_EFI_VARIABLE_* events. An error is returned if the scheme doesn't describe a single way of
+// computing digests.
+func (s EFIVariableMeasurementScheme) Digest(alg AlgorithmId, data *EFIVariableData) (Digest, error) {
+	if !alg.supported() {
+		return nil, fmt.Errorf("unsupported algorithm %v", alg)
+	}
+
+	switch s {
+	case EFIVariableMeasurementFull:
+		var b bytes.Buffer
+		if err := data.EncodeMeasuredBytes(&b); err != nil {
+			return nil, xerrors.Errorf("cannot encode variable: %w", err)
+		}
+		return alg.hash(b.Bytes()), nil
+	case EFIVariableMeasurementDataOnly:
+		return alg.hash(data.VariableData), nil
+	case EFIVariableMeasurementDataHash:
+		return alg.hash(alg.hash(data.VariableData)), nil
+	default:
+		return nil, fmt.Errorf("cannot compute digest for scheme %v", s)
+	}
+}
+
+// detectEFIVariableMeasurementScheme returns the scheme that is consistent with the digest of the supplied event for the
+// specified algorithm, or EFIVariableMeasurementUnknown if there isn't one.
+func detectEFIVariableMeasurementScheme(event *Event, alg AlgorithmId) EFIVariableMeasurementScheme {
+	data, ok := event.Data.(*EFIVariableData)
+	if !ok {
+		return EFIVariableMeasurementUnknown
+	}
+	for _, s := range []EFIVariableMeasurementScheme{
+		EFIVariableMeasurementFull, EFIVariableMeasurementDataOnly, EFIVariableMeasurementDataHash} {
+		digest, err := s.Digest(alg, data)
+		if err != nil {
+			continue
+		}
+		if bytes.Equal(digest, event.Digests[alg]) {
+			return s
+		}
+	}
+	return EFIVariableMeasurementUnknown
+}
+
+// EFIVariableMeasurementScheme detects how the firmware that produced this log computes the digests of events of the specified
+// type, which should be one of the EV_EFI_VARIABLE_* types. The digests of every event of this type are compared against each
+// known scheme using the first supported algorithm in the log. If the events are consistent with more than one scheme,
+// EFIVariableMeasurementMixed is returned. Events with digests that aren't consistent with any scheme are ignored.
+func (l *Log) EFIVariableMeasurementScheme(eventType EventType) EFIVariableMeasurementScheme {
+	var alg AlgorithmId
+	found := false
+	for _, a := range l.Algorithms {
+		if a.supported() {
+			alg = a
+			found = true
+			break
+		}
+	}
+	if !found {
+		return EFIVariableMeasurementUnknown
+	}
+
+	scheme := EFIVariableMeasurementUnknown
+	for _, e := range l.Events {
+		if e.EventType != eventType {
+			continue
+		}
+		s := detectEFIVariableMeasurementScheme(e, alg)
+		switch {
+		case s == EFIVariableMeasurementUnknown:
+		case scheme == EFIVariableMeasurementUnknown:
+			scheme = s
+		case scheme != s:
+			return EFIVariableMeasurementMixed
+		}
+	}
+	return scheme
+}
+
+// EFIVariableMeasurementSchemes returns the measurement scheme detected for each of the EV_EFI_VARIABLE_* event types that appear
+// in this log. See EFIVariableMeasurementScheme.
+func (l *Log) EFIVariableMeasurementSchemes() map[EventType]EFIVariableMeasurementScheme {
+	out := make(map[EventType]EFIVariableMeasurementScheme)
+	for _, e := range l.Events {
+		switch e.EventType {
+		case EventTypeEFIVariableDriverConfig, EventTypeEFIVariableBoot, EventTypeEFIVariableAuthority:
+		default:
+			continue
+		}
+		if _, ok := out[e.EventType]; !ok {
+			out[e.EventType] = l.EFIVariableMeasurementScheme(e.EventType)
+		}
+	}
+	return out
+}
@@ -0,0 +1,88 @@
+// Copyright 2019 Canonical Ltd.
+// Licensed under the LGPLv3 with static-linking exception.
+// See LICENCE file for details.
+
+package tcglog
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+)
+
+func TestEFIVariableMeasurementScheme(t *testing.T) {
+	makeEvent := func(eventType EventType, name string, scheme EFIVariableMeasurementScheme) *Event {
+		varData := &EFIVariableData{VariableName: EFIGlobalVariableGuid, UnicodeName: name, VariableData: []byte{0x01, 0x00}}
+		var b bytes.Buffer
+		varData.EncodeMeasuredBytes(&b)
+
+		event := makeTestEvent(1, eventType, b.Bytes(), AlgorithmSha1, AlgorithmSha256)
+		for _, alg := range []AlgorithmId{AlgorithmSha1, AlgorithmSha256} {
+			digest, err := scheme.Digest(alg, varData)
+			if err != nil {
+				t.Fatalf("Digest failed: %v", err)
+			}
+			event.Digests[alg] = digest
+		}
+		return event
+	}
+
+	for _, data := range []struct {
+		desc     string
+		events   []*Event
+		expected map[EventType]EFIVariableMeasurementScheme
+	}{
+		{desc: "Empty", expected: map[EventType]EFIVariableMeasurementScheme{}},
+		{
+			desc: "Full",
+			events: []*Event{
+				makeEvent(EventTypeEFIVariableDriverConfig, "SecureBoot", EFIVariableMeasurementFull),
+				makeEvent(EventTypeEFIVariableBoot, "BootOrder", EFIVariableMeasurementFull)},
+			expected: map[EventType]EFIVariableMeasurementScheme{
+				EventTypeEFIVariableDriverConfig: EFIVariableMeasurementFull,
+				EventTypeEFIVariableBoot:         EFIVariableMeasurementFull},
+		},
+		{
+			desc: "DataOnly",
+			events: []*Event{
+				makeEvent(EventTypeEFIVariableDriverConfig, "SecureBoot", EFIVariableMeasurementFull),
+				makeEvent(EventTypeEFIVariableBoot, "BootOrder", EFIVariableMeasurementDataOnly),
+				makeEvent(EventTypeEFIVariableBoot, "Boot0001", EFIVariableMeasurementDataOnly)},
+			expected: map[EventType]EFIVariableMeasurementScheme{
+				EventTypeEFIVariableDriverConfig: EFIVariableMeasurementFull,
+				EventTypeEFIVariableBoot:         EFIVariableMeasurementDataOnly},
+		},
+		{
+			desc:     "DataHash",
+			events:   []*Event{makeEvent(EventTypeEFIVariableBoot, "BootOrder", EFIVariableMeasurementDataHash)},
+			expected: map[EventType]EFIVariableMeasurementScheme{EventTypeEFIVariableBoot: EFIVariableMeasurementDataHash},
+		},
+		{
+			desc: "Mixed",
+			events: []*Event{
+				makeEvent(EventTypeEFIVariableBoot, "BootOrder", EFIVariableMeasurementFull),
+				makeEvent(EventTypeEFIVariableBoot, "Boot0001", EFIVariableMeasurementDataOnly)},
+			expected: map[EventType]EFIVariableMeasurementScheme{EventTypeEFIVariableBoot: EFIVariableMeasurementMixed},
+		},
+	} {
+		t.Run(data.desc, func(t *testing.T) {
+			log := NewLog(data.events)
+			if schemes := log.EFIVariableMeasurementSchemes(); !reflect.DeepEqual(schemes, data.expected) {
+				t.Errorf("Unexpected schemes: %v", schemes)
+			}
+		})
+	}
+}
+
+func TestEFIVariableMeasurementSchemeUnknown(t *testing.T) {
+	event := makeTestEvent(1, EventTypeEFIVariableBoot, makeTestPFPVariable(EFIGlobalVariableGuid, "BootOrder"), AlgorithmSha256)
+	event.Digests[AlgorithmSha256] = AlgorithmSha256.hash([]byte("foo"))
+
+	log := NewLog([]*Event{event})
+	if s := log.EFIVariableMeasurementScheme(EventTypeEFIVariableBoot); s != EFIVariableMeasurementUnknown {
+		t.Errorf("Unexpected scheme: %v", s)
+	}
+	if _, err := EFIVariableMeasurementMixed.Digest(AlgorithmSha256, event.Data.(*EFIVariableData)); err == nil {
+		t.Errorf("Expected an error computing a digest for a mixed scheme")
+	}
+}
@@ -0,0 +1,263 @@
+// Copyright 2019 Canonical Ltd.
+// Licensed under the LGPLv3 with static-linking exception.
+// See LICENCE file for details.
+
+package tcglog
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"golang.org/x/xerrors"
+)
+
+// FilePath returns the path of the image from the media file path nodes of the device path from which the image was loaded, using
+// "/" as the separator (eg, "/EFI/ubuntu/shimx64.efi"). An empty string is returned if the device path doesn't contain a file
+// path, eg, if the image was loaded from a firmware volume.
+func (e *EFIImageLoadEvent) FilePath() string {
+	data := e.DevicePathData()
+
+	var components []string
+	for len(data) >= 4 {
+		t := efiDevicePathNodeType(data[0])
+		subType := data[1]
+		length := int(binary.LittleEndian.Uint16(data[2:]))
+		if length < 4 || length > len(data) {
+			break
+		}
+		if t == efiDevicePathNodeEoH {
+			break
+		}
+		if t == efiDevicePathNodeMedia && subType == efiMediaDevicePathNodeFilePath {
+			for _, c := range strings.Split(filePathDevicePathNodeToString(data[4:length]), "\\") {
+				if c != "" {
+					components = append(components, c)
+				}
+			}
+		}
+		data = data[length:]
+	}
+
+	if len(components) == 0 {
+		return ""
+	}
+	return "/" + path.Join(components...)
+}
+
+// ESPImageStatus describes the result of comparing an image load event with the contents of an EFI system partition.
+type ESPImageStatus int
+
+const (
+	// ESPImageMatched indicates that the file at the path recorded in the event matches the digests in the event.
+	ESPImageMatched ESPImageStatus = iota
+
+	// ESPImageModified indicates that the file at the path recorded in the event doesn't match the digests in the event, eg,
+	// because it has been updated since boot.
+	ESPImageModified
+
+	// ESPImageMissing indicates that there is no file at the path recorded in the event.
+	ESPImageMissing
+
+	// ESPImageNoPath indicates that the event doesn't record the path of the image, eg, because it was loaded from a firmware
+	// volume or from a buffer.
+	ESPImageNoPath
+)
+
+func (s ESPImageStatus) String() string {
+	switch s {
+	case ESPImageMatched:
+		return "matched"
+	case ESPImageModified:
+		return "modified"
+	case ESPImageMissing:
+		return "missing"
+	case ESPImageNoPath:
+		return "no path"
+	default:
+		return fmt.Sprintf("%%!(UNKNOWN_ESP_IMAGE_STATUS=%d)", int(s))
+	}
+}
+
+// ESPImage is the result of comparing an EV_EFI_BOOT_SERVICES_APPLICATION event with the contents of an EFI system partition.
+type ESPImage struct {
+	Event  *Event
+	Path   string // The path recorded in the event, relative to the root of the ESP
+	Status ESPImageStatus
+	File   string // The path of the file on the ESP that matches the event's digests, if any
+}
+
+func (i *ESPImage) String() string {
+	var b bytes.Buffer
+	fmt.Fprintf(&b, "event %d in PCR %d: ", i.Event.Index, i.Event.PCRIndex)
+	if i.Path != "" {
+		fmt.Fprintf(&b, "%s ", i.Path)
+	}
+	fmt.Fprintf(&b, "(%s)", i.Status)
+	if i.File != "" && i.File != i.Path {
+		fmt.Fprintf(&b, ", matches %s", i.File)
+	}
+	return b.String()
+}
+
+type espChecker struct {
+	root  string
+	algs  AlgorithmIdList
+	files []string // Lazily populated list of PE images on the ESP
+	cache map[string]DigestMap
+}
+
+// resolve finds the file on the ESP with the supplied path. FAT is case-insensitive, so each component is matched
+// case-insensitively if there isn't an exact match. Paths with ".." components are rejected so that a path recorded in the log
+// can't refer to a file outside of the ESP.
+func (c *espChecker) resolve(p string) (string, bool) {
+	dir := c.root
+	resolved := ""
+	for _, component := range strings.Split(strings.TrimPrefix(p, "/"), "/") {
+		if component == ".." {
+			return "", false
+		}
+		if _, err := os.Lstat(filepath.Join(dir, component)); err != nil {
+			entries, err := ioutil.ReadDir(dir)
+			if err != nil {
+				return "", false
+			}
+			found := false
+			for _, e := range entries {
+				if strings.EqualFold(e.Name(), component) {
+					component = e.Name()
+					found = true
+					break
+				}
+			}
+			if !found {
+				return "", false
+			}
+		}
+		dir = filepath.Join(dir, component)
+		resolved += "/" + component
+	}
+	return resolved, true
+}
+
+func (c *espChecker) digests(p string) (DigestMap, error) {
+	if d, ok := c.cache[p]; ok {
+		return d, nil
+	}
+
+	f, err := os.Open(filepath.Join(c.root, filepath.FromSlash(p)))
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		return nil, err
+	}
+
+	out := make(DigestMap)
+	for _, alg := range c.algs {
+		if out[alg], err = ComputeAuthenticodeDigest(f, info.Size(), alg); err != nil {
+			return nil, err
+		}
+	}
+	c.cache[p] = out
+	return out, nil
+}
+
+func (c *espChecker) matches(e *Event, digests DigestMap) bool {
+	for _, alg := range c.algs {
+		if !bytes.Equal(e.Digests[alg], digests[alg]) {
+			return false
+		}
+	}
+	return true
+}
+
+// find searches the ESP for a PE image that matches the digests of the supplied event.
+func (c *espChecker) find(e *Event) (string, error) {
+	if c.files == nil {
+		c.files = []string{}
+		err := filepath.Walk(c.root, func(p string, info os.FileInfo, err error) error {
+			if err != nil {
+				return err
+			}
+			if info.Mode().IsRegular() && strings.EqualFold(filepath.Ext(p), ".efi") {
+				rel, _ := filepath.Rel(c.root, p)
+				c.files = append(c.files, "/"+filepath.ToSlash(rel))
+			}
+			return nil
+		})
+		if err != nil {
+			return "", xerrors.Errorf("cannot enumerate ESP: %w", err)
+		}
+	}
+
+	for _, f := range c.files {
+		digests, err := c.digests(f)
+		if err != nil {
+			// Not a valid PE image.
+			continue
+		}
+		if c.matches(e, digests) {
+			return f, nil
+		}
+	}
+	return "", nil
+}
+
+// CheckESPImages compares the EV_EFI_BOOT_SERVICES_APPLICATION events in this log with the PE images on the EFI system partition
+// mounted at root, by computing the Authenticode digests of the images for each supported algorithm in this log. For each event,
+// it reports whether the image at the path recorded in the event still matches the measurement. If it doesn't, or if the event
+// doesn't record a path, the ESP is searched for another image that matches the measurement, so that the binary corresponding to
+// each event can be identified.
+func (l *Log) CheckESPImages(root string) ([]*ESPImage, error) {
+	c := &espChecker{root: root, cache: make(map[string]DigestMap)}
+	for _, alg := range l.Algorithms {
+		if alg.supported() {
+			c.algs = append(c.algs, alg)
+		}
+	}
+	if len(c.algs) == 0 {
+		return nil, errors.New("log has no supported algorithms")
+	}
+
+	var out []*ESPImage
+	for _, e := range l.Events {
+		if e.EventType != EventTypeEFIBootServicesApplication {
+			continue
+		}
+		d, ok := e.Data.(*EFIImageLoadEvent)
+		if !ok {
+			continue
+		}
+
+		image := &ESPImage{Event: e, Path: d.FilePath()}
+		out = append(out, image)
+
+		if image.Path == "" {
+			image.Status = ESPImageNoPath
+		} else if p, exists := c.resolve(image.Path); !exists {
+			image.Status = ESPImageMissing
+		} else if digests, err := c.digests(p); err == nil && c.matches(e, digests) {
+			image.Status = ESPImageMatched
+			image.File = p
+			continue
+		} else {
+			image.Status = ESPImageModified
+		}
+
+		file, err := c.find(e)
+		if err != nil {
+			return nil, err
+		}
+		image.File = file
+	}
+	return out, nil
+}
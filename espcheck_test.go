@@ -0,0 +1,134 @@
+// Copyright 2019 Canonical Ltd.
+// Licensed under the LGPLv3 with static-linking exception.
+// See LICENCE file for details.
+
+package tcglog
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestCheckESPImages(t *testing.T) {
+	dir, err := ioutil.TempDir("", "esp")
+	if err != nil {
+		t.Fatalf("TempDir failed: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	writeImage := func(path string, image []byte) {
+		path = filepath.Join(dir, filepath.FromSlash(path))
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatalf("MkdirAll failed: %v", err)
+		}
+		if err := ioutil.WriteFile(path, image, 0644); err != nil {
+			t.Fatalf("WriteFile failed: %v", err)
+		}
+	}
+
+	shim := makeTestPEImage(0, []byte("shim"), nil, nil)
+	oldGrub := makeTestPEImage(0, []byte("grub 1"), nil, nil)
+	newGrub := makeTestPEImage(0, []byte("grub 2"), nil, nil)
+	writeImage("EFI/BOOT/BOOTX64.EFI", shim)
+	writeImage("EFI/ubuntu/grubx64.efi", newGrub)
+	writeImage("EFI/backup/grubx64.efi", oldGrub)
+	writeImage("EFI/ubuntu/grub.cfg", []byte("not a PE image"))
+
+	b, err := NewLogBuilder(AlgorithmIdList{AlgorithmSha1, AlgorithmSha256}, nil)
+	if err != nil {
+		t.Fatalf("NewLogBuilder failed: %v", err)
+	}
+	addImage := func(data, image []byte) {
+		digests := make(DigestMap)
+		for _, alg := range []AlgorithmId{AlgorithmSha1, AlgorithmSha256} {
+			digests[alg], err = ComputeAuthenticodeDigest(bytes.NewReader(image), int64(len(image)), alg)
+			if err != nil {
+				t.Fatalf("ComputeAuthenticodeDigest failed: %v", err)
+			}
+		}
+		if _, err := b.AddEventWithDigests(4, EventTypeEFIBootServicesApplication, digests, data); err != nil {
+			t.Fatalf("AddEventWithDigests failed: %v", err)
+		}
+	}
+	addImage(makeTestImageLoadEventData(`\EFI\boot\bootx64.efi`), shim)
+	addImage(makeTestImageLoadEventData(`\EFI\ubuntu\grubx64.efi`), oldGrub)
+	addImage(makeTestImageLoadEventData(`\EFI\ubuntu\mmx64.efi`), makeTestPEImage(0, []byte("mm"), nil, nil))
+
+	var noPath bytes.Buffer
+	binary.Write(&noPath, binary.LittleEndian, []uint64{0, 0, 0, uint64(len(efiEndEntireDevicePath))})
+	noPath.Write(efiEndEntireDevicePath)
+	addImage(noPath.Bytes(), oldGrub)
+	log := b.Log()
+
+	images, err := log.CheckESPImages(dir)
+	if err != nil {
+		t.Fatalf("CheckESPImages failed: %v", err)
+	}
+
+	expected := []struct {
+		path   string
+		status ESPImageStatus
+		file   string
+	}{
+		{path: "/EFI/boot/bootx64.efi", status: ESPImageMatched, file: "/EFI/BOOT/BOOTX64.EFI"},
+		{path: "/EFI/ubuntu/grubx64.efi", status: ESPImageModified, file: "/EFI/backup/grubx64.efi"},
+		{path: "/EFI/ubuntu/mmx64.efi", status: ESPImageMissing},
+		{status: ESPImageNoPath, file: "/EFI/backup/grubx64.efi"},
+	}
+	if len(images) != len(expected) {
+		t.Fatalf("Unexpected number of images (%d)", len(images))
+	}
+	for i, e := range expected {
+		if images[i].Path != e.path || images[i].Status != e.status || images[i].File != e.file {
+			t.Errorf("Unexpected result for image %d: %s", i, images[i])
+		}
+	}
+	if images[1].String() != "event 1 in PCR 4: /EFI/ubuntu/grubx64.efi (modified), matches /EFI/backup/grubx64.efi" {
+		t.Errorf("Unexpected string: %s", images[1])
+	}
+}
+
+func TestCheckESPImagesOutsideESP(t *testing.T) {
+	dir, err := ioutil.TempDir("", "esp")
+	if err != nil {
+		t.Fatalf("TempDir failed: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	root := filepath.Join(dir, "esp")
+	if err := os.MkdirAll(filepath.Join(root, "EFI"), 0755); err != nil {
+		t.Fatalf("MkdirAll failed: %v", err)
+	}
+	image := makeTestPEImage(0, []byte("outside"), nil, nil)
+	if err := ioutil.WriteFile(filepath.Join(dir, "outside.efi"), image, 0644); err != nil {
+		t.Fatalf("WriteFile failed: %v", err)
+	}
+
+	b, err := NewLogBuilder(AlgorithmIdList{AlgorithmSha256}, nil)
+	if err != nil {
+		t.Fatalf("NewLogBuilder failed: %v", err)
+	}
+	digest, err := ComputeAuthenticodeDigest(bytes.NewReader(image), int64(len(image)), AlgorithmSha256)
+	if err != nil {
+		t.Fatalf("ComputeAuthenticodeDigest failed: %v", err)
+	}
+	if _, err := b.AddEventWithDigests(4, EventTypeEFIBootServicesApplication, DigestMap{AlgorithmSha256: digest},
+		makeTestImageLoadEventData(`\EFI\..\..\outside.efi`)); err != nil {
+		t.Fatalf("AddEventWithDigests failed: %v", err)
+	}
+
+	images, err := b.Log().CheckESPImages(root)
+	if err != nil {
+		t.Fatalf("CheckESPImages failed: %v", err)
+	}
+	if len(images) != 1 {
+		t.Fatalf("Unexpected number of images (%d)", len(images))
+	}
+	if images[0].Path != "/../outside.efi" || images[0].Status != ESPImageMissing || images[0].File != "" {
+		t.Errorf("Unexpected result: %s", images[0])
+	}
+}
@@ -0,0 +1,136 @@
+// Copyright 2019 Canonical Ltd.
+// Licensed under the LGPLv3 with static-linking exception.
+// See LICENCE file for details.
+
+package tcglog
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+
+	"golang.org/x/xerrors"
+)
+
+type measuredBytesEncoder interface {
+	EncodeMeasuredBytes(w io.Writer) error
+}
+
+// isNormalSeparatorValue indicates whether the supplied EV_SEPARATOR event data is one of the values that indicate a normal
+// separator.
+func isNormalSeparatorValue(data []byte) bool {
+	if len(data) != 4 {
+		return false
+	}
+	v := binary.LittleEndian.Uint32(data)
+	for _, n := range validNormalSeparatorValues {
+		if v == n {
+			return true
+		}
+	}
+	return false
+}
+
+// measuredBytes returns the bytes that are hashed to produce the digests of an event with the specified type and decoded data,
+// according to the rules in the TCG PC Client Platform Firmware Profile Specification. An error is returned if the digests are
+// of content that is only referenced by the event data.
+//
+// https://trustedcomputinggroup.org/wp-content/uploads/TCG_PCClientSpecPlat_TPM_2p0_1p04_pub.pdf
+//  (section 9.4.1 "Event Types")
+func measuredBytes(eventType EventType, data EventData) ([]byte, error) {
+	if err, isErr := data.(error); isErr {
+		return nil, xerrors.Errorf("invalid event data: %w", err)
+	}
+
+	switch eventType {
+	case EventTypeEventTag:
+		if isLinuxEFIStubEvent(data) {
+			break
+		}
+		return data.Bytes(), nil
+	case EventTypeSCRTMVersion, EventTypePlatformConfigFlags, EventTypeTableOfDevices,
+		EventTypeNonhostInfo, EventTypeOmitBootDeviceEvents, EventTypeCompactHash:
+		return data.Bytes(), nil
+	case EventTypeSeparator:
+		if !isNormalSeparatorValue(data.Bytes()) {
+			// The event data for an error separator is implementation defined, but the measured value is always
+			// the error value.
+			var d [4]byte
+			binary.LittleEndian.PutUint32(d[:], SeparatorEventErrorValue)
+			return d[:], nil
+		}
+		return data.Bytes(), nil
+	case EventTypeAction, EventTypeEFIAction:
+		return data.Bytes(), nil
+	case EventTypeEFIVariableDriverConfig, EventTypeEFIVariableBoot, EventTypeEFIVariableAuthority, EventTypeEFISPDMDevicePolicy:
+		return data.Bytes(), nil
+	case EventTypeEFIGPTEvent:
+		return data.Bytes(), nil
+	case EventTypeIPL:
+		if e, ok := data.(measuredBytesEncoder); ok {
+			var b bytes.Buffer
+			if err := e.EncodeMeasuredBytes(&b); err != nil {
+				return nil, err
+			}
+			return b.Bytes(), nil
+		}
+	}
+
+	return nil, fmt.Errorf("the digest for a %v event is not computed from the event data", eventType)
+}
+
+func newEvent(pcr PCRIndex, eventType EventType, data []byte, algs AlgorithmIdList, options *LogOptions) (*Event, error) {
+	if !isPCRIndexInRange(pcr) {
+		return nil, fmt.Errorf("out-of-range PCR index (%d)", pcr)
+	}
+	if len(algs) == 0 {
+		return nil, errors.New("no digest algorithms")
+	}
+	for _, alg := range algs {
+		if !alg.supported() {
+			return nil, fmt.Errorf("unsupported algorithm %v", alg)
+		}
+	}
+
+	digests := make(DigestMap)
+
+	if eventType == EventTypeNoAction {
+		for _, alg := range algs {
+			digests[alg] = make(Digest, alg.Size())
+		}
+	} else {
+		measured, err := measuredBytes(eventType, DecodeEventData(pcr, eventType, nil, data, options))
+		if err != nil {
+			return nil, err
+		}
+		for _, alg := range algs {
+			digests[alg] = alg.hash(measured)
+		}
+	}
+
+	return &Event{
+		PCRIndex:  pcr,
+		EventType: eventType,
+		Digests:   digests,
+		Data:      DecodeEventData(pcr, eventType, digests, data, options)}, nil
+}
+
+// NewEvent returns a new event with the specified PCR index, type and data, and digests for the supplied algorithms. The
+// digests are computed according to the rules in the TCG PC Client Platform Firmware Profile Specification for the event type,
+// which specify whether the digest is of the event data, part of it or some other value derived from it. EV_NO_ACTION events have
+// digests of all zeroes.
+//
+// Events whose digests are of content that is only referenced by the event data cannot be created with this function, and an
+// error will be returned for these. This includes EV_EFI_BOOT_SERVICES_APPLICATION events, where the digest is the Authenticode
+// digest of the loaded image, and EV_POST_CODE events, where the digest is of the firmware code. These must be constructed with
+// the correct digests by the caller (eg, with LogBuilder.AddEventWithDigests).
+//
+// The event data is decoded without any of the options in LogOptions, so events measured by GRUB or systemd's EFI stub will not
+// have the correct digests. Use a LogBuilder with the appropriate options in order to create these.
+//
+// The Index field of the returned event is not populated.
+func NewEvent(pcr PCRIndex, eventType EventType, data []byte, algs AlgorithmIdList) (*Event, error) {
+	return newEvent(pcr, eventType, data, algs, nil)
+}
@@ -0,0 +1,72 @@
+// Copyright 2019 Canonical Ltd.
+// Licensed under the LGPLv3 with static-linking exception.
+// See LICENCE file for details.
+
+package tcglog
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestNewEvent(t *testing.T) {
+	algs := AlgorithmIdList{AlgorithmSha1, AlgorithmSha256}
+
+	for _, data := range []struct {
+		desc      string
+		pcr       PCRIndex
+		eventType EventType
+		data      []byte
+		measured  []byte
+	}{
+		{desc: "EFIAction", pcr: 4, eventType: EventTypeEFIAction, data: []byte("Calling EFI Application from Boot Option"),
+			measured: []byte("Calling EFI Application from Boot Option")},
+		{desc: "Separator", pcr: 7, eventType: EventTypeSeparator, data: []byte{0, 0, 0, 0}, measured: []byte{0, 0, 0, 0}},
+		{desc: "ErrorSeparator", pcr: 7, eventType: EventTypeSeparator, data: []byte("error"), measured: []byte{1, 0, 0, 0}},
+		{desc: "SCRTMVersion", pcr: 0, eventType: EventTypeSCRTMVersion, data: []byte{0x31, 0x00}, measured: []byte{0x31, 0x00}},
+	} {
+		t.Run(data.desc, func(t *testing.T) {
+			e, err := NewEvent(data.pcr, data.eventType, data.data, algs)
+			if err != nil {
+				t.Fatalf("NewEvent failed: %v", err)
+			}
+			if e.PCRIndex != data.pcr || e.EventType != data.eventType || !bytes.Equal(e.Data.Bytes(), data.data) {
+				t.Errorf("Unexpected event")
+			}
+			for _, alg := range algs {
+				if !bytes.Equal(e.Digests[alg], alg.hash(data.measured)) {
+					t.Errorf("Unexpected %v digest", alg)
+				}
+			}
+		})
+	}
+
+	e, err := NewEvent(7, EventTypeSeparator, []byte("error"), algs)
+	if err != nil {
+		t.Fatalf("NewEvent failed: %v", err)
+	}
+	if !e.Data.(*SeparatorEventData).IsError {
+		t.Errorf("Separator should indicate an error")
+	}
+
+	if _, err := NewEvent(4, EventTypeEFIBootServicesApplication, make([]byte, 36), algs); err == nil {
+		t.Errorf("NewEvent should fail for an event where the digest is of referenced content")
+	}
+	if _, err := NewEvent(7, EventTypeEFIVariableDriverConfig, []byte{1, 2, 3}, algs); err == nil {
+		t.Errorf("NewEvent should fail for invalid event data")
+	}
+}
+
+func TestLogBuilderAddEventGrub(t *testing.T) {
+	b, err := NewLogBuilder(AlgorithmIdList{AlgorithmSha256}, &LogOptions{EnableGrub: true})
+	if err != nil {
+		t.Fatalf("NewLogBuilder failed: %v", err)
+	}
+	e, err := b.AddEvent(8, EventTypeIPL, []byte("grub_cmd: linux /vmlinuz\x00"))
+	if err != nil {
+		t.Fatalf("AddEvent failed: %v", err)
+	}
+	if !bytes.Equal(e.Digests[AlgorithmSha256], AlgorithmSha256.hash([]byte("linux /vmlinuz"))) {
+		t.Errorf("Unexpected digest")
+	}
+}
@@ -5,7 +5,9 @@
 package tcglog
 
 import (
+	"bytes"
 	"fmt"
+	"io"
 )
 
 // EventData represents all event data types that appear in a log. Some implementations of this are exported so that event data
@@ -18,6 +20,19 @@ type EventData interface {
 
 	// Bytes returns the raw event data bytes as they appear in the event log from which this event data was decoded.
 	Bytes() []byte
+
+	// EncodeTo encodes this event data in to the form in which it appears in the event log. Unlike Bytes, this reflects any
+	// changes made to the exported fields of the event data since it was decoded.
+	EncodeTo(w io.Writer) error
+}
+
+// encodeEventData returns the encoded form of the supplied event data (see EventData.EncodeTo).
+func encodeEventData(data EventData) ([]byte, error) {
+	var b bytes.Buffer
+	if err := data.EncodeTo(&b); err != nil {
+		return nil, err
+	}
+	return b.Bytes(), nil
 }
 
 // invalidEventData corresponds to an event data blob that failed to decode correctly.
@@ -34,6 +49,12 @@ func (e *invalidEventData) Bytes() []byte {
 	return e.data
 }
 
+// EncodeTo encodes this event data in to the form in which it appears in the event log.
+func (e *invalidEventData) EncodeTo(w io.Writer) error {
+	_, err := w.Write(e.data)
+	return err
+}
+
 func (e *invalidEventData) Error() string {
 	return e.err.Error()
 }
@@ -55,20 +76,59 @@ func (e *opaqueEventData) Bytes() []byte {
 	return e.data
 }
 
+// EncodeTo encodes this event data in to the form in which it appears in the event log.
+func (e *opaqueEventData) EncodeTo(w io.Writer) error {
+	_, err := w.Write(e.data)
+	return err
+}
+
+// DecodeEventData decodes the supplied event data for an event with the specified PCR index, type and digests, using the supplied
+// options. This is useful for decoding events that were obtained from a source other than a TCG event log, such as a Canonical
+// Event Log. The options may be nil. Data that cannot be decoded is returned as an EventData implementation that also implements
+// the error interface.
+func DecodeEventData(pcrIndex PCRIndex, eventType EventType, digests DigestMap, data []byte, options *LogOptions) EventData {
+	if options == nil {
+		options = &LogOptions{}
+	}
+	return decodeEventData(pcrIndex, eventType, digests, data, options)
+}
+
 func decodeEventData(pcrIndex PCRIndex, eventType EventType, digests DigestMap, data []byte, options *LogOptions) EventData {
-	if options.EnableGrub && (pcrIndex == 8 || pcrIndex == 9) {
-		if out := decodeEventDataGRUB(pcrIndex, eventType, data); out != nil {
+	if out := decodeEventDataCustom(pcrIndex, eventType, data); out != nil {
+		return out
+	}
+
+	if options.EnableGrub {
+		if out := decodeEventDataGRUB(pcrIndex, eventType, data, options); out != nil {
 			return out
 		}
 	}
 
-	if options.EnableSystemdEFIStub && pcrIndex == options.SystemdEFIStubPCR {
+	if options.EnableSystemdUKI {
+		if out := decodeEventDataSystemdUKI(pcrIndex, eventType, digests, data); out != nil {
+			return out
+		}
+	}
+
+	if options.EnableSystemdEFIStub && options.isSystemdEFIStubPCR(pcrIndex) {
 		if out := decodeEventDataSystemdEFIStub(eventType, data); out != nil {
 			return out
 		}
 
 	}
 
+	if pcrIndex == ShimMokPCR {
+		if out := decodeEventDataShim(eventType, data); out != nil {
+			return out
+		}
+	}
+
+	if isDRTMPCR(pcrIndex) {
+		if out := decodeEventDataSecureLaunch(eventType, data); out != nil {
+			return out
+		}
+	}
+
 	out, err := decodeEventDataTCG(eventType, digests, data)
 	if err != nil {
 		return &invalidEventData{data: data, err: err}
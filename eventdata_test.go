@@ -0,0 +1,293 @@
+// Copyright 2019 Canonical Ltd.
+// Licensed under the LGPLv3 with static-linking exception.
+// See LICENCE file for details.
+
+package tcglog
+
+import (
+	"bytes"
+	"crypto/x509"
+	"testing"
+)
+
+func TestEventDataEncodeRoundTrip(t *testing.T) {
+	varData := EFIVariableData{
+		VariableName: MakeEFIGUID(0x8be4df61, 0x93ca, 0x11d2, 0xaa0d, [...]uint8{0x00, 0xe0, 0x98, 0x03, 0x2b, 0x8c}),
+		UnicodeName:  "SecureBoot",
+		VariableData: []byte{0x01}}
+	var varBytes bytes.Buffer
+	varData.EncodeMeasuredBytes(&varBytes)
+
+	specId02 := &SpecIdEvent{PlatformClass: 1, UintnSize: 2, VendorInfo: []byte("foo")}
+
+	for _, data := range []struct {
+		desc      string
+		pcr       PCRIndex
+		eventType EventType
+		data      []byte
+		options   *LogOptions
+	}{
+		{
+			desc:      "SpecIdEvent03",
+			eventType: EventTypeNoAction,
+			data:      encodeSpecIdEvent03(nil, AlgorithmIdList{AlgorithmSha1, AlgorithmSha256}),
+		},
+		{
+			desc:      "SpecIdEvent02",
+			eventType: EventTypeNoAction,
+			data:      encodeSpecIdEvent02(specId02),
+		},
+		{
+			desc:      "StartupLocality",
+			eventType: EventTypeNoAction,
+			data:      append([]byte("StartupLocality\x00"), 3),
+		},
+		{
+			desc:      "NvIndexInstance",
+			eventType: EventTypeNoAction,
+			data:      append([]byte("NvIndexInstance\x00\x01\x00\x00\x00\x00\x00\x00\x00"), 1, 2, 3),
+		},
+		{
+			desc:      "VendorNoAction",
+			eventType: EventTypeNoAction,
+			data:      append([]byte("Vendor\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00"), 1, 2, 3),
+		},
+		{
+			desc:      "EFIVariable",
+			pcr:       7,
+			eventType: EventTypeEFIVariableDriverConfig,
+			data:      varBytes.Bytes(),
+		},
+		{
+			desc:      "Separator",
+			pcr:       7,
+			eventType: EventTypeSeparator,
+			data:      []byte{0, 0, 0, 0},
+		},
+		{
+			desc:      "Action",
+			pcr:       5,
+			eventType: EventTypeAction,
+			data:      []byte("Calling EFI Application from Boot Option"),
+		},
+		{
+			desc:      "GrubCmd",
+			pcr:       8,
+			eventType: EventTypeIPL,
+			data:      []byte("grub_cmd: linux /vmlinuz root=/dev/sda1\x00"),
+			options:   &LogOptions{EnableGrub: true},
+		},
+		{
+			desc:      "KernelCmdline",
+			pcr:       8,
+			eventType: EventTypeIPL,
+			data:      []byte("kernel_cmdline: /vmlinuz root=/dev/sda1\x00"),
+			options:   &LogOptions{EnableGrub: true},
+		},
+		{
+			desc:      "SystemdEFIStub",
+			pcr:       8,
+			eventType: EventTypeIPL,
+			data:      []byte{0x66, 0x00, 0x6f, 0x00, 0x6f, 0x00, 0x00},
+			options:   &LogOptions{EnableSystemdEFIStub: true, SystemdEFIStubPCRs: []PCRIndex{8}},
+		},
+		{
+			desc:      "SystemdEFIStubMultiplePCRs",
+			pcr:       12,
+			eventType: EventTypeIPL,
+			data:      []byte{0x66, 0x00, 0x6f, 0x00, 0x6f, 0x00, 0x00},
+			options:   &LogOptions{EnableSystemdEFIStub: true, SystemdEFIStubPCRs: []PCRIndex{8, 12}},
+		},
+		{
+			desc:      "Opaque",
+			pcr:       4,
+			eventType: EventTypePostCode,
+			data:      []byte{1, 2, 3, 4},
+		},
+	} {
+		t.Run(data.desc, func(t *testing.T) {
+			d := DecodeEventData(data.pcr, data.eventType, DigestMap{}, data.data, data.options)
+			if err, isErr := d.(error); isErr {
+				t.Fatalf("DecodeEventData failed: %v", err)
+			}
+			var buf bytes.Buffer
+			if err := d.EncodeTo(&buf); err != nil {
+				t.Fatalf("EncodeTo failed: %v", err)
+			}
+			if !bytes.Equal(buf.Bytes(), data.data) {
+				t.Errorf("Unexpected encoding (got %x, expected %x)", buf.Bytes(), data.data)
+			}
+		})
+	}
+}
+
+func TestEventDataEncodeModified(t *testing.T) {
+	varData := EFIVariableData{
+		VariableName: MakeEFIGUID(0x8be4df61, 0x93ca, 0x11d2, 0xaa0d, [...]uint8{0x00, 0xe0, 0x98, 0x03, 0x2b, 0x8c}),
+		UnicodeName:  "SecureBoot",
+		VariableData: []byte{0x01}}
+	var varBytes bytes.Buffer
+	varData.EncodeMeasuredBytes(&varBytes)
+
+	d, ok := DecodeEventData(7, EventTypeEFIVariableDriverConfig, DigestMap{}, varBytes.Bytes(), nil).(*EFIVariableData)
+	if !ok {
+		t.Fatalf("Unexpected event data type")
+	}
+	d.VariableData = []byte{0x00}
+
+	var buf bytes.Buffer
+	if err := d.EncodeTo(&buf); err != nil {
+		t.Fatalf("EncodeTo failed: %v", err)
+	}
+
+	d2, ok := DecodeEventData(7, EventTypeEFIVariableDriverConfig, DigestMap{}, buf.Bytes(), nil).(*EFIVariableData)
+	if !ok {
+		t.Fatalf("Unexpected event data type")
+	}
+	if d2.UnicodeName != "SecureBoot" {
+		t.Errorf("Unexpected variable name (%s)", d2.UnicodeName)
+	}
+	if !bytes.Equal(d2.VariableData, []byte{0x00}) {
+		t.Errorf("Unexpected variable data (%x)", d2.VariableData)
+	}
+}
+
+func TestSpecIdEventEncodeInvalid(t *testing.T) {
+	d := &SpecIdEvent{Spec: SpecEFI_2}
+	if err := d.EncodeTo(new(bytes.Buffer)); err == nil {
+		t.Errorf("EncodeTo should have failed with no digest algorithms")
+	}
+	d = &SpecIdEvent{Spec: SpecUnknown}
+	if err := d.EncodeTo(new(bytes.Buffer)); err == nil {
+		t.Errorf("EncodeTo should have failed for an unknown specification")
+	}
+}
+
+func TestEventDataEncodeModifiedFields(t *testing.T) {
+	cert := makeTestCertificate(t, "Preboot Test")
+	otherCert, err := x509.ParseCertificate(makeTestCertificate(t, "Other"))
+	if err != nil {
+		t.Fatalf("ParseCertificate failed: %v", err)
+	}
+	embeddedCert := append(append([]byte{0x01, 0x00, 0x00, 0x00, 0x30, 0x00}, cert...), 0xff, 0xff)
+
+	for _, data := range []struct {
+		desc      string
+		pcr       PCRIndex
+		eventType EventType
+		digests   DigestMap
+		data      []byte
+		modify    func(EventData)
+		expected  []byte
+		err       string
+	}{
+		{
+			desc:      "PlatformConfigFlagsString",
+			pcr:       1,
+			eventType: EventTypePlatformConfigFlags,
+			data:      []byte("foo"),
+			modify:    func(d EventData) { d.(*PlatformConfigFlagsEventData).Str = "bar" },
+			expected:  []byte("bar\x00"),
+		},
+		{
+			desc:      "PlatformConfigFlagsUTF16",
+			pcr:       1,
+			eventType: EventTypePlatformConfigFlags,
+			data:      []byte{0x66, 0x00, 0x6f, 0x00, 0x6f, 0x00},
+			modify:    func(d EventData) { d.(*PlatformConfigFlagsEventData).Str = "ba" },
+			expected:  []byte{0x62, 0x00, 0x61, 0x00, 0x00, 0x00},
+		},
+		{
+			desc:      "PlatformConfigFlagsInteger",
+			pcr:       1,
+			eventType: EventTypePlatformConfigFlags,
+			data:      []byte{0x01, 0x00, 0x00, 0x00},
+			modify:    func(d EventData) { d.(*PlatformConfigFlagsEventData).Flags = 0x0300 },
+			expected:  []byte{0x00, 0x03, 0x00, 0x00},
+		},
+		{
+			desc:      "PlatformConfigFlagsIntegerGrow",
+			pcr:       1,
+			eventType: EventTypePlatformConfigFlags,
+			data:      []byte{0x01},
+			modify:    func(d EventData) { d.(*PlatformConfigFlagsEventData).Flags = 0x10000 },
+			expected:  []byte{0x00, 0x00, 0x01, 0x00},
+		},
+		{
+			desc:      "SeparatorError",
+			pcr:       7,
+			eventType: EventTypeSeparator,
+			digests:   DigestMap{AlgorithmSha256: AlgorithmSha256.hash([]byte{0, 0, 0, 0})},
+			data:      []byte{0, 0, 0, 0},
+			modify:    func(d EventData) { d.(*SeparatorEventData).IsError = true },
+			expected:  []byte{0x01, 0x00, 0x00, 0x00},
+		},
+		{
+			desc:      "Nonhost",
+			pcr:       0,
+			eventType: EventTypeNonhostInfo,
+			data:      []byte{0x66, 0x00, 0x6f, 0x00, 0x6f, 0x00, 0x00, 0x00},
+			modify:    func(d EventData) { d.(*NonhostEventData).Str = "ba" },
+			expected:  []byte{0x62, 0x00, 0x61, 0x00, 0x00, 0x00},
+		},
+		{
+			desc:      "TXT",
+			pcr:       17,
+			eventType: EventTypeTXTPCRMapping,
+			data:      []byte{1, 2, 3},
+			modify:    func(d EventData) { d.(*TXTEventData).Data = []byte{4, 5} },
+			expected:  []byte{4, 5},
+		},
+		{
+			desc:      "PrebootCert",
+			pcr:       0,
+			eventType: EventTypePrebootCert,
+			data:      cert,
+			modify:    func(d EventData) { d.(*PrebootCertEventData).Certificate = otherCert },
+			expected:  otherCert.Raw,
+		},
+		{
+			desc:      "PrebootCertEmbedded",
+			pcr:       0,
+			eventType: EventTypePrebootCert,
+			data:      embeddedCert,
+			modify:    func(d EventData) { d.(*PrebootCertEventData).Certificate = &x509.Certificate{Raw: []byte{0x30, 0x00}} },
+			err:       "cannot replace an embedded certificate with one of a different length",
+		},
+	} {
+		t.Run(data.desc, func(t *testing.T) {
+			digests := data.digests
+			if digests == nil {
+				digests = DigestMap{}
+			}
+			d := DecodeEventData(data.pcr, data.eventType, digests, data.data, nil)
+			if err, isErr := d.(error); isErr {
+				t.Fatalf("DecodeEventData failed: %v", err)
+			}
+
+			var buf bytes.Buffer
+			if err := d.EncodeTo(&buf); err != nil {
+				t.Fatalf("EncodeTo failed: %v", err)
+			}
+			if !bytes.Equal(buf.Bytes(), data.data) {
+				t.Errorf("Unexpected encoding of unmodified event data (got %x, expected %x)", buf.Bytes(), data.data)
+			}
+
+			data.modify(d)
+			buf.Reset()
+			err := d.EncodeTo(&buf)
+			if data.err != "" {
+				if err == nil || err.Error() != data.err {
+					t.Errorf("Unexpected error: %v", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("EncodeTo failed: %v", err)
+			}
+			if !bytes.Equal(buf.Bytes(), data.expected) {
+				t.Errorf("Unexpected encoding (got %x, expected %x)", buf.Bytes(), data.expected)
+			}
+		})
+	}
+}
@@ -0,0 +1,151 @@
+// Copyright 2019 Canonical Ltd.
+// Licensed under the LGPLv3 with static-linking exception.
+// See LICENCE file for details.
+
+package tcglog
+
+import (
+	"bytes"
+	"fmt"
+)
+
+// ExplainPCROptions specifies the assumptions that Log.ExplainPCR is permitted to make when searching for events that produce a
+// PCR value.
+type ExplainPCROptions struct {
+	// MaxOmittedEvents is the maximum number of events that may be omitted from a prefix of the log in order to produce the
+	// value. The default of zero only permits exact prefixes. The cost of the search grows quickly with this value.
+	MaxOmittedEvents int
+
+	// OmittableEventTypes restricts the events that may be omitted to those with one of the listed types. If empty, any event may
+	// be omitted.
+	OmittableEventTypes []EventType
+}
+
+func (o *ExplainPCROptions) omittable(e *Event) bool {
+	if len(o.OmittableEventTypes) == 0 {
+		return true
+	}
+	for _, t := range o.OmittableEventTypes {
+		if e.EventType == t {
+			return true
+		}
+	}
+	return false
+}
+
+// PCRExplanation describes a sequence of events from a log that produces a PCR value.
+type PCRExplanation struct {
+	PCRIndex  PCRIndex
+	Algorithm AlgorithmId
+	Events    []*Event // The events whose replay produces the value, in log order
+	Omitted   []*Event // Events within the prefix that had to be omitted in order to produce the value
+	Next      *Event   // The first event after the prefix, or nil if the prefix is the complete log for this PCR
+}
+
+// Prefix indicates whether the value is produced by an exact prefix of the log, without omitting any events.
+func (e *PCRExplanation) Prefix() bool {
+	return len(e.Omitted) == 0
+}
+
+func (e *PCRExplanation) String() string {
+	var b bytes.Buffer
+	fmt.Fprintf(&b, "PCR %d, bank %v: produced by %d event(s)", e.PCRIndex, e.Algorithm, len(e.Events))
+	if len(e.Omitted) > 0 {
+		fmt.Fprintf(&b, ", omitting events")
+		for i, o := range e.Omitted {
+			if i > 0 {
+				fmt.Fprintf(&b, ",")
+			}
+			fmt.Fprintf(&b, " %d", o.Index)
+		}
+	}
+	if e.Next == nil {
+		fmt.Fprintf(&b, " (complete log)")
+	} else {
+		fmt.Fprintf(&b, " (next event: %d)", e.Next.Index)
+	}
+	return b.String()
+}
+
+type pcrExplainer struct {
+	alg     AlgorithmId
+	pcr     PCRIndex
+	events  []*Event
+	target  Digest
+	options *ExplainPCROptions
+}
+
+func (x *pcrExplainer) explanation(end int, omitted []*Event) *PCRExplanation {
+	result := &PCRExplanation{PCRIndex: x.pcr, Algorithm: x.alg, Omitted: omitted}
+	j := 0
+	for _, e := range x.events[:end] {
+		if j < len(omitted) && omitted[j] == e {
+			j++
+			continue
+		}
+		result.Events = append(result.Events, e)
+	}
+	if end < len(x.events) {
+		result.Next = x.events[end]
+	}
+	return result
+}
+
+// search looks for a sequence of events starting at x.events[start] that extends value to the target value, with exactly
+// remaining more events omitted.
+func (x *pcrExplainer) search(start int, value Digest, omitted []*Event, remaining int) *PCRExplanation {
+	if remaining == 0 {
+		// If the last event of the prefix was omitted, a shorter prefix with fewer omitted events produces the same value and
+		// will already have been tried.
+		if len(omitted) == 0 && bytes.Equal(value, x.target) {
+			return x.explanation(start, omitted)
+		}
+		for i := start; i < len(x.events); i++ {
+			value = extendDigest(x.alg, value, x.events[i].Digests[x.alg])
+			if bytes.Equal(value, x.target) {
+				return x.explanation(i+1, omitted)
+			}
+		}
+		return nil
+	}
+
+	for i := start; i < len(x.events); i++ {
+		if x.options.omittable(x.events[i]) {
+			o := append(omitted[:len(omitted):len(omitted)], x.events[i])
+			if result := x.search(i+1, value, o, remaining-1); result != nil {
+				return result
+			}
+		}
+		value = extendDigest(x.alg, value, x.events[i].Digests[x.alg])
+	}
+	return nil
+}
+
+// ExplainPCR searches for the events from this log whose replay produces the supplied value of the specified PCR, eg, from a
+// quote or from a snapshot of the PCR taken before the boot completed. By default, only prefixes of the log are considered. If
+// options permits it, the search also considers prefixes with up to options.MaxOmittedEvents events omitted. Explanations that
+// omit fewer events are preferred, and then shorter prefixes. If no explanation is found, nil is returned. An error is returned
+// if the log doesn't contain digests for the specified algorithm.
+func (l *Log) ExplainPCR(alg AlgorithmId, pcr PCRIndex, value Digest, options *ExplainPCROptions) (*PCRExplanation, error) {
+	if err := l.checkReplayAlgorithm(alg); err != nil {
+		return nil, err
+	}
+	if options == nil {
+		options = &ExplainPCROptions{}
+	}
+
+	x := &pcrExplainer{alg: alg, pcr: pcr, target: value, options: options}
+	for _, e := range l.Events {
+		if e.PCRIndex == pcr && e.EventType != EventTypeNoAction {
+			x.events = append(x.events, e)
+		}
+	}
+
+	initial := l.initialPCRValue(alg, pcr)
+	for n := 0; n <= options.MaxOmittedEvents && n <= len(x.events); n++ {
+		if result := x.search(0, initial, nil, n); result != nil {
+			return result, nil
+		}
+	}
+	return nil, nil
+}
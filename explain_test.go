@@ -0,0 +1,105 @@
+// Copyright 2019 Canonical Ltd.
+// Licensed under the LGPLv3 with static-linking exception.
+// See LICENCE file for details.
+
+package tcglog
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestExplainPCR(t *testing.T) {
+	events := []*Event{
+		makeTestEvent(4, EventTypeEFIAction, []byte("Calling EFI Application from Boot Option"), AlgorithmSha256),
+		makeTestEvent(4, EventTypeSeparator, []byte{0, 0, 0, 0}, AlgorithmSha256),
+		makeTestEvent(4, EventTypeEFIBootServicesApplication, []byte("shim"), AlgorithmSha256),
+		makeTestEvent(4, EventTypeEFIBootServicesApplication, []byte("grub"), AlgorithmSha256),
+		makeTestEvent(4, EventTypeEFIBootServicesApplication, []byte("kernel"), AlgorithmSha256),
+		makeTestEvent(7, EventTypeSeparator, []byte{0, 0, 0, 0}, AlgorithmSha256)}
+	log := NewLog(events)
+
+	replay := func(events ...*Event) Digest {
+		value := make(Digest, AlgorithmSha256.Size())
+		for _, e := range events {
+			value = extendDigest(AlgorithmSha256, value, e.Digests[AlgorithmSha256])
+		}
+		return value
+	}
+
+	for _, data := range []struct {
+		desc     string
+		value    Digest
+		options  *ExplainPCROptions
+		expected *PCRExplanation
+	}{
+		{
+			desc:     "Complete",
+			value:    replay(events[:5]...),
+			expected: &PCRExplanation{PCRIndex: 4, Algorithm: AlgorithmSha256, Events: events[:5]},
+		},
+		{
+			desc:     "Prefix",
+			value:    replay(events[:3]...),
+			expected: &PCRExplanation{PCRIndex: 4, Algorithm: AlgorithmSha256, Events: events[:3], Next: events[3]},
+		},
+		{
+			desc:     "Empty",
+			value:    replay(),
+			expected: &PCRExplanation{PCRIndex: 4, Algorithm: AlgorithmSha256, Next: events[0]},
+		},
+		{
+			desc:  "OmittedNotPermitted",
+			value: replay(events[0], events[1], events[3]),
+		},
+		{
+			desc:    "Omitted",
+			value:   replay(events[0], events[1], events[3]),
+			options: &ExplainPCROptions{MaxOmittedEvents: 1},
+			expected: &PCRExplanation{PCRIndex: 4, Algorithm: AlgorithmSha256, Events: []*Event{events[0], events[1], events[3]},
+				Omitted: []*Event{events[2]}, Next: events[4]},
+		},
+		{
+			desc:    "OmittedTwo",
+			value:   replay(events[1], events[3]),
+			options: &ExplainPCROptions{MaxOmittedEvents: 2},
+			expected: &PCRExplanation{PCRIndex: 4, Algorithm: AlgorithmSha256, Events: []*Event{events[1], events[3]},
+				Omitted: []*Event{events[0], events[2]}, Next: events[4]},
+		},
+		{
+			desc:    "OmittedWrongType",
+			value:   replay(events[0], events[2], events[3]),
+			options: &ExplainPCROptions{MaxOmittedEvents: 1, OmittableEventTypes: []EventType{EventTypeEFIBootServicesApplication}},
+		},
+		{
+			desc:    "OmittedType",
+			value:   replay(events[0], events[1], events[2], events[4]),
+			options: &ExplainPCROptions{MaxOmittedEvents: 1, OmittableEventTypes: []EventType{EventTypeEFIBootServicesApplication}},
+			expected: &PCRExplanation{PCRIndex: 4, Algorithm: AlgorithmSha256,
+				Events: []*Event{events[0], events[1], events[2], events[4]}, Omitted: []*Event{events[3]}},
+		},
+		{
+			desc:    "NotFound",
+			value:   AlgorithmSha256.hash([]byte("foo")),
+			options: &ExplainPCROptions{MaxOmittedEvents: 2},
+		},
+	} {
+		t.Run(data.desc, func(t *testing.T) {
+			explanation, err := log.ExplainPCR(AlgorithmSha256, 4, data.value, data.options)
+			if err != nil {
+				t.Fatalf("ExplainPCR failed: %v", err)
+			}
+			if !reflect.DeepEqual(explanation, data.expected) {
+				t.Errorf("Unexpected explanation: %v", explanation)
+			}
+			if explanation != nil && explanation.Prefix() != (len(data.expected.Omitted) == 0) {
+				t.Errorf("Unexpected Prefix() result")
+			}
+		})
+	}
+
+	if _, err := log.ExplainPCR(AlgorithmSha1, 4, nil, nil); err == nil ||
+		err.Error() != "log does not contain digests for algorithm SHA-1" {
+		t.Errorf("Unexpected error: %v", err)
+	}
+}
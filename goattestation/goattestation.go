@@ -0,0 +1,60 @@
+// Copyright 2019 Canonical Ltd.
+// Licensed under the LGPLv3 with static-linking exception.
+// See LICENCE file for details.
+
+// Package goattestation provides conversions between the event log types in the tcglog package and those in
+// github.com/google/go-attestation/attest, so that logs parsed or verified by go-attestation can make use of the event data
+// decoders in the tcglog package.
+package goattestation
+
+import (
+	"github.com/canonical/tcglog-parser"
+	"github.com/canonical/tcglog-parser/internal/logconv"
+	"github.com/google/go-attestation/attest"
+)
+
+func fromAttestEvents(events []attest.Event) []logconv.Event {
+	var out []logconv.Event
+	for _, e := range events {
+		out = append(out, logconv.Event{Index: e.Index, Type: uint32(e.Type), Data: e.Data, Digest: e.Digest})
+	}
+	return out
+}
+
+// FromEvents converts the supplied go-attestation events, which contain digests for the specified algorithm, in to events
+// with decoded event data. The supplied options, which may be nil, are used to decode the event data. The events could be those
+// returned from attest.EventLog.Verify.
+func FromEvents(events []attest.Event, alg tcglog.AlgorithmId, options *tcglog.LogOptions) []*tcglog.Event {
+	return logconv.FromEvents(fromAttestEvents(events), alg, options)
+}
+
+// FromEventLog converts the supplied go-attestation event log in to a log. The supplied options, which may be nil, are used to
+// decode the event data.
+//
+// Note that go-attestation doesn't verify any of the event data when parsing a log, and the returned log is no more trustworthy
+// than the supplied one. Use FromEvents with the events returned from attest.EventLog.Verify in order to obtain events that have
+// been verified against a set of PCR values.
+func FromEventLog(log *attest.EventLog, options *tcglog.LogOptions) (*tcglog.Log, error) {
+	var algs tcglog.AlgorithmIdList
+	for _, alg := range log.Algs {
+		algs = append(algs, tcglog.AlgorithmId(alg))
+	}
+	return logconv.FromEventLog(algs, func(alg tcglog.AlgorithmId) []logconv.Event {
+		return fromAttestEvents(log.Events(attest.HashAlg(alg)))
+	}, options)
+}
+
+// ToEvents converts the events in the supplied log in to go-attestation events containing digests for the specified algorithm,
+// so that they can be passed to functions in go-attestation that consume events, such as attest.ParseSecurebootState.
+func ToEvents(log *tcglog.Log, alg tcglog.AlgorithmId) ([]attest.Event, error) {
+	events, err := logconv.ToEvents(log, alg)
+	if err != nil {
+		return nil, err
+	}
+
+	var out []attest.Event
+	for _, e := range events {
+		out = append(out, attest.Event{Index: e.Index, Type: attest.EventType(e.Type), Data: e.Data, Digest: e.Digest})
+	}
+	return out, nil
+}
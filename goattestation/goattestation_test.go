@@ -0,0 +1,61 @@
+// Copyright 2019 Canonical Ltd.
+// Licensed under the LGPLv3 with static-linking exception.
+// See LICENCE file for details.
+
+package goattestation
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"testing"
+
+	"github.com/canonical/tcglog-parser"
+)
+
+func TestEventsRoundTrip(t *testing.T) {
+	var events []*tcglog.Event
+	for _, d := range []struct {
+		pcr       tcglog.PCRIndex
+		eventType tcglog.EventType
+		data      []byte
+	}{
+		{pcr: 0, eventType: tcglog.EventTypeSCRTMVersion, data: []byte{0x31, 0x00}},
+		{pcr: 4, eventType: tcglog.EventTypeEFIAction, data: []byte("Calling EFI Application from Boot Option")},
+		{pcr: 4, eventType: tcglog.EventTypeSeparator, data: []byte{0, 0, 0, 0}},
+	} {
+		digest := sha256.Sum256(d.data)
+		digests := tcglog.DigestMap{tcglog.AlgorithmSha256: digest[:]}
+		events = append(events, &tcglog.Event{
+			PCRIndex:  d.pcr,
+			EventType: d.eventType,
+			Digests:   digests,
+			Data:      tcglog.DecodeEventData(d.pcr, d.eventType, digests, d.data, nil)})
+	}
+	log := tcglog.NewLog(events)
+
+	if _, err := ToEvents(log, tcglog.AlgorithmSha1); err == nil {
+		t.Errorf("ToEvents should fail for an algorithm that isn't in the log")
+	}
+
+	attestEvents, err := ToEvents(log, tcglog.AlgorithmSha256)
+	if err != nil {
+		t.Fatalf("ToEvents failed: %v", err)
+	}
+	if len(attestEvents) != len(events) {
+		t.Fatalf("Unexpected number of events (%d)", len(attestEvents))
+	}
+
+	converted := FromEvents(attestEvents, tcglog.AlgorithmSha256, nil)
+	for i, e := range converted {
+		orig := log.Events[i]
+		if e.PCRIndex != orig.PCRIndex || e.Index != orig.Index || e.EventType != orig.EventType {
+			t.Errorf("Unexpected event %d: %d %d %v", i, e.PCRIndex, e.Index, e.EventType)
+		}
+		if !bytes.Equal(e.Digests[tcglog.AlgorithmSha256], orig.Digests[tcglog.AlgorithmSha256]) {
+			t.Errorf("Unexpected digest for event %d", i)
+		}
+		if e.Data.String() != orig.Data.String() || !bytes.Equal(e.Data.Bytes(), orig.Data.Bytes()) {
+			t.Errorf("Unexpected data for event %d: %s", i, e.Data)
+		}
+	}
+}
@@ -0,0 +1,112 @@
+// Copyright 2019 Canonical Ltd.
+// Licensed under the LGPLv3 with static-linking exception.
+// See LICENCE file for details.
+
+// Package goefilib exposes the UEFI structures contained in events decoded by the tcglog package as types from
+// github.com/canonical/go-efilib.
+package goefilib
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/canonical/go-efilib"
+	"github.com/canonical/tcglog-parser"
+
+	"golang.org/x/xerrors"
+)
+
+// ToGUID converts the supplied GUID to a go-efilib GUID. Both types have the same representation.
+func ToGUID(guid tcglog.EFIGUID) efi.GUID {
+	return efi.GUID(guid)
+}
+
+// FromGUID converts the supplied go-efilib GUID to a GUID.
+func FromGUID(guid efi.GUID) tcglog.EFIGUID {
+	return tcglog.EFIGUID(guid)
+}
+
+// DecodeImageLoadDevicePath decodes the device path from the UEFI_IMAGE_LOAD_EVENT structure associated with the supplied
+// EV_EFI_BOOT_SERVICES_APPLICATION, EV_EFI_BOOT_SERVICES_DRIVER or EV_EFI_RUNTIME_SERVICES_DRIVER event.
+func DecodeImageLoadDevicePath(event *tcglog.Event) (efi.DevicePath, error) {
+	switch event.EventType {
+	case tcglog.EventTypeEFIBootServicesApplication, tcglog.EventTypeEFIBootServicesDriver,
+		tcglog.EventTypeEFIRuntimeServicesDriver:
+	default:
+		return nil, fmt.Errorf("unexpected event type %v", event.EventType)
+	}
+	if err, isErr := event.Data.(error); isErr {
+		return nil, xerrors.Errorf("invalid event data: %w", err)
+	}
+
+	r := bytes.NewReader(event.Data.Bytes())
+
+	// Skip ImageLocationInMemory, ImageLengthInMemory and ImageLinkTimeAddress
+	if _, err := r.Seek(24, io.SeekStart); err != nil {
+		return nil, err
+	}
+	var length uint64
+	if err := binary.Read(r, binary.LittleEndian, &length); err != nil {
+		return nil, xerrors.Errorf("cannot read device path length: %w", err)
+	}
+	if length > uint64(r.Len()) {
+		return nil, errors.New("device path length is larger than the event data")
+	}
+
+	path, err := efi.ReadDevicePath(io.LimitReader(r, int64(length)))
+	if err != nil {
+		return nil, xerrors.Errorf("cannot decode device path: %w", err)
+	}
+	return path, nil
+}
+
+func checkVariableData(data *tcglog.EFIVariableData) error {
+	if len(data.TrailingBytes()) > 0 {
+		return errors.New("event data contains trailing bytes")
+	}
+	return nil
+}
+
+// DecodeSignatureDatabase decodes the contents of a signature database variable (PK, KEK, db or dbx) from the supplied data,
+// which is associated with an EV_EFI_VARIABLE_DRIVER_CONFIG event.
+func DecodeSignatureDatabase(data *tcglog.EFIVariableData) (efi.SignatureDatabase, error) {
+	if err := checkVariableData(data); err != nil {
+		return nil, err
+	}
+	db, err := efi.ReadSignatureDatabase(bytes.NewReader(data.VariableData))
+	if err != nil {
+		return nil, xerrors.Errorf("cannot decode signature database: %w", err)
+	}
+	return db, nil
+}
+
+// DecodeSignatureData decodes the EFI_SIGNATURE_DATA structure from the supplied data, which is associated with an
+// EV_EFI_VARIABLE_AUTHORITY event that records the database entry used to authenticate an image.
+func DecodeSignatureData(data *tcglog.EFIVariableData) (*efi.SignatureData, error) {
+	if err := checkVariableData(data); err != nil {
+		return nil, err
+	}
+	if len(data.VariableData) < len(efi.GUID{}) {
+		return nil, errors.New("variable data is too short")
+	}
+
+	out := &efi.SignatureData{Data: data.VariableData[len(efi.GUID{}):]}
+	copy(out.Owner[:], data.VariableData)
+	return out, nil
+}
+
+// DecodeLoadOption decodes the contents of a Boot#### variable from the supplied data, which is associated with an
+// EV_EFI_VARIABLE_BOOT event.
+func DecodeLoadOption(data *tcglog.EFIVariableData) (*efi.LoadOption, error) {
+	if err := checkVariableData(data); err != nil {
+		return nil, err
+	}
+	opt, err := efi.ReadLoadOption(bytes.NewReader(data.VariableData))
+	if err != nil {
+		return nil, xerrors.Errorf("cannot decode load option: %w", err)
+	}
+	return opt, nil
+}
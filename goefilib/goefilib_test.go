@@ -0,0 +1,67 @@
+// Copyright 2019 Canonical Ltd.
+// Licensed under the LGPLv3 with static-linking exception.
+// See LICENCE file for details.
+
+package goefilib
+
+import (
+	"bytes"
+	"crypto/sha1"
+	"encoding/binary"
+	"testing"
+
+	"github.com/canonical/tcglog-parser"
+)
+
+func TestGUIDRoundTrip(t *testing.T) {
+	guid := tcglog.MakeEFIGUID(0x8be4df61, 0x93ca, 0x11d2, 0xaa0d, [...]uint8{0x00, 0xe0, 0x98, 0x03, 0x2b, 0x8c})
+	if FromGUID(ToGUID(guid)) != guid {
+		t.Errorf("GUID didn't round-trip")
+	}
+}
+
+func TestDecodeSignatureData(t *testing.T) {
+	owner := tcglog.MakeEFIGUID(0x605dab50, 0xe046, 0x4300, 0xabb6, [...]uint8{0x3d, 0xd8, 0x10, 0xdd, 0x8b, 0x23})
+	cert := []byte{0x30, 0x82, 0x01, 0x02}
+
+	var data bytes.Buffer
+	dbGUID := tcglog.MakeEFIGUID(0xd719b2cb, 0x3d3a, 0x4596, 0xa3bc, [...]uint8{0xda, 0xd0, 0x0e, 0x67, 0x65, 0x6f})
+	data.Write(dbGUID[:])
+	binary.Write(&data, binary.LittleEndian, uint64(2))
+	binary.Write(&data, binary.LittleEndian, uint64(len(owner)+len(cert)))
+	binary.Write(&data, binary.LittleEndian, []uint16{'d', 'b'})
+	data.Write(owner[:])
+	data.Write(cert)
+
+	digest := sha1.Sum(data.Bytes())
+	digests := tcglog.DigestMap{tcglog.AlgorithmSha1: digest[:]}
+	varData, ok := tcglog.DecodeEventData(7, tcglog.EventTypeEFIVariableAuthority, digests, data.Bytes(), nil).(*tcglog.EFIVariableData)
+	if !ok {
+		t.Fatalf("Unexpected event data type")
+	}
+
+	sigData, err := DecodeSignatureData(varData)
+	if err != nil {
+		t.Fatalf("DecodeSignatureData failed: %v", err)
+	}
+	if FromGUID(sigData.Owner) != owner {
+		t.Errorf("Unexpected owner: %x", sigData.Owner)
+	}
+	if !bytes.Equal(sigData.Data, cert) {
+		t.Errorf("Unexpected data: %x", sigData.Data)
+	}
+}
+
+func TestDecodeImageLoadDevicePathWrongType(t *testing.T) {
+	data := []byte{0, 0, 0, 0}
+	digest := sha1.Sum(data)
+	digests := tcglog.DigestMap{tcglog.AlgorithmSha1: digest[:]}
+	event := &tcglog.Event{
+		PCRIndex:  7,
+		EventType: tcglog.EventTypeSeparator,
+		Digests:   digests,
+		Data:      tcglog.DecodeEventData(7, tcglog.EventTypeSeparator, digests, data, nil)}
+	if _, err := DecodeImageLoadDevicePath(event); err == nil {
+		t.Errorf("DecodeImageLoadDevicePath should fail for a separator event")
+	}
+}
@@ -0,0 +1,61 @@
+// Copyright 2019 Canonical Ltd.
+// Licensed under the LGPLv3 with static-linking exception.
+// See LICENCE file for details.
+
+// Package goeventlog provides adapters between the event log types in the tcglog package and those in the tcg package from
+// github.com/google/go-eventlog, so that logs parsed by go-eventlog and the event data decoders in the tcglog package can be
+// used together.
+package goeventlog
+
+import (
+	"github.com/canonical/tcglog-parser"
+	"github.com/canonical/tcglog-parser/internal/logconv"
+	"github.com/google/go-eventlog/register"
+	"github.com/google/go-eventlog/tcg"
+)
+
+func fromTCGEvents(events []tcg.Event) []logconv.Event {
+	var out []logconv.Event
+	for _, e := range events {
+		out = append(out, logconv.Event{Index: e.Index, Type: uint32(e.Type), Data: e.Data, Digest: e.Digest})
+	}
+	return out
+}
+
+// FromEvents converts the supplied go-eventlog events, which contain digests for the specified algorithm, in to events with
+// decoded event data. The supplied options, which may be nil, are used to decode the event data. The events could be those
+// returned from tcg.EventLog.Verify.
+func FromEvents(events []tcg.Event, alg tcglog.AlgorithmId, options *tcglog.LogOptions) []*tcglog.Event {
+	return logconv.FromEvents(fromTCGEvents(events), alg, options)
+}
+
+// FromEventLog converts the supplied go-eventlog event log in to a log. The supplied options, which may be nil, are used to
+// decode the event data.
+//
+// The event log returned from tcg.ParseEventLog has not been verified against any PCR values, so the returned log is no more
+// trustworthy than the supplied one. Use FromEvents with the events returned from tcg.EventLog.Verify in order to obtain events
+// that have been verified against a set of PCR values.
+func FromEventLog(log *tcg.EventLog, options *tcglog.LogOptions) (*tcglog.Log, error) {
+	var algs tcglog.AlgorithmIdList
+	for _, alg := range log.Algs {
+		algs = append(algs, tcglog.AlgorithmId(alg))
+	}
+	return logconv.FromEventLog(algs, func(alg tcglog.AlgorithmId) []logconv.Event {
+		return fromTCGEvents(log.Events(register.HashAlg(alg)))
+	}, options)
+}
+
+// ToEvents converts the events in the supplied log in to go-eventlog events containing digests for the specified algorithm, so
+// that they can be passed to functions in go-eventlog that consume events, such as those in the extract package.
+func ToEvents(log *tcglog.Log, alg tcglog.AlgorithmId) ([]tcg.Event, error) {
+	events, err := logconv.ToEvents(log, alg)
+	if err != nil {
+		return nil, err
+	}
+
+	var out []tcg.Event
+	for _, e := range events {
+		out = append(out, tcg.Event{Index: e.Index, Type: tcg.EventType(e.Type), Data: e.Data, Digest: e.Digest})
+	}
+	return out, nil
+}
@@ -0,0 +1,137 @@
+// Copyright 2019 Canonical Ltd.
+// Licensed under the LGPLv3 with static-linking exception.
+// See LICENCE file for details.
+
+// Package gotpm2 reads PCR values from a TPM using github.com/canonical/go-tpm2, so that they can be compared against the values
+// computed by replaying a log with tcglog.Log.ComparePCRs.
+package gotpm2
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/canonical/go-tpm2"
+	"github.com/canonical/go-tpm2/mu"
+	"github.com/canonical/tcglog-parser"
+
+	"golang.org/x/xerrors"
+)
+
+const (
+	tpm1TagRquCommand          tpm2.StructTag   = 0x00c1
+	tpm1OrdPcrRead             tpm2.CommandCode = 0x00000015
+	tpm1OrdGetCapability       tpm2.CommandCode = 0x00000065
+	tpm1CapProperty            uint32           = 0x00000005
+	tpm1CapPropManufacturer    uint32           = 0x00000103
+	tpm1CapPropManufacturerLen uint32           = 4
+)
+
+// PCRReader is an implementation of tcglog.PCRReader that reads PCR values from a TPM 2.0 or TPM 1.2 device. TPM 1.2 devices only
+// have a SHA-1 bank.
+type PCRReader struct {
+	tpm     *tpm2.TPMContext
+	version int
+}
+
+// NewPCRReader returns a new PCRReader for the supplied TPM. An error is returned if the TPM doesn't respond as a TPM 2.0 or
+// TPM 1.2 device.
+func NewPCRReader(tpm *tpm2.TPMContext) (*PCRReader, error) {
+	version := tpmDeviceVersion(tpm)
+	if version == 0 {
+		return nil, errors.New("not a valid TPM device")
+	}
+	return &PCRReader{tpm: tpm, version: version}, nil
+}
+
+// ReadPCRs implements tcglog.PCRReader.ReadPCRs.
+func (r *PCRReader) ReadPCRs(pcrs []tcglog.PCRIndex, algorithms tcglog.AlgorithmIdList) (map[tcglog.PCRIndex]tcglog.DigestMap, error) {
+	if r.version == 1 {
+		for _, alg := range algorithms {
+			if alg != tcglog.AlgorithmSha1 {
+				return nil, fmt.Errorf("TPM 1.2 devices don't support the %v algorithm", alg)
+			}
+		}
+		return r.readPCRsTPM1(pcrs)
+	}
+	return r.readPCRsTPM2(pcrs, algorithms)
+}
+
+func (r *PCRReader) readPCRsTPM2(pcrs []tcglog.PCRIndex, algorithms tcglog.AlgorithmIdList) (map[tcglog.PCRIndex]tcglog.DigestMap, error) {
+	var sel tpm2.PCRSelect
+	for _, pcr := range pcrs {
+		sel = append(sel, int(pcr))
+	}
+
+	var selections tpm2.PCRSelectionList
+	for _, alg := range algorithms {
+		selections = append(selections, tpm2.PCRSelection{Hash: tpm2.HashAlgorithmId(alg), Select: sel})
+	}
+
+	_, digests, err := r.tpm.PCRRead(selections)
+	if err != nil {
+		return nil, xerrors.Errorf("cannot read PCR values: %w", err)
+	}
+
+	result := make(map[tcglog.PCRIndex]tcglog.DigestMap)
+	for _, pcr := range pcrs {
+		result[pcr] = tcglog.DigestMap{}
+		for _, alg := range algorithms {
+			result[pcr][alg] = tcglog.Digest(digests[tpm2.HashAlgorithmId(alg)][int(pcr)])
+		}
+	}
+	return result, nil
+}
+
+func (r *PCRReader) readPCRsTPM1(pcrs []tcglog.PCRIndex) (map[tcglog.PCRIndex]tcglog.DigestMap, error) {
+	result := make(map[tcglog.PCRIndex]tcglog.DigestMap)
+	for _, pcr := range pcrs {
+		in, err := mu.MarshalToBytes(uint32(pcr))
+		if err != nil {
+			return nil, xerrors.Errorf("cannot marshal PCR index: %w", err)
+		}
+		rc, _, out, err := r.tpm.RunCommandBytes(tpm1TagRquCommand, tpm1OrdPcrRead, in)
+		if err != nil {
+			return nil, xerrors.Errorf("cannot read PCR %d: %w", pcr, err)
+		}
+		if rc != tpm2.Success {
+			return nil, fmt.Errorf("cannot read PCR %d: unexpected response code (0x%08x)", pcr, rc)
+		}
+		result[pcr] = tcglog.DigestMap{tcglog.AlgorithmSha1: tcglog.Digest(out)}
+	}
+	return result, nil
+}
+
+// tpmDeviceVersion returns the major version of the supplied TPM, or 0 if it doesn't respond as a TPM 2.0 or TPM 1.2 device.
+func tpmDeviceVersion(tpm *tpm2.TPMContext) int {
+	if isTpm2, _ := tpm.IsTPM2(); isTpm2 {
+		return 2
+	}
+
+	payload, _ := mu.MarshalToBytes(tpm1CapProperty, tpm1CapPropManufacturerLen, tpm1CapPropManufacturer)
+	if rc, _, _, err := tpm.RunCommandBytes(tpm1TagRquCommand, tpm1OrdGetCapability, payload); err == nil && rc == tpm2.Success {
+		return 1
+	}
+
+	return 0
+}
+
+// OpenPCRReader opens the TPM device at the specified path and returns a PCRReader for it, along with a function that closes
+// the device.
+func OpenPCRReader(path string) (*PCRReader, func() error, error) {
+	tcti, err := tpm2.OpenTPMDevice(path)
+	if err != nil {
+		return nil, nil, xerrors.Errorf("cannot open TPM device: %w", err)
+	}
+	tpm, err := tpm2.NewTPMContext(tcti)
+	if err != nil {
+		tcti.Close()
+		return nil, nil, xerrors.Errorf("cannot create TPM context: %w", err)
+	}
+
+	r, err := NewPCRReader(tpm)
+	if err != nil {
+		tpm.Close()
+		return nil, nil, err
+	}
+	return r, tpm.Close, nil
+}
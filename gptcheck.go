@@ -0,0 +1,153 @@
+// Copyright 2019 Canonical Ltd.
+// Licensed under the LGPLv3 with static-linking exception.
+// See LICENCE file for details.
+
+package tcglog
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+
+	"golang.org/x/xerrors"
+)
+
+const efiPartitionTableHeaderSignature = 0x5452415020494645 // "EFI PART"
+
+// ReadDiskGPTData reads the primary GUID partition table from the supplied disk and returns it in the form that firmware measures
+// it for an EV_EFI_GPT_EVENT event, which is the partition table header followed by the entries that are in use. The logical
+// block size is detected by searching for the header at LBA 1 for block sizes of 512 and 4096 bytes.
+//
+// https://trustedcomputinggroup.org/wp-content/uploads/TCG_PCClientSpecPlat_TPM_2p0_1p04_pub.pdf
+//  (section 9.4 "UEFI_GPT_DATA Structure")
+func ReadDiskGPTData(disk io.ReaderAt) (*EFIGPTData, error) {
+	var header EFIPartitionTableHeader
+	var blockSize int64
+	for _, sz := range []int64{512, 4096} {
+		if err := binary.Read(io.NewSectionReader(disk, sz, int64(binary.Size(header))), binary.LittleEndian, &header); err != nil {
+			continue
+		}
+		if header.Signature == efiPartitionTableHeaderSignature {
+			blockSize = sz
+			break
+		}
+	}
+	if blockSize == 0 {
+		return nil, errors.New("cannot find GPT header")
+	}
+	if header.SizeOfPartitionEntry < 128 {
+		return nil, fmt.Errorf("invalid SizeOfPartitionEntry (%d)", header.SizeOfPartitionEntry)
+	}
+
+	var entries bytes.Buffer
+	n := uint64(0)
+	r := io.NewSectionReader(disk, int64(header.PartitionEntryLBA)*blockSize,
+		int64(header.NumberOfPartitionEntries)*int64(header.SizeOfPartitionEntry))
+	var zero EFIGUID
+	for i := uint32(0); i < header.NumberOfPartitionEntries; i++ {
+		entry := make([]byte, header.SizeOfPartitionEntry)
+		if _, err := io.ReadFull(r, entry); err != nil {
+			return nil, xerrors.Errorf("cannot read partition entry %d: %w", i, err)
+		}
+		if bytes.Equal(entry[:16], zero[:]) {
+			continue
+		}
+		entries.Write(entry)
+		n++
+	}
+
+	var b bytes.Buffer
+	binary.Write(&b, binary.LittleEndian, header)
+	binary.Write(&b, binary.LittleEndian, n)
+	entries.WriteTo(&b)
+	return decodeEventDataEFIGPT(b.Bytes())
+}
+
+// GPTCheck is the result of comparing the partition table measured in a log with the current partition table on a disk.
+type GPTCheck struct {
+	Event        *Event      // The EV_EFI_GPT_EVENT event
+	Current      *EFIGPTData // The partition table read from the disk
+	DigestsMatch bool        // The digests of the current partition table match those of the event
+	Changes      []string    // A description of the changes to the partition table since boot
+}
+
+// Changed indicates whether the partition table has changed since boot, in which case PCR 5 will have a different value on the
+// next boot.
+func (c *GPTCheck) Changed() bool {
+	return !c.DigestsMatch || len(c.Changes) > 0
+}
+
+func diffGPTData(measured, current *EFIGPTData) (changes []string) {
+	if measured.Header.DiskGUID != current.Header.DiskGUID {
+		changes = append(changes, fmt.Sprintf("disk GUID changed from %s to %s", measured.Header.DiskGUID,
+			current.Header.DiskGUID))
+	}
+	if measured.Header.FirstUsableLBA != current.Header.FirstUsableLBA ||
+		measured.Header.LastUsableLBA != current.Header.LastUsableLBA {
+		changes = append(changes, fmt.Sprintf("usable LBAs changed from %d-%d to %d-%d", measured.Header.FirstUsableLBA,
+			measured.Header.LastUsableLBA, current.Header.FirstUsableLBA, current.Header.LastUsableLBA))
+	}
+
+	currentParts := make(map[EFIGUID]*EFIPartitionEntry)
+	for _, p := range current.Partitions {
+		currentParts[p.UniquePartitionGUID] = p
+	}
+	seen := make(map[EFIGUID]bool)
+	for _, p := range measured.Partitions {
+		seen[p.UniquePartitionGUID] = true
+		c, ok := currentParts[p.UniquePartitionGUID]
+		switch {
+		case !ok:
+			changes = append(changes, fmt.Sprintf("partition %s (\"%s\") was removed", p.UniquePartitionGUID, p.Name))
+		case *c != *p:
+			changes = append(changes, fmt.Sprintf("partition %s (\"%s\") was modified: { %s } -> { %s }",
+				p.UniquePartitionGUID, p.Name, p, c))
+		}
+	}
+	for _, p := range current.Partitions {
+		if !seen[p.UniquePartitionGUID] {
+			changes = append(changes, fmt.Sprintf("partition %s (\"%s\") was added", p.UniquePartitionGUID, p.Name))
+		}
+	}
+	return changes
+}
+
+// CheckGPT compares the partition table measured by the EV_EFI_GPT_EVENT event in this log with the current partition table on
+// the supplied disk, which should be the boot disk. This detects changes to the partition table since boot that will change the
+// value of PCR 5 on the next boot. An error is returned if the log doesn't contain an EV_EFI_GPT_EVENT event or if the partition
+// table can't be read from the disk.
+func (l *Log) CheckGPT(disk io.ReaderAt) (*GPTCheck, error) {
+	var event *Event
+	for _, e := range l.Events {
+		if e.EventType == EventTypeEFIGPTEvent {
+			event = e
+			break
+		}
+	}
+	if event == nil {
+		return nil, errors.New("log contains no EV_EFI_GPT_EVENT event")
+	}
+	measured, ok := event.Data.(*EFIGPTData)
+	if !ok {
+		return nil, fmt.Errorf("invalid event data for EV_EFI_GPT_EVENT event: %v", event.Data)
+	}
+
+	current, err := ReadDiskGPTData(disk)
+	if err != nil {
+		return nil, xerrors.Errorf("cannot read partition table from disk: %w", err)
+	}
+
+	check := &GPTCheck{Event: event, Current: current, DigestsMatch: true}
+	for _, alg := range l.Algorithms {
+		if !alg.supported() {
+			continue
+		}
+		if !bytes.Equal(alg.hash(current.Bytes()), event.Digests[alg]) {
+			check.DigestsMatch = false
+		}
+	}
+	check.Changes = diffGPTData(measured, current)
+	return check, nil
+}
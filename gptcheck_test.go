@@ -0,0 +1,77 @@
+// Copyright 2019 Canonical Ltd.
+// Licensed under the LGPLv3 with static-linking exception.
+// See LICENCE file for details.
+
+package tcglog
+
+import (
+	"bytes"
+	"testing"
+)
+
+// makeTestDisk creates a disk image with the specified logical block size containing the partition table described by the
+// supplied UEFI_GPT_DATA.
+func makeTestDisk(blockSize int, gptData []byte) []byte {
+	disk := make([]byte, blockSize*(2+128*128/blockSize+1))
+	copy(disk[blockSize:], gptData[:92])
+	copy(disk[2*blockSize:], gptData[100:])
+	return disk
+}
+
+func TestCheckGPT(t *testing.T) {
+	diskGUID := MakeEFIGUID(0x1, 0x2, 0x3, 0x4, [...]uint8{5, 6, 7, 8, 9, 10})
+	esp := testGPTPartition{
+		typeGUID:   MakeEFIGUID(0xc12a7328, 0xf81f, 0x11d2, 0xba4b, [...]uint8{0x00, 0xa0, 0xc9, 0x3e, 0xc9, 0x3b}),
+		uniqueGUID: MakeEFIGUID(0xa, 0xb, 0xc, 0xd, [...]uint8{1, 2, 3, 4, 5, 6}),
+		name:       "EFI System Partition"}
+	root := testGPTPartition{
+		typeGUID:   MakeEFIGUID(0x0fc63daf, 0x8483, 0x4772, 0x8e79, [...]uint8{0x3d, 0x69, 0xd8, 0x47, 0x7d, 0xe4}),
+		uniqueGUID: MakeEFIGUID(0xe, 0xf, 0x10, 0x11, [...]uint8{1, 2, 3, 4, 5, 6}),
+		name:       "root"}
+	data := makeTestGPTEventData(diskGUID, []testGPTPartition{esp, root})
+
+	b, err := NewLogBuilder(AlgorithmIdList{AlgorithmSha256}, nil)
+	if err != nil {
+		t.Fatalf("NewLogBuilder failed: %v", err)
+	}
+	b.AddEventWithDigests(5, EventTypeEFIGPTEvent, DigestMap{AlgorithmSha256: AlgorithmSha256.hash(data)}, data)
+	log := b.Log()
+
+	for _, blockSize := range []int{512, 4096} {
+		check, err := log.CheckGPT(bytes.NewReader(makeTestDisk(blockSize, data)))
+		if err != nil {
+			t.Fatalf("CheckGPT failed: %v", err)
+		}
+		if check.Changed() || !bytes.Equal(check.Current.Bytes(), data) {
+			t.Errorf("Unexpected result for block size %d: %v", blockSize, check.Changes)
+		}
+	}
+
+	home := testGPTPartition{
+		typeGUID:   MakeEFIGUID(0x933ac7e1, 0x2eb4, 0x4f13, 0xb844, [...]uint8{0x0e, 0x14, 0xe2, 0xae, 0xf9, 0x15}),
+		uniqueGUID: MakeEFIGUID(0x12, 0x13, 0x14, 0x15, [...]uint8{1, 2, 3, 4, 5, 6}),
+		name:       "home"}
+	esp.name = "ESP"
+	check, err := log.CheckGPT(bytes.NewReader(makeTestDisk(512, makeTestGPTEventData(diskGUID, []testGPTPartition{esp, home}))))
+	if err != nil {
+		t.Fatalf("CheckGPT failed: %v", err)
+	}
+	if !check.Changed() || check.DigestsMatch {
+		t.Errorf("Expected a change")
+	}
+	if len(check.Changes) != 3 {
+		t.Fatalf("Unexpected changes: %q", check.Changes)
+	}
+	if check.Changes[1] != "partition {0000000e-000f-0010-0011-010203040506} (\"root\") was removed" ||
+		check.Changes[2] != "partition {00000012-0013-0014-0015-010203040506} (\"home\") was added" {
+		t.Errorf("Unexpected changes: %q", check.Changes)
+	}
+
+	if _, err := log.CheckGPT(bytes.NewReader(make([]byte, 8192))); err == nil ||
+		err.Error() != "cannot read partition table from disk: cannot find GPT header" {
+		t.Errorf("Unexpected error: %v", err)
+	}
+	if _, err := NewLog(nil).CheckGPT(bytes.NewReader(nil)); err == nil || err.Error() != "log contains no EV_EFI_GPT_EVENT event" {
+		t.Errorf("Unexpected error: %v", err)
+	}
+}
@@ -44,13 +44,29 @@ type GrubStringEventData struct {
 }
 
 func (e *GrubStringEventData) String() string {
-	return fmt.Sprintf("%s{ %s }", grubEventTypeString(e.Type), e.Str)
+	return fmt.Sprintf("%s{ %s }", grubEventTypeString(e.Type), EscapeString(e.Str))
 }
 
 func (e *GrubStringEventData) Bytes() []byte {
 	return e.data
 }
 
+// EncodeTo encodes this event data in to the form in which it appears in the event log, which is the string with a prefix
+// indicating its type and a NULL terminator.
+func (e *GrubStringEventData) EncodeTo(w io.Writer) error {
+	var prefix string
+	switch e.Type {
+	case GrubCmd:
+		prefix = grubCmdPrefix
+	case KernelCmdline:
+		prefix = kernelCmdlinePrefix
+	default:
+		return fmt.Errorf("invalid type (%d)", e.Type)
+	}
+	_, err := io.WriteString(w, prefix+e.Str+"\x00")
+	return err
+}
+
 // EncodeMeasuredBytes encodes this data to the form that would be hashed and measured by GRUB.
 func (e *GrubStringEventData) EncodeMeasuredBytes(buf io.Writer) error {
 	if _, err := io.WriteString(buf, e.Str); err != nil {
@@ -59,13 +75,13 @@ func (e *GrubStringEventData) EncodeMeasuredBytes(buf io.Writer) error {
 	return nil
 }
 
-func decodeEventDataGRUB(pcrIndex PCRIndex, eventType EventType, data []byte) EventData {
+func decodeEventDataGRUB(pcrIndex PCRIndex, eventType EventType, data []byte, options *LogOptions) EventData {
 	if eventType != EventTypeIPL {
 		return nil
 	}
 
 	switch pcrIndex {
-	case 8:
+	case options.grubCmdPCR():
 		str := string(data)
 		switch {
 		case strings.HasPrefix(str, kernelCmdlinePrefix):
@@ -75,9 +91,9 @@ func decodeEventDataGRUB(pcrIndex PCRIndex, eventType EventType, data []byte) Ev
 		default:
 			return nil
 		}
-	case 9:
-		return &asciiStringEventData{data: data}
+	case options.grubFilePCR():
+		return &AsciiStringEventData{data: data}
 	default:
-		panic("unhandled PCR index")
+		return nil
 	}
 }
@@ -0,0 +1,43 @@
+// Copyright 2019 Canonical Ltd.
+// Licensed under the LGPLv3 with static-linking exception.
+// See LICENCE file for details.
+
+package tcglog
+
+import (
+	"testing"
+)
+
+func TestDecodeEventDataGRUB(t *testing.T) {
+	cmd := []byte("grub_cmd: linux /vmlinuz root=/dev/sda1\x00")
+	file := []byte("/boot/vmlinuz\x00")
+
+	for _, data := range []struct {
+		desc    string
+		pcr     PCRIndex
+		data    []byte
+		options *LogOptions
+		isCmd   bool
+		isFile  bool
+	}{
+		{desc: "DefaultCmd", pcr: 8, data: cmd, options: &LogOptions{EnableGrub: true}, isCmd: true},
+		{desc: "DefaultFile", pcr: 9, data: file, options: &LogOptions{EnableGrub: true}, isFile: true},
+		{desc: "CustomCmd", pcr: 12, data: cmd, options: &LogOptions{EnableGrub: true, GrubCmdPCR: 12, GrubFilePCR: 13}, isCmd: true},
+		{desc: "CustomFile", pcr: 13, data: file, options: &LogOptions{EnableGrub: true, GrubCmdPCR: 12, GrubFilePCR: 13}, isFile: true},
+		{desc: "CustomNotDefault", pcr: 8, data: cmd, options: &LogOptions{EnableGrub: true, GrubCmdPCR: 12, GrubFilePCR: 13}},
+		{desc: "Disabled", pcr: 8, data: cmd, options: &LogOptions{}},
+	} {
+		t.Run(data.desc, func(t *testing.T) {
+			d := DecodeEventData(data.pcr, EventTypeIPL, DigestMap{}, data.data, data.options)
+			_, isCmd := d.(*GrubStringEventData)
+			if isCmd != data.isCmd {
+				t.Errorf("Unexpected event data type %T", d)
+			}
+			if data.isFile {
+				if _, ok := d.(*AsciiStringEventData); !ok {
+					t.Errorf("Unexpected event data type %T", d)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,829 @@
+// Copyright 2019 Canonical Ltd.
+// Licensed under the LGPLv3 with static-linking exception.
+// See LICENCE file for details.
+
+package tcglog
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"golang.org/x/xerrors"
+)
+
+// GrubBootConfig describes the configuration and files that GRUB will use on a future boot, from which the measurements that
+// it will perform can be predicted with PredictGrubMeasurements.
+type GrubBootConfig struct {
+	// Version is the version of GRUB, eg, "2.06" or "2.06-2ubuntu7". Versions before 2.04 don't perform TPM measurements. If
+	// empty, the behaviour of the latest version is assumed.
+	Version string
+
+	// ConfigPath is the path of the configuration file that GRUB loads first, as it opens it, eg,
+	// "(hd0,gpt1)/EFI/ubuntu/grub.cfg". Its contents must be supplied in Files.
+	ConfigPath string
+
+	// Env is the initial environment, which is normally set from the image that GRUB was built from and its boot device (eg,
+	// "prefix", "root", "cmdpath", "grub_cpu" and "grub_platform").
+	Env map[string]string
+
+	// Files contains the files that GRUB can read, indexed by path. A path that GRUB opens without a device (eg,
+	// "/boot/vmlinuz") is also looked up with the device in the "root" variable (eg, "(hd0,gpt2)/boot/vmlinuz"), and a path
+	// with a device is also looked up without it.
+	Files map[string][]byte
+
+	// SearchResults maps the UUIDs, labels and file paths that the search commands look for to the device that is found, eg,
+	// "hd0,gpt2".
+	SearchResults map[string]string
+
+	// Entry selects the menu entry to boot by title, identifier or index, with submenus separated by '>' as in the "default"
+	// variable. If empty, the entry in the "default" variable is booted after the configuration has been executed.
+	Entry string
+}
+
+// errGrubBooted is used to unwind the interpreter once a menu entry has been booted from a configuration loaded with configfile.
+var errGrubBooted = errors.New("booted")
+
+const grubMaxDepth = 64
+
+type grubMenuEntry struct {
+	title   string
+	id      string
+	params  []string
+	body    string
+	submenu bool
+}
+
+// grubInterpreter executes a GRUB configuration in order to predict the measurements that GRUB makes. Every command is
+// measured, and commands that GRUB implements by reading files (eg, linux, initrd, source, configfile, load_env, loadfont and
+// insmod) result in the files being measured. Other commands are assumed to succeed without side effects.
+type grubInterpreter struct {
+	config  *GrubBootConfig
+	algs    AlgorithmIdList
+	cmdPCR  PCRIndex
+	filePCR PCRIndex
+
+	env       map[string]string
+	params    []string
+	status    int
+	functions map[string][]grubStatement
+	menu      []*grubMenuEntry
+	modules   map[string]bool
+	depth     int
+
+	events  []*Event
+	indices map[PCRIndex]uint
+}
+
+func (in *grubInterpreter) measure(pcr PCRIndex, data EventData, measured []byte) {
+	digests := make(DigestMap)
+	for _, alg := range in.algs {
+		digests[alg] = alg.hash(measured)
+	}
+	in.events = append(in.events, &Event{
+		Index:     in.indices[pcr],
+		PCRIndex:  pcr,
+		EventType: EventTypeIPL,
+		Digests:   digests,
+		Data:      data})
+	in.indices[pcr]++
+}
+
+// measureString measures a string in the way that GRUB's TPM verifier does, to its command PCR with a prefix in the event data.
+func (in *grubInterpreter) measureString(t GrubStringEventType, str string) {
+	prefix := grubCmdPrefix
+	if t == KernelCmdline {
+		prefix = kernelCmdlinePrefix
+	}
+	in.measure(in.cmdPCR, &GrubStringEventData{data: []byte(prefix + str + "\x00"), Type: t, Str: str}, []byte(str))
+}
+
+// lookupFile returns the contents of the file with the specified path.
+func (in *grubInterpreter) lookupFile(path string) ([]byte, bool) {
+	if data, ok := in.config.Files[path]; ok {
+		return data, true
+	}
+	if strings.HasPrefix(path, "(") {
+		if i := strings.IndexByte(path, ')'); i >= 0 {
+			data, ok := in.config.Files[path[i+1:]]
+			return data, ok
+		}
+		return nil, false
+	}
+	if root := in.env["root"]; root != "" {
+		data, ok := in.config.Files["("+root+")"+path]
+		return data, ok
+	}
+	return nil, false
+}
+
+// openFile simulates GRUB opening a file, which results in its contents being measured to the file PCR with the path as it was
+// opened in the event data.
+func (in *grubInterpreter) openFile(path string) ([]byte, bool) {
+	data, ok := in.lookupFile(path)
+	if !ok {
+		return nil, false
+	}
+	in.measure(in.filePCR, &AsciiStringEventData{data: []byte(path + "\x00")}, data)
+	return data, true
+}
+
+func (in *grubInterpreter) variable(name string) string {
+	switch name {
+	case "?":
+		return strconv.Itoa(in.status)
+	case "#":
+		return strconv.Itoa(len(in.params))
+	case "@", "*":
+		return strings.Join(in.params, " ")
+	}
+	if n, err := strconv.Atoi(name); err == nil {
+		if n > 0 && n <= len(in.params) {
+			return in.params[n-1]
+		}
+		return ""
+	}
+	return in.env[name]
+}
+
+// expandWord expands a word to zero or more arguments. Unquoted variable references are split in to separate arguments at
+// whitespace.
+func (in *grubInterpreter) expandWord(w grubWord) (out []string) {
+	var current strings.Builder
+	have := false
+	flush := func() {
+		if have {
+			out = append(out, current.String())
+		}
+		current.Reset()
+		have = false
+	}
+
+	for _, part := range w {
+		switch {
+		case !part.variable:
+			current.WriteString(part.text)
+			have = have || part.quoted || part.text != ""
+		case part.quoted:
+			current.WriteString(in.variable(part.text))
+			have = true
+		default:
+			value := in.variable(part.text)
+			if value == "" {
+				continue
+			}
+			if isGrubBlank(value[0]) || value[0] == '\n' {
+				flush()
+			}
+			for i, field := range strings.Fields(value) {
+				if i > 0 {
+					flush()
+				}
+				current.WriteString(field)
+				have = true
+			}
+			if last := value[len(value)-1]; isGrubBlank(last) || last == '\n' {
+				flush()
+			}
+		}
+	}
+	flush()
+	return out
+}
+
+func (in *grubInterpreter) execStatements(stmts []grubStatement) error {
+	for _, stmt := range stmts {
+		var err error
+		switch s := stmt.(type) {
+		case *grubCommand:
+			err = in.execCommand(s)
+		case *grubIf:
+			err = in.execIf(s)
+		case *grubFunction:
+			in.functions[s.name] = s.body
+			in.status = 0
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (in *grubInterpreter) execIf(s *grubIf) error {
+	for _, clause := range s.clauses {
+		if err := in.execStatements(clause.condition); err != nil {
+			return err
+		}
+		if in.status == 0 {
+			return in.execStatements(clause.body)
+		}
+	}
+	in.status = 0
+	return in.execStatements(s.els)
+}
+
+func (in *grubInterpreter) execScript(src, name string) error {
+	if in.depth >= grubMaxDepth {
+		return errors.New("maximum recursion depth exceeded")
+	}
+	in.depth++
+	defer func() { in.depth-- }()
+
+	stmts, err := parseGrubScript(src)
+	if err != nil {
+		return xerrors.Errorf("cannot parse %s: %w", name, err)
+	}
+	return in.execStatements(stmts)
+}
+
+// execCommand executes a simple command. GRUB measures the command with its expanded arguments separated by spaces before
+// executing it.
+//
+// https://git.savannah.gnu.org/cgit/grub.git/tree/grub-core/script/execute.c
+//  (grub_script_execute_cmdline)
+func (in *grubInterpreter) execCommand(c *grubCommand) error {
+	var args []string
+	for _, w := range c.words {
+		args = append(args, in.expandWord(w)...)
+	}
+	if c.hasBlock {
+		args = append(args, "{"+c.block+"}")
+	}
+	if len(args) == 0 {
+		return nil
+	}
+	in.measureString(GrubCmd, strings.Join(args, " "))
+
+	invert := false
+	if args[0] == "!" {
+		invert = true
+		args = args[1:]
+		if len(args) == 0 {
+			in.status = 0
+			return nil
+		}
+	}
+	if c.hasBlock {
+		args = args[:len(args)-1]
+	}
+
+	status, err := in.run(args[0], args[1:], c)
+	if err != nil {
+		return err
+	}
+	if invert {
+		if status == 0 {
+			status = 1
+		} else {
+			status = 0
+		}
+	}
+	in.status = status
+	return nil
+}
+
+func grubStatus(ok bool) int {
+	if ok {
+		return 0
+	}
+	return 1
+}
+
+func (in *grubInterpreter) run(name string, args []string, c *grubCommand) (int, error) {
+	switch name {
+	case "set":
+		for _, arg := range args {
+			if i := strings.IndexByte(arg, '='); i >= 0 {
+				in.env[arg[:i]] = arg[i+1:]
+			}
+		}
+		return 0, nil
+	case "unset":
+		for _, arg := range args {
+			delete(in.env, arg)
+		}
+		return 0, nil
+	case "true":
+		return 0, nil
+	case "false":
+		return 1, nil
+	case "[":
+		if len(args) == 0 || args[len(args)-1] != "]" {
+			return 1, nil
+		}
+		return grubStatus(in.test(args[:len(args)-1])), nil
+	case "test":
+		return grubStatus(in.test(args)), nil
+	case "setparams":
+		in.params = args
+		return 0, nil
+	case "source", ".":
+		if len(args) == 0 {
+			return 1, nil
+		}
+		data, ok := in.openFile(args[0])
+		if !ok {
+			return 1, nil
+		}
+		return in.status, in.execScript(string(data), args[0])
+	case "configfile":
+		if len(args) == 0 {
+			return 1, nil
+		}
+		data, ok := in.openFile(args[0])
+		if !ok {
+			return 1, nil
+		}
+		in.menu = nil
+		if err := in.execScript(string(data), args[0]); err != nil {
+			return 0, err
+		}
+		if err := in.boot(); err != nil {
+			return 0, err
+		}
+		return 0, errGrubBooted
+	case "load_env":
+		return in.loadEnv(args), nil
+	case "loadfont", "font":
+		return in.loadFont(args), nil
+	case "insmod":
+		return in.insmod(args), nil
+	case "search", "search.fs_uuid", "search.fs_label", "search.file":
+		return in.search(name, args), nil
+	case "linux", "linuxefi", "linux16":
+		return 0, in.loadLinux(args)
+	case "initrd", "initrdefi", "initrd16":
+		for _, path := range args {
+			if _, ok := in.openFile(path); !ok {
+				return 0, fmt.Errorf("cannot predict measurement of initrd %s: file not supplied", path)
+			}
+		}
+		return 0, nil
+	case "chainloader":
+		for _, path := range args {
+			if strings.HasPrefix(path, "-") {
+				continue
+			}
+			if _, ok := in.openFile(path); !ok {
+				return 0, fmt.Errorf("cannot predict measurement of image %s: file not supplied", path)
+			}
+			break
+		}
+		return 0, nil
+	case "menuentry", "submenu":
+		if !c.hasBlock {
+			return 1, nil
+		}
+		return grubStatus(in.addMenuEntry(args, c.block, name == "submenu")), nil
+	}
+
+	if body, ok := in.functions[name]; ok {
+		if in.depth >= grubMaxDepth {
+			return 0, errors.New("maximum recursion depth exceeded")
+		}
+		in.depth++
+		saved := in.params
+		in.params = args
+		err := in.execStatements(body)
+		in.params = saved
+		in.depth--
+		return in.status, err
+	}
+
+	if i := strings.IndexByte(name, '='); i > 0 {
+		// GRUB treats an unknown command containing '=' as an assignment.
+		in.env[name[:i]] = name[i+1:]
+		return 0, nil
+	}
+
+	return 0, nil
+}
+
+// test implements the subset of GRUB's test command that is used by generated configurations.
+func (in *grubInterpreter) test(args []string) bool {
+	for i := len(args) - 1; i >= 0; i-- {
+		if args[i] == "-o" {
+			return in.test(args[:i]) || in.test(args[i+1:])
+		}
+	}
+	for i := len(args) - 1; i >= 0; i-- {
+		if args[i] == "-a" {
+			return in.test(args[:i]) && in.test(args[i+1:])
+		}
+	}
+	if len(args) > 0 && args[0] == "!" {
+		return !in.test(args[1:])
+	}
+
+	switch len(args) {
+	case 0:
+		return false
+	case 1:
+		return args[0] != ""
+	case 2:
+		switch args[0] {
+		case "-z":
+			return args[1] == ""
+		case "-n":
+			return args[1] != ""
+		case "-e", "-f":
+			_, ok := in.lookupFile(args[1])
+			return ok
+		case "-d":
+			dir := strings.TrimSuffix(args[1], "/") + "/"
+			for path := range in.config.Files {
+				if strings.HasPrefix(path, dir) {
+					return true
+				}
+			}
+			return false
+		case "-s":
+			// GRUB opens the file to get its size, so it is measured.
+			data, ok := in.openFile(args[1])
+			return ok && len(data) > 0
+		}
+	case 3:
+		a, b := args[0], args[2]
+		switch args[1] {
+		case "=", "==":
+			return a == b
+		case "!=":
+			return a != b
+		case "<":
+			return a < b
+		case ">":
+			return a > b
+		case "<=":
+			return a <= b
+		case ">=":
+			return a >= b
+		}
+		x, _ := strconv.ParseInt(a, 10, 64)
+		y, _ := strconv.ParseInt(b, 10, 64)
+		switch args[1] {
+		case "-eq":
+			return x == y
+		case "-ne":
+			return x != y
+		case "-lt":
+			return x < y
+		case "-le":
+			return x <= y
+		case "-gt":
+			return x > y
+		case "-ge":
+			return x >= y
+		}
+	}
+	return false
+}
+
+func (in *grubInterpreter) loadEnv(args []string) int {
+	path := in.env["prefix"] + "/grubenv"
+	var whitelist []string
+	skipSig := false
+	for i := 0; i < len(args); i++ {
+		switch arg := args[i]; {
+		case arg == "-f" || arg == "--file":
+			if i+1 < len(args) {
+				i++
+				path = args[i]
+			}
+		case strings.HasPrefix(arg, "--file="):
+			path = strings.TrimPrefix(arg, "--file=")
+		case arg == "-s" || arg == "--skip-sig":
+			skipSig = true
+		default:
+			whitelist = append(whitelist, arg)
+		}
+	}
+
+	var data []byte
+	var ok bool
+	if skipSig {
+		// Files opened with --skip-sig bypass the verifiers, and so aren't measured.
+		data, ok = in.lookupFile(path)
+	} else {
+		data, ok = in.openFile(path)
+	}
+	if !ok {
+		return 1
+	}
+
+	for _, line := range strings.Split(string(data), "\n") {
+		if strings.HasPrefix(line, "#") {
+			continue
+		}
+		i := strings.IndexByte(line, '=')
+		if i < 0 {
+			continue
+		}
+		name := line[:i]
+		allowed := len(whitelist) == 0
+		for _, w := range whitelist {
+			if w == name {
+				allowed = true
+			}
+		}
+		if allowed {
+			in.env[name] = strings.NewReplacer("\\n", "\n", "\\\\", "\\").Replace(line[i+1:])
+		}
+	}
+	return 0
+}
+
+func (in *grubInterpreter) loadFont(args []string) int {
+	status := 0
+	for _, name := range args {
+		path := name
+		if !strings.HasPrefix(name, "/") && !strings.HasPrefix(name, "(") {
+			path = in.env["prefix"] + "/fonts/" + name + ".pf2"
+		}
+		if _, ok := in.openFile(path); !ok {
+			status = 1
+		}
+	}
+	return status
+}
+
+// insmod loads a module from the prefix directory, which is measured. Modules that are not supplied in the configuration are
+// assumed to be built in to the GRUB image, in which case no file is read.
+func (in *grubInterpreter) insmod(args []string) int {
+	if len(args) == 0 {
+		return 1
+	}
+	name := args[0]
+	if in.modules[name] {
+		return 0
+	}
+	in.modules[name] = true
+
+	path := name
+	if !strings.HasPrefix(name, "/") && !strings.HasPrefix(name, "(") {
+		path = fmt.Sprintf("%s/%s-%s/%s.mod", in.env["prefix"], in.env["grub_cpu"], in.env["grub_platform"], name)
+	}
+	in.openFile(path)
+	return 0
+}
+
+func (in *grubInterpreter) search(name string, args []string) int {
+	var variable string
+	var positional []string
+	for _, arg := range args {
+		switch {
+		case arg == "--set" || arg == "-s":
+			variable = "root"
+		case strings.HasPrefix(arg, "--set="):
+			variable = strings.TrimPrefix(arg, "--set=")
+		case strings.HasPrefix(arg, "-"):
+		default:
+			positional = append(positional, arg)
+		}
+	}
+	if len(positional) == 0 {
+		return 1
+	}
+	if name != "search" && len(positional) > 1 {
+		variable = positional[1]
+	}
+
+	device, ok := in.config.SearchResults[positional[0]]
+	if !ok {
+		return 1
+	}
+	if variable != "" {
+		in.env[variable] = device
+	}
+	return 0
+}
+
+// grubLoaderCmdline constructs a kernel command line from the arguments of the linux command in the same way as GRUB, which
+// quotes arguments that contain spaces and escapes quotes and backslashes.
+//
+// https://git.savannah.gnu.org/cgit/grub.git/tree/grub-core/lib/cmdline.c
+//  (grub_create_loader_cmdline)
+func grubLoaderCmdline(args []string) string {
+	var b strings.Builder
+	for i, arg := range args {
+		if i > 0 {
+			b.WriteByte(' ')
+		}
+		space := strings.IndexByte(arg, ' ') >= 0
+		if space {
+			b.WriteByte('"')
+		}
+		for j := 0; j < len(arg); j++ {
+			if c := arg[j]; c == '\\' || c == '\'' || c == '"' {
+				b.WriteByte('\\')
+			}
+			b.WriteByte(arg[j])
+		}
+		if space {
+			b.WriteByte('"')
+		}
+	}
+	return b.String()
+}
+
+// loadLinux simulates the linux command, which measures the kernel image followed by the kernel command line (which includes
+// the path of the kernel).
+func (in *grubInterpreter) loadLinux(args []string) error {
+	if len(args) == 0 {
+		return nil
+	}
+	if _, ok := in.openFile(args[0]); !ok {
+		return fmt.Errorf("cannot predict measurement of kernel %s: file not supplied", args[0])
+	}
+	in.measureString(KernelCmdline, grubLoaderCmdline(args))
+	return nil
+}
+
+// addMenuEntry implements the menuentry and submenu commands.
+//
+// https://git.savannah.gnu.org/cgit/grub.git/tree/grub-core/commands/menuentry.c
+func (in *grubInterpreter) addMenuEntry(args []string, body string, submenu bool) bool {
+	entry := &grubMenuEntry{body: body, submenu: submenu}
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		switch {
+		case arg == "--class" || arg == "--users" || arg == "--hotkey" || arg == "--source":
+			i++
+		case arg == "--id":
+			if i+1 < len(args) {
+				i++
+				entry.id = args[i]
+			}
+		case strings.HasPrefix(arg, "--id="):
+			entry.id = strings.TrimPrefix(arg, "--id=")
+		case strings.HasPrefix(arg, "--"):
+		default:
+			entry.params = append(entry.params, arg)
+		}
+	}
+	if len(entry.params) == 0 {
+		return false
+	}
+	entry.title = entry.params[0]
+	in.menu = append(in.menu, entry)
+	return true
+}
+
+func (in *grubInterpreter) findMenuEntry(menu []*grubMenuEntry, selector string) *grubMenuEntry {
+	if n, err := strconv.Atoi(selector); err == nil {
+		if n >= 0 && n < len(menu) {
+			return menu[n]
+		}
+		return nil
+	}
+	for _, e := range menu {
+		if e.id == selector || e.title == selector {
+			return e
+		}
+	}
+	return nil
+}
+
+// boot simulates GRUB booting the selected menu entry. GRUB executes the body of the entry prefixed with a setparams command
+// that sets the positional parameters to the arguments of the entry, which is measured like any other command. A submenu is
+// executed in the same way in order to construct its menu.
+func (in *grubInterpreter) boot() error {
+	selector := in.config.Entry
+	if selector == "" {
+		selector = in.env["default"]
+	}
+	if selector == "" {
+		selector = "0"
+	}
+
+	menu := in.menu
+	parts := strings.Split(selector, ">")
+	for i, part := range parts {
+		entry := in.findMenuEntry(menu, part)
+		if entry == nil {
+			return fmt.Errorf("no menu entry \"%s\"", strings.Join(parts[:i+1], ">"))
+		}
+		if entry.submenu == (i == len(parts)-1) {
+			if entry.submenu {
+				return fmt.Errorf("menu entry \"%s\" is a submenu", selector)
+			}
+			return fmt.Errorf("menu entry \"%s\" is not a submenu", strings.Join(parts[:i+1], ">"))
+		}
+
+		in.measureString(GrubCmd, "setparams "+strings.Join(entry.params, " "))
+		in.params = entry.params
+		in.menu = nil
+		if err := in.execScript(entry.body, fmt.Sprintf("menu entry \"%s\"", entry.title)); err != nil {
+			return err
+		}
+		menu = in.menu
+	}
+	return nil
+}
+
+func checkGrubVersion(version string) error {
+	if version == "" {
+		return nil
+	}
+	var major, minor int
+	if _, err := fmt.Sscanf(version, "%d.%d", &major, &minor); err != nil {
+		return fmt.Errorf("invalid GRUB version \"%s\"", version)
+	}
+	if major < 2 || (major == 2 && minor < 4) {
+		return fmt.Errorf("GRUB %s doesn't perform TPM measurements", version)
+	}
+	return nil
+}
+
+// PredictGrubMeasurements predicts the events that GRUB will measure when booting with the supplied configuration, by executing
+// the configuration file in the same way that GRUB does. The returned events contain digests for the specified algorithms, and
+// are measured to the PCRs determined by options (8 and 9 by default), in the order that GRUB measures them.
+//
+// GRUB (from version 2.04) measures every command that it executes along with its expanded arguments, the kernel command line
+// and every file that it reads. This includes the configuration file, any files that it sources, the kernel and initrds and
+// any modules and fonts that are loaded from disk. Only the subset of the GRUB script language that is used by generated
+// configurations is supported. Commands that don't read files or affect the environment are assumed to succeed.
+//
+// An error is returned if the configuration refers to a kernel or initrd that isn't supplied in config.Files, because its
+// measurement can't be predicted.
+func PredictGrubMeasurements(config *GrubBootConfig, algorithms AlgorithmIdList, options *LogOptions) ([]*Event, error) {
+	if err := checkGrubVersion(config.Version); err != nil {
+		return nil, err
+	}
+	for _, alg := range algorithms {
+		if !alg.supported() {
+			return nil, fmt.Errorf("unsupported algorithm %v", alg)
+		}
+	}
+	if options == nil {
+		options = &LogOptions{}
+	}
+
+	in := &grubInterpreter{
+		config:    config,
+		algs:      algorithms,
+		cmdPCR:    options.grubCmdPCR(),
+		filePCR:   options.grubFilePCR(),
+		env:       make(map[string]string),
+		functions: make(map[string][]grubStatement),
+		modules:   make(map[string]bool),
+		indices:   make(map[PCRIndex]uint)}
+	for name, value := range config.Env {
+		in.env[name] = value
+	}
+
+	data, ok := in.openFile(config.ConfigPath)
+	if !ok {
+		return nil, fmt.Errorf("configuration file %s not supplied", config.ConfigPath)
+	}
+	err := in.execScript(string(data), config.ConfigPath)
+	if err == nil {
+		err = in.boot()
+	}
+	if err != nil && err != errGrubBooted {
+		return nil, err
+	}
+	return in.events, nil
+}
+
+// SimulateGrubBoot simulates GRUB booting with the supplied configuration, by replacing the events that GRUB measured to its
+// command and file PCRs in the simulated log with those predicted by PredictGrubMeasurements. An error is returned if the log
+// doesn't contain any events measured by GRUB.
+func (s *Simulation) SimulateGrubBoot(config *GrubBootConfig) error {
+	events, err := PredictGrubMeasurements(config, s.log.Algorithms, &s.options)
+	if err != nil {
+		return err
+	}
+
+	first := -1
+	for _, e := range s.base.Events {
+		if e.EventType != EventTypeIPL || (e.PCRIndex != s.options.grubCmdPCR() && e.PCRIndex != s.options.grubFilePCR()) {
+			continue
+		}
+		sim, ok := s.events[e]
+		if !ok {
+			continue
+		}
+		if first < 0 {
+			for i, c := range s.log.Events {
+				if c == sim {
+					first = i
+				}
+			}
+		}
+		if err := s.DropEvent(e); err != nil {
+			return err
+		}
+	}
+	if first < 0 {
+		return errors.New("no events measured by GRUB in the log")
+	}
+
+	for i, e := range events {
+		if err := s.log.InsertEvent(first+i, e); err != nil {
+			return err
+		}
+	}
+	return nil
+}
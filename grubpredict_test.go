@@ -0,0 +1,254 @@
+// Copyright 2019 Canonical Ltd.
+// Licensed under the LGPLv3 with static-linking exception.
+// See LICENCE file for details.
+
+package tcglog
+
+import (
+	"bytes"
+	"fmt"
+	"testing"
+)
+
+const testGrubStubConfig = `search.fs_uuid 0f3b1c2a-1111-2222-3333-444455556666 root hd0,gpt2
+set prefix=($root)'/boot/grub'
+configfile $prefix/grub.cfg
+`
+
+const testGrubConfig = `if [ -s $prefix/grubenv ]; then
+  set have_grubenv=true
+  load_env
+fi
+set default="0"
+function load_video {
+  insmod all_video
+}
+font=unicode
+if loadfont $font ; then
+  set gfxmode=auto
+fi
+menuentry 'Ubuntu' --class ubuntu $menuentry_id_option 'gnulinux-simple' {
+	load_video
+	insmod gzio
+	if [ x$grub_platform = xxen ]; then insmod xzio; fi
+	linux	/boot/vmlinuz-5.4.0-42-generic root=UUID=abcd ro quiet splash $vt_handoff
+	initrd	/boot/initrd.img-5.4.0-42-generic
+}
+submenu 'Advanced options for Ubuntu' $menuentry_id_option 'gnulinux-advanced' {
+	menuentry 'Ubuntu, with Linux 5.4.0-40-generic' --class ubuntu $menuentry_id_option 'gnulinux-5.4.0-40-generic' {
+		linux	/boot/vmlinuz-5.4.0-40-generic root=UUID=abcd ro "acpi_osi=Windows 2015"
+		initrd	/boot/initrd.img-5.4.0-40-generic
+	}
+}
+`
+
+func makeTestGrubBootConfig() *GrubBootConfig {
+	return &GrubBootConfig{
+		Version:    "2.04-1ubuntu26",
+		ConfigPath: "(hd0,gpt1)/EFI/ubuntu/grub.cfg",
+		Env: map[string]string{
+			"prefix":                   "(hd0,gpt1)/EFI/ubuntu",
+			"root":                     "hd0,gpt1",
+			"grub_cpu":                 "x86_64",
+			"grub_platform":            "efi",
+			"menuentry_id_option":      "--id",
+			"vt_handoff":               "vt.handoff=7",
+			"feature_all_video_module": "y"},
+		Files: map[string][]byte{
+			"(hd0,gpt1)/EFI/ubuntu/grub.cfg":              []byte(testGrubStubConfig),
+			"(hd0,gpt2)/boot/grub/grub.cfg":               []byte(testGrubConfig),
+			"(hd0,gpt2)/boot/grub/grubenv":                []byte("# GRUB Environment Block\nrecordfail=1\n#########"),
+			"(hd0,gpt2)/boot/grub/fonts/unicode.pf2":      []byte("font"),
+			"(hd0,gpt2)/boot/vmlinuz-5.4.0-42-generic":    []byte("kernel 42"),
+			"(hd0,gpt2)/boot/initrd.img-5.4.0-42-generic": []byte("initrd 42"),
+			"/boot/vmlinuz-5.4.0-40-generic":              []byte("kernel 40"),
+			"/boot/initrd.img-5.4.0-40-generic":           []byte("initrd 40")},
+		SearchResults: map[string]string{"0f3b1c2a-1111-2222-3333-444455556666": "hd0,gpt2"}}
+}
+
+func grubEventStrings(events []*Event) (out []string) {
+	for _, e := range events {
+		out = append(out, fmt.Sprintf("%d %s", e.PCRIndex, e.Data))
+	}
+	return out
+}
+
+func TestPredictGrubMeasurements(t *testing.T) {
+	config := makeTestGrubBootConfig()
+	events, err := PredictGrubMeasurements(config, AlgorithmIdList{AlgorithmSha1, AlgorithmSha256}, nil)
+	if err != nil {
+		t.Fatalf("PredictGrubMeasurements failed: %v", err)
+	}
+
+	expected := []string{
+		"9 (hd0,gpt1)/EFI/ubuntu/grub.cfg",
+		"8 grub_cmd{ search.fs_uuid 0f3b1c2a-1111-2222-3333-444455556666 root hd0,gpt2 }",
+		"8 grub_cmd{ set prefix=(hd0,gpt2)/boot/grub }",
+		"8 grub_cmd{ configfile (hd0,gpt2)/boot/grub/grub.cfg }",
+		"9 (hd0,gpt2)/boot/grub/grub.cfg",
+		"8 grub_cmd{ [ -s (hd0,gpt2)/boot/grub/grubenv ] }",
+		"9 (hd0,gpt2)/boot/grub/grubenv",
+		"8 grub_cmd{ set have_grubenv=true }",
+		"8 grub_cmd{ load_env }",
+		"9 (hd0,gpt2)/boot/grub/grubenv",
+		"8 grub_cmd{ set default=0 }",
+		"8 grub_cmd{ font=unicode }",
+		"8 grub_cmd{ loadfont unicode }",
+		"9 (hd0,gpt2)/boot/grub/fonts/unicode.pf2",
+		"8 grub_cmd{ set gfxmode=auto }",
+		"8 grub_cmd{ menuentry Ubuntu --class ubuntu --id gnulinux-simple {\\x0a\\x09load_video\\x0a\\x09insmod gzio\\x0a\\x09if [ x$grub_platform = xxen ]; then insmod xzio; fi\\x0a\\x09linux\\x09/boot/vmlinuz-5.4.0-42-generic root=UUID=abcd ro quiet splash $vt_handoff\\x0a\\x09initrd\\x09/boot/initrd.img-5.4.0-42-generic\\x0a} }",
+		"8 grub_cmd{ submenu Advanced options for Ubuntu --id gnulinux-advanced {\\x0a\\x09menuentry 'Ubuntu, with Linux 5.4.0-40-generic' --class ubuntu $menuentry_id_option 'gnulinux-5.4.0-40-generic' {\\x0a\\x09\\x09linux\\x09/boot/vmlinuz-5.4.0-40-generic root=UUID=abcd ro \"acpi_osi=Windows 2015\"\\x0a\\x09\\x09initrd\\x09/boot/initrd.img-5.4.0-40-generic\\x0a\\x09}\\x0a} }",
+		"8 grub_cmd{ setparams Ubuntu }",
+		"8 grub_cmd{ load_video }",
+		"8 grub_cmd{ insmod all_video }",
+		"8 grub_cmd{ insmod gzio }",
+		"8 grub_cmd{ [ xefi = xxen ] }",
+		"8 grub_cmd{ linux /boot/vmlinuz-5.4.0-42-generic root=UUID=abcd ro quiet splash vt.handoff=7 }",
+		"9 /boot/vmlinuz-5.4.0-42-generic",
+		"8 kernel_cmdline{ /boot/vmlinuz-5.4.0-42-generic root=UUID=abcd ro quiet splash vt.handoff=7 }",
+		"8 grub_cmd{ initrd /boot/initrd.img-5.4.0-42-generic }",
+		"9 /boot/initrd.img-5.4.0-42-generic",
+	}
+	strs := grubEventStrings(events)
+	if len(strs) != len(expected) {
+		t.Fatalf("Unexpected events:\n%q", strs)
+	}
+	for i, s := range strs {
+		if s != expected[i] {
+			t.Errorf("Unexpected event %d: %q (expected %q)", i, s, expected[i])
+		}
+	}
+
+	indices := make(map[PCRIndex]uint)
+	for i, e := range events {
+		if e.Index != indices[e.PCRIndex] || e.EventType != EventTypeIPL {
+			t.Errorf("Unexpected index or type for event %d", i)
+		}
+		indices[e.PCRIndex]++
+	}
+	if !bytes.Equal(events[23].Digests[AlgorithmSha256], AlgorithmSha256.hash([]byte("kernel 42"))) {
+		t.Errorf("Unexpected kernel digest")
+	}
+	if !bytes.Equal(events[24].Digests[AlgorithmSha1], AlgorithmSha1.hash([]byte(
+		"/boot/vmlinuz-5.4.0-42-generic root=UUID=abcd ro quiet splash vt.handoff=7"))) {
+		t.Errorf("Unexpected kernel command line digest")
+	}
+
+	// The predicted events should be encoded in the same way as the events decoded from a log.
+	var b bytes.Buffer
+	if err := events[24].Data.(*GrubStringEventData).EncodeTo(&b); err != nil {
+		t.Fatalf("EncodeTo failed: %v", err)
+	}
+	decoded := DecodeEventData(8, EventTypeIPL, events[24].Digests, b.Bytes(), &LogOptions{EnableGrub: true})
+	if decoded.String() != events[24].Data.String() {
+		t.Errorf("Unexpected decoded event data: %s", decoded)
+	}
+}
+
+func TestPredictGrubMeasurementsSubmenu(t *testing.T) {
+	config := makeTestGrubBootConfig()
+	config.Entry = "gnulinux-advanced>Ubuntu, with Linux 5.4.0-40-generic"
+	events, err := PredictGrubMeasurements(config, AlgorithmIdList{AlgorithmSha256}, &LogOptions{GrubCmdPCR: 12, GrubFilePCR: 13})
+	if err != nil {
+		t.Fatalf("PredictGrubMeasurements failed: %v", err)
+	}
+
+	strs := grubEventStrings(events)
+	expected := []string{
+		"12 grub_cmd{ setparams Advanced options for Ubuntu }",
+		"12 grub_cmd{ menuentry Ubuntu, with Linux 5.4.0-40-generic --class ubuntu --id gnulinux-5.4.0-40-generic {\\x0a\\x09\\x09linux\\x09/boot/vmlinuz-5.4.0-40-generic root=UUID=abcd ro \"acpi_osi=Windows 2015\"\\x0a\\x09\\x09initrd\\x09/boot/initrd.img-5.4.0-40-generic\\x0a\\x09} }",
+		"12 grub_cmd{ setparams Ubuntu, with Linux 5.4.0-40-generic }",
+		"12 grub_cmd{ linux /boot/vmlinuz-5.4.0-40-generic root=UUID=abcd ro acpi_osi=Windows 2015 }",
+		"13 /boot/vmlinuz-5.4.0-40-generic",
+		"12 kernel_cmdline{ /boot/vmlinuz-5.4.0-40-generic root=UUID=abcd ro \"acpi_osi=Windows 2015\" }",
+		"12 grub_cmd{ initrd /boot/initrd.img-5.4.0-40-generic }",
+		"13 /boot/initrd.img-5.4.0-40-generic",
+	}
+	if len(strs) < len(expected) {
+		t.Fatalf("Unexpected events:\n%q", strs)
+	}
+	strs = strs[len(strs)-len(expected):]
+	for i, s := range strs {
+		if s != expected[i] {
+			t.Errorf("Unexpected event %d: %q (expected %q)", i, s, expected[i])
+		}
+	}
+}
+
+func TestPredictGrubMeasurementsErrors(t *testing.T) {
+	for _, data := range []struct {
+		desc   string
+		modify func(*GrubBootConfig)
+		err    string
+	}{
+		{
+			desc:   "OldVersion",
+			modify: func(c *GrubBootConfig) { c.Version = "2.02~beta2" },
+			err:    "GRUB 2.02~beta2 doesn't perform TPM measurements",
+		},
+		{
+			desc:   "MissingConfig",
+			modify: func(c *GrubBootConfig) { c.ConfigPath = "(hd0,gpt1)/EFI/BOOT/grub.cfg" },
+			err:    "configuration file (hd0,gpt1)/EFI/BOOT/grub.cfg not supplied",
+		},
+		{
+			desc:   "MissingKernel",
+			modify: func(c *GrubBootConfig) { delete(c.Files, "(hd0,gpt2)/boot/vmlinuz-5.4.0-42-generic") },
+			err:    "cannot predict measurement of kernel /boot/vmlinuz-5.4.0-42-generic: file not supplied",
+		},
+		{
+			desc:   "MissingEntry",
+			modify: func(c *GrubBootConfig) { c.Entry = "gnulinux-advanced>Ubuntu, with Linux 5.8.0-1-generic" },
+			err:    "no menu entry \"gnulinux-advanced>Ubuntu, with Linux 5.8.0-1-generic\"",
+		},
+		{
+			desc:   "Submenu",
+			modify: func(c *GrubBootConfig) { c.Entry = "1" },
+			err:    "menu entry \"1\" is a submenu",
+		},
+	} {
+		t.Run(data.desc, func(t *testing.T) {
+			config := makeTestGrubBootConfig()
+			data.modify(config)
+			if _, err := PredictGrubMeasurements(config, AlgorithmIdList{AlgorithmSha256}, nil); err == nil || err.Error() != data.err {
+				t.Errorf("Unexpected error: %v", err)
+			}
+		})
+	}
+}
+
+func TestGrubLoaderCmdline(t *testing.T) {
+	cmdline := grubLoaderCmdline([]string{"/vmlinuz", "a=b c", `d="e"`, `f\g`})
+	if expected := `/vmlinuz "a=b c" d=\"e\" f\\g`; cmdline != expected {
+		t.Errorf("Unexpected command line: %s", cmdline)
+	}
+}
+
+func TestSimulateGrubBoot(t *testing.T) {
+	log := makeBootAssetsTestLog()
+	s := NewSimulation(log, nil)
+	if err := s.SimulateGrubBoot(makeTestGrubBootConfig()); err != nil {
+		t.Fatalf("SimulateGrubBoot failed: %v", err)
+	}
+
+	events := s.Log().Events
+	if len(events) != 3+27 {
+		t.Fatalf("Unexpected number of events: %d", len(events))
+	}
+	if events[3].PCRIndex != 9 || events[3].Data.String() != "(hd0,gpt1)/EFI/ubuntu/grub.cfg" {
+		t.Errorf("Unexpected first GRUB event: %s", events[3].Data)
+	}
+	if events[len(events)-1].Index != 6 {
+		t.Errorf("Unexpected index for the last event: %d", events[len(events)-1].Index)
+	}
+
+	var pcrs []PCRIndex
+	for _, r := range s.Run() {
+		if r.Changed() && r.Algorithm == AlgorithmSha256 {
+			pcrs = append(pcrs, r.PCRIndex)
+		}
+	}
+	if len(pcrs) != 2 || pcrs[0] != 8 || pcrs[1] != 9 {
+		t.Errorf("Unexpected changed PCRs: %v", pcrs)
+	}
+}
@@ -0,0 +1,417 @@
+// Copyright 2019 Canonical Ltd.
+// Licensed under the LGPLv3 with static-linking exception.
+// See LICENCE file for details.
+
+package tcglog
+
+import (
+	"fmt"
+	"strings"
+)
+
+// grubWordPart is part of a word in a GRUB script, which is either literal text or a variable reference. Quoted parts are
+// not subject to field splitting.
+type grubWordPart struct {
+	text     string // The literal text, or the name of the variable
+	variable bool
+	quoted   bool
+}
+
+type grubWord []grubWordPart
+
+// literal returns the text of this word if it consists only of unquoted literal text, which is how keywords are recognized.
+func (w grubWord) literal() (string, bool) {
+	if len(w) != 1 || w[0].variable || w[0].quoted {
+		return "", false
+	}
+	return w[0].text, true
+}
+
+// grubStatement is a statement in a parsed GRUB script.
+type grubStatement interface{}
+
+// grubCommand is a simple command. If the command is followed by a block (as menuentry and submenu are), the source code of
+// the block is recorded, as GRUB passes it to the command verbatim.
+type grubCommand struct {
+	words    []grubWord
+	hasBlock bool
+	block    string
+}
+
+type grubIfClause struct {
+	condition []grubStatement
+	body      []grubStatement
+}
+
+type grubIf struct {
+	clauses []*grubIfClause
+	els     []grubStatement
+}
+
+type grubFunction struct {
+	name string
+	body []grubStatement
+}
+
+type grubTokenKind int
+
+const (
+	grubTokenEOF grubTokenKind = iota
+	grubTokenWord
+	grubTokenSeparator // A newline or semicolon
+	grubTokenLBrace
+	grubTokenRBrace
+)
+
+type grubToken struct {
+	kind  grubTokenKind
+	word  grubWord
+	start int // The offset of the start of the token in the source
+	end   int // The offset of the end of the token in the source
+}
+
+// grubParser parses the subset of the GRUB script language that is used by generated configurations: simple commands,
+// comments, quoting, variable references, if statements, function definitions and commands with blocks. Loops are not
+// supported.
+//
+// https://git.savannah.gnu.org/cgit/grub.git/tree/grub-core/script/yylex.l
+// https://git.savannah.gnu.org/cgit/grub.git/tree/grub-core/script/parser.y
+type grubParser struct {
+	src  string
+	pos  int
+	peek *grubToken
+}
+
+func isGrubBlank(c byte) bool {
+	return c == ' ' || c == '\t' || c == '\r'
+}
+
+func isGrubVariableChar(c byte) bool {
+	return c == '_' || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z') || (c >= '0' && c <= '9')
+}
+
+func isGrubWordEnd(c byte) bool {
+	return isGrubBlank(c) || c == '\n' || c == ';'
+}
+
+func (p *grubParser) errorf(format string, args ...interface{}) error {
+	line := strings.Count(p.src[:p.pos], "\n") + 1
+	return fmt.Errorf("line %d: %s", line, fmt.Sprintf(format, args...))
+}
+
+func (p *grubParser) appendLiteral(w grubWord, text string, quoted bool) grubWord {
+	if n := len(w); n > 0 && !w[n-1].variable && w[n-1].quoted == quoted {
+		w[n-1].text += text
+		return w
+	}
+	return append(w, grubWordPart{text: text, quoted: quoted})
+}
+
+func (p *grubParser) scanVariable(w grubWord, quoted bool) (grubWord, error) {
+	// p.pos is after the '$'.
+	switch {
+	case p.pos < len(p.src) && p.src[p.pos] == '{':
+		end := strings.IndexByte(p.src[p.pos:], '}')
+		if end < 0 {
+			return nil, p.errorf("unterminated variable reference")
+		}
+		name := p.src[p.pos+1 : p.pos+end]
+		p.pos += end + 1
+		return append(w, grubWordPart{text: name, variable: true, quoted: quoted}), nil
+	case p.pos < len(p.src) && (p.src[p.pos] == '?' || p.src[p.pos] == '#' || p.src[p.pos] == '@' || p.src[p.pos] == '*'):
+		p.pos++
+		return append(w, grubWordPart{text: p.src[p.pos-1 : p.pos], variable: true, quoted: quoted}), nil
+	}
+
+	start := p.pos
+	for p.pos < len(p.src) && isGrubVariableChar(p.src[p.pos]) {
+		p.pos++
+	}
+	if start == p.pos {
+		return p.appendLiteral(w, "$", quoted), nil
+	}
+	return append(w, grubWordPart{text: p.src[start:p.pos], variable: true, quoted: quoted}), nil
+}
+
+func (p *grubParser) scanWord() (grubWord, error) {
+	w := grubWord{}
+	for p.pos < len(p.src) && !isGrubWordEnd(p.src[p.pos]) {
+		c := p.src[p.pos]
+		switch c {
+		case '\\':
+			p.pos++
+			if p.pos == len(p.src) {
+				return nil, p.errorf("unexpected end of script after '\\'")
+			}
+			if p.src[p.pos] != '\n' {
+				// An escaped newline is a line continuation.
+				w = p.appendLiteral(w, p.src[p.pos:p.pos+1], true)
+			}
+			p.pos++
+		case '\'':
+			end := strings.IndexByte(p.src[p.pos+1:], '\'')
+			if end < 0 {
+				return nil, p.errorf("unterminated single quoted string")
+			}
+			w = p.appendLiteral(w, p.src[p.pos+1:p.pos+1+end], true)
+			p.pos += end + 2
+		case '"':
+			// Make sure that an empty quoted string is still a word.
+			w = p.appendLiteral(w, "", true)
+			p.pos++
+			for {
+				if p.pos == len(p.src) {
+					return nil, p.errorf("unterminated double quoted string")
+				}
+				c := p.src[p.pos]
+				if c == '"' {
+					p.pos++
+					break
+				}
+				switch {
+				case c == '\\' && p.pos+1 < len(p.src) && strings.IndexByte("\\$\"\n", p.src[p.pos+1]) >= 0:
+					if p.src[p.pos+1] != '\n' {
+						w = p.appendLiteral(w, p.src[p.pos+1:p.pos+2], true)
+					}
+					p.pos += 2
+				case c == '$':
+					p.pos++
+					var err error
+					if w, err = p.scanVariable(w, true); err != nil {
+						return nil, err
+					}
+				default:
+					w = p.appendLiteral(w, p.src[p.pos:p.pos+1], true)
+					p.pos++
+				}
+			}
+		case '$':
+			p.pos++
+			var err error
+			if w, err = p.scanVariable(w, false); err != nil {
+				return nil, err
+			}
+		default:
+			w = p.appendLiteral(w, p.src[p.pos:p.pos+1], false)
+			p.pos++
+		}
+	}
+	return w, nil
+}
+
+func (p *grubParser) scan() (*grubToken, error) {
+	for p.pos < len(p.src) {
+		c := p.src[p.pos]
+		switch {
+		case isGrubBlank(c):
+			p.pos++
+		case c == '#':
+			// Comments continue to the end of the line.
+			end := strings.IndexByte(p.src[p.pos:], '\n')
+			if end < 0 {
+				p.pos = len(p.src)
+			} else {
+				p.pos += end
+			}
+		case c == '\\' && p.pos+1 < len(p.src) && p.src[p.pos+1] == '\n':
+			p.pos += 2
+		case c == '\n' || c == ';':
+			p.pos++
+			return &grubToken{kind: grubTokenSeparator, start: p.pos - 1, end: p.pos}, nil
+		default:
+			start := p.pos
+			w, err := p.scanWord()
+			if err != nil {
+				return nil, err
+			}
+			t := &grubToken{kind: grubTokenWord, word: w, start: start, end: p.pos}
+			switch s, _ := w.literal(); s {
+			case "{":
+				t.kind = grubTokenLBrace
+			case "}":
+				t.kind = grubTokenRBrace
+			}
+			return t, nil
+		}
+	}
+	return &grubToken{kind: grubTokenEOF, start: p.pos, end: p.pos}, nil
+}
+
+func (p *grubParser) next() (*grubToken, error) {
+	if t := p.peek; t != nil {
+		p.peek = nil
+		return t, nil
+	}
+	return p.scan()
+}
+
+func (p *grubParser) unread(t *grubToken) {
+	p.peek = t
+}
+
+// skipSeparators skips newlines and semicolons, returning the next token.
+func (p *grubParser) skipSeparators() (*grubToken, error) {
+	for {
+		t, err := p.next()
+		if err != nil {
+			return nil, err
+		}
+		if t.kind != grubTokenSeparator {
+			return t, nil
+		}
+	}
+}
+
+// parseBlock parses a block after its opening brace, returning the parsed statements and the source code between the braces.
+func (p *grubParser) parseBlock(start int) ([]grubStatement, string, error) {
+	body, t, err := p.parseStatements()
+	if err != nil {
+		return nil, "", err
+	}
+	if t.kind != grubTokenRBrace {
+		return nil, "", p.errorf("expected \"}\"")
+	}
+	return body, p.src[start:t.start], nil
+}
+
+func (p *grubParser) parseIf() (grubStatement, error) {
+	out := new(grubIf)
+	for {
+		clause := new(grubIfClause)
+		condition, t, err := p.parseStatements("then")
+		if err != nil {
+			return nil, err
+		}
+		if s, _ := t.word.literal(); s != "then" {
+			return nil, p.errorf("expected \"then\"")
+		}
+		body, t, err := p.parseStatements("elif", "else", "fi")
+		if err != nil {
+			return nil, err
+		}
+		clause.condition = condition
+		clause.body = body
+		out.clauses = append(out.clauses, clause)
+
+		switch s, _ := t.word.literal(); s {
+		case "elif":
+			continue
+		case "else":
+			els, t, err := p.parseStatements("fi")
+			if err != nil {
+				return nil, err
+			}
+			if s, _ := t.word.literal(); s != "fi" {
+				return nil, p.errorf("expected \"fi\"")
+			}
+			out.els = els
+			return out, nil
+		case "fi":
+			return out, nil
+		default:
+			return nil, p.errorf("expected \"fi\"")
+		}
+	}
+}
+
+func (p *grubParser) parseFunction() (grubStatement, error) {
+	t, err := p.next()
+	if err != nil {
+		return nil, err
+	}
+	name, ok := t.word.literal()
+	if t.kind != grubTokenWord || !ok {
+		return nil, p.errorf("expected function name")
+	}
+	if t, err = p.skipSeparators(); err != nil {
+		return nil, err
+	}
+	if t.kind != grubTokenLBrace {
+		return nil, p.errorf("expected \"{\"")
+	}
+	body, _, err := p.parseBlock(t.end)
+	if err != nil {
+		return nil, err
+	}
+	return &grubFunction{name: name, body: body}, nil
+}
+
+func (p *grubParser) parseCommand(first *grubToken) (grubStatement, error) {
+	cmd := &grubCommand{words: []grubWord{first.word}}
+	for {
+		t, err := p.next()
+		if err != nil {
+			return nil, err
+		}
+		switch t.kind {
+		case grubTokenWord:
+			cmd.words = append(cmd.words, t.word)
+		case grubTokenLBrace:
+			if _, cmd.block, err = p.parseBlock(t.end); err != nil {
+				return nil, err
+			}
+			cmd.hasBlock = true
+			return cmd, nil
+		case grubTokenRBrace:
+			p.unread(t)
+			return cmd, nil
+		default:
+			return cmd, nil
+		}
+	}
+}
+
+// parseStatements parses statements until the end of the script, a closing brace or one of the specified keywords at the
+// start of a command. The token that ended the statements is returned.
+func (p *grubParser) parseStatements(terminators ...string) (out []grubStatement, end *grubToken, err error) {
+	for {
+		t, err := p.skipSeparators()
+		if err != nil {
+			return nil, nil, err
+		}
+		switch t.kind {
+		case grubTokenEOF, grubTokenRBrace:
+			return out, t, nil
+		case grubTokenLBrace:
+			return nil, nil, p.errorf("unexpected \"{\"")
+		}
+
+		keyword, _ := t.word.literal()
+		for _, terminator := range terminators {
+			if keyword == terminator {
+				return out, t, nil
+			}
+		}
+
+		var stmt grubStatement
+		switch keyword {
+		case "if":
+			stmt, err = p.parseIf()
+		case "function":
+			stmt, err = p.parseFunction()
+		case "then", "elif", "else", "fi":
+			err = p.errorf("unexpected \"%s\"", keyword)
+		case "for", "while", "until", "do", "done", "case", "esac", "select":
+			err = p.errorf("unsupported keyword \"%s\"", keyword)
+		default:
+			stmt, err = p.parseCommand(t)
+		}
+		if err != nil {
+			return nil, nil, err
+		}
+		out = append(out, stmt)
+	}
+}
+
+// parseGrubScript parses the supplied GRUB script.
+func parseGrubScript(src string) ([]grubStatement, error) {
+	p := &grubParser{src: src}
+	out, t, err := p.parseStatements()
+	if err != nil {
+		return nil, err
+	}
+	if t.kind != grubTokenEOF {
+		return nil, p.errorf("unexpected \"}\"")
+	}
+	return out, nil
+}
@@ -0,0 +1,108 @@
+// Copyright 2019 Canonical Ltd.
+// Licensed under the LGPLv3 with static-linking exception.
+// See LICENCE file for details.
+
+package tcglog
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseGrubScript(t *testing.T) {
+	for _, data := range []struct {
+		desc     string
+		src      string
+		expected []grubStatement
+	}{
+		{
+			desc: "Commands",
+			src:  "insmod gzio; set root='hd0,gpt2' # comment\n\necho \"a $b\" ${c}d\\ e",
+			expected: []grubStatement{
+				&grubCommand{words: []grubWord{{{text: "insmod"}}, {{text: "gzio"}}}},
+				&grubCommand{words: []grubWord{{{text: "set"}}, {{text: "root="}, {text: "hd0,gpt2", quoted: true}}}},
+				&grubCommand{words: []grubWord{
+					{{text: "echo"}},
+					{{text: "a ", quoted: true}, {text: "b", variable: true, quoted: true}},
+					{{text: "c", variable: true}, {text: "d"}, {text: " ", quoted: true}, {text: "e"}}}},
+			},
+		},
+		{
+			desc: "If",
+			src:  "if [ -s $prefix/grubenv ]; then\n  load_env\nelif true; then\n  false\nelse\n  set a=\"\"\nfi",
+			expected: []grubStatement{
+				&grubIf{
+					clauses: []*grubIfClause{
+						{
+							condition: []grubStatement{&grubCommand{words: []grubWord{
+								{{text: "["}}, {{text: "-s"}}, {{text: "prefix", variable: true}, {text: "/grubenv"}}, {{text: "]"}}}}},
+							body: []grubStatement{&grubCommand{words: []grubWord{{{text: "load_env"}}}}},
+						},
+						{
+							condition: []grubStatement{&grubCommand{words: []grubWord{{{text: "true"}}}}},
+							body:      []grubStatement{&grubCommand{words: []grubWord{{{text: "false"}}}}},
+						},
+					},
+					els: []grubStatement{&grubCommand{words: []grubWord{{{text: "set"}}, {{text: "a="}, {quoted: true}}}}},
+				},
+			},
+		},
+		{
+			desc: "Blocks",
+			src:  "function load_video {\n\tinsmod all_video\n}\nmenuentry 'Ubuntu' --id ubuntu {\n\tlinux /vmlinuz # }\n}",
+			expected: []grubStatement{
+				&grubFunction{name: "load_video", body: []grubStatement{
+					&grubCommand{words: []grubWord{{{text: "insmod"}}, {{text: "all_video"}}}}}},
+				&grubCommand{
+					words:    []grubWord{{{text: "menuentry"}}, {{text: "Ubuntu", quoted: true}}, {{text: "--id"}}, {{text: "ubuntu"}}},
+					hasBlock: true,
+					block:    "\n\tlinux /vmlinuz # }\n"},
+			},
+		},
+	} {
+		t.Run(data.desc, func(t *testing.T) {
+			stmts, err := parseGrubScript(data.src)
+			if err != nil {
+				t.Fatalf("parseGrubScript failed: %v", err)
+			}
+			if !reflect.DeepEqual(stmts, data.expected) {
+				t.Errorf("Unexpected statements: %#v", stmts)
+			}
+		})
+	}
+}
+
+func TestParseGrubScriptErrors(t *testing.T) {
+	for _, data := range []struct {
+		desc string
+		src  string
+		err  string
+	}{
+		{
+			desc: "UnterminatedQuote",
+			src:  "set a=1\necho 'foo",
+			err:  "line 2: unterminated single quoted string",
+		},
+		{
+			desc: "MissingFi",
+			src:  "if true; then\n  echo\n",
+			err:  "line 3: expected \"fi\"",
+		},
+		{
+			desc: "UnmatchedBrace",
+			src:  "echo\n}",
+			err:  "line 2: unexpected \"}\"",
+		},
+		{
+			desc: "Loop",
+			src:  "for i in 1 2; do echo $i; done",
+			err:  "line 1: unsupported keyword \"for\"",
+		},
+	} {
+		t.Run(data.desc, func(t *testing.T) {
+			if _, err := parseGrubScript(data.src); err == nil || err.Error() != data.err {
+				t.Errorf("Unexpected error: %v", err)
+			}
+		})
+	}
+}
@@ -0,0 +1,76 @@
+// Copyright 2019 Canonical Ltd.
+// Licensed under the LGPLv3 with static-linking exception.
+// See LICENCE file for details.
+
+package tcglog
+
+import (
+	"fmt"
+	"sync"
+)
+
+var (
+	// SystemdLoaderGuid is the GUID of the namespace for variables used by systemd-boot and systemd-stub to implement the boot
+	// loader interface.
+	SystemdLoaderGuid = MakeEFIGUID(0x4a67b082, 0x0a4c, 0x41cf, 0xb6c7, [...]uint8{0x44, 0x0b, 0x29, 0xbb, 0x8c, 0x4f})
+
+	// MicrosoftOwnerGuid is the GUID that Microsoft uses as the owner of its entries in the secure boot signature databases.
+	MicrosoftOwnerGuid = MakeEFIGUID(0x77fa9abd, 0x0359, 0x4d32, 0xbd60, [...]uint8{0x28, 0xf4, 0xe7, 0x8f, 0x78, 0x4b})
+)
+
+var (
+	efiGUIDNamesMu sync.RWMutex
+	efiGUIDNames   = make(map[EFIGUID]string)
+)
+
+func init() {
+	for guid, name := range map[EFIGUID]string{
+		EFIGlobalVariableGuid:        "EFI_GLOBAL_VARIABLE",
+		EFIImageSecurityDatabaseGuid: "EFI_IMAGE_SECURITY_DATABASE",
+		EFICertSha1Guid:              "EFI_CERT_SHA1",
+		EFICertSha256Guid:            "EFI_CERT_SHA256",
+		EFICertRSA2048Guid:           "EFI_CERT_RSA2048",
+		EFICertX509Guid:              "EFI_CERT_X509",
+		EFICertX509Sha256Guid:        "EFI_CERT_X509_SHA256",
+		ShimLockGuid:                 "SHIM_LOCK",
+		SystemdLoaderGuid:            "SYSTEMD_LOADER",
+		MicrosoftOwnerGuid:           "MICROSOFT",
+	} {
+		efiGUIDNames[guid] = name
+	}
+	for guid, name := range efiConfigurationTableNames {
+		efiGUIDNames[guid] = name
+	}
+}
+
+// RegisterEFIGUIDName registers a human readable name for the specified GUID, which is used when formatting event data that
+// contains it. Registering a name replaces any name previously registered for the same GUID, including the names of well known
+// GUIDs, and registering an empty name removes it.
+//
+// This is safe to call from multiple goroutines.
+func RegisterEFIGUIDName(guid EFIGUID, name string) {
+	efiGUIDNamesMu.Lock()
+	defer efiGUIDNamesMu.Unlock()
+
+	if name == "" {
+		delete(efiGUIDNames, guid)
+		return
+	}
+	efiGUIDNames[guid] = name
+}
+
+// Name returns the human readable name registered for this GUID, or an empty string if there isn't one. Well known GUIDs, such
+// as EFIGlobalVariableGuid and the GUIDs of standard configuration tables, are registered by default.
+func (guid EFIGUID) Name() string {
+	efiGUIDNamesMu.RLock()
+	defer efiGUIDNamesMu.RUnlock()
+	return efiGUIDNames[guid]
+}
+
+// stringWithName returns the string representation of this GUID, followed by its registered name if it has one.
+func (guid EFIGUID) stringWithName() string {
+	if name := guid.Name(); name != "" {
+		return fmt.Sprintf("%s (%s)", guid, name)
+	}
+	return guid.String()
+}
@@ -0,0 +1,53 @@
+// Copyright 2019 Canonical Ltd.
+// Licensed under the LGPLv3 with static-linking exception.
+// See LICENCE file for details.
+
+package tcglog
+
+import (
+	"testing"
+)
+
+func TestEFIGUIDName(t *testing.T) {
+	for _, data := range []struct {
+		guid     EFIGUID
+		expected string
+	}{
+		{guid: EFIGlobalVariableGuid, expected: "EFI_GLOBAL_VARIABLE"},
+		{guid: EFIImageSecurityDatabaseGuid, expected: "EFI_IMAGE_SECURITY_DATABASE"},
+		{guid: ShimLockGuid, expected: "SHIM_LOCK"},
+		{guid: SystemdLoaderGuid, expected: "SYSTEMD_LOADER"},
+		{guid: EFISMBIOS3TableGuid, expected: "SMBIOS3"},
+		{guid: MakeEFIGUID(0x01020304, 0x0506, 0x0708, 0x090a, [...]uint8{0x0b, 0x0c, 0x0d, 0x0e, 0x0f, 0x10})},
+	} {
+		if name := data.guid.Name(); name != data.expected {
+			t.Errorf("Unexpected name for %s: %s", data.guid, name)
+		}
+	}
+}
+
+func TestRegisterEFIGUIDName(t *testing.T) {
+	guid := MakeEFIGUID(0x01020304, 0x0506, 0x0708, 0x090a, [...]uint8{0x0b, 0x0c, 0x0d, 0x0e, 0x0f, 0x10})
+	RegisterEFIGUIDName(guid, "TEST_VENDOR")
+	defer RegisterEFIGUIDName(guid, "")
+
+	if guid.Name() != "TEST_VENDOR" {
+		t.Errorf("Unexpected name: %s", guid.Name())
+	}
+
+	v := &EFIVariableData{VariableName: guid, UnicodeName: "Foo"}
+	expected := "UEFI_VARIABLE_DATA{ VariableName: {01020304-0506-0708-090a-0b0c0d0e0f10} (TEST_VENDOR), UnicodeName: \"Foo\" }"
+	if v.String() != expected {
+		t.Errorf("Unexpected string: %s", v)
+	}
+
+	table := &EFIConfigurationTable{VendorGuid: guid, VendorTable: 0x1000}
+	if table.Name() != "TEST_VENDOR" {
+		t.Errorf("Unexpected table name: %s", table.Name())
+	}
+
+	RegisterEFIGUIDName(guid, "")
+	if guid.Name() != "" {
+		t.Errorf("Name should have been removed")
+	}
+}
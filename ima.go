@@ -0,0 +1,463 @@
+// Copyright 2019 Canonical Ltd.
+// Licensed under the LGPLv3 with static-linking exception.
+// See LICENCE file for details.
+
+package tcglog
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	"golang.org/x/xerrors"
+)
+
+const (
+	// IMAPCR is the PCR that IMA measures to by default.
+	IMAPCR PCRIndex = 10
+
+	imaTemplateNameLenMax = 255
+	imaEventNameLenMax    = 255
+
+	imaBootAggregateName = "boot_aggregate"
+)
+
+// Names of the IMA templates that are recognized by this package.
+const (
+	IMATemplate       = "ima"
+	IMANGTemplate     = "ima-ng"
+	IMASigTemplate    = "ima-sig"
+	IMABufTemplate    = "ima-buf"
+	IMAModSigTemplate = "ima-modsig"
+)
+
+// imaTemplateFields maps the names of the known IMA templates to their field IDs. See
+// https://www.kernel.org/doc/html/latest/security/IMA-templates.html
+var imaTemplateFields = map[string][]string{
+	IMATemplate:       {"d", "n"},
+	IMANGTemplate:     {"d-ng", "n-ng"},
+	IMASigTemplate:    {"d-ng", "n-ng", "sig"},
+	IMABufTemplate:    {"d-ng", "n-ng", "buf"},
+	IMAModSigTemplate: {"d-ng", "n-ng", "sig", "d-modsig", "modsig"},
+}
+
+// IMALogOptions allows the behaviour of ParseIMALog to be controlled.
+type IMALogOptions struct {
+	// ByteOrder specifies the byte order of the integer fields in the log. The kernel writes these in native byte order unless
+	// booted with ima_canonical_fmt. Defaults to little-endian if not set.
+	ByteOrder binary.ByteOrder
+}
+
+// IMADigest corresponds to a file or buffer digest recorded in an IMA template.
+type IMADigest struct {
+	algName   string
+	Algorithm AlgorithmId // The digest algorithm, or zero if it is not supported by this package
+	Digest    Digest
+}
+
+// AlgorithmName returns the name of the digest algorithm as it appears in the log.
+func (d *IMADigest) AlgorithmName() string {
+	return d.algName
+}
+
+func (d *IMADigest) String() string {
+	return fmt.Sprintf("%s:%x", d.algName, d.Digest)
+}
+
+// IMATemplateField corresponds to a single field of an IMA template.
+type IMATemplateField struct {
+	Id   string // The field identifier (eg, "d-ng")
+	Data []byte // The field data
+}
+
+// IMATemplateData is the decoded template data associated with an entry in an IMA log.
+type IMATemplateData struct {
+	data         []byte
+	order        binary.ByteOrder
+	Fields       []IMATemplateField
+	FileDigest   *IMADigest // The digest of the measured file or buffer ("d" or "d-ng" field)
+	FileName     string     // The name of the measured file or buffer ("n" or "n-ng" field)
+	Signature    []byte     // The file signature, if present ("sig" field)
+	ModSigDigest *IMADigest // The digest of the file without the appended signature, if present ("d-modsig" field)
+	ModSig       []byte     // The appended signature, if present ("modsig" field)
+	Buffer       []byte     // The measured buffer, if present ("buf" field)
+}
+
+func (d *IMATemplateData) String() string {
+	var builder bytes.Buffer
+	builder.WriteString("IMATemplateData{ ")
+	if d.FileDigest != nil {
+		fmt.Fprintf(&builder, "Digest: %s, ", d.FileDigest)
+	}
+	fmt.Fprintf(&builder, "Name: %q", d.FileName)
+	if len(d.Signature) > 0 {
+		fmt.Fprintf(&builder, ", Signature: %x", d.Signature)
+	}
+	if d.ModSigDigest != nil {
+		fmt.Fprintf(&builder, ", ModSigDigest: %s", d.ModSigDigest)
+	}
+	if len(d.ModSig) > 0 {
+		fmt.Fprintf(&builder, ", ModSig: %d bytes", len(d.ModSig))
+	}
+	if len(d.Buffer) > 0 {
+		fmt.Fprintf(&builder, ", Buffer: %x", d.Buffer)
+	}
+	builder.WriteString(" }")
+	return builder.String()
+}
+
+func (d *IMATemplateData) Bytes() []byte {
+	return d.data
+}
+
+func (d *IMADigest) encodeField(ng bool) []byte {
+	if !ng {
+		return d.Digest
+	}
+	return append([]byte(d.algName+":\x00"), d.Digest...)
+}
+
+// EncodeTo encodes this template data in to the form in which it appears in the IMA log. The fields listed in Fields are encoded
+// in order from the corresponding decoded fields (FileDigest, FileName etc), so that modifications to these are reflected in the
+// output. Integer fields are encoded with the byte order of the log from which this template data was decoded.
+func (d *IMATemplateData) EncodeTo(w io.Writer) error {
+	order := d.order
+	if order == nil {
+		order = binary.LittleEndian
+	}
+
+	var b bytes.Buffer
+	for i, f := range d.Fields {
+		var field []byte
+		switch f.Id {
+		case "d", "d-ng":
+			if d.FileDigest == nil {
+				return fmt.Errorf("cannot encode field %d (%s): no file digest", i, f.Id)
+			}
+			field = d.FileDigest.encodeField(f.Id == "d-ng")
+		case "n":
+			field = []byte(d.FileName)
+		case "n-ng":
+			field = []byte(d.FileName + "\x00")
+		case "sig":
+			field = d.Signature
+		case "d-modsig":
+			if d.ModSigDigest != nil {
+				field = d.ModSigDigest.encodeField(true)
+			}
+		case "modsig":
+			field = d.ModSig
+		case "buf":
+			field = d.Buffer
+		default:
+			field = f.Data
+		}
+
+		if f.Id != "d" {
+			binary.Write(&b, order, uint32(len(field)))
+		}
+		b.Write(field)
+	}
+
+	_, err := b.WriteTo(w)
+	return err
+}
+
+// IMAEvent corresponds to a single entry in an IMA runtime measurement log.
+type IMAEvent struct {
+	Index          uint      // Index of this entry amongst the entries in the log that were measured to the same PCR, starting from 0
+	PCRIndex       PCRIndex  // PCR index to which this event was measured
+	TemplateDigest Digest    // SHA-1 digest of the template data
+	TemplateName   string    // The name of the template used for this entry
+	Data           EventData // The template data recorded with this entry
+}
+
+// IsViolation indicates whether this entry records a measurement violation (eg, a file that was opened for write whilst also
+// being measured). Violations are recorded with a zero template digest, and the PCR is extended with a digest of all 0xff bytes
+// instead.
+func (e *IMAEvent) IsViolation() bool {
+	for _, b := range e.TemplateDigest {
+		if b != 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// IMALog corresponds to a parsed IMA runtime measurement log.
+type IMALog struct {
+	Events []*IMAEvent
+}
+
+func decodeIMADigestField(data []byte, ng bool) (*IMADigest, error) {
+	if !ng {
+		// The "d" field contains only a SHA-1 or MD5 digest, with no algorithm prefix.
+		switch len(data) {
+		case 20:
+			return &IMADigest{algName: "sha1", Algorithm: AlgorithmSha1, Digest: data}, nil
+		case 16:
+			return &IMADigest{algName: "md5", Digest: data}, nil
+		default:
+			return nil, fmt.Errorf("unexpected digest length (%d)", len(data))
+		}
+	}
+
+	// The "d-ng" field is of the form "<algorithm>:\0<digest>"
+	i := bytes.IndexByte(data, 0)
+	if i < 0 {
+		return nil, errors.New("no algorithm prefix")
+	}
+	name := string(data[:i])
+	if !strings.HasSuffix(name, ":") {
+		return nil, errors.New("invalid algorithm prefix")
+	}
+	name = strings.TrimSuffix(name, ":")
+
+	d := &IMADigest{algName: name, Digest: data[i+1:]}
+	d.Algorithm = parseIMAHashAlgorithm(name)
+	if d.Algorithm != 0 && d.Algorithm.Size() != len(d.Digest) {
+		return nil, fmt.Errorf("unexpected digest length for algorithm %s (%d)", name, len(d.Digest))
+	}
+	return d, nil
+}
+
+func parseIMAHashAlgorithm(name string) AlgorithmId {
+	switch name {
+	case "sha1":
+		return AlgorithmSha1
+	case "sha256":
+		return AlgorithmSha256
+	case "sha384":
+		return AlgorithmSha384
+	case "sha512":
+		return AlgorithmSha512
+	default:
+		return 0
+	}
+}
+
+func decodeIMATemplateFields(templateName string, data []byte, order binary.ByteOrder) (*IMATemplateData, error) {
+	ids, known := imaTemplateFields[templateName]
+	if !known {
+		return nil, nil
+	}
+
+	d := &IMATemplateData{data: data, order: order}
+	r := bytes.NewReader(data)
+
+	for i, id := range ids {
+		var field []byte
+		switch {
+		case templateName == IMATemplate && id == "d":
+			field = make([]byte, AlgorithmSha1.Size())
+			if _, err := io.ReadFull(r, field); err != nil {
+				return nil, xerrors.Errorf("cannot read field %d (%s): %w", i, id, err)
+			}
+		default:
+			var n uint32
+			if err := binary.Read(r, order, &n); err != nil {
+				return nil, xerrors.Errorf("cannot read length of field %d (%s): %w", i, id, err)
+			}
+			if int64(n) > int64(r.Len()) {
+				return nil, fmt.Errorf("field %d (%s) has an invalid length (%d)", i, id, n)
+			}
+			field = make([]byte, n)
+			if _, err := io.ReadFull(r, field); err != nil {
+				return nil, xerrors.Errorf("cannot read field %d (%s): %w", i, id, err)
+			}
+		}
+		d.Fields = append(d.Fields, IMATemplateField{Id: id, Data: field})
+
+		switch id {
+		case "d", "d-ng":
+			digest, err := decodeIMADigestField(field, id == "d-ng")
+			if err != nil {
+				return nil, xerrors.Errorf("cannot decode file digest: %w", err)
+			}
+			d.FileDigest = digest
+		case "n", "n-ng":
+			if len(field) > imaEventNameLenMax+1 {
+				return nil, fmt.Errorf("file name is too long (%d bytes)", len(field))
+			}
+			d.FileName = strings.TrimRight(string(field), "\x00")
+		case "sig":
+			d.Signature = field
+		case "d-modsig":
+			if len(field) == 0 {
+				break
+			}
+			digest, err := decodeIMADigestField(field, true)
+			if err != nil {
+				return nil, xerrors.Errorf("cannot decode modsig digest: %w", err)
+			}
+			d.ModSigDigest = digest
+		case "modsig":
+			d.ModSig = field
+		case "buf":
+			d.Buffer = field
+		}
+	}
+
+	if r.Len() > 0 {
+		return nil, fmt.Errorf("template data contains %d trailing bytes", r.Len())
+	}
+
+	return d, nil
+}
+
+// DecodeIMATemplateData decodes the supplied template data for an IMA log entry that uses the specified template, using the
+// supplied options. The options may be nil. This is useful for decoding entries that were obtained from a source other than a
+// binary IMA log, such as a Canonical Event Log.
+func DecodeIMATemplateData(templateName string, data []byte, options *IMALogOptions) EventData {
+	order := binary.ByteOrder(binary.LittleEndian)
+	if options != nil && options.ByteOrder != nil {
+		order = options.ByteOrder
+	}
+	return decodeIMATemplateData(templateName, data, order)
+}
+
+func decodeIMATemplateData(templateName string, data []byte, order binary.ByteOrder) EventData {
+	d, err := decodeIMATemplateFields(templateName, data, order)
+	switch {
+	case err != nil:
+		return &invalidEventData{data: data, err: xerrors.Errorf("cannot decode %s template data: %w", templateName, err)}
+	case d == nil:
+		return &opaqueEventData{data: data}
+	default:
+		return d
+	}
+}
+
+type imaParser struct {
+	r     io.Reader
+	order binary.ByteOrder
+}
+
+// https://www.kernel.org/doc/html/latest/security/IMA-templates.html
+// See also ima_measurements_show in security/integrity/ima/ima_fs.c in the Linux kernel source.
+func (p *imaParser) readNextEvent() (*IMAEvent, error) {
+	var pcrIndex PCRIndex
+	if err := binary.Read(p.r, p.order, &pcrIndex); err != nil {
+		if err == io.EOF {
+			return nil, err
+		}
+		return nil, xerrors.Errorf("cannot read PCR index: %w", err)
+	}
+
+	if !isPCRIndexInRange(pcrIndex) {
+		return nil, fmt.Errorf("log entry has an out-of-range PCR index (%d)", pcrIndex)
+	}
+
+	digest := make(Digest, AlgorithmSha1.Size())
+	if _, err := io.ReadFull(p.r, digest); err != nil {
+		return nil, xerrors.Errorf("cannot read template digest: %w", err)
+	}
+
+	var nameLen uint32
+	if err := binary.Read(p.r, p.order, &nameLen); err != nil {
+		return nil, xerrors.Errorf("cannot read template name length: %w", err)
+	}
+	if nameLen > imaTemplateNameLenMax {
+		return nil, fmt.Errorf("template name is too long (%d bytes)", nameLen)
+	}
+
+	name := make([]byte, nameLen)
+	if _, err := io.ReadFull(p.r, name); err != nil {
+		return nil, xerrors.Errorf("cannot read template name: %w", err)
+	}
+	templateName := string(name)
+
+	var data []byte
+	if templateName == IMATemplate {
+		// The "ima" template has no template data length field. It consists of a SHA-1 digest followed by a
+		// length-prefixed name.
+		var nameLen uint32
+		fixed := make([]byte, AlgorithmSha1.Size()+binary.Size(nameLen))
+		if _, err := io.ReadFull(p.r, fixed); err != nil {
+			return nil, xerrors.Errorf("cannot read template data: %w", err)
+		}
+		nameLen = p.order.Uint32(fixed[AlgorithmSha1.Size():])
+		if nameLen > imaEventNameLenMax {
+			return nil, fmt.Errorf("event name is too long (%d bytes)", nameLen)
+		}
+		data = make([]byte, len(fixed)+int(nameLen))
+		copy(data, fixed)
+		if _, err := io.ReadFull(p.r, data[len(fixed):]); err != nil {
+			return nil, xerrors.Errorf("cannot read template data: %w", err)
+		}
+	} else {
+		var dataLen uint32
+		if err := binary.Read(p.r, p.order, &dataLen); err != nil {
+			return nil, xerrors.Errorf("cannot read template data length: %w", err)
+		}
+		data = make([]byte, dataLen)
+		if _, err := io.ReadFull(p.r, data); err != nil {
+			return nil, xerrors.Errorf("cannot read template data: %w", err)
+		}
+	}
+
+	return &IMAEvent{
+		PCRIndex:       pcrIndex,
+		TemplateDigest: digest,
+		TemplateName:   templateName,
+		Data:           decodeIMATemplateData(templateName, data, p.order),
+	}, nil
+}
+
+// NewIMALog creates a new IMALog from the supplied events, which must be in the order in which they were measured and have their
+// Data fields populated (eg, by DecodeIMATemplateData). The Index field of each event is populated.
+func NewIMALog(events []*IMAEvent) *IMALog {
+	indexTracker := make(map[PCRIndex]uint)
+	for _, e := range events {
+		e.Index = indexTracker[e.PCRIndex]
+		indexTracker[e.PCRIndex]++
+	}
+	return &IMALog{Events: events}
+}
+
+// ParseIMALog parses an IMA runtime measurement log in the binary format (as exposed by the kernel at
+// /sys/kernel/security/ima/binary_runtime_measurements) read from r, using the supplied options. The options may be nil. If an
+// error occurs during parsing, this may return an incomplete list of events with the error.
+func ParseIMALog(r io.Reader, options *IMALogOptions) (*IMALog, error) {
+	order := binary.ByteOrder(binary.LittleEndian)
+	if options != nil && options.ByteOrder != nil {
+		order = options.ByteOrder
+	}
+
+	p := &imaParser{r: r, order: order}
+	log := &IMALog{}
+	indexTracker := make(map[PCRIndex]uint)
+
+	for {
+		event, err := p.readNextEvent()
+		switch {
+		case err == io.EOF:
+			return log, nil
+		case err != nil:
+			return log, err
+		default:
+			event.Index = indexTracker[event.PCRIndex]
+			indexTracker[event.PCRIndex]++
+			log.Events = append(log.Events, event)
+		}
+	}
+}
+
+// BootAggregate returns the digest recorded in the boot_aggregate entry of this log, which is normally the first entry. It returns
+// an error if there is no boot_aggregate entry or it could not be decoded.
+func (l *IMALog) BootAggregate() (*IMADigest, error) {
+	for _, e := range l.Events {
+		d, ok := e.Data.(*IMATemplateData)
+		if !ok || d.FileName != imaBootAggregateName {
+			continue
+		}
+		if d.FileDigest == nil {
+			return nil, errors.New("boot_aggregate entry has no digest")
+		}
+		return d.FileDigest, nil
+	}
+	return nil, errors.New("no boot_aggregate entry")
+}
@@ -0,0 +1,292 @@
+// Copyright 2019 Canonical Ltd.
+// Licensed under the LGPLv3 with static-linking exception.
+// See LICENCE file for details.
+
+package tcglog
+
+import (
+	"bytes"
+	"crypto/sha1"
+	"crypto/sha256"
+	"encoding/binary"
+	"testing"
+)
+
+func makeIMAField(data []byte) []byte {
+	var b bytes.Buffer
+	binary.Write(&b, binary.LittleEndian, uint32(len(data)))
+	b.Write(data)
+	return b.Bytes()
+}
+
+func makeIMAEntry(pcr PCRIndex, templateName string, templateData []byte) []byte {
+	var b bytes.Buffer
+	binary.Write(&b, binary.LittleEndian, uint32(pcr))
+	digest := sha1.Sum(templateData)
+	b.Write(digest[:])
+	binary.Write(&b, binary.LittleEndian, uint32(len(templateName)))
+	b.WriteString(templateName)
+	if templateName != IMATemplate {
+		binary.Write(&b, binary.LittleEndian, uint32(len(templateData)))
+	}
+	b.Write(templateData)
+	return b.Bytes()
+}
+
+func TestParseIMALog(t *testing.T) {
+	fileDigest := sha256.Sum256([]byte("foo"))
+	sha1FileDigest := sha1.Sum([]byte("bar"))
+
+	var ngData bytes.Buffer
+	ngData.Write(makeIMAField(append([]byte("sha256:\x00"), fileDigest[:]...)))
+	ngData.Write(makeIMAField([]byte("/usr/bin/foo\x00")))
+
+	var sigData bytes.Buffer
+	sigData.Write(makeIMAField(append([]byte("sha256:\x00"), fileDigest[:]...)))
+	sigData.Write(makeIMAField([]byte("/usr/bin/foo\x00")))
+	sigData.Write(makeIMAField([]byte{0x03, 0x02, 0x04, 0xaa, 0xbb}))
+
+	var imaData bytes.Buffer
+	imaData.Write(sha1FileDigest[:])
+	imaData.Write(makeIMAField([]byte("boot_aggregate")))
+
+	var logData bytes.Buffer
+	logData.Write(makeIMAEntry(10, IMATemplate, imaData.Bytes()))
+	logData.Write(makeIMAEntry(10, IMANGTemplate, ngData.Bytes()))
+	logData.Write(makeIMAEntry(10, IMASigTemplate, sigData.Bytes()))
+	logData.Write(makeIMAEntry(11, "foo", []byte{1, 2, 3}))
+
+	log, err := ParseIMALog(&logData, nil)
+	if err != nil {
+		t.Fatalf("ParseIMALog failed: %v", err)
+	}
+	if len(log.Events) != 4 {
+		t.Fatalf("Unexpected number of events (%d)", len(log.Events))
+	}
+
+	for i, data := range []struct {
+		templateName string
+		index        uint
+		algorithm    AlgorithmId
+		digest       []byte
+		name         string
+		sig          []byte
+	}{
+		{templateName: IMATemplate, index: 0, algorithm: AlgorithmSha1, digest: sha1FileDigest[:], name: "boot_aggregate"},
+		{templateName: IMANGTemplate, index: 1, algorithm: AlgorithmSha256, digest: fileDigest[:], name: "/usr/bin/foo"},
+		{templateName: IMASigTemplate, index: 2, algorithm: AlgorithmSha256, digest: fileDigest[:], name: "/usr/bin/foo",
+			sig: []byte{0x03, 0x02, 0x04, 0xaa, 0xbb}},
+	} {
+		e := log.Events[i]
+		if e.TemplateName != data.templateName {
+			t.Errorf("Unexpected template name for event %d: %s", i, e.TemplateName)
+		}
+		if e.Index != data.index {
+			t.Errorf("Unexpected index for event %d: %d", i, e.Index)
+		}
+		d, ok := e.Data.(*IMATemplateData)
+		if !ok {
+			t.Fatalf("Unexpected event data type for event %d: %v", i, e.Data)
+		}
+		if d.FileDigest.Algorithm != data.algorithm {
+			t.Errorf("Unexpected digest algorithm for event %d: %v", i, d.FileDigest.Algorithm)
+		}
+		if !bytes.Equal(d.FileDigest.Digest, data.digest) {
+			t.Errorf("Unexpected file digest for event %d: %x", i, d.FileDigest.Digest)
+		}
+		if d.FileName != data.name {
+			t.Errorf("Unexpected file name for event %d: %s", i, d.FileName)
+		}
+		if !bytes.Equal(d.Signature, data.sig) {
+			t.Errorf("Unexpected signature for event %d: %x", i, d.Signature)
+		}
+	}
+
+	if log.Events[3].PCRIndex != 11 || log.Events[3].Index != 0 {
+		t.Errorf("Unexpected PCR index or index for event 3")
+	}
+	if _, ok := log.Events[3].Data.(*opaqueEventData); !ok {
+		t.Errorf("Unexpected event data type for event 3")
+	}
+}
+
+func TestParseIMALogTruncated(t *testing.T) {
+	entry := makeIMAEntry(10, IMANGTemplate, makeIMAField([]byte("sha1:\x00")))
+	log, err := ParseIMALog(bytes.NewReader(append(entry, entry[:10]...)), nil)
+	if err == nil {
+		t.Fatalf("ParseIMALog should have failed")
+	}
+	if len(log.Events) != 1 {
+		t.Errorf("Unexpected number of events (%d)", len(log.Events))
+	}
+}
+
+func TestIMABootAggregate(t *testing.T) {
+	log := &Log{Spec: SpecEFI_2, Algorithms: AlgorithmIdList{AlgorithmSha1, AlgorithmSha256}}
+	for _, pcr := range []PCRIndex{0, 7, 8, 9, 10} {
+		log.Events = append(log.Events, &Event{
+			PCRIndex:  pcr,
+			EventType: EventTypeAction,
+			Digests: DigestMap{
+				AlgorithmSha1:   AlgorithmSha1.hash([]byte("foo")),
+				AlgorithmSha256: AlgorithmSha256.hash([]byte("foo"))}})
+	}
+
+	pcrValue := func(alg AlgorithmId, pcr PCRIndex) []byte {
+		if pcr == 0 || pcr == 7 || pcr == 8 || pcr == 9 {
+			return extendDigest(alg, make(Digest, alg.Size()), alg.hash([]byte("foo")))
+		}
+		return make([]byte, alg.Size())
+	}
+
+	expected := func(alg AlgorithmId, lastPCR PCRIndex) []byte {
+		var b bytes.Buffer
+		for i := PCRIndex(0); i <= lastPCR; i++ {
+			b.Write(pcrValue(alg, i))
+		}
+		return alg.hash(b.Bytes())
+	}
+
+	for _, data := range []struct {
+		desc     string
+		alg      AlgorithmId
+		legacy   bool
+		expected []byte
+	}{
+		{desc: "SHA-1", alg: AlgorithmSha1, expected: expected(AlgorithmSha1, 7)},
+		{desc: "SHA-256", alg: AlgorithmSha256, expected: expected(AlgorithmSha256, 9)},
+		{desc: "SHA-256/legacy", alg: AlgorithmSha256, legacy: true, expected: expected(AlgorithmSha256, 7)},
+	} {
+		t.Run(data.desc, func(t *testing.T) {
+			d, err := log.IMABootAggregate(data.alg, data.legacy)
+			if err != nil {
+				t.Fatalf("IMABootAggregate failed: %v", err)
+			}
+			if !bytes.Equal(d, data.expected) {
+				t.Errorf("Unexpected digest %x", d)
+			}
+		})
+	}
+
+	if _, err := log.IMABootAggregate(AlgorithmSha384, false); err == nil {
+		t.Errorf("IMABootAggregate should fail for an algorithm that isn't in the log")
+	}
+
+	var imaData bytes.Buffer
+	imaData.Write(makeIMAField(append([]byte("sha256:\x00"), expected(AlgorithmSha256, 9)...)))
+	imaData.Write(makeIMAField([]byte("boot_aggregate\x00")))
+	imaLog, err := ParseIMALog(bytes.NewReader(makeIMAEntry(10, IMANGTemplate, imaData.Bytes())), nil)
+	if err != nil {
+		t.Fatalf("ParseIMALog failed: %v", err)
+	}
+	if err := log.CheckIMABootAggregate(imaLog); err != nil {
+		t.Errorf("CheckIMABootAggregate failed: %v", err)
+	}
+}
+
+func TestIMALogVerifyPCR(t *testing.T) {
+	var logData bytes.Buffer
+	for _, name := range []string{"/usr/bin/foo\x00", "/usr/bin/bar\x00", "/usr/bin/baz\x00"} {
+		fileDigest := sha256.Sum256([]byte(name))
+		var data bytes.Buffer
+		data.Write(makeIMAField(append([]byte("sha256:\x00"), fileDigest[:]...)))
+		data.Write(makeIMAField([]byte(name)))
+		logData.Write(makeIMAEntry(10, IMANGTemplate, data.Bytes()))
+	}
+
+	log, err := ParseIMALog(bytes.NewReader(logData.Bytes()), nil)
+	if err != nil {
+		t.Fatalf("ParseIMALog failed: %v", err)
+	}
+
+	for _, alg := range []AlgorithmId{AlgorithmSha1, AlgorithmSha256} {
+		value, err := log.ReplayPCR(alg, IMAPCR, false)
+		if err != nil {
+			t.Fatalf("ReplayPCR failed: %v", err)
+		}
+		if err := log.VerifyPCR(alg, IMAPCR, value); err != nil {
+			t.Errorf("VerifyPCR failed for %v: %v", alg, err)
+		}
+
+		legacyValue, err := log.ReplayPCR(alg, IMAPCR, true)
+		if err != nil {
+			t.Fatalf("ReplayPCR failed: %v", err)
+		}
+		if err := log.VerifyPCR(alg, IMAPCR, legacyValue); err != nil {
+			t.Errorf("VerifyPCR failed for %v (legacy): %v", alg, err)
+		}
+	}
+
+	// Simulate a PCR value read before the last entry was measured.
+	prefix := &IMALog{Events: log.Events[:2]}
+	value, _ := prefix.ReplayPCR(AlgorithmSha256, IMAPCR, false)
+	err = log.VerifyPCR(AlgorithmSha256, IMAPCR, value)
+	e, ok := err.(*IMALogVerificationError)
+	if !ok {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if e.Event != log.Events[2] {
+		t.Errorf("Unexpected event in error: %v", e.Event)
+	}
+
+	// Simulate a tampered entry.
+	log.Events[1].TemplateDigest = AlgorithmSha1.hash([]byte("foo"))
+	err = log.VerifyPCR(AlgorithmSha256, IMAPCR, value)
+	e, ok = err.(*IMALogVerificationError)
+	if !ok {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if e.Event != log.Events[1] {
+		t.Errorf("Unexpected event in error: %v", e.Event)
+	}
+}
+
+func TestIMATemplateDataEncode(t *testing.T) {
+	fileDigest := sha256.Sum256([]byte("foo"))
+	sha1FileDigest := sha1.Sum([]byte("bar"))
+
+	var ngData bytes.Buffer
+	ngData.Write(makeIMAField(append([]byte("sha256:\x00"), fileDigest[:]...)))
+	ngData.Write(makeIMAField([]byte("/usr/bin/foo\x00")))
+	ngData.Write(makeIMAField([]byte{0x03, 0x02, 0x04, 0xaa, 0xbb}))
+
+	var imaData bytes.Buffer
+	imaData.Write(sha1FileDigest[:])
+	imaData.Write(makeIMAField([]byte("boot_aggregate")))
+
+	for _, data := range []struct {
+		desc         string
+		templateName string
+		data         []byte
+	}{
+		{desc: "ima", templateName: IMATemplate, data: imaData.Bytes()},
+		{desc: "ima-sig", templateName: IMASigTemplate, data: ngData.Bytes()},
+	} {
+		t.Run(data.desc, func(t *testing.T) {
+			d, ok := DecodeIMATemplateData(data.templateName, data.data, nil).(*IMATemplateData)
+			if !ok {
+				t.Fatalf("Unexpected event data type")
+			}
+			var buf bytes.Buffer
+			if err := d.EncodeTo(&buf); err != nil {
+				t.Fatalf("EncodeTo failed: %v", err)
+			}
+			if !bytes.Equal(buf.Bytes(), data.data) {
+				t.Errorf("Unexpected encoding")
+			}
+
+			d.FileName = "/usr/bin/bar"
+			buf.Reset()
+			if err := d.EncodeTo(&buf); err != nil {
+				t.Fatalf("EncodeTo failed: %v", err)
+			}
+			d2, ok := DecodeIMATemplateData(data.templateName, buf.Bytes(), nil).(*IMATemplateData)
+			if !ok {
+				t.Fatalf("Cannot decode modified template data")
+			}
+			if d2.FileName != "/usr/bin/bar" {
+				t.Errorf("Unexpected file name (%s)", d2.FileName)
+			}
+		})
+	}
+}
@@ -0,0 +1,74 @@
+// Copyright 2019 Canonical Ltd.
+// Licensed under the LGPLv3 with static-linking exception.
+// See LICENCE file for details.
+
+package tcglog
+
+import (
+	"bytes"
+	"fmt"
+)
+
+// IMABootAggregate computes the expected digest of the IMA boot_aggregate entry for the specified algorithm by replaying the
+// events in this log. IMA computes boot_aggregate by hashing the concatenation of the values of PCRs 0-7 from the selected bank.
+// Since Linux 5.8, the values of PCRs 8 and 9 are also included for banks other than SHA-1. Set legacy to compute the value
+// produced by older kernels, which only include PCRs 0-7 for every bank.
+//
+// See ima_calc_boot_aggregate in security/integrity/ima/ima_crypto.c in the Linux kernel source.
+func (l *Log) IMABootAggregate(alg AlgorithmId, legacy bool) (Digest, error) {
+	if !l.Algorithms.Contains(alg) {
+		return nil, fmt.Errorf("log does not contain digests for algorithm %v", alg)
+	}
+
+	lastPCR := PCRIndex(7)
+	if alg != AlgorithmSha1 && !legacy {
+		lastPCR = 9
+	}
+
+	h := alg.GetHash().New()
+	for pcr := PCRIndex(0); pcr <= lastPCR; pcr++ {
+		value, err := l.replayPCR(alg, pcr)
+		if err != nil {
+			return nil, err
+		}
+		h.Write(value)
+	}
+	return h.Sum(nil), nil
+}
+
+// IMABootAggregates computes the expected digest of the IMA boot_aggregate entry for each of the algorithms in this log. See
+// IMABootAggregate for more details.
+func (l *Log) IMABootAggregates(legacy bool) DigestMap {
+	out := make(DigestMap)
+	for _, alg := range l.Algorithms {
+		d, _ := l.IMABootAggregate(alg, legacy)
+		out[alg] = d
+	}
+	return out
+}
+
+// CheckIMABootAggregate checks that the boot_aggregate entry in the supplied IMA log is consistent with the events in this log.
+// It returns an error if the log doesn't contain digests for the algorithm used for the boot_aggregate entry, or if the
+// boot_aggregate digest does not match the expected value for either the current or legacy kernel behaviour.
+func (l *Log) CheckIMABootAggregate(imaLog *IMALog) error {
+	agg, err := imaLog.BootAggregate()
+	if err != nil {
+		return err
+	}
+
+	if agg.Algorithm == 0 {
+		return fmt.Errorf("unsupported boot_aggregate digest algorithm %s", agg.AlgorithmName())
+	}
+
+	for _, legacy := range []bool{false, true} {
+		expected, err := l.IMABootAggregate(agg.Algorithm, legacy)
+		if err != nil {
+			return err
+		}
+		if bytes.Equal(expected, agg.Digest) {
+			return nil
+		}
+	}
+
+	return fmt.Errorf("boot_aggregate digest %x is inconsistent with the log", agg.Digest)
+}
@@ -0,0 +1,183 @@
+// Copyright 2019 Canonical Ltd.
+// Licensed under the LGPLv3 with static-linking exception.
+// See LICENCE file for details.
+
+package tcglog
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+)
+
+// templateDigestInput returns the bytes that IMA hashes in order to compute the template digest for this entry.
+func (e *IMAEvent) templateDigestInput() ([]byte, error) {
+	if err, isErr := e.Data.(error); isErr {
+		return nil, err
+	}
+
+	if e.TemplateName != IMATemplate {
+		// For all templates other than "ima", the template digest is computed over the length-prefixed fields, which is the
+		// template data as it appears in the log.
+		return e.Data.Bytes(), nil
+	}
+
+	d, ok := e.Data.(*IMATemplateData)
+	if !ok {
+		return nil, errors.New("cannot determine template fields")
+	}
+
+	// For the "ima" template, the template digest is computed over the digest and the name padded to
+	// IMA_EVENT_NAME_LEN_MAX + 1 bytes, without any length fields.
+	var out bytes.Buffer
+	for _, f := range d.Fields {
+		switch f.Id {
+		case "n":
+			var name [imaEventNameLenMax + 1]byte
+			copy(name[:], f.Data)
+			out.Write(name[:])
+		default:
+			out.Write(f.Data)
+		}
+	}
+	return out.Bytes(), nil
+}
+
+// ExtendDigest returns the digest that IMA extends in to the specified PCR bank for this entry. For the SHA-1 bank, this is
+// the recorded template digest. For other banks, this is a digest of the template data computed with the bank's algorithm,
+// which matches the behaviour of Linux 5.10 and later. Set legacy to return the value used by older kernels, which extend
+// the SHA-1 template digest padded with zeros in to every bank.
+//
+// For entries that record a violation, the returned digest has all bits set.
+func (e *IMAEvent) ExtendDigest(alg AlgorithmId, legacy bool) (Digest, error) {
+	if !alg.supported() {
+		return nil, fmt.Errorf("unsupported algorithm %v", alg)
+	}
+
+	if e.IsViolation() {
+		return bytes.Repeat([]byte{0xff}, alg.Size()), nil
+	}
+
+	switch {
+	case alg == AlgorithmSha1:
+		return e.TemplateDigest, nil
+	case legacy:
+		out := make(Digest, alg.Size())
+		copy(out, e.TemplateDigest)
+		return out, nil
+	}
+
+	data, err := e.templateDigestInput()
+	if err != nil {
+		return nil, err
+	}
+	return alg.hash(data), nil
+}
+
+// IMALogVerificationError is returned from IMALog.VerifyPCR when the log is inconsistent with the supplied PCR value.
+type IMALogVerificationError struct {
+	// Event is the first entry that is inconsistent with the supplied PCR value. This will be nil if all of the entries are
+	// consistent with their template data, but the PCR value cannot be explained by the log.
+	Event *IMAEvent
+
+	msg string
+}
+
+func (e *IMALogVerificationError) Error() string {
+	if e.Event == nil {
+		return e.msg
+	}
+	return fmt.Sprintf("entry %d (%s): %s", e.Event.Index, e.Event.TemplateName, e.msg)
+}
+
+// ReplayPCR computes the value of the specified PCR for the specified algorithm by replaying the entries in this log. See
+// IMAEvent.ExtendDigest for a description of legacy.
+func (l *IMALog) ReplayPCR(alg AlgorithmId, pcr PCRIndex, legacy bool) (Digest, error) {
+	if !alg.supported() {
+		return nil, fmt.Errorf("unsupported algorithm %v", alg)
+	}
+
+	value := make(Digest, alg.Size())
+	for _, e := range l.Events {
+		if e.PCRIndex != pcr {
+			continue
+		}
+		digest, err := e.ExtendDigest(alg, legacy)
+		if err != nil {
+			return nil, fmt.Errorf("cannot compute digest for entry %d: %v", e.Index, err)
+		}
+		value = extendDigest(alg, value, digest)
+	}
+	return value, nil
+}
+
+// VerifyPCR verifies that the entries in this log for the specified PCR are consistent with the supplied PCR value for the
+// specified algorithm. The supplied value could be read from the TPM or obtained from another source such as a quote. Both the
+// current and legacy kernel behaviours are tried (see IMAEvent.ExtendDigest).
+//
+// If the log is inconsistent with the supplied value, an *IMALogVerificationError error will be returned which identifies the
+// first entry that is inconsistent. An entry is inconsistent if its recorded template digest doesn't match its template data.
+// If the supplied value corresponds to a replay of a prefix of the log (eg, because the log was read after the PCR value and
+// contains entries that were measured in between), the first entry that is not reflected in the PCR value is reported.
+func (l *IMALog) VerifyPCR(alg AlgorithmId, pcr PCRIndex, value Digest) error {
+	if !alg.supported() {
+		return fmt.Errorf("unsupported algorithm %v", alg)
+	}
+
+	var events []*IMAEvent
+	for _, e := range l.Events {
+		if e.PCRIndex != pcr {
+			continue
+		}
+		events = append(events, e)
+
+		if e.IsViolation() {
+			continue
+		}
+		data, err := e.templateDigestInput()
+		if err != nil {
+			return &IMALogVerificationError{Event: e, msg: fmt.Sprintf("cannot decode template data: %v", err)}
+		}
+		if !bytes.Equal(AlgorithmSha1.hash(data), e.TemplateDigest) {
+			return &IMALogVerificationError{Event: e, msg: "template digest is inconsistent with template data"}
+		}
+	}
+
+	if len(events) == 0 && bytes.Equal(value, make(Digest, alg.Size())) {
+		return nil
+	}
+
+	for _, legacy := range []bool{false, true} {
+		if legacy && alg == AlgorithmSha1 {
+			break
+		}
+
+		current := make(Digest, alg.Size())
+		var firstUnexplained *IMAEvent
+		if bytes.Equal(current, value) && len(events) > 0 {
+			firstUnexplained = events[0]
+		}
+
+		for i, e := range events {
+			digest, err := e.ExtendDigest(alg, legacy)
+			if err != nil {
+				return &IMALogVerificationError{Event: e, msg: err.Error()}
+			}
+			current = extendDigest(alg, current, digest)
+
+			switch {
+			case !bytes.Equal(current, value):
+			case i == len(events)-1:
+				return nil
+			case firstUnexplained == nil:
+				firstUnexplained = events[i+1]
+			}
+		}
+
+		if firstUnexplained != nil {
+			return &IMALogVerificationError{Event: firstUnexplained, msg: "entry is not reflected in the PCR value"}
+		}
+	}
+
+	return &IMALogVerificationError{msg: fmt.Sprintf("PCR %d value for algorithm %v is inconsistent with the log", pcr, alg)}
+}
@@ -0,0 +1,343 @@
+// Copyright 2019 Canonical Ltd.
+// Licensed under the LGPLv3 with static-linking exception.
+// See LICENCE file for details.
+
+// Package cbor implements the minimal subset of CBOR (RFC 7049) required by the encoders in this module: unsigned and negative
+// integers, byte strings, text strings, arrays, maps, tags and simple values. Indefinite length items and floating point
+// values are not supported.
+package cbor
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+
+	"golang.org/x/xerrors"
+)
+
+const (
+	majorTypeUint   = 0
+	majorTypeNegInt = 1
+	majorTypeBytes  = 2
+	majorTypeText   = 3
+	majorTypeArray  = 4
+	majorTypeMap    = 5
+	majorTypeTag    = 6
+	majorTypeSimple = 7
+
+	simpleFalse = 20
+	simpleTrue  = 21
+	simpleNull  = 22
+
+	// maxContainerLen limits the number of elements accepted in a single decoded array or map, to avoid unbounded allocations.
+	maxContainerLen = 1 << 20
+)
+
+// Encoder writes CBOR data items to an underlying writer. Arrays and maps are written by writing a header with the number of
+// elements followed by the elements themselves. Errors are sticky and are returned from Err.
+type Encoder struct {
+	w   io.Writer
+	err error
+}
+
+// NewEncoder returns a new Encoder that writes to w.
+func NewEncoder(w io.Writer) *Encoder {
+	return &Encoder{w: w}
+}
+
+// Err returns the first error that occurred whilst encoding.
+func (e *Encoder) Err() error {
+	return e.err
+}
+
+func (e *Encoder) write(b []byte) {
+	if e.err != nil {
+		return
+	}
+	_, e.err = e.w.Write(b)
+}
+
+func (e *Encoder) writeHeader(major uint8, arg uint64) {
+	var b [9]byte
+	b[0] = major << 5
+	switch {
+	case arg < 24:
+		b[0] |= uint8(arg)
+		e.write(b[:1])
+	case arg <= math.MaxUint8:
+		b[0] |= 24
+		b[1] = uint8(arg)
+		e.write(b[:2])
+	case arg <= math.MaxUint16:
+		b[0] |= 25
+		binary.BigEndian.PutUint16(b[1:], uint16(arg))
+		e.write(b[:3])
+	case arg <= math.MaxUint32:
+		b[0] |= 26
+		binary.BigEndian.PutUint32(b[1:], uint32(arg))
+		e.write(b[:5])
+	default:
+		b[0] |= 27
+		binary.BigEndian.PutUint64(b[1:], arg)
+		e.write(b[:9])
+	}
+}
+
+// WriteUint writes an unsigned integer.
+func (e *Encoder) WriteUint(v uint64) {
+	e.writeHeader(majorTypeUint, v)
+}
+
+// WriteInt writes a signed integer.
+func (e *Encoder) WriteInt(v int64) {
+	if v >= 0 {
+		e.writeHeader(majorTypeUint, uint64(v))
+		return
+	}
+	e.writeHeader(majorTypeNegInt, uint64(-1-v))
+}
+
+// WriteBytes writes a byte string.
+func (e *Encoder) WriteBytes(b []byte) {
+	e.writeHeader(majorTypeBytes, uint64(len(b)))
+	e.write(b)
+}
+
+// WriteString writes a text string.
+func (e *Encoder) WriteString(s string) {
+	e.writeHeader(majorTypeText, uint64(len(s)))
+	e.write([]byte(s))
+}
+
+// WriteArrayHeader writes the header of an array containing n elements.
+func (e *Encoder) WriteArrayHeader(n int) {
+	e.writeHeader(majorTypeArray, uint64(n))
+}
+
+// WriteMapHeader writes the header of a map containing n key-value pairs.
+func (e *Encoder) WriteMapHeader(n int) {
+	e.writeHeader(majorTypeMap, uint64(n))
+}
+
+// WriteTag writes a tag, which applies to the next data item.
+func (e *Encoder) WriteTag(tag uint64) {
+	e.writeHeader(majorTypeTag, tag)
+}
+
+// WriteBool writes a boolean value.
+func (e *Encoder) WriteBool(v bool) {
+	if v {
+		e.writeHeader(majorTypeSimple, simpleTrue)
+	} else {
+		e.writeHeader(majorTypeSimple, simpleFalse)
+	}
+}
+
+// WriteNull writes a null value.
+func (e *Encoder) WriteNull() {
+	e.writeHeader(majorTypeSimple, simpleNull)
+}
+
+// WriteRaw writes an already encoded data item.
+func (e *Encoder) WriteRaw(b []byte) {
+	e.write(b)
+}
+
+// MapEntry is a single key-value pair from a decoded map.
+type MapEntry struct {
+	Key   interface{}
+	Value interface{}
+}
+
+// Map is a decoded map. The order of entries is preserved.
+type Map []MapEntry
+
+// Get returns the value associated with the specified key. Integer keys should be supplied as uint64 or int64 depending on
+// their sign.
+func (m Map) Get(key interface{}) (interface{}, bool) {
+	for _, e := range m {
+		if e.Key == key {
+			return e.Value, true
+		}
+	}
+	return nil, false
+}
+
+// Tag is a decoded tagged data item.
+type Tag struct {
+	Number  uint64
+	Content interface{}
+}
+
+type decoder struct {
+	r     io.Reader
+	depth int
+}
+
+func (d *decoder) readHeader() (major uint8, arg uint64, err error) {
+	var b [8]byte
+	if _, err := io.ReadFull(d.r, b[:1]); err != nil {
+		return 0, 0, err
+	}
+	major = b[0] >> 5
+	info := b[0] & 0x1f
+
+	switch {
+	case info < 24:
+		return major, uint64(info), nil
+	case info == 24:
+		if _, err := io.ReadFull(d.r, b[:1]); err != nil {
+			return 0, 0, err
+		}
+		return major, uint64(b[0]), nil
+	case info == 25:
+		if _, err := io.ReadFull(d.r, b[:2]); err != nil {
+			return 0, 0, err
+		}
+		return major, uint64(binary.BigEndian.Uint16(b[:])), nil
+	case info == 26:
+		if _, err := io.ReadFull(d.r, b[:4]); err != nil {
+			return 0, 0, err
+		}
+		return major, uint64(binary.BigEndian.Uint32(b[:])), nil
+	case info == 27:
+		if _, err := io.ReadFull(d.r, b[:8]); err != nil {
+			return 0, 0, err
+		}
+		return major, binary.BigEndian.Uint64(b[:]), nil
+	default:
+		return 0, 0, fmt.Errorf("unsupported additional information value %d", info)
+	}
+}
+
+func (d *decoder) decode() (interface{}, error) {
+	if d.depth > 64 {
+		return nil, errors.New("nesting too deep")
+	}
+	d.depth++
+	defer func() { d.depth-- }()
+
+	major, arg, err := d.readHeader()
+	if err != nil {
+		if err == io.EOF && d.depth > 1 {
+			err = io.ErrUnexpectedEOF
+		}
+		return nil, err
+	}
+
+	switch major {
+	case majorTypeUint:
+		return arg, nil
+	case majorTypeNegInt:
+		if arg > math.MaxInt64 {
+			return nil, errors.New("negative integer overflow")
+		}
+		return -1 - int64(arg), nil
+	case majorTypeBytes, majorTypeText:
+		var b bytes.Buffer
+		if n, err := io.CopyN(&b, d.r, int64(arg)); err != nil {
+			if err == io.EOF && uint64(n) < arg {
+				err = io.ErrUnexpectedEOF
+			}
+			return nil, err
+		}
+		if major == majorTypeText {
+			return b.String(), nil
+		}
+		return b.Bytes(), nil
+	case majorTypeArray:
+		if arg > maxContainerLen {
+			return nil, errors.New("array too large")
+		}
+		var out []interface{}
+		for i := uint64(0); i < arg; i++ {
+			v, err := d.decode()
+			if err != nil {
+				return nil, xerrors.Errorf("cannot decode array element %d: %w", i, err)
+			}
+			out = append(out, v)
+		}
+		return out, nil
+	case majorTypeMap:
+		if arg > maxContainerLen {
+			return nil, errors.New("map too large")
+		}
+		var out Map
+		for i := uint64(0); i < arg; i++ {
+			k, err := d.decode()
+			if err != nil {
+				return nil, xerrors.Errorf("cannot decode map key %d: %w", i, err)
+			}
+			v, err := d.decode()
+			if err != nil {
+				return nil, xerrors.Errorf("cannot decode map value %d: %w", i, err)
+			}
+			out = append(out, MapEntry{Key: k, Value: v})
+		}
+		return out, nil
+	case majorTypeTag:
+		v, err := d.decode()
+		if err != nil {
+			return nil, xerrors.Errorf("cannot decode tag content: %w", err)
+		}
+		return &Tag{Number: arg, Content: v}, nil
+	default:
+		switch arg {
+		case simpleFalse:
+			return false, nil
+		case simpleTrue:
+			return true, nil
+		case simpleNull:
+			return nil, nil
+		default:
+			return nil, fmt.Errorf("unsupported simple value %d", arg)
+		}
+	}
+}
+
+// Decode decodes a single data item from r. Unsigned integers are returned as uint64, negative integers as int64, byte strings
+// as []byte, text strings as string, arrays as []interface{}, maps as Map, tags as *Tag, booleans as bool and null as nil.
+// It returns io.EOF if r is at the end of its input.
+func Decode(r io.Reader) (interface{}, error) {
+	d := &decoder{r: r}
+	return d.decode()
+}
+
+// WriteValue writes a value of one of the types returned from Decode.
+func (e *Encoder) WriteValue(v interface{}) {
+	switch v := v.(type) {
+	case uint64:
+		e.WriteUint(v)
+	case int64:
+		e.WriteInt(v)
+	case []byte:
+		e.WriteBytes(v)
+	case string:
+		e.WriteString(v)
+	case []interface{}:
+		e.WriteArrayHeader(len(v))
+		for _, x := range v {
+			e.WriteValue(x)
+		}
+	case Map:
+		e.WriteMapHeader(len(v))
+		for _, x := range v {
+			e.WriteValue(x.Key)
+			e.WriteValue(x.Value)
+		}
+	case *Tag:
+		e.WriteTag(v.Number)
+		e.WriteValue(v.Content)
+	case bool:
+		e.WriteBool(v)
+	case nil:
+		e.WriteNull()
+	default:
+		if e.err == nil {
+			e.err = fmt.Errorf("unsupported type %T", v)
+		}
+	}
+}
@@ -0,0 +1,58 @@
+// Copyright 2019 Canonical Ltd.
+// Licensed under the LGPLv3 with static-linking exception.
+// See LICENCE file for details.
+
+package cbor
+
+import (
+	"bytes"
+	"io"
+	"reflect"
+	"testing"
+
+	"golang.org/x/xerrors"
+)
+
+func TestEncodeDecode(t *testing.T) {
+	var b bytes.Buffer
+	e := NewEncoder(&b)
+	e.WriteMapHeader(3)
+	e.WriteUint(1)
+	e.WriteArrayHeader(3)
+	e.WriteUint(500)
+	e.WriteInt(-10)
+	e.WriteBytes([]byte{1, 2, 3})
+	e.WriteString("foo")
+	e.WriteTag(18)
+	e.WriteBool(true)
+	e.WriteInt(-1)
+	e.WriteNull()
+	if err := e.Err(); err != nil {
+		t.Fatalf("Encode failed: %v", err)
+	}
+
+	expected := []byte{0xa3, 0x01, 0x83, 0x19, 0x01, 0xf4, 0x29, 0x43, 0x01, 0x02, 0x03, 0x63, 0x66, 0x6f, 0x6f, 0xd2, 0xf5,
+		0x20, 0xf6}
+	if !bytes.Equal(b.Bytes(), expected) {
+		t.Errorf("Unexpected encoding: %x", b.Bytes())
+	}
+
+	v, err := Decode(&b)
+	if err != nil {
+		t.Fatalf("Decode failed: %v", err)
+	}
+	expectedValue := Map{
+		{Key: uint64(1), Value: []interface{}{uint64(500), int64(-10), []byte{1, 2, 3}}},
+		{Key: "foo", Value: &Tag{Number: 18, Content: true}},
+		{Key: int64(-1), Value: nil}}
+	if !reflect.DeepEqual(v, expectedValue) {
+		t.Errorf("Unexpected value: %#v", v)
+	}
+
+	if _, err := Decode(&b); err != io.EOF {
+		t.Errorf("Unexpected error: %v", err)
+	}
+	if _, err := Decode(bytes.NewReader(expected[:5])); !xerrors.Is(err, io.ErrUnexpectedEOF) {
+		t.Errorf("Unexpected error: %v", err)
+	}
+}
@@ -0,0 +1,133 @@
+// Copyright 2019 Canonical Ltd.
+// Licensed under the LGPLv3 with static-linking exception.
+// See LICENCE file for details.
+
+// Package logconv implements the conversions between logs and the event types of other event log packages, which are shared by
+// the goattestation and goeventlog packages.
+package logconv
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+
+	"github.com/canonical/tcglog-parser"
+
+	"golang.org/x/xerrors"
+)
+
+// Event corresponds to an event from go-attestation or go-eventlog, both of which represent events in the same way, with a
+// digest for a single algorithm.
+type Event struct {
+	Index  int // The PCR index
+	Type   uint32
+	Data   []byte
+	Digest []byte
+}
+
+// FromEvents converts the supplied events, which contain digests for the specified algorithm, in to events with decoded event
+// data. The supplied options, which may be nil, are used to decode the event data. Events without a digest are converted to events
+// without a digest for the specified algorithm.
+func FromEvents(events []Event, alg tcglog.AlgorithmId, options *tcglog.LogOptions) []*tcglog.Event {
+	var out []*tcglog.Event
+	indexTracker := make(map[tcglog.PCRIndex]uint)
+
+	for _, e := range events {
+		pcr := tcglog.PCRIndex(e.Index)
+		digests := make(tcglog.DigestMap)
+		if e.Digest != nil {
+			digests[alg] = e.Digest
+		}
+		out = append(out, &tcglog.Event{
+			Index:     indexTracker[pcr],
+			PCRIndex:  pcr,
+			EventType: tcglog.EventType(e.Type),
+			Digests:   digests,
+			Data:      tcglog.DecodeEventData(pcr, tcglog.EventType(e.Type), digests, e.Data, options)})
+		indexTracker[pcr]++
+	}
+
+	return out
+}
+
+// FromEventLog converts an event log with the specified algorithms in to a log, using the supplied function to obtain the
+// events with the digests for each algorithm. The supplied options, which may be nil, are used to decode the event data.
+//
+// go-attestation and go-eventlog don't return the Spec ID event of a crypto-agile log, and only return SHA-1 digests for a log
+// that isn't crypto-agile. If the events don't begin with a Spec ID event and algs contains anything other than SHA-1, the log
+// is assumed to be crypto-agile and a Spec ID event is recreated for the specified algorithms, so that the returned log has the
+// correct specification and algorithms. The other fields of the original Spec ID event, such as the vendor info, are not
+// recovered.
+func FromEventLog(algs tcglog.AlgorithmIdList, events func(alg tcglog.AlgorithmId) []Event, options *tcglog.LogOptions) (*tcglog.Log, error) {
+	if len(algs) == 0 {
+		return nil, errors.New("no algorithms")
+	}
+
+	var out []*tcglog.Event
+	for i, alg := range algs {
+		algEvents := events(alg)
+
+		if i == 0 {
+			out = FromEvents(algEvents, alg, options)
+			continue
+		}
+
+		if len(algEvents) != len(out) {
+			return nil, fmt.Errorf("inconsistent number of events for algorithm %v", alg)
+		}
+		for j, e := range algEvents {
+			if tcglog.PCRIndex(e.Index) != out[j].PCRIndex || tcglog.EventType(e.Type) != out[j].EventType {
+				return nil, fmt.Errorf("inconsistent event %d for algorithm %v", j, alg)
+			}
+			if e.Digest != nil {
+				out[j].Digests[alg] = e.Digest
+			}
+		}
+	}
+
+	if isCryptoAgile(algs, out) {
+		b, err := tcglog.NewLogBuilder(algs, options)
+		if err != nil {
+			return nil, xerrors.Errorf("cannot create Spec ID event: %w", err)
+		}
+		out = append(b.Log().Events[:1], out...)
+	}
+
+	return tcglog.NewLog(out), nil
+}
+
+// isCryptoAgile determines whether the supplied events with digests for the specified algorithms were obtained from a
+// crypto-agile log with the Spec ID event omitted.
+func isCryptoAgile(algs tcglog.AlgorithmIdList, events []*tcglog.Event) bool {
+	if len(events) > 0 {
+		if _, ok := events[0].Data.(*tcglog.SpecIdEvent); ok {
+			return false
+		}
+	}
+	return len(algs) > 1 || algs[0] != tcglog.AlgorithmSha1
+}
+
+// ToEvents converts the events in the supplied log in to events containing digests for the specified algorithm. The Spec ID event
+// of a crypto-agile log is omitted, as it is from the events returned by go-attestation and go-eventlog.
+func ToEvents(log *tcglog.Log, alg tcglog.AlgorithmId) ([]Event, error) {
+	if !log.Algorithms.Contains(alg) {
+		return nil, fmt.Errorf("log doesn't contain digests for algorithm %v", alg)
+	}
+
+	var out []Event
+	for i, e := range log.Events {
+		if _, isSpecId := e.Data.(*tcglog.SpecIdEvent); isSpecId && i == 0 && log.Spec == tcglog.SpecEFI_2 {
+			continue
+		}
+		var data bytes.Buffer
+		if err := e.Data.EncodeTo(&data); err != nil {
+			return nil, xerrors.Errorf("cannot encode data for event %d: %w", i, err)
+		}
+		out = append(out, Event{
+			Index:  int(e.PCRIndex),
+			Type:   uint32(e.EventType),
+			Data:   data.Bytes(),
+			Digest: e.Digests[alg]})
+	}
+	return out, nil
+}
@@ -0,0 +1,169 @@
+// Copyright 2019 Canonical Ltd.
+// Licensed under the LGPLv3 with static-linking exception.
+// See LICENCE file for details.
+
+package logconv
+
+import (
+	"bytes"
+	"crypto/sha1"
+	"crypto/sha256"
+	"testing"
+
+	"github.com/canonical/tcglog-parser"
+)
+
+func makeTestEvents(alg tcglog.AlgorithmId) []Event {
+	var out []Event
+	for _, data := range [][]byte{{0x31, 0x00}, []byte("Calling EFI Application from Boot Option"), {0, 0, 0, 0}} {
+		var digest []byte
+		switch alg {
+		case tcglog.AlgorithmSha1:
+			d := sha1.Sum(data)
+			digest = d[:]
+		default:
+			d := sha256.Sum256(data)
+			digest = d[:]
+		}
+		e := Event{Index: 4, Type: uint32(tcglog.EventTypeEFIAction), Data: data, Digest: digest}
+		switch data[0] {
+		case 0x31:
+			e.Index = 0
+			e.Type = uint32(tcglog.EventTypeSCRTMVersion)
+		case 0:
+			e.Type = uint32(tcglog.EventTypeSeparator)
+		}
+		out = append(out, e)
+	}
+	return out
+}
+
+func TestFromEventLog(t *testing.T) {
+	log, err := FromEventLog(tcglog.AlgorithmIdList{tcglog.AlgorithmSha1, tcglog.AlgorithmSha256}, makeTestEvents, nil)
+	if err != nil {
+		t.Fatalf("FromEventLog failed: %v", err)
+	}
+	if log.Spec != tcglog.SpecEFI_2 {
+		t.Errorf("Unexpected spec: %v", log.Spec)
+	}
+	if len(log.Algorithms) != 2 || log.Algorithms[0] != tcglog.AlgorithmSha1 || log.Algorithms[1] != tcglog.AlgorithmSha256 {
+		t.Errorf("Unexpected algorithms: %v", log.Algorithms)
+	}
+	if len(log.Events) != 4 {
+		t.Fatalf("Unexpected number of events (%d)", len(log.Events))
+	}
+	if _, ok := log.Events[0].Data.(*tcglog.SpecIdEvent); !ok {
+		t.Errorf("Unexpected data for event 0: %s", log.Events[0].Data)
+	}
+	for i, e := range log.Events {
+		if e.Index != []uint{0, 1, 0, 1}[i] {
+			t.Errorf("Unexpected index for event %d: %d", i, e.Index)
+		}
+		if len(e.Digests) != 2 {
+			t.Errorf("Unexpected digests for event %d", i)
+		}
+	}
+
+	events, err := ToEvents(log, tcglog.AlgorithmSha1)
+	if err != nil {
+		t.Fatalf("ToEvents failed: %v", err)
+	}
+	for i, e := range makeTestEvents(tcglog.AlgorithmSha1) {
+		if events[i].Index != e.Index || events[i].Type != e.Type || !bytes.Equal(events[i].Data, e.Data) ||
+			!bytes.Equal(events[i].Digest, e.Digest) {
+			t.Errorf("Unexpected event %d", i)
+		}
+	}
+
+	if _, err := ToEvents(log, tcglog.AlgorithmSha384); err == nil {
+		t.Errorf("ToEvents should fail for an algorithm that isn't in the log")
+	}
+}
+
+func TestFromEventLogLegacy(t *testing.T) {
+	log, err := FromEventLog(tcglog.AlgorithmIdList{tcglog.AlgorithmSha1}, makeTestEvents, nil)
+	if err != nil {
+		t.Fatalf("FromEventLog failed: %v", err)
+	}
+	if log.Spec != tcglog.SpecUnknown {
+		t.Errorf("Unexpected spec: %v", log.Spec)
+	}
+	if len(log.Algorithms) != 1 || log.Algorithms[0] != tcglog.AlgorithmSha1 {
+		t.Errorf("Unexpected algorithms: %v", log.Algorithms)
+	}
+	if len(log.Events) != 3 {
+		t.Errorf("Unexpected number of events (%d)", len(log.Events))
+	}
+}
+
+func TestFromEventLogMissingDigest(t *testing.T) {
+	log, err := FromEventLog(tcglog.AlgorithmIdList{tcglog.AlgorithmSha1, tcglog.AlgorithmSha256}, func(alg tcglog.AlgorithmId) []Event {
+		events := makeTestEvents(alg)
+		if alg == tcglog.AlgorithmSha256 {
+			events[1].Digest = nil
+		}
+		return events
+	}, nil)
+	if err != nil {
+		t.Fatalf("FromEventLog failed: %v", err)
+	}
+	if len(log.Algorithms) != 2 {
+		t.Errorf("Unexpected algorithms: %v", log.Algorithms)
+	}
+	if _, ok := log.Events[2].Digests[tcglog.AlgorithmSha256]; ok {
+		t.Errorf("Event 2 should not have a SHA-256 digest")
+	}
+	if len(log.Events[2].Digests) != 1 || len(log.Events[3].Digests) != 2 {
+		t.Errorf("Unexpected digests")
+	}
+
+	events := FromEvents([]Event{{Index: 4, Type: uint32(tcglog.EventTypeSeparator), Data: []byte{0, 0, 0, 0}}}, tcglog.AlgorithmSha256, nil)
+	if len(events[0].Digests) != 0 {
+		t.Errorf("Unexpected digests: %v", events[0].Digests)
+	}
+}
+
+func TestFromEventLogErrors(t *testing.T) {
+	for _, data := range []struct {
+		desc   string
+		algs   tcglog.AlgorithmIdList
+		events func(alg tcglog.AlgorithmId) []Event
+		err    string
+	}{
+		{
+			desc:   "NoAlgorithms",
+			events: makeTestEvents,
+			err:    "no algorithms",
+		},
+		{
+			desc: "InconsistentLength",
+			algs: tcglog.AlgorithmIdList{tcglog.AlgorithmSha1, tcglog.AlgorithmSha256},
+			events: func(alg tcglog.AlgorithmId) []Event {
+				events := makeTestEvents(alg)
+				if alg == tcglog.AlgorithmSha256 {
+					events = events[:2]
+				}
+				return events
+			},
+			err: "inconsistent number of events for algorithm SHA-256",
+		},
+		{
+			desc: "InconsistentEvent",
+			algs: tcglog.AlgorithmIdList{tcglog.AlgorithmSha1, tcglog.AlgorithmSha256},
+			events: func(alg tcglog.AlgorithmId) []Event {
+				events := makeTestEvents(alg)
+				if alg == tcglog.AlgorithmSha256 {
+					events[1].Index = 5
+				}
+				return events
+			},
+			err: "inconsistent event 1 for algorithm SHA-256",
+		},
+	} {
+		t.Run(data.desc, func(t *testing.T) {
+			if _, err := FromEventLog(data.algs, data.events, nil); err == nil || err.Error() != data.err {
+				t.Errorf("Unexpected error: %v", err)
+			}
+		})
+	}
+}
@@ -7,6 +7,7 @@ package internal
 import (
 	"bytes"
 	"fmt"
+	"strings"
 
 	"github.com/bsiegert/ranges"
 	"github.com/canonical/tcglog-parser"
@@ -45,6 +46,36 @@ func (l *PCRArgList) Contains(index tcglog.PCRIndex) bool {
 	return false
 }
 
+type BootStageArgList []tcglog.BootStage
+
+func (l *BootStageArgList) String() string {
+	var stages []string
+	for _, s := range *l {
+		stages = append(stages, s.String())
+	}
+	return strings.Join(stages, ",")
+}
+
+func (l *BootStageArgList) Set(value string) error {
+	for _, name := range strings.Split(value, ",") {
+		s, err := tcglog.ParseBootStage(name)
+		if err != nil {
+			return err
+		}
+		*l = append(*l, s)
+	}
+	return nil
+}
+
+func (l *BootStageArgList) Contains(stage tcglog.BootStage) bool {
+	for _, s := range *l {
+		if s == stage {
+			return true
+		}
+	}
+	return false
+}
+
 func ParseAlgorithm(alg string) (tcglog.AlgorithmId, error) {
 	switch alg {
 	case "sha1":
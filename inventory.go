@@ -0,0 +1,199 @@
+// Copyright 2019 Canonical Ltd.
+// Licensed under the LGPLv3 with static-linking exception.
+// See LICENCE file for details.
+
+package tcglog
+
+import (
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// FirmwareComponentKind describes the type of a firmware component in a FirmwareInventory.
+type FirmwareComponentKind int
+
+const (
+	// FirmwareComponentSCRTM is the S-CRTM or H-CRTM, measured by EV_S_CRTM_CONTENTS or EV_EFI_HCRTM_EVENT events.
+	FirmwareComponentSCRTM FirmwareComponentKind = iota
+
+	// FirmwareComponentPlatformFirmware is a region of the platform firmware, measured to PCR 0 by EV_POST_CODE, EV_POST_CODE2,
+	// EV_EFI_PLATFORM_FIRMWARE_BLOB or EV_EFI_PLATFORM_FIRMWARE_BLOB2 events.
+	FirmwareComponentPlatformFirmware
+
+	// FirmwareComponentMicrocode is a CPU microcode update, measured by EV_CPU_MICROCODE events.
+	FirmwareComponentMicrocode
+
+	// FirmwareComponentDriver is a UEFI driver that isn't loaded from a PCI device, such as a driver loaded from a firmware
+	// volume.
+	FirmwareComponentDriver
+
+	// FirmwareComponentOptionROM is a UEFI driver loaded from a PCI device, or a region of firmware measured to PCR 2.
+	FirmwareComponentOptionROM
+)
+
+func (k FirmwareComponentKind) String() string {
+	switch k {
+	case FirmwareComponentSCRTM:
+		return "S-CRTM"
+	case FirmwareComponentPlatformFirmware:
+		return "platform firmware"
+	case FirmwareComponentMicrocode:
+		return "microcode"
+	case FirmwareComponentDriver:
+		return "driver"
+	case FirmwareComponentOptionROM:
+		return "option ROM"
+	default:
+		return fmt.Sprintf("%%!(UNKNOWN_FIRMWARE_COMPONENT_KIND=%d)", int(k))
+	}
+}
+
+// FirmwareComponent describes a firmware component that is measured to the log. The component's identity for asset tracking
+// purposes is given by the digests of its event.
+type FirmwareComponent struct {
+	Kind        FirmwareComponentKind
+	Event       *Event
+	Description string // A description of the component from the event data, such as a blob description or image path
+}
+
+func (c *FirmwareComponent) String() string {
+	var b bytes.Buffer
+	b.WriteString(c.Kind.String())
+	if c.Description != "" {
+		fmt.Fprintf(&b, ": %s", EscapeString(c.Description))
+	}
+	fmt.Fprintf(&b, " (event %d in PCR %d)", c.Event.Index, c.Event.PCRIndex)
+	return b.String()
+}
+
+// FirmwareInventory is a summary of the firmware components that are evident in a log.
+type FirmwareInventory struct {
+	Vendor     string // The firmware vendor, as determined by Log.FirmwareVendor
+	Version    string // The version recorded in the EV_S_CRTM_VERSION event, as determined by Log.FirmwareVersion
+	Components []*FirmwareComponent
+}
+
+// Count returns the number of components of the specified kind.
+func (i *FirmwareInventory) Count(kind FirmwareComponentKind) (n int) {
+	for _, c := range i.Components {
+		if c.Kind == kind {
+			n++
+		}
+	}
+	return n
+}
+
+func (i *FirmwareInventory) String() string {
+	var b bytes.Buffer
+	vendor := i.Vendor
+	if vendor == "" {
+		vendor = "unknown"
+	}
+	version := i.Version
+	if version == "" {
+		version = "unknown"
+	}
+	fmt.Fprintf(&b, "Firmware vendor: %s\nS-CRTM version: %s", vendor, EscapeString(version))
+	for _, c := range i.Components {
+		fmt.Fprintf(&b, "\n%s", c)
+	}
+	return b.String()
+}
+
+type firmwareInventoryReportComponent struct {
+	Kind        string                 `json:"kind"`
+	Description string                 `json:"description,omitempty"`
+	Event       *validationReportEvent `json:"event"`
+	Digests     map[string]string      `json:"digests"`
+}
+
+type firmwareInventoryReport struct {
+	Vendor     string                              `json:"vendor,omitempty"`
+	Version    string                              `json:"version,omitempty"`
+	Components []*firmwareInventoryReportComponent `json:"components"`
+}
+
+// WriteJSON writes this inventory to w as a machine-readable JSON report. The digests of each component are keyed by the lower
+// case algorithm name without hyphens (eg, "sha256").
+func (i *FirmwareInventory) WriteJSON(w io.Writer) error {
+	report := &firmwareInventoryReport{Vendor: i.Vendor, Version: i.Version, Components: []*firmwareInventoryReportComponent{}}
+	for _, c := range i.Components {
+		rc := &firmwareInventoryReportComponent{
+			Kind:        c.Kind.String(),
+			Description: c.Description,
+			Event:       newValidationReportEvent(c.Event),
+			Digests:     make(map[string]string)}
+		for alg, digest := range c.Event.Digests {
+			rc.Digests[strings.ToLower(strings.Replace(alg.String(), "-", "", -1))] = hex.EncodeToString(digest)
+		}
+		report.Components = append(report.Components, rc)
+	}
+
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(report)
+}
+
+// firmwareComponentDescription returns a description of the firmware component measured by the supplied event.
+func firmwareComponentDescription(e *Event) string {
+	switch d := e.Data.(type) {
+	case *EFIPlatformFirmwareBlob2:
+		return d.BlobDescription
+	case *EFIPlatformFirmwareBlob:
+		return fmt.Sprintf("base 0x%x, length %d", d.BlobBase, d.BlobLength)
+	case *EFIImageLoadEvent:
+		if path := d.FilePath(); path != "" {
+			return path
+		}
+		return d.DevicePath
+	}
+	if str, _, ok := decodePrintableString(e.Data.Bytes()); ok {
+		return str
+	}
+	return ""
+}
+
+// FirmwareInventory summarizes the firmware components evident in this log - the S-CRTM, platform firmware regions, CPU
+// microcode updates, drivers and option ROMs - in the order in which they were measured, for asset tracking. Components are
+// only recorded for events measured before the first EFI application is loaded, and drivers loaded from a device path that
+// contains a PCI node are assumed to be option ROMs.
+func (l *Log) FirmwareInventory() *FirmwareInventory {
+	inventory := &FirmwareInventory{Vendor: l.FirmwareVendor(), Version: l.FirmwareVersion()}
+
+	for _, e := range l.Events {
+		if e.EventType == EventTypeEFIBootServicesApplication {
+			break
+		}
+
+		c := &FirmwareComponent{Event: e, Description: firmwareComponentDescription(e)}
+		switch e.EventType {
+		case EventTypeSCRTMContents, EventTypeEFIHCRTMEvent:
+			c.Kind = FirmwareComponentSCRTM
+		case EventTypePostCode, EventTypePostCode2, EventTypeEFIPlatformFirmwareBlob, EventTypeEFIPlatformFirmwareBlob2:
+			switch e.PCRIndex {
+			case 0:
+				c.Kind = FirmwareComponentPlatformFirmware
+			case 2:
+				c.Kind = FirmwareComponentOptionROM
+			default:
+				continue
+			}
+		case EventTypeCPUMicrocode:
+			c.Kind = FirmwareComponentMicrocode
+		case EventTypeEFIBootServicesDriver, EventTypeEFIRuntimeServicesDriver:
+			c.Kind = FirmwareComponentDriver
+			if d, ok := e.Data.(*EFIImageLoadEvent); ok && strings.Contains(d.DevicePath, "Pci(") {
+				c.Kind = FirmwareComponentOptionROM
+			}
+		default:
+			continue
+		}
+		inventory.Components = append(inventory.Components, c)
+	}
+
+	return inventory
+}
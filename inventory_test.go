@@ -0,0 +1,94 @@
+// Copyright 2019 Canonical Ltd.
+// Licensed under the LGPLv3 with static-linking exception.
+// See LICENCE file for details.
+
+package tcglog
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"testing"
+)
+
+func TestFirmwareInventory(t *testing.T) {
+	var blob bytes.Buffer
+	binary.Write(&blob, binary.LittleEndian, []uint64{0xff000000, 0x10000})
+
+	var optionROM bytes.Buffer
+	binary.Write(&optionROM, binary.LittleEndian, []uint64{0, 0, 0, 10})
+	optionROM.Write([]byte{0x01, 0x01, 0x06, 0x00, 0x00, 0x1c})
+	optionROM.Write(efiEndEntireDevicePath)
+
+	event := func(pcr PCRIndex, eventType EventType, data []byte) *Event {
+		return makeTestEvent(pcr, eventType, data, AlgorithmSha256)
+	}
+	events := []*Event{
+		event(0, EventTypeSCRTMVersion, makeTestUTF16String("1.02")),
+		event(0, EventTypeSCRTMContents, []byte("Boot Guard Measured S-CRTM")),
+		event(0, EventTypeEFIPlatformFirmwareBlob, blob.Bytes()),
+		event(0, EventTypePostCode, []byte("ACPI DATA")),
+		event(1, EventTypeCPUMicrocode, []byte{0xde, 0xad, 0xbe, 0xef}),
+		event(2, EventTypeEFIBootServicesDriver, optionROM.Bytes()),
+		event(2, EventTypeEFIBootServicesDriver, makeTestImageLoadEventData("\\Driver.efi")),
+		event(1, EventTypeEFIVariableBoot, []byte("BootOrder")),
+		event(4, EventTypeEFIBootServicesApplication, makeTestImageLoadEventData("\\EFI\\BOOT\\BOOTX64.EFI")),
+		event(2, EventTypeEFIBootServicesDriver, makeTestImageLoadEventData("\\Late.efi")),
+	}
+	inventory := NewLog(events).FirmwareInventory()
+
+	if inventory.Version != "1.02" {
+		t.Errorf("Unexpected version: %s", inventory.Version)
+	}
+	expected := []struct {
+		kind FirmwareComponentKind
+		desc string
+	}{
+		{FirmwareComponentSCRTM, "Boot Guard Measured S-CRTM"},
+		{FirmwareComponentPlatformFirmware, "base 0xff000000, length 65536"},
+		{FirmwareComponentPlatformFirmware, "ACPI DATA"},
+		{FirmwareComponentMicrocode, ""},
+		{FirmwareComponentOptionROM, "Pci(0x1c,0x0)"},
+		{FirmwareComponentDriver, "/Driver.efi"},
+	}
+	if len(inventory.Components) != len(expected) {
+		t.Fatalf("Unexpected number of components: %d", len(inventory.Components))
+	}
+	for i, c := range inventory.Components {
+		if c.Kind != expected[i].kind || c.Description != expected[i].desc || c.Event != events[i+1] {
+			t.Errorf("Unexpected component %d: %s", i, c)
+		}
+	}
+	if inventory.Count(FirmwareComponentPlatformFirmware) != 2 || inventory.Count(FirmwareComponentOptionROM) != 1 {
+		t.Errorf("Unexpected counts")
+	}
+
+	if inventory.String() != "Firmware vendor: unknown\nS-CRTM version: 1.02\n"+
+		"S-CRTM: Boot Guard Measured S-CRTM (event 1 in PCR 0)\n"+
+		"platform firmware: base 0xff000000, length 65536 (event 2 in PCR 0)\n"+
+		"platform firmware: ACPI DATA (event 3 in PCR 0)\n"+
+		"microcode (event 0 in PCR 1)\n"+
+		"option ROM: Pci(0x1c,0x0) (event 0 in PCR 2)\n"+
+		"driver: /Driver.efi (event 1 in PCR 2)" {
+		t.Errorf("Unexpected string:\n%s", inventory)
+	}
+
+	var b bytes.Buffer
+	if err := inventory.WriteJSON(&b); err != nil {
+		t.Fatalf("WriteJSON failed: %v", err)
+	}
+	var report struct {
+		Version    string
+		Components []struct {
+			Kind    string
+			Digests map[string]string
+		}
+	}
+	if err := json.Unmarshal(b.Bytes(), &report); err != nil {
+		t.Fatalf("Unmarshal failed: %v", err)
+	}
+	if report.Version != "1.02" || len(report.Components) != len(expected) || report.Components[3].Kind != "microcode" ||
+		report.Components[3].Digests["sha256"] != "5f78c33274e43fa9de5659265c1d917e25c03722dcb0b8d27db8d5feaa813953" {
+		t.Errorf("Unexpected report: %s", b.String())
+	}
+}
@@ -0,0 +1,226 @@
+// Copyright 2019 Canonical Ltd.
+// Licensed under the LGPLv3 with static-linking exception.
+// See LICENCE file for details.
+
+package tcglog
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io"
+	"path"
+	"strings"
+)
+
+type keylimeDigest struct {
+	Sha256 string `json:"sha256"`
+}
+
+type keylimeSignature struct {
+	SignatureOwner string `json:"SignatureOwner"`
+	SignatureData  string `json:"SignatureData"`
+}
+
+type keylimeSCRTMAndBIOS struct {
+	SCRTM            *keylimeDigest   `json:"scrtm,omitempty"`
+	PlatformFirmware []*keylimeDigest `json:"platform_firmware"`
+}
+
+type keylimeKernel struct {
+	ShimAuthcodeSha256   string `json:"shim_authcode_sha256,omitempty"`
+	GrubAuthcodeSha256   string `json:"grub_authcode_sha256,omitempty"`
+	KernelAuthcodeSha256 string `json:"kernel_authcode_sha256,omitempty"`
+	VmlinuzPlainSha256   string `json:"vmlinuz_plain_sha256,omitempty"`
+	InitrdPlainSha256    string `json:"initrd_plain_sha256,omitempty"`
+	KernelCmdline        string `json:"kernel_cmdline,omitempty"`
+}
+
+type keylimeMeasuredBootPolicy struct {
+	HasSecureBoot bool                   `json:"has_secureboot"`
+	SCRTMAndBIOS  []*keylimeSCRTMAndBIOS `json:"scrtm_and_bios"`
+	PK            []*keylimeSignature    `json:"pk"`
+	KEK           []*keylimeSignature    `json:"kek"`
+	DB            []*keylimeSignature    `json:"db"`
+	DBX           []*keylimeSignature    `json:"dbx"`
+	MokDig        []*keylimeDigest       `json:"mokdig"`
+	MokXDig       []*keylimeDigest       `json:"mokxdig"`
+	Kernels       []*keylimeKernel       `json:"kernels"`
+}
+
+func keylimeHex(d Digest) string {
+	return "0x" + hex.EncodeToString(d)
+}
+
+// WriteKeylimeMeasuredBootPolicy writes a Keylime measured boot reference state to w, in the format that is generated by
+// Keylime's create_mb_refstate script and consumed by its example measured boot policy. The log should be a known-good log,
+// which can be a log that has been predicted with Simulation. The reference state records the SHA-256 digests of the S-CRTM,
+// platform firmware, MokList and MokListX, the contents of PK, KEK, db and dbx, the Authenticode digests of shim, GRUB and the
+// kernel in the order in which they were loaded, the digests of the kernel and initrd measured by GRUB, and the kernel command
+// line. An error is returned if the log doesn't contain SHA-256 digests.
+func (l *Log) WriteKeylimeMeasuredBootPolicy(w io.Writer) error {
+	if !l.Algorithms.Contains(AlgorithmSha256) {
+		return errors.New("log doesn't contain SHA-256 digests")
+	}
+
+	policy := &keylimeMeasuredBootPolicy{
+		SCRTMAndBIOS: []*keylimeSCRTMAndBIOS{{PlatformFirmware: []*keylimeDigest{}}},
+		PK:           []*keylimeSignature{},
+		KEK:          []*keylimeSignature{},
+		DB:           []*keylimeSignature{},
+		DBX:          []*keylimeSignature{},
+		MokDig:       []*keylimeDigest{},
+		MokXDig:      []*keylimeDigest{},
+		Kernels:      []*keylimeKernel{}}
+	bios := policy.SCRTMAndBIOS[0]
+	kernel := new(keylimeKernel)
+
+	secureBoot := l.SecureBootPolicy()
+	policy.HasSecureBoot = secureBoot.Enabled()
+	for _, db := range secureBoot.Databases {
+		var sigs *[]*keylimeSignature
+		switch db.Name {
+		case "PK":
+			sigs = &policy.PK
+		case "KEK":
+			sigs = &policy.KEK
+		case "db":
+			sigs = &policy.DB
+		case "dbx":
+			sigs = &policy.DBX
+		default:
+			continue
+		}
+		for _, list := range db.Contents {
+			for _, s := range list.Signatures {
+				*sigs = append(*sigs, &keylimeSignature{
+					SignatureOwner: strings.Trim(s.SignatureOwner.String(), "{}"),
+					SignatureData:  "0x" + hex.EncodeToString(s.Data)})
+			}
+		}
+	}
+
+	var images []*Event
+	for _, e := range l.Events {
+		digest := &keylimeDigest{Sha256: keylimeHex(e.Digests[AlgorithmSha256])}
+
+		switch {
+		case e.PCRIndex == 0 && e.EventType == EventTypeSCRTMVersion:
+			bios.SCRTM = digest
+		case e.PCRIndex == 0 && (e.EventType == EventTypeEFIPlatformFirmwareBlob ||
+			e.EventType == EventTypeEFIPlatformFirmwareBlob2):
+			bios.PlatformFirmware = append(bios.PlatformFirmware, digest)
+		case e.PCRIndex == 4 && e.EventType == EventTypeEFIBootServicesApplication:
+			images = append(images, e)
+		}
+
+		switch d := e.Data.(type) {
+		case *ShimMokEventData:
+			switch d.Name {
+			case "MokList":
+				policy.MokDig = append(policy.MokDig, digest)
+			case "MokListX":
+				policy.MokXDig = append(policy.MokXDig, digest)
+			}
+		case *GrubStringEventData:
+			if d.Type == KernelCmdline {
+				kernel.KernelCmdline = d.Str
+			}
+		case *AsciiStringEventData:
+			if e.EventType != EventTypeIPL {
+				break
+			}
+			name := path.Base(d.String())
+			switch {
+			case strings.HasPrefix(name, "vmlinuz"):
+				kernel.VmlinuzPlainSha256 = digest.Sha256
+			case strings.HasPrefix(name, "initrd"):
+				kernel.InitrdPlainSha256 = digest.Sha256
+			}
+		}
+	}
+
+	for i, field := range []*string{&kernel.ShimAuthcodeSha256, &kernel.GrubAuthcodeSha256, &kernel.KernelAuthcodeSha256} {
+		if i < len(images) {
+			*field = keylimeHex(images[i].Digests[AlgorithmSha256])
+		}
+	}
+	if *kernel != (keylimeKernel{}) {
+		policy.Kernels = append(policy.Kernels, kernel)
+	}
+
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(policy)
+}
+
+type keylimeRuntimePolicyMeta struct {
+	Version   int `json:"version"`
+	Generator int `json:"generator"`
+}
+
+type keylimeRuntimePolicyIMA struct {
+	IgnoredKeyrings []string    `json:"ignored_keyrings"`
+	LogHashAlg      string      `json:"log_hash_alg"`
+	DMPolicy        interface{} `json:"dm_policy"`
+}
+
+type keylimeRuntimePolicy struct {
+	Meta             *keylimeRuntimePolicyMeta `json:"meta"`
+	Release          int                       `json:"release"`
+	Digests          map[string][]string       `json:"digests"`
+	Excludes         []string                  `json:"excludes"`
+	Keyrings         map[string][]string       `json:"keyrings"`
+	IMA              *keylimeRuntimePolicyIMA  `json:"ima"`
+	IMABuf           map[string][]string       `json:"ima-buf"`
+	VerificationKeys string                    `json:"verification-keys"`
+}
+
+func appendKeylimeDigest(m map[string][]string, name, digest string) {
+	for _, d := range m[name] {
+		if d == digest {
+			return
+		}
+	}
+	m[name] = append(m[name], digest)
+}
+
+// WriteKeylimeRuntimePolicy writes a Keylime runtime policy (version 1) to w that allows every file and buffer measured in this
+// log, which should be from a known-good machine. File digests are recorded against the file name. Buffers measured with the
+// ima-buf template are recorded as keyrings if their name begins with a '.' (such as ".ima" or ".builtin_trusted_keys"), or as
+// ima-buf entries otherwise. Violations are omitted.
+func (l *IMALog) WriteKeylimeRuntimePolicy(w io.Writer) error {
+	policy := &keylimeRuntimePolicy{
+		Meta:     &keylimeRuntimePolicyMeta{Version: 1},
+		Digests:  make(map[string][]string),
+		Excludes: []string{},
+		Keyrings: make(map[string][]string),
+		IMA: &keylimeRuntimePolicyIMA{
+			IgnoredKeyrings: []string{},
+			LogHashAlg:      "sha1"},
+		IMABuf: make(map[string][]string)}
+
+	for _, e := range l.Events {
+		if e.IsViolation() {
+			continue
+		}
+		d, ok := e.Data.(*IMATemplateData)
+		if !ok || d.FileDigest == nil {
+			continue
+		}
+		digest := hex.EncodeToString(d.FileDigest.Digest)
+
+		switch {
+		case e.TemplateName != IMABufTemplate:
+			appendKeylimeDigest(policy.Digests, d.FileName, digest)
+		case strings.HasPrefix(d.FileName, "."):
+			appendKeylimeDigest(policy.Keyrings, d.FileName, digest)
+		default:
+			appendKeylimeDigest(policy.IMABuf, d.FileName, digest)
+		}
+	}
+
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(policy)
+}
@@ -0,0 +1,129 @@
+// Copyright 2019 Canonical Ltd.
+// Licensed under the LGPLv3 with static-linking exception.
+// See LICENCE file for details.
+
+package tcglog
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"testing"
+)
+
+func TestWriteKeylimeMeasuredBootPolicy(t *testing.T) {
+	grubEvent := func(pcr PCRIndex, data string) *Event {
+		e := makeTestEvent(pcr, EventTypeIPL, []byte(data), AlgorithmSha256)
+		e.Data = DecodeEventData(pcr, EventTypeIPL, e.Digests, []byte(data), &LogOptions{EnableGrub: true})
+		return e
+	}
+
+	events := append([]*Event{
+		makeTestEvent(0, EventTypeSCRTMVersion, makeTestUTF16String("1.02"), AlgorithmSha256),
+		makeTestEvent(0, EventTypeEFIPlatformFirmwareBlob, make([]byte, 16), AlgorithmSha256)},
+		makeSecureBootPolicyTestLog(t, 1).Events...)
+	events = append(events,
+		makeTestEvent(14, EventTypeIPL, []byte("MokList\x00"), AlgorithmSha256),
+		makeTestEvent(14, EventTypeIPL, []byte("MokListX\x00"), AlgorithmSha256),
+		makeTestEvent(4, EventTypeEFIBootServicesApplication, makeTestImageLoadEventData("\\EFI\\ubuntu\\vmlinuz"),
+			AlgorithmSha256),
+		grubEvent(9, "/boot/vmlinuz-5.4.0-42-generic\x00"),
+		grubEvent(9, "/boot/initrd.img-5.4.0-42-generic\x00"),
+		grubEvent(8, "kernel_cmdline: /boot/vmlinuz-5.4.0-42-generic root=/dev/sda1 ro\x00"))
+	log := NewLog(events)
+
+	var b bytes.Buffer
+	if err := log.WriteKeylimeMeasuredBootPolicy(&b); err != nil {
+		t.Fatalf("WriteKeylimeMeasuredBootPolicy failed: %v", err)
+	}
+
+	var policy keylimeMeasuredBootPolicy
+	if err := json.Unmarshal(b.Bytes(), &policy); err != nil {
+		t.Fatalf("Unmarshal failed: %v", err)
+	}
+	digest := func(e *Event) string {
+		return "0x" + hex.EncodeToString(e.Digests[AlgorithmSha256])
+	}
+
+	if !policy.HasSecureBoot || len(policy.SCRTMAndBIOS) != 1 || policy.SCRTMAndBIOS[0].SCRTM.Sha256 != digest(events[0]) ||
+		len(policy.SCRTMAndBIOS[0].PlatformFirmware) != 1 || policy.SCRTMAndBIOS[0].PlatformFirmware[0].Sha256 != digest(events[1]) {
+		t.Errorf("Unexpected firmware policy: %s", b.String())
+	}
+	if len(policy.PK) != 1 || len(policy.KEK) != 1 || len(policy.DB) != 2 || len(policy.DBX) != 2 {
+		t.Errorf("Unexpected signature databases: %s", b.String())
+	}
+	h := sha256.Sum256([]byte("foo"))
+	if policy.DBX[0].SignatureOwner != "77fa9abd-0359-4d32-bd60-28f4e78f784b" || policy.DBX[0].SignatureData != "0x"+hex.EncodeToString(h[:]) {
+		t.Errorf("Unexpected dbx entry: %+v", policy.DBX[0])
+	}
+	if len(policy.MokDig) != 1 || policy.MokDig[0].Sha256 != digest(events[11]) || len(policy.MokXDig) != 1 ||
+		policy.MokXDig[0].Sha256 != digest(events[12]) {
+		t.Errorf("Unexpected MOK digests: %s", b.String())
+	}
+
+	expected := keylimeKernel{
+		ShimAuthcodeSha256:   digest(events[9]),
+		GrubAuthcodeSha256:   digest(events[10]),
+		KernelAuthcodeSha256: digest(events[13]),
+		VmlinuzPlainSha256:   digest(events[14]),
+		InitrdPlainSha256:    digest(events[15]),
+		KernelCmdline:        "/boot/vmlinuz-5.4.0-42-generic root=/dev/sda1 ro"}
+	if len(policy.Kernels) != 1 || *policy.Kernels[0] != expected {
+		t.Errorf("Unexpected kernels: %s", b.String())
+	}
+}
+
+func TestWriteKeylimeMeasuredBootPolicyNoSha256(t *testing.T) {
+	log := NewLog([]*Event{makeTestEvent(0, EventTypeSCRTMVersion, makeTestUTF16String("1.02"), AlgorithmSha1)})
+	if err := log.WriteKeylimeMeasuredBootPolicy(new(bytes.Buffer)); err == nil ||
+		err.Error() != "log doesn't contain SHA-256 digests" {
+		t.Errorf("Unexpected error: %v", err)
+	}
+}
+
+func TestWriteKeylimeRuntimePolicy(t *testing.T) {
+	ngData := func(data []byte, name string) []byte {
+		digest := sha256.Sum256(data)
+		var b bytes.Buffer
+		b.Write(makeIMAField(append([]byte("sha256:\x00"), digest[:]...)))
+		b.Write(makeIMAField([]byte(name + "\x00")))
+		return b.Bytes()
+	}
+	bufData := func(data []byte, name string) []byte {
+		return append(ngData(data, name), makeIMAField(data)...)
+	}
+
+	var logData bytes.Buffer
+	logData.Write(makeIMAEntry(10, IMANGTemplate, ngData([]byte("foo"), "/usr/bin/foo")))
+	logData.Write(makeIMAEntry(10, IMANGTemplate, ngData([]byte("foo"), "/usr/bin/foo")))
+	logData.Write(makeIMAEntry(10, IMANGTemplate, ngData([]byte("foo2"), "/usr/bin/foo")))
+	logData.Write(makeIMAEntry(10, IMABufTemplate, bufData([]byte("key"), ".ima")))
+	logData.Write(makeIMAEntry(10, IMABufTemplate, bufData([]byte("root=/dev/sda1"), "kexec-cmdline")))
+	imaLog, err := ParseIMALog(&logData, nil)
+	if err != nil {
+		t.Fatalf("ParseIMALog failed: %v", err)
+	}
+
+	var b bytes.Buffer
+	if err := imaLog.WriteKeylimeRuntimePolicy(&b); err != nil {
+		t.Fatalf("WriteKeylimeRuntimePolicy failed: %v", err)
+	}
+
+	var policy keylimeRuntimePolicy
+	if err := json.Unmarshal(b.Bytes(), &policy); err != nil {
+		t.Fatalf("Unmarshal failed: %v", err)
+	}
+	h := func(data string) string {
+		digest := sha256.Sum256([]byte(data))
+		return hex.EncodeToString(digest[:])
+	}
+	if policy.Meta.Version != 1 || policy.IMA.LogHashAlg != "sha1" || len(policy.Digests) != 1 ||
+		len(policy.Digests["/usr/bin/foo"]) != 2 || policy.Digests["/usr/bin/foo"][1] != h("foo2") {
+		t.Errorf("Unexpected policy: %s", b.String())
+	}
+	if len(policy.Keyrings[".ima"]) != 1 || policy.Keyrings[".ima"][0] != h("key") ||
+		len(policy.IMABuf["kexec-cmdline"]) != 1 || policy.IMABuf["kexec-cmdline"][0] != h("root=/dev/sda1") {
+		t.Errorf("Unexpected buffers: %s", b.String())
+	}
+}
@@ -0,0 +1,66 @@
+// Copyright 2019 Canonical Ltd.
+// Licensed under the LGPLv3 with static-linking exception.
+// See LICENCE file for details.
+
+package tcglog
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+)
+
+const (
+	// LinuxLoadOptionsEventTagID is the ID of the tagged event measured to PCR 12 by the Linux kernel's EFI stub for the
+	// LoadOptions (command line) of the kernel image.
+	LinuxLoadOptionsEventTagID TaggedEventID = 0x8f3b22ed
+
+	// LinuxInitrdEventTagID is the ID of the tagged event measured to PCR 9 by the Linux kernel's EFI stub for the initrd.
+	LinuxInitrdEventTagID TaggedEventID = 0x8f3b22ec
+)
+
+func init() {
+	RegisterTaggedEventType(LinuxLoadOptionsEventTagID, "LINUX_LOAD_OPTIONS", decodeLinuxEFIStubTaggedEvent)
+	RegisterTaggedEventType(LinuxInitrdEventTagID, "LINUX_INITRD", decodeLinuxEFIStubTaggedEvent)
+}
+
+// LinuxEFIStubEventData is the tagged event data associated with measurements of the kernel command line and initrd made by the
+// Linux kernel's EFI stub. The tagged event data is a description of the measured content (eg, "Linux initrd"), and the digest
+// is of the content itself.
+type LinuxEFIStubEventData struct {
+	data        []byte
+	Description string
+}
+
+func (e *LinuxEFIStubEventData) String() string {
+	return fmt.Sprintf("\"%s\"", EscapeString(e.Description))
+}
+
+func (e *LinuxEFIStubEventData) Bytes() []byte {
+	return e.data
+}
+
+// EncodeTo encodes this data in to the form in which it appears in the tagged event data.
+func (e *LinuxEFIStubEventData) EncodeTo(w io.Writer) error {
+	s := e.Description
+	if bytes.HasSuffix(e.data, []byte{0}) {
+		s += "\x00"
+	}
+	_, err := io.WriteString(w, s)
+	return err
+}
+
+// https://git.kernel.org/pub/scm/linux/kernel/git/torvalds/linux.git/tree/drivers/firmware/efi/libstub/efi-stub-helper.c
+func decodeLinuxEFIStubTaggedEvent(data []byte) (EventData, error) {
+	return &LinuxEFIStubEventData{data: data, Description: string(bytes.TrimSuffix(data, []byte{0}))}, nil
+}
+
+// isLinuxEFIStubEvent indicates whether the supplied event data corresponds to a tagged event measured by the Linux kernel's
+// EFI stub. The digests of these events are of the content described by the event, rather than of the event data.
+func isLinuxEFIStubEvent(data EventData) bool {
+	d, ok := data.(*TaggedEventData)
+	if !ok {
+		return false
+	}
+	return d.ID == LinuxLoadOptionsEventTagID || d.ID == LinuxInitrdEventTagID
+}
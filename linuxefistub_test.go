@@ -0,0 +1,68 @@
+// Copyright 2019 Canonical Ltd.
+// Licensed under the LGPLv3 with static-linking exception.
+// See LICENCE file for details.
+
+package tcglog
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestDecodeLinuxEFIStubTaggedEvents(t *testing.T) {
+	for _, data := range []struct {
+		desc        string
+		pcr         PCRIndex
+		id          TaggedEventID
+		data        []byte
+		description string
+		str         string
+	}{
+		{
+			desc:        "Initrd",
+			pcr:         9,
+			id:          0x8f3b22ec,
+			data:        []byte("Linux initrd"),
+			description: "Linux initrd",
+			str:         "TCG_PCClientTaggedEvent{ taggedEventID: LINUX_INITRD, taggedEventData: \"Linux initrd\" }",
+		},
+		{
+			desc:        "LoadOptions",
+			pcr:         12,
+			id:          0x8f3b22ed,
+			data:        []byte("LOADED_IMAGE::LoadOptions\x00"),
+			description: "LOADED_IMAGE::LoadOptions",
+			str:         "TCG_PCClientTaggedEvent{ taggedEventID: LINUX_LOAD_OPTIONS, taggedEventData: \"LOADED_IMAGE::LoadOptions\" }",
+		},
+	} {
+		t.Run(data.desc, func(t *testing.T) {
+			ev := makeTestTaggedEvent(data.id, data.data)
+			d, ok := DecodeEventData(data.pcr, EventTypeEventTag, DigestMap{}, ev, nil).(*TaggedEventData)
+			if !ok {
+				t.Fatalf("Unexpected event data type")
+			}
+			payload, ok := d.Payload.(*LinuxEFIStubEventData)
+			if !ok {
+				t.Fatalf("Unexpected payload type: %T", d.Payload)
+			}
+			if payload.Description != data.description {
+				t.Errorf("Unexpected description: %s", payload.Description)
+			}
+			if d.String() != data.str {
+				t.Errorf("Unexpected string: %s", d)
+			}
+
+			var buf bytes.Buffer
+			if err := payload.EncodeTo(&buf); err != nil {
+				t.Fatalf("EncodeTo failed: %v", err)
+			}
+			if !bytes.Equal(buf.Bytes(), data.data) {
+				t.Errorf("Unexpected encoding: %x", buf.Bytes())
+			}
+
+			if _, err := NewEvent(data.pcr, EventTypeEventTag, ev, AlgorithmIdList{AlgorithmSha256}); err == nil {
+				t.Errorf("NewEvent should fail for an event whose digest isn't of the event data")
+			}
+		})
+	}
+}
@@ -14,9 +14,35 @@ import (
 
 // LogOptions allows the behaviour of Log to be controlled.
 type LogOptions struct {
-	EnableGrub           bool     // Enable support for interpreting events recorded by GRUB
-	EnableSystemdEFIStub bool     // Enable support for interpreting events recorded by systemd's EFI linux loader stub
-	SystemdEFIStubPCR    PCRIndex // Specify the PCR that systemd's EFI linux loader stub measures to
+	EnableGrub           bool       // Enable support for interpreting events recorded by GRUB
+	GrubCmdPCR           PCRIndex   // Specify the PCR that GRUB measures commands and kernel commandlines to (8 if zero)
+	GrubFilePCR          PCRIndex   // Specify the PCR that GRUB measures files to (9 if zero)
+	EnableSystemdEFIStub bool       // Enable support for interpreting events recorded by systemd's EFI linux loader stub
+	SystemdEFIStubPCRs   []PCRIndex // Specify the PCRs that systemd's EFI linux loader stub measures to
+	EnableSystemdUKI     bool       // Enable support for interpreting events recorded by systemd-stub for unified kernel images to PCRs 11, 12 and 13
+}
+
+func (o *LogOptions) grubCmdPCR() PCRIndex {
+	if o.GrubCmdPCR == 0 {
+		return 8
+	}
+	return o.GrubCmdPCR
+}
+
+func (o *LogOptions) grubFilePCR() PCRIndex {
+	if o.GrubFilePCR == 0 {
+		return 9
+	}
+	return o.GrubFilePCR
+}
+
+func (o *LogOptions) isSystemdEFIStubPCR(pcr PCRIndex) bool {
+	for _, p := range o.SystemdEFIStubPCRs {
+		if p == pcr {
+			return true
+		}
+	}
+	return false
 }
 
 type parser interface {
@@ -193,15 +219,62 @@ func isSpecIdEvent(event *Event) (out bool) {
 
 // Log corresponds to a parsed event log.
 type Log struct {
-	Spec       Spec            // The specification to which this log conforms
-	Algorithms AlgorithmIdList // The digest algorithms that appear in the log
-	Events     []*Event        // The list of events in the log
+	Spec       Spec             // The specification to which this log conforms
+	Algorithms AlgorithmIdList  // The digest algorithms that appear in the log
+	Events     []*Event         // The list of events in the log
+	Quirks     []*DetectedQuirk // Known firmware quirks exhibited by the events in the log, populated by ParseLog
+}
+
+// NewLog creates a new Log from the supplied events, which must be in the order in which they were measured and have their Data
+// fields populated (eg, by DecodeEventData). The Index and Stage fields of each event are populated. If the first event is a Spec
+// ID event, the specification and digest algorithms are determined from it. Otherwise, the specification is SpecUnknown and the
+// digest algorithms are those that have digests in every event.
+func NewLog(events []*Event) *Log {
+	log := &Log{Spec: SpecUnknown, Events: events}
+
+	if len(events) > 0 {
+		if d, ok := events[0].Data.(*SpecIdEvent); ok {
+			log.Spec = d.Spec
+		}
+	}
+
+	if log.Spec == SpecEFI_2 {
+		for _, s := range events[0].Data.(*SpecIdEvent).DigestSizes {
+			if s.AlgorithmId.supported() {
+				log.Algorithms = append(log.Algorithms, s.AlgorithmId)
+			}
+		}
+		fixupSpecIdEvent(events[0], log.Algorithms)
+	} else {
+		for _, alg := range []AlgorithmId{AlgorithmSha1, AlgorithmSha256, AlgorithmSha384, AlgorithmSha512} {
+			found := true
+			for _, e := range events {
+				if _, ok := e.Digests[alg]; !ok {
+					found = false
+					break
+				}
+			}
+			if found {
+				log.Algorithms = append(log.Algorithms, alg)
+			}
+		}
+	}
+
+	indexTracker := make(map[PCRIndex]uint)
+	for _, e := range events {
+		e.Index = indexTracker[e.PCRIndex]
+		indexTracker[e.PCRIndex]++
+	}
+	log.ClassifyBootStages()
+
+	return log
 }
 
 // ParseLog parses an event log read from r, using the supplied options. If an error occurs during parsing, this may return an
 // incomplete list of events with the error.
 func ParseLog(r io.Reader, options *LogOptions) (*Log, error) {
-	var parser parser = &parser_1_2{r: r, options: options}
+	legacyParser := &parser_1_2{r: r, options: options}
+	var parser parser = legacyParser
 	event, err := parser.readNextEvent()
 	if err != nil {
 		return nil, err
@@ -252,8 +325,11 @@ func ParseLog(r io.Reader, options *LogOptions) (*Log, error) {
 		event, err := parser.readNextEvent()
 		switch {
 		case err == io.EOF:
+			log.ClassifyBootStages()
+			log.Quirks = log.DetectQuirks()
 			return log, nil
 		case err != nil:
+			log.ClassifyBootStages()
 			return log, err
 		default:
 			populateEventIndex(event)
@@ -0,0 +1,125 @@
+// Copyright 2019 Canonical Ltd.
+// Licensed under the LGPLv3 with static-linking exception.
+// See LICENCE file for details.
+
+package tcglog
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"sort"
+
+	"golang.org/x/xerrors"
+)
+
+// MarshalBinary implements encoding.BinaryMarshaler. The event is encoded as a TCG_PCR_EVENT2 structure as used in crypto-agile
+// logs, with a digest for each of the algorithms in Digests in ascending order of algorithm ID. The Index field is not encoded.
+func (e *Event) MarshalBinary() ([]byte, error) {
+	if len(e.Digests) == 0 {
+		return nil, fmt.Errorf("event has no digests")
+	}
+
+	var algs AlgorithmIdList
+	for alg := range e.Digests {
+		algs = append(algs, alg)
+	}
+	sort.Slice(algs, func(i, j int) bool { return algs[i] < algs[j] })
+
+	var b bytes.Buffer
+	if err := writeEvent_2(&b, e, algs); err != nil {
+		return nil, err
+	}
+	return b.Bytes(), nil
+}
+
+// MarshalBinaryLegacy encodes the event as a TCG_PCClientPCREventStruct structure as used in legacy SHA-1 only logs. The event
+// must have a SHA-1 digest. The Index field is not encoded.
+func (e *Event) MarshalBinaryLegacy() ([]byte, error) {
+	digest, ok := e.Digests[AlgorithmSha1]
+	if !ok {
+		return nil, fmt.Errorf("event has no SHA-1 digest")
+	}
+
+	data, err := encodeEventData(e.Data)
+	if err != nil {
+		return nil, xerrors.Errorf("cannot encode event data: %w", err)
+	}
+
+	var b bytes.Buffer
+	if err := writeEvent_1_2(&b, e.PCRIndex, e.EventType, digest, data); err != nil {
+		return nil, err
+	}
+	return b.Bytes(), nil
+}
+
+// UnmarshalBinary implements encoding.BinaryUnmarshaler. It decodes a TCG_PCR_EVENT2 structure as produced by MarshalBinary.
+// Every digest must be for an algorithm supported by this package. The event data is decoded with the default LogOptions and
+// the Index field is set to zero.
+func (e *Event) UnmarshalBinary(data []byte) error {
+	r := bytes.NewReader(data)
+
+	var header eventHeader_2
+	if err := binary.Read(r, binary.LittleEndian, &header); err != nil {
+		return xerrors.Errorf("cannot read event header: %w", err)
+	}
+	if !isPCRIndexInRange(header.PCRIndex) {
+		return fmt.Errorf("event has an out-of-range PCR index (%d)", header.PCRIndex)
+	}
+
+	digests := make(DigestMap)
+	for i := uint32(0); i < header.Count; i++ {
+		var alg AlgorithmId
+		if err := binary.Read(r, binary.LittleEndian, &alg); err != nil {
+			return xerrors.Errorf("cannot read algorithm ID: %w", err)
+		}
+		if !alg.supported() {
+			return fmt.Errorf("event contains a digest for an unsupported algorithm (%v)", alg)
+		}
+		if _, exists := digests[alg]; exists {
+			return fmt.Errorf("event contains more than one digest value for algorithm %v", alg)
+		}
+		digest := make(Digest, alg.Size())
+		if _, err := io.ReadFull(r, digest); err != nil {
+			return xerrors.Errorf("cannot read digest for algorithm %v: %w", alg, err)
+		}
+		digests[alg] = digest
+	}
+
+	var eventSize uint32
+	if err := binary.Read(r, binary.LittleEndian, &eventSize); err != nil {
+		return xerrors.Errorf("cannot read event size: %w", err)
+	}
+	if int64(eventSize) != int64(r.Len()) {
+		return fmt.Errorf("event size (%d) doesn't match the remaining data (%d bytes)", eventSize, r.Len())
+	}
+	eventData := make([]byte, eventSize)
+	io.ReadFull(r, eventData)
+
+	*e = Event{
+		PCRIndex:  header.PCRIndex,
+		EventType: header.EventType,
+		Digests:   digests,
+		Data:      DecodeEventData(header.PCRIndex, header.EventType, digests, eventData, nil)}
+	return nil
+}
+
+// UnmarshalBinaryLegacy decodes a TCG_PCClientPCREventStruct structure as produced by MarshalBinaryLegacy. The event data is
+// decoded with the default LogOptions and the Index field is set to zero.
+func (e *Event) UnmarshalBinaryLegacy(data []byte) error {
+	r := bytes.NewReader(data)
+	p := &parser_1_2{r: r, options: &LogOptions{}}
+	event, err := p.readNextEvent()
+	switch {
+	case err == io.EOF:
+		return io.ErrUnexpectedEOF
+	case err != nil:
+		return err
+	case r.Len() > 0:
+		return fmt.Errorf("event is followed by %d trailing bytes", r.Len())
+	}
+
+	*e = *event
+	return nil
+}
@@ -0,0 +1,112 @@
+// Copyright 2019 Canonical Ltd.
+// Licensed under the LGPLv3 with static-linking exception.
+// See LICENCE file for details.
+
+package tcglog
+
+import (
+	"bytes"
+	"encoding"
+	"reflect"
+	"testing"
+)
+
+var (
+	_ encoding.BinaryMarshaler   = (*Event)(nil)
+	_ encoding.BinaryUnmarshaler = (*Event)(nil)
+)
+
+func TestEventMarshalBinary(t *testing.T) {
+	for _, data := range []struct {
+		desc  string
+		event *Event
+	}{
+		{
+			desc:  "Action",
+			event: makeTestEvent(4, EventTypeEFIAction, []byte("Calling EFI Application from Boot Option"), AlgorithmSha1, AlgorithmSha256),
+		},
+		{
+			desc:  "Separator",
+			event: makeTestEvent(7, EventTypeSeparator, []byte{0, 0, 0, 0}, AlgorithmSha256, AlgorithmSha384, AlgorithmSha1),
+		},
+	} {
+		t.Run(data.desc, func(t *testing.T) {
+			b, err := data.event.MarshalBinary()
+			if err != nil {
+				t.Fatalf("MarshalBinary failed: %v", err)
+			}
+
+			var buf bytes.Buffer
+			if err := writeEvent_2(&buf, data.event, AlgorithmIdList{AlgorithmSha1, AlgorithmSha256, AlgorithmSha384}[:len(data.event.Digests)]); err != nil {
+				t.Fatalf("writeEvent_2 failed: %v", err)
+			}
+			if !bytes.Equal(b, buf.Bytes()) {
+				t.Errorf("Unexpected encoding")
+			}
+
+			var event Event
+			if err := event.UnmarshalBinary(b); err != nil {
+				t.Fatalf("UnmarshalBinary failed: %v", err)
+			}
+			if event.PCRIndex != data.event.PCRIndex || event.EventType != data.event.EventType {
+				t.Errorf("Unexpected event header")
+			}
+			if !reflect.DeepEqual(event.Digests, data.event.Digests) {
+				t.Errorf("Unexpected digests")
+			}
+			if !bytes.Equal(event.Data.Bytes(), data.event.Data.Bytes()) {
+				t.Errorf("Unexpected event data")
+			}
+		})
+	}
+}
+
+func TestEventMarshalBinaryLegacy(t *testing.T) {
+	in := makeTestEvent(4, EventTypeEFIAction, []byte("Calling EFI Application from Boot Option"), AlgorithmSha1, AlgorithmSha256)
+
+	b, err := in.MarshalBinaryLegacy()
+	if err != nil {
+		t.Fatalf("MarshalBinaryLegacy failed: %v", err)
+	}
+	if len(b) != 32+len(in.Data.Bytes()) {
+		t.Errorf("Unexpected encoding length (%d)", len(b))
+	}
+
+	var event Event
+	if err := event.UnmarshalBinaryLegacy(b); err != nil {
+		t.Fatalf("UnmarshalBinaryLegacy failed: %v", err)
+	}
+	if event.PCRIndex != in.PCRIndex || event.EventType != in.EventType {
+		t.Errorf("Unexpected event header")
+	}
+	if !reflect.DeepEqual(event.Digests, DigestMap{AlgorithmSha1: in.Digests[AlgorithmSha1]}) {
+		t.Errorf("Unexpected digests")
+	}
+	if !bytes.Equal(event.Data.Bytes(), in.Data.Bytes()) {
+		t.Errorf("Unexpected event data")
+	}
+}
+
+func TestEventUnmarshalBinaryInvalid(t *testing.T) {
+	in := makeTestEvent(4, EventTypeEFIAction, []byte("foo"), AlgorithmSha1)
+	b, err := in.MarshalBinary()
+	if err != nil {
+		t.Fatalf("MarshalBinary failed: %v", err)
+	}
+
+	var event Event
+	if err := event.UnmarshalBinary(b[:len(b)-1]); err == nil {
+		t.Errorf("UnmarshalBinary should have failed for truncated data")
+	}
+	if err := event.UnmarshalBinary(append(b, 0)); err == nil {
+		t.Errorf("UnmarshalBinary should have failed for trailing data")
+	}
+
+	b, err = in.MarshalBinaryLegacy()
+	if err != nil {
+		t.Fatalf("MarshalBinaryLegacy failed: %v", err)
+	}
+	if err := event.UnmarshalBinaryLegacy(append(b, 0)); err == nil {
+		t.Errorf("UnmarshalBinaryLegacy should have failed for trailing data")
+	}
+}
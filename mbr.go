@@ -0,0 +1,142 @@
+// Copyright 2019 Canonical Ltd.
+// Licensed under the LGPLv3 with static-linking exception.
+// See LICENCE file for details.
+
+package tcglog
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+)
+
+const (
+	mbrSize              = 512
+	mbrBootstrapCodeSize = 440
+	mbrSignature         = 0xaa55
+)
+
+// MBRPartitionEntry corresponds to an entry in a legacy MBR partition table.
+type MBRPartitionEntry struct {
+	BootIndicator uint8    // 0x80 if this is the active partition
+	StartingCHS   [3]uint8 // The CHS address of the first sector of the partition
+	OSType        uint8    // The type of partition
+	EndingCHS     [3]uint8 // The CHS address of the last sector of the partition
+	StartingLBA   uint32   // The LBA of the first sector of the partition
+	SizeInLBA     uint32   // The size of the partition in sectors
+}
+
+func (p *MBRPartitionEntry) String() string {
+	return fmt.Sprintf("{ BootIndicator: 0x%02x, OSType: 0x%02x, StartingLBA: %d, SizeInLBA: %d }", p.BootIndicator, p.OSType,
+		p.StartingLBA, p.SizeInLBA)
+}
+
+func writeMBRPartitionEntries(builder io.Writer, entries []MBRPartitionEntry) {
+	io.WriteString(builder, "[")
+	for i := range entries {
+		if i > 0 {
+			io.WriteString(builder, ", ")
+		}
+		io.WriteString(builder, entries[i].String())
+	}
+	io.WriteString(builder, "]")
+}
+
+// MBRData is the event data associated with an EV_IPL or EV_IPL_PARTITION_DATA event in a legacy BIOS log that contains the
+// entire master boot record.
+type MBRData struct {
+	data          []byte
+	reserved      uint16
+	BootstrapCode []byte // The 440-byte bootstrap code area
+	DiskSignature uint32 // The optional disk signature
+	Partitions    [4]MBRPartitionEntry
+}
+
+func (e *MBRData) String() string {
+	var builder bytes.Buffer
+	fmt.Fprintf(&builder, "MBR{ DiskSignature: 0x%08x, Partitions: ", e.DiskSignature)
+	writeMBRPartitionEntries(&builder, e.Partitions[:])
+	builder.WriteString(" }")
+	return builder.String()
+}
+
+func (e *MBRData) Bytes() []byte {
+	return e.data
+}
+
+// EncodeTo encodes this event data in to the form in which it appears in the event log.
+func (e *MBRData) EncodeTo(w io.Writer) error {
+	if len(e.BootstrapCode) != mbrBootstrapCodeSize {
+		return fmt.Errorf("invalid bootstrap code size (%d)", len(e.BootstrapCode))
+	}
+	var b bytes.Buffer
+	b.Write(e.BootstrapCode)
+	binary.Write(&b, binary.LittleEndian, e.DiskSignature)
+	binary.Write(&b, binary.LittleEndian, e.reserved)
+	binary.Write(&b, binary.LittleEndian, e.Partitions)
+	binary.Write(&b, binary.LittleEndian, uint16(mbrSignature))
+	_, err := b.WriteTo(w)
+	return err
+}
+
+func decodeMBR(data []byte) (*MBRData, error) {
+	if len(data) != mbrSize {
+		return nil, fmt.Errorf("invalid size (%d)", len(data))
+	}
+	if binary.LittleEndian.Uint16(data[510:]) != mbrSignature {
+		return nil, errors.New("invalid signature")
+	}
+
+	d := &MBRData{
+		data:          data,
+		BootstrapCode: data[:mbrBootstrapCodeSize],
+		DiskSignature: binary.LittleEndian.Uint32(data[440:]),
+		reserved:      binary.LittleEndian.Uint16(data[444:])}
+	binary.Read(bytes.NewReader(data[446:510]), binary.LittleEndian, &d.Partitions)
+	return d, nil
+}
+
+// IPLPartitionData is the event data associated with an EV_IPL_PARTITION_DATA event in a legacy BIOS log that contains only the
+// partition table entries.
+type IPLPartitionData struct {
+	data       []byte
+	Partitions []MBRPartitionEntry
+}
+
+func (e *IPLPartitionData) String() string {
+	var builder bytes.Buffer
+	builder.WriteString("IPLPartitionData{ Partitions: ")
+	writeMBRPartitionEntries(&builder, e.Partitions)
+	builder.WriteString(" }")
+	return builder.String()
+}
+
+func (e *IPLPartitionData) Bytes() []byte {
+	return e.data
+}
+
+// EncodeTo encodes this event data in to the form in which it appears in the event log.
+func (e *IPLPartitionData) EncodeTo(w io.Writer) error {
+	return binary.Write(w, binary.LittleEndian, e.Partitions)
+}
+
+// https://trustedcomputinggroup.org/wp-content/uploads/TCG_PCClientImplementation_1-21_1_00.pdf
+//  (section 3.3.2.3 "PCR[4] - IPL Code", section 3.3.2.4 "PCR[5] - IPL Configuration and Data")
+func decodeEventDataIPL(eventType EventType, data []byte) EventData {
+	// The IPL events in legacy BIOS logs contain either a string describing the boot loader or the IPL code and data itself.
+	if isPrintableASCII(data) {
+		return &AsciiStringEventData{data: data}
+	}
+	if d, err := decodeMBR(data); err == nil {
+		return d
+	}
+	if eventType != EventTypeIPLPartitionData || len(data) == 0 || len(data)%binary.Size(MBRPartitionEntry{}) != 0 {
+		return nil
+	}
+
+	d := &IPLPartitionData{data: data, Partitions: make([]MBRPartitionEntry, len(data)/binary.Size(MBRPartitionEntry{}))}
+	binary.Read(bytes.NewReader(data), binary.LittleEndian, d.Partitions)
+	return d
+}
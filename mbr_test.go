@@ -0,0 +1,91 @@
+// Copyright 2019 Canonical Ltd.
+// Licensed under the LGPLv3 with static-linking exception.
+// See LICENCE file for details.
+
+package tcglog
+
+import (
+	"bytes"
+	"encoding/binary"
+	"testing"
+)
+
+func makeTestMBR(diskSig uint32, partitions ...MBRPartitionEntry) []byte {
+	data := make([]byte, mbrSize)
+	for i := 0; i < mbrBootstrapCodeSize; i++ {
+		data[i] = byte(i)
+	}
+	binary.LittleEndian.PutUint32(data[440:], diskSig)
+	var b bytes.Buffer
+	binary.Write(&b, binary.LittleEndian, partitions)
+	copy(data[446:], b.Bytes())
+	binary.LittleEndian.PutUint16(data[510:], mbrSignature)
+	return data
+}
+
+func TestDecodeEventDataIPLMBR(t *testing.T) {
+	part := MBRPartitionEntry{BootIndicator: 0x80, OSType: 0x83, StartingLBA: 2048, SizeInLBA: 1024000}
+	data := makeTestMBR(0x12345678, part)
+
+	for _, eventType := range []EventType{EventTypeIPL, EventTypeIPLPartitionData} {
+		t.Run(eventType.String(), func(t *testing.T) {
+			d, ok := DecodeEventData(4, eventType, DigestMap{}, data, nil).(*MBRData)
+			if !ok {
+				t.Fatalf("Unexpected event data type")
+			}
+			if d.DiskSignature != 0x12345678 || d.Partitions[0] != part || len(d.BootstrapCode) != mbrBootstrapCodeSize {
+				t.Errorf("Unexpected MBR: %s", d)
+			}
+
+			var buf bytes.Buffer
+			if err := d.EncodeTo(&buf); err != nil {
+				t.Fatalf("EncodeTo failed: %v", err)
+			}
+			if !bytes.Equal(buf.Bytes(), data) {
+				t.Errorf("Unexpected encoding")
+			}
+		})
+	}
+}
+
+func TestDecodeEventDataIPLPartitionData(t *testing.T) {
+	parts := []MBRPartitionEntry{
+		{BootIndicator: 0x80, OSType: 0x07, StartingLBA: 2048, SizeInLBA: 204800},
+		{OSType: 0x83, StartingLBA: 206848, SizeInLBA: 1024000}}
+	var data bytes.Buffer
+	binary.Write(&data, binary.LittleEndian, parts)
+
+	d, ok := DecodeEventData(5, EventTypeIPLPartitionData, DigestMap{}, data.Bytes(), nil).(*IPLPartitionData)
+	if !ok {
+		t.Fatalf("Unexpected event data type")
+	}
+	if len(d.Partitions) != 2 || d.Partitions[0] != parts[0] || d.Partitions[1] != parts[1] {
+		t.Errorf("Unexpected partitions: %s", d)
+	}
+	expected := "IPLPartitionData{ Partitions: [{ BootIndicator: 0x80, OSType: 0x07, StartingLBA: 2048, SizeInLBA: 204800 }, " +
+		"{ BootIndicator: 0x00, OSType: 0x83, StartingLBA: 206848, SizeInLBA: 1024000 }] }"
+	if d.String() != expected {
+		t.Errorf("Unexpected string: %s", d)
+	}
+
+	var buf bytes.Buffer
+	if err := d.EncodeTo(&buf); err != nil {
+		t.Fatalf("EncodeTo failed: %v", err)
+	}
+	if !bytes.Equal(buf.Bytes(), data.Bytes()) {
+		t.Errorf("Unexpected encoding")
+	}
+}
+
+func TestDecodeEventDataIPLString(t *testing.T) {
+	d := DecodeEventData(4, EventTypeIPL, DigestMap{}, []byte("IPL"), nil)
+	if _, ok := d.(*AsciiStringEventData); !ok {
+		t.Fatalf("Unexpected event data type: %T", d)
+	}
+
+	// Data that is neither a string nor a MBR is left opaque
+	d = DecodeEventData(4, EventTypeIPL, DigestMap{}, make([]byte, 16), nil)
+	if _, ok := d.(*opaqueEventData); !ok {
+		t.Errorf("Unexpected event data type: %T", d)
+	}
+}
@@ -0,0 +1,259 @@
+// Copyright 2019 Canonical Ltd.
+// Licensed under the LGPLv3 with static-linking exception.
+// See LICENCE file for details.
+
+package tcglog
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"golang.org/x/xerrors"
+)
+
+// ErrEFIVariableUnavailable is returned from an EFIVariableReader for a variable whose value it can't provide, as opposed to a
+// variable that doesn't exist.
+var ErrEFIVariableUnavailable = errors.New("variable is not available from this reader")
+
+// shimMokRuntimeVariables maps the variables that shim measures to PCR 14 to the runtime variables that it mirrors them to.
+//
+// https://github.com/rhboot/shim/blob/main/mok.c
+//  (mok_state_variables)
+var shimMokRuntimeVariables = map[string]string{
+	"MokList":        "MokListRT",
+	"MokListX":       "MokListXRT",
+	"MokSBState":     "MokSBStateRT",
+	"MokListTrusted": "MokListTrustedRT",
+}
+
+// shimMokRequestVariables are the variables that mokutil creates to request a change to the MOK state, which MokManager
+// processes on the next boot.
+var shimMokRequestVariables = []struct {
+	name        string
+	description string
+}{
+	{"MokNew", "enroll new keys in MokList"},
+	{"MokDel", "delete keys from MokList"},
+	{"MokXNew", "enroll new entries in MokListX"},
+	{"MokXDel", "delete entries from MokListX"},
+	{"MokSB", "change the shim validation state"},
+	{"MokDB", "change whether shim uses the UEFI db"},
+	{"MokListTrustedNew", "change whether the kernel trusts MOK keys"},
+	{"MokPW", "set a MOK password"},
+}
+
+// MokutilExport is an EFIVariableReader that provides the value of MokListRT from a directory of DER encoded certificates
+// exported with "mokutil --export" (MOK-0001.der, MOK-0002.der etc). MokListRT is reconstructed with each certificate in its
+// own EFI_SIGNATURE_LIST owned by shim, which is how mokutil enrolls certificates and how shim adds its vendor certificate.
+// Hashes can't be exported by mokutil, so the reconstructed value won't match the measurement if MokList contains any. Every
+// other variable is unavailable.
+type MokutilExport string
+
+// ReadEFIVariable implements EFIVariableReader.ReadEFIVariable.
+func (p MokutilExport) ReadEFIVariable(name string, guid EFIGUID) ([]byte, error) {
+	if name != "MokListRT" || guid != ShimLockGuid {
+		return nil, ErrEFIVariableUnavailable
+	}
+
+	paths, err := filepath.Glob(filepath.Join(string(p), "MOK-*.der"))
+	if err != nil {
+		return nil, err
+	}
+	if len(paths) == 0 {
+		return nil, xerrors.Errorf("no exported certificates: %w", os.ErrNotExist)
+	}
+	sort.Strings(paths)
+
+	var b bytes.Buffer
+	for _, path := range paths {
+		cert, err := ioutil.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+		b.Write(EFICertX509Guid[:])
+		binary.Write(&b, binary.LittleEndian, uint32(28+16+len(cert)))
+		binary.Write(&b, binary.LittleEndian, uint32(0))
+		binary.Write(&b, binary.LittleEndian, uint32(16+len(cert)))
+		b.Write(ShimLockGuid[:])
+		b.Write(cert)
+	}
+	return b.Bytes(), nil
+}
+
+// readShimMokRuntimeVariable reads the runtime mirror of a MOK variable. Shim splits large variables across several runtime
+// variables (eg, MokListRT, MokListRT1, MokListRT2), which are concatenated.
+func readShimMokRuntimeVariable(r EFIVariableReader, name string) ([]byte, error) {
+	data, err := r.ReadEFIVariable(name, ShimLockGuid)
+	if err != nil {
+		return nil, err
+	}
+	for i := 1; ; i++ {
+		more, err := r.ReadEFIVariable(fmt.Sprintf("%s%d", name, i), ShimLockGuid)
+		switch {
+		case xerrors.Is(err, os.ErrNotExist) || err == ErrEFIVariableUnavailable:
+			return data, nil
+		case err != nil:
+			return nil, err
+		}
+		data = append(data, more...)
+	}
+}
+
+// MokVariableStatus describes whether a MOK variable has changed since it was measured.
+type MokVariableStatus int
+
+const (
+	// MokVariableUnchanged indicates that the current value of the variable matches the measurement.
+	MokVariableUnchanged MokVariableStatus = iota
+
+	// MokVariableModified indicates that the current value of the variable doesn't match the measurement.
+	MokVariableModified
+
+	// MokVariableDeleted indicates that the variable no longer exists.
+	MokVariableDeleted
+
+	// MokVariableUnknown indicates that the current value of the variable is not available.
+	MokVariableUnknown
+)
+
+func (s MokVariableStatus) String() string {
+	switch s {
+	case MokVariableUnchanged:
+		return "unchanged"
+	case MokVariableModified:
+		return "modified"
+	case MokVariableDeleted:
+		return "deleted"
+	case MokVariableUnknown:
+		return "not checked"
+	default:
+		return fmt.Sprintf("%%!(UNKNOWN_MOK_VARIABLE_STATUS=%d)", int(s))
+	}
+}
+
+// MokVariableCheck is the result of comparing a MOK variable measured to PCR 14 with the current value of its runtime mirror.
+type MokVariableCheck struct {
+	Event       *Event
+	Name        string // The name of the measured variable, eg, "MokList"
+	RuntimeName string // The name of the runtime mirror, eg, "MokListRT"
+	Status      MokVariableStatus
+	Current     []byte // The current value of the runtime mirror, if it exists
+}
+
+func (c *MokVariableCheck) String() string {
+	return fmt.Sprintf("%s (event %d in PCR %d): %s", c.Name, c.Event.Index, c.Event.PCRIndex, c.Status)
+}
+
+// MokPendingRequest is a request to change the MOK state that will be processed by MokManager on the next boot.
+type MokPendingRequest struct {
+	Name        string // The name of the request variable, eg, "MokNew"
+	Description string
+}
+
+func (r *MokPendingRequest) String() string {
+	return fmt.Sprintf("%s: request to %s", r.Name, r.Description)
+}
+
+// MokStateCheck is the result of cross-checking the MOK measurements in a log with the current MOK state.
+type MokStateCheck struct {
+	Variables []*MokVariableCheck
+	Pending   []*MokPendingRequest
+}
+
+// Changed indicates whether a measured MOK variable has changed or there are pending requests to change the MOK state, either
+// of which will change the value of PCR 14 (and, for a pending request, PCR 4 when MokManager is loaded) on the next boot.
+func (c *MokStateCheck) Changed() bool {
+	for _, v := range c.Variables {
+		if v.Status == MokVariableModified || v.Status == MokVariableDeleted {
+			return true
+		}
+	}
+	return len(c.Pending) > 0
+}
+
+func (c *MokStateCheck) String() string {
+	var lines []string
+	for _, v := range c.Variables {
+		lines = append(lines, v.String())
+	}
+	for _, r := range c.Pending {
+		lines = append(lines, r.String())
+	}
+	return strings.Join(lines, "\n")
+}
+
+// CheckMokState cross-checks the MOK variables measured by shim to PCR 14 with the current MOK state read from r, which can be
+// an EFIVarfs for the measured machine or a MokutilExport. Shim measures MokList, MokListX, MokSBState and MokListTrusted
+// before mirroring them to the runtime variables MokListRT, MokListXRT, MokSBStateRT and MokListTrustedRT, so the digests of
+// each runtime variable are compared with the last measurement of the corresponding variable. The request variables created by
+// mokutil are also checked in order to detect changes that are staged for the next boot.
+func (l *Log) CheckMokState(r EFIVariableReader) (*MokStateCheck, error) {
+	var names []string
+	last := make(map[string]*Event)
+	for _, e := range l.Events {
+		d, ok := e.Data.(*ShimMokEventData)
+		if !ok || e.PCRIndex != ShimMokPCR {
+			continue
+		}
+		if _, ok := shimMokRuntimeVariables[d.Name]; !ok {
+			continue
+		}
+		if _, seen := last[d.Name]; !seen {
+			names = append(names, d.Name)
+		}
+		last[d.Name] = e
+	}
+
+	out := new(MokStateCheck)
+	for _, name := range names {
+		e := last[name]
+		c := &MokVariableCheck{Event: e, Name: name, RuntimeName: shimMokRuntimeVariables[name]}
+		out.Variables = append(out.Variables, c)
+
+		current, err := readShimMokRuntimeVariable(r, c.RuntimeName)
+		switch {
+		case err == ErrEFIVariableUnavailable:
+			c.Status = MokVariableUnknown
+			continue
+		case xerrors.Is(err, os.ErrNotExist):
+			// A variable that was measured with no data is treated as unchanged if it doesn't exist.
+			current = nil
+			c.Status = MokVariableDeleted
+		case err != nil:
+			return nil, xerrors.Errorf("cannot read variable %s: %w", c.RuntimeName, err)
+		default:
+			c.Current = current
+			c.Status = MokVariableModified
+		}
+
+		matched := len(e.Digests) > 0
+		for alg, digest := range e.Digests {
+			if !bytes.Equal(alg.hash(current), digest) {
+				matched = false
+			}
+		}
+		if matched {
+			c.Status = MokVariableUnchanged
+		}
+	}
+
+	for _, v := range shimMokRequestVariables {
+		_, err := r.ReadEFIVariable(v.name, ShimLockGuid)
+		switch {
+		case err == ErrEFIVariableUnavailable || xerrors.Is(err, os.ErrNotExist):
+		case err != nil:
+			return nil, xerrors.Errorf("cannot read variable %s: %w", v.name, err)
+		default:
+			out.Pending = append(out.Pending, &MokPendingRequest{Name: v.name, Description: v.description})
+		}
+	}
+
+	return out, nil
+}
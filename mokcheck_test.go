@@ -0,0 +1,119 @@
+// Copyright 2019 Canonical Ltd.
+// Licensed under the LGPLv3 with static-linking exception.
+// See LICENCE file for details.
+
+package tcglog
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestCheckMokState(t *testing.T) {
+	mokList := makeTestSignatureList(EFICertX509Guid, ShimLockGuid, makeTestCertificate(t, "Test MOK"))
+	mokListX := makeTestSignatureList(EFICertX509Guid, ShimLockGuid, makeTestCertificate(t, "Revoked MOK"))
+
+	mokEvent := func(name string, data []byte) *Event {
+		e := makeTestEvent(ShimMokPCR, EventTypeIPL, []byte(name+"\x00"), AlgorithmSha1, AlgorithmSha256)
+		for alg := range e.Digests {
+			e.Digests[alg] = alg.hash(data)
+		}
+		return e
+	}
+	log := NewLog([]*Event{
+		mokEvent("MokList", mokList),
+		mokEvent("MokListX", mokListX),
+		mokEvent("MokSBState", []byte{0x01}),
+		mokEvent("MokListTrusted", nil),
+	})
+
+	dir, err := ioutil.TempDir("", "efivars")
+	if err != nil {
+		t.Fatalf("TempDir failed: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	writeVariable := func(name string, data []byte) {
+		path := filepath.Join(dir, name+"-605dab50-e046-4300-abb6-3dd810dd8b23")
+		if err := ioutil.WriteFile(path, append([]byte{0x06, 0x00, 0x00, 0x00}, data...), 0644); err != nil {
+			t.Fatalf("WriteFile failed: %v", err)
+		}
+	}
+	// MokListRT is split across 2 variables.
+	writeVariable("MokListRT", mokList[:20])
+	writeVariable("MokListRT1", mokList[20:])
+	writeVariable("MokListXRT", mokList)
+
+	check, err := log.CheckMokState(EFIVarfs(dir))
+	if err != nil {
+		t.Fatalf("CheckMokState failed: %v", err)
+	}
+	expected := `MokList (event 0 in PCR 14): unchanged
+MokListX (event 1 in PCR 14): modified
+MokSBState (event 2 in PCR 14): deleted
+MokListTrusted (event 3 in PCR 14): unchanged`
+	if check.String() != expected {
+		t.Errorf("Unexpected result:\n%s", check)
+	}
+	if !check.Changed() {
+		t.Errorf("Expected the MOK state to have changed")
+	}
+	if !bytes.Equal(check.Variables[1].Current, mokList) {
+		t.Errorf("Unexpected current value of MokListXRT")
+	}
+
+	writeVariable("MokListXRT", mokListX)
+	writeVariable("MokSBStateRT", []byte{0x01})
+	check, err = log.CheckMokState(EFIVarfs(dir))
+	if err != nil {
+		t.Fatalf("CheckMokState failed: %v", err)
+	}
+	if check.Changed() {
+		t.Errorf("Unexpected change:\n%s", check)
+	}
+
+	writeVariable("MokNew", []byte{0x01})
+	check, err = log.CheckMokState(EFIVarfs(dir))
+	if err != nil {
+		t.Fatalf("CheckMokState failed: %v", err)
+	}
+	if !check.Changed() || len(check.Pending) != 1 || check.Pending[0].String() != "MokNew: request to enroll new keys in MokList" {
+		t.Errorf("Unexpected pending requests:\n%s", check)
+	}
+}
+
+func TestCheckMokStateMokutilExport(t *testing.T) {
+	cert1 := makeTestCertificate(t, "Test MOK 1")
+	cert2 := makeTestCertificate(t, "Test MOK 2")
+	var mokList bytes.Buffer
+	mokList.Write(makeTestSignatureList(EFICertX509Guid, ShimLockGuid, cert1))
+	mokList.Write(makeTestSignatureList(EFICertX509Guid, ShimLockGuid, cert2))
+
+	e := makeTestEvent(ShimMokPCR, EventTypeIPL, []byte("MokList\x00"), AlgorithmSha256)
+	e.Digests[AlgorithmSha256] = AlgorithmSha256.hash(mokList.Bytes())
+	log := NewLog([]*Event{e, makeTestEvent(ShimMokPCR, EventTypeIPL, []byte("MokSBState\x00"), AlgorithmSha256)})
+
+	dir, err := ioutil.TempDir("", "mokutil")
+	if err != nil {
+		t.Fatalf("TempDir failed: %v", err)
+	}
+	defer os.RemoveAll(dir)
+	for name, cert := range map[string][]byte{"MOK-0001.der": cert1, "MOK-0002.der": cert2} {
+		if err := ioutil.WriteFile(filepath.Join(dir, name), cert, 0644); err != nil {
+			t.Fatalf("WriteFile failed: %v", err)
+		}
+	}
+
+	check, err := log.CheckMokState(MokutilExport(dir))
+	if err != nil {
+		t.Fatalf("CheckMokState failed: %v", err)
+	}
+	expected := `MokList (event 0 in PCR 14): unchanged
+MokSBState (event 1 in PCR 14): not checked`
+	if check.String() != expected || check.Changed() {
+		t.Errorf("Unexpected result:\n%s", check)
+	}
+}
@@ -0,0 +1,97 @@
+// Copyright 2019 Canonical Ltd.
+// Licensed under the LGPLv3 with static-linking exception.
+// See LICENCE file for details.
+
+package tcglog
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestDecodeNoActionEventTypes(t *testing.T) {
+	for _, data := range []struct {
+		desc      string
+		data      []byte
+		typ       NoActionEventType
+		signature string
+	}{
+		{
+			desc:      "StartupLocality",
+			data:      append([]byte("StartupLocality\x00"), 3),
+			typ:       StartupLocality,
+			signature: "StartupLocality",
+		},
+		{
+			desc:      "NvIndexInstance",
+			data:      append([]byte("NvIndexInstance\x00\x01\x00\x00\x00\x00\x00\x00\x00"), 0xaa, 0xbb),
+			typ:       NvIndexInstance,
+			signature: "NvIndexInstance",
+		},
+		{
+			desc:      "NvIndexDynamic",
+			data:      append([]byte("NvIndexDynamic\x00\x00\x01\x00\x00\x00\x00\x00\x00\x00"), 0xaa, 0xbb),
+			typ:       NvIndexDynamic,
+			signature: "NvIndexDynamic",
+		},
+		{
+			desc:      "Vendor",
+			data:      append([]byte("Acme Info\x00\x00\x00\x00\x00\x00\x00"), 0xaa, 0xbb),
+			typ:       UnknownNoActionEvent,
+			signature: "Acme Info",
+		},
+	} {
+		t.Run(data.desc, func(t *testing.T) {
+			d, ok := DecodeEventData(0, EventTypeNoAction, DigestMap{}, data.data, nil).(NoActionEventData)
+			if !ok {
+				t.Fatalf("Event data doesn't implement NoActionEventData")
+			}
+			if d.Type() != data.typ {
+				t.Errorf("Unexpected type: %v", d.Type())
+			}
+			if d.Signature() != data.signature {
+				t.Errorf("Unexpected signature: %s", d.Signature())
+			}
+		})
+	}
+}
+
+func TestNvIndexEventDataFields(t *testing.T) {
+	data := append([]byte("NvIndexInstance\x00\x01\x00\x00\x00\x00\x00\x00\x00"), 0xaa, 0xbb)
+	d, ok := DecodeEventData(0, EventTypeNoAction, DigestMap{}, data, nil).(*NvIndexEventData)
+	if !ok {
+		t.Fatalf("Unexpected event data type")
+	}
+	if d.Version != 1 || !bytes.Equal(d.Data, []byte{0xaa, 0xbb}) {
+		t.Errorf("Unexpected event data: %s", d)
+	}
+
+	if _, isErr := DecodeEventData(0, EventTypeNoAction, DigestMap{}, data[:20], nil).(error); !isErr {
+		t.Errorf("Decoding should fail with a truncated header")
+	}
+}
+
+func TestVendorNoActionEventData(t *testing.T) {
+	data := append([]byte("Acme Info\x00\x00\x00\x00\x00\x00\x00"), 0xaa, 0xbb)
+	d, ok := DecodeEventData(0, EventTypeNoAction, DigestMap{}, data, nil).(*VendorNoActionEventData)
+	if !ok {
+		t.Fatalf("Unexpected event data type")
+	}
+	if !bytes.Equal(d.VendorData, []byte{0xaa, 0xbb}) {
+		t.Errorf("Unexpected vendor data: %x", d.VendorData)
+	}
+}
+
+func TestLogStartupLocality(t *testing.T) {
+	log := NewLog([]*Event{
+		makeTestEvent(0, EventTypeNoAction, append([]byte("StartupLocality\x00"), 3), AlgorithmSha256),
+		makeTestEvent(0, EventTypeSCRTMVersion, []byte{0x31, 0x00}, AlgorithmSha256)})
+	if log.StartupLocality() != 3 {
+		t.Errorf("Unexpected startup locality: %d", log.StartupLocality())
+	}
+
+	log = NewLog([]*Event{makeTestEvent(0, EventTypeSCRTMVersion, []byte{0x31, 0x00}, AlgorithmSha256)})
+	if log.StartupLocality() != 0 {
+		t.Errorf("Unexpected startup locality: %d", log.StartupLocality())
+	}
+}
@@ -0,0 +1,69 @@
+// Copyright 2019 Canonical Ltd.
+// Licensed under the LGPLv3 with static-linking exception.
+// See LICENCE file for details.
+
+package tcglog
+
+import (
+	"io"
+)
+
+// NonhostEventData is the event data associated with EV_NONHOST_CODE, EV_NONHOST_CONFIG and EV_NONHOST_INFO events, which are
+// recorded for components that aren't part of the host platform, such as embedded controllers and BMCs. The format of this data
+// isn't defined, so it is decoded heuristically.
+type NonhostEventData struct {
+	data []byte
+	Str  string // The data as a string, if it is a printable ASCII or UTF-16 string
+
+	// Blob is the decoded data if it is a UEFI_PLATFORM_FIRMWARE_BLOB or UEFI_PLATFORM_FIRMWARE_BLOB2 structure, which is the
+	// case for some EV_NONHOST_CODE events.
+	Blob EventData
+}
+
+func (e *NonhostEventData) String() string {
+	if e.Blob != nil {
+		return e.Blob.String()
+	}
+	return e.Str
+}
+
+func (e *NonhostEventData) Bytes() []byte {
+	return e.data
+}
+
+// EncodeTo encodes this event data in to the form in which it appears in the event log. If Blob is set, it is encoded. Otherwise,
+// the original bytes are written if Str hasn't been modified since the event data was decoded, or Str is written as a NUL
+// terminated string in the same encoding as the original data.
+func (e *NonhostEventData) EncodeTo(w io.Writer) error {
+	if e.Blob != nil {
+		return e.Blob.EncodeTo(w)
+	}
+	str, isUTF16, ok := decodePrintableString(e.data)
+	if ok && str == e.Str {
+		_, err := w.Write(e.data)
+		return err
+	}
+	_, err := w.Write(encodePrintableString(e.Str, isUTF16))
+	return err
+}
+
+// https://trustedcomputinggroup.org/wp-content/uploads/TCG_PCClientSpecPlat_TPM_2p0_1p04_pub.pdf
+//  (section 9.4.1 "Event Types")
+func decodeEventDataNonhost(eventType EventType, data []byte) EventData {
+	if str, _, ok := decodePrintableString(data); ok {
+		return &NonhostEventData{data: data, Str: str}
+	}
+
+	if eventType != EventTypeNonhostCode {
+		return nil
+	}
+	if blob, err := decodeEventDataEFIPlatformFirmwareBlob(data); err == nil {
+		return &NonhostEventData{data: data, Blob: blob}
+	}
+	if len(data) > 0 && len(data) == 1+int(data[0])+16 {
+		if blob, err := decodeEventDataEFIPlatformFirmwareBlob2(data); err == nil {
+			return &NonhostEventData{data: data, Blob: blob}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,67 @@
+// Copyright 2019 Canonical Ltd.
+// Licensed under the LGPLv3 with static-linking exception.
+// See LICENCE file for details.
+
+package tcglog
+
+import (
+	"testing"
+)
+
+func TestDecodeEventDataNonhost(t *testing.T) {
+	blob := []byte{0x00, 0x00, 0x00, 0xfe, 0x00, 0x00, 0x00, 0x00, 0x00, 0x10, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00}
+
+	for _, data := range []struct {
+		desc      string
+		eventType EventType
+		data      []byte
+		str       string
+		isBlob    bool
+	}{
+		{
+			desc:      "ASCII",
+			eventType: EventTypeNonhostInfo,
+			data:      []byte("BMC firmware 1.23\x00"),
+			str:       "BMC firmware 1.23",
+		},
+		{
+			desc:      "UTF16",
+			eventType: EventTypeNonhostConfig,
+			data:      []byte{'E', 0, 'C', 0, 0, 0},
+			str:       "EC",
+		},
+		{
+			desc:      "Blob",
+			eventType: EventTypeNonhostCode,
+			data:      blob,
+			str:       "UEFI_PLATFORM_FIRMWARE_BLOB{BlobBase: 0xfe000000, BlobLength: 4096}",
+			isBlob:    true,
+		},
+		{
+			desc:      "Blob2",
+			eventType: EventTypeNonhostCode,
+			data:      append([]byte{0x02, 'E', 'C'}, blob...),
+			str:       "UEFI_PLATFORM_FIRMWARE_BLOB2{BlobDescription: \"EC\", BlobBase: 0xfe000000, BlobLength: 4096}",
+			isBlob:    true,
+		},
+	} {
+		t.Run(data.desc, func(t *testing.T) {
+			d, ok := DecodeEventData(0, data.eventType, DigestMap{}, data.data, nil).(*NonhostEventData)
+			if !ok {
+				t.Fatalf("Unexpected event data type")
+			}
+			if d.String() != data.str {
+				t.Errorf("Unexpected string: %s", d)
+			}
+			if (d.Blob != nil) != data.isBlob {
+				t.Errorf("Unexpected blob: %v", d.Blob)
+			}
+		})
+	}
+
+	// Blob structures are only recognized for EV_NONHOST_CODE events
+	d := DecodeEventData(0, EventTypeNonhostInfo, DigestMap{}, blob, nil)
+	if _, ok := d.(*opaqueEventData); !ok {
+		t.Errorf("Unexpected event data type: %T", d)
+	}
+}
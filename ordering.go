@@ -0,0 +1,117 @@
+// Copyright 2019 Canonical Ltd.
+// Licensed under the LGPLv3 with static-linking exception.
+// See LICENCE file for details.
+
+package tcglog
+
+import (
+	"fmt"
+)
+
+func isImageLoadEvent(e *Event) bool {
+	switch e.EventType {
+	case EventTypeEFIBootServicesApplication, EventTypeEFIBootServicesDriver, EventTypeEFIRuntimeServicesDriver:
+		return e.PCRIndex == 2 || e.PCRIndex == 4
+	default:
+		return false
+	}
+}
+
+func isSecureBootEnabled(log *Log) bool {
+	for _, e := range log.Events {
+		if e.PCRIndex != 7 || e.EventType != EventTypeEFIVariableDriverConfig {
+			continue
+		}
+		d, ok := e.Data.(*EFIVariableData)
+		if !ok || d.VariableName != EFIGlobalVariableGuid || d.UnicodeName != "SecureBoot" {
+			continue
+		}
+		enabled, err := d.SecureBootState()
+		return err == nil && enabled
+	}
+	return false
+}
+
+// CheckOrdering is a ValidationCheck that reports measurements that appear in an unexpected order. It reports:
+//  - image loads that aren't preceded by any EV_EFI_VARIABLE_AUTHORITY event when secure boot is enabled, and
+//    EV_EFI_VARIABLE_AUTHORITY events that aren't followed by an image load.
+//  - "Exit Boot Services Invocation" actions that aren't followed by a result, or that are repeated before a result is recorded.
+//  - OS-present measurements to PCRs 8 and above, and the "Exit Boot Services Invocation" action, that appear before the
+//    separators in PCRs 0-7.
+func CheckOrdering(log *Log) (out []*ValidationFinding) {
+	secureBoot := isSecureBootEnabled(log)
+
+	var (
+		seenAuthority    bool
+		pendingAuthority []*Event
+		invocation       *Event
+		separators       = make(map[PCRIndex]bool)
+	)
+
+	missingSeparators := func() (pcrs []PCRIndex) {
+		for pcr := PCRIndex(0); pcr <= 7; pcr++ {
+			if !separators[pcr] {
+				pcrs = append(pcrs, pcr)
+			}
+		}
+		return pcrs
+	}
+
+	for _, e := range log.Events {
+		if e.EventType == EventTypeNoAction {
+			continue
+		}
+
+		switch {
+		case e.PCRIndex == 7 && e.EventType == EventTypeEFIVariableAuthority:
+			seenAuthority = true
+			pendingAuthority = append(pendingAuthority, e)
+		case isImageLoadEvent(e):
+			if secureBoot && !seenAuthority {
+				out = append(out, &ValidationFinding{Severity: SeverityWarning, Event: e,
+					Message: "the image was loaded with secure boot enabled but no EV_EFI_VARIABLE_AUTHORITY event precedes it"})
+			}
+			pendingAuthority = nil
+		case e.EventType == EventTypeSeparator:
+			separators[e.PCRIndex] = true
+		}
+
+		if pcrs := missingSeparators(); len(pcrs) > 0 && e.EventType != EventTypeSeparator {
+			isExitBootServices := false
+			if d, ok := e.Data.(*ActionEventData); ok && d.Action == ExitBootServicesInvocation {
+				isExitBootServices = true
+			}
+			if e.PCRIndex >= 8 || isExitBootServices {
+				out = append(out, &ValidationFinding{Severity: SeverityWarning, Event: e,
+					Message: fmt.Sprintf("the OS-present measurement appears before the separators in PCRs %v", pcrs)})
+			}
+		}
+
+		d, ok := e.Data.(*ActionEventData)
+		if !ok {
+			continue
+		}
+		switch d.Action {
+		case ExitBootServicesInvocation:
+			if invocation != nil {
+				out = append(out, &ValidationFinding{Severity: SeverityWarning, Event: e,
+					Message: fmt.Sprintf("ExitBootServices was invoked again before the result of the previous invocation "+
+						"(event %d) was recorded", invocation.Index)})
+			}
+			invocation = e
+		case ExitBootServicesReturnedFailure, ExitBootServicesReturnedSuccess:
+			invocation = nil
+		}
+	}
+
+	for _, e := range pendingAuthority {
+		out = append(out, &ValidationFinding{Severity: SeverityWarning, Event: e,
+			Message: "the EV_EFI_VARIABLE_AUTHORITY event is not followed by an image load"})
+	}
+	if invocation != nil {
+		out = append(out, &ValidationFinding{Severity: SeverityWarning, Event: invocation,
+			Message: "the result of ExitBootServices is not recorded"})
+	}
+
+	return out
+}
@@ -0,0 +1,110 @@
+// Copyright 2019 Canonical Ltd.
+// Licensed under the LGPLv3 with static-linking exception.
+// See LICENCE file for details.
+
+package tcglog
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+)
+
+func TestCheckOrdering(t *testing.T) {
+	algs := []AlgorithmId{AlgorithmSha256}
+
+	secureBoot := func(enabled byte) *Event {
+		varData := EFIVariableData{VariableName: EFIGlobalVariableGuid, UnicodeName: "SecureBoot", VariableData: []byte{enabled}}
+		var b bytes.Buffer
+		varData.EncodeMeasuredBytes(&b)
+		return makeTestEvent(7, EventTypeEFIVariableDriverConfig, b.Bytes(), algs...)
+	}
+	separators := func() (out []*Event) {
+		for pcr := PCRIndex(0); pcr <= 7; pcr++ {
+			out = append(out, makeTestEvent(pcr, EventTypeSeparator, []byte{0, 0, 0, 0}, algs...))
+		}
+		return out
+	}
+	authority := func() *Event {
+		return makeTestEvent(7, EventTypeEFIVariableAuthority, makeTestPFPVariable(EFIImageSecurityDatabaseGuid, "db"), algs...)
+	}
+	load := func() *Event {
+		return makeTestEvent(4, EventTypeEFIBootServicesApplication, makeTestImageLoadEventData("\\EFI\\ubuntu\\shimx64.efi"),
+			algs...)
+	}
+	action := func(pcr PCRIndex, action string) *Event {
+		return makeTestEvent(pcr, EventTypeEFIAction, []byte(action), algs...)
+	}
+	ipl := func() *Event {
+		return makeTestEvent(8, EventTypeIPL, []byte("grub_cmd: linux /vmlinuz\x00"), algs...)
+	}
+	build := func(prefix []*Event, suffix ...*Event) []*Event {
+		return append(append(prefix, separators()...), suffix...)
+	}
+
+	for _, data := range []struct {
+		desc     string
+		events   []*Event
+		expected []string
+	}{
+		{
+			desc: "Valid",
+			events: build([]*Event{secureBoot(1)}, authority(), load(), ipl(),
+				action(5, "Exit Boot Services Invocation"), action(5, "Exit Boot Services Returned with Success")),
+		},
+		{
+			desc:   "SecureBootDisabled",
+			events: build([]*Event{secureBoot(0)}, load()),
+		},
+		{
+			desc:   "MissingAuthority",
+			events: build([]*Event{secureBoot(1)}, load()),
+			expected: []string{
+				"warning: ordering: event 1 in PCR 4 (type: EV_EFI_BOOT_SERVICES_APPLICATION): the image was loaded with secure " +
+					"boot enabled but no EV_EFI_VARIABLE_AUTHORITY event precedes it"},
+		},
+		{
+			desc:   "AuthorityAfterLoad",
+			events: build([]*Event{secureBoot(1)}, load(), authority()),
+			expected: []string{
+				"warning: ordering: event 1 in PCR 4 (type: EV_EFI_BOOT_SERVICES_APPLICATION): the image was loaded with secure " +
+					"boot enabled but no EV_EFI_VARIABLE_AUTHORITY event precedes it",
+				"warning: ordering: event 2 in PCR 7 (type: EV_EFI_VARIABLE_AUTHORITY): the EV_EFI_VARIABLE_AUTHORITY event is not " +
+					"followed by an image load"},
+		},
+		{
+			desc:   "MissingExitBootServicesResult",
+			events: build(nil, action(5, "Exit Boot Services Invocation")),
+			expected: []string{
+				"warning: ordering: event 1 in PCR 5 (type: EV_EFI_ACTION): the result of ExitBootServices is not recorded"},
+		},
+		{
+			desc: "RepeatedExitBootServicesInvocation",
+			events: build(nil, action(5, "Exit Boot Services Invocation"), action(5, "Exit Boot Services Invocation"),
+				action(5, "Exit Boot Services Returned with Failure")),
+			expected: []string{
+				"warning: ordering: event 2 in PCR 5 (type: EV_EFI_ACTION): ExitBootServices was invoked again before the result " +
+					"of the previous invocation (event 1) was recorded"},
+		},
+		{
+			desc:   "OSPresentBeforeSeparators",
+			events: append([]*Event{ipl()}, separators()...),
+			expected: []string{
+				"warning: ordering: event 0 in PCR 8 (type: EV_IPL): the OS-present measurement appears before the separators in " +
+					"PCRs [0 1 2 3 4 5 6 7]"},
+		},
+	} {
+		t.Run(data.desc, func(t *testing.T) {
+			v := new(Validator)
+			v.AddCheck("ordering", CheckOrdering)
+
+			var findings []string
+			for _, f := range v.Validate(NewLog(data.events)) {
+				findings = append(findings, f.String())
+			}
+			if !reflect.DeepEqual(findings, data.expected) {
+				t.Errorf("Unexpected findings: %q", findings)
+			}
+		})
+	}
+}
@@ -0,0 +1,203 @@
+// Copyright 2019 Canonical Ltd.
+// Licensed under the LGPLv3 with static-linking exception.
+// See LICENCE file for details.
+
+package tcglog
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"golang.org/x/xerrors"
+)
+
+type pciVendor struct {
+	name    string
+	devices map[uint16]string
+}
+
+// PCIDatabase is a database of PCI vendor and device names, in the format of the pci.ids file distributed by
+// https://pci-ids.ucw.cz/ (normally installed at /usr/share/misc/pci.ids or /usr/share/hwdata/pci.ids).
+type PCIDatabase struct {
+	vendors map[uint16]*pciVendor
+}
+
+// ParsePCIDatabase parses a PCI ID database from the supplied reader. Subsystem and device class entries are ignored.
+func ParsePCIDatabase(r io.Reader) (*PCIDatabase, error) {
+	db := &PCIDatabase{vendors: make(map[uint16]*pciVendor)}
+	var vendor *pciVendor
+
+	scanner := bufio.NewScanner(r)
+	for n := 1; scanner.Scan(); n++ {
+		line := scanner.Text()
+		if strings.TrimSpace(line) == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		switch {
+		case strings.HasPrefix(line, "\t\t"):
+			// Subsystem entry
+			continue
+		case strings.HasPrefix(line, "\t"):
+			if vendor == nil {
+				return nil, fmt.Errorf("device entry without vendor on line %d", n)
+			}
+			id, name, err := parsePCIDatabaseEntry(line[1:])
+			if err != nil {
+				return nil, xerrors.Errorf("cannot parse device entry on line %d: %w", n, err)
+			}
+			vendor.devices[id] = name
+		case strings.HasPrefix(line, "C "):
+			// The device class list follows the vendor list.
+			return db, nil
+		default:
+			id, name, err := parsePCIDatabaseEntry(line)
+			if err != nil {
+				return nil, xerrors.Errorf("cannot parse vendor entry on line %d: %w", n, err)
+			}
+			vendor = &pciVendor{name: name, devices: make(map[uint16]string)}
+			db.vendors[id] = vendor
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return db, nil
+}
+
+func parsePCIDatabaseEntry(line string) (uint16, string, error) {
+	fields := strings.SplitN(line, " ", 2)
+	if len(fields) != 2 {
+		return 0, "", errors.New("missing name")
+	}
+	id, err := strconv.ParseUint(fields[0], 16, 16)
+	if err != nil {
+		return 0, "", xerrors.Errorf("invalid ID: %w", err)
+	}
+	return uint16(id), strings.TrimSpace(fields[1]), nil
+}
+
+// Name returns a human readable name for the specified PCI vendor and device IDs. If the device isn't in the database, the
+// vendor name and device ID are returned. If the vendor isn't in the database, the vendor and device IDs are returned.
+func (db *PCIDatabase) Name(vendorId, deviceId uint16) string {
+	var vendor *pciVendor
+	if db != nil {
+		vendor = db.vendors[vendorId]
+	}
+	switch {
+	case vendor == nil:
+		return fmt.Sprintf("%04x:%04x", vendorId, deviceId)
+	case vendor.devices[deviceId] == "":
+		return fmt.Sprintf("%s %04x", vendor.name, deviceId)
+	default:
+		return fmt.Sprintf("%s %s", vendor.name, vendor.devices[deviceId])
+	}
+}
+
+type pciDevicePathNode struct {
+	device   uint8
+	function uint8
+}
+
+// devicePathPCINodes returns the UID of the PCI root bridge and the sequence of PCI nodes at the start of the supplied device
+// path. It returns false if the device path doesn't begin with a PCI root bridge node followed by at least one PCI node.
+func devicePathPCINodes(data []byte) (uid uint32, nodes []pciDevicePathNode, ok bool) {
+	for i := 0; len(data) >= 4; i++ {
+		t := efiDevicePathNodeType(data[0])
+		subType := data[1]
+		length := int(binary.LittleEndian.Uint16(data[2:]))
+		if length < 4 || length > len(data) {
+			return 0, nil, false
+		}
+		node := data[4:length]
+		data = data[length:]
+
+		switch {
+		case i == 0 && t == efiDevicePathNodeACPI && subType == efiACPIDevicePathNodeNormal && len(node) >= 8:
+			hid := binary.LittleEndian.Uint32(node)
+			if hid != 0x0a0341d0 && hid != 0x0a0841d0 {
+				return 0, nil, false
+			}
+			uid = binary.LittleEndian.Uint32(node[4:])
+		case i > 0 && t == efiDevicePathNodeHardware && subType == efiHardwareDevicePathNodePCI && len(node) >= 2:
+			nodes = append(nodes, pciDevicePathNode{device: node[1], function: node[0]})
+		default:
+			return uid, nodes, len(nodes) > 0
+		}
+	}
+	return uid, nodes, len(nodes) > 0
+}
+
+// PCIDeviceResolver resolves the PCI nodes of EFI device paths to the PCI devices they refer to, using the sysfs PCI topology of
+// the machine on which it is running. This is only meaningful when run on the machine on which the log was measured.
+type PCIDeviceResolver struct {
+	DB        *PCIDatabase // The database used to obtain device names. If this is nil, names are the vendor and device IDs
+	SysfsPath string       // The path at which sysfs is mounted (defaults to /sys)
+}
+
+func (r *PCIDeviceResolver) sysfsPath() string {
+	if r.SysfsPath == "" {
+		return "/sys"
+	}
+	return r.SysfsPath
+}
+
+func readSysfsPCIID(path string) (uint16, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return 0, err
+	}
+	id, err := strconv.ParseUint(strings.TrimPrefix(string(bytes.TrimSpace(data)), "0x"), 16, 16)
+	if err != nil {
+		return 0, err
+	}
+	return uint16(id), nil
+}
+
+// Resolve returns a human readable name for the last PCI device in the supplied EFI device path, such as the network controller
+// that an option ROM was loaded from. The device path must begin with a PCI root bridge node. Root bridges are assumed to be
+// in PCI domain 0, with a root bus number equal to their UID.
+func (r *PCIDeviceResolver) Resolve(devicePath []byte) (string, error) {
+	uid, nodes, ok := devicePathPCINodes(devicePath)
+	if !ok {
+		return "", errors.New("device path does not begin with a PCI device")
+	}
+
+	dir := filepath.Join(r.sysfsPath(), "devices", fmt.Sprintf("pci0000:%02x", uid))
+	for i, node := range nodes {
+		// The devices behind a bridge are all on the same bus, so the device and function numbers uniquely identify the
+		// next directory.
+		matches, err := filepath.Glob(filepath.Join(dir, fmt.Sprintf("0000:??:%02x.%x", node.device, node.function)))
+		if err != nil {
+			return "", err
+		}
+		if len(matches) != 1 {
+			return "", fmt.Errorf("cannot find device for PCI node %d in %s", i, dir)
+		}
+		dir = matches[0]
+	}
+
+	vendorId, err := readSysfsPCIID(filepath.Join(dir, "vendor"))
+	if err != nil {
+		return "", xerrors.Errorf("cannot read vendor ID: %w", err)
+	}
+	deviceId, err := readSysfsPCIID(filepath.Join(dir, "device"))
+	if err != nil {
+		return "", xerrors.Errorf("cannot read device ID: %w", err)
+	}
+	return r.DB.Name(vendorId, deviceId), nil
+}
+
+// DevicePathData returns the raw device path from which the image was loaded, which can be supplied to
+// PCIDeviceResolver.Resolve.
+func (e *EFIImageLoadEvent) DevicePathData() []byte {
+	return e.devicePath
+}
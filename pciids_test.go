@@ -0,0 +1,107 @@
+// Copyright 2019 Canonical Ltd.
+// Licensed under the LGPLv3 with static-linking exception.
+// See LICENCE file for details.
+
+package tcglog
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const testPCIIds = `# Test PCI ID database
+8086  Intel Corporation
+	1563  Ethernet Controller 10G X550T
+		8086 0001  Ethernet Converged Network Adapter X550-T2
+	a2bc  200 Series PCH PCI Express Root Port #8
+10de  NVIDIA Corporation
+C 02  Network controller
+	00  Ethernet controller
+`
+
+func TestPCIDatabaseName(t *testing.T) {
+	db, err := ParsePCIDatabase(strings.NewReader(testPCIIds))
+	if err != nil {
+		t.Fatalf("ParsePCIDatabase failed: %v", err)
+	}
+
+	for _, data := range []struct {
+		vendor   uint16
+		device   uint16
+		expected string
+	}{
+		{vendor: 0x8086, device: 0x1563, expected: "Intel Corporation Ethernet Controller 10G X550T"},
+		{vendor: 0x10de, device: 0x1234, expected: "NVIDIA Corporation 1234"},
+		{vendor: 0x1af4, device: 0x1000, expected: "1af4:1000"},
+	} {
+		if name := db.Name(data.vendor, data.device); name != data.expected {
+			t.Errorf("Unexpected name for %04x:%04x: %s", data.vendor, data.device, name)
+		}
+	}
+
+	if _, err := ParsePCIDatabase(strings.NewReader("\t1563  Orphan device\n")); err == nil {
+		t.Errorf("Expected an error for a device without a vendor")
+	}
+}
+
+func TestPCIDeviceResolverResolve(t *testing.T) {
+	dir, err := ioutil.TempDir("", "tcglog-sysfs")
+	if err != nil {
+		t.Fatalf("TempDir failed: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	bridge := filepath.Join(dir, "devices", "pci0000:00", "0000:00:1c.7")
+	nic := filepath.Join(bridge, "0000:03:00.0")
+	if err := os.MkdirAll(nic, 0755); err != nil {
+		t.Fatalf("MkdirAll failed: %v", err)
+	}
+	for path, contents := range map[string]string{
+		filepath.Join(bridge, "vendor"): "0x8086\n",
+		filepath.Join(bridge, "device"): "0xa2bc\n",
+		filepath.Join(nic, "vendor"):    "0x8086\n",
+		filepath.Join(nic, "device"):    "0x1563\n",
+	} {
+		if err := ioutil.WriteFile(path, []byte(contents), 0644); err != nil {
+			t.Fatalf("WriteFile failed: %v", err)
+		}
+	}
+
+	db, err := ParsePCIDatabase(strings.NewReader(testPCIIds))
+	if err != nil {
+		t.Fatalf("ParsePCIDatabase failed: %v", err)
+	}
+
+	pciRoot := makeTestDevicePathNode(0x02, 0x01, []byte{0xd0, 0x41, 0x03, 0x0a, 0x00, 0x00, 0x00, 0x00})
+	var path []byte
+	path = append(path, pciRoot...)
+	path = append(path, makeTestDevicePathNode(0x01, 0x01, []byte{0x07, 0x1c})...)
+	path = append(path, makeTestDevicePathNode(0x01, 0x01, []byte{0x00, 0x00})...)
+	path = append(path, makeTestDevicePathNode(0x04, 0x08, make([]byte, 20))...)
+	path = append(path, efiEndEntireDevicePath...)
+
+	r := &PCIDeviceResolver{DB: db, SysfsPath: dir}
+	name, err := r.Resolve(path)
+	if err != nil {
+		t.Fatalf("Resolve failed: %v", err)
+	}
+	if name != "Intel Corporation Ethernet Controller 10G X550T" {
+		t.Errorf("Unexpected name: %s", name)
+	}
+
+	r = &PCIDeviceResolver{SysfsPath: dir}
+	name, err = r.Resolve(path)
+	if err != nil {
+		t.Fatalf("Resolve failed: %v", err)
+	}
+	if name != "8086:1563" {
+		t.Errorf("Unexpected name: %s", name)
+	}
+
+	if _, err := r.Resolve(append(makeTestDevicePathNode(0x01, 0x01, []byte{0x00, 0x01}), efiEndEntireDevicePath...)); err == nil {
+		t.Errorf("Expected an error for a path without a PCI root")
+	}
+}
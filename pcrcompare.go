@@ -0,0 +1,89 @@
+// Copyright 2019 Canonical Ltd.
+// Licensed under the LGPLv3 with static-linking exception.
+// See LICENCE file for details.
+
+package tcglog
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+
+	"golang.org/x/xerrors"
+)
+
+// PCRReader provides access to the current PCR values of a TPM. See the gotpm2 package for an implementation that reads them
+// from a local TPM device.
+type PCRReader interface {
+	// ReadPCRs returns the values of the specified PCRs for each of the specified digest algorithms.
+	ReadPCRs(pcrs []PCRIndex, algorithms AlgorithmIdList) (map[PCRIndex]DigestMap, error)
+}
+
+// PCRDivergence describes a PCR bank with a value that is inconsistent with the value computed by replaying the log.
+type PCRDivergence struct {
+	PCRIndex  PCRIndex
+	Algorithm AlgorithmId
+	Expected  Digest // The value computed by replaying the log
+	Actual    Digest // The value read from the TPM
+}
+
+func (d *PCRDivergence) String() string {
+	return fmt.Sprintf("PCR %d, bank %v: expected %x from the log, got %x from the TPM", d.PCRIndex, d.Algorithm, d.Expected,
+		d.Actual)
+}
+
+// PCRComparison is the result of comparing the PCR values computed by replaying a log with the values read from a TPM.
+type PCRComparison struct {
+	Matched  []PCRIndex       // The PCRs for which every bank is consistent with the log
+	Diverged []*PCRDivergence // The banks that are inconsistent with the log
+}
+
+// Ok indicates whether every PCR that was compared is consistent with the log.
+func (c *PCRComparison) Ok() bool {
+	return len(c.Diverged) == 0
+}
+
+// ComparePCRs reads the current values of the specified PCRs from the supplied reader and compares them against the values
+// computed by replaying this log, for each of the supported digest algorithms in the log. If pcrs is empty, the PCRs that are
+// measured to by events in this log are compared.
+func (l *Log) ComparePCRs(r PCRReader, pcrs []PCRIndex) (*PCRComparison, error) {
+	if len(pcrs) == 0 {
+		pcrs = l.measuredPCRs()
+	}
+
+	var algs AlgorithmIdList
+	for _, alg := range l.Algorithms {
+		if alg.supported() {
+			algs = append(algs, alg)
+		}
+	}
+	if len(algs) == 0 {
+		return nil, errors.New("log has no supported digest algorithms")
+	}
+
+	actual, err := r.ReadPCRs(pcrs, algs)
+	if err != nil {
+		return nil, xerrors.Errorf("cannot read PCR values: %w", err)
+	}
+
+	result := new(PCRComparison)
+	for _, pcr := range pcrs {
+		matched := true
+		for _, alg := range algs {
+			expected, err := l.ReplayPCR(alg, pcr)
+			if err != nil {
+				return nil, xerrors.Errorf("cannot replay PCR %d for %v: %w", pcr, alg, err)
+			}
+			value := actual[pcr][alg]
+			if !bytes.Equal(expected, value) {
+				matched = false
+				result.Diverged = append(result.Diverged,
+					&PCRDivergence{PCRIndex: pcr, Algorithm: alg, Expected: expected, Actual: value})
+			}
+		}
+		if matched {
+			result.Matched = append(result.Matched, pcr)
+		}
+	}
+	return result, nil
+}
@@ -0,0 +1,78 @@
+// Copyright 2019 Canonical Ltd.
+// Licensed under the LGPLv3 with static-linking exception.
+// See LICENCE file for details.
+
+package tcglog
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+type mockPCRReader struct {
+	values map[PCRIndex]DigestMap
+	err    error
+
+	pcrs []PCRIndex
+	algs AlgorithmIdList
+}
+
+func (r *mockPCRReader) ReadPCRs(pcrs []PCRIndex, algorithms AlgorithmIdList) (map[PCRIndex]DigestMap, error) {
+	r.pcrs = pcrs
+	r.algs = algorithms
+	return r.values, r.err
+}
+
+func TestComparePCRs(t *testing.T) {
+	algs := []AlgorithmId{AlgorithmSha1, AlgorithmSha256}
+	log := NewLog([]*Event{
+		makeTestEvent(0, EventTypeSCRTMVersion, []byte{0x31, 0x00}, algs...),
+		makeTestEvent(4, EventTypeEFIAction, []byte("Calling EFI Application from Boot Option"), algs...),
+		makeTestEvent(7, EventTypeSeparator, []byte{0, 0, 0, 0}, algs...)})
+
+	values := make(map[PCRIndex]DigestMap)
+	for _, pcr := range []PCRIndex{0, 4, 7} {
+		values[pcr] = DigestMap{}
+		for _, alg := range algs {
+			values[pcr][alg], _ = log.ReplayPCR(alg, pcr)
+		}
+	}
+	values[4][AlgorithmSha256] = AlgorithmSha256.hash([]byte("foo"))
+
+	r := &mockPCRReader{values: values}
+	comparison, err := log.ComparePCRs(r, nil)
+	if err != nil {
+		t.Fatalf("ComparePCRs failed: %v", err)
+	}
+	if !reflect.DeepEqual(r.pcrs, []PCRIndex{0, 4, 7}) || !reflect.DeepEqual(r.algs, AlgorithmIdList(algs)) {
+		t.Errorf("Unexpected selection: %v %v", r.pcrs, r.algs)
+	}
+	if comparison.Ok() {
+		t.Errorf("Expected a divergence")
+	}
+	if !reflect.DeepEqual(comparison.Matched, []PCRIndex{0, 7}) {
+		t.Errorf("Unexpected matched PCRs: %v", comparison.Matched)
+	}
+	if len(comparison.Diverged) != 1 {
+		t.Fatalf("Unexpected number of divergences (%d)", len(comparison.Diverged))
+	}
+	expected, _ := log.ReplayPCR(AlgorithmSha256, 4)
+	if !reflect.DeepEqual(comparison.Diverged[0], &PCRDivergence{PCRIndex: 4, Algorithm: AlgorithmSha256, Expected: expected,
+		Actual: values[4][AlgorithmSha256]}) {
+		t.Errorf("Unexpected divergence: %s", comparison.Diverged[0])
+	}
+
+	comparison, err = log.ComparePCRs(r, []PCRIndex{0, 8})
+	if err != nil {
+		t.Fatalf("ComparePCRs failed: %v", err)
+	}
+	if !reflect.DeepEqual(comparison.Matched, []PCRIndex{0}) || len(comparison.Diverged) != 2 {
+		t.Errorf("Unexpected comparison for unmeasured PCR: %v %v", comparison.Matched, comparison.Diverged)
+	}
+
+	r.err = errors.New("some error")
+	if _, err := log.ComparePCRs(r, nil); err == nil || err.Error() != "cannot read PCR values: some error" {
+		t.Errorf("Unexpected error: %v", err)
+	}
+}
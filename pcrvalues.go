@@ -0,0 +1,199 @@
+// Copyright 2019 Canonical Ltd.
+// Licensed under the LGPLv3 with static-linking exception.
+// See LICENCE file for details.
+
+package tcglog
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"strconv"
+	"strings"
+
+	"golang.org/x/xerrors"
+)
+
+// PCRValues is a snapshot of PCR values, indexed by PCR and then by digest algorithm. It implements PCRReader, so a log can be
+// verified against a snapshot that was captured separately with Log.ComparePCRs.
+type PCRValues map[PCRIndex]DigestMap
+
+// ReadPCRs implements PCRReader.ReadPCRs. An error is returned if the snapshot doesn't contain a value for one of the requested
+// PCRs and algorithms.
+func (v PCRValues) ReadPCRs(pcrs []PCRIndex, algorithms AlgorithmIdList) (map[PCRIndex]DigestMap, error) {
+	out := make(map[PCRIndex]DigestMap)
+	for _, pcr := range pcrs {
+		out[pcr] = DigestMap{}
+		for _, alg := range algorithms {
+			value, ok := v[pcr][alg]
+			if !ok {
+				return nil, fmt.Errorf("no value for PCR %d in the %v bank", pcr, alg)
+			}
+			out[pcr][alg] = value
+		}
+	}
+	return out, nil
+}
+
+func (v PCRValues) add(pcr PCRIndex, alg AlgorithmId, value string) error {
+	value = strings.TrimPrefix(strings.TrimPrefix(value, "0x"), "0X")
+	digest, err := hex.DecodeString(value)
+	if err != nil {
+		return xerrors.Errorf("cannot decode value for PCR %d in the %v bank: %w", pcr, alg, err)
+	}
+	if len(digest) != alg.Size() {
+		return fmt.Errorf("unexpected value length for PCR %d in the %v bank (%d)", pcr, alg, len(digest))
+	}
+	if _, ok := v[pcr]; !ok {
+		v[pcr] = DigestMap{}
+	}
+	v[pcr][alg] = digest
+	return nil
+}
+
+func parsePCRAlgorithm(name string) (AlgorithmId, error) {
+	normalized := strings.ToLower(strings.Replace(strings.TrimSpace(name), "-", "", -1))
+	alg := parseIMAHashAlgorithm(normalized)
+	if alg == 0 {
+		return 0, fmt.Errorf("unrecognized algorithm \"%s\"", name)
+	}
+	return alg, nil
+}
+
+func parsePCRIndex(s string) (PCRIndex, error) {
+	n, err := strconv.ParseUint(strings.TrimSpace(s), 10, 32)
+	if err != nil {
+		return 0, fmt.Errorf("invalid PCR index \"%s\"", s)
+	}
+	return PCRIndex(n), nil
+}
+
+// parsePCRValuesJSON parses a JSON object that maps algorithm names to objects that map PCR indices to hexadecimal values,
+// optionally wrapped in an object with a "pcrs" key.
+func parsePCRValuesJSON(data []byte) (PCRValues, error) {
+	var banks map[string]json.RawMessage
+	if err := json.Unmarshal(data, &banks); err != nil {
+		return nil, xerrors.Errorf("cannot decode JSON: %w", err)
+	}
+	if inner, ok := banks["pcrs"]; ok {
+		banks = nil
+		if err := json.Unmarshal(inner, &banks); err != nil {
+			return nil, xerrors.Errorf("cannot decode pcrs: %w", err)
+		}
+	}
+
+	out := make(PCRValues)
+	for name, raw := range banks {
+		alg, err := parsePCRAlgorithm(name)
+		if err != nil {
+			return nil, err
+		}
+		var values map[string]string
+		if err := json.Unmarshal(raw, &values); err != nil {
+			return nil, xerrors.Errorf("cannot decode %s bank: %w", name, err)
+		}
+		for index, value := range values {
+			pcr, err := parsePCRIndex(index)
+			if err != nil {
+				return nil, err
+			}
+			if err := out.add(pcr, alg, value); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return out, nil
+}
+
+// parsePCRValuesText parses either the output of tpm2_pcrread, where each bank is introduced by a line containing an algorithm
+// name followed by a colon and each value is on a subsequent line of the form "<pcr> : 0x<hex>", or a list of values of the form
+// "<pcr>:<alg>=<hex>" separated by whitespace or commas. Blank lines and lines starting with '#' are ignored.
+func parsePCRValuesText(data []byte) (PCRValues, error) {
+	out := make(PCRValues)
+
+	var bank AlgorithmId
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for n := 1; scanner.Scan(); n++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		if strings.Contains(line, "=") {
+			for _, entry := range strings.FieldsFunc(line, func(r rune) bool { return r == ',' || r == ' ' || r == '\t' }) {
+				i := strings.Index(entry, ":")
+				j := strings.Index(entry, "=")
+				if i < 0 || j < i {
+					return nil, fmt.Errorf("line %d: invalid entry \"%s\"", n, entry)
+				}
+				pcr, err := parsePCRIndex(entry[:i])
+				if err != nil {
+					return nil, fmt.Errorf("line %d: %v", n, err)
+				}
+				alg, err := parsePCRAlgorithm(entry[i+1 : j])
+				if err != nil {
+					return nil, fmt.Errorf("line %d: %v", n, err)
+				}
+				if err := out.add(pcr, alg, entry[j+1:]); err != nil {
+					return nil, fmt.Errorf("line %d: %v", n, err)
+				}
+			}
+			continue
+		}
+
+		i := strings.Index(line, ":")
+		if i < 0 {
+			return nil, fmt.Errorf("line %d: unexpected \"%s\"", n, line)
+		}
+		key := strings.TrimSpace(line[:i])
+		value := strings.TrimSpace(line[i+1:])
+
+		if value == "" {
+			alg, err := parsePCRAlgorithm(key)
+			if err != nil {
+				return nil, fmt.Errorf("line %d: %v", n, err)
+			}
+			bank = alg
+			continue
+		}
+
+		if bank == 0 {
+			return nil, fmt.Errorf("line %d: PCR value outside of a bank", n)
+		}
+		pcr, err := parsePCRIndex(key)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %v", n, err)
+		}
+		if err := out.add(pcr, bank, value); err != nil {
+			return nil, fmt.Errorf("line %d: %v", n, err)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	return out, nil
+}
+
+// ParsePCRValues parses a snapshot of PCR values from r, for verifying a log offline with Log.ComparePCRs. The following formats
+// are detected automatically:
+//  - the output of tpm2_pcrread from tpm2-tools.
+//  - a list of values of the form "<pcr>:<alg>=<hex>", eg, "7:sha256=65caf8dd...", separated by whitespace, commas or newlines.
+//  - a JSON object mapping algorithm names to objects that map PCR indices to hexadecimal values, eg,
+//    {"sha256": {"7": "65caf8dd..."}}, optionally wrapped in an object with a "pcrs" key.
+// Algorithm names are case insensitive and may be written as eg, "sha256" or "SHA-256". Values may have a "0x" prefix.
+func ParsePCRValues(r io.Reader) (PCRValues, error) {
+	data, err := ioutil.ReadAll(r)
+	if err != nil {
+		return nil, xerrors.Errorf("cannot read PCR values: %w", err)
+	}
+
+	if bytes.HasPrefix(bytes.TrimSpace(data), []byte("{")) {
+		return parsePCRValuesJSON(data)
+	}
+	return parsePCRValuesText(data)
+}
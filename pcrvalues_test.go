@@ -0,0 +1,99 @@
+// Copyright 2019 Canonical Ltd.
+// Licensed under the LGPLv3 with static-linking exception.
+// See LICENCE file for details.
+
+package tcglog
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParsePCRValues(t *testing.T) {
+	sha1 := AlgorithmSha1.hash([]byte("foo"))
+	sha256a := AlgorithmSha256.hash([]byte("bar"))
+	sha256b := AlgorithmSha256.hash([]byte("baz"))
+
+	expected := PCRValues{
+		0: DigestMap{AlgorithmSha1: sha1, AlgorithmSha256: sha256a},
+		7: DigestMap{AlgorithmSha256: sha256b}}
+
+	for _, data := range []struct {
+		desc  string
+		input string
+	}{
+		{
+			desc: "TPM2Tools",
+			input: fmt.Sprintf("  sha1:\n    0 : 0x%X\n  sha256:\n    0 : 0x%X\n    7 : 0x%X\n",
+				sha1, sha256a, sha256b),
+		},
+		{
+			desc:  "List",
+			input: fmt.Sprintf("# snapshot\n0:sha1=%x, 0:sha256=%x\n7:SHA-256=0x%x\n", sha1, sha256a, sha256b),
+		},
+		{
+			desc:  "JSON",
+			input: fmt.Sprintf(`{"sha1": {"0": "%x"}, "sha256": {"0": "%x", "7": "0x%x"}}`, sha1, sha256a, sha256b),
+		},
+		{
+			desc:  "JSONWrapped",
+			input: fmt.Sprintf(`{"pcrs": {"SHA1": {"0": "%x"}, "SHA256": {"0": "%x", "7": "%x"}}}`, sha1, sha256a, sha256b),
+		},
+	} {
+		t.Run(data.desc, func(t *testing.T) {
+			values, err := ParsePCRValues(strings.NewReader(data.input))
+			if err != nil {
+				t.Fatalf("ParsePCRValues failed: %v", err)
+			}
+			if !reflect.DeepEqual(values, expected) {
+				t.Errorf("Unexpected values: %v", values)
+			}
+		})
+	}
+}
+
+func TestParsePCRValuesErrors(t *testing.T) {
+	for _, data := range []struct {
+		desc  string
+		input string
+		err   string
+	}{
+		{desc: "UnknownAlgorithm", input: "0:md5=00", err: "line 1: unrecognized algorithm \"md5\""},
+		{desc: "BadLength", input: "0:sha1=0011", err: "line 1: unexpected value length for PCR 0 in the SHA-1 bank (2)"},
+		{desc: "NoBank", input: "0 : 0x00", err: "line 1: PCR value outside of a bank"},
+		{desc: "BadIndex", input: "sha1:\n  foo : 0x00", err: "line 2: invalid PCR index \"foo\""},
+		{desc: "BadJSON", input: `{"sha1": []}`, err: "cannot decode sha1 bank: json: cannot unmarshal array into Go value of " +
+			"type map[string]string"},
+	} {
+		t.Run(data.desc, func(t *testing.T) {
+			_, err := ParsePCRValues(strings.NewReader(data.input))
+			if err == nil || err.Error() != data.err {
+				t.Errorf("Unexpected error: %v", err)
+			}
+		})
+	}
+}
+
+func TestComparePCRsWithPCRValues(t *testing.T) {
+	log := NewLog([]*Event{makeTestEvent(7, EventTypeSeparator, []byte{0, 0, 0, 0}, AlgorithmSha256)})
+	expected, _ := log.ReplayPCR(AlgorithmSha256, 7)
+
+	values, err := ParsePCRValues(strings.NewReader(fmt.Sprintf("7:sha256=%x", expected)))
+	if err != nil {
+		t.Fatalf("ParsePCRValues failed: %v", err)
+	}
+	comparison, err := log.ComparePCRs(values, nil)
+	if err != nil {
+		t.Fatalf("ComparePCRs failed: %v", err)
+	}
+	if !comparison.Ok() || !reflect.DeepEqual(comparison.Matched, []PCRIndex{7}) {
+		t.Errorf("Unexpected comparison: %v %v", comparison.Matched, comparison.Diverged)
+	}
+
+	if _, err := log.ComparePCRs(values, []PCRIndex{0}); err == nil ||
+		err.Error() != "cannot read PCR values: no value for PCR 0 in the SHA-256 bank" {
+		t.Errorf("Unexpected error: %v", err)
+	}
+}
@@ -0,0 +1,227 @@
+// Copyright 2019 Canonical Ltd.
+// Licensed under the LGPLv3 with static-linking exception.
+// See LICENCE file for details.
+
+package tcglog
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+)
+
+// pfpAllowedEventTypes describes the types of event that the TCG PC Client Platform Firmware Profile Specification permits to be
+// measured to each of PCRs 0-7, in addition to the types in pfpAnyPCREventTypes. PCR 6 is reserved for the host platform
+// manufacturer and is not restricted.
+//
+// https://trustedcomputinggroup.org/wp-content/uploads/TCG_PCClientSpecPlat_TPM_2p0_1p04_pub.pdf
+//  (section 3.3.4 "PCR Usage", section 9.4.1 "Event Types")
+var pfpAllowedEventTypes = map[PCRIndex][]EventType{
+	0: {EventTypePostCode, EventTypePostCode2, EventTypeSCRTMContents, EventTypeSCRTMVersion, EventTypeCPUMicrocode,
+		EventTypeNonhostCode, EventTypeNonhostInfo, EventTypeEFIPlatformFirmwareBlob, EventTypeEFIPlatformFirmwareBlob2,
+		EventTypeEFIHandoffTables, EventTypeEFIHandoffTables2, EventTypeEFIHCRTMEvent, EventTypeEFISPDMFirmwareBlob},
+	1: {EventTypeCPUMicrocode, EventTypePlatformConfigFlags, EventTypeTableOfDevices, EventTypeNonhostConfig,
+		EventTypeNonhostInfo, EventTypeEFIVariableDriverConfig, EventTypeEFIVariableBoot, EventTypeEFIHandoffTables,
+		EventTypeEFIHandoffTables2, EventTypeEFISPDMFirmwareConfig},
+	2: {EventTypePostCode, EventTypePostCode2, EventTypeNonhostCode, EventTypeNonhostInfo,
+		EventTypeEFIBootServicesDriver, EventTypeEFIRuntimeServicesDriver, EventTypeEFIPlatformFirmwareBlob,
+		EventTypeEFIPlatformFirmwareBlob2, EventTypeEFISPDMFirmwareBlob},
+	3: {EventTypeNonhostConfig, EventTypeNonhostInfo, EventTypeEFIVariableDriverConfig, EventTypeEFISPDMFirmwareConfig},
+	4: {EventTypeCompactHash, EventTypeIPL, EventTypeEFIBootServicesApplication, EventTypeEFIBootServicesDriver,
+		EventTypeEFIRuntimeServicesDriver, EventTypeEFIAction},
+	5: {EventTypeIPLPartitionData, EventTypeOmitBootDeviceEvents, EventTypeEFIVariableBoot, EventTypeEFIGPTEvent,
+		EventTypeEFIAction},
+	7: {EventTypeEFIVariableDriverConfig, EventTypeEFIVariableAuthority, EventTypeEFIAction,
+		EventTypeEFISPDMDevicePolicy, EventTypeEFISPDMDeviceAuthority},
+}
+
+// pfpAnyPCREventTypes are the types of event that may be measured to any PCR.
+var pfpAnyPCREventTypes = []EventType{EventTypeNoAction, EventTypeSeparator, EventTypeAction, EventTypeEventTag}
+
+// pfpActionPCRs describes the PCR that each of the action strings defined by the TCG specifications are measured to.
+var pfpActionPCRs = map[Action]PCRIndex{
+	CallingInt19h:                   4,
+	ReturnedInt19h:                  4,
+	ReturnViaInt18h:                 4,
+	CallingEFIApplication:           4,
+	ReturningFromEFIApplication:     4,
+	ExitBootServicesInvocation:      5,
+	ExitBootServicesReturnedFailure: 5,
+	ExitBootServicesReturnedSuccess: 5,
+	UEFIDebugMode:                   7,
+	DMAProtectionDisabled:           7,
+}
+
+// pfpRequiredSecureBootVariables are the secure boot configuration variables that must be measured to PCR 7.
+var pfpRequiredSecureBootVariables = []struct {
+	guid EFIGUID
+	name string
+}{
+	{EFIGlobalVariableGuid, "SecureBoot"},
+	{EFIGlobalVariableGuid, "PK"},
+	{EFIGlobalVariableGuid, "KEK"},
+	{EFIImageSecurityDatabaseGuid, "db"},
+	{EFIImageSecurityDatabaseGuid, "dbx"},
+}
+
+func isEventTypeAllowedInPCR(pcr PCRIndex, eventType EventType) bool {
+	for _, t := range pfpAnyPCREventTypes {
+		if t == eventType {
+			return true
+		}
+	}
+	allowed, restricted := pfpAllowedEventTypes[pcr]
+	if !restricted {
+		return pcr <= 7
+	}
+	for _, t := range allowed {
+		if t == eventType {
+			return true
+		}
+	}
+	return false
+}
+
+// CheckPFPCompliance is a ValidationCheck that evaluates the measurements made to PCRs 0-7 against the rules in the TCG PC Client
+// Platform Firmware Profile Specification. It reports:
+//  - events with types that aren't permitted in the PCR that they are measured to.
+//  - EV_ACTION and EV_EFI_ACTION events with non-standard strings, or standard strings measured to the wrong PCR.
+//  - missing EV_S_CRTM_VERSION events in PCR 0, and missing measurements of the secure boot configuration in PCR 7 for UEFI logs.
+//  - measurements that occur in the wrong order, such as PCR 0 measurements before the StartupLocality event, boot applications
+//    loaded before the "Calling EFI Application from Boot Option" action, or the result of ExitBootServices being recorded before
+//    it is invoked.
+func CheckPFPCompliance(log *Log) (out []*ValidationFinding) {
+	isUEFI := log.Spec == SpecEFI_1_2 || log.Spec == SpecEFI_2
+
+	var (
+		seenPCR0Measurement     bool
+		seenSCRTMVersion        bool
+		seenCallingApplication  bool
+		seenExitBootServices    bool
+		seenSecureBootVariables = make(map[int]bool)
+	)
+
+	for _, e := range log.Events {
+		if e.PCRIndex > 7 {
+			continue
+		}
+
+		if !isEventTypeAllowedInPCR(e.PCRIndex, e.EventType) {
+			out = append(out, &ValidationFinding{Severity: SeverityWarning, Event: e,
+				Message: fmt.Sprintf("%v events are not expected in PCR %d", e.EventType, e.PCRIndex)})
+		}
+
+		switch d := e.Data.(type) {
+		case *StartupLocalityEventData:
+			if seenPCR0Measurement {
+				out = append(out, &ValidationFinding{Severity: SeverityError, Event: e,
+					Message: "the StartupLocality event appears after measurements to PCR 0"})
+			}
+		case *ActionEventData:
+			pcr, restricted := pfpActionPCRs[d.Action]
+			switch {
+			case !d.IsStandard():
+				out = append(out, &ValidationFinding{Severity: SeverityWarning, Event: e,
+					Message: fmt.Sprintf("the action string \"%s\" is not defined for %v events", d, e.EventType)})
+			case restricted && pcr != e.PCRIndex:
+				out = append(out, &ValidationFinding{Severity: SeverityError, Event: e,
+					Message: fmt.Sprintf("the \"%s\" action should be measured to PCR %d", d, pcr)})
+			}
+
+			switch d.Action {
+			case CallingEFIApplication:
+				seenCallingApplication = true
+			case ExitBootServicesInvocation:
+				seenExitBootServices = true
+			case ExitBootServicesReturnedFailure, ExitBootServicesReturnedSuccess:
+				if !seenExitBootServices {
+					out = append(out, &ValidationFinding{Severity: SeverityError, Event: e,
+						Message: "the result of ExitBootServices is recorded before the \"Exit Boot Services Invocation\" action"})
+				}
+			}
+		case *EFIVariableData:
+			if e.PCRIndex == 7 && e.EventType == EventTypeEFIVariableDriverConfig {
+				for i, v := range pfpRequiredSecureBootVariables {
+					if d.VariableName == v.guid && d.UnicodeName == v.name {
+						seenSecureBootVariables[i] = true
+					}
+				}
+			}
+		}
+
+		switch {
+		case e.PCRIndex == 0 && e.EventType != EventTypeNoAction:
+			seenPCR0Measurement = true
+			if e.EventType == EventTypeSCRTMVersion {
+				seenSCRTMVersion = true
+			}
+		case e.PCRIndex == 4 && e.EventType == EventTypeEFIBootServicesApplication && isUEFI && !seenCallingApplication:
+			out = append(out, &ValidationFinding{Severity: SeverityWarning, Event: e,
+				Message: "the application was loaded before the \"Calling EFI Application from Boot Option\" action"})
+		}
+	}
+
+	if !seenSCRTMVersion {
+		out = append(out, &ValidationFinding{Severity: SeverityError, Message: "there is no EV_S_CRTM_VERSION event in PCR 0"})
+	}
+	if isUEFI {
+		for i, v := range pfpRequiredSecureBootVariables {
+			if !seenSecureBootVariables[i] {
+				out = append(out, &ValidationFinding{Severity: SeverityError,
+					Message: fmt.Sprintf("there is no measurement of the %s variable in PCR 7", v.name)})
+			}
+		}
+	}
+
+	return out
+}
+
+type validationReportEvent struct {
+	Index     uint      `json:"index"`
+	PCRIndex  PCRIndex  `json:"pcr"`
+	EventType EventType `json:"event_type"`
+	TypeName  string    `json:"event_type_name"`
+}
+
+func newValidationReportEvent(e *Event) *validationReportEvent {
+	return &validationReportEvent{Index: e.Index, PCRIndex: e.PCRIndex, EventType: e.EventType, TypeName: e.EventType.String()}
+}
+
+type validationReportFinding struct {
+	Check    string                 `json:"check"`
+	Severity string                 `json:"severity"`
+	Event    *validationReportEvent `json:"event,omitempty"`
+	Message  string                 `json:"message"`
+}
+
+type validationReport struct {
+	Compliant bool                       `json:"compliant"`
+	Errors    int                        `json:"errors"`
+	Warnings  int                        `json:"warnings"`
+	Findings  []*validationReportFinding `json:"findings"`
+}
+
+// WriteValidationReport writes the supplied findings to w as a machine-readable JSON report. The report indicates that the log is
+// compliant if none of the findings have a severity of SeverityError.
+func WriteValidationReport(w io.Writer, findings []*ValidationFinding) error {
+	report := &validationReport{Findings: []*validationReportFinding{}}
+	for _, f := range findings {
+		switch f.Severity {
+		case SeverityError:
+			report.Errors++
+		case SeverityWarning:
+			report.Warnings++
+		}
+
+		rf := &validationReportFinding{Check: f.Check, Severity: f.Severity.String(), Message: f.Message}
+		if f.Event != nil {
+			rf.Event = newValidationReportEvent(f.Event)
+		}
+		report.Findings = append(report.Findings, rf)
+	}
+	report.Compliant = report.Errors == 0
+
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(report)
+}
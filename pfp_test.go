@@ -0,0 +1,209 @@
+// Copyright 2019 Canonical Ltd.
+// Licensed under the LGPLv3 with static-linking exception.
+// See LICENCE file for details.
+
+package tcglog
+
+import (
+	"bytes"
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+type testPFPEvent struct {
+	pcr       PCRIndex
+	eventType EventType
+	data      []byte
+}
+
+func makeTestPFPVariable(guid EFIGUID, name string) []byte {
+	varData := EFIVariableData{VariableName: guid, UnicodeName: name, VariableData: []byte{0x01}}
+	var b bytes.Buffer
+	varData.EncodeMeasuredBytes(&b)
+	return b.Bytes()
+}
+
+func makeTestPFPLog(t *testing.T, events []testPFPEvent) *Log {
+	b, err := NewLogBuilder(AlgorithmIdList{AlgorithmSha256}, nil)
+	if err != nil {
+		t.Fatalf("NewLogBuilder failed: %v", err)
+	}
+	for _, e := range events {
+		if e.eventType == EventTypeEFIBootServicesApplication {
+			digests := DigestMap{AlgorithmSha256: AlgorithmSha256.hash(nil)}
+			if _, err := b.AddEventWithDigests(e.pcr, e.eventType, digests, e.data); err != nil {
+				t.Fatalf("AddEventWithDigests failed: %v", err)
+			}
+			continue
+		}
+		if _, err := b.AddEvent(e.pcr, e.eventType, e.data); err != nil {
+			t.Fatalf("AddEvent failed: %v", err)
+		}
+	}
+	return b.Log()
+}
+
+func makeTestPFPEvents() []testPFPEvent {
+	events := []testPFPEvent{
+		{pcr: 0, eventType: EventTypeNoAction, data: append([]byte("StartupLocality\x00"), 3)},
+		{pcr: 0, eventType: EventTypeSCRTMVersion, data: []byte{0x31, 0x00}},
+		{pcr: 7, eventType: EventTypeEFIVariableDriverConfig, data: makeTestPFPVariable(EFIGlobalVariableGuid, "SecureBoot")},
+		{pcr: 7, eventType: EventTypeEFIVariableDriverConfig, data: makeTestPFPVariable(EFIGlobalVariableGuid, "PK")},
+		{pcr: 7, eventType: EventTypeEFIVariableDriverConfig, data: makeTestPFPVariable(EFIGlobalVariableGuid, "KEK")},
+		{pcr: 7, eventType: EventTypeEFIVariableDriverConfig, data: makeTestPFPVariable(EFIImageSecurityDatabaseGuid, "db")},
+		{pcr: 7, eventType: EventTypeEFIVariableDriverConfig, data: makeTestPFPVariable(EFIImageSecurityDatabaseGuid, "dbx")},
+		{pcr: 4, eventType: EventTypeEFIAction, data: []byte("Calling EFI Application from Boot Option")},
+	}
+	for pcr := PCRIndex(0); pcr <= 7; pcr++ {
+		events = append(events, testPFPEvent{pcr: pcr, eventType: EventTypeSeparator, data: []byte{0, 0, 0, 0}})
+	}
+	return append(events,
+		testPFPEvent{pcr: 4, eventType: EventTypeEFIBootServicesApplication,
+			data: makeTestImageLoadEventData("\\EFI\\ubuntu\\shimx64.efi")},
+		testPFPEvent{pcr: 5, eventType: EventTypeEFIAction, data: []byte("Exit Boot Services Invocation")},
+		testPFPEvent{pcr: 5, eventType: EventTypeEFIAction, data: []byte("Exit Boot Services Returned with Success")})
+}
+
+func TestCheckPFPCompliance(t *testing.T) {
+	for _, data := range []struct {
+		desc     string
+		modify   func([]testPFPEvent) []testPFPEvent
+		expected []string
+	}{
+		{desc: "Compliant", modify: func(events []testPFPEvent) []testPFPEvent { return events }},
+		{
+			desc: "MissingSCRTMVersion",
+			modify: func(events []testPFPEvent) []testPFPEvent {
+				return append(events[:1:1], events[2:]...)
+			},
+			expected: []string{"error: pfp-compliance: there is no EV_S_CRTM_VERSION event in PCR 0"},
+		},
+		{
+			desc: "MissingDbx",
+			modify: func(events []testPFPEvent) []testPFPEvent {
+				return append(events[:6:6], events[7:]...)
+			},
+			expected: []string{"error: pfp-compliance: there is no measurement of the dbx variable in PCR 7"},
+		},
+		{
+			desc: "UnexpectedEventType",
+			modify: func(events []testPFPEvent) []testPFPEvent {
+				return append(events, testPFPEvent{pcr: 2, eventType: EventTypeSCRTMVersion, data: []byte{0x31, 0x00}})
+			},
+			expected: []string{
+				"warning: pfp-compliance: event 1 in PCR 2 (type: EV_S_CRTM_VERSION): EV_S_CRTM_VERSION events are not expected " +
+					"in PCR 2"},
+		},
+		{
+			desc: "NonStandardAction",
+			modify: func(events []testPFPEvent) []testPFPEvent {
+				return append(events, testPFPEvent{pcr: 5, eventType: EventTypeEFIAction, data: []byte("foo")})
+			},
+			expected: []string{
+				"warning: pfp-compliance: event 3 in PCR 5 (type: EV_EFI_ACTION): the action string \"foo\" is not defined for " +
+					"EV_EFI_ACTION events"},
+		},
+		{
+			desc: "ActionWrongPCR",
+			modify: func(events []testPFPEvent) []testPFPEvent {
+				events[7].pcr = 5
+				return events
+			},
+			expected: []string{
+				"error: pfp-compliance: event 0 in PCR 5 (type: EV_EFI_ACTION): the \"Calling EFI Application from Boot Option\" " +
+					"action should be measured to PCR 4"},
+		},
+		{
+			desc: "LateStartupLocality",
+			modify: func(events []testPFPEvent) []testPFPEvent {
+				events[0], events[1] = events[1], events[0]
+				return events
+			},
+			expected: []string{
+				"error: pfp-compliance: event 2 in PCR 0 (type: EV_NO_ACTION): the StartupLocality event appears after " +
+					"measurements to PCR 0"},
+		},
+		{
+			desc: "ExitBootServicesOrder",
+			modify: func(events []testPFPEvent) []testPFPEvent {
+				n := len(events)
+				events[n-2], events[n-1] = events[n-1], events[n-2]
+				return events
+			},
+			expected: []string{
+				"error: pfp-compliance: event 1 in PCR 5 (type: EV_EFI_ACTION): the result of ExitBootServices is recorded before " +
+					"the \"Exit Boot Services Invocation\" action"},
+		},
+	} {
+		t.Run(data.desc, func(t *testing.T) {
+			log := makeTestPFPLog(t, data.modify(makeTestPFPEvents()))
+
+			v := new(Validator)
+			v.AddCheck("pfp-compliance", CheckPFPCompliance)
+
+			var findings []string
+			for _, f := range v.Validate(log) {
+				findings = append(findings, f.String())
+			}
+			if !reflect.DeepEqual(findings, data.expected) {
+				t.Errorf("Unexpected findings: %q", findings)
+			}
+		})
+	}
+}
+
+func TestWriteValidationReport(t *testing.T) {
+	events := makeTestPFPEvents()
+	log := makeTestPFPLog(t, append(events[:1:1], events[2:]...))
+	log.Events[len(log.Events)-1].Digests[AlgorithmSha256] = AlgorithmSha256.hash([]byte("foo"))
+
+	v := new(Validator)
+	v.AddCheck("digests", CheckDigests)
+	v.AddCheck("pfp-compliance", CheckPFPCompliance)
+
+	var b bytes.Buffer
+	if err := WriteValidationReport(&b, v.Validate(log)); err != nil {
+		t.Fatalf("WriteValidationReport failed: %v", err)
+	}
+
+	var report struct {
+		Compliant bool
+		Errors    int
+		Warnings  int
+		Findings  []struct {
+			Check    string
+			Severity string
+			Event    *struct {
+				Index         uint
+				PCR           uint32
+				EventType     uint32 `json:"event_type"`
+				EventTypeName string `json:"event_type_name"`
+			}
+			Message string
+		}
+	}
+	if err := json.Unmarshal(b.Bytes(), &report); err != nil {
+		t.Fatalf("Unmarshal failed: %v", err)
+	}
+
+	if report.Compliant || report.Errors != 2 || report.Warnings != 0 || len(report.Findings) != 2 {
+		t.Fatalf("Unexpected report: %s", b.String())
+	}
+	if report.Findings[0].Check != "pfp-compliance" || report.Findings[0].Severity != "error" || report.Findings[0].Event != nil {
+		t.Errorf("Unexpected first finding: %s", b.String())
+	}
+	f := report.Findings[1]
+	if f.Check != "digests" || f.Severity != "error" || f.Event == nil || f.Event.Index != 2 || f.Event.PCR != 5 ||
+		f.Event.EventType != uint32(EventTypeEFIAction) || f.Event.EventTypeName != "EV_EFI_ACTION" {
+		t.Errorf("Unexpected second finding: %s", b.String())
+	}
+
+	b.Reset()
+	if err := WriteValidationReport(&b, nil); err != nil {
+		t.Fatalf("WriteValidationReport failed: %v", err)
+	}
+	if b.String() != "{\n  \"compliant\": true,\n  \"errors\": 0,\n  \"warnings\": 0,\n  \"findings\": []\n}\n" {
+		t.Errorf("Unexpected empty report: %s", b.String())
+	}
+}
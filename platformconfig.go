@@ -0,0 +1,114 @@
+// Copyright 2019 Canonical Ltd.
+// Licensed under the LGPLv3 with static-linking exception.
+// See LICENCE file for details.
+
+package tcglog
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math"
+)
+
+// PlatformConfigFlagsFormat indicates the form of the data associated with an EV_PLATFORM_CONFIG_FLAGS event. The format of this
+// data is platform specific, so it is decoded on a best-effort basis.
+type PlatformConfigFlagsFormat int
+
+const (
+	// PlatformConfigFlagsASCII indicates that the data is an ASCII string.
+	PlatformConfigFlagsASCII PlatformConfigFlagsFormat = iota
+
+	// PlatformConfigFlagsUTF16 indicates that the data is a little-endian UTF-16 string.
+	PlatformConfigFlagsUTF16
+
+	// PlatformConfigFlagsInteger indicates that the data is a little-endian integer bitmask of 1, 2, 4 or 8 bytes.
+	PlatformConfigFlagsInteger
+)
+
+// PlatformConfigFlagsEventData is the event data associated with an EV_PLATFORM_CONFIG_FLAGS event. Data that isn't in one of
+// the recognized forms is not decoded.
+type PlatformConfigFlagsEventData struct {
+	data   []byte
+	Format PlatformConfigFlagsFormat
+	Str    string // The decoded string for the PlatformConfigFlagsASCII and PlatformConfigFlagsUTF16 formats
+	Flags  uint64 // The decoded bitmask for the PlatformConfigFlagsInteger format
+}
+
+func (e *PlatformConfigFlagsEventData) String() string {
+	switch e.Format {
+	case PlatformConfigFlagsInteger:
+		return fmt.Sprintf("0x%0*x", len(e.data)*2, e.Flags)
+	default:
+		return e.Str
+	}
+}
+
+func (e *PlatformConfigFlagsEventData) Bytes() []byte {
+	return e.data
+}
+
+// EncodeTo encodes this event data in to the form in which it appears in the event log. If the exported fields haven't been
+// modified since the event data was decoded, the original bytes are written. Otherwise, strings are written with a NUL
+// terminator, and a bitmask is written with the same size as the original data if it fits, or with the smallest size that it
+// fits in.
+func (e *PlatformConfigFlagsEventData) EncodeTo(w io.Writer) error {
+	orig, _ := decodeEventDataPlatformConfigFlags(e.data).(*PlatformConfigFlagsEventData)
+	if orig != nil && orig.Format == e.Format && orig.Str == e.Str && orig.Flags == e.Flags {
+		_, err := w.Write(e.data)
+		return err
+	}
+
+	switch e.Format {
+	case PlatformConfigFlagsASCII, PlatformConfigFlagsUTF16:
+		_, err := w.Write(encodePrintableString(e.Str, e.Format == PlatformConfigFlagsUTF16))
+		return err
+	case PlatformConfigFlagsInteger:
+		var b [8]byte
+		binary.LittleEndian.PutUint64(b[:], e.Flags)
+		size := 8
+		switch {
+		case orig != nil && orig.Format == PlatformConfigFlagsInteger && e.Flags>>(uint(len(e.data))*8) == 0:
+			size = len(e.data)
+		case e.Flags <= math.MaxUint8:
+			size = 1
+		case e.Flags <= math.MaxUint16:
+			size = 2
+		case e.Flags <= math.MaxUint32:
+			size = 4
+		}
+		_, err := w.Write(b[:size])
+		return err
+	default:
+		return fmt.Errorf("invalid format (%d)", e.Format)
+	}
+}
+
+// https://trustedcomputinggroup.org/wp-content/uploads/TCG_PCClientSpecPlat_TPM_2p0_1p04_pub.pdf
+//  (section 9.4.1 "Event Types")
+func decodeEventDataPlatformConfigFlags(data []byte) EventData {
+	// The contents of this event are platform specific. Firmware has been observed recording ASCII strings, UTF-16 strings
+	// and small bitmasks, so try each of these in turn. Some firmware includes a NULL terminator and some doesn't.
+	if str, isUTF16, ok := decodePrintableString(data); ok {
+		format := PlatformConfigFlagsASCII
+		if isUTF16 {
+			format = PlatformConfigFlagsUTF16
+		}
+		return &PlatformConfigFlagsEventData{data: data, Format: format, Str: str}
+	}
+
+	var flags uint64
+	switch len(data) {
+	case 1:
+		flags = uint64(data[0])
+	case 2:
+		flags = uint64(binary.LittleEndian.Uint16(data))
+	case 4:
+		flags = uint64(binary.LittleEndian.Uint32(data))
+	case 8:
+		flags = binary.LittleEndian.Uint64(data)
+	default:
+		return nil
+	}
+	return &PlatformConfigFlagsEventData{data: data, Format: PlatformConfigFlagsInteger, Flags: flags}
+}
@@ -0,0 +1,82 @@
+// Copyright 2019 Canonical Ltd.
+// Licensed under the LGPLv3 with static-linking exception.
+// See LICENCE file for details.
+
+package tcglog
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestDecodeEventDataPlatformConfigFlags(t *testing.T) {
+	for _, data := range []struct {
+		desc   string
+		data   []byte
+		format PlatformConfigFlagsFormat
+		str    string
+		flags  uint64
+	}{
+		{
+			desc:   "ASCII",
+			data:   []byte("SecureBoot=1"),
+			format: PlatformConfigFlagsASCII,
+			str:    "SecureBoot=1",
+		},
+		{
+			desc:   "ASCIINullTerminated",
+			data:   []byte("SecureBoot=1\x00"),
+			format: PlatformConfigFlagsASCII,
+			str:    "SecureBoot=1",
+		},
+		{
+			desc:   "UTF16",
+			data:   []byte{'O', 0, 'K', 0, 0, 0},
+			format: PlatformConfigFlagsUTF16,
+			str:    "OK",
+		},
+		{
+			desc:   "Integer32",
+			data:   []byte{0x05, 0x00, 0x00, 0x80},
+			format: PlatformConfigFlagsInteger,
+			str:    "0x80000005",
+			flags:  0x80000005,
+		},
+		{
+			desc:   "Integer8",
+			data:   []byte{0x01},
+			format: PlatformConfigFlagsInteger,
+			str:    "0x01",
+			flags:  1,
+		},
+	} {
+		t.Run(data.desc, func(t *testing.T) {
+			d, ok := DecodeEventData(1, EventTypePlatformConfigFlags, DigestMap{}, data.data, nil).(*PlatformConfigFlagsEventData)
+			if !ok {
+				t.Fatalf("Unexpected event data type")
+			}
+			if d.Format != data.format {
+				t.Errorf("Unexpected format: %d", d.Format)
+			}
+			if d.String() != data.str {
+				t.Errorf("Unexpected string: %s", d)
+			}
+			if d.Flags != data.flags {
+				t.Errorf("Unexpected flags: %x", d.Flags)
+			}
+
+			var buf bytes.Buffer
+			if err := d.EncodeTo(&buf); err != nil {
+				t.Fatalf("EncodeTo failed: %v", err)
+			}
+			if !bytes.Equal(buf.Bytes(), data.data) {
+				t.Errorf("Unexpected encoding: %x", buf.Bytes())
+			}
+		})
+	}
+
+	d := DecodeEventData(1, EventTypePlatformConfigFlags, DigestMap{}, []byte{0xff, 0x01, 0x02}, nil)
+	if _, ok := d.(*opaqueEventData); !ok {
+		t.Errorf("Unexpected event data type: %T", d)
+	}
+}
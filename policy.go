@@ -0,0 +1,324 @@
+// Copyright 2019 Canonical Ltd.
+// Licensed under the LGPLv3 with static-linking exception.
+// See LICENCE file for details.
+
+package tcglog
+
+import (
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"golang.org/x/xerrors"
+)
+
+// PolicyRuleKind describes how a PolicyRule constrains the events selected by it.
+type PolicyRuleKind string
+
+const (
+	// PolicyForbid indicates that the log must not contain any events that are selected by the rule.
+	PolicyForbid PolicyRuleKind = "forbid"
+
+	// PolicyRequire indicates that the log must contain at least one event that is selected by the rule.
+	PolicyRequire PolicyRuleKind = "require"
+
+	// PolicyAllowOnly indicates that every event selected by the rule must also be selected by one of the rule's Allow
+	// selectors.
+	PolicyAllowOnly PolicyRuleKind = "allow-only"
+)
+
+// EventSelector selects events from a log. An event is selected if it matches every field that is set, so the zero value
+// selects every event.
+type EventSelector struct {
+	PCRs []PCRIndex `json:"pcrs,omitempty"`
+
+	// Types is a list of event types, each of which is either a name such as "EV_EFI_ACTION" or a number.
+	Types []string `json:"types,omitempty"`
+
+	// Data is a regular expression that must match the string representation of the event data.
+	Data string `json:"data,omitempty"`
+
+	// Digest is a hexadecimal digest that must match the event digest for one of the algorithms in the log.
+	Digest string `json:"digest,omitempty"`
+
+	// Authority is a regular expression that must match the subject of the X.509 certificate measured by an
+	// EV_EFI_VARIABLE_AUTHORITY event. Events that don't measure a certificate aren't selected.
+	Authority string `json:"authority,omitempty"`
+}
+
+type compiledEventSelector struct {
+	pcrs      map[PCRIndex]bool
+	types     map[EventType]bool
+	data      *regexp.Regexp
+	digest    Digest
+	authority *regexp.Regexp
+}
+
+var policyEventTypes = func() map[string]EventType {
+	m := make(map[string]EventType)
+	add := func(start, end uint32) {
+		for t := start; t <= end; t++ {
+			if name := EventType(t).String(); strings.HasPrefix(name, "EV_") {
+				m[name] = EventType(t)
+			}
+		}
+	}
+	add(0, 0x20)
+	add(0x80000000, 0x800000ff)
+	return m
+}()
+
+func parsePolicyEventType(s string) (EventType, error) {
+	if t, ok := policyEventTypes[s]; ok {
+		return t, nil
+	}
+	t, err := strconv.ParseUint(s, 0, 32)
+	if err != nil {
+		return 0, fmt.Errorf("unrecognized event type \"%s\"", s)
+	}
+	return EventType(t), nil
+}
+
+func (s *EventSelector) compile() (*compiledEventSelector, error) {
+	out := new(compiledEventSelector)
+	if len(s.PCRs) > 0 {
+		out.pcrs = make(map[PCRIndex]bool)
+		for _, pcr := range s.PCRs {
+			out.pcrs[pcr] = true
+		}
+	}
+	if len(s.Types) > 0 {
+		out.types = make(map[EventType]bool)
+		for _, name := range s.Types {
+			t, err := parsePolicyEventType(name)
+			if err != nil {
+				return nil, err
+			}
+			out.types[t] = true
+		}
+	}
+	if s.Data != "" {
+		re, err := regexp.Compile(s.Data)
+		if err != nil {
+			return nil, xerrors.Errorf("invalid data pattern: %w", err)
+		}
+		out.data = re
+	}
+	if s.Digest != "" {
+		digest, err := hex.DecodeString(strings.TrimPrefix(s.Digest, "0x"))
+		if err != nil {
+			return nil, xerrors.Errorf("invalid digest: %w", err)
+		}
+		out.digest = digest
+	}
+	if s.Authority != "" {
+		re, err := regexp.Compile(s.Authority)
+		if err != nil {
+			return nil, xerrors.Errorf("invalid authority pattern: %w", err)
+		}
+		out.authority = re
+	}
+	return out, nil
+}
+
+func (s *compiledEventSelector) matches(e *Event) bool {
+	if s.pcrs != nil && !s.pcrs[e.PCRIndex] {
+		return false
+	}
+	if s.types != nil && !s.types[e.EventType] {
+		return false
+	}
+	if s.data != nil && !s.data.MatchString(e.Data.String()) {
+		return false
+	}
+	if s.digest != nil {
+		found := false
+		for _, d := range e.Digests {
+			if bytes.Equal(d, s.digest) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	if s.authority != nil {
+		d, ok := e.Data.(*EFIVariableData)
+		if !ok || e.EventType != EventTypeEFIVariableAuthority {
+			return false
+		}
+		sig, err := d.AuthoritySignature()
+		if err != nil {
+			return false
+		}
+		cert, err := sig.Certificate()
+		if err != nil || !s.authority.MatchString(cert.Subject.String()) {
+			return false
+		}
+	}
+	return true
+}
+
+// PolicyRule is a single rule in a Policy.
+type PolicyRule struct {
+	Name   string          `json:"name"`
+	Kind   PolicyRuleKind  `json:"kind"`
+	Events EventSelector   `json:"events"`          // The events that this rule applies to
+	Allow  []EventSelector `json:"allow,omitempty"` // The permitted events for a PolicyAllowOnly rule
+}
+
+// Policy is a set of declarative rules over the events in a log, such as "PCR 7 must only contain these authorities" or "the
+// log must not contain the 'UEFI Debug Mode' action". A policy can be read from JSON with ReadPolicy, eg:
+//  {
+//    "rules": [
+//      {"name": "no-debug", "kind": "forbid", "events": {"types": ["EV_EFI_ACTION"], "data": "^UEFI Debug Mode$"}},
+//      {"name": "authorities", "kind": "allow-only", "events": {"pcrs": [7], "types": ["EV_EFI_VARIABLE_AUTHORITY"]},
+//       "allow": [{"authority": "CN=Microsoft Corporation UEFI CA 2011"}]}
+//    ]
+//  }
+type Policy struct {
+	Rules []*PolicyRule `json:"rules"`
+}
+
+// ReadPolicy reads a JSON encoded policy from r.
+func ReadPolicy(r io.Reader) (*Policy, error) {
+	var p Policy
+	dec := json.NewDecoder(r)
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&p); err != nil {
+		return nil, xerrors.Errorf("cannot decode policy: %w", err)
+	}
+	for _, rule := range p.Rules {
+		if _, _, err := rule.compile(); err != nil {
+			return nil, err
+		}
+	}
+	return &p, nil
+}
+
+func (r *PolicyRule) compile() (events *compiledEventSelector, allow []*compiledEventSelector, err error) {
+	switch r.Kind {
+	case PolicyForbid, PolicyRequire:
+		if len(r.Allow) > 0 {
+			return nil, nil, fmt.Errorf("invalid rule \"%s\": allow is only valid for %s rules", r.Name, PolicyAllowOnly)
+		}
+	case PolicyAllowOnly:
+	default:
+		return nil, nil, fmt.Errorf("invalid rule \"%s\": unrecognized kind \"%s\"", r.Name, r.Kind)
+	}
+
+	events, err = r.Events.compile()
+	if err != nil {
+		return nil, nil, xerrors.Errorf("invalid rule \"%s\": %w", r.Name, err)
+	}
+	for i := range r.Allow {
+		s, err := r.Allow[i].compile()
+		if err != nil {
+			return nil, nil, xerrors.Errorf("invalid rule \"%s\": allow %d: %w", r.Name, i, err)
+		}
+		allow = append(allow, s)
+	}
+	return events, allow, nil
+}
+
+// PolicyRuleResult is the result of evaluating a single PolicyRule against a log.
+type PolicyRuleResult struct {
+	Rule       *PolicyRule
+	Passed     bool
+	Violations []*Event // The events that violate the rule. This is empty for a failed PolicyRequire rule
+}
+
+func (r *PolicyRuleResult) String() string {
+	if r.Passed {
+		return fmt.Sprintf("PASS: %s", r.Rule.Name)
+	}
+
+	var b bytes.Buffer
+	switch r.Rule.Kind {
+	case PolicyForbid:
+		fmt.Fprintf(&b, "FAIL: %s: the log contains forbidden events", r.Rule.Name)
+	case PolicyRequire:
+		fmt.Fprintf(&b, "FAIL: %s: the log doesn't contain a required event", r.Rule.Name)
+	case PolicyAllowOnly:
+		fmt.Fprintf(&b, "FAIL: %s: the log contains events that aren't allowed", r.Rule.Name)
+	}
+	for _, e := range r.Violations {
+		fmt.Fprintf(&b, "\n\t- event %d in PCR %d (type: %s): %s", e.Index, e.PCRIndex, e.EventType, e.Data)
+	}
+	return b.String()
+}
+
+// PolicyResult is the result of evaluating a Policy against a log.
+type PolicyResult struct {
+	Rules []*PolicyRuleResult
+}
+
+// Passed indicates whether the log satisfies every rule in the policy.
+func (r *PolicyResult) Passed() bool {
+	for _, rule := range r.Rules {
+		if !rule.Passed {
+			return false
+		}
+	}
+	return true
+}
+
+func (r *PolicyResult) String() string {
+	var results []string
+	for _, rule := range r.Rules {
+		results = append(results, rule.String())
+	}
+	return strings.Join(results, "\n")
+}
+
+// Evaluate evaluates the supplied log against this policy, returning the result for each rule in the order in which they appear
+// in the policy. An error is returned if any of the rules are invalid.
+func (p *Policy) Evaluate(log *Log) (*PolicyResult, error) {
+	result := new(PolicyResult)
+	for _, rule := range p.Rules {
+		events, allow, err := rule.compile()
+		if err != nil {
+			return nil, err
+		}
+
+		r := &PolicyRuleResult{Rule: rule}
+		found := false
+		for _, e := range log.Events {
+			if !events.matches(e) {
+				continue
+			}
+			found = true
+
+			switch rule.Kind {
+			case PolicyForbid:
+				r.Violations = append(r.Violations, e)
+			case PolicyAllowOnly:
+				allowed := false
+				for _, s := range allow {
+					if s.matches(e) {
+						allowed = true
+						break
+					}
+				}
+				if !allowed {
+					r.Violations = append(r.Violations, e)
+				}
+			}
+		}
+
+		switch rule.Kind {
+		case PolicyRequire:
+			r.Passed = found
+		default:
+			r.Passed = len(r.Violations) == 0
+		}
+		result.Rules = append(result.Rules, r)
+	}
+	return result, nil
+}
@@ -0,0 +1,148 @@
+// Copyright 2019 Canonical Ltd.
+// Licensed under the LGPLv3 with static-linking exception.
+// See LICENCE file for details.
+
+package tcglog
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestPolicy(t *testing.T) {
+	owner := MakeEFIGUID(0x77fa9abd, 0x0359, 0x4d32, 0xbd60, [...]uint8{0x28, 0xf4, 0xe7, 0x8f, 0x78, 0x4b})
+	authority := func(cert []byte) *Event {
+		varData := EFIVariableData{VariableName: EFIImageSecurityDatabaseGuid, UnicodeName: "db",
+			VariableData: append(owner[:], cert...)}
+		var b bytes.Buffer
+		varData.EncodeMeasuredBytes(&b)
+		return makeTestEvent(7, EventTypeEFIVariableAuthority, b.Bytes(), AlgorithmSha256)
+	}
+
+	trusted := authority(makeTestCertificate(t, "Trusted CA"))
+	untrusted := authority(makeTestCertificate(t, "Untrusted CA"))
+	debug := makeTestEvent(7, EventTypeEFIAction, []byte("UEFI Debug Mode"), AlgorithmSha256)
+	separator := makeTestEvent(7, EventTypeSeparator, []byte{0, 0, 0, 0}, AlgorithmSha256)
+	log := NewLog([]*Event{debug, trusted, untrusted, separator})
+
+	policy, err := ReadPolicy(strings.NewReader(fmt.Sprintf(`{
+  "rules": [
+    {"name": "no-debug", "kind": "forbid", "events": {"types": ["EV_EFI_ACTION"], "data": "^UEFI Debug Mode$"}},
+    {"name": "authorities", "kind": "allow-only", "events": {"pcrs": [7], "types": ["EV_EFI_VARIABLE_AUTHORITY"]},
+     "allow": [{"authority": "CN=Trusted CA"}]},
+    {"name": "authorities-by-digest", "kind": "allow-only", "events": {"types": ["0x800000e0"]},
+     "allow": [{"digest": "%x"}, {"digest": "%x"}]},
+    {"name": "separator", "kind": "require", "events": {"pcrs": [7], "types": ["EV_SEPARATOR"]}},
+    {"name": "grub", "kind": "require", "events": {"pcrs": [8]}}
+  ]
+}`, trusted.Digests[AlgorithmSha256], untrusted.Digests[AlgorithmSha256])))
+	if err != nil {
+		t.Fatalf("ReadPolicy failed: %v", err)
+	}
+
+	result, err := policy.Evaluate(log)
+	if err != nil {
+		t.Fatalf("Evaluate failed: %v", err)
+	}
+	if result.Passed() {
+		t.Errorf("Expected the policy to fail")
+	}
+
+	for i, expected := range []struct {
+		passed     bool
+		violations []*Event
+	}{
+		{violations: []*Event{debug}},
+		{violations: []*Event{untrusted}},
+		{passed: true},
+		{passed: true},
+		{},
+	} {
+		r := result.Rules[i]
+		if r.Passed != expected.passed || len(r.Violations) != len(expected.violations) {
+			t.Errorf("Unexpected result for rule %d: %s", i, r)
+			continue
+		}
+		for j, e := range expected.violations {
+			if r.Violations[j] != e {
+				t.Errorf("Unexpected violation %d for rule %d", j, i)
+			}
+		}
+	}
+
+	if result.Rules[0].String() != "FAIL: no-debug: the log contains forbidden events\n"+
+		"\t- event 0 in PCR 7 (type: EV_EFI_ACTION): UEFI Debug Mode" {
+		t.Errorf("Unexpected string: %s", result.Rules[0])
+	}
+	if result.Rules[4].String() != "FAIL: grub: the log doesn't contain a required event" {
+		t.Errorf("Unexpected string: %s", result.Rules[4])
+	}
+
+	policy.Rules = policy.Rules[2:4]
+	result, err = policy.Evaluate(log)
+	if err != nil {
+		t.Fatalf("Evaluate failed: %v", err)
+	}
+	if !result.Passed() || result.String() != "PASS: authorities-by-digest\nPASS: separator" {
+		t.Errorf("Unexpected result: %s", result)
+	}
+}
+
+func TestPolicyGPTEvent(t *testing.T) {
+	policy, err := ReadPolicy(strings.NewReader(`{"rules": [{"name": "gpt", "kind": "require", "events": {"pcrs": [5], "types": ["EV_EFI_GPT_EVENT"]}}]}`))
+	if err != nil {
+		t.Fatalf("ReadPolicy failed: %v", err)
+	}
+
+	log := NewLog([]*Event{makeTestEvent(5, EventTypeEFIGPTEvent, []byte{1, 2, 3, 4}, AlgorithmSha256)})
+	result, err := policy.Evaluate(log)
+	if err != nil {
+		t.Fatalf("Evaluate failed: %v", err)
+	}
+	if !result.Passed() {
+		t.Errorf("Unexpected result: %s", result)
+	}
+}
+
+func TestReadPolicyErrors(t *testing.T) {
+	for _, data := range []struct {
+		desc   string
+		policy string
+		err    string
+	}{
+		{
+			desc:   "UnknownField",
+			policy: `{"rules": [{"name": "a", "kind": "forbid", "event": {}}]}`,
+			err:    "cannot decode policy: json: unknown field \"event\"",
+		},
+		{
+			desc:   "InvalidKind",
+			policy: `{"rules": [{"name": "a", "kind": "deny"}]}`,
+			err:    "invalid rule \"a\": unrecognized kind \"deny\"",
+		},
+		{
+			desc:   "UnexpectedAllow",
+			policy: `{"rules": [{"name": "a", "kind": "forbid", "allow": [{}]}]}`,
+			err:    "invalid rule \"a\": allow is only valid for allow-only rules",
+		},
+		{
+			desc:   "InvalidType",
+			policy: `{"rules": [{"name": "a", "kind": "forbid", "events": {"types": ["EV_FOO"]}}]}`,
+			err:    "invalid rule \"a\": unrecognized event type \"EV_FOO\"",
+		},
+		{
+			desc:   "InvalidAllowPattern",
+			policy: `{"rules": [{"name": "a", "kind": "allow-only", "allow": [{"data": "("}]}]}`,
+			err:    "invalid rule \"a\": allow 0: invalid data pattern: error parsing regexp: missing closing ): `(`",
+		},
+	} {
+		t.Run(data.desc, func(t *testing.T) {
+			_, err := ReadPolicy(strings.NewReader(data.policy))
+			if err == nil || err.Error() != data.err {
+				t.Errorf("Unexpected error: %v", err)
+			}
+		})
+	}
+}
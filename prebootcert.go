@@ -0,0 +1,94 @@
+// Copyright 2019 Canonical Ltd.
+// Licensed under the LGPLv3 with static-linking exception.
+// See LICENCE file for details.
+
+package tcglog
+
+import (
+	"bytes"
+	"crypto/x509"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// PrebootCertEventData is the event data associated with an EV_PREBOOT_CERT event, which records certificate material that is
+// measured before boot. Some platforms record a bare DER encoded X.509 certificate and others embed one in a vendor specific
+// structure.
+type PrebootCertEventData struct {
+	data        []byte
+	certOffset  int
+	certLen     int
+	Certificate *x509.Certificate // The X.509 certificate in the event data
+}
+
+func (e *PrebootCertEventData) String() string {
+	return fmt.Sprintf("PrebootCert{ %s }", e.CertificateInfo())
+}
+
+func (e *PrebootCertEventData) Bytes() []byte {
+	return e.data
+}
+
+// EncodeTo encodes this event data in to the form in which it appears in the event log. A certificate that is embedded in other
+// data is replaced in the original data, and so it can only be replaced with a certificate of the same length.
+func (e *PrebootCertEventData) EncodeTo(w io.Writer) error {
+	if e.Certificate == nil {
+		return errors.New("no certificate")
+	}
+	if !e.IsEmbedded() {
+		_, err := w.Write(e.Certificate.Raw)
+		return err
+	}
+	if len(e.Certificate.Raw) != e.certLen {
+		return errors.New("cannot replace an embedded certificate with one of a different length")
+	}
+
+	var b bytes.Buffer
+	b.Write(e.data[:e.certOffset])
+	b.Write(e.Certificate.Raw)
+	b.Write(e.data[e.certOffset+e.certLen:])
+	_, err := b.WriteTo(w)
+	return err
+}
+
+// CertificateInfo returns a summary of the certificate in the event data.
+func (e *PrebootCertEventData) CertificateInfo() *X509CertificateInfo {
+	return newX509CertificateInfo(e.Certificate)
+}
+
+// IsEmbedded indicates whether the certificate is embedded in other data, rather than the event data being a bare certificate.
+func (e *PrebootCertEventData) IsEmbedded() bool {
+	return e.certOffset != 0 || e.certLen != len(e.data)
+}
+
+// findEmbeddedCertificate searches the supplied data for a DER encoded X.509 certificate with a 2-byte length, returning the
+// certificate and its offset.
+func findEmbeddedCertificate(data []byte) (*x509.Certificate, int) {
+	for i := 0; i+4 <= len(data); i++ {
+		if data[i] != 0x30 || data[i+1] != 0x82 {
+			continue
+		}
+		n := 4 + int(binary.BigEndian.Uint16(data[i+2:]))
+		if i+n > len(data) {
+			continue
+		}
+		if cert, err := x509.ParseCertificate(data[i : i+n]); err == nil {
+			return cert, i
+		}
+	}
+	return nil, 0
+}
+
+// https://trustedcomputinggroup.org/wp-content/uploads/TCG_PCClientImplementation_1-21_1_00.pdf
+//  (section 11.3.1 "Event Types")
+func decodeEventDataPrebootCert(data []byte) EventData {
+	if cert, err := x509.ParseCertificate(data); err == nil {
+		return &PrebootCertEventData{data: data, certLen: len(cert.Raw), Certificate: cert}
+	}
+	if cert, offset := findEmbeddedCertificate(data); cert != nil {
+		return &PrebootCertEventData{data: data, certOffset: offset, certLen: len(cert.Raw), Certificate: cert}
+	}
+	return nil
+}
@@ -0,0 +1,50 @@
+// Copyright 2019 Canonical Ltd.
+// Licensed under the LGPLv3 with static-linking exception.
+// See LICENCE file for details.
+
+package tcglog
+
+import (
+	"testing"
+)
+
+func TestDecodeEventDataPrebootCert(t *testing.T) {
+	cert := makeTestCertificate(t, "Preboot Test")
+
+	for _, data := range []struct {
+		desc     string
+		data     []byte
+		embedded bool
+	}{
+		{
+			desc: "Bare",
+			data: cert,
+		},
+		{
+			desc:     "Embedded",
+			data:     append(append([]byte{0x01, 0x00, 0x00, 0x00, 0x30, 0x00}, cert...), 0xff, 0xff),
+			embedded: true,
+		},
+	} {
+		t.Run(data.desc, func(t *testing.T) {
+			d, ok := DecodeEventData(0, EventTypePrebootCert, DigestMap{}, data.data, nil).(*PrebootCertEventData)
+			if !ok {
+				t.Fatalf("Unexpected event data type")
+			}
+			if d.Certificate.Subject.CommonName != "Preboot Test" {
+				t.Errorf("Unexpected certificate subject: %s", d.Certificate.Subject)
+			}
+			if d.IsEmbedded() != data.embedded {
+				t.Errorf("Unexpected IsEmbedded result")
+			}
+			if d.CertificateInfo().Subject != "CN=Preboot Test" {
+				t.Errorf("Unexpected certificate info: %s", d.CertificateInfo())
+			}
+		})
+	}
+
+	d := DecodeEventData(0, EventTypePrebootCert, DigestMap{}, []byte{0x30, 0x82, 0x00, 0x01, 0x00}, nil)
+	if _, ok := d.(*opaqueEventData); !ok {
+		t.Errorf("Unexpected event data type: %T", d)
+	}
+}
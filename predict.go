@@ -0,0 +1,182 @@
+// Copyright 2019 Canonical Ltd.
+// Licensed under the LGPLv3 with static-linking exception.
+// See LICENCE file for details.
+
+package tcglog
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"golang.org/x/xerrors"
+)
+
+// PredictedFile describes a boot asset that will be replaced before the next boot.
+type PredictedFile struct {
+	Path string `json:"path"` // The path of the asset as recorded in the log, eg, "/EFI/ubuntu/shimx64.efi"
+	File string `json:"file"` // The path of the file containing the new contents of the asset
+}
+
+// PredictedCommand describes a GRUB command that will be replaced before the next boot.
+type PredictedCommand struct {
+	Old string `json:"old"`
+	New string `json:"new"`
+}
+
+// PredictedVariable describes an EFI variable that will be changed before the next boot.
+type PredictedVariable struct {
+	Name string `json:"name"`
+	GUID string `json:"guid,omitempty"` // The GUID of the variable, which can be omitted if the name is unique in the log
+	File string `json:"file,omitempty"` // The file containing the new value, or empty if the variable will be deleted
+}
+
+// PredictedChanges describes the intended changes to the boot assets and configuration of a machine, which can be applied to a
+// Simulation in order to compute the PCR values for the next boot before rebooting (eg, to pre-compute a sealing policy). It
+// can be read from JSON with ReadPredictedChanges, eg:
+//  {
+//    "efi-images": [{"path": "/EFI/ubuntu/shimx64.efi", "file": "shimx64.efi.new"}],
+//    "grub-files": [{"path": "/EFI/ubuntu/grub.cfg", "file": "grub.cfg.new"}],
+//    "grub-commands": [{"old": "linux /vmlinuz-5.4.0-42-generic", "new": "linux /vmlinuz-5.4.0-45-generic"}],
+//    "kernel-cmdline": "/vmlinuz-5.4.0-45-generic root=/dev/sda1 ro quiet",
+//    "efi-variables": [{"name": "dbx", "file": "dbx.esl"}]
+//  }
+// A new kernel is described by the image load (if it is verified by shim), the file measured by GRUB, the GRUB command that
+// loads it and the kernel command line.
+type PredictedChanges struct {
+	EFIImages     []*PredictedFile     `json:"efi-images"`
+	GrubFiles     []*PredictedFile     `json:"grub-files"`
+	GrubCommands  []*PredictedCommand  `json:"grub-commands"`
+	KernelCmdline *string              `json:"kernel-cmdline"`
+	EFIVariables  []*PredictedVariable `json:"efi-variables"`
+
+	dir string
+}
+
+// ReadPredictedChanges reads a JSON encoded description of the intended changes from r. Relative file paths are interpreted
+// relative to dir.
+func ReadPredictedChanges(r io.Reader, dir string) (*PredictedChanges, error) {
+	var changes PredictedChanges
+	dec := json.NewDecoder(r)
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&changes); err != nil {
+		return nil, xerrors.Errorf("cannot decode changes: %w", err)
+	}
+	changes.dir = dir
+	return &changes, nil
+}
+
+func (c *PredictedChanges) path(path string) string {
+	if filepath.IsAbs(path) {
+		return path
+	}
+	return filepath.Join(c.dir, path)
+}
+
+// parseEFIGUID parses a GUID in the registry format, with or without braces.
+func parseEFIGUID(s string) (EFIGUID, error) {
+	var a uint32
+	var b, c, d uint16
+	var e uint64
+	if _, err := fmt.Sscanf(strings.Trim(s, "{}"), "%08x-%04x-%04x-%04x-%012x", &a, &b, &c, &d, &e); err != nil {
+		return EFIGUID{}, fmt.Errorf("invalid GUID \"%s\"", s)
+	}
+	var node [6]uint8
+	for i := range node {
+		node[i] = uint8(e >> uint(40-i*8))
+	}
+	return MakeEFIGUID(a, b, c, d, node), nil
+}
+
+// variableGUID returns the GUID of the variable with the specified name from the log, which must be unique.
+func (s *Simulation) variableGUID(name string) (guid EFIGUID, err error) {
+	found := false
+	for _, e := range s.base.Events {
+		d, ok := e.Data.(*EFIVariableData)
+		if !ok || d.UnicodeName != name {
+			continue
+		}
+		if found && d.VariableName != guid {
+			return EFIGUID{}, fmt.Errorf("more than one variable named %s is measured", name)
+		}
+		guid = d.VariableName
+		found = true
+	}
+	if !found {
+		return EFIGUID{}, fmt.Errorf("no measurement of a variable named %s in the log", name)
+	}
+	return guid, nil
+}
+
+func (s *Simulation) replaceEFIImageFile(path, file string) error {
+	f, err := os.Open(file)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		return err
+	}
+	return s.ReplaceEFIImage(path, f, info.Size())
+}
+
+// ApplyChanges applies the supplied changes to this simulation.
+func (s *Simulation) ApplyChanges(changes *PredictedChanges) error {
+	for _, i := range changes.EFIImages {
+		if err := s.replaceEFIImageFile(i.Path, changes.path(i.File)); err != nil {
+			return xerrors.Errorf("cannot replace EFI image %s: %w", i.Path, err)
+		}
+	}
+
+	for _, f := range changes.GrubFiles {
+		contents, err := ioutil.ReadFile(changes.path(f.File))
+		if err != nil {
+			return xerrors.Errorf("cannot replace GRUB file %s: %w", f.Path, err)
+		}
+		if err := s.ReplaceGrubFile(f.Path, contents); err != nil {
+			return xerrors.Errorf("cannot replace GRUB file %s: %w", f.Path, err)
+		}
+	}
+
+	for _, c := range changes.GrubCommands {
+		if err := s.ReplaceGrubCommand(c.Old, c.New); err != nil {
+			return err
+		}
+	}
+
+	if changes.KernelCmdline != nil {
+		if err := s.SetKernelCmdline(*changes.KernelCmdline); err != nil {
+			return err
+		}
+	}
+
+	for _, v := range changes.EFIVariables {
+		var guid EFIGUID
+		var err error
+		if v.GUID != "" {
+			guid, err = parseEFIGUID(v.GUID)
+		} else {
+			guid, err = s.variableGUID(v.Name)
+		}
+		if err != nil {
+			return xerrors.Errorf("cannot change variable %s: %w", v.Name, err)
+		}
+
+		var data []byte
+		if v.File != "" {
+			if data, err = ioutil.ReadFile(changes.path(v.File)); err != nil {
+				return xerrors.Errorf("cannot change variable %s: %w", v.Name, err)
+			}
+		}
+		if err := s.SetEFIVariable(v.Name, guid, data); err != nil {
+			return xerrors.Errorf("cannot change variable %s: %w", v.Name, err)
+		}
+	}
+
+	return nil
+}
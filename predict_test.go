@@ -0,0 +1,168 @@
+// Copyright 2019 Canonical Ltd.
+// Licensed under the LGPLv3 with static-linking exception.
+// See LICENCE file for details.
+
+package tcglog
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestParseEFIGUID(t *testing.T) {
+	for _, data := range []struct {
+		desc     string
+		guid     string
+		expected EFIGUID
+		err      string
+	}{
+		{
+			desc:     "Braces",
+			guid:     "{d719b2cb-3d3a-4596-a3bc-dad00e67656f}",
+			expected: EFIImageSecurityDatabaseGuid,
+		},
+		{
+			desc:     "NoBraces",
+			guid:     "605dab50-e046-4300-abb6-3dd810dd8b23",
+			expected: ShimLockGuid,
+		},
+		{
+			desc: "Invalid",
+			guid: "605dab50-e046",
+			err:  "invalid GUID \"605dab50-e046\"",
+		},
+	} {
+		t.Run(data.desc, func(t *testing.T) {
+			guid, err := parseEFIGUID(data.guid)
+			if data.err != "" {
+				if err == nil || err.Error() != data.err {
+					t.Errorf("Unexpected error: %v", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("parseEFIGUID failed: %v", err)
+			}
+			if guid != data.expected {
+				t.Errorf("Unexpected GUID: %s", guid)
+			}
+		})
+	}
+}
+
+func TestApplyChanges(t *testing.T) {
+	dir, err := ioutil.TempDir("", "predict")
+	if err != nil {
+		t.Fatalf("TempDir failed: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	shim := makeTestPEImage(0, []byte("shim 15.7"), nil, nil)
+	for name, contents := range map[string][]byte{
+		"shimx64.efi": shim,
+		"vmlinuz":     []byte("new kernel"),
+		"dbx.esl":     []byte("new dbx"),
+	} {
+		if err := ioutil.WriteFile(filepath.Join(dir, name), contents, 0644); err != nil {
+			t.Fatalf("WriteFile failed: %v", err)
+		}
+	}
+
+	changes, err := ReadPredictedChanges(strings.NewReader(`{
+  "efi-images": [{"path": "/EFI/ubuntu/shimx64.efi", "file": "shimx64.efi"}],
+  "grub-files": [{"path": "/vmlinuz-5.4.0-42-generic", "file": "vmlinuz"}],
+  "kernel-cmdline": "/vmlinuz-5.4.0-42-generic ro quiet",
+  "efi-variables": [{"name": "dbx", "file": "dbx.esl"}, {"name": "BootOrder", "guid": "8be4df61-93ca-11d2-aa0d-00e098032b8c"}]
+}`), dir)
+	if err != nil {
+		t.Fatalf("ReadPredictedChanges failed: %v", err)
+	}
+
+	log := makeBootAssetsTestLog()
+	s := NewSimulation(log, nil)
+	if err := s.ApplyChanges(changes); err != nil {
+		t.Fatalf("ApplyChanges failed: %v", err)
+	}
+
+	events := s.Log().Events
+	expectedShim, _ := ComputeAuthenticodeDigest(bytes.NewReader(shim), int64(len(shim)), AlgorithmSha256)
+	for _, data := range []struct {
+		index    int
+		expected []byte
+	}{
+		{0, nil},
+		{1, AlgorithmSha256.hash(nil)},
+		{2, expectedShim},
+		{4, AlgorithmSha256.hash([]byte("/vmlinuz-5.4.0-42-generic ro quiet"))},
+		{5, AlgorithmSha256.hash([]byte("new kernel"))},
+	} {
+		digest := events[data.index].Digests[AlgorithmSha256]
+		if bytes.Equal(digest, log.Events[data.index].Digests[AlgorithmSha256]) {
+			t.Errorf("Event %d wasn't changed", data.index)
+		}
+		if data.expected != nil && !bytes.Equal(digest, data.expected) {
+			t.Errorf("Unexpected digest for event %d: %x", data.index, digest)
+		}
+	}
+	if !bytes.Equal(events[3].Digests[AlgorithmSha256], log.Events[3].Digests[AlgorithmSha256]) {
+		t.Errorf("Event 3 shouldn't have changed")
+	}
+
+	var pcrs []PCRIndex
+	for _, r := range s.Run() {
+		if r.Changed() && r.Algorithm == AlgorithmSha256 {
+			pcrs = append(pcrs, r.PCRIndex)
+		}
+	}
+	expected := []PCRIndex{1, 4, 7, 8, 9}
+	if len(pcrs) != len(expected) {
+		t.Fatalf("Unexpected changed PCRs: %v", pcrs)
+	}
+	for i, pcr := range expected {
+		if pcrs[i] != pcr {
+			t.Errorf("Unexpected changed PCRs: %v", pcrs)
+		}
+	}
+}
+
+func TestApplyChangesErrors(t *testing.T) {
+	for _, data := range []struct {
+		desc    string
+		changes string
+		err     string
+	}{
+		{
+			desc:    "MissingFile",
+			changes: `{"efi-images": [{"path": "/EFI/ubuntu/shimx64.efi", "file": "/nonexistent/shimx64.efi"}]}`,
+			err:     "cannot replace EFI image /EFI/ubuntu/shimx64.efi: open /nonexistent/shimx64.efi: no such file or directory",
+		},
+		{
+			desc:    "UnknownVariable",
+			changes: `{"efi-variables": [{"name": "db"}]}`,
+			err:     "cannot change variable db: no measurement of a variable named db in the log",
+		},
+		{
+			desc:    "UnknownCommand",
+			changes: `{"grub-commands": [{"old": "linux /vmlinuz", "new": "linux /vmlinuz.new"}]}`,
+			err:     "no measurement of the command \"linux /vmlinuz\" in the log",
+		},
+	} {
+		t.Run(data.desc, func(t *testing.T) {
+			changes, err := ReadPredictedChanges(strings.NewReader(data.changes), "")
+			if err != nil {
+				t.Fatalf("ReadPredictedChanges failed: %v", err)
+			}
+			if err := NewSimulation(makeBootAssetsTestLog(), nil).ApplyChanges(changes); err == nil || err.Error() != data.err {
+				t.Errorf("Unexpected error: %v", err)
+			}
+		})
+	}
+
+	if _, err := ReadPredictedChanges(strings.NewReader(`{"kernel": "vmlinuz"}`), ""); err == nil {
+		t.Errorf("Expected an error for an unknown field")
+	}
+}
@@ -0,0 +1,221 @@
+// Copyright 2019 Canonical Ltd.
+// Licensed under the LGPLv3 with static-linking exception.
+// See LICENCE file for details.
+
+package tcglog
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+)
+
+// FirmwareQuirk identifies a known way in which firmware deviates from the TCG specifications when measuring events and recording
+// them in the log.
+type FirmwareQuirk int
+
+const (
+	// QuirkDuplicateAuthorityEvents indicates that the same authority is measured more than once to PCR 7 by
+	// EV_EFI_VARIABLE_AUTHORITY events, rather than only the first time that it is used to verify an image. See
+	// Log.DuplicateAuthorityEvents.
+	QuirkDuplicateAuthorityEvents FirmwareQuirk = iota
+
+	// QuirkMisplacedSeparators indicates that EV_SEPARATOR events are duplicated in a PCR, or that pre-OS events are measured to
+	// PCRs 0-7 after the separator.
+	QuirkMisplacedSeparators
+
+	// QuirkTruncatedVariableMeasurements indicates that the digests of EFI variable events don't cover the entire
+	// UEFI_VARIABLE_DATA structure recorded in the log, either because only the variable contents are measured or because the
+	// event data has trailing bytes that are not measured.
+	QuirkTruncatedVariableMeasurements
+
+	// QuirkZeroDigests indicates that events other than EV_NO_ACTION events have digests of all zeroes, which typically means
+	// that the firmware didn't measure them to the corresponding bank.
+	QuirkZeroDigests
+)
+
+var firmwareQuirkNames = map[FirmwareQuirk]string{
+	QuirkDuplicateAuthorityEvents:      "duplicate-authority-events",
+	QuirkMisplacedSeparators:           "misplaced-separators",
+	QuirkTruncatedVariableMeasurements: "truncated-variable-measurements",
+	QuirkZeroDigests:                   "zero-digests",
+}
+
+func (q FirmwareQuirk) String() string {
+	if name, ok := firmwareQuirkNames[q]; ok {
+		return name
+	}
+	return fmt.Sprintf("%%!(UNKNOWN_QUIRK=%d)", int(q))
+}
+
+// DetectedQuirk describes a firmware quirk that was detected in a log.
+type DetectedQuirk struct {
+	Quirk  FirmwareQuirk
+	Events []*Event // The events that exhibit the quirk
+	Known  bool     // The quirk is recorded in the catalogue for the firmware that produced the log
+}
+
+func (q *DetectedQuirk) String() string {
+	var events []string
+	for _, e := range q.Events {
+		events = append(events, fmt.Sprintf("%d:%d", e.PCRIndex, e.Index))
+	}
+	s := fmt.Sprintf("%s (events: %s)", q.Quirk, strings.Join(events, ", "))
+	if q.Known {
+		s += " [known]"
+	}
+	return s
+}
+
+type firmwareQuirkEntry struct {
+	vendor        string
+	versionPrefix string
+	quirks        []FirmwareQuirk
+}
+
+var (
+	firmwareQuirksMu sync.RWMutex
+	firmwareQuirks   []firmwareQuirkEntry
+)
+
+// RegisterFirmwareQuirks adds an entry to the catalogue of firmware that is known to exhibit the specified quirks. The firmware is
+// identified by the name of the firmware vendor that matches the vendorInfo field of the log's Spec ID event (see
+// RegisterFirmwareVendor) and a prefix of the version string recorded in the EV_S_CRTM_VERSION event. An empty vendor or version
+// prefix matches any firmware.
+func RegisterFirmwareQuirks(vendor, versionPrefix string, quirks ...FirmwareQuirk) {
+	firmwareQuirksMu.Lock()
+	defer firmwareQuirksMu.Unlock()
+	firmwareQuirks = append(firmwareQuirks, firmwareQuirkEntry{vendor: vendor, versionPrefix: versionPrefix, quirks: quirks})
+}
+
+// FirmwareVendor returns the name of the registered firmware vendor that matches the vendorInfo field of this log's Spec ID event,
+// or an empty string if there isn't one.
+func (l *Log) FirmwareVendor() string {
+	if len(l.Events) == 0 {
+		return ""
+	}
+	d, ok := l.Events[0].Data.(*SpecIdEvent)
+	if !ok {
+		return ""
+	}
+	return matchVendor(d.VendorInfo)
+}
+
+// FirmwareVersion returns the version string recorded in the EV_S_CRTM_VERSION event in this log, or an empty string if there
+// isn't one or it isn't a printable string.
+func (l *Log) FirmwareVersion() string {
+	for _, e := range l.Events {
+		if e.PCRIndex != 0 || e.EventType != EventTypeSCRTMVersion {
+			continue
+		}
+		str, _, _ := decodePrintableString(e.Data.Bytes())
+		return str
+	}
+	return ""
+}
+
+// KnownQuirks returns the quirks that the catalogue records for the firmware that produced this log. See RegisterFirmwareQuirks.
+func (l *Log) KnownQuirks() (out []FirmwareQuirk) {
+	vendor := l.FirmwareVendor()
+	version := l.FirmwareVersion()
+
+	firmwareQuirksMu.RLock()
+	defer firmwareQuirksMu.RUnlock()
+
+	seen := make(map[FirmwareQuirk]bool)
+	for _, entry := range firmwareQuirks {
+		if entry.vendor != "" && entry.vendor != vendor {
+			continue
+		}
+		if !strings.HasPrefix(version, entry.versionPrefix) {
+			continue
+		}
+		for _, q := range entry.quirks {
+			if !seen[q] {
+				seen[q] = true
+				out = append(out, q)
+			}
+		}
+	}
+	return out
+}
+
+func detectMisplacedSeparators(log *Log) (out []*Event) {
+	separators := make(map[PCRIndex]bool)
+	for _, e := range log.Events {
+		if e.PCRIndex > 7 || e.EventType == EventTypeNoAction {
+			continue
+		}
+		switch {
+		case e.EventType == EventTypeSeparator && separators[e.PCRIndex]:
+			out = append(out, e)
+		case e.EventType == EventTypeSeparator:
+			separators[e.PCRIndex] = true
+		case separators[e.PCRIndex] && !isPostSeparatorEventAllowed(e):
+			out = append(out, e)
+		}
+	}
+	return out
+}
+
+func detectTruncatedVariableMeasurements(log *Log) (out []*Event) {
+	for _, e := range log.Events {
+		if _, ok := e.Data.(*EFIVariableData); !ok {
+			continue
+		}
+		for _, alg := range log.Algorithms {
+			if _, ok := e.Digests[alg]; !ok || !alg.supported() {
+				continue
+			}
+			if candidate, matched, err := matchMeasuredBytes(e, alg); err == nil && matched && candidate.quirk != "" {
+				out = append(out, e)
+			}
+			break
+		}
+	}
+	return out
+}
+
+func detectZeroDigests(log *Log) (out []*Event) {
+	for _, e := range log.Events {
+		if e.EventType == EventTypeNoAction {
+			continue
+		}
+		for _, alg := range log.Algorithms {
+			if d, ok := e.Digests[alg]; ok && isZeroDigest(d) {
+				out = append(out, e)
+				break
+			}
+		}
+	}
+	return out
+}
+
+var firmwareQuirkDetectors = []struct {
+	quirk  FirmwareQuirk
+	detect func(log *Log) []*Event
+}{
+	{QuirkDuplicateAuthorityEvents, (*Log).DuplicateAuthorityEvents},
+	{QuirkMisplacedSeparators, detectMisplacedSeparators},
+	{QuirkTruncatedVariableMeasurements, detectTruncatedVariableMeasurements},
+	{QuirkZeroDigests, detectZeroDigests},
+}
+
+// DetectQuirks returns the known firmware quirks that are exhibited by the events in this log. Each detected quirk is marked as
+// known if the catalogue records it for the firmware that produced the log (see KnownQuirks). ParseLog populates the Quirks field
+// of the returned log with the result of this.
+func (l *Log) DetectQuirks() (out []*DetectedQuirk) {
+	known := make(map[FirmwareQuirk]bool)
+	for _, q := range l.KnownQuirks() {
+		known[q] = true
+	}
+
+	for _, d := range firmwareQuirkDetectors {
+		events := d.detect(l)
+		if len(events) == 0 {
+			continue
+		}
+		out = append(out, &DetectedQuirk{Quirk: d.quirk, Events: events, Known: known[d.quirk]})
+	}
+	return out
+}
@@ -0,0 +1,136 @@
+// Copyright 2019 Canonical Ltd.
+// Licensed under the LGPLv3 with static-linking exception.
+// See LICENCE file for details.
+
+package tcglog
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+)
+
+func makeTestQuirksLog(t *testing.T) *Log {
+	b, err := NewLogBuilder(AlgorithmIdList{AlgorithmSha1, AlgorithmSha256}, nil)
+	if err != nil {
+		t.Fatalf("NewLogBuilder failed: %v", err)
+	}
+
+	varData := EFIVariableData{VariableName: EFIGlobalVariableGuid, UnicodeName: "BootOrder", VariableData: []byte{0x01, 0x00}}
+	var varBytes bytes.Buffer
+	varData.EncodeMeasuredBytes(&varBytes)
+
+	authority := makeTestPFPVariable(EFIImageSecurityDatabaseGuid, "db")
+
+	add := func(pcr PCRIndex, eventType EventType, data []byte) {
+		if _, err := b.AddEvent(pcr, eventType, data); err != nil {
+			t.Fatalf("AddEvent failed: %v", err)
+		}
+	}
+	addWithDigests := func(pcr PCRIndex, eventType EventType, digests DigestMap, data []byte) {
+		if _, err := b.AddEventWithDigests(pcr, eventType, digests, data); err != nil {
+			t.Fatalf("AddEventWithDigests failed: %v", err)
+		}
+	}
+
+	add(0, EventTypeSCRTMVersion, []byte("1.2.3\x00"))
+	addWithDigests(1, EventTypeEFIVariableBoot, DigestMap{
+		AlgorithmSha1:   AlgorithmSha1.hash(varData.VariableData),
+		AlgorithmSha256: AlgorithmSha256.hash(varData.VariableData)}, varBytes.Bytes())
+	add(7, EventTypeEFIVariableAuthority, authority)
+	for pcr := PCRIndex(0); pcr <= 7; pcr++ {
+		add(pcr, EventTypeSeparator, []byte{0, 0, 0, 0})
+	}
+	add(7, EventTypeEFIVariableAuthority, authority)
+	add(1, EventTypeEFIVariableBoot, varBytes.Bytes())
+	addWithDigests(4, EventTypeEFIBootServicesApplication, DigestMap{
+		AlgorithmSha1:   AlgorithmSha1.hash(nil),
+		AlgorithmSha256: make(Digest, AlgorithmSha256.Size())}, makeTestImageLoadEventData("\\EFI\\ubuntu\\shimx64.efi"))
+
+	log := b.Log()
+	specId := log.Events[0].Data.(*SpecIdEvent)
+	specId.VendorInfo = []byte("Dell Inc.")
+	var specIdBytes bytes.Buffer
+	specId.EncodeTo(&specIdBytes)
+	specId.data = specIdBytes.Bytes()
+	return log
+}
+
+func withTestFirmwareQuirks(t *testing.T, fn func()) {
+	defer registerTestFirmwareVendors()()
+
+	firmwareQuirksMu.Lock()
+	orig := firmwareQuirks
+	firmwareQuirks = nil
+	firmwareQuirksMu.Unlock()
+
+	defer func() {
+		firmwareQuirksMu.Lock()
+		firmwareQuirks = orig
+		firmwareQuirksMu.Unlock()
+	}()
+
+	fn()
+}
+
+func TestDetectQuirks(t *testing.T) {
+	withTestFirmwareQuirks(t, func() {
+		RegisterFirmwareQuirks("dell", "1.2", QuirkDuplicateAuthorityEvents, QuirkZeroDigests)
+		RegisterFirmwareQuirks("lenovo", "", QuirkMisplacedSeparators)
+		RegisterFirmwareQuirks("", "1.3", QuirkTruncatedVariableMeasurements)
+
+		log := makeTestQuirksLog(t)
+		if log.FirmwareVendor() != "dell" {
+			t.Errorf("Unexpected vendor: %q", log.FirmwareVendor())
+		}
+		if log.FirmwareVersion() != "1.2.3" {
+			t.Errorf("Unexpected version: %q", log.FirmwareVersion())
+		}
+		if !reflect.DeepEqual(log.KnownQuirks(), []FirmwareQuirk{QuirkDuplicateAuthorityEvents, QuirkZeroDigests}) {
+			t.Errorf("Unexpected known quirks: %v", log.KnownQuirks())
+		}
+
+		var quirks []string
+		for _, q := range log.DetectQuirks() {
+			quirks = append(quirks, q.String())
+		}
+		expected := []string{
+			"duplicate-authority-events (events: 7:2) [known]",
+			"misplaced-separators (events: 1:2)",
+			"truncated-variable-measurements (events: 1:0)",
+			"zero-digests (events: 4:1) [known]"}
+		if !reflect.DeepEqual(quirks, expected) {
+			t.Errorf("Unexpected quirks: %q", quirks)
+		}
+	})
+}
+
+func TestParseLogQuirks(t *testing.T) {
+	withTestFirmwareQuirks(t, func() {
+		RegisterFirmwareQuirks("dell", "", QuirkZeroDigests)
+
+		var b bytes.Buffer
+		if err := WriteLog(&b, makeTestQuirksLog(t)); err != nil {
+			t.Fatalf("WriteLog failed: %v", err)
+		}
+
+		log, err := ParseLog(&b, &LogOptions{})
+		if err != nil {
+			t.Fatalf("ParseLog failed: %v", err)
+		}
+		if len(log.Quirks) != 4 {
+			t.Fatalf("Unexpected number of quirks (%d)", len(log.Quirks))
+		}
+		for _, q := range log.Quirks {
+			if q.Known != (q.Quirk == QuirkZeroDigests) {
+				t.Errorf("Unexpected quirk: %s", q)
+			}
+		}
+	})
+}
+
+func TestFirmwareQuirkString(t *testing.T) {
+	if FirmwareQuirk(100).String() != "%!(UNKNOWN_QUIRK=100)" {
+		t.Errorf("Unexpected string: %s", FirmwareQuirk(100))
+	}
+}
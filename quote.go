@@ -0,0 +1,251 @@
+// Copyright 2019 Canonical Ltd.
+// Licensed under the LGPLv3 with static-linking exception.
+// See LICENCE file for details.
+
+package tcglog
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/rsa"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+
+	"golang.org/x/xerrors"
+)
+
+const (
+	tpmGeneratedValue uint32 = 0xff544347 // TPM_GENERATED_VALUE
+	tpmStAttestQuote  uint16 = 0x8018     // TPM_ST_ATTEST_QUOTE
+
+	tpmAlgRSASSA uint16 = 0x0014 // TPM_ALG_RSASSA
+	tpmAlgRSAPSS uint16 = 0x0016 // TPM_ALG_RSAPSS
+	tpmAlgECDSA  uint16 = 0x0018 // TPM_ALG_ECDSA
+)
+
+// Quote corresponds to the fields of a TPMS_ATTEST structure of type TPM_ST_ATTEST_QUOTE that are relevant for verifying it
+// against a log.
+//
+// https://trustedcomputinggroup.org/wp-content/uploads/TPM-Rev-2.0-Part-2-Structures-01.38.pdf
+//  (section 10.12.8 "TPMS_ATTEST", section 10.12.4 "TPMS_QUOTE_INFO")
+type Quote struct {
+	QualifiedSigner []byte                     // The qualified name of the signing key
+	ExtraData       []byte                     // The qualifying data supplied by the caller, normally a nonce
+	Clock           uint64                     // The value of the TPM's clock
+	ResetCount      uint32                     // The number of TPM resets since the last TPM2_Clear
+	RestartCount    uint32                     // The number of TPM restarts or resumes since the last TPM reset
+	Safe            bool                       // Whether the clock value is guaranteed not to have been reported before
+	FirmwareVersion uint64                     // The TPM vendor's firmware version
+	PCRSelection    map[AlgorithmId][]PCRIndex // The PCRs included in the quote for each bank, in ascending order
+	PCRSelectOrder  AlgorithmIdList            // The order of the banks in the selection
+	PCRDigest       Digest                     // The digest of the selected PCR values
+}
+
+func readTPM2B(r io.Reader) ([]byte, error) {
+	var size uint16
+	if err := binary.Read(r, binary.BigEndian, &size); err != nil {
+		return nil, err
+	}
+	data := make([]byte, size)
+	if _, err := io.ReadFull(r, data); err != nil {
+		return nil, err
+	}
+	return data, nil
+}
+
+// DecodeQuote decodes the supplied TPMS_ATTEST structure, which must be the attestation data returned from TPM2_Quote.
+func DecodeQuote(attest []byte) (*Quote, error) {
+	r := bytes.NewReader(attest)
+
+	var header struct {
+		Magic uint32
+		Type  uint16
+	}
+	if err := binary.Read(r, binary.BigEndian, &header); err != nil {
+		return nil, xerrors.Errorf("cannot read header: %w", err)
+	}
+	if header.Magic != tpmGeneratedValue {
+		return nil, fmt.Errorf("invalid magic value (0x%08x)", header.Magic)
+	}
+	if header.Type != tpmStAttestQuote {
+		return nil, fmt.Errorf("unexpected attestation type (0x%04x)", header.Type)
+	}
+
+	q := &Quote{PCRSelection: make(map[AlgorithmId][]PCRIndex)}
+
+	var err error
+	if q.QualifiedSigner, err = readTPM2B(r); err != nil {
+		return nil, xerrors.Errorf("cannot read qualifiedSigner: %w", err)
+	}
+	if q.ExtraData, err = readTPM2B(r); err != nil {
+		return nil, xerrors.Errorf("cannot read extraData: %w", err)
+	}
+
+	var clockInfo struct {
+		Clock           uint64
+		ResetCount      uint32
+		RestartCount    uint32
+		Safe            uint8
+		FirmwareVersion uint64
+	}
+	if err := binary.Read(r, binary.BigEndian, &clockInfo); err != nil {
+		return nil, xerrors.Errorf("cannot read clockInfo and firmwareVersion: %w", err)
+	}
+	q.Clock = clockInfo.Clock
+	q.ResetCount = clockInfo.ResetCount
+	q.RestartCount = clockInfo.RestartCount
+	q.Safe = clockInfo.Safe != 0
+	q.FirmwareVersion = clockInfo.FirmwareVersion
+
+	var count uint32
+	if err := binary.Read(r, binary.BigEndian, &count); err != nil {
+		return nil, xerrors.Errorf("cannot read PCR selection count: %w", err)
+	}
+	for i := uint32(0); i < count; i++ {
+		var sel struct {
+			Hash         AlgorithmId
+			SizeofSelect uint8
+		}
+		if err := binary.Read(r, binary.BigEndian, &sel); err != nil {
+			return nil, xerrors.Errorf("cannot read PCR selection %d: %w", i, err)
+		}
+		bitmap := make([]byte, sel.SizeofSelect)
+		if _, err := io.ReadFull(r, bitmap); err != nil {
+			return nil, xerrors.Errorf("cannot read PCR selection %d: %w", i, err)
+		}
+		if _, dup := q.PCRSelection[sel.Hash]; dup {
+			return nil, fmt.Errorf("duplicate PCR selection for %v", sel.Hash)
+		}
+		pcrs := []PCRIndex{}
+		for j, b := range bitmap {
+			for k := 0; k < 8; k++ {
+				if b&(1<<uint(k)) != 0 {
+					pcrs = append(pcrs, PCRIndex(j*8+k))
+				}
+			}
+		}
+		q.PCRSelection[sel.Hash] = pcrs
+		q.PCRSelectOrder = append(q.PCRSelectOrder, sel.Hash)
+	}
+
+	digest, err := readTPM2B(r)
+	if err != nil {
+		return nil, xerrors.Errorf("cannot read pcrDigest: %w", err)
+	}
+	q.PCRDigest = digest
+
+	if r.Len() > 0 {
+		return nil, fmt.Errorf("%d trailing bytes", r.Len())
+	}
+
+	return q, nil
+}
+
+// verifyQuoteSignature verifies the supplied TPMT_SIGNATURE against attest using the supplied public key, and returns the digest
+// algorithm associated with the signature.
+//
+// https://trustedcomputinggroup.org/wp-content/uploads/TPM-Rev-2.0-Part-2-Structures-01.38.pdf
+//  (section 11.3.4 "TPMT_SIGNATURE")
+func verifyQuoteSignature(attest, signature []byte, key crypto.PublicKey) (AlgorithmId, bool, error) {
+	r := bytes.NewReader(signature)
+
+	var header struct {
+		SigAlg uint16
+		Hash   AlgorithmId
+	}
+	if err := binary.Read(r, binary.BigEndian, &header); err != nil {
+		return 0, false, xerrors.Errorf("cannot read signature header: %w", err)
+	}
+	if !header.Hash.supported() {
+		return 0, false, fmt.Errorf("unsupported signature digest algorithm %v", header.Hash)
+	}
+	digest := header.Hash.hash(attest)
+
+	switch header.SigAlg {
+	case tpmAlgRSASSA, tpmAlgRSAPSS:
+		sig, err := readTPM2B(r)
+		if err != nil {
+			return 0, false, xerrors.Errorf("cannot read RSA signature: %w", err)
+		}
+		pub, ok := key.(*rsa.PublicKey)
+		if !ok {
+			return 0, false, errors.New("RSA signature requires a RSA public key")
+		}
+		if header.SigAlg == tpmAlgRSASSA {
+			err = rsa.VerifyPKCS1v15(pub, header.Hash.GetHash(), digest, sig)
+		} else {
+			err = rsa.VerifyPSS(pub, header.Hash.GetHash(), digest, sig, &rsa.PSSOptions{SaltLength: rsa.PSSSaltLengthAuto})
+		}
+		return header.Hash, err == nil, nil
+	case tpmAlgECDSA:
+		sigR, err := readTPM2B(r)
+		if err != nil {
+			return 0, false, xerrors.Errorf("cannot read ECDSA signature R: %w", err)
+		}
+		sigS, err := readTPM2B(r)
+		if err != nil {
+			return 0, false, xerrors.Errorf("cannot read ECDSA signature S: %w", err)
+		}
+		pub, ok := key.(*ecdsa.PublicKey)
+		if !ok {
+			return 0, false, errors.New("ECDSA signature requires an ECDSA public key")
+		}
+		return header.Hash, ecdsa.Verify(pub, digest, new(big.Int).SetBytes(sigR), new(big.Int).SetBytes(sigS)), nil
+	default:
+		return 0, false, fmt.Errorf("unsupported signature algorithm (0x%04x)", header.SigAlg)
+	}
+}
+
+// QuoteVerification is the result of verifying a TPM2 quote against a log.
+type QuoteVerification struct {
+	Quote          *Quote
+	SignatureValid bool   // The quote's signature was verified with the supplied key
+	ExpectedDigest Digest // The PCR digest computed by replaying the log for the quoted selection
+}
+
+// DigestMatches indicates whether the PCR digest in the quote is consistent with the log.
+func (v *QuoteVerification) DigestMatches() bool {
+	return bytes.Equal(v.Quote.PCRDigest, v.ExpectedDigest)
+}
+
+// Explained indicates whether the quote has a valid signature and the log explains the quoted PCR values.
+func (v *QuoteVerification) Explained() bool {
+	return v.SignatureValid && v.DigestMatches()
+}
+
+// VerifyQuote verifies a TPM2 quote against this log. The attest argument is the TPMS_ATTEST structure returned from TPM2_Quote,
+// and signature is the corresponding TPMT_SIGNATURE structure, which is verified using the supplied public key (which must be a
+// *rsa.PublicKey or *ecdsa.PublicKey). The expected PCR digest is computed from the values of the quoted PCRs obtained by
+// replaying this log, using the digest algorithm of the signature.
+//
+// An error is returned if the quote or signature can't be decoded, or if the log doesn't contain digests for one of the quoted
+// banks. A signature that fails to verify or a PCR digest that doesn't match isn't an error - use the returned QuoteVerification
+// to determine whether the log explains the quote.
+func (l *Log) VerifyQuote(attest, signature []byte, key crypto.PublicKey) (*QuoteVerification, error) {
+	quote, err := DecodeQuote(attest)
+	if err != nil {
+		return nil, xerrors.Errorf("cannot decode quote: %w", err)
+	}
+
+	hashAlg, valid, err := verifyQuoteSignature(attest, signature, key)
+	if err != nil {
+		return nil, xerrors.Errorf("cannot verify signature: %w", err)
+	}
+
+	h := hashAlg.GetHash().New()
+	for _, alg := range quote.PCRSelectOrder {
+		for _, pcr := range quote.PCRSelection[alg] {
+			value, err := l.ReplayPCR(alg, pcr)
+			if err != nil {
+				return nil, xerrors.Errorf("cannot replay PCR %d for %v: %w", pcr, alg, err)
+			}
+			h.Write(value)
+		}
+	}
+
+	return &QuoteVerification{Quote: quote, SignatureValid: valid, ExpectedDigest: h.Sum(nil)}, nil
+}
@@ -0,0 +1,172 @@
+// Copyright 2019 Canonical Ltd.
+// Licensed under the LGPLv3 with static-linking exception.
+// See LICENCE file for details.
+
+package tcglog
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/binary"
+	"reflect"
+	"testing"
+)
+
+func writeTestTPM2B(w *bytes.Buffer, data []byte) {
+	binary.Write(w, binary.BigEndian, uint16(len(data)))
+	w.Write(data)
+}
+
+func makeTestQuote(nonce []byte, selection map[AlgorithmId][]PCRIndex, order AlgorithmIdList, digest Digest) []byte {
+	var w bytes.Buffer
+	binary.Write(&w, binary.BigEndian, tpmGeneratedValue)
+	binary.Write(&w, binary.BigEndian, tpmStAttestQuote)
+	writeTestTPM2B(&w, []byte{0x00, 0x0b, 0x01, 0x02})
+	writeTestTPM2B(&w, nonce)
+	binary.Write(&w, binary.BigEndian, struct {
+		Clock           uint64
+		ResetCount      uint32
+		RestartCount    uint32
+		Safe            uint8
+		FirmwareVersion uint64
+	}{Clock: 1000, ResetCount: 2, RestartCount: 3, Safe: 1, FirmwareVersion: 0x20190604})
+	binary.Write(&w, binary.BigEndian, uint32(len(order)))
+	for _, alg := range order {
+		bitmap := make([]byte, 3)
+		for _, pcr := range selection[alg] {
+			bitmap[pcr/8] |= 1 << (pcr % 8)
+		}
+		binary.Write(&w, binary.BigEndian, alg)
+		w.WriteByte(uint8(len(bitmap)))
+		w.Write(bitmap)
+	}
+	writeTestTPM2B(&w, digest)
+	return w.Bytes()
+}
+
+func TestDecodeQuote(t *testing.T) {
+	selection := map[AlgorithmId][]PCRIndex{AlgorithmSha256: {0, 7, 12}, AlgorithmSha1: {23}}
+	order := AlgorithmIdList{AlgorithmSha256, AlgorithmSha1}
+	attest := makeTestQuote([]byte("nonce"), selection, order, Digest{0x01, 0x02})
+
+	q, err := DecodeQuote(attest)
+	if err != nil {
+		t.Fatalf("DecodeQuote failed: %v", err)
+	}
+	expected := &Quote{
+		QualifiedSigner: []byte{0x00, 0x0b, 0x01, 0x02},
+		ExtraData:       []byte("nonce"),
+		Clock:           1000,
+		ResetCount:      2,
+		RestartCount:    3,
+		Safe:            true,
+		FirmwareVersion: 0x20190604,
+		PCRSelection:    selection,
+		PCRSelectOrder:  order,
+		PCRDigest:       Digest{0x01, 0x02}}
+	if !reflect.DeepEqual(q, expected) {
+		t.Errorf("Unexpected quote: %+v", q)
+	}
+
+	if _, err := DecodeQuote(append(attest, 0)); err == nil || err.Error() != "1 trailing bytes" {
+		t.Errorf("Unexpected error: %v", err)
+	}
+	attest[5] = 0x17
+	if _, err := DecodeQuote(attest); err == nil || err.Error() != "unexpected attestation type (0x8017)" {
+		t.Errorf("Unexpected error: %v", err)
+	}
+}
+
+func TestVerifyQuote(t *testing.T) {
+	algs := []AlgorithmId{AlgorithmSha1, AlgorithmSha256}
+	log := NewLog([]*Event{
+		makeTestEvent(0, EventTypeSCRTMVersion, []byte{0x31, 0x00}, algs...),
+		makeTestEvent(7, EventTypeSeparator, []byte{0, 0, 0, 0}, algs...)})
+
+	pcrDigest := func(alg AlgorithmId, pcrs ...PCRIndex) Digest {
+		h := AlgorithmSha256.GetHash().New()
+		for _, pcr := range pcrs {
+			value, _ := log.ReplayPCR(alg, pcr)
+			h.Write(value)
+		}
+		return h.Sum(nil)
+	}
+	selection := map[AlgorithmId][]PCRIndex{AlgorithmSha256: {0, 7}}
+	order := AlgorithmIdList{AlgorithmSha256}
+
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("GenerateKey failed: %v", err)
+	}
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("GenerateKey failed: %v", err)
+	}
+
+	signECDSA := func(attest []byte) []byte {
+		r, s, err := ecdsa.Sign(rand.Reader, ecKey, AlgorithmSha256.hash(attest))
+		if err != nil {
+			t.Fatalf("Sign failed: %v", err)
+		}
+		var w bytes.Buffer
+		binary.Write(&w, binary.BigEndian, tpmAlgECDSA)
+		binary.Write(&w, binary.BigEndian, AlgorithmSha256)
+		writeTestTPM2B(&w, r.Bytes())
+		writeTestTPM2B(&w, s.Bytes())
+		return w.Bytes()
+	}
+	signRSA := func(attest []byte) []byte {
+		sig, err := rsa.SignPKCS1v15(rand.Reader, rsaKey, crypto.SHA256, AlgorithmSha256.hash(attest))
+		if err != nil {
+			t.Fatalf("Sign failed: %v", err)
+		}
+		var w bytes.Buffer
+		binary.Write(&w, binary.BigEndian, tpmAlgRSASSA)
+		binary.Write(&w, binary.BigEndian, AlgorithmSha256)
+		writeTestTPM2B(&w, sig)
+		return w.Bytes()
+	}
+
+	good := makeTestQuote([]byte("nonce"), selection, order, pcrDigest(AlgorithmSha256, 0, 7))
+	bad := makeTestQuote([]byte("nonce"), selection, order, pcrDigest(AlgorithmSha256, 0))
+
+	for _, data := range []struct {
+		desc           string
+		attest         []byte
+		signature      []byte
+		key            crypto.PublicKey
+		signatureValid bool
+		digestMatches  bool
+	}{
+		{desc: "ECDSA", attest: good, signature: signECDSA(good), key: &ecKey.PublicKey, signatureValid: true, digestMatches: true},
+		{desc: "RSA", attest: good, signature: signRSA(good), key: &rsaKey.PublicKey, signatureValid: true, digestMatches: true},
+		{desc: "BadDigest", attest: bad, signature: signECDSA(bad), key: &ecKey.PublicKey, signatureValid: true},
+		{desc: "BadSignature", attest: good, signature: signECDSA(bad), key: &ecKey.PublicKey, digestMatches: true},
+	} {
+		t.Run(data.desc, func(t *testing.T) {
+			v, err := log.VerifyQuote(data.attest, data.signature, data.key)
+			if err != nil {
+				t.Fatalf("VerifyQuote failed: %v", err)
+			}
+			if v.SignatureValid != data.signatureValid {
+				t.Errorf("Unexpected SignatureValid: %v", v.SignatureValid)
+			}
+			if v.DigestMatches() != data.digestMatches {
+				t.Errorf("Unexpected DigestMatches: %v (expected %x, quoted %x)", v.DigestMatches(), v.ExpectedDigest,
+					v.Quote.PCRDigest)
+			}
+			if v.Explained() != (data.signatureValid && data.digestMatches) {
+				t.Errorf("Unexpected Explained: %v", v.Explained())
+			}
+		})
+	}
+
+	if _, err := log.VerifyQuote(good, signECDSA(good), &rsaKey.PublicKey); err == nil ||
+		err.Error() != "cannot verify signature: ECDSA signature requires an ECDSA public key" {
+		t.Errorf("Unexpected error: %v", err)
+	}
+}
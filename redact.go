@@ -0,0 +1,130 @@
+// Copyright 2019 Canonical Ltd.
+// Licensed under the LGPLv3 with static-linking exception.
+// See LICENCE file for details.
+
+package tcglog
+
+import (
+	"bytes"
+	"strings"
+	"unicode/utf8"
+
+	"golang.org/x/xerrors"
+)
+
+// RedactOptions allows the behaviour of Log.Redact to be controlled.
+type RedactOptions struct {
+	KeepVariableData bool // Don't redact the contents of measured EFI variables
+	KeepStrings      bool // Don't redact strings measured by GRUB, systemd's EFI linux loader stub or to EV_IPL events
+	KeepDevicePaths  bool // Don't redact the device paths of loaded EFI images
+
+	// Strip indicates that redacted variable contents and strings should be removed entirely. By default, they are masked
+	// with a payload of the same length.
+	Strip bool
+}
+
+// efiEndEntireDevicePath is the encoding of a device path that consists only of an End of Hardware Device Path node.
+var efiEndEntireDevicePath = []byte{0x7f, 0xff, 0x04, 0x00}
+
+func (o *RedactOptions) maskString(s string) string {
+	if o.Strip {
+		return ""
+	}
+	return strings.Repeat("*", utf8.RuneCountInString(s))
+}
+
+func (o *RedactOptions) maskBytes(data []byte, mask byte) []byte {
+	if o.Strip {
+		return nil
+	}
+	return bytes.Repeat([]byte{mask}, len(data))
+}
+
+func (o *RedactOptions) redactEventData(event *Event) (EventData, error) {
+	switch d := event.Data.(type) {
+	case *EFIVariableData:
+		if o.KeepVariableData {
+			break
+		}
+		out := &EFIVariableData{
+			VariableName: d.VariableName,
+			UnicodeName:  d.UnicodeName,
+			VariableData: o.maskBytes(d.VariableData, 0)}
+		var b bytes.Buffer
+		if err := out.EncodeTo(&b); err != nil {
+			return nil, err
+		}
+		out.data = b.Bytes()
+		out.consumedBytes = len(out.data)
+		return out, nil
+	case *GrubStringEventData:
+		if o.KeepStrings {
+			break
+		}
+		out := &GrubStringEventData{Type: d.Type, Str: o.maskString(d.Str)}
+		var b bytes.Buffer
+		if err := out.EncodeTo(&b); err != nil {
+			return nil, err
+		}
+		out.data = b.Bytes()
+		return out, nil
+	case *SystemdEFIStubEventData:
+		if o.KeepStrings {
+			break
+		}
+		out := &SystemdEFIStubEventData{Str: o.maskString(d.Str)}
+		var b bytes.Buffer
+		if err := out.EncodeTo(&b); err != nil {
+			return nil, err
+		}
+		out.data = b.Bytes()
+		return out, nil
+	case *AsciiStringEventData:
+		if o.KeepStrings || event.EventType != EventTypeIPL {
+			break
+		}
+		return &AsciiStringEventData{data: o.maskBytes(d.data, '*')}, nil
+	case *EFIImageLoadEvent:
+		if o.KeepDevicePaths {
+			break
+		}
+		// Retain the image location, length and link-time address, and replace the device path with one that consists
+		// only of an end node.
+		var b bytes.Buffer
+		if err := (&EFIImageLoadEvent{
+			LocationInMemory: d.LocationInMemory,
+			LengthInMemory:   d.LengthInMemory,
+			LinkTimeAddress:  d.LinkTimeAddress}).EncodeTo(&b); err != nil {
+			return nil, xerrors.Errorf("cannot encode redacted image load event: %w", err)
+		}
+		out, err := decodeEventDataEFIImageLoad(b.Bytes())
+		if err != nil {
+			return nil, xerrors.Errorf("cannot decode redacted image load event: %w", err)
+		}
+		return out, nil
+	}
+
+	return event.Data, nil
+}
+
+// Redact returns a copy of this log with the event data payloads that may contain configuration details (the contents of EFI
+// variables, strings measured by boot components and the device paths of loaded EFI images) redacted according to the supplied
+// options, which may be nil. The PCR indices, event types and digests of every event are preserved, so the redacted log can be
+// shared for debugging purposes and still be used to compute PCR values. The redacted event data will not match the digests.
+//
+// Device paths are replaced with a path consisting only of an end node, regardless of options.Strip.
+func (l *Log) Redact(options *RedactOptions) (*Log, error) {
+	if options == nil {
+		options = &RedactOptions{}
+	}
+
+	out := l.ShallowCopy()
+	for i, e := range out.Events {
+		data, err := options.redactEventData(e)
+		if err != nil {
+			return nil, xerrors.Errorf("cannot redact data for event %d: %w", i, err)
+		}
+		e.Data = data
+	}
+	return out, nil
+}
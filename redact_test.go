@@ -0,0 +1,136 @@
+// Copyright 2019 Canonical Ltd.
+// Licensed under the LGPLv3 with static-linking exception.
+// See LICENCE file for details.
+
+package tcglog
+
+import (
+	"bytes"
+	"encoding/binary"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func makeTestImageLoadEventData(path string) []byte {
+	var node bytes.Buffer
+	binary.Write(&node, binary.LittleEndian, convertStringToUtf16(path+"\x00"))
+
+	var devicePath bytes.Buffer
+	devicePath.Write([]byte{0x04, 0x04})
+	binary.Write(&devicePath, binary.LittleEndian, uint16(node.Len()+4))
+	node.WriteTo(&devicePath)
+	devicePath.Write(efiEndEntireDevicePath)
+
+	var b bytes.Buffer
+	binary.Write(&b, binary.LittleEndian, []uint64{0x5e3c7018, 0x1e4a50, 0, uint64(devicePath.Len())})
+	devicePath.WriteTo(&b)
+	return b.Bytes()
+}
+
+func makeRedactTestLog() *Log {
+	varData := EFIVariableData{
+		VariableName: MakeEFIGUID(0x8be4df61, 0x93ca, 0x11d2, 0xaa0d, [...]uint8{0x00, 0xe0, 0x98, 0x03, 0x2b, 0x8c}),
+		UnicodeName:  "BootOrder",
+		VariableData: []byte{0x01, 0x00, 0x02, 0x00}}
+	var varBytes bytes.Buffer
+	varData.EncodeMeasuredBytes(&varBytes)
+
+	options := &LogOptions{EnableGrub: true}
+	var events []*Event
+	for _, e := range []struct {
+		pcr       PCRIndex
+		eventType EventType
+		data      []byte
+	}{
+		{pcr: 1, eventType: EventTypeEFIVariableBoot, data: varBytes.Bytes()},
+		{pcr: 4, eventType: EventTypeEFIAction, data: []byte("Calling EFI Application from Boot Option")},
+		{pcr: 4, eventType: EventTypeEFIBootServicesApplication, data: makeTestImageLoadEventData("\\EFI\\ubuntu\\shimx64.efi")},
+		{pcr: 8, eventType: EventTypeIPL, data: []byte("grub_cmd: linux /vmlinuz root=/dev/sda1\x00")},
+		{pcr: 9, eventType: EventTypeIPL, data: []byte("/boot/vmlinuz\x00")},
+	} {
+		event := makeTestEvent(e.pcr, e.eventType, e.data, AlgorithmSha1, AlgorithmSha256)
+		event.Data = DecodeEventData(e.pcr, e.eventType, event.Digests, e.data, options)
+		events = append(events, event)
+	}
+	return NewLog(events)
+}
+
+func TestLogRedact(t *testing.T) {
+	log := makeRedactTestLog()
+	redacted, err := log.Redact(nil)
+	if err != nil {
+		t.Fatalf("Redact failed: %v", err)
+	}
+
+	if len(redacted.Events) != len(log.Events) {
+		t.Fatalf("Unexpected number of events")
+	}
+	for i, e := range redacted.Events {
+		o := log.Events[i]
+		if e.PCRIndex != o.PCRIndex || e.EventType != o.EventType || e.Index != o.Index {
+			t.Errorf("Unexpected header for event %d", i)
+		}
+		if !reflect.DeepEqual(e.Digests, o.Digests) {
+			t.Errorf("Unexpected digests for event %d", i)
+		}
+		if err, isErr := e.Data.(error); isErr {
+			t.Errorf("Redacted data for event %d is invalid: %v", i, err)
+		}
+	}
+
+	varData, ok := redacted.Events[0].Data.(*EFIVariableData)
+	if !ok {
+		t.Fatalf("Unexpected event data type for event 0")
+	}
+	if varData.UnicodeName != "BootOrder" || !bytes.Equal(varData.VariableData, []byte{0, 0, 0, 0}) {
+		t.Errorf("Unexpected variable data: %s %x", varData, varData.VariableData)
+	}
+
+	if !bytes.Equal(redacted.Events[1].Data.Bytes(), log.Events[1].Data.Bytes()) {
+		t.Errorf("EV_EFI_ACTION event should not be redacted")
+	}
+
+	if strings.Contains(redacted.Events[2].Data.String(), "shimx64") {
+		t.Errorf("Device path was not redacted: %s", redacted.Events[2].Data)
+	}
+	if !bytes.Equal(redacted.Events[2].Data.Bytes()[:24], log.Events[2].Data.Bytes()[:24]) {
+		t.Errorf("Image location was not preserved")
+	}
+
+	grubData, ok := redacted.Events[3].Data.(*GrubStringEventData)
+	if !ok {
+		t.Fatalf("Unexpected event data type for event 3")
+	}
+	if grubData.Type != GrubCmd || grubData.Str != strings.Repeat("*", len("linux /vmlinuz root=/dev/sda1")) {
+		t.Errorf("Unexpected GRUB string: %s", grubData)
+	}
+	if !bytes.Equal(redacted.Events[4].Data.Bytes(), []byte("**************")) {
+		t.Errorf("Unexpected EV_IPL data: %q", redacted.Events[4].Data.Bytes())
+	}
+
+	if !strings.Contains(log.Events[2].Data.String(), "shimx64") {
+		t.Errorf("Original log was modified")
+	}
+}
+
+func TestLogRedactStrip(t *testing.T) {
+	log := makeRedactTestLog()
+	redacted, err := log.Redact(&RedactOptions{KeepDevicePaths: true, Strip: true})
+	if err != nil {
+		t.Fatalf("Redact failed: %v", err)
+	}
+
+	if varData := redacted.Events[0].Data.(*EFIVariableData); len(varData.VariableData) != 0 {
+		t.Errorf("Variable data was not stripped")
+	}
+	if redacted.Events[2].Data != log.Events[2].Data {
+		t.Errorf("Device path should not be redacted")
+	}
+	if grubData := redacted.Events[3].Data.(*GrubStringEventData); grubData.Str != "" {
+		t.Errorf("GRUB string was not stripped")
+	}
+	if len(redacted.Events[4].Data.Bytes()) != 0 {
+		t.Errorf("EV_IPL data was not stripped")
+	}
+}
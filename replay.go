@@ -0,0 +1,209 @@
+// Copyright 2019 Canonical Ltd.
+// Licensed under the LGPLv3 with static-linking exception.
+// See LICENCE file for details.
+
+package tcglog
+
+import (
+	"fmt"
+)
+
+// extendDigest computes the result of extending the supplied PCR value with digest, using the specified algorithm.
+func extendDigest(alg AlgorithmId, pcrValue, digest Digest) Digest {
+	h := alg.GetHash().New()
+	h.Write(pcrValue)
+	h.Write(digest)
+	return h.Sum(nil)
+}
+
+// initialPCRValue returns the value of the specified PCR for the specified algorithm before any events are extended to it. The
+// initial value of PCR 0 depends on the startup locality, and is 4 if a H-CRTM sequence occurred. PCRs 17 to 22 are initialized
+// to all ones, and are only reset to zero by a dynamic launch.
+//
+// https://trustedcomputinggroup.org/wp-content/uploads/TCG_PCClientSpecPlat_TPM_2p0_1p04_pub.pdf
+//  (section 10.4.1 "PCR Initialization")
+func (l *Log) initialPCRValue(alg AlgorithmId, pcr PCRIndex) Digest {
+	value := make(Digest, alg.Size())
+	switch {
+	case pcr == 0 && l.HasHCRTM():
+		value[len(value)-1] = 4
+	case pcr == 0:
+		value[len(value)-1] = l.StartupLocality()
+	case isDRTMPCR(pcr) && !l.HasDynamicLaunch():
+		for i := range value {
+			value[i] = 0xff
+		}
+	}
+	return value
+}
+
+// replayPCR computes the value of the specified PCR for the specified algorithm by replaying the events in this log. An error is
+// returned if an event extended to the PCR doesn't have a digest for the specified algorithm.
+func (l *Log) replayPCR(alg AlgorithmId, pcr PCRIndex) (Digest, error) {
+	value := l.initialPCRValue(alg, pcr)
+	for _, e := range l.Events {
+		if e.PCRIndex != pcr || e.EventType == EventTypeNoAction {
+			continue
+		}
+		digest, ok := e.Digests[alg]
+		if !ok {
+			return nil, fmt.Errorf("event %d in PCR %d has no %v digest", e.Index, e.PCRIndex, alg)
+		}
+		value = extendDigest(alg, value, digest)
+	}
+	return value, nil
+}
+
+// checkReplayAlgorithm returns an error if the PCR values for the specified algorithm can't be computed from this log.
+func (l *Log) checkReplayAlgorithm(alg AlgorithmId) error {
+	if !l.Algorithms.Contains(alg) {
+		return fmt.Errorf("log does not contain digests for algorithm %v", alg)
+	}
+	if !alg.supported() {
+		return fmt.Errorf("unsupported algorithm %v", alg)
+	}
+	return nil
+}
+
+// ReplayPCR computes the expected value of the specified PCR for the specified algorithm by replaying the events in this log. This
+// can be compared with the value read from the TPM in order to verify the log. An error is returned if the log doesn't contain
+// digests for the specified algorithm, or if an event extended to the PCR is missing a digest for it.
+func (l *Log) ReplayPCR(alg AlgorithmId, pcr PCRIndex) (Digest, error) {
+	if err := l.checkReplayAlgorithm(alg); err != nil {
+		return nil, err
+	}
+	return l.replayPCR(alg, pcr)
+}
+
+// ReplayPCRs computes the expected values of every PCR that is measured to in this log for the specified algorithm, by replaying
+// the events in this log. PCRs that have no events recorded in the log are omitted. An error is returned if the log doesn't
+// contain digests for the specified algorithm, or if an event is missing a digest for it.
+func (l *Log) ReplayPCRs(alg AlgorithmId) (map[PCRIndex]Digest, error) {
+	if err := l.checkReplayAlgorithm(alg); err != nil {
+		return nil, err
+	}
+
+	out := make(map[PCRIndex]Digest)
+	for _, pcr := range l.measuredPCRs() {
+		value, err := l.replayPCR(alg, pcr)
+		if err != nil {
+			return nil, err
+		}
+		out[pcr] = value
+	}
+	return out, nil
+}
+
+// ReplayAllPCRs computes the expected values of every PCR that is measured to in this log for each of the supported algorithms in
+// this log. See ReplayPCRs for more details. If an event extended to a PCR is missing a digest for an algorithm, the value of that
+// PCR is omitted for that algorithm.
+func (l *Log) ReplayAllPCRs() map[PCRIndex]DigestMap {
+	out := make(map[PCRIndex]DigestMap)
+	for _, pcr := range l.measuredPCRs() {
+		out[pcr] = make(DigestMap)
+	}
+	for _, alg := range l.Algorithms {
+		if !alg.supported() {
+			continue
+		}
+		for pcr, digests := range out {
+			if value, err := l.replayPCR(alg, pcr); err == nil {
+				digests[alg] = value
+			}
+		}
+	}
+	return out
+}
+
+// ReplayedEvent associates an event with the value of its PCR immediately after the event was extended, as computed by replaying
+// the log.
+type ReplayedEvent struct {
+	*Event
+	PCRValue Digest // The value of the PCR after this event
+}
+
+// ReplayEvents replays the events in this log for the specified algorithm, and returns every event along with the running value of
+// its PCR after the event was extended. This makes it possible to see how each measurement contributes to the final PCR value, and
+// the last entry for a PCR contains the same value as ReplayPCR. EV_NO_ACTION events aren't extended, so the value associated with
+// these is the current value of the PCR. An error is returned if the log doesn't contain digests for the specified algorithm, or
+// if an event is missing a digest for it.
+func (l *Log) ReplayEvents(alg AlgorithmId) ([]*ReplayedEvent, error) {
+	if err := l.checkReplayAlgorithm(alg); err != nil {
+		return nil, err
+	}
+
+	values := make(map[PCRIndex]Digest)
+	var out []*ReplayedEvent
+	for _, e := range l.Events {
+		value, ok := values[e.PCRIndex]
+		if !ok {
+			value = l.initialPCRValue(alg, e.PCRIndex)
+		}
+		if e.EventType != EventTypeNoAction {
+			digest, ok := e.Digests[alg]
+			if !ok {
+				return nil, fmt.Errorf("event %d in PCR %d has no %v digest", e.Index, e.PCRIndex, alg)
+			}
+			value = extendDigest(alg, value, digest)
+		}
+		values[e.PCRIndex] = value
+		out = append(out, &ReplayedEvent{Event: e, PCRValue: value})
+	}
+	return out, nil
+}
+
+// measuredPCRs returns the indices of the PCRs that are extended by events in this log, in the order in which they first appear.
+func (l *Log) measuredPCRs() (out []PCRIndex) {
+	seen := make(map[PCRIndex]bool)
+	for _, e := range l.Events {
+		if e.EventType == EventTypeNoAction || seen[e.PCRIndex] {
+			continue
+		}
+		seen[e.PCRIndex] = true
+		out = append(out, e.PCRIndex)
+	}
+	return out
+}
+
+// StartupLocality returns the locality from which TPM2_Startup was executed, as recorded by a StartupLocality EV_NO_ACTION
+// event. It returns 0 if the log doesn't contain one. This determines the initial value of PCR 0.
+func (l *Log) StartupLocality() uint8 {
+	for _, e := range l.Events {
+		if e.PCRIndex != 0 || e.EventType != EventTypeNoAction {
+			continue
+		}
+		if d, ok := e.Data.(*StartupLocalityEventData); ok {
+			return d.StartupLocality
+		}
+	}
+	return 0
+}
+
+// HasDynamicLaunch indicates whether the log records a dynamic launch, which resets PCRs 17 to 22 to zero before the DRTM
+// measurements are extended to them. Every dynamic launch begins by measuring the DRTM component (the Intel TXT SINIT ACM or the
+// AMD secure loader block) to PCR 17, so this is true if the log contains events extended to PCR 17.
+func (l *Log) HasDynamicLaunch() bool {
+	for _, e := range l.Events {
+		if e.PCRIndex == 17 && e.EventType != EventTypeNoAction {
+			return true
+		}
+	}
+	return false
+}
+
+// HasHCRTM indicates whether the log records a H-CRTM sequence, either with an EV_EFI_HCRTM_EVENT event or a StartupLocality
+// event indicating locality 4. When a H-CRTM sequence occurs, the initial value of PCR 0 is not all zeroes.
+//
+// https://trustedcomputinggroup.org/wp-content/uploads/TCG_PCClientSpecPlat_TPM_2p0_1p04_pub.pdf
+//  (section 9.4.5.3 "Startup Locality Event", section 10.4.1 "PCR Initialization")
+func (l *Log) HasHCRTM() bool {
+	if l.StartupLocality() == 4 {
+		return true
+	}
+	for _, e := range l.Events {
+		if e.PCRIndex == 0 && e.EventType == EventTypeEFIHCRTMEvent {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,318 @@
+// Copyright 2019 Canonical Ltd.
+// Licensed under the LGPLv3 with static-linking exception.
+// See LICENCE file for details.
+
+package tcglog
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestDecodeEventDataHCRTM(t *testing.T) {
+	d := DecodeEventData(0, EventTypeEFIHCRTMEvent, DigestMap{}, []byte(HCRTMEventString), nil)
+	if _, ok := d.(*AsciiStringEventData); !ok {
+		t.Fatalf("Unexpected event data type: %T", d)
+	}
+	if d.String() != "HCRTM" {
+		t.Errorf("Unexpected string: %s", d)
+	}
+
+	if _, isErr := DecodeEventData(0, EventTypeEFIHCRTMEvent, DigestMap{}, []byte("foo"), nil).(error); !isErr {
+		t.Errorf("Decoding should fail with unexpected data")
+	}
+}
+
+func TestReplayPCR0(t *testing.T) {
+	startupLocality := func(locality uint8) *Event {
+		return makeTestEvent(0, EventTypeNoAction, append([]byte("StartupLocality\x00"), locality), AlgorithmSha256)
+	}
+	version := makeTestEvent(0, EventTypeSCRTMVersion, []byte{0x31, 0x00}, AlgorithmSha256)
+	hcrtm := makeTestEvent(0, EventTypeEFIHCRTMEvent, []byte(HCRTMEventString), AlgorithmSha256)
+
+	initial := func(v uint8) Digest {
+		d := make(Digest, AlgorithmSha256.Size())
+		d[len(d)-1] = v
+		return d
+	}
+
+	for _, data := range []struct {
+		desc    string
+		events  []*Event
+		hcrtm   bool
+		initial Digest
+	}{
+		{
+			desc:    "Locality0",
+			events:  []*Event{version},
+			initial: initial(0),
+		},
+		{
+			desc:    "Locality3",
+			events:  []*Event{startupLocality(3), version},
+			initial: initial(3),
+		},
+		{
+			desc:    "HCRTMLocality",
+			events:  []*Event{startupLocality(4), hcrtm, version},
+			hcrtm:   true,
+			initial: initial(4),
+		},
+		{
+			desc:    "HCRTMEvent",
+			events:  []*Event{hcrtm, version},
+			hcrtm:   true,
+			initial: initial(4),
+		},
+	} {
+		t.Run(data.desc, func(t *testing.T) {
+			log := NewLog(data.events)
+			if log.HasHCRTM() != data.hcrtm {
+				t.Errorf("Unexpected HasHCRTM result")
+			}
+
+			expected := data.initial
+			for _, e := range data.events {
+				if e.EventType == EventTypeNoAction {
+					continue
+				}
+				expected = extendDigest(AlgorithmSha256, expected, e.Digests[AlgorithmSha256])
+			}
+			value, err := log.ReplayPCR(AlgorithmSha256, 0)
+			if err != nil {
+				t.Fatalf("ReplayPCR failed: %v", err)
+			}
+			if !bytes.Equal(value, expected) {
+				t.Errorf("Unexpected PCR 0 value")
+			}
+		})
+	}
+}
+
+func TestReplayPCRs(t *testing.T) {
+	algs := AlgorithmIdList{AlgorithmSha1, AlgorithmSha256}
+	events := []*Event{
+		makeTestEvent(0, EventTypeNoAction, append([]byte("StartupLocality\x00"), 3), algs...),
+		makeTestEvent(0, EventTypeSCRTMVersion, []byte{0x31, 0x00}, algs...),
+		makeTestEvent(7, EventTypeSeparator, []byte{0, 0, 0, 0}, algs...),
+		makeTestEvent(4, EventTypeEFIAction, []byte("Calling EFI Application from Boot Option"), algs...),
+		makeTestEvent(4, EventTypeSeparator, []byte{0, 0, 0, 0}, algs...)}
+	log := NewLog(events)
+
+	expected := func(alg AlgorithmId, pcr PCRIndex) Digest {
+		value := make(Digest, alg.Size())
+		if pcr == 0 {
+			value[len(value)-1] = 3
+		}
+		for _, e := range events {
+			if e.PCRIndex != pcr || e.EventType == EventTypeNoAction {
+				continue
+			}
+			value = extendDigest(alg, value, e.Digests[alg])
+		}
+		return value
+	}
+
+	for _, alg := range algs {
+		values, err := log.ReplayPCRs(alg)
+		if err != nil {
+			t.Fatalf("ReplayPCRs failed: %v", err)
+		}
+		if len(values) != 3 {
+			t.Errorf("Unexpected number of PCRs (%d)", len(values))
+		}
+		for _, pcr := range []PCRIndex{0, 4, 7} {
+			if !bytes.Equal(values[pcr], expected(alg, pcr)) {
+				t.Errorf("Unexpected value for PCR %d, bank %v: %x", pcr, alg, values[pcr])
+			}
+			value, err := log.ReplayPCR(alg, pcr)
+			if err != nil {
+				t.Fatalf("ReplayPCR failed: %v", err)
+			}
+			if !bytes.Equal(value, values[pcr]) {
+				t.Errorf("Unexpected value for PCR %d, bank %v: %x", pcr, alg, value)
+			}
+		}
+	}
+
+	all := log.ReplayAllPCRs()
+	if len(all) != 3 {
+		t.Errorf("Unexpected number of PCRs (%d)", len(all))
+	}
+	for pcr, digests := range all {
+		if len(digests) != len(algs) {
+			t.Errorf("Unexpected number of banks for PCR %d (%d)", pcr, len(digests))
+		}
+		for alg, digest := range digests {
+			if !bytes.Equal(digest, expected(alg, pcr)) {
+				t.Errorf("Unexpected value for PCR %d, bank %v: %x", pcr, alg, digest)
+			}
+		}
+	}
+
+	value, err := log.ReplayPCR(AlgorithmSha256, 8)
+	if err != nil {
+		t.Fatalf("ReplayPCR failed: %v", err)
+	}
+	if !bytes.Equal(value, make(Digest, AlgorithmSha256.Size())) {
+		t.Errorf("Unexpected value for unused PCR: %x", value)
+	}
+
+	if _, err := log.ReplayPCRs(AlgorithmSha384); err == nil {
+		t.Errorf("ReplayPCRs should fail for a missing bank")
+	}
+	if _, err := log.ReplayPCR(AlgorithmSha384, 0); err == nil {
+		t.Errorf("ReplayPCR should fail for a missing bank")
+	}
+}
+
+func TestReplayEvents(t *testing.T) {
+	events := []*Event{
+		makeTestEvent(0, EventTypeNoAction, append([]byte("StartupLocality\x00"), 3), AlgorithmSha256),
+		makeTestEvent(0, EventTypeSCRTMVersion, []byte{0x31, 0x00}, AlgorithmSha256),
+		makeTestEvent(4, EventTypeEFIAction, []byte("Calling EFI Application from Boot Option"), AlgorithmSha256),
+		makeTestEvent(0, EventTypeSeparator, []byte{0, 0, 0, 0}, AlgorithmSha256),
+		makeTestEvent(4, EventTypeSeparator, []byte{0, 0, 0, 0}, AlgorithmSha256)}
+	log := NewLog(events)
+
+	replayed, err := log.ReplayEvents(AlgorithmSha256)
+	if err != nil {
+		t.Fatalf("ReplayEvents failed: %v", err)
+	}
+	if len(replayed) != len(events) {
+		t.Fatalf("Unexpected number of events (%d)", len(replayed))
+	}
+
+	pcr0 := make(Digest, AlgorithmSha256.Size())
+	pcr0[len(pcr0)-1] = 3
+	pcr4 := make(Digest, AlgorithmSha256.Size())
+	var expected []Digest
+	expected = append(expected, pcr0)
+	pcr0 = extendDigest(AlgorithmSha256, pcr0, events[1].Digests[AlgorithmSha256])
+	expected = append(expected, pcr0)
+	pcr4 = extendDigest(AlgorithmSha256, pcr4, events[2].Digests[AlgorithmSha256])
+	expected = append(expected, pcr4)
+	pcr0 = extendDigest(AlgorithmSha256, pcr0, events[3].Digests[AlgorithmSha256])
+	expected = append(expected, pcr0)
+	pcr4 = extendDigest(AlgorithmSha256, pcr4, events[4].Digests[AlgorithmSha256])
+	expected = append(expected, pcr4)
+
+	for i, e := range replayed {
+		if e.Event != events[i] {
+			t.Errorf("Unexpected event at index %d", i)
+		}
+		if !bytes.Equal(e.PCRValue, expected[i]) {
+			t.Errorf("Unexpected PCR value at index %d: %x", i, e.PCRValue)
+		}
+	}
+
+	for _, pcr := range []PCRIndex{0, 4} {
+		value, _ := log.ReplayPCR(AlgorithmSha256, pcr)
+		last := pcr0
+		if pcr == 4 {
+			last = pcr4
+		}
+		if !bytes.Equal(value, last) {
+			t.Errorf("Final value of PCR %d is inconsistent with ReplayPCR", pcr)
+		}
+	}
+
+	if _, err := log.ReplayEvents(AlgorithmSha1); err == nil {
+		t.Errorf("ReplayEvents should fail for a missing bank")
+	}
+}
+
+func TestReplayDRTMPCRs(t *testing.T) {
+	ones := bytes.Repeat([]byte{0xff}, AlgorithmSha256.Size())
+	zeroes := make(Digest, AlgorithmSha256.Size())
+
+	for _, data := range []struct {
+		desc     string
+		events   []*Event
+		launch   bool
+		pcr      PCRIndex
+		expected Digest
+	}{
+		{
+			desc:     "NoLaunch",
+			events:   []*Event{makeTestEvent(18, EventTypeEventTag, []byte{1, 2, 3}, AlgorithmSha256)},
+			pcr:      18,
+			expected: ones,
+		},
+		{
+			desc: "Launch",
+			events: []*Event{
+				makeTestEvent(17, EventTypeTXTHashStart, []byte{}, AlgorithmSha256),
+				makeTestEvent(18, EventTypeEventTag, []byte{1, 2, 3}, AlgorithmSha256)},
+			launch:   true,
+			pcr:      18,
+			expected: zeroes,
+		},
+		{
+			desc:     "PCR16",
+			events:   []*Event{makeTestEvent(16, EventTypeEventTag, []byte{1, 2, 3}, AlgorithmSha256)},
+			pcr:      16,
+			expected: zeroes,
+		},
+		{
+			desc:     "PCR23",
+			events:   []*Event{makeTestEvent(23, EventTypeEventTag, []byte{1, 2, 3}, AlgorithmSha256)},
+			pcr:      23,
+			expected: zeroes,
+		},
+	} {
+		t.Run(data.desc, func(t *testing.T) {
+			log := NewLog(data.events)
+			if log.HasDynamicLaunch() != data.launch {
+				t.Errorf("Unexpected HasDynamicLaunch result")
+			}
+
+			expected := data.expected
+			for _, e := range data.events {
+				if e.PCRIndex == data.pcr {
+					expected = extendDigest(AlgorithmSha256, expected, e.Digests[AlgorithmSha256])
+				}
+			}
+			value, err := log.ReplayPCR(AlgorithmSha256, data.pcr)
+			if err != nil {
+				t.Fatalf("ReplayPCR failed: %v", err)
+			}
+			if !bytes.Equal(value, expected) {
+				t.Errorf("Unexpected value for PCR %d: %x", data.pcr, value)
+			}
+		})
+	}
+}
+
+func TestReplayMissingDigest(t *testing.T) {
+	algs := AlgorithmIdList{AlgorithmSha1, AlgorithmSha256}
+	events := []*Event{
+		makeTestEvent(0, EventTypeSCRTMVersion, []byte{0x31, 0x00}, algs...),
+		makeTestEvent(4, EventTypeEFIAction, []byte("Calling EFI Application from Boot Option"), algs...),
+		makeTestEvent(4, EventTypeSeparator, []byte{0, 0, 0, 0}, algs...)}
+	delete(events[1].Digests, AlgorithmSha256)
+	log := &Log{Spec: SpecEFI_2, Algorithms: algs, Events: events}
+	events[2].Index = 1
+
+	const expectedErr = "event 0 in PCR 4 has no SHA-256 digest"
+	if _, err := log.ReplayPCR(AlgorithmSha256, 4); err == nil || err.Error() != expectedErr {
+		t.Errorf("Unexpected error from ReplayPCR: %v", err)
+	}
+	if _, err := log.ReplayPCRs(AlgorithmSha256); err == nil || err.Error() != expectedErr {
+		t.Errorf("Unexpected error from ReplayPCRs: %v", err)
+	}
+	if _, err := log.ReplayEvents(AlgorithmSha256); err == nil || err.Error() != expectedErr {
+		t.Errorf("Unexpected error from ReplayEvents: %v", err)
+	}
+	if _, err := log.ReplayPCR(AlgorithmSha256, 0); err != nil {
+		t.Errorf("ReplayPCR failed for PCR 0: %v", err)
+	}
+
+	all := log.ReplayAllPCRs()
+	if _, ok := all[4][AlgorithmSha256]; ok {
+		t.Errorf("ReplayAllPCRs should omit the SHA-256 value of PCR 4")
+	}
+	if len(all[4]) != 1 || len(all[0]) != 2 {
+		t.Errorf("Unexpected result from ReplayAllPCRs: %v", all)
+	}
+}
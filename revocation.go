@@ -0,0 +1,138 @@
+// Copyright 2019 Canonical Ltd.
+// Licensed under the LGPLv3 with static-linking exception.
+// See LICENCE file for details.
+
+package tcglog
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"fmt"
+)
+
+// Revocation describes a boot component measured in the log that is revoked by an entry in the dbx measured earlier in the same
+// log. The component is either an image, identified by the Authenticode digest of an EV_EFI_BOOT_SERVICES_APPLICATION,
+// EV_EFI_BOOT_SERVICES_DRIVER or EV_EFI_RUNTIME_SERVICES_DRIVER event, or a certificate measured by an
+// EV_EFI_VARIABLE_AUTHORITY event.
+type Revocation struct {
+	Event *Event            // The event that measures the revoked component
+	Dbx   *Event            // The measurement of dbx that contains the revoking entry
+	Entry *EFISignatureData // The entry in dbx that revokes the component
+}
+
+func (r *Revocation) String() string {
+	return fmt.Sprintf("event %d in PCR %d (type: %s) is revoked by %s entry in dbx measured by event %d", r.Event.Index,
+		r.Event.PCRIndex, r.Event.EventType, efiSignatureTypeString(r.Entry.SignatureType), r.Dbx.Index)
+}
+
+// findImageRevocation returns the entry in dbx that revokes the image measured by the supplied event. Images are matched by
+// their Authenticode digest, which is what the firmware measures for image load events.
+func findImageRevocation(e *Event, dbx EFISignatureDatabase) *EFISignatureData {
+	for _, l := range dbx {
+		for _, s := range l.Signatures {
+			var digest Digest
+			switch s.SignatureType {
+			case EFICertSha1Guid:
+				digest = e.Digests[AlgorithmSha1]
+			case EFICertSha256Guid:
+				digest = e.Digests[AlgorithmSha256]
+			default:
+				continue
+			}
+			if len(digest) > 0 && bytes.Equal(s.Data, digest) {
+				return s
+			}
+		}
+	}
+	return nil
+}
+
+// findAuthorityRevocation returns the entry in dbx that revokes the supplied authority. A certificate is revoked if it is in dbx,
+// if the SHA-256 digest of its TBSCertificate is in dbx or if it is issued by a certificate in dbx.
+func findAuthorityRevocation(sig *EFISignatureData, dbx EFISignatureDatabase) *EFISignatureData {
+	cert, err := sig.Certificate()
+	for _, l := range dbx {
+		for _, s := range l.Signatures {
+			switch {
+			case s.SignatureType == sig.SignatureType && bytes.Equal(s.Data, sig.Data):
+				return s
+			case err != nil:
+				continue
+			case s.SignatureType == EFICertX509Sha256Guid:
+				h := sha256.Sum256(cert.RawTBSCertificate)
+				if len(s.Data) >= len(h) && bytes.Equal(s.Data[:len(h)], h[:]) {
+					return s
+				}
+			case s.SignatureType == EFICertX509Guid:
+				issuer, err := s.Certificate()
+				if err != nil {
+					continue
+				}
+				if cert.CheckSignatureFrom(issuer) == nil {
+					return s
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// Revocations cross-references the contents of dbx measured to PCR 7 against the images and authorities measured later in this
+// log, and returns each boot component that is revoked by the dbx that the platform measured. A component that is revoked by
+// the measured dbx should not have been permitted to run, which indicates that the firmware isn't enforcing dbx or that the log
+// is inconsistent.
+//
+// Each component is checked against the most recent measurement of dbx that precedes it. Components measured before dbx is
+// measured aren't checked.
+func (l *Log) Revocations() (out []*Revocation) {
+	var dbxEvent *Event
+	var dbx EFISignatureDatabase
+
+	for _, e := range l.Events {
+		var entry *EFISignatureData
+		switch {
+		case e.PCRIndex == 7 && e.EventType == EventTypeEFIVariableDriverConfig:
+			if d, ok := e.Data.(*EFIVariableData); ok && d.VariableName == EFIImageSecurityDatabaseGuid && d.UnicodeName == "dbx" {
+				dbxEvent = e
+				dbx, _ = d.SignatureDatabase()
+			}
+			continue
+		case dbxEvent == nil:
+			continue
+		case isImageLoadEvent(e):
+			entry = findImageRevocation(e, dbx)
+		case e.PCRIndex == 7 && e.EventType == EventTypeEFIVariableAuthority:
+			d, ok := e.Data.(*EFIVariableData)
+			if !ok {
+				continue
+			}
+			sig, err := d.AuthoritySignature()
+			if err != nil {
+				continue
+			}
+			entry = findAuthorityRevocation(sig, dbx)
+		}
+
+		if entry != nil {
+			out = append(out, &Revocation{Event: e, Dbx: dbxEvent, Entry: entry})
+		}
+	}
+	return out
+}
+
+// CheckRevocations is a ValidationCheck that reports images and authorities measured in the log that are revoked by the dbx
+// measured earlier in the same log (see Log.Revocations).
+func CheckRevocations(log *Log) (out []*ValidationFinding) {
+	for _, r := range log.Revocations() {
+		what := "the image"
+		if r.Event.EventType == EventTypeEFIVariableAuthority {
+			what = "the authority"
+		}
+		out = append(out, &ValidationFinding{
+			Severity: SeverityError,
+			Event:    r.Event,
+			Message: fmt.Sprintf("%s is revoked by a %s entry in the dbx measured by event %d", what,
+				efiSignatureTypeString(r.Entry.SignatureType), r.Dbx.Index)})
+	}
+	return out
+}
@@ -0,0 +1,101 @@
+// Copyright 2019 Canonical Ltd.
+// Licensed under the LGPLv3 with static-linking exception.
+// See LICENCE file for details.
+
+package tcglog
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"crypto/x509"
+	"reflect"
+	"testing"
+)
+
+func TestRevocations(t *testing.T) {
+	owner := MakeEFIGUID(0x77fa9abd, 0x0359, 0x4d32, 0xbd60, [...]uint8{0x28, 0xf4, 0xe7, 0x8f, 0x78, 0x4b})
+	ca, leaf := makeTestCertificateChain(t)
+	revoked := makeTestCertificate(t, "Revoked")
+	hashed := makeTestCertificate(t, "Hashed")
+	trusted := makeTestCertificate(t, "Trusted")
+
+	hashedCert, err := x509.ParseCertificate(hashed)
+	if err != nil {
+		t.Fatalf("ParseCertificate failed: %v", err)
+	}
+	tbsDigest := sha256.Sum256(hashedCert.RawTBSCertificate)
+	revokedImage := sha256.Sum256([]byte("revoked image"))
+
+	var dbxData bytes.Buffer
+	dbxData.Write(makeTestSignatureList(EFICertSha256Guid, owner, revokedImage[:]))
+	dbxData.Write(makeTestSignatureList(EFICertX509Guid, owner, revoked))
+	dbxData.Write(makeTestSignatureList(EFICertX509Guid, owner, ca))
+	dbxData.Write(makeTestSignatureList(EFICertX509Sha256Guid, owner, append(tbsDigest[:], make([]byte, 16)...)))
+
+	variable := func(eventType EventType, name string, data []byte) *Event {
+		varData := EFIVariableData{VariableName: EFIImageSecurityDatabaseGuid, UnicodeName: name, VariableData: data}
+		var b bytes.Buffer
+		varData.EncodeMeasuredBytes(&b)
+		return makeTestEvent(7, eventType, b.Bytes(), AlgorithmSha256)
+	}
+	image := func(digest []byte) *Event {
+		e := makeTestEvent(4, EventTypeEFIBootServicesApplication, makeTestImageLoadEventData("\\EFI\\ubuntu\\shimx64.efi"),
+			AlgorithmSha256)
+		e.Digests[AlgorithmSha256] = digest
+		return e
+	}
+
+	early := image(revokedImage[:])
+	dbx := variable(EventTypeEFIVariableDriverConfig, "dbx", dbxData.Bytes())
+	revokedAuthority := variable(EventTypeEFIVariableAuthority, "db", append(owner[:], revoked...))
+	issuedAuthority := variable(EventTypeEFIVariableAuthority, "db", append(owner[:], leaf...))
+	hashedAuthority := variable(EventTypeEFIVariableAuthority, "db", append(owner[:], hashed...))
+	trustedAuthority := variable(EventTypeEFIVariableAuthority, "db", append(owner[:], trusted...))
+	revokedLoad := image(revokedImage[:])
+	otherImage := sha256.Sum256([]byte("other image"))
+	otherLoad := image(otherImage[:])
+
+	log := NewLog([]*Event{
+		early,
+		dbx,
+		revokedAuthority,
+		issuedAuthority,
+		hashedAuthority,
+		trustedAuthority,
+		revokedLoad,
+		otherLoad})
+
+	var events []*Event
+	var types []EFIGUID
+	for _, r := range log.Revocations() {
+		if r.Dbx != dbx {
+			t.Errorf("Unexpected dbx event for %s", r)
+		}
+		events = append(events, r.Event)
+		types = append(types, r.Entry.SignatureType)
+	}
+	if !reflect.DeepEqual(events, []*Event{revokedAuthority, issuedAuthority, hashedAuthority, revokedLoad}) {
+		t.Errorf("Unexpected revoked events")
+	}
+	if !reflect.DeepEqual(types, []EFIGUID{EFICertX509Guid, EFICertX509Guid, EFICertX509Sha256Guid, EFICertSha256Guid}) {
+		t.Errorf("Unexpected revoking entries: %v", types)
+	}
+
+	v := new(Validator)
+	v.AddCheck("dbx-revocations", CheckRevocations)
+	var findings []string
+	for _, f := range v.Validate(log) {
+		findings = append(findings, f.String())
+	}
+	if !reflect.DeepEqual(findings, []string{
+		"error: dbx-revocations: event 1 in PCR 7 (type: EV_EFI_VARIABLE_AUTHORITY): the authority is revoked by a X509 entry " +
+			"in the dbx measured by event 0",
+		"error: dbx-revocations: event 2 in PCR 7 (type: EV_EFI_VARIABLE_AUTHORITY): the authority is revoked by a X509 entry " +
+			"in the dbx measured by event 0",
+		"error: dbx-revocations: event 3 in PCR 7 (type: EV_EFI_VARIABLE_AUTHORITY): the authority is revoked by a " +
+			"X509_SHA256 entry in the dbx measured by event 0",
+		"error: dbx-revocations: event 1 in PCR 4 (type: EV_EFI_BOOT_SERVICES_APPLICATION): the image is revoked by a SHA256 " +
+			"entry in the dbx measured by event 0"}) {
+		t.Errorf("Unexpected findings: %q", findings)
+	}
+}
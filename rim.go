@@ -0,0 +1,202 @@
+// Copyright 2019 Canonical Ltd.
+// Licensed under the LGPLv3 with static-linking exception.
+// See LICENCE file for details.
+
+package tcglog
+
+import (
+	"crypto/sha256"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"sort"
+)
+
+const (
+	swidNamespace = "http://standards.iso.org/iso/19770/-2/2015/schema.xsd"
+	rimNamespace  = "https://trustedcomputinggroup.org/resource/tcg-reference-integrity-manifest-rim-information-model/"
+)
+
+// rimHashNamespaces are the XML namespaces used to identify the algorithm of each hash attribute, as used by the TCG PC Client RIM
+// specification.
+var rimHashNamespaces = map[AlgorithmId]struct {
+	prefix string
+	uri    string
+}{
+	AlgorithmSha1:   {"SHA1", "http://www.w3.org/2000/09/xmldsig#sha1"},
+	AlgorithmSha256: {"SHA256", "http://www.w3.org/2001/04/xmlenc#sha256"},
+	AlgorithmSha384: {"SHA384", "http://www.w3.org/2001/04/xmldsig-more#sha384"},
+	AlgorithmSha512: {"SHA512", "http://www.w3.org/2001/04/xmlenc#sha512"},
+}
+
+// ReferenceManifestOptions allows the contents of a reference manifest written by Log.WriteReferenceManifest to be customized.
+type ReferenceManifestOptions struct {
+	Name          string // The name of the manifest, which defaults to "measured-boot"
+	TagID         string // The unique identifier of the manifest, which defaults to a UUID derived from the measurements
+	Version       string // The version of the manifest, which defaults to the S-CRTM version recorded in the log
+	EntityName    string // The name of the entity that created the manifest, which defaults to "tcglog-parser"
+	EntityRegID   string // The registration ID (domain name) of the entity that created the manifest
+	PlatformModel string // The platform model that the manifest applies to
+}
+
+type rimEntity struct {
+	Name  string `xml:"name,attr"`
+	RegID string `xml:"regid,attr,omitempty"`
+	Role  string `xml:"role,attr"`
+}
+
+type rimMeta struct {
+	Attrs []xml.Attr `xml:",any,attr"`
+}
+
+type rimResource struct {
+	Type  string     `xml:"type,attr"`
+	Name  string     `xml:"name,attr"`
+	Attrs []xml.Attr `xml:",any,attr"`
+}
+
+type rimPayload struct {
+	Resources []*rimResource `xml:"Resource"`
+}
+
+type rimSoftwareIdentity struct {
+	XMLName      xml.Name    `xml:"SoftwareIdentity"`
+	Attrs        []xml.Attr  `xml:",any,attr"`
+	Name         string      `xml:"name,attr"`
+	TagID        string      `xml:"tagId,attr"`
+	Version      string      `xml:"version,attr"`
+	Corpus       bool        `xml:"corpus,attr"`
+	Patch        bool        `xml:"patch,attr"`
+	Supplemental bool        `xml:"supplemental,attr"`
+	TagVersion   int         `xml:"tagVersion,attr"`
+	Entity       *rimEntity  `xml:"Entity"`
+	Meta         *rimMeta    `xml:"Meta"`
+	Payload      *rimPayload `xml:"Payload"`
+}
+
+// rimAttr returns an attribute with a name that includes a namespace prefix. The prefixes are declared explicitly on the root
+// element because encoding/xml doesn't support choosing them.
+func rimAttr(prefix, name, value string) xml.Attr {
+	return xml.Attr{Name: xml.Name{Local: prefix + ":" + name}, Value: value}
+}
+
+func rimHashAttrs(digests DigestMap) (out []xml.Attr) {
+	var algs []AlgorithmId
+	for alg := range digests {
+		if _, ok := rimHashNamespaces[alg]; ok {
+			algs = append(algs, alg)
+		}
+	}
+	sort.Slice(algs, func(i, j int) bool { return algs[i] < algs[j] })
+	for _, alg := range algs {
+		out = append(out, rimAttr(rimHashNamespaces[alg].prefix, "hash", fmt.Sprintf("%x", digests[alg])))
+	}
+	return out
+}
+
+// WriteReferenceManifest writes a reference integrity manifest to w that describes the expected measurements of this log, which
+// should be a known-good log. It is an unsigned SWID tag in the style of a TCG PC Client base RIM. The payload contains a Resource
+// element of type "measurement" for each component (see SBOMComponents) with the PCR, event type and digests that it is
+// expected to be measured with, followed by a Resource element of type "pcr" for each measured PCR with its expected final value.
+// Digests are recorded in the hash attribute of the namespace for the algorithm, as defined by the TCG PC Client RIM
+// specification. The supplied options may be nil, in which case the defaults are used.
+func (l *Log) WriteReferenceManifest(w io.Writer, options *ReferenceManifestOptions) error {
+	if options == nil {
+		options = &ReferenceManifestOptions{}
+	}
+
+	tag := &rimSoftwareIdentity{
+		Attrs: []xml.Attr{
+			{Name: xml.Name{Local: "xmlns"}, Value: swidNamespace},
+			{Name: xml.Name{Local: "xmlns:rim"}, Value: rimNamespace}},
+		Name:    options.Name,
+		TagID:   options.TagID,
+		Version: options.Version,
+		Entity:  &rimEntity{Name: options.EntityName, RegID: options.EntityRegID, Role: "softwareCreator tagCreator"},
+		Meta:    &rimMeta{},
+		Payload: &rimPayload{}}
+	if tag.Name == "" {
+		tag.Name = "measured-boot"
+	}
+	if tag.Version == "" {
+		tag.Version = l.FirmwareVersion()
+	}
+	if tag.Version == "" {
+		tag.Version = "0"
+	}
+	if tag.Entity.Name == "" {
+		tag.Entity.Name = "tcglog-parser"
+	}
+
+	for _, alg := range []AlgorithmId{AlgorithmSha1, AlgorithmSha256, AlgorithmSha384, AlgorithmSha512} {
+		if l.Algorithms.Contains(alg) {
+			ns := rimHashNamespaces[alg]
+			tag.Attrs = append(tag.Attrs, xml.Attr{Name: xml.Name{Local: "xmlns:" + ns.prefix}, Value: ns.uri})
+		}
+	}
+
+	tag.Meta.Attrs = append(tag.Meta.Attrs,
+		rimAttr("rim", "colloquialVersion", tag.Version),
+		rimAttr("rim", "product", tag.Name),
+		rimAttr("rim", "bindingSpec", "PC Client RIM"),
+		rimAttr("rim", "bindingSpecVersion", "1.2"))
+	if vendor := l.FirmwareVendor(); vendor != "" {
+		tag.Meta.Attrs = append(tag.Meta.Attrs, rimAttr("rim", "firmwareManufacturerStr", vendor))
+	}
+	if options.PlatformModel != "" {
+		tag.Meta.Attrs = append(tag.Meta.Attrs, rimAttr("rim", "platformModel", options.PlatformModel))
+	}
+
+	for _, c := range l.SBOMComponents() {
+		r := &rimResource{Type: "measurement", Name: c.Name}
+		r.Attrs = append(r.Attrs,
+			xml.Attr{Name: xml.Name{Local: "pcr"}, Value: fmt.Sprintf("%d", c.Event.PCRIndex)},
+			xml.Attr{Name: xml.Name{Local: "eventType"}, Value: c.Event.EventType.String()})
+		if c.Version != "" {
+			r.Attrs = append(r.Attrs, xml.Attr{Name: xml.Name{Local: "version"}, Value: c.Version})
+		}
+		if c.Path != "" {
+			r.Attrs = append(r.Attrs, xml.Attr{Name: xml.Name{Local: "path"}, Value: c.Path})
+		}
+		r.Attrs = append(r.Attrs, rimHashAttrs(c.Event.Digests)...)
+		tag.Payload.Resources = append(tag.Payload.Resources, r)
+	}
+
+	values := l.ReplayAllPCRs()
+	var pcrs []PCRIndex
+	for pcr := range values {
+		pcrs = append(pcrs, pcr)
+	}
+	sort.Slice(pcrs, func(i, j int) bool { return pcrs[i] < pcrs[j] })
+
+	// The default tag ID is a version 5 style UUID derived from the expected PCR values, so that the same known-good log always
+	// produces the same identifier.
+	h := sha256.New()
+	for _, pcr := range pcrs {
+		r := &rimResource{Type: "pcr", Name: fmt.Sprintf("PCR %d", pcr)}
+		r.Attrs = append(r.Attrs, xml.Attr{Name: xml.Name{Local: "pcr"}, Value: fmt.Sprintf("%d", pcr)})
+		r.Attrs = append(r.Attrs, rimHashAttrs(values[pcr])...)
+		tag.Payload.Resources = append(tag.Payload.Resources, r)
+		for _, a := range r.Attrs {
+			h.Write([]byte(a.Value))
+		}
+	}
+
+	if tag.TagID == "" {
+		id := h.Sum(nil)[:16]
+		id[6] = (id[6] & 0x0f) | 0x50
+		id[8] = (id[8] & 0x3f) | 0x80
+		tag.TagID = fmt.Sprintf("%x-%x-%x-%x-%x", id[0:4], id[4:6], id[6:8], id[8:10], id[10:16])
+	}
+
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	enc := xml.NewEncoder(w)
+	enc.Indent("", "  ")
+	if err := enc.Encode(tag); err != nil {
+		return err
+	}
+	_, err := io.WriteString(w, "\n")
+	return err
+}
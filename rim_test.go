@@ -0,0 +1,111 @@
+// Copyright 2019 Canonical Ltd.
+// Licensed under the LGPLv3 with static-linking exception.
+// See LICENCE file for details.
+
+package tcglog
+
+import (
+	"bytes"
+	"encoding/hex"
+	"encoding/xml"
+	"testing"
+)
+
+func TestWriteReferenceManifest(t *testing.T) {
+	log := makeSBOMTestLog()
+	var b bytes.Buffer
+	if err := log.WriteReferenceManifest(&b, &ReferenceManifestOptions{EntityRegID: "example.com",
+		PlatformModel: "Test Model"}); err != nil {
+		t.Fatalf("WriteReferenceManifest failed: %v", err)
+	}
+
+	var tag struct {
+		XMLName xml.Name
+		Name    string `xml:"name,attr"`
+		TagID   string `xml:"tagId,attr"`
+		Version string `xml:"version,attr"`
+		Entity  struct {
+			Name  string `xml:"name,attr"`
+			RegID string `xml:"regid,attr"`
+		}
+		Meta struct {
+			Attrs []xml.Attr `xml:",any,attr"`
+		}
+		Resources []struct {
+			Type  string     `xml:"type,attr"`
+			Name  string     `xml:"name,attr"`
+			Attrs []xml.Attr `xml:",any,attr"`
+		} `xml:"Payload>Resource"`
+	}
+	if err := xml.Unmarshal(b.Bytes(), &tag); err != nil {
+		t.Fatalf("Unmarshal failed: %v", err)
+	}
+
+	if tag.XMLName.Space != swidNamespace || tag.XMLName.Local != "SoftwareIdentity" || tag.Name != "measured-boot" ||
+		tag.Version != "1.02" || tag.Entity.Name != "tcglog-parser" || tag.Entity.RegID != "example.com" {
+		t.Errorf("Unexpected tag:\n%s", b.String())
+	}
+	if tag.TagID != "64b026dd-8955-57df-81f3-6d24702a1954" {
+		t.Errorf("Unexpected tag ID: %s", tag.TagID)
+	}
+
+	meta := make(map[string]string)
+	for _, a := range tag.Meta.Attrs {
+		if a.Name.Space == rimNamespace {
+			meta[a.Name.Local] = a.Value
+		}
+	}
+	if meta["colloquialVersion"] != "1.02" || meta["platformModel"] != "Test Model" || meta["bindingSpec"] != "PC Client RIM" {
+		t.Errorf("Unexpected meta: %v", meta)
+	}
+
+	if len(tag.Resources) != 6 {
+		t.Fatalf("Unexpected number of resources: %d", len(tag.Resources))
+	}
+	attrs := func(i int) map[string]string {
+		out := make(map[string]string)
+		for _, a := range tag.Resources[i].Attrs {
+			out[a.Name.Space+" "+a.Name.Local] = a.Value
+		}
+		return out
+	}
+
+	shim := attrs(2)
+	if tag.Resources[2].Type != "measurement" || tag.Resources[2].Name != "shimx64.efi" || shim[" pcr"] != "4" ||
+		shim[" path"] != "/EFI/ubuntu/shimx64.efi" ||
+		shim["http://www.w3.org/2001/04/xmlenc#sha256 hash"] != hex.EncodeToString(log.Events[3].Digests[AlgorithmSha256]) ||
+		shim["http://www.w3.org/2000/09/xmldsig#sha1 hash"] != hex.EncodeToString(log.Events[3].Digests[AlgorithmSha1]) {
+		t.Errorf("Unexpected measurement resource: %v", shim)
+	}
+
+	expected, err := log.ReplayPCR(AlgorithmSha256, 4)
+	if err != nil {
+		t.Fatalf("ReplayPCR failed: %v", err)
+	}
+	pcr4 := attrs(5)
+	if tag.Resources[5].Type != "pcr" || pcr4[" pcr"] != "4" ||
+		pcr4["http://www.w3.org/2001/04/xmlenc#sha256 hash"] != hex.EncodeToString(expected) {
+		t.Errorf("Unexpected PCR resource: %v", pcr4)
+	}
+}
+
+func TestWriteReferenceManifestDefaultOptions(t *testing.T) {
+	log := makeSBOMTestLog()
+	var b bytes.Buffer
+	if err := log.WriteReferenceManifest(&b, nil); err != nil {
+		t.Fatalf("WriteReferenceManifest failed: %v", err)
+	}
+
+	var tag struct {
+		Name   string `xml:"name,attr"`
+		Entity struct {
+			Name string `xml:"name,attr"`
+		}
+	}
+	if err := xml.Unmarshal(b.Bytes(), &tag); err != nil {
+		t.Fatalf("Unmarshal failed: %v", err)
+	}
+	if tag.Name != "measured-boot" || tag.Entity.Name != "tcglog-parser" {
+		t.Errorf("Unexpected tag: %s, %s", tag.Name, tag.Entity.Name)
+	}
+}
@@ -0,0 +1,312 @@
+// Copyright 2019 Canonical Ltd.
+// Licensed under the LGPLv3 with static-linking exception.
+// See LICENCE file for details.
+
+package tcglog
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"time"
+)
+
+// SBOMFormat describes the format of a software bill of materials written by Log.WriteSBOM.
+type SBOMFormat int
+
+const (
+	// SBOMFormatCycloneDX is the CycloneDX 1.4 JSON format.
+	SBOMFormatCycloneDX SBOMFormat = iota
+
+	// SBOMFormatSPDX is the SPDX 2.3 JSON format.
+	SBOMFormatSPDX
+)
+
+func (f SBOMFormat) String() string {
+	switch f {
+	case SBOMFormatCycloneDX:
+		return "cyclonedx"
+	case SBOMFormatSPDX:
+		return "spdx"
+	default:
+		return fmt.Sprintf("%%!(UNKNOWN_SBOM_FORMAT=%d)", int(f))
+	}
+}
+
+// ParseSBOMFormat returns the SBOMFormat with the supplied name ("cyclonedx" or "spdx").
+func ParseSBOMFormat(name string) (SBOMFormat, error) {
+	for _, f := range []SBOMFormat{SBOMFormatCycloneDX, SBOMFormatSPDX} {
+		if strings.ToLower(name) == f.String() {
+			return f, nil
+		}
+	}
+	return 0, fmt.Errorf("unrecognized SBOM format \"%s\"", name)
+}
+
+// SBOMOptions allows the behaviour of Log.WriteSBOM to be controlled.
+type SBOMOptions struct {
+	Format  SBOMFormat
+	Name    string    // The name of the document, which defaults to "measured-boot"
+	Created time.Time // The creation time recorded in the document, which defaults to the current time
+}
+
+// SBOMComponent is a measured boot component that is included in a software bill of materials.
+type SBOMComponent struct {
+	Name     string
+	Version  string // The version of the component, if it can be derived from the log
+	Path     string // The path from which the component was loaded, if any
+	Firmware bool   // Whether this is a firmware component rather than an EFI application
+	Event    *Event
+}
+
+// SBOMComponents returns the measured boot components that are included in a software bill of materials: the firmware components
+// from FirmwareInventory, with the S-CRTM version recorded against the S-CRTM and platform firmware, followed by each EFI
+// application that was loaded.
+func (l *Log) SBOMComponents() (out []*SBOMComponent) {
+	inventory := l.FirmwareInventory()
+	for _, c := range inventory.Components {
+		sc := &SBOMComponent{Name: c.Description, Firmware: true, Event: c.Event}
+		if sc.Name == "" {
+			sc.Name = c.Kind.String()
+		}
+		switch c.Kind {
+		case FirmwareComponentSCRTM, FirmwareComponentPlatformFirmware:
+			sc.Version = inventory.Version
+		}
+		if d, ok := c.Event.Data.(*EFIImageLoadEvent); ok {
+			sc.Path = d.FilePath()
+		}
+		out = append(out, sc)
+	}
+
+	for _, e := range l.Events {
+		if e.EventType != EventTypeEFIBootServicesApplication {
+			continue
+		}
+		sc := &SBOMComponent{Name: "EFI application", Event: e}
+		if d, ok := e.Data.(*EFIImageLoadEvent); ok {
+			sc.Path = d.FilePath()
+			if sc.Path != "" {
+				sc.Name = sc.Path[strings.LastIndex(sc.Path, "/")+1:]
+			}
+		}
+		out = append(out, sc)
+	}
+
+	return out
+}
+
+// sbomDigests returns the algorithms for which the supplied event has digests in ascending order, so that the output is stable.
+func sbomDigests(e *Event) (algs []AlgorithmId) {
+	for alg := range e.Digests {
+		algs = append(algs, alg)
+	}
+	sort.Slice(algs, func(i, j int) bool { return algs[i] < algs[j] })
+	return algs
+}
+
+type cycloneDXHash struct {
+	Alg     string `json:"alg"`
+	Content string `json:"content"`
+}
+
+type cycloneDXProperty struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+}
+
+type cycloneDXComponent struct {
+	Type       string               `json:"type"`
+	BOMRef     string               `json:"bom-ref"`
+	Name       string               `json:"name"`
+	Version    string               `json:"version,omitempty"`
+	Hashes     []*cycloneDXHash     `json:"hashes"`
+	Properties []*cycloneDXProperty `json:"properties"`
+}
+
+type cycloneDXTool struct {
+	Name string `json:"name"`
+}
+
+type cycloneDXMetadata struct {
+	Timestamp string           `json:"timestamp"`
+	Tools     []*cycloneDXTool `json:"tools"`
+}
+
+type cycloneDXDocument struct {
+	BOMFormat   string                `json:"bomFormat"`
+	SpecVersion string                `json:"specVersion"`
+	Version     int                   `json:"version"`
+	Metadata    *cycloneDXMetadata    `json:"metadata"`
+	Components  []*cycloneDXComponent `json:"components"`
+}
+
+type spdxChecksum struct {
+	Algorithm     string `json:"algorithm"`
+	ChecksumValue string `json:"checksumValue"`
+}
+
+type spdxPackage struct {
+	Name                  string          `json:"name"`
+	SPDXID                string          `json:"SPDXID"`
+	VersionInfo           string          `json:"versionInfo,omitempty"`
+	DownloadLocation      string          `json:"downloadLocation"`
+	FilesAnalyzed         bool            `json:"filesAnalyzed"`
+	Checksums             []*spdxChecksum `json:"checksums"`
+	PrimaryPackagePurpose string          `json:"primaryPackagePurpose"`
+	Comment               string          `json:"comment"`
+}
+
+type spdxRelationship struct {
+	SPDXElementID      string `json:"spdxElementId"`
+	RelationshipType   string `json:"relationshipType"`
+	RelatedSPDXElement string `json:"relatedSpdxElement"`
+}
+
+type spdxCreationInfo struct {
+	Created  string   `json:"created"`
+	Creators []string `json:"creators"`
+}
+
+type spdxDocument struct {
+	SPDXVersion       string              `json:"spdxVersion"`
+	DataLicense       string              `json:"dataLicense"`
+	SPDXID            string              `json:"SPDXID"`
+	Name              string              `json:"name"`
+	DocumentNamespace string              `json:"documentNamespace"`
+	CreationInfo      *spdxCreationInfo   `json:"creationInfo"`
+	Packages          []*spdxPackage      `json:"packages"`
+	Relationships     []*spdxRelationship `json:"relationships"`
+}
+
+// sbomComponentComment describes where the supplied component was measured. The digests of a component are those recorded in
+// the log, which are Authenticode digests for EFI images rather than digests of the file.
+func sbomComponentComment(c *SBOMComponent) string {
+	s := fmt.Sprintf("measured by event %d in PCR %d (type: %s)", c.Event.Index, c.Event.PCRIndex, c.Event.EventType)
+	if c.Path != "" {
+		s += fmt.Sprintf(", loaded from %s", c.Path)
+	}
+	return s
+}
+
+func writeCycloneDX(w io.Writer, name string, created time.Time, components []*SBOMComponent) error {
+	doc := &cycloneDXDocument{
+		BOMFormat:   "CycloneDX",
+		SpecVersion: "1.4",
+		Version:     1,
+		Metadata: &cycloneDXMetadata{
+			Timestamp: created.UTC().Format(time.RFC3339),
+			Tools:     []*cycloneDXTool{{Name: "tcglog-parser"}}},
+		Components: []*cycloneDXComponent{}}
+
+	for _, c := range components {
+		cc := &cycloneDXComponent{
+			Type:    "application",
+			BOMRef:  fmt.Sprintf("pcr%d-event%d", c.Event.PCRIndex, c.Event.Index),
+			Name:    c.Name,
+			Version: c.Version,
+			Hashes:  []*cycloneDXHash{},
+			Properties: []*cycloneDXProperty{
+				{Name: "tcglog:pcr", Value: fmt.Sprintf("%d", c.Event.PCRIndex)},
+				{Name: "tcglog:event-index", Value: fmt.Sprintf("%d", c.Event.Index)},
+				{Name: "tcglog:event-type", Value: c.Event.EventType.String()}}}
+		if c.Firmware {
+			cc.Type = "firmware"
+		}
+		if c.Path != "" {
+			cc.Properties = append(cc.Properties, &cycloneDXProperty{Name: "tcglog:path", Value: c.Path})
+		}
+		for _, alg := range sbomDigests(c.Event) {
+			cc.Hashes = append(cc.Hashes, &cycloneDXHash{Alg: alg.String(), Content: hex.EncodeToString(c.Event.Digests[alg])})
+		}
+		doc.Components = append(doc.Components, cc)
+	}
+
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(doc)
+}
+
+func writeSPDX(w io.Writer, name string, created time.Time, components []*SBOMComponent) error {
+	// The document namespace must be unique for each document, so derive it from the measurements that it describes.
+	h := sha256.New()
+	for _, c := range components {
+		for _, alg := range sbomDigests(c.Event) {
+			h.Write(c.Event.Digests[alg])
+		}
+	}
+
+	doc := &spdxDocument{
+		SPDXVersion:       "SPDX-2.3",
+		DataLicense:       "CC0-1.0",
+		SPDXID:            "SPDXRef-DOCUMENT",
+		Name:              name,
+		DocumentNamespace: fmt.Sprintf("urn:tcglog-parser:%s:%x", name, h.Sum(nil)[:16]),
+		CreationInfo: &spdxCreationInfo{
+			Created:  created.UTC().Format(time.RFC3339),
+			Creators: []string{"Tool: tcglog-parser"}},
+		Packages:      []*spdxPackage{},
+		Relationships: []*spdxRelationship{}}
+
+	for i, c := range components {
+		p := &spdxPackage{
+			Name:                  c.Name,
+			SPDXID:                fmt.Sprintf("SPDXRef-Package-%d", i),
+			VersionInfo:           c.Version,
+			DownloadLocation:      "NOASSERTION",
+			Checksums:             []*spdxChecksum{},
+			PrimaryPackagePurpose: "APPLICATION",
+			Comment:               sbomComponentComment(c)}
+		if c.Firmware {
+			p.PrimaryPackagePurpose = "FIRMWARE"
+		}
+		for _, alg := range sbomDigests(c.Event) {
+			p.Checksums = append(p.Checksums, &spdxChecksum{
+				Algorithm:     strings.Replace(alg.String(), "-", "", -1),
+				ChecksumValue: hex.EncodeToString(c.Event.Digests[alg])})
+		}
+		doc.Packages = append(doc.Packages, p)
+		doc.Relationships = append(doc.Relationships, &spdxRelationship{
+			SPDXElementID:      "SPDXRef-DOCUMENT",
+			RelationshipType:   "DESCRIBES",
+			RelatedSPDXElement: p.SPDXID})
+	}
+
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(doc)
+}
+
+// WriteSBOM writes a software bill of materials that lists the measured boot components in this log (see SBOMComponents) to w,
+// in the format specified by options. The digests of each component are those recorded in the log, so they can be matched
+// against the measurements made by other machines - note that these are Authenticode digests for EFI images rather than digests
+// of the image files. The supplied options may be nil, in which case a CycloneDX document is written with the default name and
+// creation time.
+func (l *Log) WriteSBOM(w io.Writer, options *SBOMOptions) error {
+	if options == nil {
+		options = &SBOMOptions{}
+	}
+
+	name := options.Name
+	if name == "" {
+		name = "measured-boot"
+	}
+	created := options.Created
+	if created.IsZero() {
+		created = time.Now()
+	}
+
+	components := l.SBOMComponents()
+	switch options.Format {
+	case SBOMFormatCycloneDX:
+		return writeCycloneDX(w, name, created, components)
+	case SBOMFormatSPDX:
+		return writeSPDX(w, name, created, components)
+	default:
+		return fmt.Errorf("unrecognized SBOM format %v", options.Format)
+	}
+}
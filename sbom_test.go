@@ -0,0 +1,149 @@
+// Copyright 2019 Canonical Ltd.
+// Licensed under the LGPLv3 with static-linking exception.
+// See LICENCE file for details.
+
+package tcglog
+
+import (
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	"testing"
+	"time"
+)
+
+func makeSBOMTestLog() *Log {
+	event := func(pcr PCRIndex, eventType EventType, data []byte) *Event {
+		return makeTestEvent(pcr, eventType, data, AlgorithmSha1, AlgorithmSha256)
+	}
+	return NewLog([]*Event{
+		event(0, EventTypeSCRTMVersion, makeTestUTF16String("1.02")),
+		event(0, EventTypePostCode, []byte("ACPI DATA")),
+		event(1, EventTypeCPUMicrocode, []byte{0xde, 0xad, 0xbe, 0xef}),
+		event(4, EventTypeEFIBootServicesApplication, makeTestImageLoadEventData("\\EFI\\ubuntu\\shimx64.efi")),
+	})
+}
+
+func TestSBOMComponents(t *testing.T) {
+	log := makeSBOMTestLog()
+	components := log.SBOMComponents()
+	if len(components) != 3 {
+		t.Fatalf("Unexpected number of components: %d", len(components))
+	}
+	for i, expected := range []SBOMComponent{
+		{Name: "ACPI DATA", Version: "1.02", Firmware: true, Event: log.Events[1]},
+		{Name: "microcode", Firmware: true, Event: log.Events[2]},
+		{Name: "shimx64.efi", Path: "/EFI/ubuntu/shimx64.efi", Event: log.Events[3]},
+	} {
+		if *components[i] != expected {
+			t.Errorf("Unexpected component %d: %+v", i, components[i])
+		}
+	}
+}
+
+func TestWriteSBOMCycloneDX(t *testing.T) {
+	log := makeSBOMTestLog()
+	var b bytes.Buffer
+	if err := log.WriteSBOM(&b, &SBOMOptions{Format: SBOMFormatCycloneDX,
+		Created: time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)}); err != nil {
+		t.Fatalf("WriteSBOM failed: %v", err)
+	}
+
+	var doc struct {
+		BOMFormat  string
+		Metadata   struct{ Timestamp string }
+		Components []struct {
+			Type       string
+			Name       string
+			Version    string
+			Hashes     []struct{ Alg, Content string }
+			Properties []struct{ Name, Value string }
+		}
+	}
+	if err := json.Unmarshal(b.Bytes(), &doc); err != nil {
+		t.Fatalf("Unmarshal failed: %v", err)
+	}
+	if doc.BOMFormat != "CycloneDX" || doc.Metadata.Timestamp != "2020-01-02T03:04:05Z" || len(doc.Components) != 3 {
+		t.Fatalf("Unexpected document: %s", b.String())
+	}
+	if doc.Components[0].Type != "firmware" || doc.Components[0].Version != "1.02" {
+		t.Errorf("Unexpected firmware component: %+v", doc.Components[0])
+	}
+	shim := doc.Components[2]
+	if shim.Type != "application" || shim.Name != "shimx64.efi" || len(shim.Hashes) != 2 || shim.Hashes[0].Alg != "SHA-1" ||
+		shim.Hashes[1].Content != hex.EncodeToString(log.Events[3].Digests[AlgorithmSha256]) {
+		t.Errorf("Unexpected application component: %+v", shim)
+	}
+	if len(shim.Properties) != 4 || shim.Properties[3].Name != "tcglog:path" || shim.Properties[3].Value != "/EFI/ubuntu/shimx64.efi" {
+		t.Errorf("Unexpected properties: %+v", shim.Properties)
+	}
+}
+
+func TestWriteSBOMSPDX(t *testing.T) {
+	log := makeSBOMTestLog()
+	var b bytes.Buffer
+	if err := log.WriteSBOM(&b, &SBOMOptions{Format: SBOMFormatSPDX, Name: "host1"}); err != nil {
+		t.Fatalf("WriteSBOM failed: %v", err)
+	}
+
+	var doc struct {
+		SPDXVersion string
+		Name        string
+		Packages    []struct {
+			SPDXID                string
+			Name                  string
+			Checksums             []struct{ Algorithm, ChecksumValue string }
+			PrimaryPackagePurpose string
+			Comment               string
+		}
+		Relationships []struct{ RelatedSPDXElement string }
+	}
+	if err := json.Unmarshal(b.Bytes(), &doc); err != nil {
+		t.Fatalf("Unmarshal failed: %v", err)
+	}
+	if doc.SPDXVersion != "SPDX-2.3" || doc.Name != "host1" || len(doc.Packages) != 3 || len(doc.Relationships) != 3 {
+		t.Fatalf("Unexpected document: %s", b.String())
+	}
+	shim := doc.Packages[2]
+	if shim.SPDXID != "SPDXRef-Package-2" || shim.PrimaryPackagePurpose != "APPLICATION" || len(shim.Checksums) != 2 ||
+		shim.Checksums[1].Algorithm != "SHA256" || doc.Relationships[2].RelatedSPDXElement != shim.SPDXID {
+		t.Errorf("Unexpected package: %+v", shim)
+	}
+	if shim.Comment != "measured by event 0 in PCR 4 (type: EV_EFI_BOOT_SERVICES_APPLICATION), loaded from /EFI/ubuntu/shimx64.efi" {
+		t.Errorf("Unexpected comment: %s", shim.Comment)
+	}
+	if doc.Packages[0].PrimaryPackagePurpose != "FIRMWARE" {
+		t.Errorf("Unexpected firmware package: %+v", doc.Packages[0])
+	}
+}
+
+func TestParseSBOMFormat(t *testing.T) {
+	if f, err := ParseSBOMFormat("SPDX"); err != nil || f != SBOMFormatSPDX {
+		t.Errorf("Unexpected result: %v, %v", f, err)
+	}
+	if _, err := ParseSBOMFormat("swid"); err == nil || err.Error() != "unrecognized SBOM format \"swid\"" {
+		t.Errorf("Unexpected error: %v", err)
+	}
+}
+
+func TestWriteSBOMDefaultOptions(t *testing.T) {
+	log := makeSBOMTestLog()
+	var b bytes.Buffer
+	if err := log.WriteSBOM(&b, nil); err != nil {
+		t.Fatalf("WriteSBOM failed: %v", err)
+	}
+
+	var doc struct {
+		BOMFormat string
+		Metadata  struct{ Timestamp string }
+	}
+	if err := json.Unmarshal(b.Bytes(), &doc); err != nil {
+		t.Fatalf("Unmarshal failed: %v", err)
+	}
+	if doc.BOMFormat != "CycloneDX" {
+		t.Errorf("Unexpected format: %s", doc.BOMFormat)
+	}
+	if _, err := time.Parse(time.RFC3339, doc.Metadata.Timestamp); err != nil {
+		t.Errorf("Unexpected timestamp: %s", doc.Metadata.Timestamp)
+	}
+}
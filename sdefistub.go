@@ -7,7 +7,6 @@ package tcglog
 import (
 	"bytes"
 	"encoding/binary"
-	"fmt"
 	"io"
 )
 
@@ -18,13 +17,23 @@ type SystemdEFIStubEventData struct {
 }
 
 func (e *SystemdEFIStubEventData) String() string {
-	return fmt.Sprintf("%s", e.Str)
+	return EscapeString(e.Str)
 }
 
 func (e *SystemdEFIStubEventData) Bytes() []byte {
 	return e.data
 }
 
+// EncodeTo encodes this event data in to the form in which it appears in the event log, which is the UTF-16 string in
+// little-endian form terminated with a single zero byte.
+func (e *SystemdEFIStubEventData) EncodeTo(w io.Writer) error {
+	var b bytes.Buffer
+	binary.Write(&b, binary.LittleEndian, convertStringToUtf16(e.Str))
+	b.WriteByte(0)
+	_, err := b.WriteTo(w)
+	return err
+}
+
 // EncodeMeasured bytes encodes this data to the form that would be hashed and measured by the systemd EFI stub linux loader for the
 // specified kernel commandline. Note that it assumes that the calling bootloader includes a UTF-16 NULL terminator at the end of
 // LoadOptions, and sets LoadOptionsSize to StrLen(LoadOptions)+1
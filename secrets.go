@@ -0,0 +1,134 @@
+// Copyright 2019 Canonical Ltd.
+// Licensed under the LGPLv3 with static-linking exception.
+// See LICENCE file for details.
+
+package tcglog
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// SecretPattern describes a type of secret to search for in measured strings. The pattern must contain exactly one capture group,
+// which identifies the secret value itself.
+type SecretPattern struct {
+	Name   string // A description of the type of secret, eg, "password"
+	Regexp *regexp.Regexp
+}
+
+var (
+	// SecretPasswordPattern matches key=value arguments where the key looks like it names a password.
+	SecretPasswordPattern = SecretPattern{
+		Name:   "password",
+		Regexp: regexp.MustCompile(`(?i)\b[\w.-]*(?:password|passwd|passphrase|pwd)[\w.-]*=(\S+)`)}
+
+	// SecretLUKSKeyPattern matches kernel command line arguments that specify a LUKS key or key file.
+	SecretLUKSKeyPattern = SecretPattern{
+		Name:   "LUKS key",
+		Regexp: regexp.MustCompile(`(?i)\b(?:rd\.)?(?:luks\.key|cryptkey)=(\S+)`)}
+
+	// SecretTokenPattern matches key=value arguments where the key looks like it names a token, API key or other secret.
+	SecretTokenPattern = SecretPattern{
+		Name:   "token",
+		Regexp: regexp.MustCompile(`(?i)\b[\w.-]*(?:token|secret|api[_-]?key|access[_-]?key)[\w.-]*=(\S+)`)}
+
+	// SecretURLCredentialsPattern matches URLs with embedded credentials, such as those used for network root filesystems.
+	SecretURLCredentialsPattern = SecretPattern{
+		Name:   "URL credentials",
+		Regexp: regexp.MustCompile(`\b[a-zA-Z][a-zA-Z0-9+.-]*://[^/\s:@]+:([^/\s@]+)@`)}
+
+	// DefaultSecretPatterns is the set of patterns used by Log.ScanSecrets if no patterns are supplied.
+	DefaultSecretPatterns = []SecretPattern{SecretPasswordPattern, SecretLUKSKeyPattern, SecretTokenPattern,
+		SecretURLCredentialsPattern}
+)
+
+// Secret describes a likely secret found in a string measured to the log.
+type Secret struct {
+	Event   *Event
+	Source  string // A description of the measured string that contains the secret, eg, "kernel command line"
+	Pattern string // The name of the SecretPattern that matched
+	Match   string // The text matched by the pattern
+	Value   string // The secret value within Match
+
+	valueOffset int // The offset of Value within Match
+}
+
+// Masked returns Match with the secret value replaced by asterisks, so that findings can be reported without disclosing the
+// secret any further.
+func (s *Secret) Masked() string {
+	return s.Match[:s.valueOffset] + strings.Repeat("*", len(s.Value)) + s.Match[s.valueOffset+len(s.Value):]
+}
+
+func (s *Secret) String() string {
+	return fmt.Sprintf("event %d in PCR %d (type: %s): possible %s in %s: %s", s.Event.Index, s.Event.PCRIndex,
+		s.Event.EventType, s.Pattern, s.Source, s.Masked())
+}
+
+// measuredStrings returns the human readable strings associated with the supplied event that might contain secrets, along with
+// a description of where each of them came from.
+func measuredStrings(e *Event) (sources, strs []string) {
+	switch d := e.Data.(type) {
+	case *GrubStringEventData:
+		switch d.Type {
+		case KernelCmdline:
+			return []string{"kernel command line"}, []string{d.Str}
+		default:
+			return []string{"GRUB command"}, []string{d.Str}
+		}
+	case *SystemdEFIStubEventData:
+		return []string{"kernel command line"}, []string{d.Str}
+	case *SystemdUKIEventData:
+		if d.Type == SystemdUKIKernelCmdline {
+			return []string{"kernel command line"}, []string{d.Str}
+		}
+	case *AsciiStringEventData:
+		if e.EventType == EventTypeIPL {
+			return []string{"EV_IPL string"}, []string{string(d.data)}
+		}
+	case *EFIVariableData:
+		o, err := d.LoadOption()
+		if err != nil {
+			break
+		}
+		sources = append(sources, "load option description")
+		strs = append(strs, o.Description)
+		if str, _, ok := decodePrintableString(o.OptionalData); ok {
+			sources = append(sources, "load option data")
+			strs = append(strs, str)
+		}
+	}
+	return sources, strs
+}
+
+// ScanSecrets searches the kernel command lines, GRUB commands, EV_IPL strings and EFI load options measured in this log for
+// likely secrets, such as passwords, LUKS keys and tokens. Measured strings are recorded in plain text in the log, which is
+// readable by any user on many systems, so secrets passed on the kernel command line or in a boot entry are disclosed.
+//
+// If no patterns are supplied, DefaultSecretPatterns is used.
+func (l *Log) ScanSecrets(patterns ...SecretPattern) (out []*Secret) {
+	if len(patterns) == 0 {
+		patterns = DefaultSecretPatterns
+	}
+
+	for _, e := range l.Events {
+		sources, strs := measuredStrings(e)
+		for i, str := range strs {
+			for _, p := range patterns {
+				for _, m := range p.Regexp.FindAllStringSubmatchIndex(str, -1) {
+					if len(m) < 4 || m[2] < 0 || m[2] == m[3] {
+						continue
+					}
+					out = append(out, &Secret{
+						Event:       e,
+						Source:      sources[i],
+						Pattern:     p.Name,
+						Match:       str[m[0]:m[1]],
+						Value:       str[m[2]:m[3]],
+						valueOffset: m[2] - m[0]})
+				}
+			}
+		}
+	}
+	return out
+}
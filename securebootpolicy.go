@@ -0,0 +1,276 @@
+// Copyright 2019 Canonical Ltd.
+// Licensed under the LGPLv3 with static-linking exception.
+// See LICENCE file for details.
+
+package tcglog
+
+import (
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+)
+
+// secureBootStateVariables are the boolean secure boot state variables, in the order in which they are reported.
+var secureBootStateVariables = []string{"SecureBoot", "SetupMode", "AuditMode", "DeployedMode"}
+
+// SecureBootDatabase describes a measurement of one of the secure boot signature databases (PK, KEK, db or dbx) to PCR 7.
+type SecureBootDatabase struct {
+	Name     string
+	Event    *Event
+	Contents EFISignatureDatabase // The decoded contents, or nil if they could not be decoded
+	Err      error                // The error that occurred when decoding the contents, if any
+}
+
+// SecureBootAuthority describes an EV_EFI_VARIABLE_AUTHORITY event in PCR 7.
+type SecureBootAuthority struct {
+	Event     *Event
+	Source    string            // The name of the variable that the authority was measured from, eg, "db" or "MokListRT"
+	Signature *EFISignatureData // The decoded authority, or nil if it isn't a recognized signature
+}
+
+// SecureBootImage describes an EFI image that was loaded, and the authorities that were measured in order to authorize it.
+type SecureBootImage struct {
+	Event *Event
+	Path  string // The path of the image, or empty if the device path doesn't contain one
+
+	// Authorities are the authorities that were measured to PCR 7 after the previous image was loaded and before this one. The
+	// firmware only measures each authority the first time that it is used, so this is empty for an image that was authorized
+	// by a previously measured authority.
+	Authorities []*SecureBootAuthority
+}
+
+// SecureBootPolicy is a high-level summary of the secure boot policy measured to PCR 7.
+type SecureBootPolicy struct {
+	// States contains the values of the SecureBoot, SetupMode, AuditMode and DeployedMode variables that are measured. If a
+	// variable is measured more than once, the last value is recorded.
+	States map[string]bool
+
+	Databases   []*SecureBootDatabase  // The measurements of PK, KEK, db and dbx, in the order in which they were measured
+	Authorities []*SecureBootAuthority // The authorities measured to PCR 7, in the order in which they were measured
+	Images      []*SecureBootImage     // The EFI images that were loaded, in the order in which they were loaded
+}
+
+// Enabled indicates whether the SecureBoot variable is measured and indicates that secure boot is enabled.
+func (p *SecureBootPolicy) Enabled() bool {
+	return p.States["SecureBoot"]
+}
+
+// SecureBootPolicy reconstructs the secure boot policy from the events measured to PCR 7 - the secure boot state, the contents
+// of the signature databases and the authorities used to authorize each EFI image that was loaded.
+func (l *Log) SecureBootPolicy() *SecureBootPolicy {
+	policy := &SecureBootPolicy{States: make(map[string]bool)}
+	var pending []*SecureBootAuthority
+
+	for _, e := range l.Events {
+		if isImageLoadEvent(e) {
+			image := &SecureBootImage{Event: e, Authorities: pending}
+			if d, ok := e.Data.(*EFIImageLoadEvent); ok {
+				image.Path = d.FilePath()
+			}
+			policy.Images = append(policy.Images, image)
+			pending = nil
+			continue
+		}
+		if e.PCRIndex != 7 {
+			continue
+		}
+		d, ok := e.Data.(*EFIVariableData)
+		if !ok {
+			continue
+		}
+
+		switch {
+		case e.EventType == EventTypeEFIVariableAuthority:
+			a := &SecureBootAuthority{Event: e, Source: d.UnicodeName}
+			a.Signature, _ = d.AuthoritySignature()
+			policy.Authorities = append(policy.Authorities, a)
+			pending = append(pending, a)
+		case e.EventType != EventTypeEFIVariableDriverConfig:
+		case d.IsSecureBootState():
+			if value, err := d.SecureBootState(); err == nil {
+				policy.States[d.UnicodeName] = value
+			}
+		case d.IsSignatureDatabase():
+			db := &SecureBootDatabase{Name: d.UnicodeName, Event: e}
+			db.Contents, db.Err = d.SignatureDatabase()
+			policy.Databases = append(policy.Databases, db)
+		}
+	}
+
+	return policy
+}
+
+// shortSignatureString returns a brief description of a signature, which is the subject of a X.509 certificate or the digest
+// for other types.
+func shortSignatureString(s *EFISignatureData) string {
+	if info, err := s.CertificateInfo(); err == nil {
+		return fmt.Sprintf("X509: \"%s\"", EscapeString(info.Subject))
+	}
+	return fmt.Sprintf("%s: %x", efiSignatureTypeString(s.SignatureType), s.Data)
+}
+
+func (p *SecureBootPolicy) String() string {
+	var b bytes.Buffer
+
+	fmt.Fprintf(&b, "Secure boot state:")
+	for _, name := range secureBootStateVariables {
+		value, ok := p.States[name]
+		if !ok {
+			continue
+		}
+		d := &EFIVariableData{VariableName: EFIGlobalVariableGuid, UnicodeName: name, VariableData: []byte{0}}
+		if value {
+			d.VariableData[0] = 1
+		}
+		str, _ := d.SecureBootStateString()
+		fmt.Fprintf(&b, "\n  %s: %s", name, str)
+	}
+	if _, ok := p.States["SecureBoot"]; !ok {
+		fmt.Fprintf(&b, "\n  SecureBoot: not measured")
+	}
+
+	fmt.Fprintf(&b, "\nSignature databases:")
+	for _, db := range p.Databases {
+		fmt.Fprintf(&b, "\n  %s (event %d in PCR %d):", db.Name, db.Event.Index, db.Event.PCRIndex)
+		if db.Err != nil {
+			fmt.Fprintf(&b, " invalid: %v", db.Err)
+			continue
+		}
+
+		// List certificates individually, but only count hashes as dbx normally contains hundreds of them.
+		hashes := make(map[EFIGUID]int)
+		var hashTypes []EFIGUID
+		for _, l := range db.Contents {
+			for _, s := range l.Signatures {
+				if s.SignatureType == EFICertX509Guid {
+					fmt.Fprintf(&b, "\n    %s", shortSignatureString(s))
+					continue
+				}
+				if hashes[s.SignatureType] == 0 {
+					hashTypes = append(hashTypes, s.SignatureType)
+				}
+				hashes[s.SignatureType]++
+			}
+		}
+		for _, t := range hashTypes {
+			fmt.Fprintf(&b, "\n    %d %s entries", hashes[t], efiSignatureTypeString(t))
+		}
+	}
+
+	fmt.Fprintf(&b, "\nLoaded images:")
+	for _, i := range p.Images {
+		path := i.Path
+		if path == "" {
+			path = "<no path>"
+		}
+		fmt.Fprintf(&b, "\n  %s (event %d in PCR %d):", path, i.Event.Index, i.Event.PCRIndex)
+		switch {
+		case len(i.Authorities) > 0:
+			for _, a := range i.Authorities {
+				sig := "unrecognized signature"
+				if a.Signature != nil {
+					sig = shortSignatureString(a.Signature)
+				}
+				fmt.Fprintf(&b, "\n    authorized by %s from %s (event %d in PCR %d)", sig, a.Source, a.Event.Index,
+					a.Event.PCRIndex)
+			}
+		case p.Enabled():
+			fmt.Fprintf(&b, " authorized by a previously measured authority")
+		default:
+			fmt.Fprintf(&b, " not verified")
+		}
+	}
+
+	return b.String()
+}
+
+type secureBootPolicyReportSignature struct {
+	Type        string `json:"type"`
+	Owner       string `json:"owner"`
+	Subject     string `json:"subject,omitempty"`
+	Issuer      string `json:"issuer,omitempty"`
+	Fingerprint string `json:"sha256_fingerprint,omitempty"`
+	Data        string `json:"data,omitempty"`
+}
+
+func newSecureBootPolicyReportSignature(s *EFISignatureData) *secureBootPolicyReportSignature {
+	out := &secureBootPolicyReportSignature{Type: efiSignatureTypeString(s.SignatureType), Owner: s.SignatureOwner.String()}
+	if info, err := s.CertificateInfo(); err == nil {
+		out.Subject = info.Subject
+		out.Issuer = info.Issuer
+		out.Fingerprint = hex.EncodeToString(info.Fingerprint)
+	} else {
+		out.Data = hex.EncodeToString(s.Data)
+	}
+	return out
+}
+
+type secureBootPolicyReportDatabase struct {
+	Name       string                             `json:"name"`
+	Event      *validationReportEvent             `json:"event"`
+	Signatures []*secureBootPolicyReportSignature `json:"signatures"`
+	Error      string                             `json:"error,omitempty"`
+}
+
+type secureBootPolicyReportAuthority struct {
+	Event     *validationReportEvent           `json:"event"`
+	Source    string                           `json:"source"`
+	Signature *secureBootPolicyReportSignature `json:"signature,omitempty"`
+}
+
+type secureBootPolicyReportImage struct {
+	Event       *validationReportEvent             `json:"event"`
+	Path        string                             `json:"path,omitempty"`
+	Authorities []*secureBootPolicyReportAuthority `json:"authorities"`
+}
+
+type secureBootPolicyReport struct {
+	States    map[string]bool                   `json:"states"`
+	Databases []*secureBootPolicyReportDatabase `json:"databases"`
+	Images    []*secureBootPolicyReportImage    `json:"images"`
+}
+
+// WriteJSON writes this policy to w as a machine-readable JSON report.
+func (p *SecureBootPolicy) WriteJSON(w io.Writer) error {
+	report := &secureBootPolicyReport{
+		States:    p.States,
+		Databases: []*secureBootPolicyReportDatabase{},
+		Images:    []*secureBootPolicyReportImage{}}
+
+	for _, db := range p.Databases {
+		rd := &secureBootPolicyReportDatabase{
+			Name:       db.Name,
+			Event:      newValidationReportEvent(db.Event),
+			Signatures: []*secureBootPolicyReportSignature{}}
+		if db.Err != nil {
+			rd.Error = db.Err.Error()
+		}
+		for _, l := range db.Contents {
+			for _, s := range l.Signatures {
+				rd.Signatures = append(rd.Signatures, newSecureBootPolicyReportSignature(s))
+			}
+		}
+		report.Databases = append(report.Databases, rd)
+	}
+
+	for _, i := range p.Images {
+		ri := &secureBootPolicyReportImage{
+			Event:       newValidationReportEvent(i.Event),
+			Path:        i.Path,
+			Authorities: []*secureBootPolicyReportAuthority{}}
+		for _, a := range i.Authorities {
+			ra := &secureBootPolicyReportAuthority{Event: newValidationReportEvent(a.Event), Source: a.Source}
+			if a.Signature != nil {
+				ra.Signature = newSecureBootPolicyReportSignature(a.Signature)
+			}
+			ri.Authorities = append(ri.Authorities, ra)
+		}
+		report.Images = append(report.Images, ri)
+	}
+
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(report)
+}
@@ -0,0 +1,150 @@
+// Copyright 2019 Canonical Ltd.
+// Licensed under the LGPLv3 with static-linking exception.
+// See LICENCE file for details.
+
+package tcglog
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func makeSecureBootPolicyTestLog(t *testing.T, secureBoot byte) *Log {
+	owner := MakeEFIGUID(0x77fa9abd, 0x0359, 0x4d32, 0xbd60, [...]uint8{0x28, 0xf4, 0xe7, 0x8f, 0x78, 0x4b})
+	ca := makeTestCertificate(t, "Test UEFI CA")
+	h1 := sha256.Sum256([]byte("foo"))
+	h2 := sha256.Sum256([]byte("bar"))
+
+	variable := func(eventType EventType, guid EFIGUID, name string, data []byte) *Event {
+		varData := EFIVariableData{VariableName: guid, UnicodeName: name, VariableData: data}
+		var b bytes.Buffer
+		varData.EncodeMeasuredBytes(&b)
+		return makeTestEvent(7, eventType, b.Bytes(), AlgorithmSha256)
+	}
+	config := func(guid EFIGUID, name string, data []byte) *Event {
+		return variable(EventTypeEFIVariableDriverConfig, guid, name, data)
+	}
+
+	var db bytes.Buffer
+	db.Write(makeTestSignatureList(EFICertX509Guid, owner, ca))
+	db.Write(makeTestSignatureList(EFICertSha256Guid, owner, h1[:]))
+
+	return NewLog([]*Event{
+		config(EFIGlobalVariableGuid, "SecureBoot", []byte{secureBoot}),
+		config(EFIGlobalVariableGuid, "PK", makeTestSignatureList(EFICertX509Guid, owner, makeTestCertificate(t, "Test PK"))),
+		config(EFIGlobalVariableGuid, "KEK", makeTestSignatureList(EFICertX509Guid, owner, makeTestCertificate(t, "Test KEK"))),
+		config(EFIImageSecurityDatabaseGuid, "db", db.Bytes()),
+		config(EFIImageSecurityDatabaseGuid, "dbx", makeTestSignatureList(EFICertSha256Guid, owner, h1[:], h2[:])),
+		makeTestEvent(7, EventTypeSeparator, []byte{0, 0, 0, 0}, AlgorithmSha256),
+		variable(EventTypeEFIVariableAuthority, EFIImageSecurityDatabaseGuid, "db", append(owner[:], ca...)),
+		makeTestEvent(4, EventTypeEFIBootServicesApplication, makeTestImageLoadEventData("\\EFI\\ubuntu\\shimx64.efi"),
+			AlgorithmSha256),
+		makeTestEvent(4, EventTypeEFIBootServicesApplication, makeTestImageLoadEventData("\\EFI\\ubuntu\\grubx64.efi"),
+			AlgorithmSha256),
+	})
+}
+
+func TestSecureBootPolicy(t *testing.T) {
+	log := makeSecureBootPolicyTestLog(t, 1)
+	policy := log.SecureBootPolicy()
+
+	if !policy.Enabled() {
+		t.Errorf("Expected secure boot to be enabled")
+	}
+	if len(policy.Databases) != 4 {
+		t.Fatalf("Unexpected number of databases: %d", len(policy.Databases))
+	}
+	for i, name := range []string{"PK", "KEK", "db", "dbx"} {
+		db := policy.Databases[i]
+		if db.Name != name || db.Event != log.Events[i+1] || db.Err != nil {
+			t.Errorf("Unexpected database %d: %s, %v", i, db.Name, db.Err)
+		}
+	}
+	if len(policy.Authorities) != 1 || policy.Authorities[0].Event != log.Events[6] ||
+		policy.Authorities[0].Signature == nil || policy.Authorities[0].Source != "db" {
+		t.Errorf("Unexpected authorities")
+	}
+	if len(policy.Images) != 2 {
+		t.Fatalf("Unexpected number of images: %d", len(policy.Images))
+	}
+	if policy.Images[0].Path != "/EFI/ubuntu/shimx64.efi" || len(policy.Images[0].Authorities) != 1 ||
+		policy.Images[0].Authorities[0] != policy.Authorities[0] {
+		t.Errorf("Unexpected first image")
+	}
+	if policy.Images[1].Path != "/EFI/ubuntu/grubx64.efi" || len(policy.Images[1].Authorities) != 0 {
+		t.Errorf("Unexpected second image")
+	}
+
+	expected := `Secure boot state:
+  SecureBoot: enabled
+Signature databases:
+  PK (event 1 in PCR 7):
+    X509: "CN=Test PK"
+  KEK (event 2 in PCR 7):
+    X509: "CN=Test KEK"
+  db (event 3 in PCR 7):
+    X509: "CN=Test UEFI CA"
+    1 SHA256 entries
+  dbx (event 4 in PCR 7):
+    2 SHA256 entries
+Loaded images:
+  /EFI/ubuntu/shimx64.efi (event 0 in PCR 4):
+    authorized by X509: "CN=Test UEFI CA" from db (event 6 in PCR 7)
+  /EFI/ubuntu/grubx64.efi (event 1 in PCR 4): authorized by a previously measured authority`
+	if policy.String() != expected {
+		t.Errorf("Unexpected string:\n%s", policy)
+	}
+
+	var b bytes.Buffer
+	if err := policy.WriteJSON(&b); err != nil {
+		t.Fatalf("WriteJSON failed: %v", err)
+	}
+	var report struct {
+		States    map[string]bool
+		Databases []struct {
+			Name       string
+			Signatures []struct {
+				Type    string
+				Subject string
+			}
+		}
+		Images []struct {
+			Path        string
+			Authorities []struct {
+				Source    string
+				Signature struct{ Subject string }
+			}
+		}
+	}
+	if err := json.Unmarshal(b.Bytes(), &report); err != nil {
+		t.Fatalf("Unmarshal failed: %v", err)
+	}
+	if !report.States["SecureBoot"] || len(report.Databases) != 4 || len(report.Databases[3].Signatures) != 2 ||
+		report.Databases[2].Signatures[0].Subject != "CN=Test UEFI CA" || report.Databases[2].Signatures[1].Type != "SHA256" {
+		t.Errorf("Unexpected databases in report: %s", b.String())
+	}
+	if len(report.Images) != 2 || len(report.Images[0].Authorities) != 1 ||
+		report.Images[0].Authorities[0].Signature.Subject != "CN=Test UEFI CA" || len(report.Images[1].Authorities) != 0 {
+		t.Errorf("Unexpected images in report: %s", b.String())
+	}
+}
+
+func TestSecureBootPolicyDisabled(t *testing.T) {
+	log := makeSecureBootPolicyTestLog(t, 0)
+	log.Events = append(log.Events[:6], log.Events[7:]...)
+
+	policy := log.SecureBootPolicy()
+	if policy.Enabled() {
+		t.Errorf("Expected secure boot to be disabled")
+	}
+	if len(policy.Images) != 2 || len(policy.Images[0].Authorities) != 0 {
+		t.Fatalf("Unexpected images")
+	}
+	expected := "  /EFI/ubuntu/shimx64.efi (event 0 in PCR 4): not verified"
+	if !strings.Contains(policy.String(), expected) {
+		t.Errorf("Unexpected string:\n%s", policy)
+	}
+}
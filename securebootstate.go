@@ -0,0 +1,65 @@
+// Copyright 2019 Canonical Ltd.
+// Licensed under the LGPLv3 with static-linking exception.
+// See LICENCE file for details.
+
+package tcglog
+
+import (
+	"fmt"
+)
+
+// IsSecureBootState indicates whether this is the measurement of one of the boolean secure boot state variables (SecureBoot,
+// SetupMode, AuditMode or DeployedMode).
+func (e *EFIVariableData) IsSecureBootState() bool {
+	if e.VariableName != EFIGlobalVariableGuid {
+		return false
+	}
+	switch e.UnicodeName {
+	case "SecureBoot", "SetupMode", "AuditMode", "DeployedMode":
+		return true
+	default:
+		return false
+	}
+}
+
+// SecureBootState decodes the variable data as the value of a boolean secure boot state variable. This returns an error if this
+// is not the measurement of a secure boot state variable (see IsSecureBootState) or the variable data is not a single byte with
+// the value 0 or 1.
+//
+// https://uefi.org/sites/default/files/resources/UEFI_Spec_2_8_final.pdf
+//  (section 3.3 "Globally Defined Variables", section 32.3 "Firmware/OS Key Exchange")
+func (e *EFIVariableData) SecureBootState() (bool, error) {
+	if !e.IsSecureBootState() {
+		return false, fmt.Errorf("%s-%s is not a secure boot state variable", e.UnicodeName, e.VariableName)
+	}
+	if len(e.VariableData) != 1 {
+		return false, fmt.Errorf("invalid variable data length (%d)", len(e.VariableData))
+	}
+	switch e.VariableData[0] {
+	case 0:
+		return false, nil
+	case 1:
+		return true, nil
+	default:
+		return false, fmt.Errorf("invalid value (%d)", e.VariableData[0])
+	}
+}
+
+// SecureBootStateString returns a description of the value of a boolean secure boot state variable, such as "enabled" for
+// SecureBoot or "user mode" for SetupMode. This returns an error if the value cannot be decoded (see SecureBootState).
+func (e *EFIVariableData) SecureBootStateString() (string, error) {
+	value, err := e.SecureBootState()
+	if err != nil {
+		return "", err
+	}
+	switch {
+	case e.UnicodeName == "SetupMode" && value:
+		return "setup mode", nil
+	case e.UnicodeName == "SetupMode":
+		return "user mode", nil
+	case value:
+		return "enabled", nil
+	default:
+		return "disabled", nil
+	}
+}
@@ -0,0 +1,65 @@
+// Copyright 2019 Canonical Ltd.
+// Licensed under the LGPLv3 with static-linking exception.
+// See LICENCE file for details.
+
+package tcglog
+
+import (
+	"testing"
+)
+
+func TestEFIVariableDataSecureBootState(t *testing.T) {
+	for _, data := range []struct {
+		desc  string
+		name  string
+		data  []byte
+		value bool
+		str   string
+	}{
+		{desc: "SecureBootEnabled", name: "SecureBoot", data: []byte{0x01}, value: true, str: "enabled"},
+		{desc: "SecureBootDisabled", name: "SecureBoot", data: []byte{0x00}, str: "disabled"},
+		{desc: "SetupMode", name: "SetupMode", data: []byte{0x01}, value: true, str: "setup mode"},
+		{desc: "UserMode", name: "SetupMode", data: []byte{0x00}, str: "user mode"},
+		{desc: "AuditMode", name: "AuditMode", data: []byte{0x00}, str: "disabled"},
+		{desc: "DeployedMode", name: "DeployedMode", data: []byte{0x01}, value: true, str: "enabled"},
+	} {
+		t.Run(data.desc, func(t *testing.T) {
+			v := &EFIVariableData{VariableName: EFIGlobalVariableGuid, UnicodeName: data.name, VariableData: data.data}
+			if !v.IsSecureBootState() {
+				t.Fatalf("Expected a secure boot state variable")
+			}
+			value, err := v.SecureBootState()
+			if err != nil {
+				t.Fatalf("SecureBootState failed: %v", err)
+			}
+			if value != data.value {
+				t.Errorf("Unexpected value: %v", value)
+			}
+			str, err := v.SecureBootStateString()
+			if err != nil {
+				t.Fatalf("SecureBootStateString failed: %v", err)
+			}
+			if str != data.str {
+				t.Errorf("Unexpected string: %s", str)
+			}
+		})
+	}
+}
+
+func TestEFIVariableDataSecureBootStateInvalid(t *testing.T) {
+	for _, data := range []struct {
+		desc string
+		v    *EFIVariableData
+	}{
+		{desc: "WrongName", v: &EFIVariableData{VariableName: EFIGlobalVariableGuid, UnicodeName: "PK", VariableData: []byte{0x01}}},
+		{desc: "WrongGuid", v: &EFIVariableData{VariableName: ShimLockGuid, UnicodeName: "SecureBoot", VariableData: []byte{0x01}}},
+		{desc: "WrongLength", v: &EFIVariableData{VariableName: EFIGlobalVariableGuid, UnicodeName: "SecureBoot", VariableData: []byte{0x01, 0x00}}},
+		{desc: "WrongValue", v: &EFIVariableData{VariableName: EFIGlobalVariableGuid, UnicodeName: "SecureBoot", VariableData: []byte{0x02}}},
+	} {
+		t.Run(data.desc, func(t *testing.T) {
+			if _, err := data.v.SecureBootState(); err == nil {
+				t.Errorf("Expected an error")
+			}
+		})
+	}
+}
@@ -0,0 +1,148 @@
+// Copyright 2019 Canonical Ltd.
+// Licensed under the LGPLv3 with static-linking exception.
+// See LICENCE file for details.
+
+package tcglog
+
+// NewSecurityAuditor returns a new Validator with checks that report weak platform configurations that are evident from a log,
+// rather than problems with the log itself. Findings with SeverityError indicate configurations that defeat the protection
+// provided by measured or verified boot, and findings with SeverityWarning indicate configurations that weaken it.
+func NewSecurityAuditor() *Validator {
+	v := new(Validator)
+	v.AddCheck("weak-banks", CheckWeakBanks)
+	v.AddCheck("secure-boot", CheckSecureBootState)
+	v.AddCheck("separator-errors", CheckSeparatorErrors)
+	v.AddCheck("unverified-images", CheckUnverifiedImages)
+	v.AddCheck("firmware-modes", CheckFirmwareModes)
+	return v
+}
+
+// CheckWeakBanks is a ValidationCheck that reports logs that only contain digests for SHA-1, which is vulnerable to collision
+// attacks.
+func CheckWeakBanks(log *Log) (out []*ValidationFinding) {
+	for _, alg := range log.Algorithms {
+		if alg != AlgorithmSha1 {
+			return nil
+		}
+	}
+	if !log.Algorithms.Contains(AlgorithmSha1) {
+		return nil
+	}
+	return []*ValidationFinding{{Severity: SeverityError, Message: "the log only contains a SHA-1 bank"}}
+}
+
+// CheckSecureBootState is a ValidationCheck that reports measurements of the SecureBoot and SetupMode variables which indicate
+// that secure boot is disabled or that the platform is in setup mode, where the secure boot keys can be modified without
+// authentication. It also reports logs where the SecureBoot variable is not measured.
+func CheckSecureBootState(log *Log) (out []*ValidationFinding) {
+	seenSecureBoot := false
+	for _, e := range log.Events {
+		if e.PCRIndex != 7 || e.EventType != EventTypeEFIVariableDriverConfig {
+			continue
+		}
+		d, ok := e.Data.(*EFIVariableData)
+		if !ok || !d.IsSecureBootState() {
+			continue
+		}
+		value, err := d.SecureBootState()
+		if err != nil {
+			continue
+		}
+		switch {
+		case d.UnicodeName == "SecureBoot":
+			seenSecureBoot = true
+			if !value {
+				out = append(out, &ValidationFinding{Severity: SeverityError, Event: e, Message: "secure boot is disabled"})
+			}
+		case d.UnicodeName == "SetupMode" && value:
+			out = append(out, &ValidationFinding{Severity: SeverityError, Event: e,
+				Message: "the platform is in setup mode, so the secure boot keys can be modified without authentication"})
+		}
+	}
+	if !seenSecureBoot {
+		out = append(out, &ValidationFinding{Severity: SeverityWarning,
+			Message: "the SecureBoot variable is not measured, so the secure boot state can't be determined"})
+	}
+	return out
+}
+
+// CheckSeparatorErrors is a ValidationCheck that reports EV_SEPARATOR events that indicate that an error occurred in the pre-OS
+// environment, in which case the affected PCRs don't reflect a trustworthy boot.
+func CheckSeparatorErrors(log *Log) (out []*ValidationFinding) {
+	for _, e := range log.Events {
+		if d, ok := e.Data.(*SeparatorEventData); ok && d.IsError {
+			out = append(out, &ValidationFinding{Severity: SeverityError, Event: e,
+				Message: "the separator indicates that an error occurred in the pre-OS environment"})
+		}
+	}
+	return out
+}
+
+// CheckUnverifiedImages is a ValidationCheck that reports EFI images that were loaded without being measured or verified. It
+// reports images with digests of zero, images that were loaded whilst secure boot is disabled and, when secure boot is enabled,
+// images that were loaded before any EV_EFI_VARIABLE_AUTHORITY event was measured.
+func CheckUnverifiedImages(log *Log) (out []*ValidationFinding) {
+	secureBoot := isSecureBootEnabled(log)
+	seenAuthority := false
+
+	for _, e := range log.Events {
+		if e.PCRIndex == 7 && e.EventType == EventTypeEFIVariableAuthority {
+			seenAuthority = true
+			continue
+		}
+		if !isImageLoadEvent(e) {
+			continue
+		}
+
+		measured := false
+		for _, d := range e.Digests {
+			if !isZeroDigest(d) {
+				measured = true
+				break
+			}
+		}
+
+		switch {
+		case !measured:
+			out = append(out, &ValidationFinding{Severity: SeverityError, Event: e, Message: "the image was not measured"})
+		case !secureBoot:
+			out = append(out, &ValidationFinding{Severity: SeverityWarning, Event: e,
+				Message: "the image was loaded without signature verification because secure boot is disabled"})
+		case !seenAuthority:
+			out = append(out, &ValidationFinding{Severity: SeverityWarning, Event: e,
+				Message: "the image was loaded before any authority was measured, so it may not have been verified"})
+		}
+	}
+	return out
+}
+
+// CheckFirmwareModes is a ValidationCheck that reports firmware debug and audit modes that are evident from the log. These are
+// the "UEFI Debug Mode" and "DMA Protection Disabled" actions measured to PCR 7, and the AuditMode variable being set, in which
+// case image verification failures are logged rather than enforced.
+//
+// https://trustedcomputinggroup.org/wp-content/uploads/TCG_PCClientSpecPlat_TPM_2p0_1p04_pub.pdf
+//  (section 3.3.4.8 "PCR[7] - Secure Boot Policy Measurements")
+func CheckFirmwareModes(log *Log) (out []*ValidationFinding) {
+	for _, e := range log.Events {
+		switch d := e.Data.(type) {
+		case *ActionEventData:
+			switch d.Action {
+			case UEFIDebugMode:
+				out = append(out, &ValidationFinding{Severity: SeverityError, Event: e,
+					Message: "the firmware debugger is enabled"})
+			case DMAProtectionDisabled:
+				out = append(out, &ValidationFinding{Severity: SeverityWarning, Event: e,
+					Message: "DMA protection is disabled"})
+			}
+		case *EFIVariableData:
+			if e.PCRIndex != 7 || d.VariableName != EFIGlobalVariableGuid || d.UnicodeName != "AuditMode" {
+				continue
+			}
+			if value, err := d.SecureBootState(); err == nil && value {
+				out = append(out, &ValidationFinding{Severity: SeverityWarning, Event: e,
+					Message: "the platform is in audit mode, so image verification failures are not enforced"})
+			}
+		}
+	}
+	return out
+}
@@ -0,0 +1,86 @@
+// Copyright 2019 Canonical Ltd.
+// Licensed under the LGPLv3 with static-linking exception.
+// See LICENCE file for details.
+
+package tcglog
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+)
+
+func TestSecurityAuditor(t *testing.T) {
+	variable := func(name string, value byte, alg AlgorithmId) *Event {
+		varData := EFIVariableData{VariableName: EFIGlobalVariableGuid, UnicodeName: name, VariableData: []byte{value}}
+		var b bytes.Buffer
+		varData.EncodeMeasuredBytes(&b)
+		return makeTestEvent(7, EventTypeEFIVariableDriverConfig, b.Bytes(), alg)
+	}
+	image := makeTestImageLoadEventData("\\EFI\\ubuntu\\shimx64.efi")
+
+	unmeasured := makeTestEvent(4, EventTypeEFIBootServicesApplication, image, AlgorithmSha1)
+	unmeasured.Digests[AlgorithmSha1] = make(Digest, AlgorithmSha1.Size())
+
+	for _, data := range []struct {
+		desc     string
+		log      *Log
+		findings []string
+	}{
+		{
+			desc: "Weak",
+			log: NewLog([]*Event{
+				variable("SecureBoot", 0, AlgorithmSha1),
+				variable("SetupMode", 1, AlgorithmSha1),
+				variable("AuditMode", 1, AlgorithmSha1),
+				makeTestEvent(7, EventTypeEFIAction, []byte("UEFI Debug Mode"), AlgorithmSha1),
+				makeTestEvent(7, EventTypeEFIAction, []byte("DMA Protection Disabled"), AlgorithmSha1),
+				makeTestEvent(4, EventTypeEFIBootServicesApplication, image, AlgorithmSha1),
+				unmeasured,
+				makeTestEvent(7, EventTypeSeparator, []byte{1, 0, 0, 0}, AlgorithmSha1)}),
+			findings: []string{
+				"error: weak-banks: the log only contains a SHA-1 bank",
+				"error: secure-boot: event 0 in PCR 7 (type: EV_EFI_VARIABLE_DRIVER_CONFIG): secure boot is disabled",
+				"error: secure-boot: event 1 in PCR 7 (type: EV_EFI_VARIABLE_DRIVER_CONFIG): the platform is in setup mode, " +
+					"so the secure boot keys can be modified without authentication",
+				"warning: firmware-modes: event 2 in PCR 7 (type: EV_EFI_VARIABLE_DRIVER_CONFIG): the platform is in audit mode, " +
+					"so image verification failures are not enforced",
+				"error: firmware-modes: event 3 in PCR 7 (type: EV_EFI_ACTION): the firmware debugger is enabled",
+				"warning: firmware-modes: event 4 in PCR 7 (type: EV_EFI_ACTION): DMA protection is disabled",
+				"warning: unverified-images: event 0 in PCR 4 (type: EV_EFI_BOOT_SERVICES_APPLICATION): the image was loaded " +
+					"without signature verification because secure boot is disabled",
+				"error: unverified-images: event 1 in PCR 4 (type: EV_EFI_BOOT_SERVICES_APPLICATION): the image was not measured",
+				"error: separator-errors: event 5 in PCR 7 (type: EV_SEPARATOR): the separator indicates that an error " +
+					"occurred in the pre-OS environment"},
+		},
+		{
+			desc: "SecureBootEnabled",
+			log: NewLog([]*Event{
+				variable("SecureBoot", 1, AlgorithmSha256),
+				variable("SetupMode", 0, AlgorithmSha256),
+				makeTestEvent(4, EventTypeEFIBootServicesApplication, image, AlgorithmSha256),
+				makeTestEvent(7, EventTypeEFIVariableAuthority, []byte("authority"), AlgorithmSha256),
+				makeTestEvent(4, EventTypeEFIBootServicesApplication, image, AlgorithmSha256),
+				makeTestEvent(7, EventTypeSeparator, []byte{0, 0, 0, 0}, AlgorithmSha256)}),
+			findings: []string{
+				"warning: unverified-images: event 0 in PCR 4 (type: EV_EFI_BOOT_SERVICES_APPLICATION): the image was loaded " +
+					"before any authority was measured, so it may not have been verified"},
+		},
+		{
+			desc: "NoSecureBoot",
+			log:  NewLog([]*Event{makeTestEvent(7, EventTypeSeparator, []byte{0, 0, 0, 0}, AlgorithmSha1, AlgorithmSha256)}),
+			findings: []string{
+				"warning: secure-boot: the SecureBoot variable is not measured, so the secure boot state can't be determined"},
+		},
+	} {
+		t.Run(data.desc, func(t *testing.T) {
+			var findings []string
+			for _, f := range NewSecurityAuditor().Validate(data.log) {
+				findings = append(findings, f.String())
+			}
+			if !reflect.DeepEqual(findings, data.findings) {
+				t.Errorf("Unexpected findings: %q", findings)
+			}
+		})
+	}
+}
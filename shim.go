@@ -0,0 +1,150 @@
+// Copyright 2019 Canonical Ltd.
+// Licensed under the LGPLv3 with static-linking exception.
+// See LICENCE file for details.
+
+package tcglog
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"golang.org/x/xerrors"
+)
+
+var (
+	// ShimLockGuid is the GUID of the namespace for variables owned by shim, such as MokList and SbatLevel.
+	ShimLockGuid = MakeEFIGUID(0x605dab50, 0xe046, 0x4300, 0xabb6, [...]uint8{0x3d, 0xd8, 0x10, 0xdd, 0x8b, 0x23})
+)
+
+// ShimMokPCR is the PCR that shim measures its machine owner key variables to.
+const ShimMokPCR PCRIndex = 14
+
+var shimMokVariables = map[string]bool{
+	"MokList":        true,
+	"MokListX":       true,
+	"MokListTrusted": true,
+	"MokSBState":     true,
+}
+
+// ShimMokEventData is the event data associated with shim's measurement of one of its machine owner key variables (MokList,
+// MokListX, MokListTrusted or MokSBState) to PCR 14. The event data only contains the name of the variable. The digest is of the
+// variable contents, which are not recorded in the log but can be checked against the current contents of the variable.
+type ShimMokEventData struct {
+	data []byte
+	Name string
+}
+
+func (e *ShimMokEventData) String() string {
+	return fmt.Sprintf("shim{ %s }", e.Name)
+}
+
+func (e *ShimMokEventData) Bytes() []byte {
+	return e.data
+}
+
+// EncodeTo encodes this event data in to the form in which it appears in the event log, which is the variable name with a NULL
+// terminator.
+func (e *ShimMokEventData) EncodeTo(w io.Writer) error {
+	_, err := io.WriteString(w, e.Name+"\x00")
+	return err
+}
+
+// IsSignatureDatabase indicates whether the measured variable is a signature database (MokList or MokListX).
+func (e *ShimMokEventData) IsSignatureDatabase() bool {
+	return e.Name == "MokList" || e.Name == "MokListX"
+}
+
+// SignatureDatabase decodes the supplied contents of the measured variable as a signature database. The contents can be checked
+// against the digest of the event. This returns an error if the measured variable is not a signature database (see
+// IsSignatureDatabase) or the contents cannot be decoded.
+func (e *ShimMokEventData) SignatureDatabase(contents []byte) (EFISignatureDatabase, error) {
+	if !e.IsSignatureDatabase() {
+		return nil, fmt.Errorf("%s is not a signature database", e.Name)
+	}
+	return DecodeEFISignatureDatabase(contents)
+}
+
+// https://github.com/rhboot/shim/blob/main/mok.c
+//  (mok_state_variables)
+func decodeEventDataShim(eventType EventType, data []byte) EventData {
+	if eventType != EventTypeIPL || len(data) == 0 || data[len(data)-1] != 0 {
+		return nil
+	}
+	name := string(data[:len(data)-1])
+	if !shimMokVariables[name] {
+		return nil
+	}
+	return &ShimMokEventData{data: data, Name: name}
+}
+
+// SbatEntry corresponds to a single entry in a SBAT revocation level, which specifies the minimum generation of a component
+// that is permitted to be loaded.
+type SbatEntry struct {
+	Component  string
+	Generation int
+	Date       string // The date the entry was added, which is only present for the "sbat" entry
+}
+
+// SbatLevel corresponds to the contents of shim's SbatLevel variable.
+type SbatLevel []SbatEntry
+
+func (l SbatLevel) String() string {
+	var builder bytes.Buffer
+	for _, e := range l {
+		fmt.Fprintf(&builder, "%s,%d", e.Component, e.Generation)
+		if e.Date != "" {
+			fmt.Fprintf(&builder, ",%s", e.Date)
+		}
+		builder.WriteString("\n")
+	}
+	return builder.String()
+}
+
+// DecodeSbatLevel decodes the supplied data as the contents of shim's SbatLevel variable, which is a sequence of newline
+// separated "component,generation[,date]" entries.
+//
+// https://github.com/rhboot/shim/blob/main/SBAT.md
+func DecodeSbatLevel(data []byte) (SbatLevel, error) {
+	var out SbatLevel
+	for i, line := range strings.Split(strings.TrimRight(string(data), "\x00"), "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" {
+			continue
+		}
+		fields := strings.Split(line, ",")
+		if len(fields) < 2 || len(fields) > 3 || fields[0] == "" {
+			return nil, fmt.Errorf("invalid entry %d", i)
+		}
+		generation, err := strconv.Atoi(fields[1])
+		if err != nil {
+			return nil, xerrors.Errorf("invalid generation for entry %d: %w", i, err)
+		}
+		e := SbatEntry{Component: fields[0], Generation: generation}
+		if len(fields) == 3 {
+			e.Date = fields[2]
+		}
+		out = append(out, e)
+	}
+	if len(out) == 0 {
+		return nil, errors.New("no entries")
+	}
+	return out, nil
+}
+
+// IsSbatLevel indicates whether this is shim's measurement of its SbatLevel variable.
+func (e *EFIVariableData) IsSbatLevel() bool {
+	return e.VariableName == ShimLockGuid && e.UnicodeName == "SbatLevel"
+}
+
+// SbatLevel decodes the variable data as a SBAT revocation level. This returns an error if this is not the measurement of the
+// SbatLevel variable (see IsSbatLevel) or the variable data cannot be decoded.
+func (e *EFIVariableData) SbatLevel() (SbatLevel, error) {
+	if !e.IsSbatLevel() {
+		return nil, fmt.Errorf("%s-%s is not the SbatLevel variable", e.UnicodeName, e.VariableName)
+	}
+	return DecodeSbatLevel(e.VariableData)
+}
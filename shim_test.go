@@ -0,0 +1,147 @@
+// Copyright 2019 Canonical Ltd.
+// Licensed under the LGPLv3 with static-linking exception.
+// See LICENCE file for details.
+
+package tcglog
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+)
+
+func TestDecodeEventDataShimMok(t *testing.T) {
+	for _, data := range []struct {
+		desc string
+		data []byte
+		name string
+		isDb bool
+	}{
+		{desc: "MokList", data: []byte("MokList\x00"), name: "MokList", isDb: true},
+		{desc: "MokListX", data: []byte("MokListX\x00"), name: "MokListX", isDb: true},
+		{desc: "MokListTrusted", data: []byte("MokListTrusted\x00"), name: "MokListTrusted"},
+		{desc: "MokSBState", data: []byte("MokSBState\x00"), name: "MokSBState"},
+	} {
+		t.Run(data.desc, func(t *testing.T) {
+			d, ok := DecodeEventData(ShimMokPCR, EventTypeIPL, DigestMap{}, data.data, nil).(*ShimMokEventData)
+			if !ok {
+				t.Fatalf("Unexpected event data type")
+			}
+			if d.Name != data.name {
+				t.Errorf("Unexpected name: %s", d.Name)
+			}
+			if d.IsSignatureDatabase() != data.isDb {
+				t.Errorf("Unexpected IsSignatureDatabase result")
+			}
+
+			var b bytes.Buffer
+			if err := d.EncodeTo(&b); err != nil {
+				t.Fatalf("EncodeTo failed: %v", err)
+			}
+			if !bytes.Equal(b.Bytes(), data.data) {
+				t.Errorf("Unexpected encoding: %x", b.Bytes())
+			}
+		})
+	}
+
+	t.Run("Encode", func(t *testing.T) {
+		var b bytes.Buffer
+		if err := (&ShimMokEventData{Name: "MokList"}).EncodeTo(&b); err != nil {
+			t.Fatalf("EncodeTo failed: %v", err)
+		}
+		if !bytes.Equal(b.Bytes(), []byte("MokList\x00")) {
+			t.Errorf("Unexpected encoding: %x", b.Bytes())
+		}
+	})
+
+	t.Run("OtherPCR", func(t *testing.T) {
+		if _, ok := DecodeEventData(4, EventTypeIPL, DigestMap{}, []byte("MokList\x00"), nil).(*ShimMokEventData); ok {
+			t.Errorf("Unexpected event data type")
+		}
+	})
+
+	t.Run("OtherName", func(t *testing.T) {
+		if _, ok := DecodeEventData(ShimMokPCR, EventTypeIPL, DigestMap{}, []byte("Foo\x00"), nil).(*ShimMokEventData); ok {
+			t.Errorf("Unexpected event data type")
+		}
+	})
+}
+
+func TestShimMokSignatureDatabase(t *testing.T) {
+	cert := makeTestCertificate(t, "MOK")
+	contents := makeTestSignatureList(EFICertX509Guid, ShimLockGuid, cert)
+
+	d := &ShimMokEventData{data: []byte("MokList\x00"), Name: "MokList"}
+	decoded, err := d.SignatureDatabase(contents)
+	if err != nil {
+		t.Fatalf("SignatureDatabase failed: %v", err)
+	}
+	if len(decoded) != 1 || len(decoded[0].Signatures) != 1 || !reflect.DeepEqual(decoded[0].Signatures[0].Data, cert) {
+		t.Errorf("Unexpected signature database")
+	}
+
+	d = &ShimMokEventData{data: []byte("MokSBState\x00"), Name: "MokSBState"}
+	if _, err := d.SignatureDatabase([]byte{0x01}); err == nil {
+		t.Errorf("Expected an error")
+	}
+}
+
+func TestDecodeSbatLevel(t *testing.T) {
+	for _, data := range []struct {
+		desc     string
+		data     string
+		expected SbatLevel
+		err      bool
+	}{
+		{
+			desc:     "Original",
+			data:     "sbat,1,2021030218\n",
+			expected: SbatLevel{{Component: "sbat", Generation: 1, Date: "2021030218"}},
+		},
+		{
+			desc: "Revocations",
+			data: "sbat,1,2022052400\nshim,2\ngrub,2\n",
+			expected: SbatLevel{
+				{Component: "sbat", Generation: 1, Date: "2022052400"},
+				{Component: "shim", Generation: 2},
+				{Component: "grub", Generation: 2}},
+		},
+		{desc: "Empty", data: "", err: true},
+		{desc: "BadGeneration", data: "sbat,x\n", err: true},
+	} {
+		t.Run(data.desc, func(t *testing.T) {
+			level, err := DecodeSbatLevel([]byte(data.data))
+			if data.err {
+				if err == nil {
+					t.Errorf("Expected an error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("DecodeSbatLevel failed: %v", err)
+			}
+			if !reflect.DeepEqual(level, data.expected) {
+				t.Errorf("Unexpected level: %v", level)
+			}
+			if level.String() != data.data {
+				t.Errorf("Unexpected string: %q", level.String())
+			}
+		})
+	}
+}
+
+func TestEFIVariableDataSbatLevel(t *testing.T) {
+	v := &EFIVariableData{VariableName: ShimLockGuid, UnicodeName: "SbatLevel", VariableData: []byte("sbat,1,2021030218\n")}
+	level, err := v.SbatLevel()
+	if err != nil {
+		t.Fatalf("SbatLevel failed: %v", err)
+	}
+	if len(level) != 1 || level[0].Component != "sbat" {
+		t.Errorf("Unexpected level: %v", level)
+	}
+
+	v = &EFIVariableData{VariableName: EFIGlobalVariableGuid, UnicodeName: "SbatLevel"}
+	if _, err := v.SbatLevel(); err == nil {
+		t.Errorf("Expected an error")
+	}
+}
@@ -0,0 +1,330 @@
+// Copyright 2019 Canonical Ltd.
+// Licensed under the LGPLv3 with static-linking exception.
+// See LICENCE file for details.
+
+package tcglog
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	"golang.org/x/xerrors"
+)
+
+// Simulation applies hypothetical changes to a copy of a log and recomputes the PCR values, so that the impact of a change to the
+// boot chain (eg, an updated bootloader or a new grub.cfg) can be assessed before rebooting. Events are identified by the
+// *Event from the original log, so that the original log can be used to select the events to change.
+type Simulation struct {
+	base    *Log
+	log     *Log
+	events  map[*Event]*Event
+	options LogOptions
+}
+
+// NewSimulation creates a new simulation for the supplied log. The options should be the same as those used to parse the log, and
+// determine which PCRs GRUB measures to. The supplied log is not modified.
+func NewSimulation(log *Log, options *LogOptions) *Simulation {
+	s := &Simulation{
+		base:   log,
+		log:    log.ShallowCopy(),
+		events: make(map[*Event]*Event)}
+	if options != nil {
+		s.options = *options
+	}
+	for i, e := range log.Events {
+		s.events[e] = s.log.Events[i]
+	}
+	return s
+}
+
+// Log returns the simulated log with the changes applied so far.
+func (s *Simulation) Log() *Log {
+	return s.log
+}
+
+func (s *Simulation) event(e *Event) (*Event, error) {
+	sim, ok := s.events[e]
+	if !ok {
+		return nil, errors.New("event is not part of the simulated log")
+	}
+	return sim, nil
+}
+
+// DropEvent simulates the supplied event from the original log not being measured.
+func (s *Simulation) DropEvent(e *Event) error {
+	sim, err := s.event(e)
+	if err != nil {
+		return err
+	}
+	for i, c := range s.log.Events {
+		if c == sim {
+			delete(s.events, e)
+			return s.log.RemoveEvent(i)
+		}
+	}
+	panic("simulated event not found")
+}
+
+// SetDigest simulates the supplied event from the original log being measured with the specified digest for the specified
+// algorithm.
+func (s *Simulation) SetDigest(e *Event, alg AlgorithmId, digest Digest) error {
+	sim, err := s.event(e)
+	if err != nil {
+		return err
+	}
+	if !s.log.Algorithms.Contains(alg) {
+		return fmt.Errorf("log does not contain digests for algorithm %v", alg)
+	}
+	if len(digest) != alg.Size() {
+		return fmt.Errorf("invalid digest length for algorithm %v (%d)", alg, len(digest))
+	}
+	sim.Digests[alg] = append(Digest(nil), digest...)
+	return nil
+}
+
+// SetMeasuredData simulates the supplied event from the original log being measured with the supplied data, by replacing the
+// digest for each algorithm with the digest of data. The event data is not changed.
+func (s *Simulation) SetMeasuredData(e *Event, data []byte) error {
+	sim, err := s.event(e)
+	if err != nil {
+		return err
+	}
+	for _, alg := range s.log.Algorithms {
+		if !alg.supported() {
+			return fmt.Errorf("unsupported algorithm %v", alg)
+		}
+		sim.Digests[alg] = alg.hash(data)
+	}
+	return nil
+}
+
+// ReplaceGrubFile simulates GRUB measuring the supplied contents for the file with the specified path (eg,
+// "/EFI/ubuntu/grub.cfg"), which matches the path recorded by GRUB with or without its device prefix. Every measurement of the
+// file is replaced, and an error is returned if the log doesn't contain any. Note that this doesn't simulate the commands that
+// GRUB measures when executing a new configuration file. These can be simulated with DropEvent and SetMeasuredData.
+func (s *Simulation) ReplaceGrubFile(path string, contents []byte) error {
+	found := false
+	for _, e := range s.base.Events {
+		if e.PCRIndex != s.options.grubFilePCR() || e.EventType != EventTypeIPL {
+			continue
+		}
+		if _, ok := s.events[e]; !ok {
+			continue
+		}
+		recorded := strings.TrimRight(string(e.Data.Bytes()), "\x00")
+		if recorded != path && !strings.HasSuffix(recorded, ")"+path) {
+			continue
+		}
+		if err := s.SetMeasuredData(e, contents); err != nil {
+			return err
+		}
+		found = true
+	}
+	if !found {
+		return fmt.Errorf("no measurement of %s in the log", path)
+	}
+	return nil
+}
+
+// ReplaceEFIImage simulates the EFI image with the specified path (eg, "/EFI/ubuntu/shimx64.efi") being replaced by the supplied
+// PE image, by replacing the digests of every image load event for that path with the Authenticode digests of the new image.
+// Paths are compared case-insensitively because the EFI system partition is a FAT filesystem. An error is returned if the log
+// doesn't contain any loads of the image.
+func (s *Simulation) ReplaceEFIImage(path string, image io.ReaderAt, size int64) error {
+	digests := make(DigestMap)
+	for _, alg := range s.log.Algorithms {
+		if !alg.supported() {
+			return fmt.Errorf("unsupported algorithm %v", alg)
+		}
+		digest, err := ComputeAuthenticodeDigest(image, size, alg)
+		if err != nil {
+			return xerrors.Errorf("cannot compute Authenticode digest: %w", err)
+		}
+		digests[alg] = digest
+	}
+
+	found := false
+	for _, e := range s.base.Events {
+		if _, ok := s.events[e]; !ok || !isImageLoadEvent(e) {
+			continue
+		}
+		d, ok := e.Data.(*EFIImageLoadEvent)
+		if !ok || !strings.EqualFold(d.FilePath(), path) {
+			continue
+		}
+		for alg, digest := range digests {
+			if err := s.SetDigest(e, alg, digest); err != nil {
+				return err
+			}
+		}
+		found = true
+	}
+	if !found {
+		return fmt.Errorf("no load of %s in the log", path)
+	}
+	return nil
+}
+
+// replaceGrubStrings simulates GRUB measuring the string returned from fn instead of each string with the specified prefix that
+// it measured to its command PCR. Strings for which fn returns false are not modified.
+func (s *Simulation) replaceGrubStrings(prefix string, fn func(string) (string, bool)) (found bool, err error) {
+	t := GrubCmd
+	if prefix == kernelCmdlinePrefix {
+		t = KernelCmdline
+	}
+
+	for _, e := range s.base.Events {
+		if e.PCRIndex != s.options.grubCmdPCR() || e.EventType != EventTypeIPL {
+			continue
+		}
+		sim, ok := s.events[e]
+		if !ok {
+			continue
+		}
+		recorded := strings.TrimRight(string(e.Data.Bytes()), "\x00")
+		if !strings.HasPrefix(recorded, prefix) {
+			continue
+		}
+		str, ok := fn(strings.TrimPrefix(recorded, prefix))
+		if !ok {
+			continue
+		}
+		if err := s.SetMeasuredData(e, []byte(str)); err != nil {
+			return false, err
+		}
+		sim.Data = &GrubStringEventData{data: []byte(prefix + str + "\x00"), Type: t, Str: str}
+		found = true
+	}
+	return found, nil
+}
+
+// ReplaceGrubCommand simulates GRUB executing the command newCmd instead of every instance of the command oldCmd (eg, to simulate
+// a "linux" command that loads a new kernel). An error is returned if the log doesn't contain any measurements of oldCmd.
+func (s *Simulation) ReplaceGrubCommand(oldCmd, newCmd string) error {
+	found, err := s.replaceGrubStrings(grubCmdPrefix, func(cmd string) (string, bool) {
+		return newCmd, cmd == oldCmd
+	})
+	if err != nil {
+		return err
+	}
+	if !found {
+		return fmt.Errorf("no measurement of the command \"%s\" in the log", oldCmd)
+	}
+	return nil
+}
+
+// SetKernelCmdline simulates GRUB measuring the supplied kernel command line in place of every kernel command line that it
+// measured. An error is returned if the log doesn't contain any.
+func (s *Simulation) SetKernelCmdline(cmdline string) error {
+	found, err := s.replaceGrubStrings(kernelCmdlinePrefix, func(string) (string, bool) {
+		return cmdline, true
+	})
+	if err != nil {
+		return err
+	}
+	if !found {
+		return errors.New("no measurement of a kernel command line in the log")
+	}
+	return nil
+}
+
+// SetEFIVariable simulates the EFI variable with the specified name and GUID having the supplied value, by replacing every
+// EV_EFI_VARIABLE_DRIVER_CONFIG and EV_EFI_VARIABLE_BOOT measurement of it. Some firmware implementations measure only the
+// variable data for EV_EFI_VARIABLE_BOOT events rather than the full UEFI_VARIABLE_DATA structure, and this is detected from the
+// original measurement. An empty value simulates the variable not existing. An error is returned if the log doesn't contain any
+// measurements of the variable.
+func (s *Simulation) SetEFIVariable(name string, guid EFIGUID, data []byte) error {
+	found := false
+	for _, e := range s.base.Events {
+		if e.EventType != EventTypeEFIVariableDriverConfig && e.EventType != EventTypeEFIVariableBoot {
+			continue
+		}
+		sim, ok := s.events[e]
+		if !ok {
+			continue
+		}
+		d, ok := e.Data.(*EFIVariableData)
+		if !ok || d.UnicodeName != name || d.VariableName != guid {
+			continue
+		}
+
+		dataOnly := false
+		for alg, digest := range e.Digests {
+			if alg.supported() && bytes.Equal(alg.hash(d.VariableData), digest) {
+				dataOnly = true
+			}
+		}
+
+		v := &EFIVariableData{VariableName: guid, UnicodeName: name, VariableData: append([]byte(nil), data...)}
+		var measured bytes.Buffer
+		if dataOnly {
+			measured.Write(v.VariableData)
+		} else if err := v.EncodeMeasuredBytes(&measured); err != nil {
+			return err
+		}
+		if err := s.SetMeasuredData(e, measured.Bytes()); err != nil {
+			return err
+		}
+		var encoded bytes.Buffer
+		if err := v.EncodeMeasuredBytes(&encoded); err != nil {
+			return err
+		}
+		sim.Data = DecodeEventData(e.PCRIndex, e.EventType, sim.Digests, encoded.Bytes(), &s.options)
+		found = true
+	}
+	if !found {
+		return fmt.Errorf("no measurement of the variable %s-%s in the log", name, guid)
+	}
+	return nil
+}
+
+// SimulatedPCR describes the value of a PCR before and after the changes in a simulation.
+type SimulatedPCR struct {
+	PCRIndex  PCRIndex
+	Algorithm AlgorithmId
+	Before    Digest // The value of the PCR computed from the original log, or nil if an event is missing a digest for Algorithm
+	After     Digest // The value of the PCR computed from the simulated log, or nil if an event is missing a digest for Algorithm
+}
+
+// Changed indicates whether the value of the PCR is changed by the simulation.
+func (p *SimulatedPCR) Changed() bool {
+	return !bytes.Equal(p.Before, p.After)
+}
+
+func (p *SimulatedPCR) String() string {
+	if !p.Changed() {
+		return fmt.Sprintf("PCR %d, bank %v: unchanged (%x)", p.PCRIndex, p.Algorithm, p.Before)
+	}
+	return fmt.Sprintf("PCR %d, bank %v: %x -> %x", p.PCRIndex, p.Algorithm, p.Before, p.After)
+}
+
+// Run recomputes the values of every PCR measured to in the original or simulated log for each supported algorithm, and returns
+// them along with the original values, sorted by PCR index.
+func (s *Simulation) Run() []*SimulatedPCR {
+	seen := make(map[PCRIndex]bool)
+	var pcrs []PCRIndex
+	for _, pcr := range append(s.base.measuredPCRs(), s.log.measuredPCRs()...) {
+		if !seen[pcr] {
+			seen[pcr] = true
+			pcrs = append(pcrs, pcr)
+		}
+	}
+	sort.Slice(pcrs, func(i, j int) bool { return pcrs[i] < pcrs[j] })
+
+	var out []*SimulatedPCR
+	for _, pcr := range pcrs {
+		for _, alg := range s.log.Algorithms {
+			if !alg.supported() {
+				continue
+			}
+			before, _ := s.base.replayPCR(alg, pcr)
+			after, _ := s.log.replayPCR(alg, pcr)
+			out = append(out, &SimulatedPCR{PCRIndex: pcr, Algorithm: alg, Before: before, After: after})
+		}
+	}
+	return out
+}
@@ -0,0 +1,246 @@
+// Copyright 2019 Canonical Ltd.
+// Licensed under the LGPLv3 with static-linking exception.
+// See LICENCE file for details.
+
+package tcglog
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestSimulation(t *testing.T) {
+	algs := AlgorithmIdList{AlgorithmSha1, AlgorithmSha256}
+	b, err := NewLogBuilder(algs, nil)
+	if err != nil {
+		t.Fatalf("NewLogBuilder failed: %v", err)
+	}
+	b.AddEvent(4, EventTypeEFIAction, []byte("Calling EFI Application from Boot Option"))
+	b.AddEvent(4, EventTypeSeparator, []byte{0, 0, 0, 0})
+	b.AddEvent(7, EventTypeSeparator, []byte{0, 0, 0, 0})
+	b.AddEventWithDigests(9, EventTypeIPL, DigestMap{AlgorithmSha1: AlgorithmSha1.hash([]byte("old config")),
+		AlgorithmSha256: AlgorithmSha256.hash([]byte("old config"))}, []byte("(hd0,gpt1)/EFI/ubuntu/grub.cfg\x00"))
+	b.AddEventWithDigests(8, EventTypeIPL, DigestMap{AlgorithmSha1: AlgorithmSha1.hash([]byte("set timeout=5")),
+		AlgorithmSha256: AlgorithmSha256.hash([]byte("set timeout=5"))}, []byte("grub_cmd: set timeout=5\x00"))
+	log := b.Log()
+
+	findEvent := func(pcr PCRIndex, eventType EventType) *Event {
+		for _, e := range log.Events {
+			if e.PCRIndex == pcr && e.EventType == eventType {
+				return e
+			}
+		}
+		t.Fatalf("missing event")
+		return nil
+	}
+
+	t.Run("NoChanges", func(t *testing.T) {
+		results := NewSimulation(log, nil).Run()
+		if len(results) != 8 {
+			t.Fatalf("Unexpected number of results (%d)", len(results))
+		}
+		for _, r := range results {
+			if r.Changed() {
+				t.Errorf("Unexpected change: %s", r)
+			}
+		}
+		if results[0].PCRIndex != 4 || results[0].Algorithm != AlgorithmSha1 || results[7].PCRIndex != 9 {
+			t.Errorf("Unexpected ordering")
+		}
+	})
+
+	t.Run("GrubConfig", func(t *testing.T) {
+		s := NewSimulation(log, nil)
+		if err := s.ReplaceGrubFile("/EFI/ubuntu/grub.cfg", []byte("new config")); err != nil {
+			t.Fatalf("ReplaceGrubFile failed: %v", err)
+		}
+		for _, r := range s.Run() {
+			if r.Changed() != (r.PCRIndex == 9) {
+				t.Errorf("Unexpected result: %s", r)
+			}
+			if r.PCRIndex == 9 {
+				expected := extendDigest(r.Algorithm, make(Digest, r.Algorithm.Size()), r.Algorithm.hash([]byte("new config")))
+				if !bytes.Equal(r.After, expected) {
+					t.Errorf("Unexpected value: %s", r)
+				}
+			}
+		}
+		if !bytes.Equal(findEvent(9, EventTypeIPL).Digests[AlgorithmSha256], AlgorithmSha256.hash([]byte("old config"))) {
+			t.Errorf("Original log was modified")
+		}
+
+		if err := s.ReplaceGrubFile("/boot/grub/grub.cfg", nil); err == nil ||
+			err.Error() != "no measurement of /boot/grub/grub.cfg in the log" {
+			t.Errorf("Unexpected error: %v", err)
+		}
+	})
+
+	t.Run("DropEvent", func(t *testing.T) {
+		s := NewSimulation(log, nil)
+		e := findEvent(8, EventTypeIPL)
+		if err := s.DropEvent(e); err != nil {
+			t.Fatalf("DropEvent failed: %v", err)
+		}
+		results := s.Run()
+		if len(results) != 8 {
+			t.Fatalf("Unexpected number of results (%d)", len(results))
+		}
+		for _, r := range results {
+			if r.Changed() != (r.PCRIndex == 8) {
+				t.Errorf("Unexpected result: %s", r)
+			}
+			if r.PCRIndex == 8 && !bytes.Equal(r.After, make(Digest, r.Algorithm.Size())) {
+				t.Errorf("Unexpected value: %s", r)
+			}
+		}
+		if len(s.Log().Events) != len(log.Events)-1 {
+			t.Errorf("Unexpected number of simulated events")
+		}
+		if err := s.DropEvent(e); err == nil || err.Error() != "event is not part of the simulated log" {
+			t.Errorf("Unexpected error: %v", err)
+		}
+	})
+
+	t.Run("SetDigest", func(t *testing.T) {
+		s := NewSimulation(log, nil)
+		e := findEvent(7, EventTypeSeparator)
+		digest := AlgorithmSha256.hash([]byte{0xff, 0xff, 0xff, 0xff})
+		if err := s.SetDigest(e, AlgorithmSha256, digest); err != nil {
+			t.Fatalf("SetDigest failed: %v", err)
+		}
+		for _, r := range s.Run() {
+			if r.Changed() != (r.PCRIndex == 7 && r.Algorithm == AlgorithmSha256) {
+				t.Errorf("Unexpected result: %s", r)
+			}
+		}
+
+		if err := s.SetDigest(e, AlgorithmSha384, make(Digest, 48)); err == nil ||
+			err.Error() != "log does not contain digests for algorithm SHA-384" {
+			t.Errorf("Unexpected error: %v", err)
+		}
+		if err := s.SetDigest(e, AlgorithmSha1, digest); err == nil ||
+			err.Error() != "invalid digest length for algorithm SHA-1 (32)" {
+			t.Errorf("Unexpected error: %v", err)
+		}
+	})
+}
+
+func makeBootAssetsTestLog() *Log {
+	algs := []AlgorithmId{AlgorithmSha1, AlgorithmSha256}
+	grubEvent := func(pcr PCRIndex, data, measured string) *Event {
+		e := makeTestEvent(pcr, EventTypeIPL, []byte(measured), algs...)
+		e.Data = DecodeEventData(pcr, EventTypeIPL, e.Digests, []byte(data), &LogOptions{EnableGrub: true})
+		return e
+	}
+	variable := func(pcr PCRIndex, eventType EventType, guid EFIGUID, name string, data []byte, dataOnly bool) *Event {
+		v := &EFIVariableData{VariableName: guid, UnicodeName: name, VariableData: data}
+		var b bytes.Buffer
+		v.EncodeMeasuredBytes(&b)
+		e := makeTestEvent(pcr, eventType, b.Bytes(), algs...)
+		if dataOnly {
+			for _, alg := range algs {
+				e.Digests[alg] = alg.hash(data)
+			}
+		}
+		return e
+	}
+
+	return NewLog([]*Event{
+		variable(7, EventTypeEFIVariableDriverConfig, EFIImageSecurityDatabaseGuid, "dbx", []byte("old dbx"), false),
+		variable(1, EventTypeEFIVariableBoot, EFIGlobalVariableGuid, "BootOrder", []byte{0x01, 0x00}, true),
+		makeTestEvent(4, EventTypeEFIBootServicesApplication, makeTestImageLoadEventData("\\EFI\\ubuntu\\shimx64.efi"),
+			algs...),
+		grubEvent(8, "grub_cmd: linux /vmlinuz-5.4.0-42-generic\x00", "linux /vmlinuz-5.4.0-42-generic"),
+		grubEvent(8, "kernel_cmdline: /vmlinuz-5.4.0-42-generic ro\x00", "/vmlinuz-5.4.0-42-generic ro"),
+		grubEvent(9, "/vmlinuz-5.4.0-42-generic\x00", "old kernel"),
+	})
+}
+
+func TestSimulationBootAssets(t *testing.T) {
+	log := makeBootAssetsTestLog()
+
+	changed := func(results []*SimulatedPCR) (out []PCRIndex) {
+		for _, r := range results {
+			if r.Changed() && r.Algorithm == AlgorithmSha256 {
+				out = append(out, r.PCRIndex)
+			}
+		}
+		return out
+	}
+
+	t.Run("ReplaceEFIImage", func(t *testing.T) {
+		s := NewSimulation(log, nil)
+		image := makeTestPEImage(0, []byte("shim 15.7"), nil, nil)
+		if err := s.ReplaceEFIImage("/efi/UBUNTU/shimx64.efi", bytes.NewReader(image), int64(len(image))); err != nil {
+			t.Fatalf("ReplaceEFIImage failed: %v", err)
+		}
+		expected, _ := ComputeAuthenticodeDigest(bytes.NewReader(image), int64(len(image)), AlgorithmSha256)
+		if !bytes.Equal(s.Log().Events[2].Digests[AlgorithmSha256], expected) {
+			t.Errorf("Unexpected digest")
+		}
+		if pcrs := changed(s.Run()); len(pcrs) != 1 || pcrs[0] != 4 {
+			t.Errorf("Unexpected changed PCRs: %v", pcrs)
+		}
+		if err := s.ReplaceEFIImage("/EFI/ubuntu/grubx64.efi", bytes.NewReader(image), int64(len(image))); err == nil ||
+			err.Error() != "no load of /EFI/ubuntu/grubx64.efi in the log" {
+			t.Errorf("Unexpected error: %v", err)
+		}
+	})
+
+	t.Run("ReplaceGrubCommand", func(t *testing.T) {
+		s := NewSimulation(log, &LogOptions{EnableGrub: true})
+		if err := s.ReplaceGrubCommand("linux /vmlinuz-5.4.0-42-generic", "linux /vmlinuz-5.4.0-45-generic"); err != nil {
+			t.Fatalf("ReplaceGrubCommand failed: %v", err)
+		}
+		e := s.Log().Events[3]
+		if !bytes.Equal(e.Digests[AlgorithmSha256], AlgorithmSha256.hash([]byte("linux /vmlinuz-5.4.0-45-generic"))) ||
+			e.Data.String() != "grub_cmd{ linux /vmlinuz-5.4.0-45-generic }" {
+			t.Errorf("Unexpected event: %s", e.Data)
+		}
+		if pcrs := changed(s.Run()); len(pcrs) != 1 || pcrs[0] != 8 {
+			t.Errorf("Unexpected changed PCRs: %v", pcrs)
+		}
+		if err := s.ReplaceGrubCommand("initrd /initrd.img", "initrd /initrd.img.new"); err == nil ||
+			err.Error() != "no measurement of the command \"initrd /initrd.img\" in the log" {
+			t.Errorf("Unexpected error: %v", err)
+		}
+	})
+
+	t.Run("SetKernelCmdline", func(t *testing.T) {
+		s := NewSimulation(log, nil)
+		if err := s.SetKernelCmdline("/vmlinuz-5.4.0-42-generic ro quiet"); err != nil {
+			t.Fatalf("SetKernelCmdline failed: %v", err)
+		}
+		e := s.Log().Events[4]
+		if !bytes.Equal(e.Digests[AlgorithmSha1], AlgorithmSha1.hash([]byte("/vmlinuz-5.4.0-42-generic ro quiet"))) ||
+			e.Data.String() != "kernel_cmdline{ /vmlinuz-5.4.0-42-generic ro quiet }" {
+			t.Errorf("Unexpected event: %s", e.Data)
+		}
+	})
+
+	t.Run("SetEFIVariable", func(t *testing.T) {
+		s := NewSimulation(log, nil)
+		if err := s.SetEFIVariable("dbx", EFIImageSecurityDatabaseGuid, []byte("new dbx")); err != nil {
+			t.Fatalf("SetEFIVariable failed: %v", err)
+		}
+		if err := s.SetEFIVariable("BootOrder", EFIGlobalVariableGuid, []byte{0x02, 0x00, 0x01, 0x00}); err != nil {
+			t.Fatalf("SetEFIVariable failed: %v", err)
+		}
+
+		var expected bytes.Buffer
+		v := &EFIVariableData{VariableName: EFIImageSecurityDatabaseGuid, UnicodeName: "dbx", VariableData: []byte("new dbx")}
+		v.EncodeMeasuredBytes(&expected)
+		dbx := s.Log().Events[0]
+		if !bytes.Equal(dbx.Digests[AlgorithmSha256], AlgorithmSha256.hash(expected.Bytes())) ||
+			!bytes.Equal(dbx.Data.(*EFIVariableData).VariableData, []byte("new dbx")) {
+			t.Errorf("Unexpected dbx event")
+		}
+		// BootOrder was measured without the UEFI_VARIABLE_DATA header.
+		if !bytes.Equal(s.Log().Events[1].Digests[AlgorithmSha256], AlgorithmSha256.hash([]byte{0x02, 0x00, 0x01, 0x00})) {
+			t.Errorf("Unexpected BootOrder event")
+		}
+		if pcrs := changed(s.Run()); len(pcrs) != 2 || pcrs[0] != 1 || pcrs[1] != 7 {
+			t.Errorf("Unexpected changed PCRs: %v", pcrs)
+		}
+	})
+}
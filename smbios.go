@@ -0,0 +1,183 @@
+// Copyright 2019 Canonical Ltd.
+// Licensed under the LGPLv3 with static-linking exception.
+// See LICENCE file for details.
+
+package tcglog
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+)
+
+const (
+	smbiosTypeBIOSInformation   = 0
+	smbiosTypeSystemInformation = 1
+	smbiosTypeEndOfTable        = 127
+)
+
+var smbiosTypeNames = map[uint8]string{
+	0:   "BIOS Information",
+	1:   "System Information",
+	2:   "Baseboard Information",
+	3:   "System Enclosure",
+	4:   "Processor Information",
+	7:   "Cache Information",
+	8:   "Port Connector Information",
+	9:   "System Slots",
+	11:  "OEM Strings",
+	12:  "System Configuration Options",
+	13:  "BIOS Language Information",
+	16:  "Physical Memory Array",
+	17:  "Memory Device",
+	19:  "Memory Array Mapped Address",
+	32:  "System Boot Information",
+	43:  "TPM Device",
+	127: "End-of-Table",
+}
+
+// SMBIOSStructure corresponds to a single structure in a SMBIOS structure table.
+type SMBIOSStructure struct {
+	Type      uint8    // The type of this structure
+	Handle    uint16   // The handle of this structure
+	Formatted []byte   // The formatted area of this structure, including the header
+	Strings   []string // The strings in the unformed area of this structure
+}
+
+// TypeName returns a human readable name for the type of this structure.
+func (s *SMBIOSStructure) TypeName() string {
+	if name, ok := smbiosTypeNames[s.Type]; ok {
+		return name
+	}
+	if s.Type >= 128 {
+		return fmt.Sprintf("OEM-specific (%d)", s.Type)
+	}
+	return fmt.Sprintf("Type %d", s.Type)
+}
+
+// StringAt returns the string referenced by the string number at the specified offset in the formatted area. It returns an empty
+// string if the offset is outside of the formatted area or the string number is zero or invalid.
+func (s *SMBIOSStructure) StringAt(offset int) string {
+	if offset >= len(s.Formatted) {
+		return ""
+	}
+	n := int(s.Formatted[offset])
+	if n == 0 || n > len(s.Strings) {
+		return ""
+	}
+	return s.Strings[n-1]
+}
+
+func (s *SMBIOSStructure) String() string {
+	return fmt.Sprintf("{ Type: %d (%s), Handle: 0x%04x, Length: %d }", s.Type, s.TypeName(), s.Handle, len(s.Formatted))
+}
+
+// DecodeSMBIOSStructureTable decodes the supplied SMBIOS structure table, such as the one exposed by Linux at
+// /sys/firmware/dmi/tables/DMI. The log only records the address of SMBIOS tables that are measured in EV_EFI_HANDOFF_TABLES events,
+// so the table contents must be obtained separately. Decoding stops after the End-of-Table structure, if there is one.
+//
+// https://www.dmtf.org/sites/default/files/standards/documents/DSP0134_3.2.0.pdf
+//  (section 6.1 "Structure table format")
+func DecodeSMBIOSStructureTable(data []byte) ([]*SMBIOSStructure, error) {
+	var out []*SMBIOSStructure
+
+	for i := 0; len(data) > 0; i++ {
+		if len(data) < 4 {
+			return nil, fmt.Errorf("cannot decode structure %d: truncated header", i)
+		}
+		length := int(data[1])
+		if length < 4 || length > len(data) {
+			return nil, fmt.Errorf("cannot decode structure %d: invalid length (%d)", i, length)
+		}
+
+		s := &SMBIOSStructure{
+			Type:      data[0],
+			Handle:    binary.LittleEndian.Uint16(data[2:]),
+			Formatted: data[:length]}
+
+		// The unformed area is a sequence of NULL terminated strings, terminated with an additional NULL byte.
+		end := bytes.Index(data[length:], []byte{0, 0})
+		if end < 0 {
+			return nil, fmt.Errorf("cannot decode structure %d: unterminated string set", i)
+		}
+		strs := data[length : length+end]
+		if len(strs) > 0 {
+			for _, str := range bytes.Split(strs, []byte{0}) {
+				s.Strings = append(s.Strings, string(str))
+			}
+		}
+
+		out = append(out, s)
+		data = data[length+end+2:]
+
+		if s.Type == smbiosTypeEndOfTable {
+			break
+		}
+	}
+
+	if len(out) == 0 {
+		return nil, errors.New("no structures")
+	}
+	return out, nil
+}
+
+// SMBIOSSystemInfo contains identifying details of a system, obtained from its SMBIOS structures.
+type SMBIOSSystemInfo struct {
+	BIOSVendor         string
+	BIOSVersion        string
+	BIOSReleaseDate    string
+	SystemManufacturer string
+	SystemProductName  string
+	SystemVersion      string
+}
+
+// GetSMBIOSSystemInfo returns the system vendor, product and BIOS version details from the supplied SMBIOS structures. Details
+// that aren't present are left empty.
+func GetSMBIOSSystemInfo(structures []*SMBIOSStructure) *SMBIOSSystemInfo {
+	info := &SMBIOSSystemInfo{}
+	for _, s := range structures {
+		switch s.Type {
+		case smbiosTypeBIOSInformation:
+			info.BIOSVendor = s.StringAt(0x04)
+			info.BIOSVersion = s.StringAt(0x05)
+			info.BIOSReleaseDate = s.StringAt(0x08)
+		case smbiosTypeSystemInformation:
+			info.SystemManufacturer = s.StringAt(0x04)
+			info.SystemProductName = s.StringAt(0x05)
+			info.SystemVersion = s.StringAt(0x06)
+		}
+	}
+	return info
+}
+
+func (i *SMBIOSSystemInfo) String() string {
+	return fmt.Sprintf("SystemManufacturer: \"%s\", SystemProductName: \"%s\", SystemVersion: \"%s\", BIOSVendor: \"%s\", "+
+		"BIOSVersion: \"%s\", BIOSReleaseDate: \"%s\"", EscapeString(i.SystemManufacturer), EscapeString(i.SystemProductName),
+		EscapeString(i.SystemVersion), EscapeString(i.BIOSVendor), EscapeString(i.BIOSVersion), EscapeString(i.BIOSReleaseDate))
+}
+
+// SMBIOSTable returns the entry for the SMBIOS table in this event data if there is one, preferring the SMBIOS 3 table. It returns
+// nil if the event doesn't measure a SMBIOS table.
+func (e *EFIHandoffTablePointers) SMBIOSTable() *EFIConfigurationTable {
+	return findSMBIOSTable(e.Tables)
+}
+
+// SMBIOSTable returns the entry for the SMBIOS table in this event data if there is one, preferring the SMBIOS 3 table. It returns
+// nil if the event doesn't measure a SMBIOS table.
+func (e *EFIHandoffTablePointers2) SMBIOSTable() *EFIConfigurationTable {
+	return findSMBIOSTable(e.Tables)
+}
+
+func findSMBIOSTable(tables []EFIConfigurationTable) *EFIConfigurationTable {
+	var out *EFIConfigurationTable
+	for i := range tables {
+		switch tables[i].VendorGuid {
+		case EFISMBIOS3TableGuid:
+			return &tables[i]
+		case EFISMBIOSTableGuid:
+			out = &tables[i]
+		}
+	}
+	return out
+}
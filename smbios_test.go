@@ -0,0 +1,127 @@
+// Copyright 2019 Canonical Ltd.
+// Licensed under the LGPLv3 with static-linking exception.
+// See LICENCE file for details.
+
+package tcglog
+
+import (
+	"testing"
+)
+
+func makeTestSMBIOSStructure(t uint8, handle uint16, formatted []byte, strs ...string) []byte {
+	out := []byte{t, byte(4 + len(formatted)), byte(handle), byte(handle >> 8)}
+	out = append(out, formatted...)
+	if len(strs) == 0 {
+		return append(out, 0, 0)
+	}
+	for _, s := range strs {
+		out = append(out, []byte(s)...)
+		out = append(out, 0)
+	}
+	return append(out, 0)
+}
+
+func TestDecodeSMBIOSStructureTable(t *testing.T) {
+	var table []byte
+	// BIOS Information: vendor=1, version=2, starting segment, release date=3
+	table = append(table, makeTestSMBIOSStructure(0, 0x0000, []byte{1, 2, 0x00, 0xe0, 3, 0xff}, "ACME", "1.2.3", "01/02/2019")...)
+	// System Information: manufacturer=1, product=2, version=3, serial=4
+	table = append(table, makeTestSMBIOSStructure(1, 0x0001, []byte{1, 2, 3, 4}, "ACME Corp", "Widget", "v1", "12345")...)
+	table = append(table, makeTestSMBIOSStructure(200, 0x0002, []byte{0})...)
+	table = append(table, makeTestSMBIOSStructure(127, 0xfeff, nil)...)
+	table = append(table, 0xff, 0xff)
+
+	structures, err := DecodeSMBIOSStructureTable(table)
+	if err != nil {
+		t.Fatalf("DecodeSMBIOSStructureTable failed: %v", err)
+	}
+	if len(structures) != 4 {
+		t.Fatalf("Unexpected number of structures: %d", len(structures))
+	}
+	for i, expected := range []struct {
+		typeName string
+		handle   uint16
+		nstrs    int
+	}{
+		{"BIOS Information", 0x0000, 3},
+		{"System Information", 0x0001, 4},
+		{"OEM-specific (200)", 0x0002, 0},
+		{"End-of-Table", 0xfeff, 0},
+	} {
+		s := structures[i]
+		if s.TypeName() != expected.typeName {
+			t.Errorf("Unexpected type name for structure %d: %s", i, s.TypeName())
+		}
+		if s.Handle != expected.handle {
+			t.Errorf("Unexpected handle for structure %d: 0x%04x", i, s.Handle)
+		}
+		if len(s.Strings) != expected.nstrs {
+			t.Errorf("Unexpected number of strings for structure %d: %d", i, len(s.Strings))
+		}
+	}
+
+	info := GetSMBIOSSystemInfo(structures)
+	expected := SMBIOSSystemInfo{
+		BIOSVendor:         "ACME",
+		BIOSVersion:        "1.2.3",
+		BIOSReleaseDate:    "01/02/2019",
+		SystemManufacturer: "ACME Corp",
+		SystemProductName:  "Widget",
+		SystemVersion:      "v1"}
+	if *info != expected {
+		t.Errorf("Unexpected system info: %s", info)
+	}
+}
+
+func TestDecodeSMBIOSStructureTableErrors(t *testing.T) {
+	for _, data := range []struct {
+		desc string
+		data []byte
+		err  string
+	}{
+		{
+			desc: "Empty",
+			err:  "no structures",
+		},
+		{
+			desc: "TruncatedHeader",
+			data: []byte{0, 4},
+			err:  "cannot decode structure 0: truncated header",
+		},
+		{
+			desc: "InvalidLength",
+			data: []byte{0, 2, 0, 0, 0, 0},
+			err:  "cannot decode structure 0: invalid length (2)",
+		},
+		{
+			desc: "UnterminatedStrings",
+			data: []byte{0, 4, 0, 0, 'a', 0},
+			err:  "cannot decode structure 0: unterminated string set",
+		},
+	} {
+		t.Run(data.desc, func(t *testing.T) {
+			_, err := DecodeSMBIOSStructureTable(data.data)
+			if err == nil {
+				t.Fatalf("Expected an error")
+			}
+			if err.Error() != data.err {
+				t.Errorf("Unexpected error: %v", err)
+			}
+		})
+	}
+}
+
+func TestEFIHandoffTablePointersSMBIOSTable(t *testing.T) {
+	e := &EFIHandoffTablePointers{Tables: []EFIConfigurationTable{
+		{VendorGuid: EFIACPI20TableGuid, VendorTable: 0x1000},
+		{VendorGuid: EFISMBIOSTableGuid, VendorTable: 0x2000},
+		{VendorGuid: EFISMBIOS3TableGuid, VendorTable: 0x3000}}}
+	if table := e.SMBIOSTable(); table == nil || table.VendorTable != 0x3000 {
+		t.Errorf("Unexpected SMBIOS table: %v", table)
+	}
+
+	e = &EFIHandoffTablePointers{Tables: []EFIConfigurationTable{{VendorGuid: EFIACPI20TableGuid, VendorTable: 0x1000}}}
+	if table := e.SMBIOSTable(); table != nil {
+		t.Errorf("Unexpected SMBIOS table: %v", table)
+	}
+}
@@ -0,0 +1,195 @@
+// Copyright 2019 Canonical Ltd.
+// Licensed under the LGPLv3 with static-linking exception.
+// See LICENCE file for details.
+
+package tcglog
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+
+	"golang.org/x/xerrors"
+)
+
+const spdmDeviceSecuritySignature = "SPDM Device Sec\x00"
+
+// SPDMDeviceType indicates the type of device associated with a SPDM device security event.
+type SPDMDeviceType uint32
+
+const (
+	SPDMDeviceTypeNull SPDMDeviceType = 0 // TCG_DEVICE_SECURITY_EVENT_DATA_DEVICE_TYPE_NULL
+	SPDMDeviceTypePCI  SPDMDeviceType = 1 // TCG_DEVICE_SECURITY_EVENT_DATA_DEVICE_TYPE_PCI
+	SPDMDeviceTypeUSB  SPDMDeviceType = 2 // TCG_DEVICE_SECURITY_EVENT_DATA_DEVICE_TYPE_USB
+)
+
+func (t SPDMDeviceType) String() string {
+	switch t {
+	case SPDMDeviceTypeNull:
+		return "NULL"
+	case SPDMDeviceTypePCI:
+		return "PCI"
+	case SPDMDeviceTypeUSB:
+		return "USB"
+	default:
+		return fmt.Sprintf("%08x", uint32(t))
+	}
+}
+
+// SPDMMeasurementBlock corresponds to the SPDM_MEASUREMENT_BLOCK type, which is a measurement obtained from a device using the
+// DMTF Security Protocol and Data Model (SPDM).
+type SPDMMeasurementBlock struct {
+	Index                    uint8  // The index of this measurement on the device
+	MeasurementSpecification uint8  // A bitmask of the specifications that Measurement conforms to
+	Measurement              []byte // The measurement
+}
+
+// DMTFMeasurement decodes the measurement as a DMTF measurement, returning the value type and value. It returns an error if the
+// measurement doesn't conform to the DMTF specification.
+//
+// https://www.dmtf.org/sites/default/files/standards/documents/DSP0274_1.1.0.pdf
+//  (section 10.11.1 "Measurement block")
+func (b *SPDMMeasurementBlock) DMTFMeasurement() (valueType uint8, value []byte, err error) {
+	if b.MeasurementSpecification&0x01 == 0 {
+		return 0, nil, errors.New("not a DMTF measurement")
+	}
+	if len(b.Measurement) < 3 {
+		return 0, nil, errors.New("measurement too short")
+	}
+	n := int(binary.LittleEndian.Uint16(b.Measurement[1:]))
+	if n > len(b.Measurement)-3 {
+		return 0, nil, errors.New("invalid value size")
+	}
+	return b.Measurement[0], b.Measurement[3 : 3+n], nil
+}
+
+func (b *SPDMMeasurementBlock) String() string {
+	if t, v, err := b.DMTFMeasurement(); err == nil {
+		return fmt.Sprintf("SPDM_MEASUREMENT_BLOCK{ Index: %d, DMTFSpecMeasurementValueType: 0x%02x, DMTFSpecMeasurementValue: %x }",
+			b.Index, t, v)
+	}
+	return fmt.Sprintf("SPDM_MEASUREMENT_BLOCK{ Index: %d, MeasurementSpecification: 0x%02x, Measurement: %x }", b.Index,
+		b.MeasurementSpecification, b.Measurement)
+}
+
+// SPDMDeviceSecurityEventData corresponds to the TCG_DEVICE_SECURITY_EVENT_DATA type and is the event data associated with
+// EV_EFI_SPDM_FIRMWARE_BLOB, EV_EFI_SPDM_FIRMWARE_CONFIG and EV_EFI_SPDM_DEVICE_AUTHORITY events.
+type SPDMDeviceSecurityEventData struct {
+	data             []byte
+	devicePath       []byte
+	Version          uint16
+	SPDMHashAlgo     uint32 // The SPDM hash algorithm used for the measurement
+	DeviceType       SPDMDeviceType
+	MeasurementBlock SPDMMeasurementBlock
+	DevicePath       string // The textual representation of the device path of the measured device
+	DeviceContext    []byte // The device type specific context, such as the PCI identifiers of the device
+}
+
+func (e *SPDMDeviceSecurityEventData) String() string {
+	return fmt.Sprintf("TCG_DEVICE_SECURITY_EVENT_DATA{ Version: %d, SpdmHashAlgo: 0x%08x, DeviceType: %s, SpdmMeasurementBlock: %s, "+
+		"DevicePath: %s }", e.Version, e.SPDMHashAlgo, e.DeviceType, &e.MeasurementBlock, EscapeString(e.DevicePath))
+}
+
+func (e *SPDMDeviceSecurityEventData) Bytes() []byte {
+	return e.data
+}
+
+// EncodeTo encodes this event data in to the form in which it appears in the event log. The device path is copied from the
+// original event data, as DevicePath is only a textual representation of it.
+func (e *SPDMDeviceSecurityEventData) EncodeTo(w io.Writer) error {
+	if len(e.MeasurementBlock.Measurement) > 0xffff {
+		return errors.New("measurement too large")
+	}
+	length := 32 + len(e.MeasurementBlock.Measurement) + 8 + len(e.devicePath)
+	if length > 0xffff {
+		return errors.New("header too large")
+	}
+
+	var b bytes.Buffer
+	b.WriteString(spdmDeviceSecuritySignature)
+	binary.Write(&b, binary.LittleEndian, e.Version)
+	binary.Write(&b, binary.LittleEndian, uint16(length))
+	binary.Write(&b, binary.LittleEndian, e.SPDMHashAlgo)
+	binary.Write(&b, binary.LittleEndian, e.DeviceType)
+	b.WriteByte(e.MeasurementBlock.Index)
+	b.WriteByte(e.MeasurementBlock.MeasurementSpecification)
+	binary.Write(&b, binary.LittleEndian, uint16(len(e.MeasurementBlock.Measurement)))
+	b.Write(e.MeasurementBlock.Measurement)
+	binary.Write(&b, binary.LittleEndian, uint64(len(e.devicePath)))
+	b.Write(e.devicePath)
+	b.Write(e.DeviceContext)
+	_, err := b.WriteTo(w)
+	return err
+}
+
+// https://trustedcomputinggroup.org/wp-content/uploads/TCG_PCClientSpecPlat_TPM_2p0_1p05p_r14_pub.pdf
+//  (section 10.2.7 "TCG_DEVICE_SECURITY_EVENT_DATA Structure")
+func decodeEventDataSPDMDeviceSecurity(data []byte) (*SPDMDeviceSecurityEventData, error) {
+	r := bytes.NewReader(data)
+
+	var signature [16]byte
+	if _, err := io.ReadFull(r, signature[:]); err != nil {
+		return nil, xerrors.Errorf("cannot read signature: %w", err)
+	}
+	if string(signature[:]) != spdmDeviceSecuritySignature {
+		return nil, errors.New("invalid signature")
+	}
+
+	var header struct {
+		Version      uint16
+		Length       uint16
+		SPDMHashAlgo uint32
+		DeviceType   SPDMDeviceType
+	}
+	if err := binary.Read(r, binary.LittleEndian, &header); err != nil {
+		return nil, xerrors.Errorf("cannot read header: %w", err)
+	}
+	if header.Version != 1 {
+		return nil, fmt.Errorf("unsupported version (%d)", header.Version)
+	}
+
+	d := &SPDMDeviceSecurityEventData{
+		data:         data,
+		Version:      header.Version,
+		SPDMHashAlgo: header.SPDMHashAlgo,
+		DeviceType:   header.DeviceType}
+
+	var block struct {
+		Index                    uint8
+		MeasurementSpecification uint8
+		MeasurementSize          uint16
+	}
+	if err := binary.Read(r, binary.LittleEndian, &block); err != nil {
+		return nil, xerrors.Errorf("cannot read measurement block header: %w", err)
+	}
+	d.MeasurementBlock.Index = block.Index
+	d.MeasurementBlock.MeasurementSpecification = block.MeasurementSpecification
+	d.MeasurementBlock.Measurement = make([]byte, block.MeasurementSize)
+	if _, err := io.ReadFull(r, d.MeasurementBlock.Measurement); err != nil {
+		return nil, xerrors.Errorf("cannot read measurement: %w", err)
+	}
+
+	var devicePathLength uint64
+	if err := binary.Read(r, binary.LittleEndian, &devicePathLength); err != nil {
+		return nil, xerrors.Errorf("cannot read device path length: %w", err)
+	}
+	if devicePathLength > uint64(r.Len()) {
+		return nil, errors.New("invalid device path length")
+	}
+	d.devicePath = make([]byte, devicePathLength)
+	if _, err := io.ReadFull(r, d.devicePath); err != nil {
+		return nil, xerrors.Errorf("cannot read device path: %w", err)
+	}
+	path, err := decodeDevicePath(d.devicePath)
+	if err != nil {
+		return nil, xerrors.Errorf("cannot decode device path: %w", err)
+	}
+	d.DevicePath = path
+
+	d.DeviceContext = make([]byte, r.Len())
+	r.Read(d.DeviceContext)
+
+	return d, nil
+}
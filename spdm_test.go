@@ -0,0 +1,124 @@
+// Copyright 2019 Canonical Ltd.
+// Licensed under the LGPLv3 with static-linking exception.
+// See LICENCE file for details.
+
+package tcglog
+
+import (
+	"bytes"
+	"encoding/binary"
+	"testing"
+)
+
+func makeTestSPDMEventData(measurement, devicePath, context []byte) []byte {
+	var b bytes.Buffer
+	b.WriteString(spdmDeviceSecuritySignature)
+	binary.Write(&b, binary.LittleEndian, uint16(1))
+	binary.Write(&b, binary.LittleEndian, uint16(32+len(measurement)+8+len(devicePath)))
+	binary.Write(&b, binary.LittleEndian, uint32(0x2))
+	binary.Write(&b, binary.LittleEndian, SPDMDeviceTypePCI)
+	b.WriteByte(1)
+	b.WriteByte(0x01)
+	binary.Write(&b, binary.LittleEndian, uint16(len(measurement)))
+	b.Write(measurement)
+	binary.Write(&b, binary.LittleEndian, uint64(len(devicePath)))
+	b.Write(devicePath)
+	b.Write(context)
+	return b.Bytes()
+}
+
+func TestDecodeSPDMDeviceSecurityEventData(t *testing.T) {
+	measurement := []byte{0x81, 0x04, 0x00, 0xde, 0xad, 0xbe, 0xef}
+	context := []byte{0x00, 0x00, 0x10, 0x00, 0x86, 0x80, 0x34, 0x12}
+	data := makeTestSPDMEventData(measurement, efiEndEntireDevicePath, context)
+
+	for _, eventType := range []EventType{EventTypeEFISPDMFirmwareBlob, EventTypeEFISPDMFirmwareConfig, EventTypeEFISPDMDeviceAuthority} {
+		t.Run(eventType.String(), func(t *testing.T) {
+			d, ok := DecodeEventData(2, eventType, DigestMap{}, data, nil).(*SPDMDeviceSecurityEventData)
+			if !ok {
+				t.Fatalf("Unexpected event data type")
+			}
+			if d.Version != 1 || d.SPDMHashAlgo != 0x2 || d.DeviceType != SPDMDeviceTypePCI {
+				t.Errorf("Unexpected header: %s", d)
+			}
+			if !bytes.Equal(d.DeviceContext, context) {
+				t.Errorf("Unexpected device context: %x", d.DeviceContext)
+			}
+			valueType, value, err := d.MeasurementBlock.DMTFMeasurement()
+			if err != nil {
+				t.Fatalf("DMTFMeasurement failed: %v", err)
+			}
+			if valueType != 0x81 || !bytes.Equal(value, []byte{0xde, 0xad, 0xbe, 0xef}) {
+				t.Errorf("Unexpected measurement: %x %x", valueType, value)
+			}
+
+			var buf bytes.Buffer
+			if err := d.EncodeTo(&buf); err != nil {
+				t.Fatalf("EncodeTo failed: %v", err)
+			}
+			if !bytes.Equal(buf.Bytes(), data) {
+				t.Errorf("Unexpected encoding: %x", buf.Bytes())
+			}
+		})
+	}
+}
+
+func TestDecodeSPDMDeviceSecurityEventDataErrors(t *testing.T) {
+	valid := makeTestSPDMEventData([]byte{0x81, 0x00, 0x00}, efiEndEntireDevicePath, nil)
+
+	badVersion := append([]byte{}, valid...)
+	badVersion[16] = 2
+
+	for _, data := range []struct {
+		desc string
+		data []byte
+		err  string
+	}{
+		{
+			desc: "InvalidSignature",
+			data: append([]byte("SPDM Device Sec2"), valid[16:]...),
+			err:  "cannot decode EV_EFI_SPDM_FIRMWARE_BLOB event data: invalid signature",
+		},
+		{
+			desc: "UnsupportedVersion",
+			data: badVersion,
+			err:  "cannot decode EV_EFI_SPDM_FIRMWARE_BLOB event data: unsupported version (2)",
+		},
+		{
+			desc: "TruncatedMeasurement",
+			data: valid[:34],
+			err:  "cannot decode EV_EFI_SPDM_FIRMWARE_BLOB event data: cannot read measurement: unexpected EOF",
+		},
+		{
+			desc: "InvalidDevicePathLength",
+			data: valid[:len(valid)-1],
+			err:  "cannot decode EV_EFI_SPDM_FIRMWARE_BLOB event data: invalid device path length",
+		},
+	} {
+		t.Run(data.desc, func(t *testing.T) {
+			err, isErr := DecodeEventData(2, EventTypeEFISPDMFirmwareBlob, DigestMap{}, data.data, nil).(error)
+			if !isErr {
+				t.Fatalf("Expected an error")
+			}
+			if err.Error() != data.err {
+				t.Errorf("Unexpected error: %v", err)
+			}
+		})
+	}
+}
+
+func TestDecodeSPDMDevicePolicy(t *testing.T) {
+	var data bytes.Buffer
+	data.Write(EFIImageSecurityDatabaseGuid[:])
+	binary.Write(&data, binary.LittleEndian, uint64(5))
+	binary.Write(&data, binary.LittleEndian, uint64(0))
+	binary.Write(&data, binary.LittleEndian, []uint16{'d', 'e', 'v', 'd', 'b'})
+
+	d, ok := DecodeEventData(7, EventTypeEFISPDMDevicePolicy, DigestMap{}, data.Bytes(), nil).(*EFIVariableData)
+	if !ok {
+		t.Fatalf("Unexpected event data type")
+	}
+	if d.UnicodeName != "devdb" {
+		t.Errorf("Unexpected variable name: %s", d.UnicodeName)
+	}
+}
@@ -0,0 +1,241 @@
+// Copyright 2019 Canonical Ltd.
+// Licensed under the LGPLv3 with static-linking exception.
+// See LICENCE file for details.
+
+package tcglog
+
+import (
+	"bytes"
+	"debug/pe"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"strings"
+
+	"golang.org/x/xerrors"
+)
+
+// systemdUKIMeasuredSections are the sections of a unified kernel image that systemd-stub measures to PCR 11, in the order in
+// which it measures them. The .pcrsig section isn't measured because it contains signatures for the resulting PCR values.
+//
+// https://github.com/systemd/systemd/blob/main/src/fundamental/uki.h
+//  (unified_sections)
+var systemdUKIMeasuredSections = []string{
+	".linux",
+	".osrel",
+	".cmdline",
+	".initrd",
+	".ucode",
+	".splash",
+	".dtb",
+	".uname",
+	".sbat",
+	".pcrpkey",
+	".profile",
+	".dtbauto",
+	".hwids",
+	".efifw",
+}
+
+// SystemdMeasureDefaultPhases are the boot phases for which systemd-measure calculates PCR 11 values by default. Each phase is a
+// colon separated list of the words that systemd-pcrphase measures to PCR 11 after systemd-stub, up to and including that phase.
+var SystemdMeasureDefaultPhases = []string{
+	"enter-initrd",
+	"enter-initrd:leave-initrd",
+	"enter-initrd:leave-initrd:sysinit",
+	"enter-initrd:leave-initrd:sysinit:ready",
+}
+
+// SystemdUKIImageSection is a section of a unified kernel image that systemd-stub measures to PCR 11.
+type SystemdUKIImageSection struct {
+	Name string
+	Data []byte
+}
+
+func readSystemdUKIImageSection(s *pe.Section) (*SystemdUKIImageSection, error) {
+	// systemd-stub measures the section as it is loaded in memory, which is VirtualSize bytes long and padded with zeroes if this
+	// is larger than the section's data in the file.
+	size := int64(s.VirtualSize)
+	if size == 0 {
+		size = int64(s.Size)
+	}
+	data, err := ioutil.ReadAll(io.NewSectionReader(s, 0, size))
+	if err != nil {
+		return nil, xerrors.Errorf("cannot read section %s: %w", s.Name, err)
+	}
+	return &SystemdUKIImageSection{Name: s.Name, Data: append(data, make([]byte, size-int64(len(data)))...)}, nil
+}
+
+// ReadSystemdUKISections returns the sections of the supplied unified kernel image that systemd-stub measures to PCR 11, in the
+// order in which it measures them.
+//
+// An image with .profile sections is a multi-profile image. The sections before the first .profile section are shared by every
+// profile, and the sections of each profile follow its .profile section. The sections of the specified profile (numbered from 0)
+// are returned in this case, with those of the profile taking precedence over the shared ones. The profile is ignored for an
+// image without .profile sections.
+//
+// An error is returned for an image with .dtbauto sections, because systemd-stub selects one of these according to the
+// hardware that it runs on.
+func ReadSystemdUKISections(r io.ReaderAt, profile int) ([]*SystemdUKIImageSection, error) {
+	f, err := pe.NewFile(r)
+	if err != nil {
+		return nil, xerrors.Errorf("cannot decode PE image: %w", err)
+	}
+
+	base := make(map[string]*pe.Section)
+	var profiles []map[string]*pe.Section
+	for _, s := range f.Sections {
+		if s.Name == ".dtbauto" {
+			return nil, fmt.Errorf("cannot predict which .dtbauto section is measured")
+		}
+		sections := base
+		if s.Name == ".profile" {
+			profiles = append(profiles, make(map[string]*pe.Section))
+		}
+		if len(profiles) > 0 {
+			sections = profiles[len(profiles)-1]
+		}
+		if _, exists := sections[s.Name]; !exists {
+			// systemd-stub uses the first section with each name.
+			sections[s.Name] = s
+		}
+	}
+
+	if len(profiles) > 0 {
+		if profile < 0 || profile >= len(profiles) {
+			return nil, fmt.Errorf("image has no profile %d", profile)
+		}
+		for name, s := range profiles[profile] {
+			base[name] = s
+		}
+	}
+
+	var out []*SystemdUKIImageSection
+	for _, name := range systemdUKIMeasuredSections {
+		s, ok := base[name]
+		if !ok {
+			continue
+		}
+		section, err := readSystemdUKIImageSection(s)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, section)
+	}
+	if len(out) == 0 || out[0].Name != ".linux" {
+		return nil, fmt.Errorf("image has no .linux section")
+	}
+	return out, nil
+}
+
+// PredictSystemdUKIMeasurements returns the events that systemd-stub measures to PCR 11 when booting the supplied sections of a
+// unified kernel image (see ReadSystemdUKISections), with digests for the specified algorithms. systemd-stub measures the
+// name of each section (including its NULL terminator) followed by its contents, both with the section name as the event data.
+//
+// https://github.com/systemd/systemd/blob/main/src/boot/stub.c
+func PredictSystemdUKIMeasurements(sections []*SystemdUKIImageSection, algorithms AlgorithmIdList) ([]*Event, error) {
+	for _, alg := range algorithms {
+		if !alg.supported() {
+			return nil, fmt.Errorf("unsupported algorithm %v", alg)
+		}
+	}
+
+	var events []*Event
+	for _, s := range sections {
+		var data bytes.Buffer
+		binary.Write(&data, binary.LittleEndian, append(convertStringToUtf16(s.Name), 0))
+
+		for _, measured := range [][]byte{[]byte(s.Name + "\x00"), s.Data} {
+			digests := make(DigestMap)
+			for _, alg := range algorithms {
+				digests[alg] = alg.hash(measured)
+			}
+			events = append(events, &Event{
+				Index:     uint(len(events)),
+				PCRIndex:  SystemdKernelBootPCR,
+				EventType: EventTypeIPL,
+				Digests:   digests,
+				Data: &SystemdUKIEventData{
+					data:          data.Bytes(),
+					Type:          SystemdUKISection,
+					Str:           s.Name,
+					IsSectionName: len(events)%2 == 0}})
+		}
+	}
+	return events, nil
+}
+
+// SystemdMeasurePCR is a value of PCR 11 calculated by ComputeSystemdMeasurePCRs.
+type SystemdMeasurePCR struct {
+	Algorithm AlgorithmId
+	Phase     string
+	Value     Digest
+}
+
+// ComputeSystemdMeasurePCRs computes the values of PCR 11 for the supplied sections of a unified kernel image (see
+// ReadSystemdUKISections) for each of the specified algorithms and boot phases, in the same way as "systemd-measure calculate".
+// A phase is a colon separated list of the words that systemd-pcrphase measures to PCR 11 after systemd-stub has measured the
+// image (see SystemdMeasureDefaultPhases), and an empty phase is the value before any of these are measured. If no phases are
+// supplied, the default phases are used.
+//
+// https://github.com/systemd/systemd/blob/main/src/measure/measure.c
+func ComputeSystemdMeasurePCRs(sections []*SystemdUKIImageSection, algorithms AlgorithmIdList, phases []string) ([]*SystemdMeasurePCR, error) {
+	if len(phases) == 0 {
+		phases = SystemdMeasureDefaultPhases
+	}
+
+	var out []*SystemdMeasurePCR
+	for _, alg := range algorithms {
+		if !alg.supported() {
+			return nil, fmt.Errorf("unsupported algorithm %v", alg)
+		}
+
+		extend := func(value Digest, data []byte) Digest {
+			h := alg.GetHash().New()
+			h.Write(value)
+			h.Write(alg.hash(data))
+			return h.Sum(nil)
+		}
+
+		value := make(Digest, alg.Size())
+		for _, s := range sections {
+			value = extend(value, []byte(s.Name+"\x00"))
+			value = extend(value, s.Data)
+		}
+
+		for _, phase := range phases {
+			v := value
+			for _, word := range strings.Split(phase, ":") {
+				if word != "" {
+					v = extend(v, []byte(word))
+				}
+			}
+			out = append(out, &SystemdMeasurePCR{Algorithm: alg, Phase: phase, Value: v})
+		}
+	}
+	return out, nil
+}
+
+type systemdMeasureJSONEntry struct {
+	Phase string `json:"phase,omitempty"`
+	PCR   int    `json:"pcr"`
+	Hash  string `json:"hash"`
+}
+
+// WriteSystemdMeasureJSON writes the supplied PCR values to w in the JSON format produced by "systemd-measure calculate --json",
+// which is an object that maps each lower case algorithm name (eg, "sha256") to an array of objects with "phase", "pcr" and
+// "hash" fields.
+func WriteSystemdMeasureJSON(w io.Writer, pcrs []*SystemdMeasurePCR) error {
+	banks := make(map[string][]*systemdMeasureJSONEntry)
+	for _, p := range pcrs {
+		bank := strings.ToLower(strings.Replace(p.Algorithm.String(), "-", "", -1))
+		banks[bank] = append(banks[bank], &systemdMeasureJSONEntry{
+			Phase: p.Phase,
+			PCR:   int(SystemdKernelBootPCR),
+			Hash:  hex.EncodeToString(p.Value)})
+	}
+	return json.NewEncoder(w).Encode(banks)
+}
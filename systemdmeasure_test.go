@@ -0,0 +1,228 @@
+// Copyright 2019 Canonical Ltd.
+// Licensed under the LGPLv3 with static-linking exception.
+// See LICENCE file for details.
+
+package tcglog
+
+import (
+	"bytes"
+	"debug/pe"
+	"encoding/binary"
+	"testing"
+)
+
+// makeTestUKI creates a minimal PE32+ image with the supplied sections, which are specified as pairs of names and contents.
+func makeTestUKI(sections ...string) []byte {
+	const sizeOfHeaders = 0x400
+
+	var b bytes.Buffer
+	dos := make([]byte, 0x40)
+	copy(dos, "MZ")
+	binary.LittleEndian.PutUint32(dos[0x3c:], 0x40)
+	b.Write(dos)
+	b.WriteString("PE\x00\x00")
+
+	binary.Write(&b, binary.LittleEndian, pe.FileHeader{
+		Machine:              pe.IMAGE_FILE_MACHINE_AMD64,
+		NumberOfSections:     uint16(len(sections) / 2),
+		SizeOfOptionalHeader: uint16(binary.Size(pe.OptionalHeader64{})),
+		Characteristics:      0x22})
+	binary.Write(&b, binary.LittleEndian, pe.OptionalHeader64{
+		Magic:               0x20b,
+		SizeOfHeaders:       sizeOfHeaders,
+		SectionAlignment:    0x1000,
+		FileAlignment:       0x200,
+		SizeOfImage:         uint32(0x1000 * (len(sections)/2 + 1)),
+		NumberOfRvaAndSizes: 16})
+
+	var data bytes.Buffer
+	for i := 0; i < len(sections); i += 2 {
+		var name [8]uint8
+		copy(name[:], sections[i])
+		size := (len(sections[i+1]) + 0x1ff) &^ 0x1ff
+		binary.Write(&b, binary.LittleEndian, pe.SectionHeader32{
+			Name:             name,
+			VirtualSize:      uint32(len(sections[i+1])),
+			VirtualAddress:   uint32(0x1000 * (i/2 + 1)),
+			SizeOfRawData:    uint32(size),
+			PointerToRawData: uint32(sizeOfHeaders + data.Len()),
+			Characteristics:  0x40000040})
+		data.WriteString(sections[i+1])
+		data.Write(make([]byte, size-len(sections[i+1])))
+	}
+
+	b.Write(make([]byte, sizeOfHeaders-b.Len()))
+	b.Write(data.Bytes())
+	return b.Bytes()
+}
+
+func TestReadSystemdUKISections(t *testing.T) {
+	for _, data := range []struct {
+		desc     string
+		image    []byte
+		profile  int
+		expected []string
+	}{
+		{
+			desc: "Order",
+			image: makeTestUKI(".sbat", "sbat,1\n", ".cmdline", "quiet", ".pcrsig", "{}", ".linux", "kernel", ".text", "stub",
+				".osrel", "ID=ubuntu\n"),
+			expected: []string{".linux", "kernel", ".osrel", "ID=ubuntu\n", ".cmdline", "quiet", ".sbat", "sbat,1\n"},
+		},
+		{
+			desc: "Profile",
+			image: makeTestUKI(".linux", "kernel", ".cmdline", "quiet", ".profile", "ID=default", ".profile", "ID=debug",
+				".cmdline", "debug"),
+			profile:  1,
+			expected: []string{".linux", "kernel", ".cmdline", "debug", ".profile", "ID=debug"},
+		},
+		{
+			desc: "DefaultProfile",
+			image: makeTestUKI(".linux", "kernel", ".cmdline", "quiet", ".profile", "ID=default", ".profile", "ID=debug",
+				".cmdline", "debug"),
+			expected: []string{".linux", "kernel", ".cmdline", "quiet", ".profile", "ID=default"},
+		},
+	} {
+		t.Run(data.desc, func(t *testing.T) {
+			sections, err := ReadSystemdUKISections(bytes.NewReader(data.image), data.profile)
+			if err != nil {
+				t.Fatalf("ReadSystemdUKISections failed: %v", err)
+			}
+			var s []string
+			for _, section := range sections {
+				s = append(s, section.Name, string(section.Data))
+			}
+			if len(s) != len(data.expected) {
+				t.Fatalf("Unexpected sections: %q", s)
+			}
+			for i := range s {
+				if s[i] != data.expected[i] {
+					t.Errorf("Unexpected sections: %q", s)
+					break
+				}
+			}
+		})
+	}
+}
+
+func TestReadSystemdUKISectionsErrors(t *testing.T) {
+	for _, data := range []struct {
+		desc    string
+		image   []byte
+		profile int
+		err     string
+	}{
+		{
+			desc:  "NoLinux",
+			image: makeTestUKI(".text", "stub", ".cmdline", "quiet"),
+			err:   "image has no .linux section",
+		},
+		{
+			desc:    "NoProfile",
+			image:   makeTestUKI(".linux", "kernel", ".profile", "ID=default"),
+			profile: 1,
+			err:     "image has no profile 1",
+		},
+		{
+			desc:  "DTBAuto",
+			image: makeTestUKI(".linux", "kernel", ".dtbauto", "dtb1", ".dtbauto", "dtb2"),
+			err:   "cannot predict which .dtbauto section is measured",
+		},
+	} {
+		t.Run(data.desc, func(t *testing.T) {
+			if _, err := ReadSystemdUKISections(bytes.NewReader(data.image), data.profile); err == nil || err.Error() != data.err {
+				t.Errorf("Unexpected error: %v", err)
+			}
+		})
+	}
+}
+
+func TestPredictSystemdUKIMeasurements(t *testing.T) {
+	sections := []*SystemdUKIImageSection{{Name: ".linux", Data: []byte("kernel")}, {Name: ".cmdline", Data: []byte("quiet")}}
+	events, err := PredictSystemdUKIMeasurements(sections, AlgorithmIdList{AlgorithmSha256})
+	if err != nil {
+		t.Fatalf("PredictSystemdUKIMeasurements failed: %v", err)
+	}
+	if len(events) != 4 {
+		t.Fatalf("Unexpected number of events: %d", len(events))
+	}
+
+	for i, e := range events {
+		d, ok := DecodeEventData(11, EventTypeIPL, e.Digests, e.Data.Bytes(), &LogOptions{EnableSystemdUKI: true}).(*SystemdUKIEventData)
+		if !ok {
+			t.Fatalf("Unexpected event data type for event %d", i)
+		}
+		if d.String() != e.Data.String() {
+			t.Errorf("Unexpected event data for event %d: %s (decoded as %s)", i, e.Data, d)
+		}
+		if e.Index != uint(i) || e.PCRIndex != 11 || e.EventType != EventTypeIPL {
+			t.Errorf("Unexpected event %d", i)
+		}
+	}
+	if events[0].Data.String() != "systemd{ section_name: .linux }" || events[1].Data.String() != "systemd{ section: .linux }" {
+		t.Errorf("Unexpected event data: %s, %s", events[0].Data, events[1].Data)
+	}
+	if !bytes.Equal(events[3].Digests[AlgorithmSha256], AlgorithmSha256.hash([]byte("quiet"))) {
+		t.Errorf("Unexpected digest")
+	}
+
+	log := NewLog(events)
+	values := log.ReplayAllPCRs()
+	pcrs, err := ComputeSystemdMeasurePCRs(sections, AlgorithmIdList{AlgorithmSha256}, []string{""})
+	if err != nil {
+		t.Fatalf("ComputeSystemdMeasurePCRs failed: %v", err)
+	}
+	if !bytes.Equal(values[11][AlgorithmSha256], pcrs[0].Value) {
+		t.Errorf("Replayed value doesn't match the computed value")
+	}
+}
+
+func TestComputeSystemdMeasurePCRs(t *testing.T) {
+	image := makeTestUKI(".text", "stub", ".linux", "kernel image", ".osrel", "ID=ubuntu\n", ".cmdline", "root=/dev/sda1 quiet")
+	sections, err := ReadSystemdUKISections(bytes.NewReader(image), 0)
+	if err != nil {
+		t.Fatalf("ReadSystemdUKISections failed: %v", err)
+	}
+
+	// The expected output was produced by "systemd-measure calculate --linux=k --osrel=o --cmdline=c --json=short", with the
+	// options specified for each test.
+	for _, data := range []struct {
+		desc     string
+		algs     AlgorithmIdList
+		phases   []string
+		expected string
+	}{
+		{
+			desc:     "Phases",
+			algs:     AlgorithmIdList{AlgorithmSha256},
+			phases:   []string{"", "enter-initrd"},
+			expected: `{"sha256":[{"pcr":11,"hash":"d9753058575b8e275dbb1a29f2ff773f52d4d74b146424770cf8506b8f38cb2d"},{"phase":"enter-initrd","pcr":11,"hash":"d8b457afc47a79b0b839de57782bcc77ab40ec52d3748ac7dc23e84b840bda8a"}]}`,
+		},
+		{
+			desc: "DefaultPhases",
+			algs: AlgorithmIdList{AlgorithmSha256, AlgorithmSha1},
+			expected: `{"sha1":[{"phase":"enter-initrd","pcr":11,"hash":"65749d4d975dccf5fdf8dda94dd255ac15f707fb"},` +
+				`{"phase":"enter-initrd:leave-initrd","pcr":11,"hash":"0ef78936f88359d41127f50bfb2e940923b20c46"},` +
+				`{"phase":"enter-initrd:leave-initrd:sysinit","pcr":11,"hash":"f35ab69a238ed139921af60fff259123a1471804"},` +
+				`{"phase":"enter-initrd:leave-initrd:sysinit:ready","pcr":11,"hash":"41823b82e612d29ba3bda6d81192600d3f59a95d"}],` +
+				`"sha256":[{"phase":"enter-initrd","pcr":11,"hash":"d8b457afc47a79b0b839de57782bcc77ab40ec52d3748ac7dc23e84b840bda8a"},` +
+				`{"phase":"enter-initrd:leave-initrd","pcr":11,"hash":"dfcaee18b4ff7df57f85fcbe6ebc9d05d356a3e9d3ffd79d77570d6069866aeb"},` +
+				`{"phase":"enter-initrd:leave-initrd:sysinit","pcr":11,"hash":"332b25682343e973ffcac565f73bfbb2c6b4e67d7a42c3b7926bf1d717033046"},` +
+				`{"phase":"enter-initrd:leave-initrd:sysinit:ready","pcr":11,"hash":"b5a979222d62d8c906888639891fa9df59bcc27e1311376379ce189da5c2d09c"}]}`,
+		},
+	} {
+		t.Run(data.desc, func(t *testing.T) {
+			pcrs, err := ComputeSystemdMeasurePCRs(sections, data.algs, data.phases)
+			if err != nil {
+				t.Fatalf("ComputeSystemdMeasurePCRs failed: %v", err)
+			}
+			var b bytes.Buffer
+			if err := WriteSystemdMeasureJSON(&b, pcrs); err != nil {
+				t.Fatalf("WriteSystemdMeasureJSON failed: %v", err)
+			}
+			if b.String() != data.expected+"\n" {
+				t.Errorf("Unexpected output: %s", b.String())
+			}
+		})
+	}
+}
@@ -0,0 +1,135 @@
+// Copyright 2019 Canonical Ltd.
+// Licensed under the LGPLv3 with static-linking exception.
+// See LICENCE file for details.
+
+package tcglog
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"strings"
+)
+
+const (
+	SystemdKernelBootPCR   PCRIndex = 11 // The PCR that systemd-stub measures the sections of a unified kernel image to
+	SystemdKernelConfigPCR PCRIndex = 12 // The PCR that systemd-stub measures the kernel command line, credentials and confexts to
+	SystemdSysextsPCR      PCRIndex = 13 // The PCR that systemd-stub measures system extensions to
+)
+
+// SystemdUKIEventType indicates the type of payload measured by systemd-stub or systemd-boot for a unified kernel image.
+type SystemdUKIEventType int
+
+const (
+	// SystemdUKISection indicates that the event is a measurement of a section of a unified kernel image, or of its name.
+	SystemdUKISection SystemdUKIEventType = iota
+
+	// SystemdUKIKernelCmdline indicates that the event is a measurement of the kernel command line.
+	SystemdUKIKernelCmdline
+
+	// SystemdUKICredential indicates that the event is a measurement of a credential file.
+	SystemdUKICredential
+
+	// SystemdUKIConfext indicates that the event is a measurement of a configuration extension image.
+	SystemdUKIConfext
+
+	// SystemdUKISysext indicates that the event is a measurement of a system extension image.
+	SystemdUKISysext
+)
+
+func (t SystemdUKIEventType) String() string {
+	switch t {
+	case SystemdUKISection:
+		return "section"
+	case SystemdUKIKernelCmdline:
+		return "kernel_cmdline"
+	case SystemdUKICredential:
+		return "credential"
+	case SystemdUKIConfext:
+		return "confext"
+	case SystemdUKISysext:
+		return "sysext"
+	default:
+		return fmt.Sprintf("SystemdUKIEventType(%d)", int(t))
+	}
+}
+
+// SystemdUKIEventData represents the data associated with an event measured by systemd-stub or systemd-boot when booting a
+// unified kernel image. The event data is a UTF-16 description of the measured payload, and the digest is of the payload itself.
+type SystemdUKIEventData struct {
+	data []byte
+	Type SystemdUKIEventType
+
+	// Str is the section name for SystemdUKISection, the command line for SystemdUKIKernelCmdline and the file name for the
+	// other types.
+	Str string
+
+	// IsSectionName indicates that a SystemdUKISection event is the measurement of the section name rather than the section
+	// contents. systemd-stub measures both with the same event data, so this is determined from the event digests.
+	IsSectionName bool
+}
+
+func (e *SystemdUKIEventData) String() string {
+	if e.IsSectionName {
+		return fmt.Sprintf("systemd{ section_name: %s }", e.Str)
+	}
+	return fmt.Sprintf("systemd{ %s: %s }", e.Type, e.Str)
+}
+
+func (e *SystemdUKIEventData) Bytes() []byte {
+	return e.data
+}
+
+// EncodeTo encodes this event data in to the form in which it appears in the event log, which is the description as a UTF-16
+// string in little-endian form with a NULL terminator.
+func (e *SystemdUKIEventData) EncodeTo(w io.Writer) error {
+	return binary.Write(w, binary.LittleEndian, append(convertStringToUtf16(e.Str), 0))
+}
+
+func isSystemdUKISectionNameDigest(name string, digests DigestMap) bool {
+	if len(digests) == 0 {
+		return false
+	}
+	for alg, digest := range digests {
+		if !alg.supported() || !bytes.Equal(alg.hash([]byte(name+"\x00")), digest) {
+			return false
+		}
+	}
+	return true
+}
+
+// https://github.com/systemd/systemd/blob/main/src/boot/efi/stub.c
+func decodeEventDataSystemdUKI(pcrIndex PCRIndex, eventType EventType, digests DigestMap, data []byte) EventData {
+	if eventType != EventTypeIPL {
+		return nil
+	}
+	str, isUTF16, ok := decodePrintableString(data)
+	if !ok || !isUTF16 {
+		return nil
+	}
+
+	d := &SystemdUKIEventData{data: data, Str: str}
+	switch pcrIndex {
+	case SystemdKernelBootPCR:
+		if !strings.HasPrefix(str, ".") {
+			return nil
+		}
+		d.Type = SystemdUKISection
+		d.IsSectionName = isSystemdUKISectionNameDigest(str, digests)
+	case SystemdKernelConfigPCR:
+		switch {
+		case strings.HasSuffix(str, ".cred"):
+			d.Type = SystemdUKICredential
+		case strings.HasSuffix(str, ".raw"):
+			d.Type = SystemdUKIConfext
+		default:
+			d.Type = SystemdUKIKernelCmdline
+		}
+	case SystemdSysextsPCR:
+		d.Type = SystemdUKISysext
+	default:
+		return nil
+	}
+	return d
+}
@@ -0,0 +1,131 @@
+// Copyright 2019 Canonical Ltd.
+// Licensed under the LGPLv3 with static-linking exception.
+// See LICENCE file for details.
+
+package tcglog
+
+import (
+	"bytes"
+	"encoding/binary"
+	"testing"
+)
+
+func makeTestUTF16String(str string) []byte {
+	var b bytes.Buffer
+	binary.Write(&b, binary.LittleEndian, append(convertStringToUtf16(str), 0))
+	return b.Bytes()
+}
+
+func TestDecodeEventDataSystemdUKI(t *testing.T) {
+	for _, data := range []struct {
+		desc          string
+		pcr           PCRIndex
+		data          []byte
+		digests       DigestMap
+		typ           SystemdUKIEventType
+		str           string
+		isSectionName bool
+		s             string
+	}{
+		{
+			desc:          "SectionName",
+			pcr:           11,
+			data:          makeTestUTF16String(".linux"),
+			digests:       DigestMap{AlgorithmSha256: AlgorithmSha256.hash([]byte(".linux\x00"))},
+			typ:           SystemdUKISection,
+			str:           ".linux",
+			isSectionName: true,
+			s:             "systemd{ section_name: .linux }",
+		},
+		{
+			desc:    "SectionContents",
+			pcr:     11,
+			data:    makeTestUTF16String(".linux"),
+			digests: DigestMap{AlgorithmSha256: AlgorithmSha256.hash([]byte("kernel image"))},
+			typ:     SystemdUKISection,
+			str:     ".linux",
+			s:       "systemd{ section: .linux }",
+		},
+		{
+			desc: "KernelCmdline",
+			pcr:  12,
+			data: makeTestUTF16String("root=/dev/sda1 quiet"),
+			typ:  SystemdUKIKernelCmdline,
+			str:  "root=/dev/sda1 quiet",
+			s:    "systemd{ kernel_cmdline: root=/dev/sda1 quiet }",
+		},
+		{
+			desc: "Credential",
+			pcr:  12,
+			data: makeTestUTF16String("foo.cred"),
+			typ:  SystemdUKICredential,
+			str:  "foo.cred",
+			s:    "systemd{ credential: foo.cred }",
+		},
+		{
+			desc: "Confext",
+			pcr:  12,
+			data: makeTestUTF16String("foo.raw"),
+			typ:  SystemdUKIConfext,
+			str:  "foo.raw",
+			s:    "systemd{ confext: foo.raw }",
+		},
+		{
+			desc: "Sysext",
+			pcr:  13,
+			data: makeTestUTF16String("bar.raw"),
+			typ:  SystemdUKISysext,
+			str:  "bar.raw",
+			s:    "systemd{ sysext: bar.raw }",
+		},
+	} {
+		t.Run(data.desc, func(t *testing.T) {
+			d, ok := DecodeEventData(data.pcr, EventTypeIPL, data.digests, data.data, &LogOptions{EnableSystemdUKI: true}).(*SystemdUKIEventData)
+			if !ok {
+				t.Fatalf("Unexpected event data type")
+			}
+			if d.Type != data.typ {
+				t.Errorf("Unexpected type: %v", d.Type)
+			}
+			if d.Str != data.str {
+				t.Errorf("Unexpected string: %s", d.Str)
+			}
+			if d.IsSectionName != data.isSectionName {
+				t.Errorf("Unexpected IsSectionName")
+			}
+			if d.String() != data.s {
+				t.Errorf("Unexpected String(): %s", d)
+			}
+
+			var b bytes.Buffer
+			if err := d.EncodeTo(&b); err != nil {
+				t.Fatalf("EncodeTo failed: %v", err)
+			}
+			if !bytes.Equal(b.Bytes(), data.data) {
+				t.Errorf("Unexpected encoding: %x", b.Bytes())
+			}
+		})
+	}
+
+	t.Run("Encode", func(t *testing.T) {
+		var b bytes.Buffer
+		if err := (&SystemdUKIEventData{Type: SystemdUKISection, Str: ".cmdline"}).EncodeTo(&b); err != nil {
+			t.Fatalf("EncodeTo failed: %v", err)
+		}
+		if !bytes.Equal(b.Bytes(), makeTestUTF16String(".cmdline")) {
+			t.Errorf("Unexpected encoding: %x", b.Bytes())
+		}
+	})
+
+	t.Run("Disabled", func(t *testing.T) {
+		if _, ok := DecodeEventData(11, EventTypeIPL, DigestMap{}, makeTestUTF16String(".linux"), nil).(*SystemdUKIEventData); ok {
+			t.Errorf("Unexpected event data type")
+		}
+	})
+
+	t.Run("NotSection", func(t *testing.T) {
+		if _, ok := DecodeEventData(11, EventTypeIPL, DigestMap{}, makeTestUTF16String("linux"), &LogOptions{EnableSystemdUKI: true}).(*SystemdUKIEventData); ok {
+			t.Errorf("Unexpected event data type")
+		}
+	})
+}
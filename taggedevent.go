@@ -0,0 +1,125 @@
+// Copyright 2019 Canonical Ltd.
+// Licensed under the LGPLv3 with static-linking exception.
+// See LICENCE file for details.
+
+package tcglog
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"sync"
+
+	"golang.org/x/xerrors"
+)
+
+// TaggedEventID is the identifier of a TCG_PCClientTaggedEvent, which indicates the format of the tagged event data.
+type TaggedEventID uint32
+
+func (id TaggedEventID) String() string {
+	taggedEventTypesMu.RLock()
+	t, ok := taggedEventTypes[id]
+	taggedEventTypesMu.RUnlock()
+	if ok && t.name != "" {
+		return t.name
+	}
+	return fmt.Sprintf("%08x", uint32(id))
+}
+
+// TaggedEventDecoder is a function that decodes the data of a tagged event with a specific ID.
+type TaggedEventDecoder func(data []byte) (EventData, error)
+
+type taggedEventType struct {
+	name    string
+	decoder TaggedEventDecoder
+}
+
+var (
+	taggedEventTypesMu sync.RWMutex
+	taggedEventTypes   = make(map[TaggedEventID]taggedEventType)
+)
+
+// RegisterTaggedEventType registers a name and an optional decoder for the data of tagged events with the specified ID. Tagged
+// events with an ID that isn't registered are still decoded, but their data is left in its raw form. Registering a type replaces
+// any type previously registered for the same ID, and registering an empty name and a nil decoder removes it.
+//
+// This is safe to call from multiple goroutines, but types should generally be registered before any logs are parsed.
+func RegisterTaggedEventType(id TaggedEventID, name string, fn TaggedEventDecoder) {
+	taggedEventTypesMu.Lock()
+	defer taggedEventTypesMu.Unlock()
+
+	if name == "" && fn == nil {
+		delete(taggedEventTypes, id)
+		return
+	}
+	taggedEventTypes[id] = taggedEventType{name: name, decoder: fn}
+}
+
+// TaggedEventData corresponds to the TCG_PCClientTaggedEvent type and is the event data associated with EV_EVENT_TAG events.
+type TaggedEventData struct {
+	data    []byte
+	ID      TaggedEventID // The identifier of this tagged event
+	Data    []byte        // The raw tagged event data
+	Payload EventData     // The decoded tagged event data, or nil if there is no decoder registered for ID
+}
+
+func (e *TaggedEventData) String() string {
+	if e.Payload != nil {
+		return fmt.Sprintf("TCG_PCClientTaggedEvent{ taggedEventID: %s, taggedEventData: %s }", e.ID, e.Payload)
+	}
+	return fmt.Sprintf("TCG_PCClientTaggedEvent{ taggedEventID: %s, taggedEventData: %x }", e.ID, e.Data)
+}
+
+func (e *TaggedEventData) Bytes() []byte {
+	return e.data
+}
+
+// EncodeTo encodes this event data in to the form in which it appears in the event log. The tagged event data is encoded from
+// Data.
+func (e *TaggedEventData) EncodeTo(w io.Writer) error {
+	if int64(len(e.Data)) > math.MaxUint32 {
+		return errors.New("data too large")
+	}
+	var b bytes.Buffer
+	binary.Write(&b, binary.LittleEndian, e.ID)
+	binary.Write(&b, binary.LittleEndian, uint32(len(e.Data)))
+	b.Write(e.Data)
+	_, err := b.WriteTo(w)
+	return err
+}
+
+// https://trustedcomputinggroup.org/wp-content/uploads/TCG_PCClientImplementation_1-21_1_00.pdf
+//  (section 11.3.2.1 "Event Tag Events")
+func decodeEventDataTaggedEvent(data []byte) (*TaggedEventData, error) {
+	r := bytes.NewReader(data)
+
+	var header struct {
+		ID   TaggedEventID
+		Size uint32
+	}
+	if err := binary.Read(r, binary.LittleEndian, &header); err != nil {
+		return nil, xerrors.Errorf("cannot read header: %w", err)
+	}
+	if int64(header.Size) != int64(r.Len()) {
+		return nil, errors.New("invalid tagged event data size")
+	}
+
+	d := &TaggedEventData{data: data, ID: header.ID, Data: data[8 : 8+header.Size]}
+
+	taggedEventTypesMu.RLock()
+	t, ok := taggedEventTypes[header.ID]
+	taggedEventTypesMu.RUnlock()
+	if !ok || t.decoder == nil {
+		return d, nil
+	}
+
+	payload, err := t.decoder(d.Data)
+	if err != nil {
+		return nil, xerrors.Errorf("cannot decode tagged event data for %v: %w", header.ID, err)
+	}
+	d.Payload = payload
+	return d, nil
+}
@@ -0,0 +1,94 @@
+// Copyright 2019 Canonical Ltd.
+// Licensed under the LGPLv3 with static-linking exception.
+// See LICENCE file for details.
+
+package tcglog
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"testing"
+)
+
+func makeTestTaggedEvent(id TaggedEventID, data []byte) []byte {
+	var b bytes.Buffer
+	binary.Write(&b, binary.LittleEndian, id)
+	binary.Write(&b, binary.LittleEndian, uint32(len(data)))
+	b.Write(data)
+	return b.Bytes()
+}
+
+func TestDecodeEventDataTaggedEvent(t *testing.T) {
+	data := makeTestTaggedEvent(0x12345678, []byte{1, 2, 3})
+
+	d, ok := DecodeEventData(0, EventTypeEventTag, DigestMap{}, data, nil).(*TaggedEventData)
+	if !ok {
+		t.Fatalf("Unexpected event data type")
+	}
+	if d.ID != 0x12345678 || !bytes.Equal(d.Data, []byte{1, 2, 3}) || d.Payload != nil {
+		t.Errorf("Unexpected event data: %s", d)
+	}
+	if d.String() != "TCG_PCClientTaggedEvent{ taggedEventID: 12345678, taggedEventData: 010203 }" {
+		t.Errorf("Unexpected string: %s", d)
+	}
+
+	var buf bytes.Buffer
+	if err := d.EncodeTo(&buf); err != nil {
+		t.Fatalf("EncodeTo failed: %v", err)
+	}
+	if !bytes.Equal(buf.Bytes(), data) {
+		t.Errorf("Unexpected encoding: %x", buf.Bytes())
+	}
+
+	for _, data := range []struct {
+		desc string
+		data []byte
+	}{
+		{desc: "TruncatedHeader", data: data[:6]},
+		{desc: "TruncatedData", data: data[:len(data)-1]},
+		{desc: "TrailingBytes", data: append(data, 0)},
+	} {
+		t.Run(data.desc, func(t *testing.T) {
+			if _, isErr := DecodeEventData(0, EventTypeEventTag, DigestMap{}, data.data, nil).(error); !isErr {
+				t.Errorf("Expected an error")
+			}
+		})
+	}
+}
+
+func TestRegisterTaggedEventType(t *testing.T) {
+	const id TaggedEventID = 0xa5a5a5a5
+
+	RegisterTaggedEventType(id, "TEST_TAG", func(data []byte) (EventData, error) {
+		if len(data) == 0 {
+			return nil, errors.New("no data")
+		}
+		return &AsciiStringEventData{data: data}, nil
+	})
+	defer RegisterTaggedEventType(id, "", nil)
+
+	d, ok := DecodeEventData(0, EventTypeEventTag, DigestMap{}, makeTestTaggedEvent(id, []byte("foo")), nil).(*TaggedEventData)
+	if !ok {
+		t.Fatalf("Unexpected event data type")
+	}
+	if d.Payload == nil || d.Payload.String() != "foo" {
+		t.Errorf("Unexpected payload: %v", d.Payload)
+	}
+	if d.String() != "TCG_PCClientTaggedEvent{ taggedEventID: TEST_TAG, taggedEventData: foo }" {
+		t.Errorf("Unexpected string: %s", d)
+	}
+
+	err, isErr := DecodeEventData(0, EventTypeEventTag, DigestMap{}, makeTestTaggedEvent(id, nil), nil).(error)
+	if !isErr {
+		t.Fatalf("Expected an error")
+	}
+	if err.Error() != "cannot decode EV_EVENT_TAG event data: cannot decode tagged event data for TEST_TAG: no data" {
+		t.Errorf("Unexpected error: %v", err)
+	}
+
+	RegisterTaggedEventType(id, "", nil)
+	if id.String() != "a5a5a5a5" {
+		t.Errorf("Unexpected name after removal: %s", id)
+	}
+}
@@ -7,6 +7,7 @@ package tcglog
 import (
 	"bytes"
 	"encoding/binary"
+	"errors"
 	"fmt"
 	"io"
 	"math"
@@ -43,12 +44,14 @@ const (
 	SpecId                                            // "Spec ID Event00", "Spec ID Event02" or "Spec ID Event03" event type
 	StartupLocality                                   // "StartupLocality" event type
 	BiosIntegrityMeasurement                          // "SP800-155 Event" event type
+	NvIndexInstance                                   // "NvIndexInstance" event type
+	NvIndexDynamic                                    // "NvIndexDynamic" event type
 )
 
 // NoActionEventData provides a mechanism to determine the type of a EV_NO_ACTION event from the decoded EventData.
 type NoActionEventData interface {
 	Type() NoActionEventType
-	Spec() string
+	Signature() string
 }
 
 // SpecIdEvent corresponds to the TCG_PCClientSpecIdEventStruct, TCG_EfiSpecIdEventStruct, and TCG_EfiSpecIdEvent types and is the
@@ -96,6 +99,48 @@ func (e *SpecIdEvent) Bytes() []byte {
 	return e.data
 }
 
+// EncodeTo encodes this event data in to the form in which it appears in the event log. The fields are encoded according to
+// the specification indicated by the Spec field.
+func (e *SpecIdEvent) EncodeTo(w io.Writer) error {
+	var signature string
+	switch e.Spec {
+	case SpecPCClient:
+		signature = "Spec ID Event00"
+	case SpecEFI_1_2:
+		signature = "Spec ID Event02"
+	case SpecEFI_2:
+		signature = "Spec ID Event03"
+	default:
+		return fmt.Errorf("unrecognized specification (%d)", e.Spec)
+	}
+	if len(e.VendorInfo) > math.MaxUint8 {
+		return errors.New("vendor info is too large")
+	}
+
+	var b bytes.Buffer
+	var sig [16]byte
+	copy(sig[:], signature)
+	b.Write(sig[:])
+	binary.Write(&b, binary.LittleEndian, specIdEventCommon{
+		PlatformClass:    e.PlatformClass,
+		SpecVersionMinor: e.SpecVersionMinor,
+		SpecVersionMajor: e.SpecVersionMajor,
+		SpecErrata:       e.SpecErrata,
+		UintnSize:        e.UintnSize})
+	if e.Spec == SpecEFI_2 {
+		if len(e.DigestSizes) == 0 {
+			return errors.New("no digest algorithms")
+		}
+		binary.Write(&b, binary.LittleEndian, uint32(len(e.DigestSizes)))
+		binary.Write(&b, binary.LittleEndian, e.DigestSizes)
+	}
+	b.WriteByte(uint8(len(e.VendorInfo)))
+	b.Write(e.VendorInfo)
+
+	_, err := b.WriteTo(w)
+	return err
+}
+
 func (e *SpecIdEvent) Type() NoActionEventType {
 	return SpecId
 }
@@ -170,39 +215,55 @@ var (
 	validNormalSeparatorValues = [...]uint32{0, math.MaxUint32}
 )
 
-// asciiStringEventData corresponds to event data that is an ASCII string. The event data may be informational (it provides a hint
+// AsciiStringEventData corresponds to event data that is an ASCII string. The event data may be informational (it provides a hint
 // as to what was measured as opposed to representing what was measured).
-type asciiStringEventData struct {
+type AsciiStringEventData struct {
 	data []byte
 }
 
-func (e *asciiStringEventData) String() string {
-	return *(*string)(unsafe.Pointer(&e.data))
+func (e *AsciiStringEventData) String() string {
+	return EscapeString(strings.TrimRight(*(*string)(unsafe.Pointer(&e.data)), "\x00"))
 }
 
-func (e *asciiStringEventData) Bytes() []byte {
+func (e *AsciiStringEventData) Bytes() []byte {
 	return e.data
 }
 
-// unknownNoActionEventData is the event data for a EV_NO_ACTION event with an unrecognized type.
-type unknownNoActionEventData struct {
-	data      []byte
-	signature string
+// EncodeTo encodes this event data in to the form in which it appears in the event log.
+func (e *AsciiStringEventData) EncodeTo(w io.Writer) error {
+	_, err := w.Write(e.data)
+	return err
 }
 
-func (e *unknownNoActionEventData) String() string {
+// VendorNoActionEventData is the event data for a EV_NO_ACTION event with an unrecognized signature, which is used for vendor
+// specific informational events. Its type is UnknownNoActionEvent.
+type VendorNoActionEventData struct {
+	data       []byte
+	signature  string
+	VendorData []byte // The data that follows the signature
+}
+
+func (e *VendorNoActionEventData) String() string {
 	return ""
 }
 
-func (e *unknownNoActionEventData) Bytes() []byte {
+func (e *VendorNoActionEventData) Bytes() []byte {
 	return e.data
 }
 
-func (e *unknownNoActionEventData) Type() NoActionEventType {
+// EncodeTo encodes this event data in to the form in which it appears in the event log.
+func (e *VendorNoActionEventData) EncodeTo(w io.Writer) error {
+	var sig [16]byte
+	copy(sig[:], e.signature)
+	_, err := w.Write(append(sig[:], e.VendorData...))
+	return err
+}
+
+func (e *VendorNoActionEventData) Type() NoActionEventType {
 	return UnknownNoActionEvent
 }
 
-func (e *unknownNoActionEventData) Signature() string {
+func (e *VendorNoActionEventData) Signature() string {
 	return e.signature
 }
 
@@ -251,20 +312,28 @@ func decodeEventDataNoAction(data []byte) (EventData, error) {
 			return nil, xerrors.Errorf("cannot decode StartupLocality data: %w", err)
 		}
 		return out, nil
+	case "NvIndexInstance", "NvIndexDynamic":
+		out, err := decodeNvIndexEvent(r, signature, data)
+		if err != nil {
+			return nil, xerrors.Errorf("cannot decode %s data: %w", signature, err)
+		}
+		return out, nil
 	default:
-		return &unknownNoActionEventData{data: data, signature: signature}, nil
+		return &VendorNoActionEventData{data: data, signature: signature, VendorData: data[16:]}, nil
 	}
 }
 
 // https://trustedcomputinggroup.org/wp-content/uploads/TCG_PCClientImplementation_1-21_1_00.pdf (section 11.3.3 "EV_ACTION event types")
 // https://trustedcomputinggroup.org/wp-content/uploads/PC-ClientSpecific_Platform_Profile_for_TPM_2p0_Systems_v51.pdf (section 9.4.3 "EV_ACTION Event Types")
-func decodeEventDataAction(data []byte) *asciiStringEventData {
-	return &asciiStringEventData{data: data}
+func decodeEventDataAction(data []byte, eventType EventType) *ActionEventData {
+	return &ActionEventData{data: data, eventType: eventType, Message: strings.TrimRight(string(data), "\x00"),
+		Action: lookupAction(string(data))}
 }
 
 // SeparatorEventData is the event data associated with a EV_SEPARATOR event.
 type SeparatorEventData struct {
 	data    []byte
+	isError bool
 	IsError bool // The event indicates an error condition
 }
 
@@ -279,6 +348,21 @@ func (e *SeparatorEventData) Bytes() []byte {
 	return e.data
 }
 
+// EncodeTo encodes this event data in to the form in which it appears in the event log. If IsError hasn't been modified since the
+// event data was decoded, the original bytes are written. Otherwise, the value indicated by IsError is written - 0x00000000 for a
+// normal separator and SeparatorEventErrorValue for an error.
+func (e *SeparatorEventData) EncodeTo(w io.Writer) error {
+	if e.data != nil && e.IsError == e.isError {
+		_, err := w.Write(e.data)
+		return err
+	}
+	var value uint32
+	if e.IsError {
+		value = SeparatorEventErrorValue
+	}
+	return binary.Write(w, binary.LittleEndian, value)
+}
+
 // https://trustedcomputinggroup.org/wp-content/uploads/TCG_PCClientImplementation_1-21_1_00.pdf
 //  (section 3.3.2.2 2 Error Conditions" , section 8.2.3 "Measuring Boot Events")
 // https://trustedcomputinggroup.org/wp-content/uploads/PC-ClientSpecific_Platform_Profile_for_TPM_2p0_Systems_v51.pdf:
@@ -294,7 +378,25 @@ func decodeEventDataSeparator(digests DigestMap, data []byte) *SeparatorEventDat
 		break
 	}
 
-	return &SeparatorEventData{data: data, IsError: isError}
+	return &SeparatorEventData{data: data, isError: isError, IsError: isError}
+}
+
+// https://trustedcomputinggroup.org/wp-content/uploads/TCG_PCClientSpecPlat_TPM_2p0_1p04_pub.pdf
+//  (section 9.4.1 "Event Types")
+func decodeEventDataHCRTM(data []byte) (*AsciiStringEventData, error) {
+	if string(data) != HCRTMEventString {
+		return nil, errors.New("unexpected data")
+	}
+	return &AsciiStringEventData{data: data}, nil
+}
+
+// https://trustedcomputinggroup.org/wp-content/uploads/TCG_PCClientSpecPlat_TPM_2p0_1p04_pub.pdf
+//  (section 9.4.1 "Event Types")
+func decodeEventDataOmitBootDeviceEvents(data []byte) (*AsciiStringEventData, error) {
+	if string(data) != OmitBootDeviceEventsString {
+		return nil, fmt.Errorf("unexpected data (expected \"%s\")", OmitBootDeviceEventsString)
+	}
+	return &AsciiStringEventData{data: data}, nil
 }
 
 // https://trustedcomputinggroup.org/wp-content/uploads/TCG_PCClientImplementation_1-21_1_00.pdf (section 11.3.1 "Event Types")
@@ -302,19 +404,48 @@ func decodeEventDataSeparator(digests DigestMap, data []byte) *SeparatorEventDat
 // https://trustedcomputinggroup.org/wp-content/uploads/TCG_PCClientSpecPlat_TPM_2p0_1p04_pub.pdf (section 9.4.1 "Event Types")
 func decodeEventDataTCG(eventType EventType, digests DigestMap, data []byte) (out EventData, err error) {
 	switch eventType {
+	case EventTypePostCode:
+		out = decodeEventDataPostCode(data)
+	case EventTypeSCRTMContents:
+		out = decodeEventDataSCRTMContents(data)
+	case EventTypeEFIHCRTMEvent:
+		out, err = decodeEventDataHCRTM(data)
+	case EventTypeEventTag:
+		out, err = decodeEventDataTaggedEvent(data)
+	case EventTypePlatformConfigFlags:
+		out = decodeEventDataPlatformConfigFlags(data)
+	case EventTypeOmitBootDeviceEvents:
+		out, err = decodeEventDataOmitBootDeviceEvents(data)
+	case EventTypeIPL, EventTypeIPLPartitionData:
+		out = decodeEventDataIPL(eventType, data)
+	case EventTypePrebootCert:
+		out = decodeEventDataPrebootCert(data)
+	case EventTypeNonhostCode, EventTypeNonhostConfig, EventTypeNonhostInfo:
+		out = decodeEventDataNonhost(eventType, data)
 	case EventTypeNoAction:
 		out, err = decodeEventDataNoAction(data)
 	case EventTypeSeparator:
 		return decodeEventDataSeparator(digests, data), nil
 	case EventTypeAction, EventTypeEFIAction:
-		return decodeEventDataAction(data), nil
-	case EventTypeEFIVariableDriverConfig, EventTypeEFIVariableBoot, EventTypeEFIVariableAuthority:
+		return decodeEventDataAction(data, eventType), nil
+	case EventTypeEFIVariableDriverConfig, EventTypeEFIVariableBoot, EventTypeEFIVariableAuthority, EventTypeEFISPDMDevicePolicy:
 		out, err = decodeEventDataEFIVariable(data, eventType)
+	case EventTypeEFISPDMFirmwareBlob, EventTypeEFISPDMFirmwareConfig, EventTypeEFISPDMDeviceAuthority:
+		out, err = decodeEventDataSPDMDeviceSecurity(data)
 	case EventTypeEFIBootServicesApplication, EventTypeEFIBootServicesDriver, EventTypeEFIRuntimeServicesDriver:
 		out, err = decodeEventDataEFIImageLoad(data)
 	case EventTypeEFIGPTEvent:
 		out, err = decodeEventDataEFIGPT(data)
+	case EventTypeEFIHandoffTables:
+		out, err = decodeEventDataEFIHandoffTables(data)
+	case EventTypeEFIHandoffTables2:
+		out, err = decodeEventDataEFIHandoffTables2(data)
+	case EventTypeEFIPlatformFirmwareBlob:
+		out, err = decodeEventDataEFIPlatformFirmwareBlob(data)
+	case EventTypeEFIPlatformFirmwareBlob2, EventTypePostCode2:
+		out, err = decodeEventDataEFIPlatformFirmwareBlob2(data)
 	default:
+		out = decodeEventDataTXT(eventType, data)
 	}
 
 	if err != nil {
@@ -0,0 +1,37 @@
+// Copyright 2019 Canonical Ltd.
+// Licensed under the LGPLv3 with static-linking exception.
+// See LICENCE file for details.
+
+package tcglog
+
+import (
+	"testing"
+)
+
+func TestDecodeEventDataOmitBootDeviceEvents(t *testing.T) {
+	d := DecodeEventData(4, EventTypeOmitBootDeviceEvents, DigestMap{}, []byte(OmitBootDeviceEventsString), nil)
+	if _, ok := d.(*AsciiStringEventData); !ok {
+		t.Fatalf("Unexpected event data type: %T", d)
+	}
+	if d.String() != "BOOT ATTEMPTS OMITTED" {
+		t.Errorf("Unexpected string: %s", d)
+	}
+
+	for _, data := range []struct {
+		desc string
+		data []byte
+	}{
+		{desc: "NullTerminated", data: []byte("BOOT ATTEMPTS OMITTED\x00")},
+		{desc: "WrongCase", data: []byte("Boot Attempts Omitted")},
+	} {
+		t.Run(data.desc, func(t *testing.T) {
+			err, isErr := DecodeEventData(4, EventTypeOmitBootDeviceEvents, DigestMap{}, data.data, nil).(error)
+			if !isErr {
+				t.Fatalf("Expected an error")
+			}
+			if err.Error() != "cannot decode EV_OMIT_BOOT_DEVICE_EVENTS event data: unexpected data (expected \"BOOT ATTEMPTS OMITTED\")" {
+				t.Errorf("Unexpected error: %v", err)
+			}
+		})
+	}
+}
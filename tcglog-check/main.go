@@ -15,9 +15,8 @@ import (
 	"sort"
 	"strings"
 
-	"github.com/canonical/go-tpm2"
-	"github.com/canonical/go-tpm2/mu"
 	"github.com/canonical/tcglog-parser"
+	"github.com/canonical/tcglog-parser/gotpm2"
 	"github.com/canonical/tcglog-parser/internal"
 )
 
@@ -57,8 +56,11 @@ func (l *requiredAlgsArg) Set(value string) error {
 
 var (
 	withGrub      bool
+	grubCmdPcr    int
+	grubFilePcr   int
 	withSdEfiStub bool
-	sdEfiStubPcr  int
+	withSdUKI     bool
+	sdEfiStubPcrs internal.PCRArgList
 	noDefaultPcrs bool
 	tpmPath       string
 	pcrs = internal.PCRArgList{0, 1, 2, 3, 4, 5, 6, 7}
@@ -67,12 +69,28 @@ var (
 	ignoreDataDecodeErrors      bool
 	ignoreMeasuredTrailingBytes bool
 	requiredAlgs                requiredAlgsArg
+	imaLogPath                  string
+	reportPath                  string
+	pcrValuesPath               string
+	efivarsPath                 string
+	bootEntries                 bool
+	mok                         bool
+	mokutilExportPath           string
+	espPath                     string
+	gptDiskPath                 string
+	audit                       bool
+	scanSecrets                 bool
+	policyPath                  string
+	corimPath                   string
 )
 
 func init() {
-	flag.BoolVar(&withGrub, "with-grub", false, "Validate log entries made by GRUB in to PCR's 8 and 9")
+	flag.BoolVar(&withGrub, "with-grub", false, "Validate log entries made by GRUB")
+	flag.IntVar(&grubCmdPcr, "grub-cmd-pcr", 8, "Specify the PCR that GRUB measures commands and kernel commandlines to")
+	flag.IntVar(&grubFilePcr, "grub-file-pcr", 9, "Specify the PCR that GRUB measures files to")
 	flag.BoolVar(&withSdEfiStub, "with-systemd-efi-stub", false, "Interpret measurements made by systemd's EFI stub Linux loader")
-	flag.IntVar(&sdEfiStubPcr, "systemd-efi-stub-pcr", 8, "Specify the PCR that systemd's EFI stub Linux loader measures to")
+	flag.Var(&sdEfiStubPcrs, "systemd-efi-stub-pcr", "Specify a PCR that systemd's EFI stub Linux loader measures to (default 8). Can be specified multiple times")
+	flag.BoolVar(&withSdUKI, "with-systemd-uki", false, "Interpret measurements made by systemd-stub for unified kernel images to PCRs 11, 12 and 13")
 	flag.BoolVar(&noDefaultPcrs, "no-default-pcrs", false, "Omit the default PCRs")
 	flag.StringVar(&tpmPath, "tpm-path", "/dev/tpm0", "Validate log entries associated with the specified TPM")
 	flag.Var(&pcrs, "pcrs", "Validate log entries for the specified PCRs. Can be specified multiple times")
@@ -84,6 +102,32 @@ func init() {
 	flag.BoolVar(&ignoreMeasuredTrailingBytes, "ignore-measured-trailing-bytes", false,
 		"Don't exit with an error if any event data contains trailing bytes that were hashed and measured")
 	flag.Var(&requiredAlgs, "required-algs", "Require the specified algorithms to be present in the log")
+	flag.StringVar(&imaLogPath, "ima-log", "", "Also validate the specified IMA runtime measurement log (binary format)")
+	flag.StringVar(&reportPath, "report", "", "Write a machine-readable JSON report of the findings of the built-in validator checks, "+
+		"including compliance with the TCG PC Client Platform Firmware Profile, to the specified file")
+	flag.StringVar(&pcrValuesPath, "pcr-values", "", "Verify the log against the PCR values in the specified file rather than "+
+		"the TPM. The file can contain the output of tpm2_pcrread, a list of pcr:alg=hex values, or JSON")
+	flag.StringVar(&efivarsPath, "efivars", "", "Compare the EFI variables measured in the log with their current contents in "+
+		"the specified efivarfs directory (eg, "+tcglog.DefaultEFIVarfsPath+"), and report variables that have changed since boot")
+	flag.BoolVar(&bootEntries, "boot-entries", false, "Correlate the boot entries measured in the log with the current Boot####, "+
+		"BootOrder and BootCurrent variables (read from the directory specified by -efivars, or "+tcglog.DefaultEFIVarfsPath+
+		"), and report stale entries and the entry that was used for the current boot")
+	flag.BoolVar(&mok, "mok", false, "Cross-check the MOK variables measured to PCR 14 with the current MokListRT, MokListXRT, "+
+		"MokSBStateRT and MokListTrustedRT variables (read from the directory specified by -efivars, or "+tcglog.DefaultEFIVarfsPath+
+		"), and report MOK changes that are staged for the next boot")
+	flag.StringVar(&mokutilExportPath, "mokutil-export", "", "Cross-check the MokList measured to PCR 14 with the certificates "+
+		"exported to the specified directory with \"mokutil --export\"")
+	flag.StringVar(&espPath, "esp", "", "Compare the EFI applications measured in the log with the images on the EFI system "+
+		"partition mounted at the specified path, and report images that have changed since boot")
+	flag.StringVar(&gptDiskPath, "gpt-disk", "", "Compare the partition table measured in the log with the current partition "+
+		"table on the specified boot disk (eg, /dev/nvme0n1), and report changes since boot")
+	flag.BoolVar(&audit, "audit", false, "Report weak platform security configurations that are evident from the log, such as "+
+		"secure boot being disabled or the firmware debugger being enabled")
+	flag.BoolVar(&scanSecrets, "scan-secrets", false, "Report likely secrets, such as passwords and LUKS keys, in the kernel "+
+		"command lines, GRUB commands and boot entries measured in the log")
+	flag.StringVar(&policyPath, "policy", "", "Evaluate the log against the rules in the specified JSON policy file")
+	flag.StringVar(&corimPath, "corim", "", "Verify the log against the reference values in the specified CoRIM file, and report "+
+		"which reference claims are satisfied. The signature of a signed CoRIM is not verified")
 }
 
 type efiBootVariableBehaviour int
@@ -94,87 +138,40 @@ const (
 	efiBootVariableBehaviourVarDataOnly
 )
 
-func pcrIndexListToSelect(l []tcglog.PCRIndex) (out tpm2.PCRSelect) {
-	for _, i := range l {
-		out = append(out, int(i))
-	}
-	return
-}
-
-func readPCRsFromTPM2Device(tpm *tpm2.TPMContext, algorithms tcglog.AlgorithmIdList) (map[tcglog.PCRIndex]tcglog.DigestMap, error) {
-	result := make(map[tcglog.PCRIndex]tcglog.DigestMap)
-
-	var selections tpm2.PCRSelectionList
-	for _, alg := range algorithms {
-		selections = append(selections, tpm2.PCRSelection{Hash: tpm2.HashAlgorithmId(alg), Select: pcrIndexListToSelect(pcrs)})
-	}
-
-	for _, i := range pcrs {
-		result[i] = tcglog.DigestMap{}
+func openPCRReader() (tcglog.PCRReader, func() error, error) {
+	if pcrValuesPath == "" {
+		return gotpm2.OpenPCRReader(tpmPath)
 	}
 
-	_, digests, err := tpm.PCRRead(selections)
+	f, err := os.Open(pcrValuesPath)
 	if err != nil {
-		return nil, fmt.Errorf("cannot read PCR values: %v", err)
+		return nil, nil, fmt.Errorf("cannot open PCR values file: %v", err)
 	}
+	defer f.Close()
 
-	for _, s := range selections {
-		for _, i := range s.Select {
-			result[tcglog.PCRIndex(i)][tcglog.AlgorithmId(s.Hash)] = tcglog.Digest(digests[s.Hash][i])
-		}
-	}
-	return result, nil
-}
-
-func readPCRsFromTPM1Device(tpm *tpm2.TPMContext) (map[tcglog.PCRIndex]tcglog.DigestMap, error) {
-	result := make(map[tcglog.PCRIndex]tcglog.DigestMap)
-	for _, i := range pcrs {
-		in, err := mu.MarshalToBytes(uint32(i))
-		if err != nil {
-			return nil, fmt.Errorf("cannot read PCR values due to a marshalling error: %v", err)
-		}
-		rc, _, out, err := tpm.RunCommandBytes(tpm2.StructTag(0x00c1), tpm2.CommandCode(0x00000015), in)
-		if err != nil {
-			return nil, fmt.Errorf("cannot read PCR values: %v", err)
-		}
-		if rc != tpm2.Success {
-			return nil, fmt.Errorf("cannot read PCR values: unexpected response code (0x%08x)", rc)
-		}
-		result[i] = tcglog.DigestMap{}
-		result[i][tcglog.AlgorithmSha1] = out
+	values, err := tcglog.ParsePCRValues(f)
+	if err != nil {
+		return nil, nil, fmt.Errorf("cannot parse PCR values file: %v", err)
 	}
-	return result, nil
+	return values, func() error { return nil }, nil
 }
 
-func getTPMDeviceVersion(tpm *tpm2.TPMContext) int {
-	if isTpm2, _ := tpm.IsTPM2(); isTpm2 {
-		return 2
-	}
-
-	payload, _ := mu.MarshalToBytes(uint32(0x00000005), uint32(4), uint32(0x00000103))
-	if rc, _, _, err := tpm.RunCommandBytes(tpm2.StructTag(0x00c1), tpm2.CommandCode(0x00000065), payload); err == nil && rc == tpm2.Success {
-		return 1
+func readPCRs(pcrs []tcglog.PCRIndex, algorithms tcglog.AlgorithmIdList) (map[tcglog.PCRIndex]tcglog.DigestMap, error) {
+	r, closeReader, err := openPCRReader()
+	if err != nil {
+		return nil, err
 	}
-
-	return 0
+	defer closeReader()
+	return r.ReadPCRs(pcrs, algorithms)
 }
 
-func readPCRs(algorithms tcglog.AlgorithmIdList) (map[tcglog.PCRIndex]tcglog.DigestMap, error) {
-	tcti, err := tpm2.OpenTPMDevice(tpmPath)
+func comparePCRs(log *tcglog.Log, pcrs []tcglog.PCRIndex) (*tcglog.PCRComparison, error) {
+	r, closeReader, err := openPCRReader()
 	if err != nil {
-		return nil, fmt.Errorf("could not open TPM device: %v", err)
+		return nil, err
 	}
-	tpm, _ := tpm2.NewTPMContext(tcti)
-	defer tpm.Close()
-
-	switch getTPMDeviceVersion(tpm) {
-	case 2:
-		return readPCRsFromTPM2Device(tpm, algorithms)
-	case 1:
-		return readPCRsFromTPM1Device(tpm)
-	}
-
-	return nil, errors.New("not a valid TPM device")
+	defer closeReader()
+	return log.ComparePCRs(r, pcrs)
 }
 
 type incorrectDigestValue struct {
@@ -189,20 +186,19 @@ type checkedEvent struct {
 	incorrectDigestValues []incorrectDigestValue
 }
 
-func (e *checkedEvent) extendsPCR() bool {
-	if e.EventType == tcglog.EventTypeNoAction {
-		return false
-	}
-	return true
-}
-
 func (e *checkedEvent) expectedMeasuredBytes(efiBootVariableQuirk bool) []byte {
 	if err := e.dataDecoderErr(); err != nil {
 		return nil
 	}
 
 	switch e.EventType {
-	case tcglog.EventTypeEventTag, tcglog.EventTypeSCRTMVersion, tcglog.EventTypePlatformConfigFlags, tcglog.EventTypeTableOfDevices, tcglog.EventTypeNonhostInfo, tcglog.EventTypeOmitBootDeviceEvents:
+	case tcglog.EventTypeEventTag:
+		if d, ok := e.Data.(*tcglog.TaggedEventData); ok && (d.ID == tcglog.LinuxLoadOptionsEventTagID || d.ID == tcglog.LinuxInitrdEventTagID) {
+			// The Linux EFI stub measures the content described by the event
+			return nil
+		}
+		return e.Data.Bytes()
+	case tcglog.EventTypeSCRTMVersion, tcglog.EventTypePlatformConfigFlags, tcglog.EventTypeTableOfDevices, tcglog.EventTypeNonhostInfo, tcglog.EventTypeOmitBootDeviceEvents:
 		return e.Data.Bytes()
 	case tcglog.EventTypeSeparator:
 		if e.Data.(*tcglog.SeparatorEventData).IsError {
@@ -213,7 +209,7 @@ func (e *checkedEvent) expectedMeasuredBytes(efiBootVariableQuirk bool) []byte {
 		return e.Data.Bytes()
 	case tcglog.EventTypeAction, tcglog.EventTypeEFIAction:
 		return e.Data.Bytes()
-	case tcglog.EventTypeEFIVariableDriverConfig, tcglog.EventTypeEFIVariableBoot, tcglog.EventTypeEFIVariableAuthority:
+	case tcglog.EventTypeEFIVariableDriverConfig, tcglog.EventTypeEFIVariableBoot, tcglog.EventTypeEFIVariableAuthority, tcglog.EventTypeEFISPDMDevicePolicy:
 		if e.EventType == tcglog.EventTypeEFIVariableBoot && efiBootVariableQuirk {
 			return e.Data.(*tcglog.EFIVariableData).VariableData
 		}
@@ -333,19 +329,6 @@ type logChecker struct {
 	seenIncorrectDigests      bool
 }
 
-func (c *logChecker) simulatePCRExtend(event *checkedEvent) {
-	if !event.extendsPCR() {
-		return
-	}
-
-	for alg, digest := range event.Digests {
-		h := alg.GetHash().New()
-		h.Write(c.expectedPCRValues[event.PCRIndex][alg])
-		h.Write(digest)
-		c.expectedPCRValues[event.PCRIndex][alg] = h.Sum(nil)
-	}
-}
-
 func (c *logChecker) processEvent(event *tcglog.Event) {
 	if !pcrs.Contains(event.PCRIndex) {
 		return
@@ -359,28 +342,314 @@ func (c *logChecker) processEvent(event *tcglog.Event) {
 		c.seenIncorrectDigests = true
 	}
 
-	c.simulatePCRExtend(ce)
 	c.events = append(c.events, ce)
 }
 
 func (c *logChecker) run(log *tcglog.Log) {
+	for _, event := range log.Events {
+		c.processEvent(event)
+	}
+
 	c.expectedPCRValues = make(map[tcglog.PCRIndex]tcglog.DigestMap)
 	for _, pcr := range pcrs {
 		c.expectedPCRValues[pcr] = tcglog.DigestMap{}
 
 		for _, alg := range log.Algorithms {
-			c.expectedPCRValues[pcr][alg] = make(tcglog.Digest, alg.Size())
+			c.expectedPCRValues[pcr][alg], _ = log.ReplayPCR(alg, pcr)
 		}
 	}
+}
 
-	for _, event := range log.Events {
-		c.processEvent(event)
+func checkIMALog(log *tcglog.Log) (failCount int) {
+	f, err := os.Open(imaLogPath)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to open IMA log: %v\n", err)
+		return 1
+	}
+	defer f.Close()
+
+	imaLog, err := tcglog.ParseIMALog(f, nil)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to parse IMA log: %v\n", err)
+		return 1
+	}
+
+	fmt.Printf("\n")
+	if err := log.CheckIMABootAggregate(imaLog); err != nil {
+		fmt.Printf("*** FAIL ***: The IMA boot_aggregate entry is not consistent with the TCG log: %v\n\n", err)
+		failCount++
+	}
+
+	if tpmPath == "" && pcrValuesPath == "" {
+		fmt.Printf("- INFO: Expected PCR values from IMA log:\n")
+		for _, alg := range log.Algorithms {
+			value, err := imaLog.ReplayPCR(alg, tcglog.IMAPCR, false)
+			if err != nil {
+				fmt.Printf("\tPCR %d, bank %s: %v\n", tcglog.IMAPCR, alg, err)
+				continue
+			}
+			fmt.Printf("\tPCR %d, bank %s: %x\n", tcglog.IMAPCR, alg, value)
+		}
+		return failCount
+	}
+
+	tpmPCRValues, err := readPCRs([]tcglog.PCRIndex{tcglog.IMAPCR}, log.Algorithms)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Cannot read PCR values from TPM: %v", err)
+		return failCount + 1
+	}
+
+	seenIMALogError := false
+	for _, alg := range log.Algorithms {
+		err := imaLog.VerifyPCR(alg, tcglog.IMAPCR, tpmPCRValues[tcglog.IMAPCR][alg])
+		if err == nil {
+			continue
+		}
+		if !seenIMALogError {
+			seenIMALogError = true
+			fmt.Printf("*** FAIL ***: The IMA log is not consistent with what was measured in to the TPM:\n")
+			failCount++
+		}
+		fmt.Printf("\t- PCR %d, bank %s: %v\n", tcglog.IMAPCR, alg, err)
+	}
+
+	return failCount
+}
+
+func checkEFIVariables(log *tcglog.Log) (failCount int) {
+	mismatches, err := log.CheckEFIVariables(tcglog.EFIVarfs(efivarsPath))
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Cannot compare EFI variables: %v\n", err)
+		return 1
+	}
+	if len(mismatches) == 0 {
+		return 0
+	}
+
+	fmt.Printf("\n*** FAIL ***: Some measured EFI variables have changed since boot:\n")
+	for _, m := range mismatches {
+		fmt.Printf("\t- %s\n", m)
+	}
+	fmt.Printf("The values of the PCRs that these variables are measured to will be different on the next boot.\n")
+	return 1
+}
+
+func checkBootEntries(log *tcglog.Log) (failCount int) {
+	c, err := log.CorrelateBootEntries(tcglog.EFIVarfs(efivarsPath))
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Cannot correlate boot entries: %v\n", err)
+		return 1
+	}
+
+	fmt.Printf("\n- INFO: Boot entries measured in the log:\n")
+	for _, e := range c.Entries {
+		fmt.Printf("\t- %s\n", e)
+	}
+	switch {
+	case c.Taken != nil:
+		fmt.Printf("The current boot used %s.\n", c.Taken.Name)
+	case c.BootCurrent != "":
+		fmt.Printf("The current boot used %s, which was not measured.\n", c.BootCurrent)
+	}
+
+	if len(c.Stale()) > 0 || c.OrderChanged() {
+		fmt.Printf("*** FAIL ***: The boot entries or BootOrder have changed since boot. The value of PCR 1 will be different " +
+			"on the next boot.\n")
+		return 1
+	}
+	return 0
+}
+
+func checkMokState(log *tcglog.Log) (failCount int) {
+	var r tcglog.EFIVariableReader = tcglog.EFIVarfs(efivarsPath)
+	if mokutilExportPath != "" {
+		r = tcglog.MokutilExport(mokutilExportPath)
+	}
+	check, err := log.CheckMokState(r)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Cannot cross-check MOK state: %v\n", err)
+		return 1
+	}
+
+	fmt.Printf("\n- INFO: MOK variables measured in the log:\n")
+	for _, v := range check.Variables {
+		fmt.Printf("\t- %s\n", v)
+	}
+	if !check.Changed() {
+		return 0
+	}
+
+	fmt.Printf("*** FAIL ***: The MOK state has changed since boot:\n")
+	for _, v := range check.Variables {
+		if v.Status == tcglog.MokVariableModified || v.Status == tcglog.MokVariableDeleted {
+			fmt.Printf("\t- %s was %s\n", v.RuntimeName, v.Status)
+		}
+	}
+	for _, r := range check.Pending {
+		fmt.Printf("\t- %s\n", r)
+	}
+	fmt.Printf("The value of PCR 14 will be different on the next boot.\n")
+	return 1
+}
+
+func checkESPImages(log *tcglog.Log) (failCount int) {
+	images, err := log.CheckESPImages(espPath)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Cannot compare EFI applications with ESP: %v\n", err)
+		return 1
 	}
+
+	fmt.Printf("\n- INFO: EFI applications measured in the log:\n")
+	seenMismatch := false
+	for _, i := range images {
+		fmt.Printf("\t- %s\n", i)
+		if i.Status == tcglog.ESPImageModified || i.Status == tcglog.ESPImageMissing {
+			seenMismatch = true
+		}
+	}
+	if seenMismatch {
+		fmt.Printf("*** FAIL ***: Some measured EFI applications have changed on the ESP since boot. The values of the PCRs " +
+			"that these applications are measured to will be different on the next boot.\n")
+		return 1
+	}
+	return 0
+}
+
+func checkGPT(log *tcglog.Log) (failCount int) {
+	f, err := os.Open(gptDiskPath)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to open disk: %v\n", err)
+		return 1
+	}
+	defer f.Close()
+
+	check, err := log.CheckGPT(f)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Cannot compare partition table with disk: %v\n", err)
+		return 1
+	}
+	if !check.Changed() {
+		return 0
+	}
+
+	fmt.Printf("\n*** FAIL ***: The partition table on %s has changed since boot:\n", gptDiskPath)
+	for _, c := range check.Changes {
+		fmt.Printf("\t- %s\n", c)
+	}
+	fmt.Printf("The value of PCR 5 will be different on the next boot.\n")
+	return 1
+}
+
+func auditLog(log *tcglog.Log) (failCount int) {
+	findings := tcglog.NewSecurityAuditor().Validate(log)
+	if len(findings) == 0 {
+		return 0
+	}
+
+	fmt.Printf("\n- INFO: Security audit of the platform configuration evident from the log:\n")
+	for _, f := range findings {
+		fmt.Printf("\t- %s\n", f)
+		if f.Severity == tcglog.SeverityError {
+			failCount = 1
+		}
+	}
+	if failCount > 0 {
+		fmt.Printf("*** FAIL ***: The log indicates that the platform is configured in a way that defeats the protection " +
+			"provided by measured or verified boot.\n")
+	}
+	return failCount
+}
+
+func checkSecrets(log *tcglog.Log) (failCount int) {
+	secrets := log.ScanSecrets()
+	if len(secrets) == 0 {
+		return 0
+	}
+
+	fmt.Printf("\n*** FAIL ***: The following measured strings contain likely secrets:\n")
+	for _, s := range secrets {
+		fmt.Printf("\t- %s\n", s)
+	}
+	fmt.Printf("Measured strings are recorded in plain text in the log, which may be readable by any user. Secrets should not " +
+		"be passed on the kernel command line or in boot entries.\n")
+	return 1
+}
+
+func checkPolicy(log *tcglog.Log) (failCount int) {
+	f, err := os.Open(policyPath)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to open policy: %v\n", err)
+		return 1
+	}
+	defer f.Close()
+
+	policy, err := tcglog.ReadPolicy(f)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to read policy: %v\n", err)
+		return 1
+	}
+	result, err := policy.Evaluate(log)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to evaluate policy: %v\n", err)
+		return 1
+	}
+
+	if result.Passed() {
+		fmt.Printf("\n- INFO: The log satisfies the policy in %s:\n", policyPath)
+	} else {
+		fmt.Printf("\n*** FAIL ***: The log does not satisfy the policy in %s:\n", policyPath)
+		failCount = 1
+	}
+	for _, r := range result.Rules {
+		fmt.Printf("\t- %s\n", strings.Replace(r.String(), "\n", "\n\t", -1))
+	}
+	return failCount
+}
+
+func checkCoRIM(log *tcglog.Log) (failCount int) {
+	f, err := os.Open(corimPath)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to open CoRIM: %v\n", err)
+		return 1
+	}
+	defer f.Close()
+
+	corim, err := tcglog.ReadCoRIM(f)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to read CoRIM: %v\n", err)
+		return 1
+	}
+	result := corim.Verify(log)
+
+	if result.Satisfied() {
+		fmt.Printf("\n- INFO: The log satisfies the reference values in %s:\n", corimPath)
+	} else {
+		fmt.Printf("\n*** FAIL ***: The log does not satisfy the reference values in %s:\n", corimPath)
+		failCount = 1
+	}
+	for _, c := range result.Claims {
+		fmt.Printf("\t- %s\n", c)
+	}
+	return failCount
+}
+
+func writeReport(path string, log *tcglog.Log) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	return tcglog.WriteValidationReport(f, tcglog.NewValidator().Validate(log))
 }
 
 func run() int {
 	flag.Parse()
 
+	if len(sdEfiStubPcrs) == 0 {
+		sdEfiStubPcrs = internal.PCRArgList{8}
+	}
+
 	args := flag.Args()
 	if len(args) > 1 {
 		fmt.Fprintf(os.Stderr, "Too many arguments\n")
@@ -394,10 +663,10 @@ func run() int {
 
 	if !noDefaultPcrs {
 		if withGrub {
-			pcrs = append(pcrs, 8, 9)
+			pcrs = append(pcrs, tcglog.PCRIndex(grubCmdPcr), tcglog.PCRIndex(grubFilePcr))
 		}
 		if withSdEfiStub {
-			pcrs = append(pcrs, tcglog.PCRIndex(sdEfiStubPcr))
+			pcrs = append(pcrs, sdEfiStubPcrs...)
 		}
 	} else {
 		pcrs = pcrs[8:]
@@ -424,12 +693,19 @@ func run() int {
 
 	failCount := 0
 
-	log, err := tcglog.ParseLog(f, &tcglog.LogOptions{EnableGrub: withGrub, EnableSystemdEFIStub: withSdEfiStub, SystemdEFIStubPCR: tcglog.PCRIndex(sdEfiStubPcr)})
+	log, err := tcglog.ParseLog(f, &tcglog.LogOptions{EnableGrub: withGrub, GrubCmdPCR: tcglog.PCRIndex(grubCmdPcr), GrubFilePCR: tcglog.PCRIndex(grubFilePcr), EnableSystemdEFIStub: withSdEfiStub, SystemdEFIStubPCRs: sdEfiStubPcrs, EnableSystemdUKI: withSdUKI})
 	if err != nil {
 		fmt.Fprintf(os.Stderr, "Failed to parse log: %v\n", err)
 		return 1
 	}
 
+	if reportPath != "" {
+		if err := writeReport(reportPath, log); err != nil {
+			fmt.Fprintf(os.Stderr, "Failed to write report: %v\n", err)
+			return 1
+		}
+	}
+
 	missingAlg := false
 	for _, alg := range requiredAlgs {
 		if log.Algorithms.Contains(alg) {
@@ -491,6 +767,20 @@ func run() int {
 		fmt.Printf("This might be a bug in the firmware or bootloader code responsible for performing these measurements.\n\n")
 	}
 
+	var nonStandardActions []string
+	for _, e := range c.events {
+		if d, ok := e.Data.(*tcglog.ActionEventData); ok && !d.IsStandard() {
+			nonStandardActions = append(nonStandardActions, fmt.Sprintf("\t- Event %d in PCR %d (type: %s): \"%s\"\n", e.Index, e.PCRIndex, e.EventType, d))
+		}
+	}
+	if len(nonStandardActions) > 0 {
+		fmt.Printf("- INFO: The following events contain action strings that aren't defined by the TCG specifications:\n")
+		for _, a := range nonStandardActions {
+			fmt.Printf("%s", a)
+		}
+		fmt.Printf("\n")
+	}
+
 	if c.seenMeasuredTrailingBytes {
 		if !ignoreMeasuredTrailingBytes {
 			fmt.Printf("*** FAIL ***")
@@ -527,7 +817,7 @@ func run() int {
 			"digests for these events or by a remote verifier for attestation purposes.\n\n")
 	}
 
-	if tpmPath == "" {
+	if tpmPath == "" && pcrValuesPath == "" {
 		fmt.Printf("- INFO: Expected PCR values from log:\n")
 		for _, i := range pcrs {
 			for _, alg := range log.Algorithms {
@@ -535,25 +825,22 @@ func run() int {
 			}
 		}
 	} else {
-		tpmPCRValues, err := readPCRs(log.Algorithms)
+		comparison, err := comparePCRs(log, pcrs)
 		if err != nil {
-			fmt.Fprintf(os.Stderr, "Cannot read PCR values from TPM: %v", err)
+			fmt.Fprintf(os.Stderr, "Cannot compare PCR values with TPM: %v", err)
 			return 1
 		}
 
-		seenLogConsistencyError := false
-		for _, i := range pcrs {
-			for _, alg := range log.Algorithms {
-				if bytes.Equal(c.expectedPCRValues[i][alg], tpmPCRValues[i][alg]) {
-					continue
-				}
-				if !seenLogConsistencyError {
-					seenLogConsistencyError = true
-					fmt.Printf("*** FAIL ***: The log is not consistent with what was measured in to the TPM for some PCRs:\n")
-					failCount++
-				}
-				fmt.Printf("\t- PCR %d, bank %s - actual value from TPM: %x, expected value from log: %x\n",
-					i, alg, tpmPCRValues[i][alg], c.expectedPCRValues[i][alg])
+		seenLogConsistencyError := !comparison.Ok()
+		if seenLogConsistencyError {
+			fmt.Printf("*** FAIL ***: The log is not consistent with what was measured in to the TPM for some PCRs:\n")
+			failCount++
+		}
+		for _, d := range comparison.Diverged {
+			fmt.Printf("\t- PCR %d, bank %s - actual value from TPM: %x, expected value from log: %x\n",
+				d.PCRIndex, d.Algorithm, d.Actual, d.Expected)
+			if point, err := log.FindDivergence(d.Algorithm, d.PCRIndex, d.Actual); err == nil {
+				fmt.Printf("\t  %s\n", point)
 			}
 		}
 
@@ -564,6 +851,46 @@ func run() int {
 		}
 	}
 
+	if imaLogPath != "" {
+		failCount += checkIMALog(log)
+	}
+
+	if efivarsPath != "" {
+		failCount += checkEFIVariables(log)
+	}
+
+	if bootEntries {
+		failCount += checkBootEntries(log)
+	}
+
+	if mok || mokutilExportPath != "" {
+		failCount += checkMokState(log)
+	}
+
+	if espPath != "" {
+		failCount += checkESPImages(log)
+	}
+
+	if gptDiskPath != "" {
+		failCount += checkGPT(log)
+	}
+
+	if audit {
+		failCount += auditLog(log)
+	}
+
+	if scanSecrets {
+		failCount += checkSecrets(log)
+	}
+
+	if policyPath != "" {
+		failCount += checkPolicy(log)
+	}
+
+	if corimPath != "" {
+		failCount += checkCoRIM(log)
+	}
+
 	if failCount > 0 {
 		return 1
 	}
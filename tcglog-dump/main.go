@@ -9,12 +9,15 @@ import (
 	"encoding/hex"
 	"flag"
 	"fmt"
+	"io"
 	"io/ioutil"
 	"os"
 	"strings"
 
 	"github.com/canonical/tcglog-parser"
+	"github.com/canonical/tcglog-parser/cel"
 	"github.com/canonical/tcglog-parser/internal"
+	"github.com/canonical/tcglog-parser/tpm2tools"
 )
 
 var (
@@ -25,9 +28,23 @@ var (
 	extractDataPrefix    string
 	extractVarDataPrefix string
 	withGrub             bool
+	grubCmdPcr           int
+	grubFilePcr          int
 	withSdEfiStub        bool
-	sdEfiStubPcr         int
+	withSdUKI            bool
+	sdEfiStubPcrs        internal.PCRArgList
 	pcrs                 internal.PCRArgList
+	inputFormat          string
+	tpm2ToolsYAML        bool
+	smbiosTablePath      string
+	resolvePCI           bool
+	pciIdsPath           string
+	pcrValues            bool
+	stages               internal.BootStageArgList
+	showStages           bool
+	secureBootPolicy     string
+	bootChain            bool
+	espPath              string
 )
 
 func init() {
@@ -39,13 +56,127 @@ func init() {
 	flag.BoolVar(&varDataHexDump, "vardatahexdump", false, "Display hexdump of EFI variable data")
 	flag.StringVar(&extractDataPrefix, "extract-data", "", "Extract event data to individual files named with the specified prefix (format: <prefix>-<pcr>-<num>")
 	flag.StringVar(&extractVarDataPrefix, "extract-vardata", "", "Extract EFI variable data to individual files named with the specified prefix (format: <prefix>-<pcr>-<num>")
-	flag.BoolVar(&withGrub, "with-grub", false, "Interpret measurements made by GRUB to PCR's 8 and 9")
+	flag.BoolVar(&withGrub, "with-grub", false, "Interpret measurements made by GRUB")
+	flag.IntVar(&grubCmdPcr, "grub-cmd-pcr", 8, "Specify the PCR that GRUB measures commands and kernel commandlines to")
+	flag.IntVar(&grubFilePcr, "grub-file-pcr", 9, "Specify the PCR that GRUB measures files to")
 	flag.BoolVar(&withSdEfiStub, "with-systemd-efi-stub", false, "Interpret measurements made by systemd's EFI stub Linux loader")
-	flag.IntVar(&sdEfiStubPcr, "systemd-efi-stub-pcr", 8, "Specify the PCR that systemd's EFI stub Linux loader measures to")
+	flag.Var(&sdEfiStubPcrs, "systemd-efi-stub-pcr", "Specify a PCR that systemd's EFI stub Linux loader measures to (default 8). Can be specified multiple times")
+	flag.BoolVar(&withSdUKI, "with-systemd-uki", false, "Interpret measurements made by systemd-stub for unified kernel images to PCRs 11, 12 and 13")
 	flag.Var(&pcrs, "pcrs", "Display events associated with the specified PCRs. Can be specified multiple times")
+	flag.StringVar(&inputFormat, "input-format", "binary", "Format of the log (binary, cel-json, cel-cbor, cel-tlv or tpm2-tools-yaml)")
+	flag.BoolVar(&tpm2ToolsYAML, "tpm2-tools-yaml", false, "Display the log in the YAML format produced by tpm2_eventlog")
+	flag.StringVar(&smbiosTablePath, "smbios-table", "", "Decode measured SMBIOS tables in verbose mode using the structure table at the specified path (eg, /sys/firmware/dmi/tables/DMI)")
+	flag.BoolVar(&resolvePCI, "resolve-pci", false, "Resolve the PCI devices that images were loaded from in verbose mode, using the sysfs PCI topology of this machine")
+	flag.StringVar(&pciIdsPath, "pci-ids", "/usr/share/misc/pci.ids", "Path of the PCI ID database used to name devices resolved with -resolve-pci")
+	flag.BoolVar(&pcrValues, "pcr-values", false, "Display the running value of the PCR after each event, computed by replaying the log")
+	flag.Var(&stages, "stage", "Display events measured during the specified boot stage (s-crtm, platform-firmware, option-roms, boot-manager, bootloader, kernel or os-present). Can be specified multiple times")
+	flag.BoolVar(&showStages, "show-stages", false, "Display the boot stage during which each event was measured")
+	flag.StringVar(&secureBootPolicy, "secure-boot-policy", "", "Display a summary of the secure boot policy measured to PCR 7 instead of the events (text or json)")
+	flag.BoolVar(&bootChain, "boot-chain", false, "Display the tree of images loaded during boot instead of the events")
+	flag.StringVar(&espPath, "esp", "", "Identify the images in the boot chain on the EFI system partition mounted at the specified path (used with -boot-chain)")
+}
+
+func readLog(r io.Reader, options *tcglog.LogOptions) (*tcglog.Log, error) {
+	var readCEL func(io.Reader) ([]*cel.Record, error)
+
+	switch inputFormat {
+	case "binary":
+		return tcglog.ParseLog(r, options)
+	case "cel-json":
+		readCEL = cel.ReadJSON
+	case "cel-cbor":
+		readCEL = cel.ReadCBOR
+	case "cel-tlv":
+		readCEL = cel.ReadTLV
+	case "tpm2-tools-yaml":
+		return tpm2tools.ReadYAML(r, options)
+	default:
+		return nil, fmt.Errorf("unrecognized input format \"%s\"", inputFormat)
+	}
+
+	records, err := readCEL(r)
+	if err != nil {
+		return nil, err
+	}
+	return cel.ToLog(records, options)
+}
+
+func writeSignatureDatabase(w io.Writer, data *tcglog.EFIVariableData) {
+	db, err := data.SignatureDatabase()
+	if err != nil {
+		fmt.Fprintf(w, "\n  Invalid signature database: %v", err)
+		return
+	}
+
+	for i, l := range db {
+		fmt.Fprintf(w, "\n  Signature list %d:", i)
+		for _, s := range l.Signatures {
+			fmt.Fprintf(w, "\n    %s", s)
+		}
+	}
+}
+
+func writeAuthority(w io.Writer, data *tcglog.EFIVariableData) {
+	sig, err := data.AuthoritySignature()
+	if err != nil {
+		fmt.Fprintf(w, "\n  Invalid authority: %v", err)
+		return
+	}
+	fmt.Fprintf(w, "\n  Authority: %s", sig)
+}
+
+func writeSbatLevel(w io.Writer, data *tcglog.EFIVariableData) {
+	level, err := data.SbatLevel()
+	if err != nil {
+		fmt.Fprintf(w, "\n  Invalid SBAT level: %v", err)
+		return
+	}
+	for _, e := range level {
+		fmt.Fprintf(w, "\n  SBAT: %s,%d", e.Component, e.Generation)
+		if e.Date != "" {
+			fmt.Fprintf(w, ",%s", e.Date)
+		}
+	}
+}
+
+func writeSecureBootState(w io.Writer, data *tcglog.EFIVariableData) {
+	state, err := data.SecureBootStateString()
+	if err != nil {
+		fmt.Fprintf(w, "\n  Invalid %s value: %v", data.UnicodeName, err)
+		return
+	}
+	fmt.Fprintf(w, "\n  %s: %s", data.UnicodeName, state)
+}
+
+func writeLoadOption(w io.Writer, data *tcglog.EFIVariableData) {
+	opt, err := data.LoadOption()
+	if err != nil {
+		fmt.Fprintf(w, "\n  Invalid load option: %v", err)
+		return
+	}
+	fmt.Fprintf(w, "\n  Load option: %s", opt)
+}
+
+func writeLoadOrder(w io.Writer, data *tcglog.EFIVariableData) {
+	order, err := data.LoadOrder()
+	if err != nil {
+		fmt.Fprintf(w, "\n  Invalid load order: %v", err)
+		return
+	}
+	fmt.Fprintf(w, "\n  %s: %s", data.UnicodeName, strings.Join(order, ", "))
+}
+
+func writeSMBIOSStructures(w io.Writer, structures []*tcglog.SMBIOSStructure) {
+	fmt.Fprintf(w, "\n  SMBIOS system info: %s", tcglog.GetSMBIOSSystemInfo(structures))
+	for _, s := range structures {
+		fmt.Fprintf(w, "\n    %s", s)
+	}
 }
 
 func shouldDisplayEvent(event *tcglog.Event) bool {
+	if len(stages) > 0 && !stages.Contains(event.Stage) {
+		return false
+	}
 	if len(pcrs) == 0 {
 		return true
 	}
@@ -55,6 +186,10 @@ func shouldDisplayEvent(event *tcglog.Event) bool {
 func main() {
 	flag.Parse()
 
+	if len(sdEfiStubPcrs) == 0 {
+		sdEfiStubPcrs = internal.PCRArgList{8}
+	}
+
 	algorithmId, err := internal.ParseAlgorithm(alg)
 	if err != nil {
 		fmt.Fprintf(os.Stderr, "%v\n", err)
@@ -80,25 +215,101 @@ func main() {
 		os.Exit(1)
 	}
 
-	log, err := tcglog.ParseLog(file, &tcglog.LogOptions{EnableGrub: withGrub, EnableSystemdEFIStub: withSdEfiStub, SystemdEFIStubPCR: tcglog.PCRIndex(sdEfiStubPcr)})
+	log, err := readLog(file, &tcglog.LogOptions{EnableGrub: withGrub, GrubCmdPCR: tcglog.PCRIndex(grubCmdPcr), GrubFilePCR: tcglog.PCRIndex(grubFilePcr), EnableSystemdEFIStub: withSdEfiStub, SystemdEFIStubPCRs: sdEfiStubPcrs, EnableSystemdUKI: withSdUKI})
 	if err != nil {
 		fmt.Fprintf(os.Stderr, "Failed to parse log file: %v\n", err)
 		os.Exit(1)
 	}
 
+	var smbiosStructures []*tcglog.SMBIOSStructure
+	if smbiosTablePath != "" {
+		data, err := ioutil.ReadFile(smbiosTablePath)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Failed to read SMBIOS table: %v\n", err)
+			os.Exit(1)
+		}
+		smbiosStructures, err = tcglog.DecodeSMBIOSStructureTable(data)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Failed to decode SMBIOS table: %v\n", err)
+			os.Exit(1)
+		}
+	}
+
+	var pciResolver *tcglog.PCIDeviceResolver
+	if resolvePCI {
+		pciResolver = &tcglog.PCIDeviceResolver{}
+		if f, err := os.Open(pciIdsPath); err == nil {
+			pciResolver.DB, err = tcglog.ParsePCIDatabase(f)
+			f.Close()
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "Failed to parse PCI ID database: %v\n", err)
+				os.Exit(1)
+			}
+		}
+	}
+
+	if tpm2ToolsYAML {
+		if err := tpm2tools.WriteYAML(os.Stdout, log); err != nil {
+			fmt.Fprintf(os.Stderr, "Failed to write YAML: %v\n", err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	switch secureBootPolicy {
+	case "":
+	case "text":
+		fmt.Println(log.SecureBootPolicy())
+		return
+	case "json":
+		if err := log.SecureBootPolicy().WriteJSON(os.Stdout); err != nil {
+			fmt.Fprintf(os.Stderr, "Failed to write secure boot policy: %v\n", err)
+			os.Exit(1)
+		}
+		return
+	default:
+		fmt.Fprintf(os.Stderr, "Unrecognized secure boot policy format \"%s\"\n", secureBootPolicy)
+		os.Exit(1)
+	}
+
+	if bootChain {
+		chain, err := log.BootChain(espPath)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Failed to reconstruct boot chain: %v\n", err)
+			os.Exit(1)
+		}
+		fmt.Println(chain)
+		return
+	}
+
 	if !log.Algorithms.Contains(algorithmId) {
 		fmt.Fprintf(os.Stderr,
 			"The log doesn't contain entries for the %s digest algorithm\n", algorithmId)
 		os.Exit(1)
 	}
 
-	for _, event := range log.Events {
+	var replayed []*tcglog.ReplayedEvent
+	if pcrValues {
+		replayed, err = log.ReplayEvents(algorithmId)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Failed to replay log: %v\n", err)
+			os.Exit(1)
+		}
+	}
+
+	for i, event := range log.Events {
 		if !shouldDisplayEvent(event) {
 			continue
 		}
 
 		var builder bytes.Buffer
 		fmt.Fprintf(&builder, "%2d %x %s", event.PCRIndex, event.Digests[algorithmId], event.EventType)
+		if replayed != nil {
+			fmt.Fprintf(&builder, " (PCR: %x)", replayed[i].PCRValue)
+		}
+		if showStages {
+			fmt.Fprintf(&builder, " (stage: %s)", event.Stage)
+		}
 		if verbose || hexDump {
 			data := event.Data.String()
 			if data != "" {
@@ -106,6 +317,35 @@ func main() {
 			}
 		}
 
+		if verbose {
+			if varData, ok := event.Data.(*tcglog.EFIVariableData); ok {
+				switch {
+				case varData.IsSbatLevel():
+					writeSbatLevel(&builder, varData)
+				case event.EventType == tcglog.EventTypeEFIVariableAuthority:
+					writeAuthority(&builder, varData)
+				case varData.IsSecureBootState():
+					writeSecureBootState(&builder, varData)
+				case varData.IsSignatureDatabase():
+					writeSignatureDatabase(&builder, varData)
+				case varData.IsLoadOption():
+					writeLoadOption(&builder, varData)
+				case varData.IsLoadOrder():
+					writeLoadOrder(&builder, varData)
+				}
+			}
+			if imageLoad, ok := event.Data.(*tcglog.EFIImageLoadEvent); ok && pciResolver != nil {
+				if name, err := pciResolver.Resolve(imageLoad.DevicePathData()); err == nil {
+					fmt.Fprintf(&builder, "\n  PCI device: %s", name)
+				}
+			}
+			if tables, ok := event.Data.(interface {
+				SMBIOSTable() *tcglog.EFIConfigurationTable
+			}); ok && smbiosStructures != nil && tables.SMBIOSTable() != nil {
+				writeSMBIOSStructures(&builder, smbiosStructures)
+			}
+		}
+
 		if hexDump {
 			fmt.Fprintf(&builder, "\n  Event data:\n  %s", strings.Replace(hex.Dump(event.Data.Bytes()), "\n", "\n  ", -1))
 		}
@@ -0,0 +1,57 @@
+// Copyright 2019 Canonical Ltd.
+// Licensed under the LGPLv3 with static-linking exception.
+// See LICENCE file for details.
+
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/canonical/tcglog-parser/corpus"
+)
+
+var (
+	outputDir string
+	kind      string
+)
+
+func init() {
+	flag.StringVar(&outputDir, "o", ".", "Directory in which to write the corpus")
+	flag.StringVar(&kind, "kind", "", "Only write logs of the specified kind (valid, edge or malformed)")
+}
+
+func main() {
+	flag.Parse()
+
+	if len(flag.Args()) > 0 {
+		fmt.Fprintf(os.Stderr, "Too many arguments\n")
+		os.Exit(1)
+	}
+
+	switch kind {
+	case "", corpus.Valid.String(), corpus.EdgeCase.String(), corpus.Malformed.String():
+	default:
+		fmt.Fprintf(os.Stderr, "Unrecognized kind \"%s\"\n", kind)
+		os.Exit(1)
+	}
+
+	if err := os.MkdirAll(outputDir, 0755); err != nil {
+		fmt.Fprintf(os.Stderr, "Cannot create output directory: %v\n", err)
+		os.Exit(1)
+	}
+
+	for _, e := range corpus.Generate() {
+		if kind != "" && e.Kind.String() != kind {
+			continue
+		}
+		path := filepath.Join(outputDir, fmt.Sprintf("%s-%s", e.Kind, e.Name))
+		if err := ioutil.WriteFile(path, e.Data, 0644); err != nil {
+			fmt.Fprintf(os.Stderr, "Cannot write %s: %v\n", path, err)
+			os.Exit(1)
+		}
+	}
+}
@@ -0,0 +1,203 @@
+// Copyright 2019 Canonical Ltd.
+// Licensed under the LGPLv3 with static-linking exception.
+// See LICENCE file for details.
+
+package main
+
+import (
+	"crypto"
+	"crypto/x509"
+	"encoding/hex"
+	"encoding/pem"
+	"errors"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+
+	"github.com/canonical/tcglog-parser"
+	"github.com/canonical/tcglog-parser/internal"
+)
+
+var (
+	alg        string
+	jsonOutput bool
+	sbomFormat string
+	sbomName   string
+	rim        bool
+	keylime    bool
+	keylimeIMA string
+	eat        bool
+	eatNonce   string
+	eatKey     string
+)
+
+func init() {
+	flag.StringVar(&alg, "alg", "sha256", "Name of the hash algorithm used to identify components")
+	flag.BoolVar(&jsonOutput, "json", false, "Write the inventory as JSON, including the digests of each component for every algorithm in the log")
+	flag.StringVar(&sbomFormat, "sbom", "", "Write the measured boot components as a software bill of materials in the specified format (cyclonedx or spdx)")
+	flag.StringVar(&sbomName, "sbom-name", "", "Name of the software bill of materials document (default \"measured-boot\")")
+	flag.BoolVar(&rim, "rim", false, "Write a reference integrity manifest (a SWID tag in the style of a TCG PC Client base RIM) describing the expected measurements in the log")
+	flag.BoolVar(&keylime, "keylime", false, "Write a Keylime measured boot reference state describing the expected measurements in the log")
+	flag.StringVar(&keylimeIMA, "keylime-runtime-policy", "", "Write a Keylime runtime policy that allows the files measured in the specified IMA log (binary format)")
+	flag.BoolVar(&eat, "eat", false, "Write an IETF entity attestation token (CBOR) containing the PCR values, boot chain and secure boot state derived from the log")
+	flag.StringVar(&eatNonce, "eat-nonce", "", "Hex encoded nonce to include in the entity attestation token")
+	flag.StringVar(&eatKey, "eat-key", "", "PEM encoded PKCS#8 private key file used to sign the entity attestation token (unsigned if empty)")
+}
+
+func readSigner(path string) (crypto.Signer, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, errors.New("no PEM data found")
+	}
+	key, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, err
+	}
+	signer, ok := key.(crypto.Signer)
+	if !ok {
+		return nil, fmt.Errorf("unsupported key type %T", key)
+	}
+	return signer, nil
+}
+
+func main() {
+	flag.Parse()
+
+	algorithmId, err := internal.ParseAlgorithm(alg)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		os.Exit(1)
+	}
+
+	if keylimeIMA != "" {
+		f, err := os.Open(keylimeIMA)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Failed to open IMA log: %v\n", err)
+			os.Exit(1)
+		}
+		defer f.Close()
+
+		imaLog, err := tcglog.ParseIMALog(f, nil)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Failed to parse IMA log: %v\n", err)
+			os.Exit(1)
+		}
+		if err := imaLog.WriteKeylimeRuntimePolicy(os.Stdout); err != nil {
+			fmt.Fprintf(os.Stderr, "Failed to write runtime policy: %v\n", err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	args := flag.Args()
+	if len(args) > 1 {
+		fmt.Fprintf(os.Stderr, "Too many arguments\n")
+		os.Exit(1)
+	}
+
+	var path string
+	if len(args) == 1 {
+		path = args[0]
+	} else {
+		path = "/sys/kernel/security/tpm0/binary_bios_measurements"
+	}
+
+	file, err := os.Open(path)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to open log file: %v\n", err)
+		os.Exit(1)
+	}
+	defer file.Close()
+
+	// The Keylime reference state includes the kernel, initrd and command line measured by GRUB.
+	log, err := tcglog.ParseLog(file, &tcglog.LogOptions{EnableGrub: keylime})
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to parse log file: %v\n", err)
+		os.Exit(1)
+	}
+
+	if sbomFormat != "" {
+		format, err := tcglog.ParseSBOMFormat(sbomFormat)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%v\n", err)
+			os.Exit(1)
+		}
+		if err := log.WriteSBOM(os.Stdout, &tcglog.SBOMOptions{Format: format, Name: sbomName}); err != nil {
+			fmt.Fprintf(os.Stderr, "Failed to write SBOM: %v\n", err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	if rim {
+		if err := log.WriteReferenceManifest(os.Stdout, &tcglog.ReferenceManifestOptions{}); err != nil {
+			fmt.Fprintf(os.Stderr, "Failed to write reference manifest: %v\n", err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	if eat {
+		options := &tcglog.EATOptions{}
+		if options.Nonce, err = hex.DecodeString(eatNonce); err != nil {
+			fmt.Fprintf(os.Stderr, "Invalid nonce: %v\n", err)
+			os.Exit(1)
+		}
+		if eatKey != "" {
+			if options.Signer, err = readSigner(eatKey); err != nil {
+				fmt.Fprintf(os.Stderr, "Failed to read signing key: %v\n", err)
+				os.Exit(1)
+			}
+		}
+		if err := log.WriteEAT(os.Stdout, options); err != nil {
+			fmt.Fprintf(os.Stderr, "Failed to write entity attestation token: %v\n", err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	if keylime {
+		if err := log.WriteKeylimeMeasuredBootPolicy(os.Stdout); err != nil {
+			fmt.Fprintf(os.Stderr, "Failed to write Keylime reference state: %v\n", err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	inventory := log.FirmwareInventory()
+
+	if jsonOutput {
+		if err := inventory.WriteJSON(os.Stdout); err != nil {
+			fmt.Fprintf(os.Stderr, "Failed to write inventory: %v\n", err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	if !log.Algorithms.Contains(algorithmId) {
+		fmt.Fprintf(os.Stderr, "The log doesn't contain entries for the %s digest algorithm\n", algorithmId)
+		os.Exit(1)
+	}
+
+	vendorName := inventory.Vendor
+	if vendorName == "" {
+		vendorName = "unknown"
+	}
+	version := inventory.Version
+	if version == "" {
+		version = "unknown"
+	}
+	fmt.Printf("Firmware vendor: %s\n", vendorName)
+	fmt.Printf("S-CRTM version: %s\n", tcglog.EscapeString(version))
+	fmt.Printf("Components: %d platform firmware, %d drivers, %d option ROMs, %d microcode\n",
+		inventory.Count(tcglog.FirmwareComponentPlatformFirmware), inventory.Count(tcglog.FirmwareComponentDriver),
+		inventory.Count(tcglog.FirmwareComponentOptionROM), inventory.Count(tcglog.FirmwareComponentMicrocode))
+	for _, c := range inventory.Components {
+		fmt.Printf("%x %s\n", c.Event.Digests[algorithmId], c)
+	}
+}
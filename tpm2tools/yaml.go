@@ -0,0 +1,251 @@
+// Copyright 2019 Canonical Ltd.
+// Licensed under the LGPLv3 with static-linking exception.
+// See LICENCE file for details.
+
+// Package tpm2tools implements conversion between the event logs supported by the tcglog package and the YAML format produced
+// by the tpm2_eventlog command from tpm2-tools.
+package tpm2tools
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/canonical/tcglog-parser"
+
+	"golang.org/x/xerrors"
+)
+
+func algorithmName(alg tcglog.AlgorithmId) string {
+	switch alg {
+	case tcglog.AlgorithmSha1:
+		return "sha1"
+	case tcglog.AlgorithmSha256:
+		return "sha256"
+	case tcglog.AlgorithmSha384:
+		return "sha384"
+	case tcglog.AlgorithmSha512:
+		return "sha512"
+	default:
+		return fmt.Sprintf("0x%04x", uint16(alg))
+	}
+}
+
+func formatGUID(guid tcglog.EFIGUID) string {
+	return strings.Trim(guid.String(), "{}")
+}
+
+// quoteString returns s in a form that can be used as a YAML scalar. Strings that could be misinterpreted are double-quoted.
+func quoteString(s string) string {
+	if s == "" {
+		return `""`
+	}
+	for i, r := range s {
+		switch {
+		case unicode.IsLetter(r), unicode.IsDigit(r):
+		case r == '_' || r == '/' || r == '.' || r == '-' && i > 0:
+		default:
+			return strconv.Quote(s)
+		}
+	}
+	switch s {
+	case "true", "false", "yes", "no", "null", "on", "off", "Yes", "No", "True", "False", "Null", "On", "Off", "~":
+		return strconv.Quote(s)
+	}
+	return s
+}
+
+// isPrintableBlock indicates whether s can be emitted as a literal block scalar.
+func isPrintableBlock(s string) bool {
+	if s == "" || strings.TrimSpace(s) != s {
+		return false
+	}
+	for _, r := range s {
+		if r != '\n' && !unicode.IsPrint(r) {
+			return false
+		}
+	}
+	return true
+}
+
+type yamlWriter struct {
+	w   io.Writer
+	err error
+}
+
+func (w *yamlWriter) printf(format string, args ...interface{}) {
+	if w.err != nil {
+		return
+	}
+	_, w.err = fmt.Fprintf(w.w, format, args...)
+}
+
+// writeString writes a string value for the specified key at the specified indentation level, using a literal block scalar in
+// the same way as tpm2_eventlog where possible.
+func (w *yamlWriter) writeString(indent, key, s string) {
+	if !isPrintableBlock(s) {
+		w.printf("%s%s: %s\n", indent, key, strconv.Quote(s))
+		return
+	}
+	w.printf("%s%s: |-\n", indent, key)
+	for _, line := range strings.Split(s, "\n") {
+		w.printf("%s  %s\n", indent, line)
+	}
+}
+
+func (w *yamlWriter) writeSpecIdEvent(d *tcglog.SpecIdEvent) {
+	w.printf("  SpecID:\n")
+	w.printf("  - Signature: %s\n", quoteString(d.Signature()))
+	w.printf("    platformClass: %d\n", d.PlatformClass)
+	w.printf("    specVersionMinor: %d\n", d.SpecVersionMinor)
+	w.printf("    specVersionMajor: %d\n", d.SpecVersionMajor)
+	w.printf("    specErrata: %d\n", d.SpecErrata)
+	w.printf("    uintnSize: %d\n", d.UintnSize)
+	if d.Spec == tcglog.SpecEFI_2 {
+		w.printf("    numberOfAlgorithms: %d\n", len(d.DigestSizes))
+		w.printf("    Algorithms:\n")
+		for i, s := range d.DigestSizes {
+			w.printf("    - Algorithm[%d]:\n", i)
+			w.printf("      algorithmId: %s\n", algorithmName(s.AlgorithmId))
+			w.printf("      digestSize: %d\n", s.DigestSize)
+		}
+	}
+	w.printf("    vendorInfoSize: %d\n", len(d.VendorInfo))
+	if len(d.VendorInfo) > 0 {
+		w.printf("    vendorInfo: \"%x\"\n", d.VendorInfo)
+	}
+}
+
+func (w *yamlWriter) writeEFIVariableData(d *tcglog.EFIVariableData) {
+	w.printf("  Event:\n")
+	w.printf("    VariableName: %s\n", formatGUID(d.VariableName))
+	w.printf("    UnicodeNameLength: %d\n", len([]rune(d.UnicodeName)))
+	w.printf("    VariableDataLength: %d\n", len(d.VariableData))
+	w.printf("    UnicodeName: %s\n", quoteString(d.UnicodeName))
+	w.printf("    VariableData: \"%x\"\n", d.VariableData)
+}
+
+// writeImageLoadEvent writes the UEFI_IMAGE_LOAD_EVENT structure in data. It returns false if the data is too short.
+func (w *yamlWriter) writeImageLoadEvent(data []byte) bool {
+	const hdrSize = 32
+	if len(data) < hdrSize {
+		return false
+	}
+	w.printf("  Event:\n")
+	w.printf("    ImageLocationInMemory: 0x%x\n", binary.LittleEndian.Uint64(data[0:]))
+	w.printf("    ImageLengthInMemory: %d\n", binary.LittleEndian.Uint64(data[8:]))
+	w.printf("    ImageLinkTimeAddress: 0x%x\n", binary.LittleEndian.Uint64(data[16:]))
+	w.printf("    LengthOfDevicePath: %d\n", binary.LittleEndian.Uint64(data[24:]))
+	w.printf("    DevicePath: '%x'\n", data[hdrSize:])
+	return true
+}
+
+func (w *yamlWriter) writeEvent(num int, e *tcglog.Event, algs tcglog.AlgorithmIdList) {
+	w.printf("- EventNum: %d\n", num)
+	w.printf("  PCRIndex: %d\n", e.PCRIndex)
+	w.printf("  EventType: %s\n", eventTypeName(e.EventType))
+
+	var buf bytes.Buffer
+	if err := e.Data.EncodeTo(&buf); err != nil {
+		if w.err == nil {
+			w.err = xerrors.Errorf("cannot encode data for event %d: %w", num, err)
+		}
+		return
+	}
+	data := buf.Bytes()
+
+	if d, ok := e.Data.(*tcglog.SpecIdEvent); ok && num == 0 {
+		w.printf("  Digest: \"%x\"\n", e.Digests[tcglog.AlgorithmSha1])
+		w.printf("  EventSize: %d\n", len(data))
+		w.writeSpecIdEvent(d)
+		return
+	}
+
+	w.printf("  DigestCount: %d\n", len(algs))
+	w.printf("  Digests:\n")
+	for _, alg := range algs {
+		w.printf("  - AlgorithmId: %s\n", algorithmName(alg))
+		w.printf("    Digest: \"%x\"\n", e.Digests[alg])
+	}
+	w.printf("  EventSize: %d\n", len(data))
+
+	if _, isErr := e.Data.(error); !isErr {
+		switch e.EventType {
+		case tcglog.EventTypeEFIVariableDriverConfig, tcglog.EventTypeEFIVariableBoot, tcglog.EventTypeEFIVariableAuthority:
+			if d, ok := e.Data.(*tcglog.EFIVariableData); ok && len(d.TrailingBytes()) == 0 {
+				w.writeEFIVariableData(d)
+				return
+			}
+		case tcglog.EventTypeEFIBootServicesApplication, tcglog.EventTypeEFIBootServicesDriver,
+			tcglog.EventTypeEFIRuntimeServicesDriver:
+			if w.writeImageLoadEvent(data) {
+				return
+			}
+		case tcglog.EventTypeAction, tcglog.EventTypeEFIAction:
+			if isPrintableBlock(string(data)) {
+				w.writeString("  ", "Event", string(data))
+				return
+			}
+		case tcglog.EventTypeIPL:
+			if s := string(data); isPrintableBlock(strings.TrimSuffix(s, "\x00")) && !strings.Contains(s[:len(s)-1], "\x00") {
+				w.printf("  Event:\n")
+				w.writeString("    ", "String", strings.TrimSuffix(s, "\x00"))
+				return
+			}
+		}
+	}
+
+	w.printf("  Event: \"%x\"\n", data)
+}
+
+func eventTypeName(t tcglog.EventType) string {
+	s := t.String()
+	if !strings.HasPrefix(s, "EV_") {
+		return fmt.Sprintf("0x%x", uint32(t))
+	}
+	return s
+}
+
+// WriteYAML writes the supplied log to w in the YAML format produced by tpm2_eventlog, including the final PCR values computed
+// by replaying the log.
+func WriteYAML(w io.Writer, log *tcglog.Log) error {
+	yw := &yamlWriter{w: w}
+
+	algs := make(tcglog.AlgorithmIdList, len(log.Algorithms))
+	copy(algs, log.Algorithms)
+	sort.Slice(algs, func(i, j int) bool { return algs[i] < algs[j] })
+
+	yw.printf("---\n")
+	yw.printf("version: 1\n")
+	yw.printf("events:\n")
+
+	for i, e := range log.Events {
+		yw.writeEvent(i, e, algs)
+	}
+
+	yw.printf("pcrs:\n")
+	for _, alg := range algs {
+		pcrValues, err := log.ReplayPCRs(alg)
+		if err != nil {
+			return xerrors.Errorf("cannot replay log: %w", err)
+		}
+
+		var pcrs []tcglog.PCRIndex
+		for pcr := range pcrValues {
+			pcrs = append(pcrs, pcr)
+		}
+		sort.Slice(pcrs, func(i, j int) bool { return pcrs[i] < pcrs[j] })
+
+		yw.printf("  %s:\n", algorithmName(alg))
+		for _, pcr := range pcrs {
+			yw.printf("    %-2d : 0x%s\n", pcr, strings.ToUpper(fmt.Sprintf("%x", pcrValues[pcr])))
+		}
+	}
+
+	return yw.err
+}
@@ -0,0 +1,262 @@
+// Copyright 2019 Canonical Ltd.
+// Licensed under the LGPLv3 with static-linking exception.
+// See LICENCE file for details.
+
+package tpm2tools
+
+import (
+	"bytes"
+	"crypto/sha1"
+	"crypto/sha256"
+	"encoding/binary"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/canonical/tcglog-parser"
+)
+
+func makeEvent(pcr tcglog.PCRIndex, eventType tcglog.EventType, data []byte) *tcglog.Event {
+	sha1Digest := sha1.Sum(data)
+	sha256Digest := sha256.Sum256(data)
+	digests := tcglog.DigestMap{tcglog.AlgorithmSha1: sha1Digest[:], tcglog.AlgorithmSha256: sha256Digest[:]}
+	return &tcglog.Event{
+		PCRIndex:  pcr,
+		EventType: eventType,
+		Digests:   digests,
+		Data:      tcglog.DecodeEventData(pcr, eventType, digests, data, nil)}
+}
+
+func TestWriteYAML(t *testing.T) {
+	log := &tcglog.Log{
+		Spec:       tcglog.SpecEFI_2,
+		Algorithms: tcglog.AlgorithmIdList{tcglog.AlgorithmSha256, tcglog.AlgorithmSha1},
+		Events: []*tcglog.Event{
+			makeEvent(4, tcglog.EventTypeEFIAction, []byte("Calling EFI Application from Boot Option")),
+			makeEvent(7, tcglog.EventTypeSeparator, []byte{0, 0, 0, 0}),
+			makeEvent(8, tcglog.EventTypeIPL, []byte("grub_cmd: linux /vmlinuz\x00"))}}
+
+	var out bytes.Buffer
+	if err := WriteYAML(&out, log); err != nil {
+		t.Fatalf("WriteYAML failed: %v", err)
+	}
+
+	sepDigest := sha256.Sum256([]byte{0, 0, 0, 0})
+	h := sha256.New()
+	h.Write(make([]byte, 32))
+	h.Write(sepDigest[:])
+
+	for _, expected := range []string{
+		"---\nversion: 1\nevents:\n- EventNum: 0\n  PCRIndex: 4\n  EventType: EV_EFI_ACTION\n  DigestCount: 2\n  Digests:\n" +
+			"  - AlgorithmId: sha1\n",
+		"  EventSize: 40\n  Event: |-\n    Calling EFI Application from Boot Option\n",
+		fmt.Sprintf("  - AlgorithmId: sha256\n    Digest: \"%x\"\n  EventSize: 4\n  Event: \"00000000\"\n", sepDigest),
+		"  EventType: EV_IPL\n",
+		"  Event:\n    String: |-\n      grub_cmd: linux /vmlinuz\n",
+		"pcrs:\n  sha1:\n    4  : 0x",
+		"  sha256:\n    4  : 0x",
+		fmt.Sprintf("    7  : 0x%s\n", strings.ToUpper(fmt.Sprintf("%x", h.Sum(nil)))),
+	} {
+		if !strings.Contains(out.String(), expected) {
+			t.Errorf("Output doesn't contain %q:\n%s", expected, out.String())
+		}
+	}
+}
+
+func TestWriteYAMLStartupLocality(t *testing.T) {
+	log := &tcglog.Log{
+		Spec:       tcglog.SpecEFI_2,
+		Algorithms: tcglog.AlgorithmIdList{tcglog.AlgorithmSha256},
+		Events: []*tcglog.Event{
+			makeEvent(0, tcglog.EventTypeNoAction, append([]byte("StartupLocality\x00"), 3)),
+			makeEvent(0, tcglog.EventTypeSCRTMVersion, []byte{0x31, 0x00})}}
+
+	var out bytes.Buffer
+	if err := WriteYAML(&out, log); err != nil {
+		t.Fatalf("WriteYAML failed: %v", err)
+	}
+
+	initial := make([]byte, 32)
+	initial[31] = 3
+	versionDigest := sha256.Sum256([]byte{0x31, 0x00})
+	h := sha256.New()
+	h.Write(initial)
+	h.Write(versionDigest[:])
+
+	expected := fmt.Sprintf("pcrs:\n  sha256:\n    0  : 0x%s\n", strings.ToUpper(fmt.Sprintf("%x", h.Sum(nil))))
+	if !strings.Contains(out.String(), expected) {
+		t.Errorf("Output doesn't contain %q:\n%s", expected, out.String())
+	}
+}
+
+func makeSpecIdEvent() *tcglog.Event {
+	var data bytes.Buffer
+	var sig [16]byte
+	copy(sig[:], "Spec ID Event03")
+	data.Write(sig[:])
+	binary.Write(&data, binary.LittleEndian, uint32(0))
+	data.Write([]byte{0, 2, 0, 2})
+	binary.Write(&data, binary.LittleEndian, uint32(2))
+	binary.Write(&data, binary.LittleEndian, []uint16{uint16(tcglog.AlgorithmSha1), 20, uint16(tcglog.AlgorithmSha256), 32})
+	data.WriteByte(0)
+
+	digests := tcglog.DigestMap{tcglog.AlgorithmSha1: make(tcglog.Digest, 20)}
+	return &tcglog.Event{
+		EventType: tcglog.EventTypeNoAction,
+		Digests:   digests,
+		Data:      tcglog.DecodeEventData(0, tcglog.EventTypeNoAction, digests, data.Bytes(), nil)}
+}
+
+func TestReadYAMLRoundTrip(t *testing.T) {
+	var varData bytes.Buffer
+	guid := tcglog.MakeEFIGUID(0x8be4df61, 0x93ca, 0x11d2, 0xaa0d, [...]uint8{0x00, 0xe0, 0x98, 0x03, 0x2b, 0x8c})
+	varData.Write(guid[:])
+	binary.Write(&varData, binary.LittleEndian, uint64(10))
+	binary.Write(&varData, binary.LittleEndian, uint64(1))
+	for _, c := range "SecureBoot" {
+		binary.Write(&varData, binary.LittleEndian, uint16(c))
+	}
+	varData.WriteByte(1)
+
+	var imageData bytes.Buffer
+	binary.Write(&imageData, binary.LittleEndian, []uint64{0x7c000000, 0x1000, 0, 4})
+	imageData.Write([]byte{0x7f, 0xff, 0x04, 0x00})
+
+	events := []*tcglog.Event{
+		makeSpecIdEvent(),
+		makeEvent(0, tcglog.EventTypeSCRTMVersion, []byte{0x31, 0x00, 0x00, 0x00}),
+		makeEvent(7, tcglog.EventTypeEFIVariableDriverConfig, varData.Bytes()),
+		makeEvent(4, tcglog.EventTypeEFIBootServicesApplication, imageData.Bytes()),
+		makeEvent(4, tcglog.EventTypeEFIAction, []byte("Calling EFI Application from Boot Option")),
+		makeEvent(8, tcglog.EventTypeIPL, []byte("grub_cmd: linux /vmlinuz\x00")),
+		makeEvent(0, tcglog.EventTypePostCode, []byte{1, 2, 3})}
+	log := tcglog.NewLog(events)
+
+	var out bytes.Buffer
+	if err := WriteYAML(&out, log); err != nil {
+		t.Fatalf("WriteYAML failed: %v", err)
+	}
+
+	log2, err := ReadYAML(&out, nil)
+	if err != nil {
+		t.Fatalf("ReadYAML failed: %v", err)
+	}
+
+	if log2.Spec != tcglog.SpecEFI_2 {
+		t.Errorf("Unexpected spec: %v", log2.Spec)
+	}
+	if len(log2.Algorithms) != 2 {
+		t.Errorf("Unexpected algorithms: %v", log2.Algorithms)
+	}
+	if len(log2.Events) != len(log.Events) {
+		t.Fatalf("Unexpected number of events (%d)", len(log2.Events))
+	}
+	for i, e := range log2.Events {
+		orig := log.Events[i]
+		if e.PCRIndex != orig.PCRIndex || e.EventType != orig.EventType || e.Index != orig.Index {
+			t.Errorf("Unexpected event %d: %d %v %d", i, e.PCRIndex, e.EventType, e.Index)
+		}
+		for _, alg := range log.Algorithms {
+			if !bytes.Equal(e.Digests[alg], orig.Digests[alg]) {
+				t.Errorf("Unexpected %v digest for event %d: %x", alg, i, e.Digests[alg])
+			}
+		}
+		if _, isErr := e.Data.(error); isErr {
+			t.Errorf("Unexpected error for event %d: %v", i, e.Data)
+		}
+		if !bytes.Equal(e.Data.Bytes(), orig.Data.Bytes()) {
+			t.Errorf("Unexpected data for event %d: %x", i, e.Data.Bytes())
+		}
+	}
+}
+
+func TestReadYAMLFromTPM2Tools(t *testing.T) {
+	data := `---
+version: 1
+events:
+- EventNum: 0
+  PCRIndex: 0
+  EventType: EV_NO_ACTION
+  Digest: "0000000000000000000000000000000000000000"
+  EventSize: 33
+  SpecID:
+  - Signature: Spec ID Event03
+    platformClass: 0
+    specVersionMinor: 0
+    specVersionMajor: 2
+    specErrata: 0
+    uintnSize: 2
+    numberOfAlgorithms: 1
+    Algorithms:
+      - Algorithm[0]:
+        algorithmId: sha256
+        digestSize: 32
+    vendorInfoSize: 0
+- EventNum: 1
+  PCRIndex: 7
+  EventType: EV_EFI_VARIABLE_DRIVER_CONFIG
+  DigestCount: 1
+  Digests:
+  - AlgorithmId: sha256
+    Digest: "ccfc4bb32888a345bc8aeadaba552b627d99348c767681ab3141f5b01e40a40e"
+  EventSize: 53
+  Event:
+    VariableName: 8be4df61-93ca-11d2-aa0d-00e098032b8c
+    UnicodeNameLength: 10
+    VariableDataLength: 1
+    UnicodeName: SecureBoot
+    VariableData:
+      Enabled: 'Yes'
+- EventNum: 2
+  PCRIndex: 4
+  EventType: EV_EFI_ACTION
+  DigestCount: 1
+  Digests:
+    - AlgorithmId: sha256
+      Digest: "3d6772b4f84ed47595d72a2c4c5ffd15f5bb72c7507fe26f2aaee2c69d5633ba"
+  EventSize: 40
+  Event: |-
+    Calling EFI Application from Boot Option
+pcrs:
+  sha256:
+    4  : 0x1111111111111111111111111111111111111111111111111111111111111111
+`
+
+	log, err := ReadYAML(strings.NewReader(data), nil)
+	if err != nil {
+		t.Fatalf("ReadYAML failed: %v", err)
+	}
+	if log.Spec != tcglog.SpecEFI_2 || len(log.Algorithms) != 1 || log.Algorithms[0] != tcglog.AlgorithmSha256 {
+		t.Errorf("Unexpected spec or algorithms: %v %v", log.Spec, log.Algorithms)
+	}
+	if len(log.Events) != 3 {
+		t.Fatalf("Unexpected number of events (%d)", len(log.Events))
+	}
+
+	if _, isErr := log.Events[1].Data.(error); !isErr {
+		t.Errorf("Data for event 1 should not be available")
+	}
+	if log.Events[1].Digests[tcglog.AlgorithmSha256][0] != 0xcc {
+		t.Errorf("Unexpected digest for event 1")
+	}
+
+	if string(log.Events[2].Data.Bytes()) != "Calling EFI Application from Boot Option" {
+		t.Errorf("Unexpected data for event 2: %q", log.Events[2].Data.Bytes())
+	}
+	expected := sha256.Sum256(log.Events[2].Data.Bytes())
+	if !bytes.Equal(log.Events[2].Digests[tcglog.AlgorithmSha256], expected[:]) {
+		t.Errorf("Unexpected digest for event 2")
+	}
+}
+
+func TestEventTypeName(t *testing.T) {
+	if name := eventTypeName(tcglog.EventTypeEFIGPTEvent); name != "EV_EFI_GPT_EVENT" {
+		t.Errorf("Unexpected name: %s", name)
+	}
+	if eventTypes["EV_EFI_GPT_EVENT"] != tcglog.EventTypeEFIGPTEvent {
+		t.Errorf("EV_EFI_GPT_EVENT is not recognized")
+	}
+	if name := eventTypeName(0x800000ff); name != "0x800000ff" {
+		t.Errorf("Unexpected name: %s", name)
+	}
+}
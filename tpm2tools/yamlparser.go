@@ -0,0 +1,447 @@
+// Copyright 2019 Canonical Ltd.
+// Licensed under the LGPLv3 with static-linking exception.
+// See LICENCE file for details.
+
+package tpm2tools
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// This file implements a parser for the subset of YAML that is produced by tpm2_eventlog: block mappings and sequences, plain
+// and quoted scalars, and literal or folded block scalars. Flow collections, anchors, tags and multi-line flow scalars are not
+// supported.
+
+type yamlNodeKind int
+
+const (
+	yamlScalar yamlNodeKind = iota
+	yamlMapping
+	yamlSequence
+)
+
+type yamlMapEntry struct {
+	key   string
+	value *yamlNode
+}
+
+// yamlNode corresponds to a node in a YAML document. A null value is represented by a nil *yamlNode.
+type yamlNode struct {
+	kind    yamlNodeKind
+	value   string
+	entries []yamlMapEntry
+	items   []*yamlNode
+}
+
+// get returns the value associated with the specified key if this is a mapping node.
+func (n *yamlNode) get(key string) *yamlNode {
+	if n == nil || n.kind != yamlMapping {
+		return nil
+	}
+	for _, e := range n.entries {
+		if e.key == key {
+			return e.value
+		}
+	}
+	return nil
+}
+
+// has indicates whether this is a mapping node that contains the specified key.
+func (n *yamlNode) has(key string) bool {
+	if n == nil || n.kind != yamlMapping {
+		return false
+	}
+	for _, e := range n.entries {
+		if e.key == key {
+			return true
+		}
+	}
+	return false
+}
+
+// scalar returns the value of this node if it is a scalar node.
+func (n *yamlNode) scalar() (string, bool) {
+	if n == nil || n.kind != yamlScalar {
+		return "", false
+	}
+	return n.value, true
+}
+
+type yamlLine struct {
+	num    int
+	indent int
+	text   string // the line with the indentation removed
+	raw    string
+}
+
+type yamlParser struct {
+	lines []yamlLine
+	pos   int
+}
+
+func (p *yamlParser) errorf(format string, args ...interface{}) error {
+	num := len(p.lines)
+	if p.pos < len(p.lines) {
+		num = p.lines[p.pos].num
+	}
+	return fmt.Errorf("line %d: %s", num, fmt.Sprintf(format, args...))
+}
+
+// skipBlank advances past blank lines, comments and document markers and indicates whether there is another line.
+func (p *yamlParser) skipBlank() bool {
+	for ; p.pos < len(p.lines); p.pos++ {
+		t := p.lines[p.pos].text
+		if t != "" && !strings.HasPrefix(t, "#") && t != "---" && t != "..." {
+			return true
+		}
+	}
+	return false
+}
+
+func isSequenceEntry(text string) bool {
+	return text == "-" || strings.HasPrefix(text, "- ")
+}
+
+// splitKey splits a mapping entry in to its key and the remaining text.
+func splitKey(text string) (key, rest string, ok bool) {
+	if strings.HasPrefix(text, "\"") || strings.HasPrefix(text, "'") {
+		k, n, err := parseQuoted(text)
+		if err != nil {
+			return "", "", false
+		}
+		text = text[n:]
+		if !strings.HasPrefix(text, ":") || (len(text) > 1 && text[1] != ' ') {
+			return "", "", false
+		}
+		return k, strings.TrimSpace(text[1:]), true
+	}
+
+	if i := strings.Index(text, ": "); i >= 0 {
+		return strings.TrimSpace(text[:i]), strings.TrimSpace(text[i+2:]), true
+	}
+	if strings.HasSuffix(text, ":") {
+		return strings.TrimSpace(text[:len(text)-1]), "", true
+	}
+	return "", "", false
+}
+
+var yamlEscapes = map[byte]string{
+	'0': "\x00", 'a': "\a", 'b': "\b", 't': "\t", '\t': "\t", 'n': "\n", 'v': "\v", 'f': "\f", 'r': "\r", 'e': "\x1b", ' ': " ",
+	'"': "\"", '/': "/", '\\': "\\", 'N': "\u0085", '_': "\u00a0", 'L': "\u2028", 'P': "\u2029"}
+
+// parseQuoted parses the quoted scalar at the start of s, returning its value and the number of bytes consumed.
+func parseQuoted(s string) (string, int, error) {
+	if strings.HasPrefix(s, "'") {
+		var out strings.Builder
+		for i := 1; i < len(s); i++ {
+			if s[i] != '\'' {
+				out.WriteByte(s[i])
+				continue
+			}
+			if i+1 < len(s) && s[i+1] == '\'' {
+				out.WriteByte('\'')
+				i++
+				continue
+			}
+			return out.String(), i + 1, nil
+		}
+		return "", 0, errors.New("unterminated single-quoted scalar")
+	}
+
+	var out strings.Builder
+	for i := 1; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case c == '"':
+			return out.String(), i + 1, nil
+		case c != '\\':
+			out.WriteByte(c)
+			continue
+		case i+1 >= len(s):
+			return "", 0, errors.New("unterminated escape sequence")
+		}
+
+		i++
+		if r, ok := yamlEscapes[s[i]]; ok {
+			out.WriteString(r)
+			continue
+		}
+
+		var n int
+		switch s[i] {
+		case 'x':
+			n = 2
+		case 'u':
+			n = 4
+		case 'U':
+			n = 8
+		default:
+			return "", 0, fmt.Errorf("invalid escape sequence \\%c", s[i])
+		}
+		if i+n >= len(s) {
+			return "", 0, errors.New("unterminated escape sequence")
+		}
+		v, err := strconv.ParseUint(s[i+1:i+1+n], 16, 32)
+		if err != nil {
+			return "", 0, fmt.Errorf("invalid escape sequence: %v", err)
+		}
+		if n == 2 {
+			out.WriteByte(byte(v))
+		} else {
+			if !utf8.ValidRune(rune(v)) {
+				return "", 0, fmt.Errorf("invalid code point 0x%x", v)
+			}
+			out.WriteRune(rune(v))
+		}
+		i += n
+	}
+	return "", 0, errors.New("unterminated double-quoted scalar")
+}
+
+func parseScalar(text string) (*yamlNode, error) {
+	if strings.HasPrefix(text, "\"") || strings.HasPrefix(text, "'") {
+		v, n, err := parseQuoted(text)
+		if err != nil {
+			return nil, err
+		}
+		if rest := strings.TrimSpace(text[n:]); rest != "" && !strings.HasPrefix(rest, "#") {
+			return nil, fmt.Errorf("unexpected characters after quoted scalar: %q", rest)
+		}
+		return &yamlNode{kind: yamlScalar, value: v}, nil
+	}
+
+	if i := strings.Index(text, " #"); i >= 0 {
+		text = text[:i]
+	}
+	text = strings.TrimSpace(text)
+	switch {
+	case text == "~" || text == "null":
+		return nil, nil
+	case strings.HasPrefix(text, "[") || strings.HasPrefix(text, "{"):
+		return nil, errors.New("flow collections are not supported")
+	}
+	return &yamlNode{kind: yamlScalar, value: text}, nil
+}
+
+// parseBlockScalar parses a literal or folded block scalar with the specified header, for an entry at the specified
+// indentation level.
+func (p *yamlParser) parseBlockScalar(indent int, header string) (*yamlNode, error) {
+	style := header[0]
+	chomp := strings.TrimLeft(header[1:], "0123456789")
+	if chomp != "" && chomp != "-" && chomp != "+" {
+		return nil, p.errorf("unsupported block scalar header %q", header)
+	}
+
+	var lines []string
+	blockIndent := -1
+	for ; p.pos < len(p.lines); p.pos++ {
+		l := p.lines[p.pos]
+		if l.text == "" {
+			lines = append(lines, "")
+			continue
+		}
+		if l.indent <= indent {
+			break
+		}
+		if blockIndent < 0 {
+			blockIndent = l.indent
+		}
+		if l.indent < blockIndent {
+			return nil, p.errorf("inconsistent indentation in block scalar")
+		}
+		lines = append(lines, l.raw[blockIndent:])
+	}
+
+	var trailing int
+	for trailing < len(lines) && lines[len(lines)-1-trailing] == "" {
+		trailing++
+	}
+	body := lines[:len(lines)-trailing]
+
+	var value string
+	if style == '|' {
+		value = strings.Join(body, "\n")
+	} else {
+		var b strings.Builder
+		for i, line := range body {
+			switch {
+			case i == 0:
+			case line == "" || body[i-1] == "":
+				b.WriteString("\n")
+			default:
+				b.WriteString(" ")
+			}
+			b.WriteString(line)
+		}
+		value = b.String()
+	}
+
+	switch {
+	case len(body) == 0:
+	case chomp == "":
+		value += "\n"
+	case chomp == "+":
+		value += strings.Repeat("\n", trailing+1)
+	}
+	return &yamlNode{kind: yamlScalar, value: value}, nil
+}
+
+// parseValue parses the value of a mapping entry or sequence item at the specified indentation level, where text is the
+// remaining text on the line after the key or sequence indicator.
+func (p *yamlParser) parseValue(indent int, text string) (*yamlNode, error) {
+	if text != "" {
+		if text[0] == '|' || text[0] == '>' {
+			return p.parseBlockScalar(indent, text)
+		}
+		return parseScalar(text)
+	}
+
+	if !p.skipBlank() {
+		return nil, nil
+	}
+	l := p.lines[p.pos]
+	switch {
+	case l.indent > indent:
+		return p.parseNode(l.indent)
+	case l.indent == indent && isSequenceEntry(l.text):
+		// Sequences may appear at the same indentation level as their parent key.
+		return p.parseSequence(indent)
+	default:
+		return nil, nil
+	}
+}
+
+func (p *yamlParser) parseSequence(indent int) (*yamlNode, error) {
+	n := &yamlNode{kind: yamlSequence}
+	for p.skipBlank() {
+		l := p.lines[p.pos]
+		if l.indent < indent || (l.indent == indent && !isSequenceEntry(l.text)) {
+			break
+		}
+		if l.indent > indent {
+			return nil, p.errorf("unexpected indentation")
+		}
+
+		rest := strings.TrimLeft(l.text[1:], " ")
+		if rest == "" {
+			p.pos++
+			if !p.skipBlank() || p.lines[p.pos].indent <= indent {
+				n.items = append(n.items, nil)
+				continue
+			}
+			item, err := p.parseNode(p.lines[p.pos].indent)
+			if err != nil {
+				return nil, err
+			}
+			n.items = append(n.items, item)
+			continue
+		}
+
+		// The item starts on the same line as the sequence indicator. If it is a collection, treat the remainder of
+		// the line as though it were on a line of its own.
+		contentIndent := l.indent + len(l.text) - len(rest)
+		if _, _, isMapping := splitKey(rest); isMapping || isSequenceEntry(rest) {
+			p.lines[p.pos] = yamlLine{num: l.num, indent: contentIndent, text: rest, raw: strings.Repeat(" ", contentIndent) + rest}
+			item, err := p.parseNode(contentIndent)
+			if err != nil {
+				return nil, err
+			}
+			n.items = append(n.items, item)
+			continue
+		}
+
+		p.pos++
+		item, err := p.parseValue(indent, rest)
+		if err != nil {
+			return nil, err
+		}
+		n.items = append(n.items, item)
+	}
+	return n, nil
+}
+
+func (p *yamlParser) parseMapping(indent int) (*yamlNode, error) {
+	n := &yamlNode{kind: yamlMapping}
+	for p.skipBlank() {
+		l := p.lines[p.pos]
+		if l.indent < indent || (l.indent == indent && isSequenceEntry(l.text)) {
+			break
+		}
+		if l.indent > indent {
+			return nil, p.errorf("unexpected indentation")
+		}
+
+		key, rest, ok := splitKey(l.text)
+		if !ok {
+			return nil, p.errorf("expected a mapping entry")
+		}
+		p.pos++
+		value, err := p.parseValue(indent, rest)
+		if err != nil {
+			return nil, err
+		}
+		n.entries = append(n.entries, yamlMapEntry{key: key, value: value})
+	}
+	return n, nil
+}
+
+func (p *yamlParser) parseNode(indent int) (*yamlNode, error) {
+	if !p.skipBlank() {
+		return nil, nil
+	}
+	l := p.lines[p.pos]
+	if isSequenceEntry(l.text) {
+		return p.parseSequence(indent)
+	}
+	if _, _, ok := splitKey(l.text); ok {
+		return p.parseMapping(indent)
+	}
+	p.pos++
+	return p.parseValue(indent, l.text)
+}
+
+// parseYAML parses the first document in the supplied data.
+func parseYAML(data []byte) (*yamlNode, error) {
+	p := &yamlParser{}
+	for i, raw := range strings.Split(string(data), "\n") {
+		raw = strings.TrimSuffix(raw, "\r")
+		text := strings.TrimLeft(raw, " ")
+		if strings.HasPrefix(text, "\t") {
+			return nil, fmt.Errorf("line %d: tabs cannot be used for indentation", i+1)
+		}
+		p.lines = append(p.lines, yamlLine{num: i + 1, indent: len(raw) - len(text), text: strings.TrimRight(text, " \t"), raw: raw})
+	}
+
+	// Only the first document is parsed.
+	for i, l := range p.lines {
+		if l.indent == 0 && (l.text == "---" || l.text == "...") && i > 0 {
+			var seenContent bool
+			for _, l2 := range p.lines[:i] {
+				if l2.text != "" && !strings.HasPrefix(l2.text, "#") && l2.text != "---" {
+					seenContent = true
+					break
+				}
+			}
+			if seenContent {
+				p.lines = p.lines[:i]
+				break
+			}
+		}
+	}
+
+	if !p.skipBlank() {
+		return nil, nil
+	}
+	n, err := p.parseNode(p.lines[p.pos].indent)
+	if err != nil {
+		return nil, err
+	}
+	if p.skipBlank() {
+		return nil, p.errorf("unexpected content")
+	}
+	return n, nil
+}
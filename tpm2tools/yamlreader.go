@@ -0,0 +1,400 @@
+// Copyright 2019 Canonical Ltd.
+// Licensed under the LGPLv3 with static-linking exception.
+// See LICENCE file for details.
+
+package tpm2tools
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"strconv"
+	"strings"
+	"unicode/utf16"
+
+	"github.com/canonical/tcglog-parser"
+
+	"golang.org/x/xerrors"
+)
+
+// unavailableEventData corresponds to the data for an event that could not be reconstructed from the YAML, because it was
+// either omitted or only recorded in a decoded form that doesn't preserve the original bytes.
+type unavailableEventData struct {
+	reason string
+}
+
+func (d *unavailableEventData) String() string {
+	return fmt.Sprintf("Unavailable event data: %s", d.reason)
+}
+
+func (d *unavailableEventData) Bytes() []byte {
+	return nil
+}
+
+func (d *unavailableEventData) EncodeTo(w io.Writer) error {
+	return fmt.Errorf("event data is unavailable: %s", d.reason)
+}
+
+func (d *unavailableEventData) Error() string {
+	return d.reason
+}
+
+var eventTypes = func() map[string]tcglog.EventType {
+	m := make(map[string]tcglog.EventType)
+	add := func(start, end uint32) {
+		for t := start; t <= end; t++ {
+			if name := eventTypeName(tcglog.EventType(t)); strings.HasPrefix(name, "EV_") {
+				m[name] = tcglog.EventType(t)
+			}
+		}
+	}
+	add(0, 0x20)
+	add(0x80000000, 0x800000ff)
+	return m
+}()
+
+func parseUint(n *yamlNode, bits int) (uint64, error) {
+	s, ok := n.scalar()
+	if !ok {
+		return 0, errors.New("expected a scalar")
+	}
+	return strconv.ParseUint(s, 0, bits)
+}
+
+func parseHex(n *yamlNode) ([]byte, error) {
+	s, ok := n.scalar()
+	if !ok {
+		return nil, errors.New("expected a scalar")
+	}
+	return hex.DecodeString(strings.TrimPrefix(s, "0x"))
+}
+
+func parseAlgorithm(n *yamlNode) (tcglog.AlgorithmId, error) {
+	s, ok := n.scalar()
+	if !ok {
+		return 0, errors.New("expected a scalar")
+	}
+	name := strings.TrimPrefix(strings.ToLower(s), "tpm2_alg_")
+	for _, alg := range []tcglog.AlgorithmId{tcglog.AlgorithmSha1, tcglog.AlgorithmSha256, tcglog.AlgorithmSha384,
+		tcglog.AlgorithmSha512} {
+		if algorithmName(alg) == name {
+			return alg, nil
+		}
+	}
+	alg, err := strconv.ParseUint(s, 0, 16)
+	if err != nil {
+		return 0, fmt.Errorf("unrecognized algorithm \"%s\"", s)
+	}
+	return tcglog.AlgorithmId(alg), nil
+}
+
+func parseEventType(n *yamlNode) (tcglog.EventType, error) {
+	s, ok := n.scalar()
+	if !ok {
+		return 0, errors.New("expected a scalar")
+	}
+	if t, ok := eventTypes[s]; ok {
+		return t, nil
+	}
+	t, err := strconv.ParseUint(s, 0, 32)
+	if err != nil {
+		return 0, fmt.Errorf("unrecognized event type \"%s\"", s)
+	}
+	return tcglog.EventType(t), nil
+}
+
+func parseGUID(n *yamlNode) (out tcglog.EFIGUID, err error) {
+	s, ok := n.scalar()
+	if !ok {
+		return out, errors.New("expected a scalar")
+	}
+	var a uint32
+	var b, c, d uint16
+	var e uint64
+	if _, err := fmt.Sscanf(strings.Trim(s, "{}"), "%08x-%04x-%04x-%04x-%012x", &a, &b, &c, &d, &e); err != nil {
+		return out, fmt.Errorf("invalid GUID \"%s\": %v", s, err)
+	}
+	var node [8]uint8
+	binary.BigEndian.PutUint64(node[:], e)
+	var nodeId [6]uint8
+	copy(nodeId[:], node[2:])
+	return tcglog.MakeEFIGUID(a, b, c, d, nodeId), nil
+}
+
+func reconstructSpecIdEvent(n *yamlNode) ([]byte, error) {
+	if n == nil || n.kind != yamlSequence || len(n.items) != 1 {
+		return nil, errors.New("expected a sequence with a single entry")
+	}
+	n = n.items[0]
+
+	signature, _ := n.get("Signature").scalar()
+	if len(signature) >= 16 {
+		return nil, fmt.Errorf("invalid signature \"%s\"", signature)
+	}
+
+	var out bytes.Buffer
+	var sig [16]byte
+	copy(sig[:], signature)
+	out.Write(sig[:])
+
+	for _, f := range []struct {
+		key  string
+		bits int
+	}{{"platformClass", 32}, {"specVersionMinor", 8}, {"specVersionMajor", 8}, {"specErrata", 8}, {"uintnSize", 8}} {
+		v, err := parseUint(n.get(f.key), f.bits)
+		if err != nil {
+			return nil, xerrors.Errorf("cannot parse %s: %w", f.key, err)
+		}
+		switch f.bits {
+		case 32:
+			binary.Write(&out, binary.LittleEndian, uint32(v))
+		default:
+			out.WriteByte(uint8(v))
+		}
+	}
+
+	if signature == "Spec ID Event03" {
+		algs := n.get("Algorithms")
+		if algs == nil || algs.kind != yamlSequence {
+			return nil, errors.New("missing Algorithms")
+		}
+		binary.Write(&out, binary.LittleEndian, uint32(len(algs.items)))
+		for i, a := range algs.items {
+			alg, err := parseAlgorithm(a.get("algorithmId"))
+			if err != nil {
+				return nil, xerrors.Errorf("cannot parse algorithm %d: %w", i, err)
+			}
+			size, err := parseUint(a.get("digestSize"), 16)
+			if err != nil {
+				return nil, xerrors.Errorf("cannot parse digest size for algorithm %d: %w", i, err)
+			}
+			binary.Write(&out, binary.LittleEndian, uint16(alg))
+			binary.Write(&out, binary.LittleEndian, uint16(size))
+		}
+	}
+
+	vendorInfoSize, err := parseUint(n.get("vendorInfoSize"), 8)
+	if err != nil {
+		return nil, xerrors.Errorf("cannot parse vendorInfoSize: %w", err)
+	}
+	out.WriteByte(uint8(vendorInfoSize))
+	if vendorInfoSize > 0 {
+		vendorInfo, err := parseHex(n.get("vendorInfo"))
+		if err != nil || uint64(len(vendorInfo)) != vendorInfoSize {
+			return nil, errors.New("vendorInfo is not available")
+		}
+		out.Write(vendorInfo)
+	}
+
+	return out.Bytes(), nil
+}
+
+func reconstructEFIVariableData(n *yamlNode) ([]byte, error) {
+	guid, err := parseGUID(n.get("VariableName"))
+	if err != nil {
+		return nil, xerrors.Errorf("cannot parse VariableName: %w", err)
+	}
+	name, ok := n.get("UnicodeName").scalar()
+	if !ok {
+		return nil, errors.New("UnicodeName is not available")
+	}
+	data, err := parseHex(n.get("VariableData"))
+	if err != nil {
+		return nil, errors.New("VariableData is not available in its raw form")
+	}
+
+	unicodeName := utf16.Encode([]rune(name))
+
+	var out bytes.Buffer
+	out.Write(guid[:])
+	binary.Write(&out, binary.LittleEndian, uint64(len(unicodeName)))
+	binary.Write(&out, binary.LittleEndian, uint64(len(data)))
+	binary.Write(&out, binary.LittleEndian, unicodeName)
+	out.Write(data)
+	return out.Bytes(), nil
+}
+
+func reconstructImageLoadEvent(n *yamlNode) ([]byte, error) {
+	var out bytes.Buffer
+	for _, key := range []string{"ImageLocationInMemory", "ImageLengthInMemory", "ImageLinkTimeAddress", "LengthOfDevicePath"} {
+		v, err := parseUint(n.get(key), 64)
+		if err != nil {
+			return nil, xerrors.Errorf("cannot parse %s: %w", key, err)
+		}
+		binary.Write(&out, binary.LittleEndian, v)
+	}
+	path, err := parseHex(n.get("DevicePath"))
+	if err != nil {
+		return nil, errors.New("DevicePath is not available in its raw form")
+	}
+	out.Write(path)
+	return out.Bytes(), nil
+}
+
+// reconstructString returns the raw form of a string recorded in the YAML. As the encoding and termination aren't recorded,
+// the candidate with the expected size is returned.
+func reconstructString(s string, eventType tcglog.EventType, size int) []byte {
+	var utf16Str bytes.Buffer
+	binary.Write(&utf16Str, binary.LittleEndian, utf16.Encode([]rune(s)))
+
+	candidates := [][]byte{[]byte(s + "\x00"), []byte(s), append(utf16Str.Bytes(), 0, 0), utf16Str.Bytes()}
+	if size < 0 {
+		if eventType == tcglog.EventTypeIPL {
+			return candidates[0]
+		}
+		return candidates[1]
+	}
+	for _, c := range candidates {
+		if len(c) == size {
+			return c
+		}
+	}
+	return nil
+}
+
+func reconstructEventData(n *yamlNode, eventType tcglog.EventType) ([]byte, error) {
+	size := -1
+	if n.has("EventSize") {
+		s, err := parseUint(n.get("EventSize"), 32)
+		if err != nil {
+			return nil, xerrors.Errorf("cannot parse EventSize: %w", err)
+		}
+		size = int(s)
+	}
+
+	var data []byte
+	var err error
+
+	ev := n.get("Event")
+	switch {
+	case n.has("SpecID"):
+		data, err = reconstructSpecIdEvent(n.get("SpecID"))
+	case ev == nil:
+		err = errors.New("event data is not present")
+	case ev.kind == yamlScalar:
+		switch eventType {
+		case tcglog.EventTypeAction, tcglog.EventTypeEFIAction:
+			data = []byte(ev.value)
+		default:
+			data, err = hex.DecodeString(ev.value)
+			if err != nil {
+				data, err = []byte(ev.value), nil
+			}
+		}
+	case ev.has("VariableName"):
+		data, err = reconstructEFIVariableData(ev)
+	case ev.has("ImageLocationInMemory"):
+		data, err = reconstructImageLoadEvent(ev)
+	case ev.has("String"):
+		if s, ok := ev.get("String").scalar(); ok {
+			data = reconstructString(s, eventType, size)
+		}
+		if data == nil {
+			err = errors.New("cannot determine the encoding of the string")
+		}
+	default:
+		err = errors.New("event data is only available in a decoded form")
+	}
+
+	switch {
+	case err != nil:
+		return nil, err
+	case size >= 0 && len(data) != size:
+		return nil, fmt.Errorf("reconstructed event data has the wrong size (got %d bytes, expected %d)", len(data), size)
+	}
+	return data, nil
+}
+
+func readEvent(n *yamlNode, options *tcglog.LogOptions) (*tcglog.Event, error) {
+	if n == nil || n.kind != yamlMapping {
+		return nil, errors.New("expected a mapping")
+	}
+
+	pcr, err := parseUint(n.get("PCRIndex"), 32)
+	if err != nil {
+		return nil, xerrors.Errorf("cannot parse PCRIndex: %w", err)
+	}
+	eventType, err := parseEventType(n.get("EventType"))
+	if err != nil {
+		return nil, xerrors.Errorf("cannot parse EventType: %w", err)
+	}
+
+	digests := make(tcglog.DigestMap)
+	switch {
+	case n.has("Digests"):
+		list := n.get("Digests")
+		if list == nil || list.kind != yamlSequence {
+			return nil, errors.New("Digests is not a sequence")
+		}
+		for i, d := range list.items {
+			alg, err := parseAlgorithm(d.get("AlgorithmId"))
+			if err != nil {
+				return nil, xerrors.Errorf("cannot parse algorithm for digest %d: %w", i, err)
+			}
+			digest, err := parseHex(d.get("Digest"))
+			if err != nil {
+				return nil, xerrors.Errorf("cannot parse digest %d: %w", i, err)
+			}
+			digests[alg] = digest
+		}
+	case n.has("Digest"):
+		digest, err := parseHex(n.get("Digest"))
+		if err != nil {
+			return nil, xerrors.Errorf("cannot parse Digest: %w", err)
+		}
+		digests[tcglog.AlgorithmSha1] = digest
+	default:
+		return nil, errors.New("no digests")
+	}
+
+	event := &tcglog.Event{PCRIndex: tcglog.PCRIndex(pcr), EventType: eventType, Digests: digests}
+
+	data, err := reconstructEventData(n, eventType)
+	if err != nil {
+		event.Data = &unavailableEventData{reason: err.Error()}
+	} else {
+		event.Data = tcglog.DecodeEventData(event.PCRIndex, eventType, digests, data, options)
+	}
+
+	return event, nil
+}
+
+// ReadYAML reconstructs a log from the YAML produced by tpm2_eventlog, read from r. Event data is decoded using the supplied
+// options, which may be nil.
+//
+// The digests for every event are always recovered. The raw event data is recovered where it is present in the YAML or can be
+// rebuilt from the decoded fields. Where it can't be recovered (eg, because tpm2_eventlog only emitted a decoded form of an EFI
+// variable), the event's Data field will implement the error interface, Data.Bytes() will return nil and Data.EncodeTo will
+// return an error, so the log can't be written. The final PCR values in the YAML are ignored - these can be recomputed from the
+// returned log.
+func ReadYAML(r io.Reader, options *tcglog.LogOptions) (*tcglog.Log, error) {
+	b, err := ioutil.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+
+	doc, err := parseYAML(b)
+	if err != nil {
+		return nil, xerrors.Errorf("cannot parse YAML: %w", err)
+	}
+
+	eventsNode := doc.get("events")
+	if eventsNode == nil || eventsNode.kind != yamlSequence {
+		return nil, errors.New("no events")
+	}
+
+	var events []*tcglog.Event
+	for i, n := range eventsNode.items {
+		e, err := readEvent(n, options)
+		if err != nil {
+			return nil, xerrors.Errorf("cannot read event %d: %w", i, err)
+		}
+		events = append(events, e)
+	}
+
+	return tcglog.NewLog(events), nil
+}
@@ -0,0 +1,80 @@
+// Copyright 2019 Canonical Ltd.
+// Licensed under the LGPLv3 with static-linking exception.
+// See LICENCE file for details.
+
+package tcglog
+
+import (
+	"fmt"
+	"io"
+)
+
+type txtEventTypeInfo struct {
+	name        string
+	description string
+}
+
+// https://www.intel.com/content/dam/www/public/us/en/documents/guides/intel-txt-software-development-guide.pdf
+//  (appendix F "TPM Event Log")
+var txtEventTypes = map[EventType]txtEventTypeInfo{
+	EventTypeTXTPCRMapping:         {"EVTYPE_PCRMAPPING", "PCR mapping"},
+	EventTypeTXTHashStart:          {"EVTYPE_HASH_START", "SINIT ACM"},
+	EventTypeTXTCombinedHash:       {"EVTYPE_COMBINED_HASH", "Combined hash"},
+	EventTypeTXTMLEHash:            {"EVTYPE_MLE_HASH", "MLE"},
+	EventTypeTXTBIOSACRegData:      {"EVTYPE_BIOSAC_REG_DATA", "BIOS ACM registration data"},
+	EventTypeTXTCPUSCRTMStat:       {"EVTYPE_CPU_SCRTM_STAT", "CPU S-CRTM status"},
+	EventTypeTXTLCPControlHash:     {"EVTYPE_LCP_CONTROL_HASH", "LCP control field"},
+	EventTypeTXTElementsDigest:     {"EVTYPE_ELEMENTS_DIGEST", "LCP policy elements"},
+	EventTypeTXTSTMHash:            {"EVTYPE_STM_HASH", "STM"},
+	EventTypeTXTOSSINITDataCapHash: {"EVTYPE_OSSINITDATA_CAP_HASH", "OsSinitData capabilities"},
+	EventTypeTXTSINITPubKeyHash:    {"EVTYPE_SINIT_PUBKEY_HASH", "SINIT ACM public key"},
+	EventTypeTXTLCPHash:            {"EVTYPE_LCP_HASH", "LCP policy"},
+	EventTypeTXTLCPDetailsHash:     {"EVTYPE_LCP_DETAILS_HASH", "LCP policy details"},
+	EventTypeTXTLCPAuthoritiesHash: {"EVTYPE_LCP_AUTHORITIES_HASH", "LCP policy authorities"},
+	EventTypeTXTNVInfoHash:         {"EVTYPE_NV_INFO_HASH", "LCP NV index information"},
+	EventTypeTXTColdBootBIOSHash:   {"EVTYPE_COLD_BOOT_BIOS_HASH", "Cold boot BIOS"},
+	EventTypeTXTKMHash:             {"EVTYPE_KM_HASH", "Boot Guard key manifest"},
+	EventTypeTXTBPMHash:            {"EVTYPE_BPM_HASH", "Boot Guard boot policy manifest"},
+	EventTypeTXTKMInfoHash:         {"EVTYPE_KM_INFO_HASH", "Boot Guard key manifest information"},
+	EventTypeTXTBPMInfoHash:        {"EVTYPE_BPM_INFO_HASH", "Boot Guard boot policy manifest information"},
+	EventTypeTXTBootPolHash:        {"EVTYPE_BOOT_POL_HASH", "Boot Guard boot policy"},
+	EventTypeTXTRandomValue:        {"EVTYPE_RANDOM_VALUE", "Random value"},
+	EventTypeTXTCapValue:           {"EVTYPE_CAP_VALUE", "Cap value"},
+}
+
+// TXTEventData is the event data associated with an event recorded by an Intel TXT measured launch. The digests of these events
+// are generally of the component or policy described by the event type, and the event data is informational.
+type TXTEventData struct {
+	data      []byte
+	eventType EventType
+	Data      []byte // The informational data recorded with the event
+}
+
+func (e *TXTEventData) String() string {
+	if len(e.Data) == 0 {
+		return fmt.Sprintf("TXT{ %s }", e.Description())
+	}
+	return fmt.Sprintf("TXT{ %s, Data: %x }", e.Description(), e.Data)
+}
+
+func (e *TXTEventData) Bytes() []byte {
+	return e.data
+}
+
+// EncodeTo encodes this event data in to the form in which it appears in the event log.
+func (e *TXTEventData) EncodeTo(w io.Writer) error {
+	_, err := w.Write(e.Data)
+	return err
+}
+
+// Description returns a description of the component or policy that is measured by this event.
+func (e *TXTEventData) Description() string {
+	return txtEventTypes[e.eventType].description
+}
+
+func decodeEventDataTXT(eventType EventType, data []byte) EventData {
+	if _, ok := txtEventTypes[eventType]; !ok {
+		return nil
+	}
+	return &TXTEventData{data: data, eventType: eventType, Data: data}
+}
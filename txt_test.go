@@ -0,0 +1,60 @@
+// Copyright 2019 Canonical Ltd.
+// Licensed under the LGPLv3 with static-linking exception.
+// See LICENCE file for details.
+
+package tcglog
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestDecodeEventDataTXT(t *testing.T) {
+	for _, data := range []struct {
+		desc      string
+		eventType EventType
+		data      []byte
+		name      string
+		str       string
+	}{
+		{
+			desc:      "MLEHash",
+			eventType: EventTypeTXTMLEHash,
+			name:      "EVTYPE_MLE_HASH",
+			str:       "TXT{ MLE }",
+		},
+		{
+			desc:      "SINITPubKeyHash",
+			eventType: EventTypeTXTSINITPubKeyHash,
+			name:      "EVTYPE_SINIT_PUBKEY_HASH",
+			str:       "TXT{ SINIT ACM public key }",
+		},
+		{
+			desc:      "CPUSCRTMStat",
+			eventType: EventTypeTXTCPUSCRTMStat,
+			data:      []byte{0x01, 0x00, 0x00, 0x00},
+			name:      "EVTYPE_CPU_SCRTM_STAT",
+			str:       "TXT{ CPU S-CRTM status, Data: 01000000 }",
+		},
+	} {
+		t.Run(data.desc, func(t *testing.T) {
+			if data.eventType.String() != data.name {
+				t.Errorf("Unexpected event type name: %s", data.eventType)
+			}
+			d, ok := DecodeEventData(17, data.eventType, DigestMap{}, data.data, nil).(*TXTEventData)
+			if !ok {
+				t.Fatalf("Unexpected event data type")
+			}
+			if d.String() != data.str {
+				t.Errorf("Unexpected string: %s", d)
+			}
+			if !bytes.Equal(d.Bytes(), data.data) {
+				t.Errorf("Unexpected bytes: %x", d.Bytes())
+			}
+		})
+	}
+
+	if _, ok := DecodeEventData(17, EventType(0x405), DigestMap{}, nil, nil).(*TXTEventData); ok {
+		t.Errorf("Unexpected event data type for unknown TXT event type")
+	}
+}
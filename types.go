@@ -99,6 +99,8 @@ func (e EventType) String() string {
 		return "EV_NONHOST_INFO"
 	case EventTypeOmitBootDeviceEvents:
 		return "EV_OMIT_BOOT_DEVICE_EVENTS"
+	case EventTypePostCode2:
+		return "EV_POST_CODE2"
 	case EventTypeEFIVariableDriverConfig:
 		return "EV_EFI_VARIABLE_DRIVER_CONFIG"
 	case EventTypeEFIVariableBoot:
@@ -110,18 +112,36 @@ func (e EventType) String() string {
 	case EventTypeEFIRuntimeServicesDriver:
 		return "EV_EFI_RUNTIME_SERVICES_DRIVER"
 	case EventTypeEFIGPTEvent:
-		return "EF_EFI_GPT_EVENT"
+		return "EV_EFI_GPT_EVENT"
 	case EventTypeEFIAction:
 		return "EV_EFI_ACTION"
 	case EventTypeEFIPlatformFirmwareBlob:
 		return "EV_EFI_PLATFORM_FIRMWARE_BLOB"
 	case EventTypeEFIHandoffTables:
 		return "EV_EFI_HANDOFF_TABLES"
+	case EventTypeEFIPlatformFirmwareBlob2:
+		return "EV_EFI_PLATFORM_FIRMWARE_BLOB2"
+	case EventTypeEFIHandoffTables2:
+		return "EV_EFI_HANDOFF_TABLES2"
 	case EventTypeEFIHCRTMEvent:
 		return "EV_EFI_HCRTM_EVENT"
 	case EventTypeEFIVariableAuthority:
 		return "EV_EFI_VARIABLE_AUTHORITY"
+	case EventTypeEFISPDMFirmwareBlob:
+		return "EV_EFI_SPDM_FIRMWARE_BLOB"
+	case EventTypeEFISPDMFirmwareConfig:
+		return "EV_EFI_SPDM_FIRMWARE_CONFIG"
+	case EventTypeEFISPDMDevicePolicy:
+		return "EV_EFI_SPDM_DEVICE_POLICY"
+	case EventTypeEFISPDMDeviceAuthority:
+		return "EV_EFI_SPDM_DEVICE_AUTHORITY"
 	default:
+		if t, ok := txtEventTypes[e]; ok {
+			return t.name
+		}
+		if name, ok := secureLaunchEventTypeNames[e]; ok {
+			return name
+		}
 		return fmt.Sprintf("%08x", uint32(e))
 	}
 }
@@ -178,4 +198,5 @@ type Event struct {
 	EventType EventType // The type of this event
 	Digests   DigestMap // The digests corresponding to this event for the supported algorithms
 	Data      EventData // The data recorded with this event
+	Stage     BootStage // The boot stage during which this event was measured (see Log.ClassifyBootStages)
 }
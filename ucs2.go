@@ -0,0 +1,72 @@
+// Copyright 2019 Canonical Ltd.
+// Licensed under the LGPLv3 with static-linking exception.
+// See LICENCE file for details.
+
+package tcglog
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"unicode"
+	"unicode/utf16"
+	"unicode/utf8"
+)
+
+// DecodeUCS2String decodes the supplied little-endian UCS-2 or UTF-16 data in to a UTF-8 string. Decoding stops at the first NULL
+// character if there is one. An error is returned if the data has an odd length or contains an unpaired surrogate.
+//
+// The returned string is not escaped, and may contain control characters. Use EscapeString before displaying it.
+func DecodeUCS2String(data []byte) (string, error) {
+	if len(data)%2 != 0 {
+		return "", errors.New("odd length")
+	}
+
+	u := make([]uint16, len(data)/2)
+	binary.Read(bytes.NewReader(data), binary.LittleEndian, u)
+	for i, c := range u {
+		if c == 0 {
+			u = u[:i]
+			break
+		}
+	}
+
+	for i := 0; i < len(u); i++ {
+		switch {
+		case u[i] >= 0xd800 && u[i] < 0xdc00:
+			if i+1 >= len(u) || u[i+1] < 0xdc00 || u[i+1] >= 0xe000 {
+				return "", fmt.Errorf("unpaired high surrogate at index %d", i)
+			}
+			i++
+		case u[i] >= 0xdc00 && u[i] < 0xe000:
+			return "", fmt.Errorf("unpaired low surrogate at index %d", i)
+		}
+	}
+
+	return string(utf16.Decode(u)), nil
+}
+
+// EscapeString returns a copy of the supplied string in which control characters, other unprintable characters and invalid UTF-8
+// sequences are replaced with escape sequences in the form used by Go string literals (eg, "\x1b" or "\u200b"). Backslashes are
+// not escaped so that paths remain readable. This makes strings obtained from a log safe to display in a terminal.
+func EscapeString(str string) string {
+	var builder bytes.Buffer
+	for len(str) > 0 {
+		r, n := utf8.DecodeRuneInString(str)
+		switch {
+		case r == utf8.RuneError && n == 1:
+			fmt.Fprintf(&builder, "\\x%02x", str[0])
+		case unicode.IsPrint(r):
+			builder.WriteRune(r)
+		case r < 0x100:
+			fmt.Fprintf(&builder, "\\x%02x", r)
+		case r < 0x10000:
+			fmt.Fprintf(&builder, "\\u%04x", r)
+		default:
+			fmt.Fprintf(&builder, "\\U%08x", r)
+		}
+		str = str[n:]
+	}
+	return builder.String()
+}
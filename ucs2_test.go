@@ -0,0 +1,81 @@
+// Copyright 2019 Canonical Ltd.
+// Licensed under the LGPLv3 with static-linking exception.
+// See LICENCE file for details.
+
+package tcglog
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestDecodeUCS2String(t *testing.T) {
+	for _, data := range []struct {
+		desc     string
+		data     []byte
+		expected string
+	}{
+		{desc: "Simple", data: makeTestUTF16String("BootOrder"), expected: "BootOrder"},
+		{desc: "NoTerminator", data: []byte{0x64, 0x00, 0x62, 0x00}, expected: "db"},
+		{desc: "StopsAtNull", data: []byte{0x64, 0x00, 0x00, 0x00, 0x62, 0x00}, expected: "d"},
+		{desc: "SurrogatePair", data: []byte{0x3d, 0xd8, 0x00, 0xde}, expected: "\U0001f600"},
+		{desc: "Empty", data: nil, expected: ""},
+	} {
+		t.Run(data.desc, func(t *testing.T) {
+			str, err := DecodeUCS2String(data.data)
+			if err != nil {
+				t.Fatalf("DecodeUCS2String failed: %v", err)
+			}
+			if str != data.expected {
+				t.Errorf("Unexpected string: %q", str)
+			}
+		})
+	}
+}
+
+func TestDecodeUCS2StringInvalid(t *testing.T) {
+	for _, data := range []struct {
+		desc string
+		data []byte
+	}{
+		{desc: "OddLength", data: []byte{0x64, 0x00, 0x62}},
+		{desc: "UnpairedHighSurrogate", data: []byte{0x3d, 0xd8, 0x41, 0x00}},
+		{desc: "TrailingHighSurrogate", data: []byte{0x41, 0x00, 0x3d, 0xd8}},
+		{desc: "UnpairedLowSurrogate", data: []byte{0x00, 0xde, 0x41, 0x00}},
+	} {
+		t.Run(data.desc, func(t *testing.T) {
+			if _, err := DecodeUCS2String(data.data); err == nil {
+				t.Errorf("DecodeUCS2String should have failed")
+			}
+		})
+	}
+}
+
+func TestEscapeString(t *testing.T) {
+	for _, data := range []struct {
+		desc     string
+		str      string
+		expected string
+	}{
+		{desc: "Printable", str: "Boot0001", expected: "Boot0001"},
+		{desc: "Path", str: `\EFI\ubuntu\shimx64.efi`, expected: `\EFI\ubuntu\shimx64.efi`},
+		{desc: "Escape", str: "\x1b[31mred", expected: `\x1b[31mred`},
+		{desc: "Null", str: "foo\x00bar", expected: `foo\x00bar`},
+		{desc: "InvalidUTF8", str: "foo\xffbar", expected: `foo\xffbar`},
+		{desc: "Unicode", str: "café", expected: "café"},
+		{desc: "ZeroWidthSpace", str: "a\u200bb", expected: `a\u200bb`},
+	} {
+		t.Run(data.desc, func(t *testing.T) {
+			if str := EscapeString(data.str); str != data.expected {
+				t.Errorf("Unexpected string: %q", str)
+			}
+		})
+	}
+}
+
+func TestEFIVariableDataStringEscaped(t *testing.T) {
+	d := &EFIVariableData{VariableName: EFIGlobalVariableGuid, UnicodeName: "\x1b[31mBootOrder"}
+	if str := d.String(); !strings.Contains(str, `\x1b[31mBootOrder`) || strings.Contains(str, "\x1b") {
+		t.Errorf("Unexpected string: %q", str)
+	}
+}
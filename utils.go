@@ -6,6 +6,7 @@ package tcglog
 
 import (
 	"bytes"
+	"encoding/binary"
 	"fmt"
 	"unicode/utf16"
 	"unicode/utf8"
@@ -48,3 +49,68 @@ func convertUtf16ToString(u []uint16) string {
 	}
 	return string(utf8Str)
 }
+
+// isPrintableASCII indicates whether the supplied data is a non-empty printable ASCII string, with an optional NULL terminator.
+func isPrintableASCII(data []byte) bool {
+	if len(data) > 0 && data[len(data)-1] == 0 {
+		data = data[:len(data)-1]
+	}
+	if len(data) == 0 {
+		return false
+	}
+	for _, c := range data {
+		if c < 0x20 || c > 0x7e {
+			return false
+		}
+	}
+	return true
+}
+
+// isPrintableUTF16 indicates whether the supplied data is a non-empty printable little-endian UTF-16 string consisting of
+// characters in the ASCII range, with an optional NULL terminator.
+func isPrintableUTF16(data []byte) bool {
+	if len(data)%2 != 0 {
+		return false
+	}
+	if len(data) >= 2 && data[len(data)-2] == 0 && data[len(data)-1] == 0 {
+		data = data[:len(data)-2]
+	}
+	if len(data) == 0 {
+		return false
+	}
+	for i := 0; i < len(data); i += 2 {
+		if data[i] < 0x20 || data[i] > 0x7e || data[i+1] != 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// decodePrintableString decodes the supplied data if it is a printable ASCII or little-endian UTF-16 string, with an optional
+// NULL terminator. The second return value indicates whether the data was UTF-16 encoded. The third return value is false if
+// the data is not a printable string.
+func decodePrintableString(data []byte) (str string, isUTF16 bool, ok bool) {
+	switch {
+	case isPrintableASCII(data):
+		return string(bytes.TrimSuffix(data, []byte{0})), false, true
+	case isPrintableUTF16(data):
+		u := make([]uint16, len(data)/2)
+		binary.Read(bytes.NewReader(data), binary.LittleEndian, u)
+		if u[len(u)-1] == 0 {
+			u = u[:len(u)-1]
+		}
+		return convertUtf16ToString(u), true, true
+	}
+	return "", false, false
+}
+
+// encodePrintableString encodes the supplied string as a NUL terminated ASCII or little-endian UTF-16 string, which is the inverse
+// of decodePrintableString.
+func encodePrintableString(str string, isUTF16 bool) []byte {
+	if !isUTF16 {
+		return append([]byte(str), 0)
+	}
+	var b bytes.Buffer
+	binary.Write(&b, binary.LittleEndian, append(convertStringToUtf16(str), 0))
+	return b.Bytes()
+}
@@ -0,0 +1,105 @@
+// Copyright 2019 Canonical Ltd.
+// Licensed under the LGPLv3 with static-linking exception.
+// See LICENCE file for details.
+
+package tcglog
+
+import (
+	"bytes"
+	"fmt"
+)
+
+// IncorrectDigest describes an event with a recorded digest that is inconsistent with the data recorded with it in the log.
+type IncorrectDigest struct {
+	Event     *Event
+	Algorithm AlgorithmId
+	Expected  Digest // The digest computed from the event data
+}
+
+func (d *IncorrectDigest) String() string {
+	return fmt.Sprintf("event %d in PCR %d (type: %s, alg: %s) - expected (from data): %x, got: %x", d.Event.Index,
+		d.Event.PCRIndex, d.Event.EventType, d.Algorithm, d.Expected, d.Event.Digests[d.Algorithm])
+}
+
+// measuredBytesCandidate is a possible form of the bytes that were measured for an event.
+type measuredBytesCandidate struct {
+	data  []byte
+	quirk string // A description of the bug that results in this form being measured, or empty for the specified form
+}
+
+// candidateMeasuredBytes returns the possible forms of the bytes that were measured for the supplied event, starting with the
+// form defined by the TCG PC Client Platform Firmware Profile Specification. The other forms take in to account known firmware
+// and bootloader bugs.
+func candidateMeasuredBytes(event *Event) ([]measuredBytesCandidate, error) {
+	measured, err := measuredBytes(event.EventType, event.Data)
+	if err != nil {
+		return nil, err
+	}
+	out := []measuredBytesCandidate{{data: measured}}
+
+	if varData, ok := event.Data.(*EFIVariableData); ok && event.EventType == EventTypeEFIVariableBoot {
+		// Some firmware measures only the variable contents for EV_EFI_VARIABLE_BOOT events, rather than the entire
+		// UEFI_VARIABLE_DATA structure.
+		out = append(out, measuredBytesCandidate{
+			data:  varData.VariableData,
+			quirk: "the digest is of the variable contents rather than the entire UEFI_VARIABLE_DATA structure"})
+	}
+
+	if t, ok := event.Data.(interface{ TrailingBytes() []byte }); ok && bytes.Equal(measured, event.Data.Bytes()) {
+		// Some software records event data with trailing bytes that are not measured.
+		for n := 1; n <= len(t.TrailingBytes()); n++ {
+			out = append(out, measuredBytesCandidate{
+				data:  measured[:len(measured)-n],
+				quirk: fmt.Sprintf("the event data has %d trailing bytes that are not measured", n)})
+		}
+	}
+
+	return out, nil
+}
+
+// matchMeasuredBytes returns the candidate form of the measured bytes for the supplied event that is consistent with its
+// digest for the specified algorithm. It returns false if the digest is inconsistent with the event data, and an error if the
+// digest can't be computed from the event data.
+func matchMeasuredBytes(event *Event, alg AlgorithmId) (*measuredBytesCandidate, bool, error) {
+	candidates, err := candidateMeasuredBytes(event)
+	if err != nil {
+		return nil, false, err
+	}
+	for i := range candidates {
+		if bytes.Equal(event.Digests[alg], alg.hash(candidates[i].data)) {
+			return &candidates[i], true, nil
+		}
+	}
+	return &candidates[0], false, nil
+}
+
+// ValidateDigests recomputes the expected digests for every event in this log where the TCG PC Client Platform Firmware Profile
+// Specification defines the bytes that are measured in terms of the event data, and returns details of each digest that doesn't
+// match. A mismatch indicates that the log doesn't describe what was measured, which might be caused by a bug in the firmware or
+// bootloader responsible for the event.
+//
+// Events whose digests are of content that is only referenced by the event data (eg, EV_EFI_BOOT_SERVICES_APPLICATION events)
+// and events with data that could not be decoded are skipped. Digests of EV_EFI_VARIABLE_BOOT events that are computed from
+// only the variable contents, and digests that don't cover trailing bytes in the event data, are accepted.
+func (l *Log) ValidateDigests() (out []*IncorrectDigest) {
+	for _, e := range l.Events {
+		if e.EventType == EventTypeNoAction {
+			continue
+		}
+
+		for _, alg := range l.Algorithms {
+			if _, ok := e.Digests[alg]; !ok || !alg.supported() {
+				continue
+			}
+
+			expected, matched, err := matchMeasuredBytes(e, alg)
+			if err != nil {
+				break
+			}
+			if !matched {
+				out = append(out, &IncorrectDigest{Event: e, Algorithm: alg, Expected: alg.hash(expected.data)})
+			}
+		}
+	}
+	return out
+}
@@ -0,0 +1,67 @@
+// Copyright 2019 Canonical Ltd.
+// Licensed under the LGPLv3 with static-linking exception.
+// See LICENCE file for details.
+
+package tcglog
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestValidateDigests(t *testing.T) {
+	varData := EFIVariableData{
+		VariableName: EFIGlobalVariableGuid,
+		UnicodeName:  "BootOrder",
+		VariableData: []byte{0x01, 0x00, 0x02, 0x00}}
+	var varBytes bytes.Buffer
+	varData.EncodeMeasuredBytes(&varBytes)
+
+	varDataOnly := makeTestEvent(1, EventTypeEFIVariableBoot, varBytes.Bytes(), AlgorithmSha256)
+	varDataOnly.Digests[AlgorithmSha256] = AlgorithmSha256.hash(varData.VariableData)
+
+	trailing := append(append([]byte(nil), varBytes.Bytes()...), 0x00, 0x00)
+	trailingUnmeasured := makeTestEvent(7, EventTypeEFIVariableDriverConfig, trailing, AlgorithmSha256)
+	trailingUnmeasured.Digests[AlgorithmSha256] = AlgorithmSha256.hash(varBytes.Bytes())
+
+	image := makeTestEvent(4, EventTypeEFIBootServicesApplication, makeTestImageLoadEventData("\\EFI\\ubuntu\\shimx64.efi"),
+		AlgorithmSha256)
+	image.Digests[AlgorithmSha256] = AlgorithmSha256.hash([]byte("foo"))
+
+	bad := makeTestEvent(4, EventTypeEFIAction, []byte("Calling EFI Application from Boot Option"), AlgorithmSha256)
+	bad.Digests[AlgorithmSha256] = AlgorithmSha256.hash([]byte("Calling EFI Application from Boot Option\x00"))
+
+	log := NewLog([]*Event{
+		makeTestEvent(0, EventTypeNoAction, append([]byte("StartupLocality\x00"), 3), AlgorithmSha256),
+		makeTestEvent(0, EventTypeSCRTMVersion, []byte{0x31, 0x00}, AlgorithmSha256),
+		makeTestEvent(1, EventTypeEFIVariableBoot, varBytes.Bytes(), AlgorithmSha256),
+		varDataOnly,
+		trailingUnmeasured,
+		image,
+		bad,
+		makeTestEvent(4, EventTypeSeparator, []byte{0, 0, 0, 0}, AlgorithmSha256)})
+
+	incorrect := log.ValidateDigests()
+	if len(incorrect) != 1 {
+		t.Fatalf("Unexpected number of incorrect digests (%d)", len(incorrect))
+	}
+	if incorrect[0].Event != bad || incorrect[0].Algorithm != AlgorithmSha256 {
+		t.Errorf("Unexpected incorrect digest: %s", incorrect[0])
+	}
+	if !bytes.Equal(incorrect[0].Expected, AlgorithmSha256.hash([]byte("Calling EFI Application from Boot Option"))) {
+		t.Errorf("Unexpected expected digest: %x", incorrect[0].Expected)
+	}
+}
+
+func TestValidateDigestsErrorSeparator(t *testing.T) {
+	sep := makeTestEvent(7, EventTypeSeparator, []byte("error"), AlgorithmSha1)
+	log := NewLog([]*Event{sep})
+	if incorrect := log.ValidateDigests(); len(incorrect) != 1 {
+		t.Fatalf("Unexpected number of incorrect digests (%d)", len(incorrect))
+	}
+
+	sep.Digests[AlgorithmSha1] = AlgorithmSha1.hash([]byte{0x01, 0x00, 0x00, 0x00})
+	if incorrect := log.ValidateDigests(); len(incorrect) != 0 {
+		t.Errorf("Unexpected incorrect digest: %s", incorrect[0])
+	}
+}
@@ -0,0 +1,393 @@
+// Copyright 2019 Canonical Ltd.
+// Licensed under the LGPLv3 with static-linking exception.
+// See LICENCE file for details.
+
+package tcglog
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"sort"
+)
+
+// ValidationSeverity indicates the severity of a ValidationFinding.
+type ValidationSeverity int
+
+const (
+	// SeverityInfo indicates a finding that is informational only.
+	SeverityInfo ValidationSeverity = iota
+
+	// SeverityWarning indicates a finding that is caused by known firmware or bootloader behaviour that doesn't comply with
+	// the relevant specifications, but which doesn't prevent the log from being used.
+	SeverityWarning
+
+	// SeverityError indicates a finding that means the log is inconsistent or doesn't comply with the relevant specifications.
+	SeverityError
+)
+
+func (s ValidationSeverity) String() string {
+	switch s {
+	case SeverityInfo:
+		return "info"
+	case SeverityWarning:
+		return "warning"
+	case SeverityError:
+		return "error"
+	default:
+		return fmt.Sprintf("%%!(UNKNOWN_SEVERITY=%d)", int(s))
+	}
+}
+
+// ValidationFinding describes an issue found by a ValidationCheck.
+type ValidationFinding struct {
+	Check    string // The name of the check that produced this finding
+	Severity ValidationSeverity
+	Event    *Event // The event that this finding relates to, or nil if it relates to the log as a whole
+	Message  string // An explanation of the finding
+}
+
+func (f *ValidationFinding) String() string {
+	if f.Event == nil {
+		return fmt.Sprintf("%s: %s: %s", f.Severity, f.Check, f.Message)
+	}
+	return fmt.Sprintf("%s: %s: event %d in PCR %d (type: %s): %s", f.Severity, f.Check, f.Event.Index, f.Event.PCRIndex,
+		f.Event.EventType, f.Message)
+}
+
+// ValidationCheck is a function that performs an individual check on a log and returns any findings. The Check field of the
+// returned findings is populated by Validator.
+type ValidationCheck func(log *Log) []*ValidationFinding
+
+type namedValidationCheck struct {
+	name  string
+	check ValidationCheck
+}
+
+// Validator runs a set of checks against a log. Use NewValidator to create a validator with the built-in checks, and AddCheck to
+// add custom checks to it. The zero value has no checks.
+type Validator struct {
+	checks []namedValidationCheck
+}
+
+// NewValidator returns a new Validator with the built-in checks.
+func NewValidator() *Validator {
+	v := new(Validator)
+	v.AddCheck("digests", CheckDigests)
+	v.AddCheck("bank-consistency", CheckBankConsistency)
+	v.AddCheck("quirks", CheckQuirks)
+	v.AddCheck("separators", CheckSeparators)
+	v.AddCheck("ordering", CheckOrdering)
+	v.AddCheck("duplicate-authorities", CheckDuplicateAuthorities)
+	v.AddCheck("authority-anchors", CheckAuthorityAnchors)
+	v.AddCheck("dbx-revocations", CheckRevocations)
+	v.AddCheck("pfp-compliance", CheckPFPCompliance)
+	v.AddCheck("spec-compliance", CheckSpecCompliance)
+	return v
+}
+
+// AddCheck adds a check with the specified name to this validator. Adding a check replaces any check previously added with the
+// same name, and adding a nil check removes it.
+func (v *Validator) AddCheck(name string, check ValidationCheck) {
+	for i, c := range v.checks {
+		if c.name != name {
+			continue
+		}
+		if check == nil {
+			v.checks = append(v.checks[:i], v.checks[i+1:]...)
+		} else {
+			v.checks[i].check = check
+		}
+		return
+	}
+	if check != nil {
+		v.checks = append(v.checks, namedValidationCheck{name: name, check: check})
+	}
+}
+
+// Checks returns the names of the checks that this validator runs, in the order in which they are run.
+func (v *Validator) Checks() (names []string) {
+	for _, c := range v.checks {
+		names = append(names, c.name)
+	}
+	return names
+}
+
+// Validate runs each of the checks in this validator against the supplied log and returns the findings, ordered by the position
+// of the associated event in the log. Findings that relate to the log as a whole appear first.
+func (v *Validator) Validate(log *Log) []*ValidationFinding {
+	var out []*ValidationFinding
+	for _, c := range v.checks {
+		for _, f := range c.check(log) {
+			f.Check = c.name
+			out = append(out, f)
+		}
+	}
+
+	positions := make(map[*Event]int)
+	for i, e := range log.Events {
+		positions[e] = i + 1
+	}
+	sort.SliceStable(out, func(i, j int) bool {
+		return positions[out[i].Event] < positions[out[j].Event]
+	})
+
+	return out
+}
+
+// CheckDigests is a ValidationCheck that reports events with digests that are inconsistent with the data recorded with them in
+// the log. See Log.ValidateDigests.
+func CheckDigests(log *Log) (out []*ValidationFinding) {
+	for _, d := range log.ValidateDigests() {
+		out = append(out, &ValidationFinding{
+			Severity: SeverityError,
+			Event:    d.Event,
+			Message: fmt.Sprintf("the %v digest is inconsistent with the event data (expected: %x, got: %x)", d.Algorithm,
+				d.Expected, d.Event.Digests[d.Algorithm])})
+	}
+	return out
+}
+
+// CheckBankConsistency is a ValidationCheck that reports events where the digests recorded in different banks are not digests
+// of the same data, which indicates that firmware extended different data to different banks. Where the measured bytes can be
+// computed from the event data, the digest in each bank must match the same form of these bytes. For other events, a digest of all
+// zeroes in one bank when the other banks contain a measurement is reported, as this indicates that the firmware didn't measure
+// to that bank.
+func CheckBankConsistency(log *Log) (out []*ValidationFinding) {
+	for _, e := range log.Events {
+		if e.EventType == EventTypeNoAction {
+			continue
+		}
+
+		var algs AlgorithmIdList
+		for _, alg := range log.Algorithms {
+			if _, ok := e.Digests[alg]; ok && alg.supported() {
+				algs = append(algs, alg)
+			}
+		}
+		if len(algs) < 2 {
+			continue
+		}
+
+		var zeroAlgs AlgorithmIdList
+		for _, alg := range algs {
+			if isZeroDigest(e.Digests[alg]) {
+				zeroAlgs = append(zeroAlgs, alg)
+			}
+		}
+		if len(zeroAlgs) > 0 && len(zeroAlgs) < len(algs) {
+			for _, alg := range zeroAlgs {
+				out = append(out, &ValidationFinding{Severity: SeverityError, Event: e,
+					Message: fmt.Sprintf("the %v digest is all zeroes but the other banks contain a measurement", alg)})
+			}
+			continue
+		}
+
+		matches := make(map[AlgorithmId]*measuredBytesCandidate)
+		for _, alg := range algs {
+			candidate, matched, err := matchMeasuredBytes(e, alg)
+			if err != nil {
+				break
+			}
+			if matched {
+				matches[alg] = candidate
+			}
+		}
+		if len(matches) == 0 {
+			continue
+		}
+
+		var matchedAlgs, unmatchedAlgs AlgorithmIdList
+		var data []byte
+		consistent := true
+		for _, alg := range algs {
+			m, ok := matches[alg]
+			switch {
+			case !ok:
+				unmatchedAlgs = append(unmatchedAlgs, alg)
+			case data == nil:
+				data = m.data
+				matchedAlgs = append(matchedAlgs, alg)
+			default:
+				if !bytes.Equal(data, m.data) {
+					consistent = false
+				}
+				matchedAlgs = append(matchedAlgs, alg)
+			}
+		}
+
+		switch {
+		case len(unmatchedAlgs) > 0:
+			out = append(out, &ValidationFinding{Severity: SeverityError, Event: e,
+				Message: fmt.Sprintf("the digests in banks %v are consistent with the event data but the digests in banks %v "+
+					"are not", matchedAlgs, unmatchedAlgs)})
+		case !consistent:
+			out = append(out, &ValidationFinding{Severity: SeverityError, Event: e,
+				Message: "the digests in each bank are of different forms of the event data"})
+		}
+	}
+	return out
+}
+
+// CheckQuirks is a ValidationCheck that reports events with digests that are only consistent with the event data because of
+// known firmware or bootloader bugs, such as EV_EFI_VARIABLE_BOOT events that only measure the variable contents.
+func CheckQuirks(log *Log) (out []*ValidationFinding) {
+	for _, e := range log.Events {
+		if e.EventType == EventTypeNoAction {
+			continue
+		}
+		for _, alg := range log.Algorithms {
+			if _, ok := e.Digests[alg]; !ok || !alg.supported() {
+				continue
+			}
+			candidate, matched, err := matchMeasuredBytes(e, alg)
+			if err != nil {
+				break
+			}
+			if matched && candidate.quirk != "" {
+				out = append(out, &ValidationFinding{Severity: SeverityWarning, Event: e, Message: candidate.quirk})
+				break
+			}
+		}
+	}
+	return out
+}
+
+// CheckSpecCompliance is a ValidationCheck that reports events that don't comply with the structural requirements of the TCG
+// PC Client Platform Firmware Profile Specification, such as out-of-range PCR indices, missing or incorrectly sized digests and
+// EV_NO_ACTION events with digests that aren't all zeroes.
+func CheckSpecCompliance(log *Log) (out []*ValidationFinding) {
+	for _, e := range log.Events {
+		if !isPCRIndexInRange(e.PCRIndex) {
+			out = append(out, &ValidationFinding{Severity: SeverityError, Event: e, Message: "the PCR index is out of range"})
+		}
+
+		for _, alg := range log.Algorithms {
+			digest, ok := e.Digests[alg]
+			switch {
+			case !ok:
+				out = append(out, &ValidationFinding{Severity: SeverityError, Event: e,
+					Message: fmt.Sprintf("there is no %v digest", alg)})
+			case alg.supported() && len(digest) != alg.Size():
+				out = append(out, &ValidationFinding{Severity: SeverityError, Event: e,
+					Message: fmt.Sprintf("the %v digest has the wrong size (%d bytes)", alg, len(digest))})
+			case e.EventType == EventTypeNoAction && !isZeroDigest(digest):
+				out = append(out, &ValidationFinding{Severity: SeverityError, Event: e,
+					Message: fmt.Sprintf("the %v digest of an EV_NO_ACTION event is not all zeroes", alg)})
+			}
+		}
+	}
+	return out
+}
+
+// isPostSeparatorEventAllowed indicates whether the supplied event may be measured after the EV_SEPARATOR event for its PCR.
+// Most pre-OS measurements must be made before the separator, but images loaded by the boot manager, the actions associated with
+// ExitBootServices and the authorities used to verify images are measured afterwards. On legacy BIOS platforms, the IPL code and
+// partition data are measured afterwards. The boot manager's actions for each boot attempt may also be measured after the
+// separator if an earlier attempt fails and it tries another boot option.
+//
+// https://trustedcomputinggroup.org/wp-content/uploads/TCG_PCClientSpecPlat_TPM_2p0_1p04_pub.pdf
+//  (section 3.3.4 "PCR Usage")
+func isPostSeparatorEventAllowed(e *Event) bool {
+	switch e.PCRIndex {
+	case 4:
+		switch e.EventType {
+		case EventTypeEFIBootServicesApplication, EventTypeEFIBootServicesDriver, EventTypeEFIRuntimeServicesDriver,
+			EventTypeIPL:
+			return true
+		case EventTypeAction, EventTypeEFIAction:
+			d, ok := e.Data.(*ActionEventData)
+			if !ok || !d.IsStandard() {
+				return false
+			}
+			switch d.Action {
+			case CallingInt19h, ReturnedInt19h, ReturnViaInt18h, CallingEFIApplication, ReturningFromEFIApplication:
+				return true
+			}
+		}
+		return false
+	case 5:
+		return e.EventType == EventTypeEFIAction || e.EventType == EventTypeEFIGPTEvent ||
+			e.EventType == EventTypeIPLPartitionData
+	case 7:
+		return e.EventType == EventTypeEFIVariableAuthority
+	default:
+		return false
+	}
+}
+
+// CheckSeparators is a ValidationCheck that reports problems with EV_SEPARATOR events. Each of PCRs 0-7 must contain a single
+// separator with one of the defined normal values or a digest of the error value, the digests in each bank must be consistent
+// with this value, and pre-OS measurements must not be made to these PCRs after the separator.
+//
+// https://trustedcomputinggroup.org/wp-content/uploads/TCG_PCClientSpecPlat_TPM_2p0_1p04_pub.pdf
+//  (section 3.3.4 "PCR Usage", section 9.4.1 "Event Types")
+func CheckSeparators(log *Log) (out []*ValidationFinding) {
+	errorValue := make([]byte, 4)
+	binary.LittleEndian.PutUint32(errorValue, SeparatorEventErrorValue)
+
+	separators := make(map[PCRIndex]*Event)
+	for _, e := range log.Events {
+		if e.EventType != EventTypeSeparator {
+			if sep, seen := separators[e.PCRIndex]; seen && e.PCRIndex <= 7 && e.EventType != EventTypeNoAction &&
+				!isPostSeparatorEventAllowed(e) {
+				out = append(out, &ValidationFinding{Severity: SeverityWarning, Event: e,
+					Message: fmt.Sprintf("the event was measured after the separator (event %d)", sep.Index)})
+			}
+			continue
+		}
+
+		if sep, seen := separators[e.PCRIndex]; seen {
+			out = append(out, &ValidationFinding{Severity: SeverityError, Event: e,
+				Message: fmt.Sprintf("the PCR already contains a separator (event %d)", sep.Index)})
+		} else {
+			separators[e.PCRIndex] = e
+		}
+
+		isError := false
+		if d, ok := e.Data.(*SeparatorEventData); ok {
+			isError = d.IsError
+		}
+
+		expected := e.Data.Bytes()
+		switch {
+		case isError:
+			out = append(out, &ValidationFinding{Severity: SeverityWarning, Event: e,
+				Message: "the separator indicates that an error occurred in the pre-OS environment"})
+			expected = errorValue
+		case !isNormalSeparatorValue(expected):
+			out = append(out, &ValidationFinding{Severity: SeverityError, Event: e,
+				Message: fmt.Sprintf("the separator has an invalid value (%x)", expected)})
+			continue
+		}
+
+		for _, alg := range log.Algorithms {
+			digest, ok := e.Digests[alg]
+			if !ok || !alg.supported() {
+				continue
+			}
+			if !bytes.Equal(digest, alg.hash(expected)) {
+				out = append(out, &ValidationFinding{Severity: SeverityError, Event: e,
+					Message: fmt.Sprintf("the %v digest is inconsistent with the separator value", alg)})
+			}
+		}
+	}
+
+	for pcr := PCRIndex(0); pcr <= 7; pcr++ {
+		if _, ok := separators[pcr]; !ok {
+			out = append(out, &ValidationFinding{Severity: SeverityError,
+				Message: fmt.Sprintf("there is no separator in PCR %d", pcr)})
+		}
+	}
+
+	return out
+}
+
+func isZeroDigest(d Digest) bool {
+	for _, b := range d {
+		if b != 0 {
+			return false
+		}
+	}
+	return true
+}
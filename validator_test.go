@@ -0,0 +1,297 @@
+// Copyright 2019 Canonical Ltd.
+// Licensed under the LGPLv3 with static-linking exception.
+// See LICENCE file for details.
+
+package tcglog
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+)
+
+func TestValidator(t *testing.T) {
+	varData := EFIVariableData{
+		VariableName: EFIGlobalVariableGuid,
+		UnicodeName:  "BootOrder",
+		VariableData: []byte{0x01, 0x00, 0x02, 0x00}}
+	var varBytes bytes.Buffer
+	varData.EncodeMeasuredBytes(&varBytes)
+
+	quirk := makeTestEvent(1, EventTypeEFIVariableBoot, varBytes.Bytes(), AlgorithmSha256)
+	quirk.Digests[AlgorithmSha256] = AlgorithmSha256.hash(varData.VariableData)
+
+	bad := makeTestEvent(4, EventTypeEFIAction, []byte("Calling EFI Application from Boot Option"), AlgorithmSha256)
+	bad.Digests[AlgorithmSha256] = AlgorithmSha256.hash([]byte("foo"))
+
+	noAction := makeTestEvent(0, EventTypeNoAction, append([]byte("StartupLocality\x00"), 3), AlgorithmSha256)
+	noAction.Digests[AlgorithmSha256] = AlgorithmSha256.hash([]byte("foo"))
+
+	events := []*Event{
+		noAction,
+		makeTestEvent(0, EventTypeSCRTMVersion, []byte{0x31, 0x00}, AlgorithmSha256),
+		quirk,
+		bad}
+	for pcr := PCRIndex(0); pcr <= 7; pcr++ {
+		events = append(events, makeTestEvent(pcr, EventTypeSeparator, []byte{0, 0, 0, 0}, AlgorithmSha256))
+	}
+	log := NewLog(events)
+
+	v := NewValidator()
+	if !reflect.DeepEqual(v.Checks(),
+		[]string{"digests", "bank-consistency", "quirks", "separators", "ordering", "duplicate-authorities", "authority-anchors",
+			"dbx-revocations", "pfp-compliance", "spec-compliance"}) {
+		t.Errorf("Unexpected checks: %v", v.Checks())
+	}
+
+	v.AddCheck("pfp-compliance", nil)
+
+	findings := v.Validate(log)
+	if len(findings) != 3 {
+		for _, f := range findings {
+			t.Logf("%s", f)
+		}
+		t.Fatalf("Unexpected number of findings (%d)", len(findings))
+	}
+
+	for i, expected := range []struct {
+		check    string
+		severity ValidationSeverity
+		event    *Event
+	}{
+		{check: "spec-compliance", severity: SeverityError, event: noAction},
+		{check: "quirks", severity: SeverityWarning, event: quirk},
+		{check: "digests", severity: SeverityError, event: bad},
+	} {
+		f := findings[i]
+		if f.Check != expected.check || f.Severity != expected.severity || f.Event != expected.event || f.Message == "" {
+			t.Errorf("Unexpected finding %d: %s", i, f)
+		}
+	}
+}
+
+func TestValidatorAddCheck(t *testing.T) {
+	log := NewLog([]*Event{makeTestEvent(4, EventTypeSeparator, []byte{0, 0, 0, 0}, AlgorithmSha256)})
+
+	v := NewValidator()
+	v.AddCheck("custom", func(log *Log) []*ValidationFinding {
+		return []*ValidationFinding{{Severity: SeverityInfo, Message: "found " + log.Events[0].EventType.String()}}
+	})
+	v.AddCheck("digests", nil)
+	v.AddCheck("bank-consistency", nil)
+	v.AddCheck("separators", nil)
+	v.AddCheck("ordering", nil)
+	v.AddCheck("duplicate-authorities", nil)
+	v.AddCheck("authority-anchors", nil)
+	v.AddCheck("dbx-revocations", nil)
+	v.AddCheck("pfp-compliance", nil)
+	if !reflect.DeepEqual(v.Checks(), []string{"quirks", "spec-compliance", "custom"}) {
+		t.Errorf("Unexpected checks: %v", v.Checks())
+	}
+
+	findings := v.Validate(log)
+	if len(findings) != 1 {
+		t.Fatalf("Unexpected number of findings (%d)", len(findings))
+	}
+	if findings[0].String() != "info: custom: found EV_SEPARATOR" {
+		t.Errorf("Unexpected finding: %s", findings[0])
+	}
+
+	var empty Validator
+	if findings := empty.Validate(log); len(findings) != 0 {
+		t.Errorf("Unexpected findings from an empty validator")
+	}
+}
+
+func TestCheckSeparators(t *testing.T) {
+	separators := func(skip PCRIndex) (out []*Event) {
+		for pcr := PCRIndex(0); pcr <= 7; pcr++ {
+			if pcr == skip {
+				continue
+			}
+			out = append(out, makeTestEvent(pcr, EventTypeSeparator, []byte{0, 0, 0, 0}, AlgorithmSha1, AlgorithmSha256))
+		}
+		return out
+	}
+
+	errorSeparator := makeTestEvent(1, EventTypeSeparator, []byte{0x01, 0x00, 0x00, 0x00}, AlgorithmSha1, AlgorithmSha256)
+	errorSeparator.Data = DecodeEventData(1, EventTypeSeparator, errorSeparator.Digests, []byte("foo"), nil)
+
+	badDigest := makeTestEvent(1, EventTypeSeparator, []byte{0, 0, 0, 0}, AlgorithmSha1, AlgorithmSha256)
+	badDigest.Digests[AlgorithmSha256] = AlgorithmSha256.hash([]byte{0xff, 0xff, 0xff, 0xff})
+
+	var legacySeparators []*Event
+	for pcr := PCRIndex(0); pcr <= 7; pcr++ {
+		legacySeparators = append(legacySeparators, makeTestEvent(pcr, EventTypeSeparator, []byte{0, 0, 0, 0}, AlgorithmSha1))
+	}
+
+	for _, data := range []struct {
+		desc     string
+		events   []*Event
+		expected []string
+	}{
+		{
+			desc: "Valid",
+			events: append(append([]*Event{
+				makeTestEvent(4, EventTypeEFIAction, []byte("Calling EFI Application from Boot Option"), AlgorithmSha1, AlgorithmSha256)},
+				separators(99)...),
+				makeTestEvent(4, EventTypeEFIBootServicesApplication, makeTestImageLoadEventData("\\EFI\\ubuntu\\shimx64.efi"),
+					AlgorithmSha1, AlgorithmSha256),
+				makeTestEvent(5, EventTypeEFIAction, []byte("Exit Boot Services Invocation"), AlgorithmSha1, AlgorithmSha256),
+				makeTestEvent(8, EventTypeIPL, []byte("grub_cmd: linux /vmlinuz\x00"), AlgorithmSha1, AlgorithmSha256)),
+		},
+		{
+			desc:     "Missing",
+			events:   separators(3),
+			expected: []string{"error: separators: there is no separator in PCR 3"},
+		},
+		{
+			desc:   "Duplicate",
+			events: append(separators(99), makeTestEvent(2, EventTypeSeparator, []byte{0, 0, 0, 0}, AlgorithmSha1, AlgorithmSha256)),
+			expected: []string{
+				"error: separators: event 1 in PCR 2 (type: EV_SEPARATOR): the PCR already contains a separator (event 0)"},
+		},
+		{
+			desc:   "InvalidValue",
+			events: append(separators(1), makeTestEvent(1, EventTypeSeparator, []byte{1, 2, 3}, AlgorithmSha1, AlgorithmSha256)),
+			expected: []string{
+				"error: separators: event 0 in PCR 1 (type: EV_SEPARATOR): the separator has an invalid value (010203)"},
+		},
+		{
+			desc:   "Error",
+			events: append(separators(1), errorSeparator),
+			expected: []string{
+				"warning: separators: event 0 in PCR 1 (type: EV_SEPARATOR): the separator indicates that an error occurred in the " +
+					"pre-OS environment"},
+		},
+		{
+			desc:   "BadDigest",
+			events: append(separators(1), badDigest),
+			expected: []string{
+				"error: separators: event 0 in PCR 1 (type: EV_SEPARATOR): the SHA-256 digest is inconsistent with the separator value"},
+		},
+		{
+			desc: "BootAttempts",
+			events: append(append([]*Event{
+				makeTestEvent(4, EventTypeEFIAction, []byte("Calling EFI Application from Boot Option"), AlgorithmSha1, AlgorithmSha256)},
+				separators(99)...),
+				makeTestEvent(4, EventTypeEFIAction, []byte("Returning from EFI Application from Boot Option"), AlgorithmSha1,
+					AlgorithmSha256),
+				makeTestEvent(4, EventTypeEFIAction, []byte("Calling EFI Application from Boot Option"), AlgorithmSha1, AlgorithmSha256),
+				makeTestEvent(4, EventTypeEFIBootServicesApplication, makeTestImageLoadEventData("\\EFI\\ubuntu\\shimx64.efi"),
+					AlgorithmSha1, AlgorithmSha256)),
+		},
+		{
+			desc: "Legacy",
+			events: append(append([]*Event{
+				makeTestEvent(0, EventTypeSCRTMVersion, []byte("1.00"), AlgorithmSha1),
+				makeTestEvent(4, EventTypeAction, []byte("Calling INT 19h"), AlgorithmSha1)},
+				legacySeparators...),
+				makeTestEvent(4, EventTypeIPL, make([]byte, 440), AlgorithmSha1),
+				makeTestEvent(5, EventTypeIPLPartitionData, make([]byte, 64), AlgorithmSha1),
+				makeTestEvent(4, EventTypeAction, []byte("Returned INT 19h"), AlgorithmSha1),
+				makeTestEvent(4, EventTypeAction, []byte("Calling INT 19h"), AlgorithmSha1),
+				makeTestEvent(4, EventTypeIPL, make([]byte, 440), AlgorithmSha1)),
+		},
+		{
+			desc: "NonStandardActionAfterSeparator",
+			events: append(separators(99),
+				makeTestEvent(4, EventTypeEFIAction, []byte("Loading shim"), AlgorithmSha1, AlgorithmSha256)),
+			expected: []string{
+				"warning: separators: event 1 in PCR 4 (type: EV_EFI_ACTION): the event was measured after the separator (event 0)"},
+		},
+		{
+			desc: "MeasuredAfterSeparator",
+			events: append(separators(99),
+				makeTestEvent(1, EventTypeEFIVariableBoot, []byte("foo"), AlgorithmSha1, AlgorithmSha256)),
+			expected: []string{
+				"warning: separators: event 1 in PCR 1 (type: EV_EFI_VARIABLE_BOOT): the event was measured after the separator (event 0)"},
+		},
+	} {
+		t.Run(data.desc, func(t *testing.T) {
+			v := new(Validator)
+			v.AddCheck("separators", CheckSeparators)
+
+			var findings []string
+			for _, f := range v.Validate(NewLog(data.events)) {
+				findings = append(findings, f.String())
+			}
+			if !reflect.DeepEqual(findings, data.expected) {
+				t.Errorf("Unexpected findings: %q", findings)
+			}
+		})
+	}
+}
+
+func TestCheckBankConsistency(t *testing.T) {
+	varData := EFIVariableData{
+		VariableName: EFIGlobalVariableGuid,
+		UnicodeName:  "BootOrder",
+		VariableData: []byte{0x01, 0x00, 0x02, 0x00}}
+	var varBytes bytes.Buffer
+	varData.EncodeMeasuredBytes(&varBytes)
+
+	algs := AlgorithmIdList{AlgorithmSha1, AlgorithmSha256}
+
+	consistentQuirk := makeTestEvent(1, EventTypeEFIVariableBoot, varBytes.Bytes(), algs...)
+	consistentQuirk.Digests[AlgorithmSha1] = AlgorithmSha1.hash(varData.VariableData)
+	consistentQuirk.Digests[AlgorithmSha256] = AlgorithmSha256.hash(varData.VariableData)
+
+	differentForms := makeTestEvent(1, EventTypeEFIVariableBoot, varBytes.Bytes(), algs...)
+	differentForms.Digests[AlgorithmSha256] = AlgorithmSha256.hash(varData.VariableData)
+
+	differentData := makeTestEvent(4, EventTypeEFIAction, []byte("Calling EFI Application from Boot Option"), algs...)
+	differentData.Digests[AlgorithmSha256] = AlgorithmSha256.hash([]byte("foo"))
+
+	bothWrong := makeTestEvent(4, EventTypeEFIAction, []byte("Calling EFI Application from Boot Option"), algs...)
+	bothWrong.Digests[AlgorithmSha1] = AlgorithmSha1.hash([]byte("foo"))
+	bothWrong.Digests[AlgorithmSha256] = AlgorithmSha256.hash([]byte("foo"))
+
+	zero := makeTestEvent(4, EventTypeEFIBootServicesApplication, makeTestImageLoadEventData("\\EFI\\ubuntu\\shimx64.efi"),
+		algs...)
+	zero.Digests[AlgorithmSha256] = make(Digest, AlgorithmSha256.Size())
+
+	for _, data := range []struct {
+		desc     string
+		event    *Event
+		expected []string
+	}{
+		{desc: "Consistent", event: makeTestEvent(4, EventTypeEFIAction, []byte("Exit Boot Services Invocation"), algs...)},
+		{desc: "ConsistentQuirk", event: consistentQuirk},
+		{
+			desc:  "DifferentForms",
+			event: differentForms,
+			expected: []string{
+				"error: bank-consistency: event 0 in PCR 1 (type: EV_EFI_VARIABLE_BOOT): the digests in each bank are of different " +
+					"forms of the event data"},
+		},
+		{
+			desc:  "DifferentData",
+			event: differentData,
+			expected: []string{
+				"error: bank-consistency: event 0 in PCR 4 (type: EV_EFI_ACTION): the digests in banks [SHA-1] are consistent with " +
+					"the event data but the digests in banks [SHA-256] are not"},
+		},
+		{desc: "BothWrong", event: bothWrong},
+		{
+			desc:  "ZeroDigest",
+			event: zero,
+			expected: []string{
+				"error: bank-consistency: event 0 in PCR 4 (type: EV_EFI_BOOT_SERVICES_APPLICATION): the SHA-256 digest is all " +
+					"zeroes but the other banks contain a measurement"},
+		},
+	} {
+		t.Run(data.desc, func(t *testing.T) {
+			v := new(Validator)
+			v.AddCheck("bank-consistency", CheckBankConsistency)
+
+			var findings []string
+			for _, f := range v.Validate(NewLog([]*Event{data.event})) {
+				findings = append(findings, f.String())
+			}
+			if !reflect.DeepEqual(findings, data.expected) {
+				t.Errorf("Unexpected findings: %q", findings)
+			}
+		})
+	}
+}
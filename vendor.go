@@ -0,0 +1,92 @@
+// Copyright 2019 Canonical Ltd.
+// Licensed under the LGPLv3 with static-linking exception.
+// See LICENCE file for details.
+
+package tcglog
+
+import (
+	"bytes"
+	"sort"
+	"sync"
+)
+
+// VendorMatcher is a function that determines whether a log was produced by a specific vendor's firmware, from the vendorInfo
+// field of the log's Spec ID event.
+type VendorMatcher func(vendorInfo []byte) bool
+
+var (
+	firmwareVendorsMu sync.RWMutex
+	firmwareVendors   = make(map[string]VendorMatcher)
+)
+
+// RegisterFirmwareVendor registers a name for the firmware of a specific vendor, which is identified from the vendorInfo field of
+// a log's Spec ID event by the supplied match function. The name is returned from Log.FirmwareVendor and is used to select entries
+// registered with RegisterFirmwareQuirks. Registering a vendor replaces any vendor previously registered with the same name, and
+// registering a nil match function removes it.
+//
+// Decoders for proprietary event data emitted by a vendor's firmware are registered with RegisterEventDataDecoder.
+func RegisterFirmwareVendor(name string, match VendorMatcher) {
+	firmwareVendorsMu.Lock()
+	defer firmwareVendorsMu.Unlock()
+
+	if match == nil {
+		delete(firmwareVendors, name)
+		return
+	}
+	firmwareVendors[name] = match
+}
+
+// FirmwareVendors returns the names of the registered firmware vendors.
+func FirmwareVendors() []string {
+	firmwareVendorsMu.RLock()
+	defer firmwareVendorsMu.RUnlock()
+
+	var names []string
+	for name := range firmwareVendors {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// matchVendor returns the name of the firmware vendor that matches the supplied vendorInfo, or an empty string if there isn't
+// one.
+func matchVendor(vendorInfo []byte) string {
+	firmwareVendorsMu.RLock()
+	defer firmwareVendorsMu.RUnlock()
+
+	var names []string
+	for name, match := range firmwareVendors {
+		if match(vendorInfo) {
+			names = append(names, name)
+		}
+	}
+	if len(names) == 0 {
+		return ""
+	}
+	sort.Strings(names)
+	return names[0]
+}
+
+// MakeVendorInfoMatcher returns a VendorMatcher that matches vendorInfo fields that begin with any of the supplied vendor names,
+// ignoring case. The name must be followed by the end of the field or a character that isn't a letter or digit, so that "Dell"
+// matches "Dell Inc." but not "Dellware".
+func MakeVendorInfoMatcher(names ...string) VendorMatcher {
+	return func(vendorInfo []byte) bool {
+		vendorInfo = bytes.ToLower(bytes.TrimSpace(bytes.TrimRight(vendorInfo, "\x00")))
+		for _, name := range names {
+			name := bytes.ToLower([]byte(name))
+			if !bytes.HasPrefix(vendorInfo, name) {
+				continue
+			}
+			if len(vendorInfo) == len(name) {
+				return true
+			}
+			c := vendorInfo[len(name)]
+			if !(c >= 'a' && c <= 'z') && !(c >= '0' && c <= '9') {
+				return true
+			}
+		}
+		return false
+	}
+}
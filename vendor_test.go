@@ -0,0 +1,126 @@
+// Copyright 2019 Canonical Ltd.
+// Licensed under the LGPLv3 with static-linking exception.
+// See LICENCE file for details.
+
+package tcglog
+
+import (
+	"bytes"
+	"testing"
+)
+
+// registerTestFirmwareVendors registers "dell" and "lenovo" firmware vendors, and returns a function that removes them again.
+func registerTestFirmwareVendors() func() {
+	RegisterFirmwareVendor("dell", MakeVendorInfoMatcher("Dell"))
+	RegisterFirmwareVendor("lenovo", MakeVendorInfoMatcher("Lenovo", "LNV"))
+	return func() {
+		RegisterFirmwareVendor("dell", nil)
+		RegisterFirmwareVendor("lenovo", nil)
+	}
+}
+
+func makeTestVendorLog(t *testing.T, vendorInfo []byte, legacy bool) []byte {
+	algs := AlgorithmIdList{AlgorithmSha256}
+	specId := encodeSpecIdEvent03(&SpecIdEvent{SpecVersionMajor: 2, UintnSize: 2, VendorInfo: vendorInfo}, algs)
+	if legacy {
+		algs = AlgorithmIdList{AlgorithmSha1}
+		specId = encodeSpecIdEvent02(&SpecIdEvent{UintnSize: 2, VendorInfo: vendorInfo})
+	}
+	log := NewLog([]*Event{
+		makeTestEvent(0, EventTypeNoAction, specId, algs...),
+		makeTestEvent(1, EventTypeTableOfDevices, []byte("Diagnostics Config\x00"), algs...)})
+
+	var b bytes.Buffer
+	write := WriteLog
+	if legacy {
+		write = WriteLegacyLog
+	}
+	if err := write(&b, log); err != nil {
+		t.Fatalf("Cannot write log: %v", err)
+	}
+	return b.Bytes()
+}
+
+func TestLogFirmwareVendor(t *testing.T) {
+	defer registerTestFirmwareVendors()()
+
+	for _, data := range []struct {
+		desc       string
+		vendorInfo []byte
+		legacy     bool
+		expected   string
+	}{
+		{
+			desc:       "Dell",
+			vendorInfo: []byte("Dell Inc."),
+			expected:   "dell",
+		},
+		{
+			desc:       "DellLegacy",
+			vendorInfo: []byte("Dell Inc."),
+			legacy:     true,
+			expected:   "dell",
+		},
+		{
+			desc:       "Lenovo",
+			vendorInfo: []byte("LNV\x00"),
+			expected:   "lenovo",
+		},
+		{
+			desc:       "NoMatch",
+			vendorInfo: []byte("Modell"),
+		},
+		{
+			desc: "NoVendorInfo",
+		},
+	} {
+		t.Run(data.desc, func(t *testing.T) {
+			log, err := ParseLog(bytes.NewReader(makeTestVendorLog(t, data.vendorInfo, data.legacy)), &LogOptions{})
+			if err != nil {
+				t.Fatalf("ParseLog failed: %v", err)
+			}
+			if vendor := log.FirmwareVendor(); vendor != data.expected {
+				t.Errorf("Unexpected vendor: %q", vendor)
+			}
+		})
+	}
+}
+
+func TestFirmwareVendors(t *testing.T) {
+	if names := FirmwareVendors(); len(names) != 0 {
+		t.Errorf("Unexpected firmware vendors: %v", names)
+	}
+
+	cleanup := registerTestFirmwareVendors()
+	names := FirmwareVendors()
+	if len(names) != 2 || names[0] != "dell" || names[1] != "lenovo" {
+		t.Errorf("Unexpected firmware vendors: %v", names)
+	}
+
+	cleanup()
+	if names := FirmwareVendors(); len(names) != 0 {
+		t.Errorf("Unexpected firmware vendors after removal: %v", names)
+	}
+}
+
+func TestMakeVendorInfoMatcher(t *testing.T) {
+	match := MakeVendorInfoMatcher("Dell", "LNV")
+	for _, data := range []struct {
+		vendorInfo string
+		expected   bool
+	}{
+		{vendorInfo: "Dell Inc.", expected: true},
+		{vendorInfo: "DELL", expected: true},
+		{vendorInfo: " dell\x00\x00", expected: true},
+		{vendorInfo: "LNV-1.0", expected: true},
+		{vendorInfo: "Modell"},
+		{vendorInfo: "Dellware"},
+		{vendorInfo: "Dell2"},
+		{vendorInfo: "Lenovo"},
+		{vendorInfo: ""},
+	} {
+		if match([]byte(data.vendorInfo)) != data.expected {
+			t.Errorf("Unexpected result for %q", data.vendorInfo)
+		}
+	}
+}
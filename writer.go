@@ -0,0 +1,189 @@
+// Copyright 2019 Canonical Ltd.
+// Licensed under the LGPLv3 with static-linking exception.
+// See LICENCE file for details.
+
+package tcglog
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+
+	"golang.org/x/xerrors"
+)
+
+// encodeSpecIdEvent03 encodes a TCG_EfiSpecIdEvent structure for the supplied digest algorithms. The remaining fields are
+// copied from template if it is not nil.
+func encodeSpecIdEvent03(template *SpecIdEvent, algs AlgorithmIdList) []byte {
+	d := SpecIdEvent{Spec: SpecEFI_2, SpecVersionMajor: 2, UintnSize: 2}
+	if template != nil {
+		d = *template
+		d.Spec = SpecEFI_2
+	}
+	d.DigestSizes = nil
+	for _, alg := range algs {
+		d.DigestSizes = append(d.DigestSizes, EFISpecIdEventAlgorithmSize{AlgorithmId: alg, DigestSize: uint16(alg.Size())})
+	}
+
+	var w bytes.Buffer
+	d.EncodeTo(&w)
+	return w.Bytes()
+}
+
+// encodeSpecIdEvent02 encodes a TCG_EfiSpecIdEventStruct structure. The fields are copied from template if it is not nil, with
+// the exception of the specification version which is always 1.2.
+func encodeSpecIdEvent02(template *SpecIdEvent) []byte {
+	d := SpecIdEvent{UintnSize: 2}
+	if template != nil {
+		d = *template
+	}
+	d.Spec = SpecEFI_1_2
+	d.SpecVersionMinor = 2
+	d.SpecVersionMajor = 1
+	d.SpecErrata = 2
+
+	var w bytes.Buffer
+	d.EncodeTo(&w)
+	return w.Bytes()
+}
+
+// specIdEventMatches indicates whether the supplied Spec ID event describes a crypto-agile log containing exactly the
+// supplied digest algorithms, in the same order.
+func specIdEventMatches(d *SpecIdEvent, algs AlgorithmIdList) bool {
+	if d.Spec != SpecEFI_2 || len(d.DigestSizes) != len(algs) {
+		return false
+	}
+	for i, s := range d.DigestSizes {
+		if s.AlgorithmId != algs[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// writeEvent_1_2 writes an event in the format of a TCG_PCClientPCREventStruct.
+func writeEvent_1_2(w io.Writer, pcrIndex PCRIndex, eventType EventType, digest Digest, data []byte) error {
+	if len(digest) != AlgorithmSha1.Size() {
+		return fmt.Errorf("invalid SHA-1 digest length (%d)", len(digest))
+	}
+
+	var b bytes.Buffer
+	binary.Write(&b, binary.LittleEndian, eventHeader_1_2{PCRIndex: pcrIndex, EventType: eventType})
+	b.Write(digest)
+	binary.Write(&b, binary.LittleEndian, uint32(len(data)))
+	b.Write(data)
+
+	_, err := b.WriteTo(w)
+	return err
+}
+
+// writeEvent_2 writes an event in the format of a TCG_PCR_EVENT2 structure, with digests for the supplied algorithms.
+func writeEvent_2(w io.Writer, event *Event, algs AlgorithmIdList) error {
+	var b bytes.Buffer
+	binary.Write(&b, binary.LittleEndian, eventHeader_2{PCRIndex: event.PCRIndex, EventType: event.EventType, Count: uint32(len(algs))})
+	for _, alg := range algs {
+		digest, ok := event.Digests[alg]
+		if !ok {
+			return fmt.Errorf("missing digest for algorithm %v", alg)
+		}
+		if len(digest) != alg.Size() {
+			return fmt.Errorf("invalid digest length for algorithm %v (%d)", alg, len(digest))
+		}
+		binary.Write(&b, binary.LittleEndian, alg)
+		b.Write(digest)
+	}
+	data, err := encodeEventData(event.Data)
+	if err != nil {
+		return xerrors.Errorf("cannot encode event data: %w", err)
+	}
+	binary.Write(&b, binary.LittleEndian, uint32(len(data)))
+	b.Write(data)
+
+	_, err = b.WriteTo(w)
+	return err
+}
+
+// WriteLog serializes the supplied log to w in the crypto-agile format defined by the TCG PC Client Platform Firmware Profile
+// Specification, with digests for each of the algorithms in log.Algorithms. Every event must have a digest for each of these
+// algorithms.
+//
+// The first event written is always a Spec ID event that describes the digest algorithms in the log. If the first event in the
+// log is a Spec ID event that already does this, it is written unmodified. If it is a Spec ID event that doesn't (eg, because the
+// log was parsed from the legacy format, or because it contains algorithms that aren't supported by this package and so have no
+// digests), then a replacement is written that retains its other fields. Otherwise, a new Spec ID event is inserted.
+func WriteLog(w io.Writer, log *Log) error {
+	if len(log.Algorithms) == 0 {
+		return errors.New("log has no digest algorithms")
+	}
+	for _, alg := range log.Algorithms {
+		if !alg.supported() {
+			return fmt.Errorf("unsupported algorithm %v", alg)
+		}
+	}
+
+	var specId *SpecIdEvent
+	if len(log.Events) > 0 && isSpecIdEvent(log.Events[0]) {
+		specId = log.Events[0].Data.(*SpecIdEvent)
+	}
+
+	var specIdData []byte
+	switch {
+	case specId != nil && specIdEventMatches(specId, log.Algorithms):
+		data, err := encodeEventData(specId)
+		if err != nil {
+			return xerrors.Errorf("cannot encode Spec ID event: %w", err)
+		}
+		specIdData = data
+	case specId != nil && specId.Spec == SpecEFI_2:
+		specIdData = encodeSpecIdEvent03(specId, log.Algorithms)
+	default:
+		specIdData = encodeSpecIdEvent03(nil, log.Algorithms)
+	}
+	if err := writeEvent_1_2(w, 0, EventTypeNoAction, make(Digest, AlgorithmSha1.Size()), specIdData); err != nil {
+		return xerrors.Errorf("cannot write Spec ID event: %w", err)
+	}
+
+	for i, event := range log.Events {
+		if i == 0 && specId != nil {
+			continue
+		}
+		if err := writeEvent_2(w, event, log.Algorithms); err != nil {
+			return xerrors.Errorf("cannot write event %d (PCR %d, %v): %w", i, event.PCRIndex, event.EventType, err)
+		}
+	}
+
+	return nil
+}
+
+// WriteLegacyLog serializes the supplied log to w in the SHA-1 only format used by firmware for TPM 1.2 devices, as defined by the
+// TCG PC Client Specific Implementation Specification for Conventional BIOS and the TCG EFI Platform Specification For TPM Family
+// 1.1 or 1.2. Every event must have a SHA-1 digest.
+//
+// If the first event in the log is a Spec ID event for the crypto-agile format, it is replaced by an EFI 1.2 Spec ID event that
+// retains its platform class, UINTN size and vendor info, as the log would otherwise be interpreted as a crypto-agile log when it
+// is parsed. Other Spec ID events are written unmodified.
+func WriteLegacyLog(w io.Writer, log *Log) error {
+	for i, event := range log.Events {
+		data, err := encodeEventData(event.Data)
+		if err != nil {
+			return xerrors.Errorf("cannot encode data for event %d (PCR %d, %v): %w", i, event.PCRIndex, event.EventType, err)
+		}
+		if i == 0 && isSpecIdEvent(event) {
+			if d := event.Data.(*SpecIdEvent); d.Spec == SpecEFI_2 {
+				data = encodeSpecIdEvent02(d)
+			}
+		}
+
+		digest, ok := event.Digests[AlgorithmSha1]
+		if !ok {
+			return fmt.Errorf("cannot write event %d (PCR %d, %v): missing SHA-1 digest", i, event.PCRIndex, event.EventType)
+		}
+		if err := writeEvent_1_2(w, event.PCRIndex, event.EventType, digest, data); err != nil {
+			return xerrors.Errorf("cannot write event %d (PCR %d, %v): %w", i, event.PCRIndex, event.EventType, err)
+		}
+	}
+
+	return nil
+}
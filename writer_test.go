@@ -0,0 +1,205 @@
+// Copyright 2019 Canonical Ltd.
+// Licensed under the LGPLv3 with static-linking exception.
+// See LICENCE file for details.
+
+package tcglog
+
+import (
+	"bytes"
+	"io"
+	"testing"
+)
+
+func makeTestEvent(pcr PCRIndex, eventType EventType, data []byte, algs ...AlgorithmId) *Event {
+	digests := make(DigestMap)
+	for _, alg := range algs {
+		digests[alg] = alg.hash(data)
+	}
+	return &Event{PCRIndex: pcr, EventType: eventType, Digests: digests, Data: DecodeEventData(pcr, eventType, digests, data, nil)}
+}
+
+func TestWriteLog(t *testing.T) {
+	algs := AlgorithmIdList{AlgorithmSha1, AlgorithmSha256}
+	log := NewLog([]*Event{
+		makeTestEvent(0, EventTypeSCRTMVersion, []byte{0x31, 0x00}, algs...),
+		makeTestEvent(4, EventTypeEFIAction, []byte("Calling EFI Application from Boot Option"), algs...),
+		makeTestEvent(4, EventTypeSeparator, []byte{0, 0, 0, 0}, algs...),
+		makeTestEvent(7, EventTypeSeparator, []byte{0, 0, 0, 0}, algs...)})
+
+	var b bytes.Buffer
+	if err := WriteLog(&b, log); err != nil {
+		t.Fatalf("WriteLog failed: %v", err)
+	}
+	data := b.Bytes()
+
+	parsed, err := ParseLog(bytes.NewReader(data), &LogOptions{})
+	if err != nil {
+		t.Fatalf("ParseLog failed: %v", err)
+	}
+	if parsed.Spec != SpecEFI_2 {
+		t.Errorf("Unexpected spec: %v", parsed.Spec)
+	}
+	if len(parsed.Algorithms) != 2 || parsed.Algorithms[0] != AlgorithmSha1 || parsed.Algorithms[1] != AlgorithmSha256 {
+		t.Errorf("Unexpected algorithms: %v", parsed.Algorithms)
+	}
+	if len(parsed.Events) != len(log.Events)+1 {
+		t.Fatalf("Unexpected number of events (%d)", len(parsed.Events))
+	}
+	if !isSpecIdEvent(parsed.Events[0]) {
+		t.Errorf("First event is not a Spec ID event")
+	}
+	for i, e := range parsed.Events[1:] {
+		orig := log.Events[i]
+		if e.PCRIndex != orig.PCRIndex || e.EventType != orig.EventType {
+			t.Errorf("Unexpected event %d: %d %v", i, e.PCRIndex, e.EventType)
+		}
+		for _, alg := range algs {
+			if !bytes.Equal(e.Digests[alg], orig.Digests[alg]) {
+				t.Errorf("Unexpected %v digest for event %d", alg, i)
+			}
+		}
+		if !bytes.Equal(e.Data.Bytes(), orig.Data.Bytes()) {
+			t.Errorf("Unexpected data for event %d", i)
+		}
+	}
+
+	// Writing the parsed log should produce identical output.
+	b.Reset()
+	if err := WriteLog(&b, parsed); err != nil {
+		t.Fatalf("WriteLog failed: %v", err)
+	}
+	if !bytes.Equal(b.Bytes(), data) {
+		t.Errorf("Log didn't round-trip")
+	}
+
+	// Writing a subset of the algorithms should produce a new Spec ID event.
+	parsed.Algorithms = AlgorithmIdList{AlgorithmSha256}
+	b.Reset()
+	if err := WriteLog(&b, parsed); err != nil {
+		t.Fatalf("WriteLog failed: %v", err)
+	}
+	parsed, err = ParseLog(&b, &LogOptions{})
+	if err != nil {
+		t.Fatalf("ParseLog failed: %v", err)
+	}
+	if len(parsed.Algorithms) != 1 || parsed.Algorithms[0] != AlgorithmSha256 {
+		t.Errorf("Unexpected algorithms: %v", parsed.Algorithms)
+	}
+	if len(parsed.Events) != len(log.Events)+1 {
+		t.Errorf("Unexpected number of events (%d)", len(parsed.Events))
+	}
+}
+
+func TestWriteLogModifiedEventData(t *testing.T) {
+	algs := AlgorithmIdList{AlgorithmSha1, AlgorithmSha256}
+	log := NewLog([]*Event{
+		makeTestEvent(4, EventTypeEFIBootServicesApplication, makeTestImageLoadEventData("\\EFI\\ubuntu\\shimx64.efi"), algs...)})
+	log.Events[0].Data.(*EFIImageLoadEvent).LocationInMemory = 0x1000
+
+	for _, data := range []struct {
+		desc  string
+		write func(io.Writer, *Log) error
+	}{
+		{desc: "CryptoAgile", write: WriteLog},
+		{desc: "Legacy", write: WriteLegacyLog},
+	} {
+		t.Run(data.desc, func(t *testing.T) {
+			var b bytes.Buffer
+			if err := data.write(&b, log); err != nil {
+				t.Fatalf("Cannot write log: %v", err)
+			}
+
+			parsed, err := ParseLog(&b, &LogOptions{})
+			if err != nil {
+				t.Fatalf("ParseLog failed: %v", err)
+			}
+			d, ok := parsed.Events[len(parsed.Events)-1].Data.(*EFIImageLoadEvent)
+			if !ok {
+				t.Fatalf("Unexpected event data type")
+			}
+			if d.LocationInMemory != 0x1000 || d.DevicePath != "\\EFI\\ubuntu\\shimx64.efi" {
+				t.Errorf("Unexpected event data: %s", d)
+			}
+		})
+	}
+
+	b, err := log.Events[0].MarshalBinaryLegacy()
+	if err != nil {
+		t.Fatalf("MarshalBinaryLegacy failed: %v", err)
+	}
+	var e Event
+	if err := e.UnmarshalBinaryLegacy(b); err != nil {
+		t.Fatalf("UnmarshalBinaryLegacy failed: %v", err)
+	}
+	if d, ok := e.Data.(*EFIImageLoadEvent); !ok || d.LocationInMemory != 0x1000 {
+		t.Errorf("Unexpected event data: %s", e.Data)
+	}
+}
+
+func TestWriteLogMissingDigest(t *testing.T) {
+	log := NewLog([]*Event{
+		makeTestEvent(0, EventTypeSCRTMVersion, []byte{0x31, 0x00}, AlgorithmSha1, AlgorithmSha256),
+		makeTestEvent(7, EventTypeSeparator, []byte{0, 0, 0, 0}, AlgorithmSha1)})
+	log.Algorithms = AlgorithmIdList{AlgorithmSha1, AlgorithmSha256}
+
+	var b bytes.Buffer
+	if err := WriteLog(&b, log); err == nil {
+		t.Errorf("WriteLog should fail for an event with a missing digest")
+	}
+}
+
+func TestWriteLegacyLog(t *testing.T) {
+	algs := AlgorithmIdList{AlgorithmSha1, AlgorithmSha256}
+	log := NewLog([]*Event{
+		makeTestEvent(0, EventTypeSCRTMVersion, []byte{0x31, 0x00}, algs...),
+		makeTestEvent(4, EventTypeEFIAction, []byte("Calling EFI Application from Boot Option"), algs...),
+		makeTestEvent(7, EventTypeSeparator, []byte{0, 0, 0, 0}, algs...)})
+
+	// Convert to a crypto-agile log first so that there is a Spec ID event to replace.
+	var b bytes.Buffer
+	if err := WriteLog(&b, log); err != nil {
+		t.Fatalf("WriteLog failed: %v", err)
+	}
+	log, err := ParseLog(&b, &LogOptions{})
+	if err != nil {
+		t.Fatalf("ParseLog failed: %v", err)
+	}
+
+	b.Reset()
+	if err := WriteLegacyLog(&b, log); err != nil {
+		t.Fatalf("WriteLegacyLog failed: %v", err)
+	}
+	parsed, err := ParseLog(&b, &LogOptions{})
+	if err != nil {
+		t.Fatalf("ParseLog failed: %v", err)
+	}
+
+	if parsed.Spec != SpecEFI_1_2 {
+		t.Errorf("Unexpected spec: %v", parsed.Spec)
+	}
+	if len(parsed.Algorithms) != 1 || parsed.Algorithms[0] != AlgorithmSha1 {
+		t.Errorf("Unexpected algorithms: %v", parsed.Algorithms)
+	}
+	if len(parsed.Events) != len(log.Events) {
+		t.Fatalf("Unexpected number of events (%d)", len(parsed.Events))
+	}
+	d, ok := parsed.Events[0].Data.(*SpecIdEvent)
+	if !ok {
+		t.Fatalf("First event is not a Spec ID event")
+	}
+	if d.SpecVersionMajor != 1 || d.SpecVersionMinor != 2 || d.UintnSize != 2 {
+		t.Errorf("Unexpected Spec ID event: %s", d)
+	}
+	for i, e := range parsed.Events[1:] {
+		orig := log.Events[i+1]
+		if e.PCRIndex != orig.PCRIndex || e.EventType != orig.EventType || e.Index != orig.Index {
+			t.Errorf("Unexpected event %d: %d %v %d", i, e.PCRIndex, e.EventType, e.Index)
+		}
+		if !bytes.Equal(e.Digests[AlgorithmSha1], orig.Digests[AlgorithmSha1]) {
+			t.Errorf("Unexpected digest for event %d", i)
+		}
+		if !bytes.Equal(e.Data.Bytes(), orig.Data.Bytes()) {
+			t.Errorf("Unexpected data for event %d", i)
+		}
+	}
+}